@@ -0,0 +1,201 @@
+package nodis
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/rdb"
+)
+
+// The archive of Backup is the magic followed by the version, then by the keys and by the end
+// of the archive. Every key is its name, its expiration and its value serialized by DUMP. The
+// end of the archive is the number of the keys and the CRC64 of all the preceding bytes.
+const (
+	backupMagic   = "NODISBAK"
+	BackupVersion = 1
+
+	backupOpKey byte = 1
+	backupOpEOF byte = 0xff
+)
+
+var (
+	ErrBackupFormat   = errors.New("nodis: bad backup format")
+	ErrBackupChecksum = errors.New("nodis: backup checksum mismatch")
+)
+
+// crcWriter computes the checksum of the bytes written
+type crcWriter struct {
+	w   io.Writer
+	crc uint64
+}
+
+func (c *crcWriter) Write(p []byte) (int, error) {
+	c.crc = rdb.CRC64(c.crc, p)
+	return c.w.Write(p)
+}
+
+// Backup writes a point-in-time archive of the keys to w, it can be loaded by RestoreBackup whatever
+// the storage. The changes are flushed to the storage first and the writers aren't stopped.
+// Only one backup or save runs at a time, Backup fails with ErrBackgroundSave otherwise.
+func (n *Nodis) Backup(ctx context.Context, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	cw := &crcWriter{w: bw}
+	buf := binary.LittleEndian.AppendUint16([]byte(backupMagic), BackupVersion)
+	if _, err := cw.Write(buf); err != nil {
+		return err
+	}
+	var count uint64
+	err := n.saveSnapshot(ctx, func(e *snapshotEntry) error {
+		buf = append(buf[:0], backupOpKey)
+		buf = binary.AppendUvarint(buf, uint64(len(e.key)))
+		buf = append(buf, e.key...)
+		buf = binary.AppendVarint(buf, e.expireAt)
+		buf = binary.AppendUvarint(buf, uint64(len(e.payload)))
+		if _, err := cw.Write(buf); err != nil {
+			return err
+		}
+		if _, err := cw.Write(e.payload); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	buf = binary.AppendUvarint(append(buf[:0], backupOpEOF), count)
+	if _, err = cw.Write(buf); err != nil {
+		return err
+	}
+	if _, err = bw.Write(binary.LittleEndian.AppendUint64(nil, cw.crc)); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// crcReader computes the checksum of the bytes read
+type crcReader struct {
+	r   *bufio.Reader
+	crc uint64
+}
+
+func (c *crcReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.crc = rdb.CRC64(c.crc, p[:n])
+	return n, err
+}
+
+func (c *crcReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.crc = rdb.CRC64(c.crc, []byte{b})
+	}
+	return b, err
+}
+
+// backupEntry is a key read from an archive
+type backupEntry struct {
+	key      string
+	expireAt int64
+	payload  []byte
+	value    ds.Value
+}
+
+// readBackup reads and checks all the keys of an archive
+func (n *Nodis) readBackup(r io.Reader) ([]*backupEntry, error) {
+	cr := &crcReader{r: bufio.NewReader(r)}
+	header := make([]byte, len(backupMagic)+2)
+	if _, err := io.ReadFull(cr, header); err != nil || string(header[:len(backupMagic)]) != backupMagic {
+		return nil, ErrBackupFormat
+	}
+	if binary.LittleEndian.Uint16(header[len(backupMagic):]) > BackupVersion {
+		return nil, ErrBackupFormat
+	}
+	var entries []*backupEntry
+	for {
+		op, err := cr.ReadByte()
+		if err != nil {
+			return nil, ErrBackupFormat
+		}
+		if op == backupOpEOF {
+			break
+		}
+		if op != backupOpKey {
+			return nil, ErrBackupFormat
+		}
+		e := &backupEntry{}
+		key, err := readBackupBytes(cr)
+		if err != nil {
+			return nil, err
+		}
+		e.key = string(key)
+		if e.expireAt, err = binary.ReadVarint(cr); err != nil {
+			return nil, ErrBackupFormat
+		}
+		if e.payload, err = readBackupBytes(cr); err != nil {
+			return nil, err
+		}
+		if e.value, err = rdb.Restore(e.payload, n.listOptions(), n.store.encodings); err != nil {
+			return nil, ErrBackupFormat
+		}
+		entries = append(entries, e)
+	}
+	count, err := binary.ReadUvarint(cr)
+	if err != nil || count != uint64(len(entries)) {
+		return nil, ErrBackupFormat
+	}
+	crc := cr.crc
+	b := make([]byte, 8)
+	if _, err = io.ReadFull(cr, b); err != nil {
+		return nil, ErrBackupFormat
+	}
+	if binary.LittleEndian.Uint64(b) != crc {
+		return nil, ErrBackupChecksum
+	}
+	return entries, nil
+}
+
+// readBackupBytes reads a length followed by as many bytes
+func readBackupBytes(r *crcReader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > 1<<32 {
+		return nil, ErrBackupFormat
+	}
+	b := make([]byte, min(n, 1<<16))
+	if uint64(len(b)) < n {
+		// the length is checked by reading before allocating it all
+		b, err = io.ReadAll(io.LimitReader(r, int64(n)))
+	} else {
+		_, err = io.ReadFull(r, b)
+	}
+	if err != nil || uint64(len(b)) != n {
+		return nil, ErrBackupFormat
+	}
+	return b, nil
+}
+
+// RestoreBackup replaces all the keys by the keys of an archive of Backup. The archive is checked
+// before the keys are replaced, the expired keys are skipped.
+func (n *Nodis) RestoreBackup(r io.Reader) error {
+	entries, err := n.readBackup(r)
+	if err != nil {
+		return err
+	}
+	n.Clear()
+	now := time.Now().UnixMilli()
+	for _, e := range entries {
+		if e.expireAt > 0 && e.expireAt <= now {
+			continue
+		}
+		_ = n.exec(func(tx *Tx) error {
+			n.putValue(tx, e.key, tx.writeKey(e.key, nil), e.value, &RestoreOptions{ExpireAt: e.expireAt}, e.payload)
+			return nil
+		})
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package nodis
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/rdb"
+)
+
+func TestBackup_RestoreBackup(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.Set("s", []byte("v"), false)
+	n.Expire("s", 3600)
+	n.RPush("list", []byte("a"), []byte("b"))
+	n.HSet("hash", "f", []byte("v"))
+	n.HSet("hash", "g", []byte("w"))
+	n.HExpire("hash", 3600, hash.ExpireAlways, "g")
+	var buf bytes.Buffer
+	if err := n.Backup(context.Background(), &buf); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+
+	m := Open(&Options{})
+	m.Set("old", []byte("v"), false)
+	if err := m.RestoreBackup(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	if m.Exists("old") != 0 {
+		t.Errorf("Exists() = 1, want the old key removed")
+	}
+	if v := m.Get("s"); string(v) != "v" || m.PExpireTime("s") != n.PExpireTime("s") {
+		t.Errorf("Get() = %s, PExpireTime() = %v", v, m.PExpireTime("s"))
+	}
+	if v := m.LRange("list", 0, -1); len(v) != 2 || string(v[1]) != "b" {
+		t.Errorf("LRange() = %v, want [a b]", v)
+	}
+	if v := m.HPExpireTime("hash", "g"); len(v) != 1 || v[0] <= 0 {
+		t.Errorf("HPExpireTime() = %v, want the expiration of the field", v)
+	}
+
+	b := bytes.Clone(buf.Bytes())
+	b[len(b)-1]++
+	if err := m.RestoreBackup(bytes.NewReader(b)); err != ErrBackupChecksum {
+		t.Errorf("RestoreBackup() error = %v, want %v", err, ErrBackupChecksum)
+	}
+	if err := m.RestoreBackup(bytes.NewReader(b[:len(b)-12])); err != ErrBackupFormat {
+		t.Errorf("RestoreBackup() error = %v, want %v", err, ErrBackupFormat)
+	}
+	// the keys are kept when the archive is bad
+	if v := m.Get("s"); string(v) != "v" {
+		t.Errorf("Get() = %s, want v", v)
+	}
+}
+
+func TestBackup_PointInTime(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	for i := 0; i < 10; i++ {
+		n.Set("key"+strconv.Itoa(i), []byte("v"), false)
+	}
+	var written bool
+	saved := make(map[string]string)
+	err := n.saveSnapshot(context.Background(), func(e *snapshotEntry) error {
+		v, err := rdb.Restore(e.payload, list.Options{}, nil)
+		if err != nil {
+			return err
+		}
+		saved[e.key] = string(v.GetValue())
+		if !written {
+			// the writes after the start of the snapshot aren't saved
+			written = true
+			for i := 0; i < 10; i++ {
+				n.Set("key"+strconv.Itoa(i), []byte("changed"), false)
+			}
+			n.Del("key9")
+			n.Set("new", []byte("v"), false)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("saveSnapshot() error = %v", err)
+	}
+	if len(saved) != 10 {
+		t.Errorf("saveSnapshot() saved %d keys, want 10", len(saved))
+	}
+	for key, v := range saved {
+		if v != "v" {
+			t.Errorf("saveSnapshot() saved %s = %s, want v", key, v)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = n.Backup(context.Background(), &buf); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = n.Backup(ctx, &bytes.Buffer{}); err != context.Canceled {
+		t.Errorf("Backup() error = %v, want %v", err, context.Canceled)
+	}
+	if n.store.snapshot.Load() != nil {
+		t.Errorf("the snapshot is running after Backup() returned")
+	}
+}
+
+func TestBackup_ConcurrentWrites(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	for i := 0; i < 1000; i++ {
+		n.Set("key"+strconv.Itoa(i), []byte("v"), false)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// the writes start once the snapshot started, or after it ended
+		for j := 0; n.store.snapshot.Load() == nil && j < 1000; j++ {
+			runtime.Gosched()
+		}
+		for i := 0; i < 1000; i++ {
+			n.Set("key"+strconv.Itoa(i), []byte("changed"), false)
+		}
+	}()
+	var buf bytes.Buffer
+	if err := n.Backup(context.Background(), &buf); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	<-done
+	m := Open(&Options{})
+	if err := m.RestoreBackup(&buf); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		if v := m.Get("key" + strconv.Itoa(i)); string(v) != "v" {
+			t.Fatalf("Get() = %s, want the value at the start of the backup", v)
+		}
+	}
+}
+
+func TestNodis_Snapshot(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.Set("key", []byte("v"), false)
+	if err := n.Snapshot(); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	// the changes are flushed to the storage before
+	v, err := n.store.ss.Get(ds.NewKey("key", 0))
+	if err != nil || string(v.GetValue()) != "v" {
+		t.Errorf("Get() = %v, %v", v, err)
+	}
+}
@@ -0,0 +1,239 @@
+package nodis
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/diiyw/nodis/redis"
+)
+
+var (
+	ErrUnblocked = errors.New("UNBLOCKED client unblocked via CLIENT UNBLOCK")
+)
+
+// blockedClient is the connection running a blocking command
+type blockedClient struct {
+	// id is the id of the connection, 0 if the command doesn't come from a connection
+	id int
+	// done is closed when the connection is closed
+	done <-chan struct{}
+	// noWait makes the command non-blocking, e.g. inside MULTI
+	noWait bool
+}
+
+// newBlockedClient watches the connection for a disconnection while the command blocks,
+// stop must be called once the command returns.
+func newBlockedClient(conn *redis.Conn) (c *blockedClient, stop func()) {
+	c = &blockedClient{id: conn.Fd}
+	if conn.State&redis.MultiCommit == redis.MultiCommit {
+		c.noWait = true
+		return c, func() {}
+	}
+	c.done, stop = conn.WaitClosed()
+	return c, stop
+}
+
+// waiter is a client blocked on keys
+type waiter struct {
+	id   int
+	keys []string
+	// ready holds the keys modified since the waiter was woken up
+	ready map[string]bool
+	wake  chan struct{}
+	// cancel receives the error of CLIENT UNBLOCK, nil unblocks as if the timeout was reached
+	cancel chan error
+}
+
+// blocking keeps the queues of the clients blocked on keys, the clients blocked on the same
+// key are served in FIFO order: only the first client of a queue is woken up when the key is
+// modified, the next one is woken up once it leaves the queue.
+type blocking struct {
+	mu      sync.Mutex
+	waiters atomic.Int64
+	queues  map[string][]*waiter
+	clients map[int]*waiter
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newBlocking() *blocking {
+	return &blocking{
+		queues:  make(map[string][]*waiter),
+		clients: make(map[int]*waiter),
+		closed:  make(chan struct{}),
+	}
+}
+
+// add appends a waiter to the queues of the keys, it returns the keys that the waiter is the first of
+func (b *blocking) add(id int, keys []string) (*waiter, map[string]bool) {
+	w := &waiter{
+		id:     id,
+		keys:   keys,
+		ready:  make(map[string]bool),
+		wake:   make(chan struct{}, 1),
+		cancel: make(chan error, 1),
+	}
+	first := make(map[string]bool, len(keys))
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range keys {
+		if _, ok := first[key]; ok {
+			continue
+		}
+		first[key] = len(b.queues[key]) == 0
+		b.queues[key] = append(b.queues[key], w)
+	}
+	if id != 0 {
+		b.clients[id] = w
+	}
+	b.waiters.Add(1)
+	return w, first
+}
+
+// remove removes the waiter from the queues and wakes up the next waiters
+func (b *blocking) remove(w *waiter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range w.keys {
+		q := b.queues[key]
+		for i, v := range q {
+			if v == w {
+				q = append(q[:i], q[i+1:]...)
+				break
+			}
+		}
+		if len(q) == 0 {
+			delete(b.queues, key)
+			continue
+		}
+		b.queues[key] = q
+		// the key may still be ready for the next waiter
+		b.signalLocked(key)
+	}
+	if b.clients[w.id] == w {
+		delete(b.clients, w.id)
+	}
+	b.waiters.Add(-1)
+}
+
+// signal wakes up the first waiter of the key
+func (b *blocking) signal(key string) {
+	if b.waiters.Load() == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.signalLocked(key)
+	b.mu.Unlock()
+}
+
+func (b *blocking) signalLocked(key string) {
+	q := b.queues[key]
+	if len(q) == 0 {
+		return
+	}
+	w := q[0]
+	w.ready[key] = true
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// readyKeys returns and resets the keys modified since the waiter was woken up, in the order of the waiter keys
+func (b *blocking) readyKeys(w *waiter) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	keys := make([]string, 0, len(w.ready))
+	for _, key := range w.keys {
+		if w.ready[key] {
+			keys = append(keys, key)
+			delete(w.ready, key)
+		}
+	}
+	return keys
+}
+
+// unblock cancels the blocking command of the client, it returns false if the client isn't blocked
+func (b *blocking) unblock(id int, err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	w, ok := b.clients[id]
+	if !ok {
+		return false
+	}
+	select {
+	case w.cancel <- err:
+	default:
+	}
+	return true
+}
+
+// close cancels all the blocking commands
+func (b *blocking) close() {
+	b.once.Do(func() {
+		close(b.closed)
+	})
+}
+
+// block calls pop on the keys in order until it succeeds. If none of the keys can be popped it waits for
+// the keys to be modified until the timeout is reached, a zero timeout blocks indefinitely. It returns the
+// popped key, or an empty key if the timeout is reached or the command is cancelled.
+func (n *Nodis) block(c *blockedClient, timeout time.Duration, keys []string, pop func(key string) bool) (string, error) {
+	if c == nil {
+		c = &blockedClient{}
+	}
+	if c.noWait {
+		for _, key := range keys {
+			if pop(key) {
+				return key, nil
+			}
+		}
+		return "", nil
+	}
+	// the waiter is queued before trying the keys so that a key modified in the meantime wakes it up
+	w, first := n.blocking.add(c.id, keys)
+	defer n.blocking.remove(w)
+	for _, key := range keys {
+		// the clients blocked before on the key are served first
+		if first[key] && pop(key) {
+			return key, nil
+		}
+	}
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		select {
+		case <-w.wake:
+			for _, key := range n.blocking.readyKeys(w) {
+				if pop(key) {
+					return key, nil
+				}
+			}
+		case err := <-w.cancel:
+			return "", err
+		case <-c.done:
+			return "", nil
+		case <-n.blocking.closed:
+			return "", nil
+		case <-deadline:
+			return "", nil
+		}
+	}
+}
+
+// UnblockClient cancels the blocking command of the client with the id, the command fails with
+// ErrUnblocked if withError is true and returns as if the timeout was reached otherwise.
+// It returns false if the client isn't blocked.
+func (n *Nodis) UnblockClient(id int, withError bool) bool {
+	var err error
+	if withError {
+		err = ErrUnblocked
+	}
+	return n.blocking.unblock(id, err)
+}
@@ -0,0 +1,158 @@
+package nodis
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/patch"
+	"github.com/diiyw/nodis/redis"
+)
+
+func TestBlocking_FIFO(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	served := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			if _, v := n.BLPop(time.Second, "list"); v != nil {
+				served <- i
+			}
+		}(i)
+		// make sure the clients are blocked in order
+		time.Sleep(20 * time.Millisecond)
+	}
+	n.RPush("list", []byte("a"), []byte("b"))
+	n.RPush("list", []byte("c"))
+	for want := 0; want < 3; want++ {
+		select {
+		case got := <-served:
+			if got != want {
+				t.Errorf("served client %d, want %d", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("client %d not served", want)
+		}
+	}
+}
+
+func TestBlocking_FirstServedBeforeNewClients(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	done := make(chan []byte, 1)
+	go func() {
+		_, v := n.BLPop(time.Second, "list")
+		done <- v
+	}()
+	time.Sleep(20 * time.Millisecond)
+	n.RPush("list", []byte("a"), []byte("b"))
+	if v := <-done; string(v) != "a" {
+		t.Errorf("BLPop() = %s, want %s", v, "a")
+	}
+	if _, v := n.BLPop(time.Second, "list"); string(v) != "b" {
+		t.Errorf("BLPop() = %s, want %s", v, "b")
+	}
+}
+
+func TestBlocking_WakeupByPatchAndRename(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	done := make(chan string, 1)
+	go func() {
+		key, _ := n.BLPop(time.Second, "list")
+		done <- key
+	}()
+	time.Sleep(20 * time.Millisecond)
+	_ = n.ApplyPatch(patch.Op{Type: patch.OpTypeRPush, Data: &patch.OpRPush{Key: "list", Values: [][]byte{[]byte("a")}}})
+	if key := <-done; key != "list" {
+		t.Errorf("BLPop() key = %s, want %s", key, "list")
+	}
+	go func() {
+		key, _ := n.BZPopMin(time.Second, "zset")
+		done <- key
+	}()
+	time.Sleep(20 * time.Millisecond)
+	n.ZAdd("tmp", "a", 1)
+	_ = n.Rename("tmp", "zset")
+	if key := <-done; key != "zset" {
+		t.Errorf("BZPopMin() key = %s, want %s", key, "zset")
+	}
+}
+
+func TestBlocking_Close(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	done := make(chan string, 1)
+	go func() {
+		key, _ := n.BLPop(0, "list")
+		done <- key
+	}()
+	time.Sleep(20 * time.Millisecond)
+	_ = n.Close()
+	select {
+	case key := <-done:
+		if key != "" {
+			t.Errorf("BLPop() key = %s, want empty", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("BLPop() not cancelled on close")
+	}
+}
+
+func TestBlocking_UnblockClient(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	if n.UnblockClient(1, false) {
+		t.Errorf("UnblockClient() = true, want false")
+	}
+	done := make(chan error, 2)
+	for _, id := range []int{1, 2} {
+		go func(id int) {
+			_, _, err := n.bLMPop(&blockedClient{id: id}, 0, []string{"list"}, list.Left, 1)
+			done <- err
+		}(id)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !n.UnblockClient(1, false) {
+		t.Errorf("UnblockClient() = false, want true")
+	}
+	if err := <-done; err != nil {
+		t.Errorf("bLMPop() error = %v, want nil", err)
+	}
+	if !n.UnblockClient(2, true) {
+		t.Errorf("UnblockClient() = false, want true")
+	}
+	if err := <-done; err != ErrUnblocked {
+		t.Errorf("bLMPop() error = %v, want %v", err, ErrUnblocked)
+	}
+}
+
+func TestBlocking_Commands(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	tests := []struct {
+		name  string
+		args  []string
+		state uint8
+		want  string
+	}{
+		// blocking commands don't block inside MULTI
+		{"BLPOP", []string{"list", "0"}, redis.MultiCommit, "*-1\r\n"},
+		{"BLMOVE", []string{"list", "dst", "LEFT", "LEFT", "0"}, redis.MultiCommit, "$-1\r\n"},
+		{"BZMPOP", []string{"0", "1", "zset", "MIN"}, redis.MultiCommit, "*-1\r\n"},
+		{"BRPOP", []string{"list", "-1"}, redis.MultiNone, "-ERR timeout is negative\r\n"},
+		{"CLIENT", []string{"UNBLOCK", "100"}, redis.MultiNone, ":0\r\n"},
+		{"CLIENT", []string{"UNBLOCK", "100", "NONE"}, redis.MultiNone, "-ERR CLIENT UNBLOCK reason should be TIMEOUT or ERROR\r\n"},
+		{"CLIENT", []string{"ID"}, redis.MultiNone, ":7\r\n"},
+	}
+	for _, tt := range tests {
+		buf := &bytes.Buffer{}
+		w := redis.NewWriter(buf)
+		GetCommand(tt.name)(n, &redis.Conn{Fd: 7, Writer: w, State: tt.state}, redis.Command{Name: tt.name, Args: tt.args})
+		if got := string(w.Bytes()); got != tt.want {
+			t.Errorf("%s %v = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
@@ -2,25 +2,227 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/diiyw/nodis"
+	"github.com/diiyw/nodis/ds"
 	"github.com/diiyw/nodis/storage"
 )
 
 var CLI struct {
-	Addr    string `arg:"" default:":6380" usage:"nodis server address"`
-	Storage string `arg:"" default:"memory" usage:"select storage: memory, pebble"`
+	Serve    ServeCmd    `cmd:"" default:"withargs" help:"Serve the Redis protocol"`
+	Import   ImportCmd   `cmd:"" help:"Import the keys of a Redis RDB file"`
+	Export   ExportCmd   `cmd:"" help:"Export the keys to a Redis RDB file"`
+	Snapshot SnapshotCmd `cmd:"" help:"Manage the snapshots of the pebble storage"`
+	Check    CheckCmd    `cmd:"" help:"Verify every value of the storage, the server must be stopped"`
 }
 
-func main() {
-	_ = kong.Parse(&CLI)
+// SnapshotFlags are the options of the snapshots of the pebble storage
+type SnapshotFlags struct {
+	SnapshotDir    string        `help:"snapshot directory, default data-snapshots"`
+	SnapshotRetain int           `help:"number of the latest snapshots kept, 0 for all"`
+	SnapshotMaxAge time.Duration `help:"age after which the snapshots are removed, 0 for never"`
+}
+
+func (f SnapshotFlags) options() storage.SnapshotOptions {
+	return storage.SnapshotOptions{Dir: f.SnapshotDir, Retain: f.SnapshotRetain, MaxAge: f.SnapshotMaxAge}
+}
+
+// CompressionFlags are the options of the compression of the stored values
+type CompressionFlags struct {
+	Compression          string `default:"none" enum:"none,snappy,zstd" help:"compression of the stored values: none, snappy, zstd"`
+	CompressionThreshold int    `help:"size in bytes from which the values are compressed, 0 for the default"`
+}
+
+// apply sets the compression options
+func (f CompressionFlags) apply(opt *nodis.Options) error {
+	codec, err := storage.ParseCodec(f.Compression)
+	if err != nil {
+		return err
+	}
+	opt.Compression = codec
+	opt.CompressionThreshold = f.CompressionThreshold
+	return nil
+}
+
+type ServeCmd struct {
+	Addr             string        `arg:"" default:":6380" help:"nodis server address"`
+	Storage          string        `arg:"" default:"memory" help:"select storage: memory, pebble, bolt, dir"`
+	SnapshotInterval time.Duration `help:"interval of the snapshots of the storage, 0 for none"`
+	ExplodeThreshold int           `help:"number of fields from which the hashes, sets and sorted sets are stored field by field, 0 for never"`
+	MaxMemory        string        `default:"0" help:"limit of the memory used by the values like 512mb, 0 for no limit"`
+	MaxMemoryPolicy  string        `default:"noeviction" enum:"noeviction,allkeys-lru,allkeys-lfu,volatile-lru,volatile-ttl" help:"eviction of the values when the maxmemory is exceeded"`
+	SnapshotFlags
+	CompressionFlags
+}
+
+func (c *ServeCmd) Run() error {
+	opt := nodis.DefaultOptions
+	opt.SnapshotDuration = c.SnapshotInterval
+	opt.ExplodeThreshold = c.ExplodeThreshold
+	maxMemory, err := nodis.ParseMemory(c.MaxMemory)
+	if err != nil {
+		return fmt.Errorf("invalid maxmemory %q", c.MaxMemory)
+	}
+	opt.MaxMemory = maxMemory
+	if opt.MaxMemoryPolicy, err = nodis.ParseEvictionPolicy(c.MaxMemoryPolicy); err != nil {
+		return err
+	}
+	if err := c.CompressionFlags.apply(opt); err != nil {
+		return err
+	}
+	n := open(opt, c.Storage, c.SnapshotFlags)
+	if err := n.Serve(c.Addr); err != nil {
+		return fmt.Errorf("Serve() = %v", err)
+	}
+	return nil
+}
+
+type ImportCmd struct {
+	File    string `arg:"" type:"existingfile" help:"RDB file to import"`
+	Storage string `default:"pebble" help:"select storage: memory, pebble, bolt, dir"`
+	CompressionFlags
+}
+
+func (c *ImportCmd) Run() error {
 	opt := nodis.DefaultOptions
-	if CLI.Storage == "pebble" {
-		opt.Storage = storage.NewPebble("data", nil)
+	if err := c.CompressionFlags.apply(opt); err != nil {
+		return err
+	}
+	f, err := os.Open(c.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n := open(opt, c.Storage, SnapshotFlags{})
+	defer n.Close()
+	return n.ImportRDB(f)
+}
+
+type ExportCmd struct {
+	File    string `arg:"" default:"dump.rdb" help:"RDB file to write"`
+	Storage string `default:"pebble" help:"select storage: memory, pebble, bolt, dir"`
+}
+
+func (c *ExportCmd) Run() error {
+	n := open(nodis.DefaultOptions, c.Storage, SnapshotFlags{})
+	defer n.Close()
+	f, err := os.Create(c.File)
+	if err != nil {
+		return err
+	}
+	if err = n.ExportRDB(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type SnapshotCmd struct {
+	List    SnapshotListCmd    `cmd:"" help:"List the snapshots"`
+	Prune   SnapshotPruneCmd   `cmd:"" help:"Remove the snapshots beyond the retention"`
+	Restore SnapshotRestoreCmd `cmd:"" help:"Replace the data by a snapshot, the server must be stopped"`
+}
+
+type SnapshotListCmd struct {
+	SnapshotFlags
+}
+
+func (c *SnapshotListCmd) Run() error {
+	snapshots, err := pebbleStorage(c.SnapshotFlags).ListSnapshots()
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		fmt.Printf("%s\t%s\t%d\n", s.Name, s.Time.Format(time.RFC3339), s.Size)
+	}
+	return nil
+}
+
+type SnapshotPruneCmd struct {
+	SnapshotFlags
+}
+
+func (c *SnapshotPruneCmd) Run() error {
+	removed, err := pebbleStorage(c.SnapshotFlags).PruneSnapshots()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d snapshots removed\n", removed)
+	return nil
+}
+
+type SnapshotRestoreCmd struct {
+	Name string `arg:"" help:"snapshot name"`
+	SnapshotFlags
+}
+
+func (c *SnapshotRestoreCmd) Run() error {
+	p := pebbleStorage(c.SnapshotFlags)
+	if err := p.RestoreSnapshot(c.Name); err != nil {
+		return err
+	}
+	// the snapshot is swapped in when the storage is opened
+	if err := p.Init(); err != nil {
+		return err
+	}
+	return p.Close()
+}
+
+type CheckCmd struct {
+	Storage string `default:"pebble" help:"select storage: pebble, bolt, dir"`
+}
+
+func (c *CheckCmd) Run() error {
+	ss := newStorage(c.Storage, SnapshotFlags{})
+	if ss == nil {
+		return fmt.Errorf("no data to check in the %s storage", c.Storage)
+	}
+	if err := ss.Init(); err != nil {
+		return err
+	}
+	defer ss.Close()
+	keys, failed := storage.Check(ss, func(key *ds.Key, err error) {
+		fmt.Printf("%q\t%v\n", key.Name, err)
+	})
+	fmt.Printf("%d keys checked, %d corrupted\n", keys, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d corrupted keys", failed)
+	}
+	return nil
+}
+
+// pebbleStorage returns the pebble storage of the data directory
+func pebbleStorage(flags SnapshotFlags) *storage.Pebble {
+	p := storage.NewPebble("data", nil)
+	p.SetSnapshotOptions(flags.options())
+	return p
+}
+
+// newStorage returns the storage of the name, nil for the memory one
+func newStorage(name string, flags SnapshotFlags) storage.Storage {
+	switch name {
+	case "pebble":
+		return pebbleStorage(flags)
+	case "bolt":
+		return storage.NewBolt("data.db", nil)
+	case "dir":
+		return storage.NewDirectory("data")
 	}
-	n := nodis.Open(opt)
-	if err := n.Serve(CLI.Addr); err != nil {
-		fmt.Printf("Serve() = %v", err)
+	return nil
+}
+
+// open opens the database with the storage
+func open(opt *nodis.Options, name string, flags SnapshotFlags) *nodis.Nodis {
+	if ss := newStorage(name, flags); ss != nil {
+		opt.Storage = ss
 	}
+	return nodis.Open(opt)
+}
+
+func main() {
+	ctx := kong.Parse(&CLI)
+	ctx.FatalIfErrorf(ctx.Run())
 }
@@ -0,0 +1,51 @@
+package ds
+
+// Changes records the fields of a collection added, changed or removed since it was reset.
+// Nothing is recorded until Track is called.
+type Changes struct {
+	// owner is the key the changes are recorded for
+	owner  string
+	fields map[string]struct{}
+}
+
+// ChangeTracker is a collection recording its changed fields
+type ChangeTracker interface {
+	Changes() *Changes
+}
+
+// Track starts recording the changes of the collection of the key, the changes recorded for
+// another key are forgotten
+func (c *Changes) Track(owner string) {
+	c.owner = owner
+	c.fields = make(map[string]struct{})
+}
+
+// Untrack stops recording the changes
+func (c *Changes) Untrack() {
+	c.owner = ""
+	c.fields = nil
+}
+
+// Tracking returns if the changes are recorded for the key
+func (c *Changes) Tracking(owner string) bool {
+	return c.fields != nil && c.owner == owner
+}
+
+// Add records the change of the field
+func (c *Changes) Add(field string) {
+	if c.fields != nil {
+		c.fields[field] = struct{}{}
+	}
+}
+
+// Fields returns the fields changed since the last reset
+func (c *Changes) Fields() map[string]struct{} {
+	return c.fields
+}
+
+// Reset forgets the changes recorded, the next ones are still recorded
+func (c *Changes) Reset() {
+	if c.fields != nil {
+		c.fields = make(map[string]struct{})
+	}
+}
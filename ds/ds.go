@@ -50,13 +50,16 @@ const (
 	// 3 => list,
 	// 4 => zset,
 	// 5 => hash
-	// 6 => stream
+	// 6 => timeseries
+	// 7 => vectorset
 	None ValueType = iota
 	String
 	Set
 	List
 	ZSet
 	Hash
+	TimeSeries
+	VectorSet
 )
 
 func (d ValueType) String() string {
@@ -73,6 +76,10 @@ func (d ValueType) String() string {
 		return "set"
 	case ZSet:
 		return "zset"
+	case TimeSeries:
+		return "TSDB-TYPE"
+	case VectorSet:
+		return "vectorset"
 	default:
 		return "none"
 	}
@@ -90,6 +97,10 @@ func StringToDataType(s string) ValueType {
 		return Set
 	case "ZSET":
 		return ZSet
+	case "TSDB-TYPE":
+		return TimeSeries
+	case "VECTORSET":
+		return VectorSet
 	default:
 		return None
 	}
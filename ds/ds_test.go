@@ -17,3 +17,26 @@ func TestKey_EncodeDecode(t *testing.T) {
 		t.Errorf("decoded = %v, want %v", decoded, key)
 	}
 }
+
+func TestChanges(t *testing.T) {
+	var c Changes
+	c.Add("a")
+	if c.Fields() != nil || c.Tracking("key") {
+		t.Errorf("Changes recorded before Track")
+	}
+	c.Track("key")
+	c.Add("a")
+	c.Add("b")
+	if len(c.Fields()) != 2 || !c.Tracking("key") || c.Tracking("other") {
+		t.Errorf("Fields() = %v, want [a b] for key", c.Fields())
+	}
+	c.Reset()
+	c.Add("c")
+	if _, ok := c.Fields()["c"]; !ok || len(c.Fields()) != 1 {
+		t.Errorf("Fields() = %v, want [c] after Reset", c.Fields())
+	}
+	c.Untrack()
+	if c.Tracking("key") {
+		t.Errorf("Tracking() = true after Untrack")
+	}
+}
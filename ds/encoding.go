@@ -0,0 +1,117 @@
+package ds
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// EncodingLimits are the limits of the compact encodings of the small collections. A collection is
+// converted to its regular encoding once it exceeds them and it is never converted back.
+type EncodingLimits struct {
+	// HashMaxListpackEntries is the max number of fields of a listpack encoded hash
+	HashMaxListpackEntries int64
+	// HashMaxListpackValue is the max length of the fields and values of a listpack encoded hash
+	HashMaxListpackValue int64
+	// SetMaxIntsetEntries is the max number of members of an intset encoded set
+	SetMaxIntsetEntries int64
+	// ZSetMaxListpackEntries is the max number of members of a listpack encoded sorted set
+	ZSetMaxListpackEntries int64
+	// ZSetMaxListpackValue is the max length of the members of a listpack encoded sorted set
+	ZSetMaxListpackValue int64
+}
+
+// DefaultEncodingLimits are the default limits, they are the same as the Redis ones
+var DefaultEncodingLimits = EncodingLimits{
+	HashMaxListpackEntries: 128,
+	HashMaxListpackValue:   64,
+	SetMaxIntsetEntries:    512,
+	ZSetMaxListpackEntries: 128,
+	ZSetMaxListpackValue:   64,
+}
+
+// Encodings holds the EncodingLimits of the collections of a database, they can be changed at any
+// time. A nil or zero Encodings holds the DefaultEncodingLimits.
+type Encodings struct {
+	limits atomic.Pointer[EncodingLimits]
+}
+
+// NewEncodings returns the encodings of the limits
+func NewEncodings(limits EncodingLimits) *Encodings {
+	e := &Encodings{}
+	e.SetLimits(limits)
+	return e
+}
+
+// Encoded is a collection whose encoding depends on the Encodings
+type Encoded interface {
+	SetEncodings(e *Encodings)
+}
+
+// Limits returns the limits of the compact encodings
+func (e *Encodings) Limits() EncodingLimits {
+	if e == nil || e.limits.Load() == nil {
+		return DefaultEncodingLimits
+	}
+	return *e.limits.Load()
+}
+
+// SetLimits sets the limits of the compact encodings. The collections already converted to their
+// regular encoding are left as is.
+func (e *Encodings) SetLimits(limits EncodingLimits) {
+	e.limits.Store(&limits)
+}
+
+// limitParams maps the Redis configuration parameters to the limits
+var limitParams = map[string]func(l *EncodingLimits) *int64{
+	"hash-max-listpack-entries": func(l *EncodingLimits) *int64 { return &l.HashMaxListpackEntries },
+	"hash-max-listpack-value":   func(l *EncodingLimits) *int64 { return &l.HashMaxListpackValue },
+	"set-max-intset-entries":    func(l *EncodingLimits) *int64 { return &l.SetMaxIntsetEntries },
+	"zset-max-listpack-entries": func(l *EncodingLimits) *int64 { return &l.ZSetMaxListpackEntries },
+	"zset-max-listpack-value":   func(l *EncodingLimits) *int64 { return &l.ZSetMaxListpackValue },
+}
+
+// EncodingLimitParams returns the names of the Redis configuration parameters of the limits
+func EncodingLimitParams() []string {
+	return []string{
+		"hash-max-listpack-entries",
+		"hash-max-listpack-value",
+		"set-max-intset-entries",
+		"zset-max-listpack-entries",
+		"zset-max-listpack-value",
+	}
+}
+
+// Limit returns the limit of the Redis configuration parameter like hash-max-listpack-entries
+func (e *Encodings) Limit(param string) (int64, bool) {
+	field, ok := limitParams[param]
+	if !ok {
+		return 0, false
+	}
+	limits := e.Limits()
+	return *field(&limits), true
+}
+
+// SetLimit sets the limit of the Redis configuration parameter like hash-max-listpack-entries
+func (e *Encodings) SetLimit(param string, value int64) bool {
+	field, ok := limitParams[param]
+	if !ok || value < 0 {
+		return false
+	}
+	for {
+		current := e.limits.Load()
+		limits := e.Limits()
+		*field(&limits) = value
+		if e.limits.CompareAndSwap(current, &limits) {
+			return true
+		}
+	}
+}
+
+// IsIntString returns whether s is the canonical representation of a 64-bit integer
+func IsIntString(s string) bool {
+	if len(s) == 0 || len(s) > 20 {
+		return false
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && strconv.FormatInt(v, 10) == s
+}
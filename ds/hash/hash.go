@@ -5,13 +5,45 @@ import (
 	"errors"
 	"path/filepath"
 	"strconv"
+	"time"
 	"unsafe"
 
 	"github.com/diiyw/nodis/ds"
 )
 
+// ExpireCondition is the condition of setting the TTL of a field
+type ExpireCondition uint8
+
+const (
+	ExpireAlways ExpireCondition = iota
+	// ExpireNX sets the TTL only when the field has no TTL
+	ExpireNX
+	// ExpireXX sets the TTL only when the field has a TTL
+	ExpireXX
+	// ExpireGT sets the TTL only when it's greater than the current one
+	ExpireGT
+	// ExpireLT sets the TTL only when it's less than the current one
+	ExpireLT
+)
+
+const (
+	EncodingListpack  = "listpack"
+	EncodingHashtable = "hashtable"
+)
+
+// HashMap is a hash whose fields may expire. Expired fields are hidden from
+// the read methods and removed by the write methods or RemoveExpired.
+// A small hash is encoded as a listpack, it is converted to a hashtable once it
+// exceeds the limits of ds.EncodingLimits.
 type HashMap struct {
+	// data is nil while the hash is encoded as a listpack
 	data map[string][]byte
+	// listpack holds the fields followed by their values, both prefixed by their varint length
+	listpack  []byte
+	count     int
+	expires   map[string]int64 // field -> unix time in milliseconds
+	changes   ds.Changes
+	encodings *ds.Encodings
 }
 
 type keyValuePair struct {
@@ -39,11 +71,166 @@ func decodeKeyValuePair(b []byte) *keyValuePair {
 	}
 }
 
-// NewHashMap creates a new hash
+// NewHashMap creates a new hash with the default encoding limits
 func NewHashMap() *HashMap {
-	return &HashMap{
-		data: make(map[string][]byte),
+	return &HashMap{}
+}
+
+// NewHashMapWithEncodings creates a new hash encoded by the limits of the encodings
+func NewHashMapWithEncodings(e *ds.Encodings) *HashMap {
+	return &HashMap{encodings: e}
+}
+
+// SetEncodings sets the encodings of the hash, they apply to the next fields set
+func (s *HashMap) SetEncodings(e *ds.Encodings) {
+	s.encodings = e
+}
+
+// Changes returns the fields changed, the expirations included
+func (s *HashMap) Changes() *ds.Changes {
+	return &s.changes
+}
+
+// Encoding returns the encoding of the hash, listpack or hashtable
+func (s *HashMap) Encoding() string {
+	if s.data == nil {
+		return EncodingListpack
+	}
+	return EncodingHashtable
+}
+
+// nextEntry returns the first field and value of the listpack and the remaining entries,
+// the capacity of the value is cut so that appending to it doesn't overwrite the next entry
+func nextEntry(b []byte) (key, value, rest []byte) {
+	kLen, n := binary.Varint(b)
+	key = b[n : n+int(kLen)]
+	b = b[n+int(kLen):]
+	vLen, n := binary.Varint(b)
+	end := n + int(vLen)
+	return key, b[n:end:end], b[end:]
+}
+
+func appendEntry(b []byte, key string, value []byte) []byte {
+	b = binary.AppendVarint(b, int64(len(key)))
+	b = append(b, key...)
+	b = binary.AppendVarint(b, int64(len(value)))
+	return append(b, value...)
+}
+
+// lookup returns the value of the field, expired or not
+func (s *HashMap) lookup(key string) ([]byte, bool) {
+	if s.data != nil {
+		v, ok := s.data[key]
+		return v, ok
+	}
+	for b := s.listpack; len(b) > 0; {
+		var k, v []byte
+		k, v, b = nextEntry(b)
+		if string(k) == key {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// store sets the value of the field and returns whether the field existed, the listpack is
+// converted to a hashtable if it exceeds the limits
+func (s *HashMap) store(key string, value []byte) bool {
+	s.changes.Add(key)
+	if s.data != nil {
+		_, ok := s.data[key]
+		s.data[key] = value
+		return ok
+	}
+	limits := s.encodings.Limits()
+	if int64(len(key)) > limits.HashMaxListpackValue || int64(len(value)) > limits.HashMaxListpackValue {
+		s.convert()
+		return s.store(key, value)
+	}
+	if _, ok := s.lookup(key); ok {
+		// the listpack is rebuilt so that the values returned before aren't modified
+		listpack := make([]byte, 0, len(s.listpack)+len(value))
+		for b := s.listpack; len(b) > 0; {
+			var k, v []byte
+			k, v, b = nextEntry(b)
+			if string(k) == key {
+				v = value
+			}
+			listpack = appendEntry(listpack, string(k), v)
+		}
+		s.listpack = listpack
+		return true
 	}
+	if int64(s.count) >= limits.HashMaxListpackEntries {
+		s.convert()
+		return s.store(key, value)
+	}
+	s.listpack = appendEntry(s.listpack, key, value)
+	s.count++
+	return false
+}
+
+// del deletes the field and returns whether it existed
+func (s *HashMap) del(key string) bool {
+	s.changes.Add(key)
+	if s.data != nil {
+		_, ok := s.data[key]
+		delete(s.data, key)
+		return ok
+	}
+	if _, ok := s.lookup(key); !ok {
+		return false
+	}
+	listpack := make([]byte, 0, len(s.listpack))
+	for b := s.listpack; len(b) > 0; {
+		var k, v []byte
+		k, v, b = nextEntry(b)
+		if string(k) != key {
+			listpack = appendEntry(listpack, string(k), v)
+		}
+	}
+	s.listpack = listpack
+	s.count--
+	return true
+}
+
+// size returns the number of fields, expired or not
+func (s *HashMap) size() int {
+	if s.data != nil {
+		return len(s.data)
+	}
+	return s.count
+}
+
+// each calls fn on the fields, expired or not, until it returns false
+func (s *HashMap) each(fn func(key string, value []byte) bool) {
+	if s.data != nil {
+		for k, v := range s.data {
+			if !fn(k, v) {
+				return
+			}
+		}
+		return
+	}
+	for b := s.listpack; len(b) > 0; {
+		var k, v []byte
+		k, v, b = nextEntry(b)
+		if !fn(string(k), v) {
+			return
+		}
+	}
+}
+
+// convert converts the listpack to a hashtable
+func (s *HashMap) convert() {
+	data := make(map[string][]byte, s.count+1)
+	s.each(func(key string, value []byte) bool {
+		data[key] = value
+		return true
+	})
+	s.data = data
+	s.listpack = nil
+	s.count = 0
 }
 
 // Type returns the type of the data structure
@@ -51,10 +238,132 @@ func (s *HashMap) Type() ds.ValueType {
 	return ds.Hash
 }
 
-// HSet sets the value of a hash
+func now() int64 {
+	return time.Now().UnixMilli()
+}
+
+// expired returns whether the field has expired
+func (s *HashMap) expired(key string, now int64) bool {
+	if len(s.expires) == 0 {
+		return false
+	}
+	at, ok := s.expires[key]
+	return ok && at <= now
+}
+
+// get returns the value of a field that hasn't expired
+func (s *HashMap) get(key string) ([]byte, bool) {
+	v, ok := s.lookup(key)
+	if !ok || s.expired(key, now()) {
+		return nil, false
+	}
+	return v, true
+}
+
+// remove removes the field if it has expired
+func (s *HashMap) remove(key string) {
+	if s.expired(key, now()) {
+		s.del(key)
+		delete(s.expires, key)
+	}
+}
+
+// HasExpired returns if a field has expired at now
+func (s *HashMap) HasExpired(now int64) bool {
+	for _, at := range s.expires {
+		if at <= now {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveExpired removes the fields expired at now and returns the number of removed fields
+func (s *HashMap) RemoveExpired(now int64) int64 {
+	var c int64
+	for key, at := range s.expires {
+		if at <= now {
+			s.del(key)
+			delete(s.expires, key)
+			c++
+		}
+	}
+	return c
+}
+
+// HPExpireAt sets the expiration of the field to the unix time at in milliseconds.
+// It returns -2 if the field doesn't exist, 0 if the condition isn't met,
+// 1 if the expiration is set and 2 if the field is deleted because at is in the past.
+func (s *HashMap) HPExpireAt(key string, at int64, cond ExpireCondition) int64 {
+	s.remove(key)
+	if _, ok := s.lookup(key); !ok {
+		return -2
+	}
+	current, hasTTL := s.expires[key]
+	switch cond {
+	case ExpireNX:
+		if hasTTL {
+			return 0
+		}
+	case ExpireXX:
+		if !hasTTL {
+			return 0
+		}
+	case ExpireGT:
+		if !hasTTL || at <= current {
+			return 0
+		}
+	case ExpireLT:
+		if hasTTL && at >= current {
+			return 0
+		}
+	}
+	if at <= now() {
+		s.del(key)
+		delete(s.expires, key)
+		return 2
+	}
+	if s.expires == nil {
+		s.expires = make(map[string]int64)
+	}
+	s.expires[key] = at
+	s.changes.Add(key)
+	return 1
+}
+
+// HPExpireTime returns the expiration of the field as a unix time in milliseconds,
+// -2 if the field doesn't exist and -1 if the field has no expiration
+func (s *HashMap) HPExpireTime(key string) int64 {
+	if _, ok := s.get(key); !ok {
+		return -2
+	}
+	at, ok := s.expires[key]
+	if !ok {
+		return -1
+	}
+	return at
+}
+
+// HPersist removes the expiration of the field, it returns -2 if the field
+// doesn't exist, -1 if the field has no expiration and 1 if it's removed
+func (s *HashMap) HPersist(key string) int64 {
+	s.remove(key)
+	if _, ok := s.lookup(key); !ok {
+		return -2
+	}
+	if _, ok := s.expires[key]; !ok {
+		return -1
+	}
+	delete(s.expires, key)
+	s.changes.Add(key)
+	return 1
+}
+
+// HSet sets the value of a hash and removes the expiration of the field
 func (s *HashMap) HSet(key string, value []byte) int64 {
-	_, replaced := s.data[key]
-	s.data[key] = value
+	s.remove(key)
+	replaced := s.store(key, value)
+	delete(s.expires, key)
 	if replaced {
 		return 0
 	}
@@ -63,7 +372,7 @@ func (s *HashMap) HSet(key string, value []byte) int64 {
 
 // HGet gets the value of a hash
 func (s *HashMap) HGet(key string) []byte {
-	v, ok := s.data[key]
+	v, ok := s.get(key)
 	if !ok {
 		return nil
 	}
@@ -74,9 +383,9 @@ func (s *HashMap) HGet(key string) []byte {
 func (s *HashMap) HDel(key ...string) int64 {
 	var v int64 = 0
 	for _, k := range key {
-		_, deleted := s.data[k]
-		if deleted {
-			delete(s.data, k)
+		s.remove(k)
+		if s.del(k) {
+			delete(s.expires, k)
 			v++
 		}
 	}
@@ -85,38 +394,67 @@ func (s *HashMap) HDel(key ...string) int64 {
 
 // HLen gets the length of a hash
 func (s *HashMap) HLen() int64 {
-	return int64(len(s.data))
+	n := int64(s.size())
+	if len(s.expires) > 0 {
+		now := now()
+		for _, at := range s.expires {
+			if at <= now {
+				n--
+			}
+		}
+	}
+	return n
 }
 
 // HKeys gets the keys of a hash
 func (s *HashMap) HKeys() []string {
-	keys := make([]string, 0, len(s.data))
-	for k := range s.data {
-		keys = append(keys, k)
-	}
+	keys := make([]string, 0, s.size())
+	now := now()
+	s.each(func(k string, _ []byte) bool {
+		if !s.expired(k, now) {
+			keys = append(keys, k)
+		}
+		return true
+	})
 	return keys
 }
 
+// Iter calls fn on the fields not expired until it returns false
+func (s *HashMap) Iter(fn func(field string, value []byte) bool) {
+	now := now()
+	s.each(func(k string, v []byte) bool {
+		if s.expired(k, now) {
+			return true
+		}
+		return fn(k, v)
+	})
+}
+
 // HExists checks if a key exists in a hash
 func (s *HashMap) HExists(key string) bool {
-	_, ok := s.data[key]
+	_, ok := s.get(key)
 	return ok
 }
 
 // HGetAll gets all the values of a hash
 func (s *HashMap) HGetAll() map[string][]byte {
-	values := make(map[string][]byte, len(s.data))
-	for k, v := range s.data {
-		values[k] = v
-	}
+	values := make(map[string][]byte, s.size())
+	now := now()
+	s.each(func(k string, v []byte) bool {
+		if !s.expired(k, now) {
+			values[k] = v
+		}
+		return true
+	})
 	return values
 }
 
 // HIncrBy increments the value of a hash
 func (s *HashMap) HIncrBy(key string, value int64) (int64, error) {
-	v, ok := s.data[key]
+	s.remove(key)
+	v, ok := s.lookup(key)
 	if !ok {
-		s.data[key] = []byte(strconv.FormatInt(value, 10))
+		s.store(key, []byte(strconv.FormatInt(value, 10)))
 		return value, nil
 	}
 	vi, err := strconv.ParseInt(*(*string)(unsafe.Pointer(&v)), 10, 64)
@@ -124,15 +462,16 @@ func (s *HashMap) HIncrBy(key string, value int64) (int64, error) {
 		return 0, errors.New("ERR hash value is not an integer")
 	}
 	i := vi + value
-	s.data[key] = []byte(strconv.FormatInt(i, 10))
+	s.store(key, []byte(strconv.FormatInt(i, 10)))
 	return i, nil
 }
 
 // HIncrByFloat increments the value of a hash
 func (s *HashMap) HIncrByFloat(key string, value float64) (float64, error) {
-	v, ok := s.data[key]
+	s.remove(key)
+	v, ok := s.lookup(key)
 	if !ok {
-		s.data[key] = []byte(strconv.FormatFloat(value, 'f', -1, 64))
+		s.store(key, []byte(strconv.FormatFloat(value, 'f', -1, 64)))
 		return value, nil
 	}
 	vf, err := strconv.ParseFloat(*(*string)(unsafe.Pointer(&v)), 64)
@@ -140,14 +479,15 @@ func (s *HashMap) HIncrByFloat(key string, value float64) (float64, error) {
 		return 0, errors.New("ERR hash value is not an integer")
 	}
 	f := vf + value
-	s.data[key] = []byte(strconv.FormatFloat(f, 'f', -1, 64))
+	s.store(key, []byte(strconv.FormatFloat(f, 'f', -1, 64)))
 	return f, nil
 }
 
 // HMSet sets the values of a hash
 func (s *HashMap) HMSet(values map[string][]byte) {
 	for key, value := range values {
-		s.data[key] = value
+		s.store(key, value)
+		delete(s.expires, key)
 	}
 }
 
@@ -155,7 +495,7 @@ func (s *HashMap) HMSet(values map[string][]byte) {
 func (s *HashMap) HMGet(fields ...string) [][]byte {
 	values := make([][]byte, len(fields))
 	for i, key := range fields {
-		value, ok := s.data[key]
+		value, ok := s.get(key)
 		if ok {
 			values[i] = value
 		} else {
@@ -167,63 +507,86 @@ func (s *HashMap) HMGet(fields ...string) [][]byte {
 
 // HSetNX sets the value of a hash if it does not exist
 func (s *HashMap) HSetNX(key string, value []byte) bool {
-	_, ok := s.data[key]
+	s.remove(key)
+	_, ok := s.lookup(key)
 	if ok {
 		return false
 	}
-	s.data[key] = value
+	s.store(key, value)
 	return true
 }
 
 // HVals gets the values of a hash
 func (s *HashMap) HVals() [][]byte {
-	values := make([][]byte, 0, len(s.data))
-	for _, v := range s.data {
-		values = append(values, v)
-	}
+	values := make([][]byte, 0, s.size())
+	now := now()
+	s.each(func(k string, v []byte) bool {
+		if !s.expired(k, now) {
+			values = append(values, v)
+		}
+		return true
+	})
 	return values
 }
 
 // HScan scans the values of a hash
 func (s *HashMap) HScan(cursor int64, match string, count int64) (int64, map[string][]byte) {
-	values := make(map[string][]byte, len(s.data))
+	values := make(map[string][]byte, s.size())
 	var i int64 = 0
-	for k, v := range s.data {
+	now := now()
+	s.each(func(k string, v []byte) bool {
+		if s.expired(k, now) {
+			return true
+		}
 		matched, _ := filepath.Match(match, k)
 		if matched && i >= cursor {
 			values[k] = v
 		}
 		i++
-		if i >= cursor+count {
-			break
-		}
-	}
+		return i < cursor+count
+	})
 	return i, values
 }
 
 // HStrLen gets the length of a hash
 func (s *HashMap) HStrLen(field string) int64 {
-	v, ok := s.data[field]
+	v, ok := s.get(field)
 	if !ok {
 		return 0
 	}
 	return int64(len(*(*string)(unsafe.Pointer(&v))))
 }
 
+// GetValue encodes the hash, the length of a field with an expiration is negative
+// and the expiration precedes the field
 func (s *HashMap) GetValue() []byte {
 	values := make([]byte, 0, 1024*100)
-	for key, value := range s.data {
+	now := now()
+	s.each(func(key string, value []byte) bool {
 		kvPair := &keyValuePair{
 			key:   key,
 			value: value,
 		}
 		data := kvPair.encode()
+		at, ok := s.expires[key]
+		if ok {
+			if at <= now {
+				return true
+			}
+			data = append(binary.AppendVarint(nil, at), data...)
+		}
 		dataLen := len(data)
 		var b = make([]byte, 8+dataLen)
-		n := binary.PutVarint(b, int64(dataLen))
+		var n int
+		if ok {
+			n = binary.PutVarint(b, -int64(dataLen))
+		} else {
+			n = binary.PutVarint(b, int64(dataLen))
+		}
 		copy(b[n:], data)
 		values = append(values, b[:n+dataLen]...)
-	}
+		return true
+	})
 	return values
 }
 
@@ -237,9 +600,20 @@ func (s *HashMap) SetValue(values []byte) {
 		if n <= 0 {
 			break
 		}
+		var at int64
+		if dataLen < 0 {
+			dataLen = -dataLen
+			var m int
+			at, m = binary.Varint(values[n:])
+			n += m
+			dataLen -= int64(m)
+		}
 		end := n + int(dataLen)
 		item := decodeKeyValuePair(values[n:end])
 		s.HSet(item.key, item.value)
+		if at != 0 {
+			s.HPExpireAt(item.key, at, ExpireAlways)
+		}
 		values = values[end:]
 	}
 }
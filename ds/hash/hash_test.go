@@ -3,7 +3,11 @@ package hash
 import (
 	"bytes"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/diiyw/nodis/ds"
 )
 
 func TestHash_HSet(t *testing.T) {
@@ -61,6 +65,29 @@ func TestHash_HKeys(t *testing.T) {
 	}
 }
 
+func TestHash_Iter(t *testing.T) {
+	hash := NewHashMap()
+	hash.HSet("a", []byte("1"))
+	hash.HSet("b", []byte("2"))
+	hash.HSet("c", []byte("3"))
+	fields := make(map[string]string)
+	hash.Iter(func(field string, value []byte) bool {
+		fields[field] = string(value)
+		return true
+	})
+	if len(fields) != 3 || fields["b"] != "2" {
+		t.Errorf("Iter failed, expected 3 fields but got %v", fields)
+	}
+	var n int
+	hash.Iter(func(string, []byte) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("Iter failed, expected to stop after 1 field but got %d", n)
+	}
+}
+
 func TestHash_HExists(t *testing.T) {
 	hash := NewHashMap()
 	key := "testKey"
@@ -293,3 +320,122 @@ func TestGetSetValue(t *testing.T) {
 		t.Errorf("GetSetValue failed, expected %s but got %s", "testValue2", vv)
 	}
 }
+
+func TestHash_HPExpireAt(t *testing.T) {
+	h := NewHashMap()
+	h.HSet("a", []byte("1"))
+	h.HSet("b", []byte("2"))
+	at := time.Now().Add(time.Hour).UnixMilli()
+	tests := []struct {
+		field string
+		at    int64
+		cond  ExpireCondition
+		want  int64
+	}{
+		{"none", at, ExpireAlways, -2},
+		{"a", at, ExpireXX, 0},
+		{"a", at, ExpireNX, 1},
+		{"a", at + 1, ExpireNX, 0},
+		{"a", at - 1, ExpireGT, 0},
+		{"a", at + 1, ExpireGT, 1},
+		{"a", at + 2, ExpireLT, 0},
+		{"a", at, ExpireLT, 1},
+		{"b", at, ExpireGT, 0},
+		{"b", at, ExpireLT, 1},
+		{"b", 1, ExpireAlways, 2},
+	}
+	for _, tt := range tests {
+		if got := h.HPExpireAt(tt.field, tt.at, tt.cond); got != tt.want {
+			t.Errorf("HPExpireAt(%s, %d, %d) = %d, want %d", tt.field, tt.at, tt.cond, got, tt.want)
+		}
+	}
+	if h.HExists("b") || h.HLen() != 1 {
+		t.Errorf("HPExpireAt() didn't delete the field")
+	}
+	if got := h.HPExpireTime("a"); got != at {
+		t.Errorf("HPExpireTime() = %d, want %d", got, at)
+	}
+	if got := h.HPersist("a"); got != 1 {
+		t.Errorf("HPersist() = %d, want %d", got, 1)
+	}
+	if got := h.HPExpireTime("a"); got != -1 {
+		t.Errorf("HPExpireTime() = %d, want %d", got, -1)
+	}
+	if got := h.HPersist("a"); got != -1 {
+		t.Errorf("HPersist() = %d, want %d", got, -1)
+	}
+}
+
+func TestHash_ExpiredFields(t *testing.T) {
+	h := NewHashMap()
+	h.HSet("a", []byte("1"))
+	h.HSet("b", []byte("2"))
+	h.HSet("c", []byte("3"))
+	h.HPExpireAt("a", time.Now().Add(time.Hour).UnixMilli(), ExpireAlways)
+	h.HPExpireAt("b", time.Now().Add(time.Hour).UnixMilli(), ExpireAlways)
+	// expire b without removing it
+	h.expires["b"] = time.Now().UnixMilli() - 1
+	if h.HGet("b") != nil || h.HExists("b") || h.HLen() != 2 || len(h.HGetAll()) != 2 || len(h.HKeys()) != 2 {
+		t.Errorf("expired field is visible")
+	}
+	h2 := NewHashMap()
+	h2.SetValue(h.GetValue())
+	if h2.HLen() != 2 || h2.HPExpireTime("a") != h.HPExpireTime("a") || h2.HPExpireTime("c") != -1 {
+		t.Errorf("GetValue() didn't keep the expirations")
+	}
+	if got := h.RemoveExpired(time.Now().UnixMilli()); got != 1 {
+		t.Errorf("RemoveExpired() = %d, want %d", got, 1)
+	}
+	h.HSet("a", []byte("4"))
+	if got := h.HPExpireTime("a"); got != -1 {
+		t.Errorf("HSet() kept the expiration %d", got)
+	}
+}
+
+func TestHash_Encoding(t *testing.T) {
+	e := ds.NewEncodings(ds.DefaultEncodingLimits)
+	e.SetLimit("hash-max-listpack-entries", 3)
+
+	h := NewHashMapWithEncodings(e)
+	h.HSet("a", []byte("1"))
+	h.HSet("b", []byte("2"))
+	h.HSet("a", []byte("3"))
+	h.HDel("b")
+	h.HSet("c", []byte("4"))
+	h.HSet("d", []byte("5"))
+	if h.Encoding() != EncodingListpack || h.HLen() != 3 || string(h.HGet("a")) != "3" || h.HExists("b") {
+		t.Errorf("listpack = %s %v", h.Encoding(), h.HGetAll())
+	}
+	h.HSet("e", []byte("6"))
+	if h.Encoding() != EncodingHashtable || h.HLen() != 4 || string(h.HGet("d")) != "5" {
+		t.Errorf("HSet() over the entries limit = %s %v", h.Encoding(), h.HGetAll())
+	}
+
+	h = NewHashMap()
+	h.HSet("a", []byte(strings.Repeat("x", 65)))
+	if h.Encoding() != EncodingHashtable || len(h.HGet("a")) != 65 {
+		t.Errorf("HSet() over the value limit = %s", h.Encoding())
+	}
+
+	h = NewHashMap()
+	h.HSet("a", []byte("1"))
+	h.HIncrBy("a", 2)
+	h2 := NewHashMap()
+	h2.SetValue(h.GetValue())
+	if h2.Encoding() != EncodingListpack || string(h2.HGet("a")) != "3" {
+		t.Errorf("SetValue() = %s %v", h2.Encoding(), h2.HGetAll())
+	}
+}
+
+func TestHash_Changes(t *testing.T) {
+	h := NewHashMap()
+	h.HSet("a", []byte("1"))
+	h.Changes().Track("key")
+	h.HSet("b", []byte("2"))
+	h.HDel("a")
+	h.HPExpireAt("b", time.Now().Add(time.Hour).UnixMilli(), ExpireAlways)
+	h.HGet("b")
+	if fields := h.Changes().Fields(); len(fields) != 2 {
+		t.Errorf("Changes() = %v, want [a b]", fields)
+	}
+}
@@ -2,22 +2,57 @@ package list
 
 import (
 	"bytes"
+	"compress/flate"
 	"encoding/binary"
+	"io"
+	"sync"
 
 	"github.com/diiyw/nodis/ds"
 )
 
-type Node struct {
+const (
+	// DefaultChunkSize is the default max number of elements of a chunk
+	DefaultChunkSize = 128
+	// minCompressSize is the min size of the chunks worth compressing
+	minCompressSize = 48
+)
+
+// Direction is an end of the list
+type Direction uint8
+
+const (
+	Left Direction = iota
+	Right
+)
+
+// Options are the options of a list
+type Options struct {
+	// ChunkSize is the max number of elements of a chunk, DefaultChunkSize if not positive
+	ChunkSize int
+	// CompressDepth is the number of chunks at each end of the list that are never compressed,
+	// the interior chunks are compressed. 0 disables the compression.
+	CompressDepth int
+}
+
+// chunk is a packed array of elements, each element is prefixed by its varint length.
+// The layout of a chunk is the layout of the serialized list, so chunks are serialized as is.
+type chunk struct {
 	data []byte
-	next *Node
-	prev *Node
+	// compressed holds the data of a compressed chunk, data is nil then
+	compressed []byte
+	// interior is set on the chunks that are deeper than the compress depth
+	interior bool
+	count    int
+	prev     *chunk
+	next     *chunk
 }
 
-// LinkedList is a doubly linked list
+// LinkedList is a doubly linked list of chunks
 type LinkedList struct {
-	head   *Node
-	tail   *Node
+	head   *chunk
+	tail   *chunk
 	length int64
+	opts   Options
 }
 
 // Type returns the type of the data structure
@@ -25,61 +60,67 @@ func (l *LinkedList) Type() ds.ValueType {
 	return ds.List
 }
 
-// NewLinkedList returns a new doubly linked list
+// NewLinkedList returns a new list with the default options
 func NewLinkedList() *LinkedList {
-	return &LinkedList{}
+	return NewLinkedListWithOptions(Options{})
+}
+
+// NewLinkedListWithOptions returns a new list
+func NewLinkedListWithOptions(opts Options) *LinkedList {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	return &LinkedList{opts: opts}
 }
 
 // LPush adds an element to the head of the list
 func (l *LinkedList) LPush(data ...[]byte) {
 	for _, datum := range data {
-		newNode := &Node{data: datum}
-		if l.head == nil {
-			l.head = newNode
-			l.tail = newNode
-		} else {
-			newNode.next = l.head
-			l.head.prev = newNode
-			l.head = newNode
+		c := l.head
+		if c == nil || c.count >= l.opts.ChunkSize {
+			c = &chunk{}
+			l.insertChunk(nil, c)
 		}
+		// the previous data is never modified, the elements returned before may still use it
+		b := appendElement(make([]byte, 0, binary.MaxVarintLen64+len(datum)+len(c.data)), datum)
+		c.data = append(b, c.data...)
+		c.count++
 		l.length++
 	}
+	l.compressEnds()
 }
 
 // RPush adds an element to the end of the list
 func (l *LinkedList) RPush(data ...[]byte) {
 	for _, datum := range data {
-		newNode := &Node{data: datum}
-		if l.head == nil {
-			l.head = newNode
-			l.tail = newNode
-		} else {
-			l.tail.next = newNode
-			newNode.prev = l.tail
-			l.tail = newNode
+		c := l.tail
+		if c == nil || c.count >= l.opts.ChunkSize {
+			c = &chunk{}
+			l.insertChunk(l.tail, c)
 		}
+		c.data = appendElement(c.data, datum)
+		c.count++
 		l.length++
 	}
+	l.compressEnds()
 }
 
 // LPop returns the first element of the list
 func (l *LinkedList) LPop(count int64) [][]byte {
 	if l.head == nil {
-		return nil // 链表为空
+		return nil
 	}
 	var result [][]byte
-	for i := int64(0); i < count; i++ {
-		if l.head == nil {
-			break
-		}
-		result = append(result, l.head.data)
-		l.head = l.head.next
-		if l.head != nil {
-			l.head.prev = nil
-		} else {
-			l.tail = nil
-		}
+	for i := int64(0); i < count && l.head != nil; i++ {
+		c := l.head
+		v, rest := nextElement(c.data)
+		c.data = rest
+		c.count--
 		l.length--
+		result = append(result, v)
+		if c.count == 0 {
+			l.removeChunk(c)
+		}
 	}
 	return result
 }
@@ -90,18 +131,84 @@ func (l *LinkedList) RPop(count int64) [][]byte {
 		return nil
 	}
 	var result [][]byte
-	for i := int64(0); i < count; i++ {
-		if l.tail == nil {
-			break
+	for i := int64(0); i < count && l.tail != nil; i++ {
+		c := l.tail
+		v, offset := lastElement(c.data)
+		// the capacity is cut so that the next push doesn't overwrite the popped element
+		c.data = c.data[:offset:offset]
+		c.count--
+		l.length--
+		result = append(result, v)
+		if c.count == 0 {
+			l.removeChunk(c)
 		}
-		result = append(result, l.tail.data)
-		l.tail = l.tail.prev
-		if l.tail != nil {
-			l.tail.next = nil
-		} else {
-			l.head = nil
+	}
+	return result
+}
+
+// Pop removes and returns count elements from the given end of the list
+func (l *LinkedList) Pop(where Direction, count int64) [][]byte {
+	if where == Left {
+		return l.LPop(count)
+	}
+	return l.RPop(count)
+}
+
+// Push adds the elements to the given end of the list
+func (l *LinkedList) Push(where Direction, data ...[]byte) {
+	if where == Left {
+		l.LPush(data...)
+		return
+	}
+	l.RPush(data...)
+}
+
+// LPos returns the indexes of the elements matching element. A negative rank scans
+// from the tail and skips the first -rank-1 matches, a positive rank skips the first rank-1 matches.
+// All the matches are returned if count is 0 and at most maxLen elements are compared if maxLen is positive.
+func (l *LinkedList) LPos(element []byte, rank, count, maxLen int64) []int64 {
+	var result []int64
+	var compared int64
+	match := func(index int64, v []byte) bool {
+		if maxLen > 0 && compared >= maxLen {
+			return false
+		}
+		compared++
+		if bytes.Equal(v, element) {
+			if rank > 1 {
+				rank--
+				return true
+			}
+			result = append(result, index)
+			return count <= 0 || int64(len(result)) < count
+		}
+		return true
+	}
+	if rank < 0 {
+		rank = -rank
+		index := l.length - 1
+		for c := l.tail; c != nil; c = c.prev {
+			values := elements(c.bytes(), c.count)
+			for i := len(values) - 1; i >= 0; i-- {
+				if !match(index, values[i]) {
+					return result
+				}
+				index--
+			}
+		}
+		return result
+	}
+	var index int64
+	for c := l.head; c != nil; c = c.next {
+		b := c.bytes()
+		for i := 0; i < c.count; i++ {
+			var v []byte
+			v, b = nextElement(b)
+			if !match(index, v) {
+				return result
+			}
+			index++
 		}
-		l.length--
 	}
 	return result
 }
@@ -115,39 +222,45 @@ func (l *LinkedList) LRange(start, end int64) [][]byte {
 	return result
 }
 
-// LRange returns a range of elements from the list
+// forEach calls fn on a range of elements of the list, start and end may be negative offsets from the end of the list
 func (l *LinkedList) forEach(start, end int64, fn func(v []byte)) {
-	currentNode := l.head
-	var index int64 = 0
-	if start != 0 && start >= end {
+	start, end, ok := l.normalizeRange(start, end)
+	if !ok {
 		return
 	}
+	c, offset := l.locate(start)
+	remaining := end - start + 1
+	for ; c != nil && remaining > 0; c = c.next {
+		b := c.bytes()
+		for i := 0; i < c.count && remaining > 0; i++ {
+			var v []byte
+			v, b = nextElement(b)
+			if i < offset {
+				continue
+			}
+			fn(v)
+			remaining--
+		}
+		offset = 0
+	}
+}
+
+// normalizeRange converts the negative offsets of a range and clamps it to the list,
+// it returns false if the range is empty
+func (l *LinkedList) normalizeRange(start, end int64) (int64, int64, bool) {
 	if start < 0 {
-		start = l.size() + start
+		start = l.length + start
 	}
 	if end < 0 {
-		end = l.size() + end
+		end = l.length + end
 	}
-	for currentNode != nil {
-		if index >= start && index <= end {
-			fn(currentNode.data)
-		}
-		if index > end {
-			break
-		}
-		currentNode = currentNode.next
-		index++
+	if start < 0 {
+		start = 0
 	}
-}
-
-func (l *LinkedList) size() int64 {
-	currentNode := l.head
-	var length int64 = 0
-	for currentNode != nil {
-		length++
-		currentNode = currentNode.next
+	if end >= l.length {
+		end = l.length - 1
 	}
-	return length
+	return start, end, start <= end
 }
 
 // LLen returns the length of the list
@@ -160,16 +273,16 @@ func (l *LinkedList) LIndex(index int64) []byte {
 	if index < 0 {
 		index = l.length + index
 	}
-	currentNode := l.head
-	var currentIndex int64 = 0
-	for currentNode != nil {
-		if currentIndex == index {
-			return currentNode.data
-		}
-		currentNode = currentNode.next
-		currentIndex++
+	c, offset := l.locate(index)
+	if c == nil {
+		return nil
+	}
+	b := c.bytes()
+	var v []byte
+	for i := 0; i <= offset; i++ {
+		v, b = nextElement(b)
 	}
-	return nil
+	return v
 }
 
 // LInsert inserts the element before or after the pivot element
@@ -177,33 +290,21 @@ func (l *LinkedList) LIndex(index int64) []byte {
 // 0 when the key doesn't exist.
 // -1 when the pivot wasn't found.
 func (l *LinkedList) LInsert(pivot, data []byte, before bool) int64 {
-	currentNode := l.head
-	for currentNode != nil {
-		if bytes.Equal(currentNode.data, pivot) {
-			newNode := &Node{data: data}
-			if before {
-				if currentNode.prev != nil {
-					currentNode.prev.next = newNode
-					newNode.prev = currentNode.prev
-				} else {
-					l.head = newNode
-				}
-				newNode.next = currentNode
-				currentNode.prev = newNode
-			} else {
-				if currentNode.next != nil {
-					currentNode.next.prev = newNode
-					newNode.next = currentNode.next
-				} else {
-					l.tail = newNode
-				}
-				newNode.prev = currentNode
-				currentNode.next = newNode
+	for c := l.head; c != nil; c = c.next {
+		values := elements(c.bytes(), c.count)
+		for i, v := range values {
+			if !bytes.Equal(v, pivot) {
+				continue
+			}
+			if !before {
+				i++
 			}
+			values = append(values[:i], append([][]byte{data}, values[i:]...)...)
+			l.setElements(c, values)
 			l.length++
+			l.compressEnds()
 			return l.length
 		}
-		currentNode = currentNode.next
 	}
 	return -1
 }
@@ -215,103 +316,61 @@ func (l *LinkedList) LInsert(pivot, data []byte, before bool) int64 {
 // count = 0: Remove all elements equal to element.
 func (l *LinkedList) LRem(count int64, value []byte) int64 {
 	var removed int64
-	if count > 0 {
-		removed = l.lRem(count, value)
-	} else if count < 0 {
-		removed = l.lRevRem(-count, value)
-	} else {
-		removed = l.lRemAll(value)
+	reverse := count < 0
+	if reverse {
+		count = -count
 	}
-	return removed
-}
-
-// lRemAll removes all elements equal to value from the list
-func (l *LinkedList) lRemAll(value []byte) int64 {
-	var removed int64
-	currentNode := l.head
-	for currentNode != nil {
-		if bytes.Equal(currentNode.data, value) {
-			if currentNode.prev != nil {
-				currentNode.prev.next = currentNode.next
-			} else {
-				l.head = currentNode.next
-			}
-			if currentNode.next != nil {
-				currentNode.next.prev = currentNode.prev
-			} else {
-				l.tail = currentNode.prev
-			}
-			l.length--
-			removed++
-		}
-		currentNode = currentNode.next
+	c := l.head
+	if reverse {
+		c = l.tail
 	}
-	return removed
-}
-
-func (l *LinkedList) lRem(count int64, value []byte) int64 {
-	var removed int64
-	currentNode := l.head
-	for currentNode != nil {
-		if bytes.Equal(currentNode.data, value) {
-			if count == 0 || removed < count {
-				if currentNode.prev != nil {
-					currentNode.prev.next = currentNode.next
-				} else {
-					l.head = currentNode.next
-				}
-				if currentNode.next != nil {
-					currentNode.next.prev = currentNode.prev
-				} else {
-					l.tail = currentNode.prev
-				}
-				l.length--
-				removed++
+	for c != nil && (count == 0 || removed < count) {
+		next := c.next
+		if reverse {
+			next = c.prev
+		}
+		values := elements(c.bytes(), c.count)
+		kept := make([][]byte, 0, len(values))
+		n := removed
+		for i := range values {
+			if reverse {
+				i = len(values) - 1 - i
+			}
+			if bytes.Equal(values[i], value) && (count == 0 || n < count) {
+				n++
+				continue
 			}
+			kept = append(kept, values[i])
 		}
-		currentNode = currentNode.next
-	}
-	return removed
-}
-
-func (l *LinkedList) lRevRem(count int64, value []byte) int64 {
-	var removed int64
-	currentNode := l.tail
-	for currentNode != nil {
-		if bytes.Equal(currentNode.data, value) {
-			if count == 0 || removed < count {
-				if currentNode.next != nil {
-					currentNode.next.prev = currentNode.prev
-				} else {
-					l.tail = currentNode.prev
-				}
-				if currentNode.prev != nil {
-					currentNode.prev.next = currentNode.next
-				} else {
-					l.head = currentNode.next
+		if n != removed {
+			if reverse {
+				for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+					kept[i], kept[j] = kept[j], kept[i]
 				}
-				l.length--
-				removed++
 			}
+			l.length -= n - removed
+			removed = n
+			l.setElements(c, kept)
 		}
-		currentNode = currentNode.prev
+		c = next
 	}
+	l.compressEnds()
 	return removed
 }
 
 // LSet sets the list element at index to value
 func (l *LinkedList) LSet(index int64, value []byte) bool {
-	currentNode := l.head
-	var currentIndex int64 = 0
-	for currentNode != nil {
-		if currentIndex == index {
-			currentNode.data = value
-			return true
-		}
-		currentNode = currentNode.next
-		currentIndex++
+	if index < 0 {
+		index = l.length + index
+	}
+	c, offset := l.locate(index)
+	if c == nil {
+		return false
 	}
-	return false
+	values := elements(c.bytes(), c.count)
+	values[offset] = value
+	l.setElements(c, values)
+	return true
 }
 
 // LTrim trims an existing list so that it will contain only the specified range of elements specified
@@ -319,55 +378,316 @@ func (l *LinkedList) LSet(index int64, value []byte) bool {
 // start and end can also be negative numbers indicating offsets from the end of the list, where -1 is the last element of the list, -2 the penultimate element and so on.
 // Out of range indexes will not produce an error: if start is larger than the end of the list, or start > end, the result will be an empty list (which causes key to be removed). If end is larger than the end of the list, Redis will treat it like the last element of the list.
 func (l *LinkedList) LTrim(start, end int64) {
-	currentNode := l.head
-	var currentIndex int64 = 0
-	if end < 0 {
-		end = l.size() + end
-	}
-	for currentNode != nil {
-		if currentIndex < start || currentIndex > end {
-			if currentNode.prev != nil {
-				currentNode.prev.next = currentNode.next
-			} else {
-				l.head = currentNode.next
-			}
-			if currentNode.next != nil {
-				currentNode.next.prev = currentNode.prev
-			} else {
-				l.tail = currentNode.prev
-			}
-			l.length--
+	start, end, ok := l.normalizeRange(start, end)
+	if !ok {
+		l.head, l.tail, l.length = nil, nil, 0
+		return
+	}
+	l.trim(l.length-1-end, true)
+	l.trim(start, false)
+	l.compressEnds()
+}
+
+// trim removes n elements from the tail or the head of the list, whole chunks are dropped at once
+func (l *LinkedList) trim(n int64, tail bool) {
+	for n > 0 {
+		c := l.head
+		if tail {
+			c = l.tail
+		}
+		if int64(c.count) <= n {
+			n -= int64(c.count)
+			l.length -= int64(c.count)
+			l.removeChunk(c)
+			continue
 		}
-		currentNode = currentNode.next
-		currentIndex++
+		values := elements(c.bytes(), c.count)
+		if tail {
+			values = values[:len(values)-int(n)]
+		} else {
+			values = values[n:]
+		}
+		l.length -= n
+		l.setElements(c, values)
+		return
 	}
 }
 
 // GetValue returns the byte slice of the list
 func (l *LinkedList) GetValue() []byte {
 	var list []byte
-	l.forEach(0, -1, func(v []byte) {
-		var b = make([]byte, len(v)+8)
-		var vLen = len(v)
-		n := binary.PutVarint(b, int64(vLen))
-		copy(b[n:], v)
-		list = append(list, b[:n+vLen]...)
-	})
+	for c := l.head; c != nil; c = c.next {
+		list = append(list, c.bytes()...)
+	}
 	return list
 }
 
+// Options returns the options of the list
+func (l *LinkedList) Options() Options {
+	return l.opts
+}
+
+// SetOptions changes the options of the list, its elements are packed again by the new options
+func (l *LinkedList) SetOptions(opts Options) {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	if opts == l.opts {
+		return
+	}
+	value := l.GetValue()
+	l.head, l.tail, l.length = nil, nil, 0
+	l.opts = opts
+	l.SetValue(value)
+}
+
 // SetValue restores the list from the byte slice
 func (l *LinkedList) SetValue(list []byte) {
-	for {
-		if len(list) == 0 {
+	for len(list) > 0 {
+		c := &chunk{}
+		var size int
+		for c.count < l.opts.ChunkSize && size < len(list) {
+			vLen, n := binary.Varint(list[size:])
+			if n <= 0 || vLen < 0 || int64(len(list)-size-n) < vLen {
+				// corrupted data, keep the elements read so far
+				list = list[:size]
+				break
+			}
+			size += n + int(vLen)
+			c.count++
+		}
+		if c.count == 0 {
 			break
 		}
-		vLen, n := binary.Varint(list)
-		if n == 0 {
+		c.data = append([]byte(nil), list[:size]...)
+		list = list[size:]
+		l.insertChunk(l.tail, c)
+		l.length += int64(c.count)
+	}
+	l.compressEnds()
+}
+
+// locate returns the chunk holding the element at index and the offset of the element in the chunk,
+// the chunks are skipped from the nearest end of the list
+func (l *LinkedList) locate(index int64) (*chunk, int) {
+	if index < 0 || index >= l.length {
+		return nil, 0
+	}
+	if index < l.length/2 {
+		for c := l.head; c != nil; c = c.next {
+			if index < int64(c.count) {
+				return c, int(index)
+			}
+			index -= int64(c.count)
+		}
+		return nil, 0
+	}
+	index = l.length - 1 - index
+	for c := l.tail; c != nil; c = c.prev {
+		if index < int64(c.count) {
+			return c, c.count - 1 - int(index)
+		}
+		index -= int64(c.count)
+	}
+	return nil, 0
+}
+
+// insertChunk links c after prev, c becomes the head if prev is nil
+func (l *LinkedList) insertChunk(prev, c *chunk) {
+	c.prev = prev
+	if prev == nil {
+		c.next = l.head
+		l.head = c
+	} else {
+		c.next = prev.next
+		prev.next = c
+	}
+	if c.next == nil {
+		l.tail = c
+	} else {
+		c.next.prev = c
+	}
+}
+
+// removeChunk unlinks c, the ends of the list are decompressed if needed
+func (l *LinkedList) removeChunk(c *chunk) {
+	if c.prev == nil {
+		l.head = c.next
+	} else {
+		c.prev.next = c.next
+	}
+	if c.next == nil {
+		l.tail = c.prev
+	} else {
+		c.next.prev = c.prev
+	}
+	c.prev, c.next = nil, nil
+	l.compressEnds()
+}
+
+// setElements replaces the elements of the chunk, the chunk is removed if values is empty
+// and split if values exceed the chunk size
+func (l *LinkedList) setElements(c *chunk, values [][]byte) {
+	if len(values) == 0 {
+		l.removeChunk(c)
+		return
+	}
+	if len(values) > l.opts.ChunkSize {
+		half := len(values) / 2
+		next := &chunk{interior: c.interior}
+		l.insertChunk(c, next)
+		next.set(encodeElements(values[half:]), len(values)-half)
+		values = values[:half]
+	}
+	c.set(encodeElements(values), len(values))
+}
+
+// compressEnds keeps the chunks within the compress depth of each end decompressed and compresses
+// the others. The decompressed chunks are always contiguous from the ends, so only the chunks up to
+// the first compressed chunk need to be checked.
+func (l *LinkedList) compressEnds() {
+	depth := l.opts.CompressDepth
+	if depth <= 0 || l.head == nil {
+		return
+	}
+	h, t := l.head, l.tail
+	for i := 0; i < depth; i++ {
+		h.setInterior(false)
+		t.setInterior(false)
+		if h == t || h.next == t {
+			return
+		}
+		h, t = h.next, t.prev
+	}
+	for c := h; !c.interior; c = c.next {
+		c.setInterior(true)
+		if c == t {
+			break
+		}
+	}
+	for c := t; !c.interior; c = c.prev {
+		c.setInterior(true)
+		if c == h {
 			break
 		}
-		v := list[n : n+int(vLen)]
-		list = list[n+int(vLen):]
-		l.RPush(v)
 	}
 }
+
+// bytes returns the packed elements of the chunk
+func (c *chunk) bytes() []byte {
+	if c.compressed != nil {
+		return decompress(c.compressed)
+	}
+	return c.data
+}
+
+// set replaces the packed elements of the chunk, the data of an interior chunk is compressed
+func (c *chunk) set(data []byte, count int) {
+	c.data = data
+	c.compressed = nil
+	c.count = count
+	if c.interior {
+		c.compress()
+	}
+}
+
+func (c *chunk) setInterior(interior bool) {
+	c.interior = interior
+	if interior {
+		c.compress()
+		return
+	}
+	if c.compressed != nil {
+		c.data = decompress(c.compressed)
+		c.compressed = nil
+	}
+}
+
+// compress compresses the data of the chunk unless it is too small to be worth it
+func (c *chunk) compress() {
+	if c.compressed != nil || len(c.data) < minCompressSize {
+		return
+	}
+	if b := compress(c.data); len(b)+8 < len(c.data) {
+		c.compressed = b
+		c.data = nil
+	}
+}
+
+var (
+	flateWriters sync.Pool
+	flateReaders sync.Pool
+)
+
+func compress(data []byte) []byte {
+	var buf bytes.Buffer
+	w, ok := flateWriters.Get().(*flate.Writer)
+	if ok {
+		w.Reset(&buf)
+	} else {
+		w, _ = flate.NewWriter(&buf, flate.BestSpeed)
+	}
+	_, _ = w.Write(data)
+	_ = w.Close()
+	flateWriters.Put(w)
+	return buf.Bytes()
+}
+
+func decompress(data []byte) []byte {
+	r, ok := flateReaders.Get().(io.ReadCloser)
+	if ok {
+		_ = r.(flate.Resetter).Reset(bytes.NewReader(data), nil)
+	} else {
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+	b, _ := io.ReadAll(r)
+	flateReaders.Put(r)
+	return b
+}
+
+func appendElement(b, v []byte) []byte {
+	b = binary.AppendVarint(b, int64(len(v)))
+	return append(b, v...)
+}
+
+// nextElement returns the first element of the packed elements and the remaining elements,
+// the capacity of the element is cut so that appending to it doesn't overwrite the next element
+func nextElement(b []byte) ([]byte, []byte) {
+	vLen, n := binary.Varint(b)
+	if n <= 0 || vLen < 0 || int64(len(b)-n) < vLen {
+		return nil, nil
+	}
+	end := n + int(vLen)
+	return b[n:end:end], b[end:]
+}
+
+// lastElement returns the last element of the packed elements and its offset
+func lastElement(b []byte) ([]byte, int) {
+	var v []byte
+	var offset int
+	for rest := b; len(rest) > 0; {
+		offset = len(b) - len(rest)
+		v, rest = nextElement(rest)
+	}
+	return v, offset
+}
+
+func elements(b []byte, count int) [][]byte {
+	values := make([][]byte, count)
+	for i := range values {
+		values[i], b = nextElement(b)
+	}
+	return values
+}
+
+func encodeElements(values [][]byte) []byte {
+	size := 0
+	for _, v := range values {
+		size += binary.MaxVarintLen64 + len(v)
+	}
+	b := make([]byte, 0, size)
+	for _, v := range values {
+		b = appendElement(b, v)
+	}
+	return b
+}
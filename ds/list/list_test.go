@@ -1,6 +1,10 @@
 package list
 
 import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"reflect"
 	"strconv"
 	"testing"
 )
@@ -186,3 +190,211 @@ func TestGetSetValue(t *testing.T) {
 		}
 	}
 }
+
+func TestList_LPos(t *testing.T) {
+	l := NewLinkedList()
+	for _, v := range []string{"a", "b", "c", "1", "2", "3", "c", "c"} {
+		l.RPush([]byte(v))
+	}
+	tests := []struct {
+		rank, count, maxLen int64
+		want                []int64
+	}{
+		{1, 1, 0, []int64{2}},
+		{2, 1, 0, []int64{6}},
+		{-1, 1, 0, []int64{7}},
+		{1, 0, 0, []int64{2, 6, 7}},
+		{-2, 0, 0, []int64{6, 2}},
+		{1, 0, 3, []int64{2}},
+		{-1, 0, 1, []int64{7}},
+		{4, 1, 0, nil},
+	}
+	for _, tt := range tests {
+		if got := l.LPos([]byte("c"), tt.rank, tt.count, tt.maxLen); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("LPos(%v, %v, %v) = %v, want %v", tt.rank, tt.count, tt.maxLen, got, tt.want)
+		}
+	}
+}
+
+func TestList_PopPush(t *testing.T) {
+	l := NewLinkedList()
+	l.Push(Right, []byte("a"), []byte("b"))
+	l.Push(Left, []byte("c"))
+	if v := l.Pop(Right, 1); string(v[0]) != "b" {
+		t.Errorf("Pop(Right) = %s, want %s", v[0], "b")
+	}
+	if v := l.Pop(Left, 1); string(v[0]) != "c" {
+		t.Errorf("Pop(Left) = %s, want %s", v[0], "c")
+	}
+}
+
+// model is a plain slice implementation of the list used to check the chunked list
+type model [][]byte
+
+func (m model) normalize(start, end int64) (int64, int64) {
+	n := int64(len(m))
+	if start < 0 {
+		start += n
+	}
+	if end < 0 {
+		end += n
+	}
+	return max(start, 0), min(end, n-1)
+}
+
+func (m model) lRange(start, end int64) [][]byte {
+	start, end = m.normalize(start, end)
+	if start > end {
+		return nil
+	}
+	return m[start : end+1]
+}
+
+func TestList_Chunks(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, opts := range []Options{{ChunkSize: 1}, {ChunkSize: 3, CompressDepth: 1}, {ChunkSize: 4, CompressDepth: 2}, {}} {
+		l := NewLinkedListWithOptions(opts)
+		var m model
+		for i := 0; i < 3000; i++ {
+			// repeated values make the chunks compressible
+			v := bytes.Repeat([]byte(strconv.Itoa(r.Intn(20))), 1+r.Intn(30))
+			start, end := int64(r.Intn(40)-20), int64(r.Intn(40)-20)
+			switch r.Intn(9) {
+			case 0:
+				l.LPush(v)
+				m = append(model{v}, m...)
+			case 1, 2:
+				l.RPush(v, v)
+				m = append(m, v, v)
+			case 3:
+				l.LPop(2)
+				m = m[min(2, len(m)):]
+			case 4:
+				l.RPop(1)
+				m = m[:max(len(m)-1, 0)]
+			case 5:
+				if len(m) > 0 {
+					index := r.Intn(len(m))
+					l.LSet(int64(index), v)
+					m[index] = v
+				}
+			case 6:
+				if len(m) > 0 {
+					pivot := m[r.Intn(len(m))]
+					l.LInsert(pivot, v, false)
+					index := 0
+					for !bytes.Equal(m[index], pivot) {
+						index++
+					}
+					m = append(m[:index+1], append(model{v}, m[index+1:]...)...)
+				}
+			case 7:
+				count := int64(r.Intn(5) - 2)
+				l.LRem(count, v)
+				kept := model{}
+				removed := int64(0)
+				for j := range m {
+					k := j
+					if count < 0 {
+						k = len(m) - 1 - j
+					}
+					if bytes.Equal(m[k], v) && (count == 0 || removed < max(count, -count)) {
+						removed++
+						continue
+					}
+					kept = append(kept, m[k])
+				}
+				if count < 0 {
+					for a, b := 0, len(kept)-1; a < b; a, b = a+1, b-1 {
+						kept[a], kept[b] = kept[b], kept[a]
+					}
+				}
+				m = kept
+			case 8:
+				if r.Intn(10) == 0 {
+					l.LTrim(start, end)
+					m = append(model{}, m.lRange(start, end)...)
+				}
+			}
+			if l.LLen() != int64(len(m)) {
+				t.Fatalf("%+v: LLen() = %d, want %d", opts, l.LLen(), len(m))
+			}
+			if got, want := l.LRange(start, end), m.lRange(start, end); len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, [][]byte(want))) {
+				t.Fatalf("%+v: LRange(%d, %d) = %s, want %s", opts, start, end, got, want)
+			}
+			if index := int64(r.Intn(20) - 10); index >= -int64(len(m)) && index < int64(len(m)) {
+				want := m[(index+int64(len(m)))%int64(len(m))]
+				if got := l.LIndex(index); !bytes.Equal(got, want) {
+					t.Fatalf("%+v: LIndex(%d) = %s, want %s", opts, index, got, want)
+				}
+			}
+		}
+		l2 := NewLinkedListWithOptions(opts)
+		l2.SetValue(l.GetValue())
+		if !reflect.DeepEqual(l2.LRange(0, -1), l.LRange(0, -1)) {
+			t.Errorf("%+v: SetValue(GetValue()) failed", opts)
+		}
+	}
+}
+
+func TestList_Compression(t *testing.T) {
+	l := NewLinkedListWithOptions(Options{ChunkSize: 8, CompressDepth: 1})
+	v := bytes.Repeat([]byte("a"), 32)
+	for i := 0; i < 64; i++ {
+		l.RPush(v)
+	}
+	compressed := 0
+	for c := l.head; c != nil; c = c.next {
+		if c.compressed != nil {
+			compressed++
+		}
+	}
+	if compressed != 6 || l.head.compressed != nil || l.tail.compressed != nil {
+		t.Errorf("compressed chunks = %d, want %d", compressed, 6)
+	}
+	if got := l.LIndex(30); !bytes.Equal(got, v) {
+		t.Errorf("LIndex() = %s, want %s", got, v)
+	}
+	l.LPop(8)
+	if l.head.compressed != nil {
+		t.Errorf("head is compressed")
+	}
+}
+
+func TestList_SetOptions(t *testing.T) {
+	l := NewLinkedList()
+	v := bytes.Repeat([]byte("a"), 32)
+	for i := 0; i < 64; i++ {
+		l.RPush(v)
+	}
+	opts := Options{ChunkSize: 8, CompressDepth: 1}
+	l.SetOptions(opts)
+	chunks, compressed := 0, 0
+	for c := l.head; c != nil; c = c.next {
+		chunks++
+		if c.compressed != nil {
+			compressed++
+		}
+	}
+	if l.Options() != opts || chunks != 8 || compressed != 6 {
+		t.Errorf("chunks = %d, compressed = %d, want 8 chunks and 6 compressed", chunks, compressed)
+	}
+	if l.LLen() != 64 || !bytes.Equal(l.LIndex(30), v) {
+		t.Errorf("LLen() = %d, want the elements kept", l.LLen())
+	}
+}
+
+func TestList_GetValueLayout(t *testing.T) {
+	// the layout of the serialized list is the same as the one of the previous node based list
+	l := NewLinkedListWithOptions(Options{ChunkSize: 2, CompressDepth: 1})
+	var want []byte
+	for i := 0; i < 10; i++ {
+		v := []byte(strconv.Itoa(i))
+		l.RPush(v)
+		want = binary.AppendVarint(want, int64(len(v)))
+		want = append(want, v...)
+	}
+	if got := l.GetValue(); !bytes.Equal(got, want) {
+		t.Errorf("GetValue() = %q, want %q", got, want)
+	}
+}
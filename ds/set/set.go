@@ -4,19 +4,123 @@ import (
 	"encoding/binary"
 	"math/rand"
 	"path/filepath"
+	"slices"
+	"strconv"
 
 	"github.com/diiyw/nodis/ds"
 )
 
+const (
+	EncodingIntset    = "intset"
+	EncodingHashtable = "hashtable"
+)
+
+// Set is a set of strings. A small set of integers is encoded as a sorted array of integers,
+// it is converted to a hashtable once it exceeds the limits of ds.EncodingLimits.
 type Set struct {
-	data map[string]struct{}
+	// data is nil while the set is encoded as an intset
+	data      map[string]struct{}
+	intset    []int64
+	changes   ds.Changes
+	encodings *ds.Encodings
 }
 
-// NewSet creates a new set
+// NewSet creates a new set with the default encoding limits
 func NewSet() *Set {
-	return &Set{
-		data: make(map[string]struct{}),
+	return &Set{}
+}
+
+// NewSetWithEncodings creates a new set encoded by the limits of the encodings
+func NewSetWithEncodings(e *ds.Encodings) *Set {
+	return &Set{encodings: e}
+}
+
+// SetEncodings sets the encodings of the set, they apply to the next members added
+func (s *Set) SetEncodings(e *ds.Encodings) {
+	s.encodings = e
+}
+
+// Changes returns the members added or removed
+func (s *Set) Changes() *ds.Changes {
+	return &s.changes
+}
+
+// Encoding returns the encoding of the set, intset or hashtable
+func (s *Set) Encoding() string {
+	if s.data == nil {
+		return EncodingIntset
+	}
+	return EncodingHashtable
+}
+
+// convert converts the intset to a hashtable
+func (s *Set) convert() {
+	s.data = make(map[string]struct{}, len(s.intset)+1)
+	for _, v := range s.intset {
+		s.data[strconv.FormatInt(v, 10)] = struct{}{}
+	}
+	s.intset = nil
+}
+
+// add adds the member and returns whether it is new
+func (s *Set) add(member string) bool {
+	s.changes.Add(member)
+	if s.data == nil {
+		if !ds.IsIntString(member) {
+			s.convert()
+		} else {
+			v, _ := strconv.ParseInt(member, 10, 64)
+			i, found := slices.BinarySearch(s.intset, v)
+			if found {
+				return false
+			}
+			if int64(len(s.intset)) < s.encodings.Limits().SetMaxIntsetEntries {
+				s.intset = slices.Insert(s.intset, i, v)
+				return true
+			}
+			s.convert()
+		}
+	}
+	if _, ok := s.data[member]; ok {
+		return false
+	}
+	s.data[member] = struct{}{}
+	return true
+}
+
+// has returns whether the member is in the set
+func (s *Set) has(member string) bool {
+	if s.data == nil {
+		if !ds.IsIntString(member) {
+			return false
+		}
+		v, _ := strconv.ParseInt(member, 10, 64)
+		_, found := slices.BinarySearch(s.intset, v)
+		return found
 	}
+	_, ok := s.data[member]
+	return ok
+}
+
+// remove removes the member and returns whether it was in the set
+func (s *Set) remove(member string) bool {
+	s.changes.Add(member)
+	if s.data == nil {
+		if !ds.IsIntString(member) {
+			return false
+		}
+		v, _ := strconv.ParseInt(member, 10, 64)
+		i, found := slices.BinarySearch(s.intset, v)
+		if found {
+			s.intset = slices.Delete(s.intset, i, i+1)
+		}
+		return found
+	}
+	if _, ok := s.data[member]; !ok {
+		return false
+	}
+	delete(s.data, member)
+	return true
 }
 
 // SAdd adds a member to the set
@@ -27,9 +131,7 @@ func (s *Set) SAdd(member ...string) int64 {
 func (s *Set) sAdd(member ...string) int64 {
 	n := 0
 	for _, m := range member {
-		_, updated := s.data[m]
-		s.data[m] = struct{}{}
-		if !updated {
+		if s.add(m) {
 			n++
 		}
 	}
@@ -38,16 +140,19 @@ func (s *Set) sAdd(member ...string) int64 {
 
 // SCard gets the set members count.
 func (s *Set) SCard() int64 {
+	if s.data == nil {
+		return int64(len(s.intset))
+	}
 	return int64(len(s.data))
 }
 
 // SDiff gets the difference between sets.
 func (s *Set) SDiff(sets ...*Set) []string {
 	diff := make([]string, 0, 32)
-	for member := range s.data {
+	s.Iter(func(member string) bool {
 		found := false
 		for _, set := range sets {
-			_, found = set.data[member]
+			found = set.has(member)
 			if found {
 				break
 			}
@@ -55,7 +160,8 @@ func (s *Set) SDiff(sets ...*Set) []string {
 		if !found {
 			diff = append(diff, member)
 		}
-	}
+		return true
+	})
 	return diff
 }
 
@@ -70,10 +176,10 @@ func (s *Set) SDiffStore(destination *Set, sets ...*Set) {
 // SInter gets the intersection between sets.
 func (s *Set) SInter(sets ...*Set) []string {
 	inter := make([]string, 0, 32)
-	for member := range s.data {
+	s.Iter(func(member string) bool {
 		found := true
 		for _, set := range sets {
-			_, found = set.data[member]
+			found = set.has(member)
 			if !found {
 				break
 			}
@@ -81,7 +187,8 @@ func (s *Set) SInter(sets ...*Set) []string {
 		if found {
 			inter = append(inter, member)
 		}
-	}
+		return true
+	})
 	return inter
 }
 
@@ -95,26 +202,24 @@ func (s *Set) SInterStore(destination *Set, sets ...*Set) {
 
 // SMembers gets the set members.
 func (s *Set) SMembers() []string {
-	members := make([]string, 0, len(s.data))
-	for member := range s.data {
+	members := make([]string, 0, s.SCard())
+	s.Iter(func(member string) bool {
 		members = append(members, member)
-	}
+		return true
+	})
 	return members
 }
 
 // SIsMember checks if a member is in the set.
 func (s *Set) SIsMember(member string) bool {
-	_, ok := s.data[member]
-	return ok
+	return s.has(member)
 }
 
 // SRem removes a member from the set.
 func (s *Set) SRem(member ...string) int64 {
 	var removed int64 = 0
 	for _, m := range member {
-		_, ok := s.data[m]
-		if ok {
-			delete(s.data, m)
+		if s.remove(m) {
 			removed++
 		}
 	}
@@ -126,13 +231,23 @@ func (s *Set) SPop(count int64) []string {
 	if count <= 0 {
 		return nil
 	}
-	if count > int64(len(s.data)) {
-		count = int64(len(s.data))
+	if count > s.SCard() {
+		count = s.SCard()
 	}
 	members := make([]string, 0, count)
+	if s.data == nil {
+		for _, i := range rand.Perm(len(s.intset))[:count] {
+			members = append(members, strconv.FormatInt(s.intset[i], 10))
+		}
+		for _, member := range members {
+			s.remove(member)
+		}
+		return members
+	}
 	for member := range s.data {
 		if count > 0 {
 			delete(s.data, member)
+			s.changes.Add(member)
 			members = append(members, member)
 			count--
 		}
@@ -144,11 +259,12 @@ func (s *Set) SPop(count int64) []string {
 func (s *Set) SUnion(sets ...*Set) []string {
 	union := s.SMembers()
 	for _, set := range sets {
-		for member := range set.data {
-			if _, ok := s.data[member]; !ok {
+		set.Iter(func(member string) bool {
+			if !s.has(member) {
 				union = append(union, member)
 			}
-		}
+			return true
+		})
 	}
 	return union
 }
@@ -164,19 +280,18 @@ func (s *Set) SUnionStore(destination *Set, sets ...*Set) {
 // SScan scans the set members.
 func (s *Set) SScan(cursor int64, match string, count int64) (int64, []string) {
 	keys := make([]string, 0, 32)
-	if cursor >= int64(len(s.data)) {
+	if cursor >= s.SCard() {
 		return 0, nil
 	}
-	var i int64 = 0
-	for member := range s.data {
+	s.Iter(func(member string) bool {
 		if count > 0 && int64(len(keys)) >= count {
-			break
+			return false
 		}
 		if matched, err := filepath.Match(match, member); matched && err == nil {
 			keys = append(keys, member)
 		}
-		i++
-	}
+		return true
+	})
 	return cursor, keys
 }
 
@@ -189,35 +304,22 @@ func (s *Set) SRandMember(count int64) []string {
 	if count < 0 {
 		unique = false
 	}
-	var kl = len(s.data)
+	var kl = int(s.SCard())
 	if count > 0 && count > int64(kl) {
 		count = int64(kl)
 	}
 	members := make([]string, 0)
+	if kl == 0 {
+		return members
+	}
+	all := s.SMembers()
 	if unique {
-		var keys = make(map[string]bool)
-		for key := range s.data {
-			keys[key] = true
-		}
-		for m := range keys {
-			if count == 0 {
-				break
-			}
-			members = append(members, m)
-			count--
+		for _, i := range rand.Perm(kl)[:count] {
+			members = append(members, all[i])
 		}
 	} else {
-		for count < 0 {
-			index := rand.Intn(kl)
-			var i int
-			for key := range s.data {
-				if i == index {
-					members = append(members, key)
-					break
-				}
-				i++
-			}
-			count++
+		for ; count < 0; count++ {
+			members = append(members, all[rand.Intn(kl)])
 		}
 	}
 	return members
@@ -225,11 +327,26 @@ func (s *Set) SRandMember(count int64) []string {
 
 // SClear clears the set.
 func (s *Set) SClear() {
-	s.data = make(map[string]struct{})
+	if s.changes.Fields() != nil {
+		s.Iter(func(member string) bool {
+			s.changes.Add(member)
+			return true
+		})
+	}
+	s.data = nil
+	s.intset = nil
 }
 
 // Iter returns an iterator for the set.
 func (s *Set) Iter(fn func(member string) bool) {
+	if s.data == nil {
+		for _, v := range s.intset {
+			if !fn(strconv.FormatInt(v, 10)) {
+				break
+			}
+		}
+		return
+	}
 	for member := range s.data {
 		if !fn(member) {
 			break
@@ -244,14 +361,12 @@ func (s *Set) Type() ds.ValueType {
 
 // GetValue the string to bytes
 func (s *Set) GetValue() []byte {
-	var members = make([]byte, 0, len(s.data))
-	for member := range s.data {
-		mLen := len(member)
-		var b = make([]byte, mLen+1)
-		n := binary.PutVarint(b, int64(mLen))
-		copy(b[n:], member)
-		members = append(members, b...)
-	}
+	var members = make([]byte, 0, s.SCard())
+	s.Iter(func(member string) bool {
+		members = binary.AppendVarint(members, int64(len(member)))
+		members = append(members, member...)
+		return true
+	})
 	return members
 }
 
@@ -265,6 +380,6 @@ func (s *Set) SetValue(members []byte) {
 		members = members[n:]
 		member := string(members[:mLen])
 		members = members[mLen:]
-		s.data[member] = struct{}{}
+		s.add(member)
 	}
 }
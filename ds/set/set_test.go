@@ -3,6 +3,8 @@ package set
 import (
 	"fmt"
 	"testing"
+
+	"github.com/diiyw/nodis/ds"
 )
 
 func TestSet_SAdd(t *testing.T) {
@@ -140,3 +142,59 @@ func TestGetSetValue(t *testing.T) {
 		fmt.Println(s3)
 	}
 }
+
+func TestSet_Encoding(t *testing.T) {
+	e := ds.NewEncodings(ds.DefaultEncodingLimits)
+	e.SetLimit("set-max-intset-entries", 3)
+
+	s := NewSetWithEncodings(e)
+	s.SAdd("3", "1", "2", "2")
+	if s.Encoding() != EncodingIntset || s.SCard() != 3 || !s.SIsMember("2") || s.SIsMember("02") {
+		t.Errorf("intset = %s %v", s.Encoding(), s.SMembers())
+	}
+	if members := s.SMembers(); fmt.Sprint(members) != "[1 2 3]" {
+		t.Errorf("SMembers() = %v, want [1 2 3]", members)
+	}
+	s.SAdd("4")
+	if s.Encoding() != EncodingHashtable || s.SCard() != 4 || !s.SIsMember("4") {
+		t.Errorf("SAdd() over the limit = %s %v", s.Encoding(), s.SMembers())
+	}
+
+	s = NewSet()
+	s.SAdd("1", "a")
+	if s.Encoding() != EncodingHashtable || !s.SIsMember("1") || !s.SIsMember("a") {
+		t.Errorf("SAdd() non integer = %s %v", s.Encoding(), s.SMembers())
+	}
+
+	s = NewSet()
+	s.SAdd("1", "-2", "3")
+	if got := s.SRem("-2", "x"); got != 1 || s.Encoding() != EncodingIntset {
+		t.Errorf("SRem() = %d %s", got, s.Encoding())
+	}
+	s2 := NewSet()
+	s2.SetValue(s.GetValue())
+	if s2.Encoding() != EncodingIntset || fmt.Sprint(s2.SMembers()) != "[1 3]" {
+		t.Errorf("SetValue() = %s %v", s2.Encoding(), s2.SMembers())
+	}
+	if popped := s2.SPop(5); len(popped) != 2 || s2.SCard() != 0 {
+		t.Errorf("SPop() = %v", popped)
+	}
+}
+
+func TestSet_Changes(t *testing.T) {
+	s := NewSet()
+	s.SAdd("1", "2", "3")
+	s.Changes().Track("key")
+	s.SAdd("4")
+	s.SRem("1")
+	s.SIsMember("2")
+	if fields := s.Changes().Fields(); len(fields) != 2 {
+		t.Errorf("Changes() = %v, want [1 4]", fields)
+	}
+	s.Changes().Reset()
+	s.SPop(1)
+	s.SClear()
+	if fields := s.Changes().Fields(); len(fields) != 3 {
+		t.Errorf("Changes() = %v, want the members removed", fields)
+	}
+}
@@ -1,6 +1,7 @@
 package str
 
 import (
+	"errors"
 	"strconv"
 	"unsafe"
 
@@ -242,3 +243,79 @@ func (s *String) GetValue() []byte {
 func (s *String) SetValue(data []byte) {
 	s.V = data
 }
+
+// MaxLCSTableSize is the max size in bytes of the table used by LCS, it's the default proto-max-bulk-len of Redis
+const MaxLCSTableSize = 512 << 20
+
+// ErrLCSTooLarge is returned by LCS when the strings need a table larger than MaxLCSTableSize
+var ErrLCSTooLarge = errors.New("insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+
+// LCSMatch is a substring shared by the two strings of LCS,
+// A and B are its inclusive ranges in the first and the second string
+type LCSMatch struct {
+	A   [2]int64
+	B   [2]int64
+	Len int64
+}
+
+// LCS returns the longest common subsequence of a and b and the matches composing it, from the
+// last one to the first one like Redis. The matches shorter than minMatchLen are skipped.
+func LCS(a, b []byte, minMatchLen int64) ([]byte, []LCSMatch, error) {
+	aLen, bLen := len(a), len(b)
+	if uint64(aLen+1)*uint64(bLen+1)*4 > MaxLCSTableSize {
+		return nil, nil, ErrLCSTooLarge
+	}
+	// table[i*(bLen+1)+j] is the length of the LCS of a[:i] and b[:j]
+	table := make([]uint32, (aLen+1)*(bLen+1))
+	lcs := func(i, j int) uint32 {
+		return table[i*(bLen+1)+j]
+	}
+	for i := 1; i <= aLen; i++ {
+		for j := 1; j <= bLen; j++ {
+			if a[i-1] == b[j-1] {
+				table[i*(bLen+1)+j] = lcs(i-1, j-1) + 1
+			} else {
+				table[i*(bLen+1)+j] = max(lcs(i-1, j), lcs(i, j-1))
+			}
+		}
+	}
+
+	// walk the table backward to rebuild the subsequence and its matches
+	idx := lcs(aLen, bLen)
+	result := make([]byte, idx)
+	var matches []LCSMatch
+	var match *LCSMatch
+	for i, j := aLen, bLen; i > 0 && j > 0; {
+		emit := false
+		if a[i-1] == b[j-1] {
+			result[idx-1] = a[i-1]
+			if match == nil {
+				match = &LCSMatch{A: [2]int64{int64(i - 1), int64(i - 1)}, B: [2]int64{int64(j - 1), int64(j - 1)}}
+			} else {
+				// the match is contiguous, extend it backward
+				match.A[0]--
+				match.B[0]--
+			}
+			// emit the match if it starts one of the strings, the walk ends
+			emit = match.A[0] == 0 || match.B[0] == 0
+			idx--
+			i--
+			j--
+		} else {
+			if lcs(i-1, j) > lcs(i, j-1) {
+				i--
+			} else {
+				j--
+			}
+			emit = match != nil
+		}
+		if emit {
+			match.Len = match.A[1] - match.A[0] + 1
+			if match.Len >= minMatchLen {
+				matches = append(matches, *match)
+			}
+			match = nil
+		}
+	}
+	return result, matches, nil
+}
@@ -73,3 +73,26 @@ func TestSet_Decr(t *testing.T) {
 		t.Errorf("expected -1, got %d", v)
 	}
 }
+
+func TestLCS(t *testing.T) {
+	v, matches, err := LCS([]byte("ohmytext"), []byte("mynewtext"), 0)
+	if err != nil || string(v) != "mytext" {
+		t.Fatalf("LCS() = %s, %v", v, err)
+	}
+	want := []LCSMatch{
+		{A: [2]int64{4, 7}, B: [2]int64{5, 8}, Len: 4},
+		{A: [2]int64{2, 3}, B: [2]int64{0, 1}, Len: 2},
+	}
+	if len(matches) != len(want) || matches[0] != want[0] || matches[1] != want[1] {
+		t.Errorf("LCS() matches = %v, want %v", matches, want)
+	}
+	if _, matches, _ = LCS([]byte("ohmytext"), []byte("mynewtext"), 4); len(matches) != 1 || matches[0] != want[0] {
+		t.Errorf("LCS() with min match len = %v, want %v", matches, want[:1])
+	}
+	if v, matches, _ = LCS(nil, []byte("abc"), 0); len(v) != 0 || len(matches) != 0 {
+		t.Errorf("LCS() of empty string = %s %v", v, matches)
+	}
+	if _, _, err = LCS(make([]byte, 20000), make([]byte, 20000), 0); err != ErrLCSTooLarge {
+		t.Errorf("LCS() error = %v, want %v", err, ErrLCSTooLarge)
+	}
+}
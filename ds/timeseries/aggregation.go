@@ -0,0 +1,155 @@
+package timeseries
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+var (
+	ErrUnknownAggregator = errors.New("ERR TSDB: Unknown aggregation type")
+)
+
+// Aggregator is the function applied to the samples of a bucket
+type Aggregator uint8
+
+const (
+	AggNone Aggregator = iota
+	AggAvg
+	AggSum
+	AggMin
+	AggMax
+	AggCount
+	AggFirst
+	AggLast
+	AggRange
+)
+
+// ParseAggregator parses an aggregation type name like "avg"
+func ParseAggregator(s string) (Aggregator, error) {
+	switch strings.ToLower(s) {
+	case "avg":
+		return AggAvg, nil
+	case "sum":
+		return AggSum, nil
+	case "min":
+		return AggMin, nil
+	case "max":
+		return AggMax, nil
+	case "count":
+		return AggCount, nil
+	case "first":
+		return AggFirst, nil
+	case "last":
+		return AggLast, nil
+	case "range":
+		return AggRange, nil
+	}
+	return AggNone, ErrUnknownAggregator
+}
+
+func (a Aggregator) String() string {
+	switch a {
+	case AggAvg:
+		return "avg"
+	case AggSum:
+		return "sum"
+	case AggMin:
+		return "min"
+	case AggMax:
+		return "max"
+	case AggCount:
+		return "count"
+	case AggFirst:
+		return "first"
+	case AggLast:
+		return "last"
+	case AggRange:
+		return "range"
+	}
+	return "none"
+}
+
+// Aggregation groups samples into buckets of BucketDuration milliseconds
+type Aggregation struct {
+	Type           Aggregator
+	BucketDuration int64
+}
+
+// bucketStart returns the start of the bucket the timestamp belongs to
+func (a *Aggregation) bucketStart(timestamp int64) int64 {
+	if a.BucketDuration <= 0 {
+		return timestamp
+	}
+	mod := timestamp % a.BucketDuration
+	if mod < 0 {
+		mod += a.BucketDuration
+	}
+	return timestamp - mod
+}
+
+// aggState accumulates the samples of a bucket
+type aggState struct {
+	count int64
+	sum   float64
+	min   float64
+	max   float64
+	first float64
+	last  float64
+}
+
+func (s *aggState) add(v float64) {
+	if s.count == 0 {
+		s.min, s.max, s.first = v, v, v
+	}
+	s.count++
+	s.sum += v
+	s.min = math.Min(s.min, v)
+	s.max = math.Max(s.max, v)
+	s.last = v
+}
+
+func (s *aggState) value(a Aggregator) float64 {
+	switch a {
+	case AggAvg:
+		if s.count == 0 {
+			return 0
+		}
+		return s.sum / float64(s.count)
+	case AggSum:
+		return s.sum
+	case AggMin:
+		return s.min
+	case AggMax:
+		return s.max
+	case AggCount:
+		return float64(s.count)
+	case AggFirst:
+		return s.first
+	case AggLast:
+		return s.last
+	case AggRange:
+		return s.max - s.min
+	}
+	return 0
+}
+
+// aggregate groups ordered samples into buckets
+func aggregate(samples []Sample, a *Aggregation) []Sample {
+	result := make([]Sample, 0)
+	var state aggState
+	var bucket int64
+	for i, s := range samples {
+		start := a.bucketStart(s.Timestamp)
+		if i > 0 && start != bucket {
+			result = append(result, Sample{Timestamp: bucket, Value: state.value(a.Type)})
+			state = aggState{}
+		}
+		bucket = start
+		state.add(s.Value)
+	}
+	if state.count > 0 {
+		result = append(result, Sample{Timestamp: bucket, Value: state.value(a.Type)})
+	}
+	return result
+}
@@ -0,0 +1,306 @@
+package timeseries
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	// defaultChunkSize is the size in bytes at which a chunk is sealed
+	defaultChunkSize = 4096
+)
+
+// bstream is an append only bit stream
+type bstream struct {
+	stream []byte
+	count  uint8 // how many right-most bits are available for writing in the current byte
+}
+
+func (b *bstream) writeBit(bit bool) {
+	if b.count == 0 {
+		b.stream = append(b.stream, 0)
+		b.count = 8
+	}
+	if bit {
+		b.stream[len(b.stream)-1] |= 1 << (b.count - 1)
+	}
+	b.count--
+}
+
+func (b *bstream) writeByte(byt byte) {
+	if b.count == 0 {
+		b.stream = append(b.stream, byt)
+		return
+	}
+	b.stream[len(b.stream)-1] |= byt >> (8 - b.count)
+	b.stream = append(b.stream, byt<<b.count)
+}
+
+func (b *bstream) writeBits(u uint64, nbits int) {
+	u <<= 64 - uint(nbits)
+	for nbits >= 8 {
+		b.writeByte(byte(u >> 56))
+		u <<= 8
+		nbits -= 8
+	}
+	for nbits > 0 {
+		b.writeBit((u >> 63) == 1)
+		u <<= 1
+		nbits--
+	}
+}
+
+// bstreamReader reads bits from a bstream
+type bstreamReader struct {
+	stream []byte
+	pos    int // position in bits
+}
+
+func (r *bstreamReader) readBit() (bool, bool) {
+	if r.pos >= len(r.stream)*8 {
+		return false, false
+	}
+	bit := r.stream[r.pos/8]&(0x80>>(r.pos%8)) != 0
+	r.pos++
+	return bit, true
+}
+
+func (r *bstreamReader) readBits(nbits int) (uint64, bool) {
+	var u uint64
+	for i := 0; i < nbits; i++ {
+		bit, ok := r.readBit()
+		if !ok {
+			return 0, false
+		}
+		u <<= 1
+		if bit {
+			u |= 1
+		}
+	}
+	return u, true
+}
+
+// chunk holds samples compressed with the gorilla encoding:
+// delta-of-delta timestamps and XOR'ed float values.
+type chunk struct {
+	b     bstream
+	count int
+	first int64
+	last  int64
+
+	// appender state, only valid when appendable is true
+	appendable bool
+	t          int64
+	tDelta     int64
+	v          float64
+	leading    uint8
+	trailing   uint8
+}
+
+func newChunk() *chunk {
+	return &chunk{appendable: true, leading: 0xff}
+}
+
+// chunkFrom builds a chunk from the given ordered samples
+func chunkFrom(samples []Sample) *chunk {
+	c := newChunk()
+	for _, s := range samples {
+		c.append(s.Timestamp, s.Value)
+	}
+	return c
+}
+
+func (c *chunk) size() int {
+	return len(c.b.stream)
+}
+
+// append adds a sample to the end of the chunk.
+// The timestamp must be greater than the last one.
+func (c *chunk) append(t int64, v float64) {
+	if !c.appendable {
+		c.restore()
+	}
+	switch c.count {
+	case 0:
+		c.b.writeBits(uint64(t), 64)
+		c.b.writeBits(math.Float64bits(v), 64)
+		c.first = t
+	default:
+		tDelta := t - c.t
+		c.writeDod(tDelta - c.tDelta)
+		c.writeXOR(v)
+		c.tDelta = tDelta
+	}
+	c.t = t
+	c.v = v
+	c.last = t
+	c.count++
+}
+
+// restore rebuilds the appender state of a chunk decoded from storage
+func (c *chunk) restore() {
+	samples := c.samples()
+	*c = *newChunk()
+	for _, s := range samples {
+		c.append(s.Timestamp, s.Value)
+	}
+}
+
+func (c *chunk) writeDod(dod int64) {
+	switch {
+	case dod == 0:
+		c.b.writeBit(false)
+	case bitRange(dod, 7):
+		c.b.writeBits(0b10, 2)
+		c.b.writeBits(uint64(dod), 7)
+	case bitRange(dod, 9):
+		c.b.writeBits(0b110, 3)
+		c.b.writeBits(uint64(dod), 9)
+	case bitRange(dod, 12):
+		c.b.writeBits(0b1110, 4)
+		c.b.writeBits(uint64(dod), 12)
+	default:
+		c.b.writeBits(0b1111, 4)
+		c.b.writeBits(uint64(dod), 64)
+	}
+}
+
+func (c *chunk) writeXOR(v float64) {
+	delta := math.Float64bits(v) ^ math.Float64bits(c.v)
+	if delta == 0 {
+		c.b.writeBit(false)
+		return
+	}
+	c.b.writeBit(true)
+	leading := uint8(bits.LeadingZeros64(delta))
+	trailing := uint8(bits.TrailingZeros64(delta))
+	// clamp number of leading zeros to fit in 5 bits
+	if leading >= 32 {
+		leading = 31
+	}
+	if c.leading != 0xff && leading >= c.leading && trailing >= c.trailing {
+		c.b.writeBit(false)
+		c.b.writeBits(delta>>c.trailing, 64-int(c.leading)-int(c.trailing))
+		return
+	}
+	c.leading, c.trailing = leading, trailing
+	c.b.writeBit(true)
+	c.b.writeBits(uint64(leading), 5)
+	// 64 significant bits are stored as 0
+	sigbits := 64 - leading - trailing
+	c.b.writeBits(uint64(sigbits), 6)
+	c.b.writeBits(delta>>trailing, int(sigbits))
+}
+
+// bitRange returns whether x can be represented with nbits
+func bitRange(x int64, nbits uint8) bool {
+	return -((1<<(nbits-1))-1) <= x && x <= 1<<(nbits-1)
+}
+
+// samples decodes all the samples of the chunk
+func (c *chunk) samples() []Sample {
+	samples := make([]Sample, 0, c.count)
+	r := &bstreamReader{stream: c.b.stream}
+	var t, tDelta int64
+	var v uint64
+	var leading, trailing uint8
+	for i := 0; i < c.count; i++ {
+		if i == 0 {
+			tb, ok := r.readBits(64)
+			if !ok {
+				break
+			}
+			vb, ok := r.readBits(64)
+			if !ok {
+				break
+			}
+			t, v = int64(tb), vb
+			samples = append(samples, Sample{Timestamp: t, Value: math.Float64frombits(v)})
+			continue
+		}
+		dod, ok := readDod(r)
+		if !ok {
+			break
+		}
+		tDelta += dod
+		t += tDelta
+		if !readXOR(r, &v, &leading, &trailing) {
+			break
+		}
+		samples = append(samples, Sample{Timestamp: t, Value: math.Float64frombits(v)})
+	}
+	return samples
+}
+
+func readDod(r *bstreamReader) (int64, bool) {
+	var prefix int
+	for prefix < 4 {
+		bit, ok := r.readBit()
+		if !ok {
+			return 0, false
+		}
+		if !bit {
+			break
+		}
+		prefix++
+	}
+	var sz int
+	switch prefix {
+	case 0:
+		return 0, true
+	case 1:
+		sz = 7
+	case 2:
+		sz = 9
+	case 3:
+		sz = 12
+	default:
+		u, ok := r.readBits(64)
+		return int64(u), ok
+	}
+	u, ok := r.readBits(sz)
+	if !ok {
+		return 0, false
+	}
+	dod := int64(u)
+	if dod > 1<<(sz-1) {
+		dod -= 1 << sz
+	}
+	return dod, true
+}
+
+func readXOR(r *bstreamReader, v *uint64, leading, trailing *uint8) bool {
+	bit, ok := r.readBit()
+	if !ok {
+		return false
+	}
+	if !bit {
+		return true
+	}
+	bit, ok = r.readBit()
+	if !ok {
+		return false
+	}
+	if bit {
+		l, ok := r.readBits(5)
+		if !ok {
+			return false
+		}
+		sigbits, ok := r.readBits(6)
+		if !ok {
+			return false
+		}
+		if sigbits == 0 {
+			sigbits = 64
+		}
+		*leading = uint8(l)
+		*trailing = 64 - *leading - uint8(sigbits)
+	}
+	sigbits := 64 - int(*leading) - int(*trailing)
+	u, ok := r.readBits(sigbits)
+	if !ok {
+		return false
+	}
+	*v ^= u << *trailing
+	return true
+}
@@ -0,0 +1,523 @@
+package timeseries
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/internal/encoding"
+)
+
+var (
+	ErrDuplicateBlocked  = errors.New("ERR TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode")
+	ErrTimestampTooOld   = errors.New("ERR TSDB: Timestamp is older than retention")
+	ErrTimestampNotLast  = errors.New("ERR TSDB: timestamp must be equal to or higher than the maximum existing timestamp")
+	ErrRuleExists        = errors.New("ERR TSDB: the destination key already has a src rule")
+	ErrRuleChain         = errors.New("ERR TSDB: the destination key already has a dst rule")
+	ErrUnknownDuplicate  = errors.New("ERR TSDB: Unknown DUPLICATE_POLICY")
+	ErrInvalidFilter     = errors.New("ERR TSDB: failed parsing labels")
+	ErrBucketDurationNeg = errors.New("ERR TSDB: bucketDuration must be greater than zero")
+)
+
+// Sample is a timestamp-value pair, timestamps are in milliseconds
+type Sample struct {
+	Timestamp int64
+	Value     float64
+}
+
+// Label is a name-value pair attached to a time series
+type Label struct {
+	Name  string
+	Value string
+}
+
+// DuplicatePolicy decides what happens when a sample is added
+// with a timestamp that already exists.
+type DuplicatePolicy uint8
+
+const (
+	DuplicateNone DuplicatePolicy = iota
+	DuplicateBlock
+	DuplicateFirst
+	DuplicateLast
+	DuplicateMin
+	DuplicateMax
+	DuplicateSum
+)
+
+// ParseDuplicatePolicy parses a policy name like "last"
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch strings.ToLower(s) {
+	case "block":
+		return DuplicateBlock, nil
+	case "first":
+		return DuplicateFirst, nil
+	case "last":
+		return DuplicateLast, nil
+	case "min":
+		return DuplicateMin, nil
+	case "max":
+		return DuplicateMax, nil
+	case "sum":
+		return DuplicateSum, nil
+	}
+	return DuplicateNone, ErrUnknownDuplicate
+}
+
+func (p DuplicatePolicy) String() string {
+	switch p {
+	case DuplicateBlock:
+		return "block"
+	case DuplicateFirst:
+		return "first"
+	case DuplicateLast:
+		return "last"
+	case DuplicateMin:
+		return "min"
+	case DuplicateMax:
+		return "max"
+	case DuplicateSum:
+		return "sum"
+	}
+	return "none"
+}
+
+// Rule is a compaction rule which downsamples the series into another key
+type Rule struct {
+	Key         string
+	Aggregation Aggregation
+
+	// current bucket state
+	hasBucket bool
+	bucket    int64
+	state     aggState
+}
+
+// Compaction is a downsampled sample that must be added to the rule key
+type Compaction struct {
+	Key    string
+	Sample Sample
+}
+
+// TimeSeries is a series of samples stored in gorilla compressed chunks
+type TimeSeries struct {
+	retention       int64
+	duplicatePolicy DuplicatePolicy
+	labels          []Label
+	chunks          []*chunk
+	rules           []*Rule
+	source          string
+}
+
+// NewTimeSeries creates a new time series
+func NewTimeSeries() *TimeSeries {
+	return &TimeSeries{
+		labels: make([]Label, 0),
+		chunks: make([]*chunk, 0),
+		rules:  make([]*Rule, 0),
+	}
+}
+
+// Type returns the type of the data structure
+func (t *TimeSeries) Type() ds.ValueType {
+	return ds.TimeSeries
+}
+
+// Retention returns the maximum age of samples in milliseconds, 0 means forever
+func (t *TimeSeries) Retention() int64 {
+	return t.retention
+}
+
+// SetRetention sets the maximum age of samples in milliseconds
+func (t *TimeSeries) SetRetention(retention int64) {
+	t.retention = retention
+	t.trim()
+}
+
+// DuplicatePolicy returns the duplicate policy of the series
+func (t *TimeSeries) DuplicatePolicy() DuplicatePolicy {
+	return t.duplicatePolicy
+}
+
+// SetDuplicatePolicy sets the duplicate policy of the series
+func (t *TimeSeries) SetDuplicatePolicy(policy DuplicatePolicy) {
+	t.duplicatePolicy = policy
+}
+
+// Labels returns the labels of the series
+func (t *TimeSeries) Labels() []Label {
+	return t.labels
+}
+
+// SetLabels replaces the labels of the series
+func (t *TimeSeries) SetLabels(labels []Label) {
+	t.labels = append(make([]Label, 0, len(labels)), labels...)
+}
+
+// Label returns the value of the label and whether it exists
+func (t *TimeSeries) Label(name string) (string, bool) {
+	for _, l := range t.labels {
+		if l.Name == name {
+			return l.Value, true
+		}
+	}
+	return "", false
+}
+
+// Source returns the key which compacts into this series
+func (t *TimeSeries) Source() string {
+	return t.source
+}
+
+// SetSource sets the key which compacts into this series
+func (t *TimeSeries) SetSource(key string) {
+	t.source = key
+}
+
+// ChunkCount returns the number of chunks
+func (t *TimeSeries) ChunkCount() int64 {
+	return int64(len(t.chunks))
+}
+
+// Len returns the number of samples
+func (t *TimeSeries) Len() int64 {
+	var n int64
+	for _, c := range t.chunks {
+		n += int64(c.count)
+	}
+	return n
+}
+
+// First returns the first sample, nil if the series is empty
+func (t *TimeSeries) First() *Sample {
+	if len(t.chunks) == 0 {
+		return nil
+	}
+	samples := t.chunks[0].samples()
+	if len(samples) == 0 {
+		return nil
+	}
+	return &samples[0]
+}
+
+// Get returns the last sample, nil if the series is empty
+func (t *TimeSeries) Get() *Sample {
+	if len(t.chunks) == 0 {
+		return nil
+	}
+	c := t.chunks[len(t.chunks)-1]
+	if c.appendable {
+		return &Sample{Timestamp: c.t, Value: c.v}
+	}
+	samples := c.samples()
+	if len(samples) == 0 {
+		return nil
+	}
+	return &samples[len(samples)-1]
+}
+
+// Add adds a sample to the series. policy overrides the series duplicate policy
+// when it isn't DuplicateNone. The returned compactions must be added to the rule keys.
+func (t *TimeSeries) Add(timestamp int64, value float64, policy DuplicatePolicy) ([]Compaction, error) {
+	last := t.Get()
+	if last == nil || timestamp > last.Timestamp {
+		t.append(timestamp, value)
+		compactions := t.compact(timestamp, value)
+		t.trim()
+		return compactions, nil
+	}
+	if t.retention > 0 && timestamp < last.Timestamp-t.retention {
+		return nil, ErrTimestampTooOld
+	}
+	if policy == DuplicateNone {
+		policy = t.duplicatePolicy
+	}
+	return nil, t.upsert(timestamp, value, policy)
+}
+
+// IncrBy increases the value of the last sample by delta, the timestamp must
+// be equal to or higher than the last timestamp.
+func (t *TimeSeries) IncrBy(timestamp int64, delta float64) (float64, []Compaction, error) {
+	last := t.Get()
+	if last == nil {
+		compactions, err := t.Add(timestamp, delta, DuplicateLast)
+		return delta, compactions, err
+	}
+	if timestamp < last.Timestamp {
+		return 0, nil, ErrTimestampNotLast
+	}
+	value := last.Value + delta
+	compactions, err := t.Add(timestamp, value, DuplicateLast)
+	return value, compactions, err
+}
+
+func (t *TimeSeries) append(timestamp int64, value float64) {
+	if len(t.chunks) == 0 || t.chunks[len(t.chunks)-1].size() >= defaultChunkSize {
+		t.chunks = append(t.chunks, newChunk())
+	}
+	t.chunks[len(t.chunks)-1].append(timestamp, value)
+}
+
+// upsert inserts or updates a sample which isn't after the last one
+func (t *TimeSeries) upsert(timestamp int64, value float64, policy DuplicatePolicy) error {
+	i := sort.Search(len(t.chunks), func(i int) bool {
+		return t.chunks[i].first > timestamp
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+	samples := t.chunks[i].samples()
+	j := sort.Search(len(samples), func(j int) bool {
+		return samples[j].Timestamp >= timestamp
+	})
+	if j < len(samples) && samples[j].Timestamp == timestamp {
+		old := samples[j].Value
+		switch policy {
+		case DuplicateFirst:
+			return nil
+		case DuplicateLast:
+			samples[j].Value = value
+		case DuplicateMin:
+			samples[j].Value = math.Min(old, value)
+		case DuplicateMax:
+			samples[j].Value = math.Max(old, value)
+		case DuplicateSum:
+			samples[j].Value = old + value
+		default:
+			return ErrDuplicateBlocked
+		}
+	} else {
+		samples = slices.Insert(samples, j, Sample{Timestamp: timestamp, Value: value})
+	}
+	t.chunks[i] = chunkFrom(samples)
+	return nil
+}
+
+// compact feeds a new sample to the compaction rules
+func (t *TimeSeries) compact(timestamp int64, value float64) []Compaction {
+	var compactions []Compaction
+	for _, r := range t.rules {
+		start := r.Aggregation.bucketStart(timestamp)
+		if r.hasBucket && start < r.bucket {
+			continue
+		}
+		if r.hasBucket && start != r.bucket {
+			compactions = append(compactions, Compaction{
+				Key:    r.Key,
+				Sample: Sample{Timestamp: r.bucket, Value: r.state.value(r.Aggregation.Type)},
+			})
+			r.state = aggState{}
+		}
+		r.hasBucket = true
+		r.bucket = start
+		r.state.add(value)
+	}
+	return compactions
+}
+
+// trim removes the samples older than the retention
+func (t *TimeSeries) trim() {
+	last := t.Get()
+	if t.retention <= 0 || last == nil {
+		return
+	}
+	minTimestamp := last.Timestamp - t.retention
+	for len(t.chunks) > 0 && t.chunks[0].last < minTimestamp {
+		t.chunks = t.chunks[1:]
+	}
+	if len(t.chunks) == 0 || t.chunks[0].first >= minTimestamp {
+		return
+	}
+	samples := t.chunks[0].samples()
+	i := sort.Search(len(samples), func(i int) bool {
+		return samples[i].Timestamp >= minTimestamp
+	})
+	t.chunks[0] = chunkFrom(samples[i:])
+}
+
+// Range returns the samples within [from, to], aggregated when aggregation is not nil.
+// A positive count limits the number of returned samples.
+func (t *TimeSeries) Range(from, to int64, count int64, aggregation *Aggregation, rev bool) []Sample {
+	samples := make([]Sample, 0)
+	for _, c := range t.chunks {
+		if c.last < from || c.first > to {
+			continue
+		}
+		for _, s := range c.samples() {
+			if s.Timestamp >= from && s.Timestamp <= to {
+				samples = append(samples, s)
+			}
+		}
+	}
+	if aggregation != nil && aggregation.Type != AggNone {
+		samples = aggregate(samples, aggregation)
+	}
+	if rev {
+		slices.Reverse(samples)
+	}
+	if count > 0 && int64(len(samples)) > count {
+		samples = samples[:count]
+	}
+	return samples
+}
+
+// Rules returns the compaction rules of the series
+func (t *TimeSeries) Rules() []*Rule {
+	return t.rules
+}
+
+// AddRule adds a compaction rule which downsamples the series into key
+func (t *TimeSeries) AddRule(key string, aggregation Aggregation) error {
+	if aggregation.BucketDuration <= 0 {
+		return ErrBucketDurationNeg
+	}
+	for _, r := range t.rules {
+		if r.Key == key {
+			return ErrRuleExists
+		}
+	}
+	t.rules = append(t.rules, &Rule{Key: key, Aggregation: aggregation})
+	return nil
+}
+
+// DeleteRule removes the compaction rule of key
+func (t *TimeSeries) DeleteRule(key string) bool {
+	for i, r := range t.rules {
+		if r.Key == key {
+			t.rules = append(t.rules[:i], t.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Filter matches the labels of a series,
+// it is parsed from label=value, label!=value, label= , label!= , label=(v1,v2) or label!=(v1,v2)
+type Filter struct {
+	Label  string
+	Values []string
+	Not    bool
+}
+
+// ParseFilter parses a label filter expression
+func ParseFilter(s string) (Filter, error) {
+	var f Filter
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return f, ErrInvalidFilter
+	}
+	f.Label = s[:i]
+	if strings.HasSuffix(f.Label, "!") {
+		f.Not = true
+		f.Label = f.Label[:len(f.Label)-1]
+	}
+	if f.Label == "" {
+		return f, ErrInvalidFilter
+	}
+	value := s[i+1:]
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		f.Values = strings.Split(value[1:len(value)-1], ",")
+	} else {
+		f.Values = []string{value}
+	}
+	return f, nil
+}
+
+// Positive returns whether the filter requires the label to have a value
+func (f Filter) Positive() bool {
+	return !f.Not && !slices.Contains(f.Values, "")
+}
+
+// Match returns whether the labels satisfy the filter, a missing label has an empty value
+func (t *TimeSeries) Match(filters ...Filter) bool {
+	for _, f := range filters {
+		value, _ := t.Label(f.Label)
+		if slices.Contains(f.Values, value) == f.Not {
+			return false
+		}
+	}
+	return true
+}
+
+// GetValue encodes the series with its compressed chunks
+func (t *TimeSeries) GetValue() []byte {
+	b := make([]byte, 0, 64)
+	b = binary.AppendVarint(b, t.retention)
+	b = append(b, byte(t.duplicatePolicy))
+	b = binary.AppendUvarint(b, uint64(len(t.labels)))
+	for _, l := range t.labels {
+		b = encoding.AppendString(b, l.Name)
+		b = encoding.AppendString(b, l.Value)
+	}
+	b = encoding.AppendString(b, t.source)
+	b = binary.AppendUvarint(b, uint64(len(t.rules)))
+	for _, r := range t.rules {
+		b = encoding.AppendString(b, r.Key)
+		b = append(b, byte(r.Aggregation.Type))
+		b = binary.AppendVarint(b, r.Aggregation.BucketDuration)
+		if r.hasBucket {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+		b = binary.AppendVarint(b, r.bucket)
+		b = binary.AppendVarint(b, r.state.count)
+		for _, f := range []float64{r.state.sum, r.state.min, r.state.max, r.state.first, r.state.last} {
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+		}
+	}
+	b = binary.AppendUvarint(b, uint64(len(t.chunks)))
+	for _, c := range t.chunks {
+		b = binary.AppendUvarint(b, uint64(c.count))
+		b = binary.AppendVarint(b, c.first)
+		b = binary.AppendVarint(b, c.last)
+		b = append(b, c.b.count)
+		b = binary.AppendUvarint(b, uint64(len(c.b.stream)))
+		b = append(b, c.b.stream...)
+	}
+	return b
+}
+
+// SetValue decodes the series from the bytes of GetValue
+func (t *TimeSeries) SetValue(b []byte) {
+	r := encoding.NewReader(b)
+	t.retention = r.Varint()
+	t.duplicatePolicy = DuplicatePolicy(r.Byte())
+	t.labels = make([]Label, 0)
+	for i := r.Uvarint(); i > 0 && r.Ok(); i-- {
+		t.labels = append(t.labels, Label{Name: r.String(), Value: r.String()})
+	}
+	t.source = r.String()
+	t.rules = make([]*Rule, 0)
+	for i := r.Uvarint(); i > 0 && r.Ok(); i-- {
+		rule := &Rule{Key: r.String()}
+		rule.Aggregation.Type = Aggregator(r.Byte())
+		rule.Aggregation.BucketDuration = r.Varint()
+		rule.hasBucket = r.Byte() == 1
+		rule.bucket = r.Varint()
+		rule.state.count = r.Varint()
+		rule.state.sum = r.Float64()
+		rule.state.min = r.Float64()
+		rule.state.max = r.Float64()
+		rule.state.first = r.Float64()
+		rule.state.last = r.Float64()
+		t.rules = append(t.rules, rule)
+	}
+	t.chunks = make([]*chunk, 0)
+	for i := r.Uvarint(); i > 0 && r.Ok(); i-- {
+		c := &chunk{}
+		c.count = int(r.Uvarint())
+		c.first = r.Varint()
+		c.last = r.Varint()
+		c.b.count = r.Byte()
+		c.b.stream = append([]byte(nil), r.Bytes(int(r.Uvarint()))...)
+		if !r.Ok() {
+			break
+		}
+		t.chunks = append(t.chunks, c)
+	}
+}
@@ -0,0 +1,221 @@
+package timeseries
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestChunk_EncodeDecode(t *testing.T) {
+	samples := []Sample{
+		{Timestamp: 1000, Value: 1},
+		{Timestamp: 2000, Value: 1},
+		{Timestamp: 3000, Value: 1.5},
+		{Timestamp: 3001, Value: -20.25},
+		{Timestamp: 4000, Value: math.MaxFloat64},
+		{Timestamp: 1 << 40, Value: 0},
+		{Timestamp: 1<<40 + 300, Value: 3.14159},
+	}
+	c := chunkFrom(samples)
+	if c.count != len(samples) {
+		t.Errorf("count = %v, want %v", c.count, len(samples))
+	}
+	if !reflect.DeepEqual(c.samples(), samples) {
+		t.Errorf("samples() = %v, want %v", c.samples(), samples)
+	}
+	// decoded chunks must keep appending correctly
+	d := &chunk{b: c.b, count: c.count, first: c.first, last: c.last}
+	d.append(1<<41, 42)
+	samples = append(samples, Sample{Timestamp: 1 << 41, Value: 42})
+	if !reflect.DeepEqual(d.samples(), samples) {
+		t.Errorf("samples() = %v, want %v", d.samples(), samples)
+	}
+}
+
+func TestTimeSeries_Add(t *testing.T) {
+	ts := NewTimeSeries()
+	for i := int64(1); i <= 10000; i++ {
+		if _, err := ts.Add(i*10, float64(i), DuplicateNone); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	if ts.Len() != 10000 {
+		t.Errorf("Len() = %v, want %v", ts.Len(), 10000)
+	}
+	if len(ts.chunks) < 2 {
+		t.Errorf("chunks = %v, want more than one", len(ts.chunks))
+	}
+	if last := ts.Get(); last.Timestamp != 100000 || last.Value != 10000 {
+		t.Errorf("Get() = %v, want %v", last, Sample{100000, 10000})
+	}
+	if _, err := ts.Add(50, 1, DuplicateNone); err != ErrDuplicateBlocked {
+		t.Errorf("Add() error = %v, want %v", err, ErrDuplicateBlocked)
+	}
+	if _, err := ts.Add(50, 100, DuplicateSum); err != nil {
+		t.Errorf("Add() error = %v", err)
+	}
+	if _, err := ts.Add(55, 7, DuplicateNone); err != nil {
+		t.Errorf("Add() error = %v", err)
+	}
+	got := ts.Range(50, 60, 0, nil, false)
+	want := []Sample{{50, 105}, {55, 7}, {60, 6}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Range() = %v, want %v", got, want)
+	}
+}
+
+func TestTimeSeries_Retention(t *testing.T) {
+	ts := NewTimeSeries()
+	ts.SetRetention(100)
+	for i := int64(0); i < 1000; i++ {
+		_, _ = ts.Add(i, float64(i), DuplicateNone)
+	}
+	if ts.Len() != 101 {
+		t.Errorf("Len() = %v, want %v", ts.Len(), 101)
+	}
+	if _, err := ts.Add(10, 1, DuplicateLast); err != ErrTimestampTooOld {
+		t.Errorf("Add() error = %v, want %v", err, ErrTimestampTooOld)
+	}
+}
+
+func TestTimeSeries_IncrBy(t *testing.T) {
+	ts := NewTimeSeries()
+	v, _, _ := ts.IncrBy(10, 5)
+	if v != 5 {
+		t.Errorf("IncrBy() = %v, want %v", v, 5)
+	}
+	v, _, _ = ts.IncrBy(10, 5)
+	if v != 10 {
+		t.Errorf("IncrBy() = %v, want %v", v, 10)
+	}
+	v, _, _ = ts.IncrBy(20, -1)
+	if v != 9 {
+		t.Errorf("IncrBy() = %v, want %v", v, 9)
+	}
+	if _, _, err := ts.IncrBy(15, 1); err != ErrTimestampNotLast {
+		t.Errorf("IncrBy() error = %v, want %v", err, ErrTimestampNotLast)
+	}
+	if ts.Len() != 2 {
+		t.Errorf("Len() = %v, want %v", ts.Len(), 2)
+	}
+}
+
+func TestTimeSeries_RangeAggregation(t *testing.T) {
+	ts := NewTimeSeries()
+	for i := int64(0); i < 10; i++ {
+		_, _ = ts.Add(i*10, float64(i), DuplicateNone)
+	}
+	tests := []struct {
+		agg  Aggregator
+		want []Sample
+	}{
+		{AggAvg, []Sample{{0, 1}, {30, 4}, {60, 7}, {90, 9}}},
+		{AggSum, []Sample{{0, 3}, {30, 12}, {60, 21}, {90, 9}}},
+		{AggMin, []Sample{{0, 0}, {30, 3}, {60, 6}, {90, 9}}},
+		{AggMax, []Sample{{0, 2}, {30, 5}, {60, 8}, {90, 9}}},
+		{AggCount, []Sample{{0, 3}, {30, 3}, {60, 3}, {90, 1}}},
+		{AggFirst, []Sample{{0, 0}, {30, 3}, {60, 6}, {90, 9}}},
+		{AggLast, []Sample{{0, 2}, {30, 5}, {60, 8}, {90, 9}}},
+	}
+	for _, tt := range tests {
+		got := ts.Range(0, 100, 0, &Aggregation{Type: tt.agg, BucketDuration: 30}, false)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Range(%v) = %v, want %v", tt.agg, got, tt.want)
+		}
+	}
+	got := ts.Range(0, 100, 2, &Aggregation{Type: AggSum, BucketDuration: 30}, true)
+	want := []Sample{{90, 9}, {60, 21}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Range(rev) = %v, want %v", got, want)
+	}
+}
+
+func TestTimeSeries_Rules(t *testing.T) {
+	ts := NewTimeSeries()
+	if err := ts.AddRule("dst", Aggregation{Type: AggMax, BucketDuration: 100}); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	if err := ts.AddRule("dst", Aggregation{Type: AggMax, BucketDuration: 100}); err != ErrRuleExists {
+		t.Errorf("AddRule() error = %v, want %v", err, ErrRuleExists)
+	}
+	var compactions []Compaction
+	for i := int64(0); i < 25; i++ {
+		c, _ := ts.Add(i*10, float64(i), DuplicateNone)
+		compactions = append(compactions, c...)
+	}
+	want := []Compaction{
+		{Key: "dst", Sample: Sample{0, 9}},
+		{Key: "dst", Sample: Sample{100, 19}},
+	}
+	if !reflect.DeepEqual(compactions, want) {
+		t.Errorf("compactions = %v, want %v", compactions, want)
+	}
+	if !ts.DeleteRule("dst") || len(ts.Rules()) != 0 {
+		t.Errorf("DeleteRule() failed")
+	}
+}
+
+func TestTimeSeries_Match(t *testing.T) {
+	ts := NewTimeSeries()
+	ts.SetLabels([]Label{{"area", "us"}, {"sensor", "1"}})
+	tests := []struct {
+		filters []string
+		want    bool
+	}{
+		{[]string{"area=us"}, true},
+		{[]string{"area=eu"}, false},
+		{[]string{"area!=eu"}, true},
+		{[]string{"area=(eu,us)"}, true},
+		{[]string{"area!=(eu,us)"}, false},
+		{[]string{"area=us", "room="}, true},
+		{[]string{"area=us", "sensor="}, false},
+		{[]string{"area=us", "sensor!="}, true},
+	}
+	for _, tt := range tests {
+		var filters []Filter
+		for _, s := range tt.filters {
+			f, err := ParseFilter(s)
+			if err != nil {
+				t.Fatalf("ParseFilter(%v) error = %v", s, err)
+			}
+			filters = append(filters, f)
+		}
+		if got := ts.Match(filters...); got != tt.want {
+			t.Errorf("Match(%v) = %v, want %v", tt.filters, got, tt.want)
+		}
+	}
+}
+
+func TestTimeSeries_GetValueSetValue(t *testing.T) {
+	ts := NewTimeSeries()
+	ts.SetRetention(1000000)
+	ts.SetDuplicatePolicy(DuplicateLast)
+	ts.SetLabels([]Label{{"area", "us"}})
+	ts.SetSource("src")
+	_ = ts.AddRule("dst", Aggregation{Type: AggAvg, BucketDuration: 60})
+	for i := int64(0); i < 2000; i++ {
+		_, _ = ts.Add(i*7, math.Sin(float64(i)), DuplicateNone)
+	}
+	restored := NewTimeSeries()
+	restored.SetValue(ts.GetValue())
+	if restored.Retention() != ts.Retention() || restored.DuplicatePolicy() != ts.DuplicatePolicy() {
+		t.Errorf("SetValue() options mismatch")
+	}
+	if !reflect.DeepEqual(restored.Labels(), ts.Labels()) {
+		t.Errorf("Labels() = %v, want %v", restored.Labels(), ts.Labels())
+	}
+	if restored.Source() != "src" {
+		t.Errorf("Source() = %v, want %v", restored.Source(), "src")
+	}
+	if !reflect.DeepEqual(restored.Rules(), ts.Rules()) {
+		t.Errorf("Rules() = %v, want %v", restored.Rules(), ts.Rules())
+	}
+	want := ts.Range(0, math.MaxInt64, 0, nil, false)
+	if got := restored.Range(0, math.MaxInt64, 0, nil, false); !reflect.DeepEqual(got, want) {
+		t.Errorf("Range() mismatch after SetValue")
+	}
+	_, _ = restored.Add(2000*7, 1, DuplicateNone)
+	if restored.Len() != 2001 {
+		t.Errorf("Len() = %v, want %v", restored.Len(), 2001)
+	}
+}
@@ -0,0 +1,330 @@
+package vector
+
+import (
+	"container/heap"
+	"maps"
+	"math"
+	"slices"
+)
+
+const (
+	// maxLevel caps the number of layers of the graph
+	maxLevel = 16
+)
+
+// node is an element of the set and a vertex of the HNSW graph
+type node struct {
+	element string
+	attr    string
+	norm    float32   // norm of the original vector when the metric is cosine
+	vec     []float32 // not quantized vector
+	q       []int8    // int8 quantized vector
+	scale   float32   // scale of the quantized vector
+	links   [][]*node // neighbors of each layer
+	// inbound are the nodes linking to the node with the number of their layers linking to it
+	inbound map[*node]int
+}
+
+// addLink adds b to the neighbors of a on the layer
+func addLink(a, b *node, layer int) {
+	a.links[layer] = append(a.links[layer], b)
+	if b.inbound == nil {
+		b.inbound = make(map[*node]int)
+	}
+	b.inbound[a]++
+}
+
+// unlinked records that a doesn't link to b on one more layer
+func unlinked(a, b *node) {
+	if b.inbound[a]--; b.inbound[a] <= 0 {
+		delete(b.inbound, a)
+	}
+}
+
+// vector returns the stored vector, dequantized if needed
+func (n *node) vector() []float32 {
+	if n.q == nil {
+		return slices.Clone(n.vec)
+	}
+	v := make([]float32, len(n.q))
+	for i, x := range n.q {
+		v[i] = float32(x) * n.scale
+	}
+	return v
+}
+
+type candidate struct {
+	n *node
+	d float32
+}
+
+// candidateHeap is a min heap of candidates, or a max heap when max is true
+type candidateHeap struct {
+	items []candidate
+	max   bool
+}
+
+func (h *candidateHeap) Len() int { return len(h.items) }
+
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.max {
+		return h.items[i].d > h.items[j].d
+	}
+	return h.items[i].d < h.items[j].d
+}
+
+func (h *candidateHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *candidateHeap) Push(x any) { h.items = append(h.items, x.(candidate)) }
+
+func (h *candidateHeap) Pop() any {
+	c := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return c
+}
+
+// newNode builds a node from the vector according to the options of the set
+func (s *VectorSet) newNode(element string, v []float32) *node {
+	n := &node{element: element}
+	v = slices.Clone(v)
+	if s.opts.Metric == Cosine {
+		var sum float64
+		for _, x := range v {
+			sum += float64(x) * float64(x)
+		}
+		n.norm = float32(math.Sqrt(sum))
+		if n.norm > 0 {
+			for i := range v {
+				v[i] /= n.norm
+			}
+		}
+	}
+	if s.opts.Quantization == NoQuant {
+		n.vec = v
+		return n
+	}
+	var maxAbs float32
+	for _, x := range v {
+		maxAbs = max(maxAbs, float32(math.Abs(float64(x))))
+	}
+	n.q = make([]int8, len(v))
+	if maxAbs == 0 {
+		return n
+	}
+	n.scale = maxAbs / 127
+	for i, x := range v {
+		n.q[i] = int8(math.Round(float64(x / n.scale)))
+	}
+	return n
+}
+
+// distance returns the distance between two nodes, lower is closer
+func (s *VectorSet) distance(a, b *node) float32 {
+	if s.opts.Quantization == NoQuant {
+		var d float32
+		for i, x := range a.vec {
+			if s.opts.Metric == Cosine {
+				d += x * b.vec[i]
+				continue
+			}
+			diff := x - b.vec[i]
+			d += diff * diff
+		}
+		if s.opts.Metric == Cosine {
+			return 1 - d
+		}
+		return d
+	}
+	if s.opts.Metric == Cosine {
+		var dot int32
+		for i, x := range a.q {
+			dot += int32(x) * int32(b.q[i])
+		}
+		return 1 - float32(dot)*a.scale*b.scale
+	}
+	var d float32
+	for i, x := range a.q {
+		diff := float32(x)*a.scale - float32(b.q[i])*b.scale
+		d += diff * diff
+	}
+	return d
+}
+
+// score converts a distance to a similarity between 0 and 1, 1 means identical
+func (s *VectorSet) score(d float32) float64 {
+	if s.opts.Metric == Cosine {
+		return math.Max(0, math.Min(1, 1-float64(d)/2))
+	}
+	return 1 / (1 + math.Sqrt(math.Max(0, float64(d))))
+}
+
+func (s *VectorSet) randomLevel() int {
+	ml := 1 / math.Log(float64(max(s.opts.M, 2)))
+	level := int(-math.Log(1-s.rand.Float64()) * ml)
+	return min(level, maxLevel)
+}
+
+// maxLinks returns the maximum number of neighbors of a node on the layer
+func (s *VectorSet) maxLinks(layer int) int {
+	if layer == 0 {
+		return s.opts.M * 2
+	}
+	return s.opts.M
+}
+
+// searchLayer returns the ef nearest nodes to q on the layer, ordered by distance
+func (s *VectorSet) searchLayer(q *node, entries []candidate, ef int, layer int) []candidate {
+	visited := make(map[*node]struct{}, ef*4)
+	candidates := &candidateHeap{}
+	results := &candidateHeap{max: true}
+	for _, e := range entries {
+		visited[e.n] = struct{}{}
+		heap.Push(candidates, e)
+		heap.Push(results, e)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && c.d > results.items[0].d {
+			break
+		}
+		if layer >= len(c.n.links) {
+			continue
+		}
+		for _, nb := range c.n.links[layer] {
+			if _, ok := visited[nb]; ok {
+				continue
+			}
+			visited[nb] = struct{}{}
+			d := s.distance(q, nb)
+			if results.Len() < ef || d < results.items[0].d {
+				heap.Push(candidates, candidate{n: nb, d: d})
+				heap.Push(results, candidate{n: nb, d: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+	found := results.items
+	slices.SortFunc(found, func(a, b candidate) int {
+		if a.d < b.d {
+			return -1
+		}
+		if a.d > b.d {
+			return 1
+		}
+		return 0
+	})
+	return found
+}
+
+// link adds b to the neighbors of a on the layer, pruning the farthest neighbors if needed
+func (s *VectorSet) link(a, b *node, layer int) {
+	if slices.Contains(a.links[layer], b) {
+		return
+	}
+	addLink(a, b, layer)
+	limit := s.maxLinks(layer)
+	if len(a.links[layer]) <= limit {
+		return
+	}
+	candidates := make([]candidate, len(a.links[layer]))
+	for i, nb := range a.links[layer] {
+		candidates[i] = candidate{n: nb, d: s.distance(a, nb)}
+	}
+	slices.SortFunc(candidates, func(x, y candidate) int {
+		if x.d < y.d {
+			return -1
+		}
+		if x.d > y.d {
+			return 1
+		}
+		return 0
+	})
+	links := a.links[layer][:0]
+	for _, c := range candidates[:limit] {
+		links = append(links, c.n)
+	}
+	a.links[layer] = links
+	for _, c := range candidates[limit:] {
+		unlinked(a, c.n)
+	}
+}
+
+// insert adds the node to the graph
+func (s *VectorSet) insert(n *node) {
+	level := s.randomLevel()
+	n.links = make([][]*node, level+1)
+	if s.entry == nil {
+		s.entry = n
+		s.level = level
+		return
+	}
+	entries := []candidate{{n: s.entry, d: s.distance(n, s.entry)}}
+	for l := s.level; l > level; l-- {
+		entries = s.searchLayer(n, entries, 1, l)
+	}
+	for l := min(level, s.level); l >= 0; l-- {
+		found := s.searchLayer(n, entries, s.opts.EF, l)
+		for _, c := range found[:min(len(found), s.opts.M)] {
+			addLink(n, c.n, l)
+			s.link(c.n, n, l)
+		}
+		entries = found
+	}
+	if level > s.level {
+		s.level = level
+		s.entry = n
+	}
+}
+
+// remove deletes the node from the graph and repairs the links of the nodes linking to it
+func (s *VectorSet) remove(n *node) {
+	for l := range n.links {
+		for _, c := range n.links[l] {
+			unlinked(n, c)
+		}
+	}
+	for _, o := range slices.Collect(maps.Keys(n.inbound)) {
+		for l := range o.links {
+			i := slices.Index(o.links[l], n)
+			if i < 0 {
+				continue
+			}
+			o.links[l] = slices.Delete(o.links[l], i, i+1)
+			for _, c := range n.links[l] {
+				if c != o {
+					s.link(o, c, l)
+				}
+			}
+		}
+	}
+	n.inbound = nil
+	if s.entry != n {
+		return
+	}
+	s.entry = nil
+	s.level = 0
+	for _, o := range s.nodes {
+		if s.entry == nil || len(o.links)-1 > s.level {
+			s.entry = o
+			s.level = len(o.links) - 1
+		}
+	}
+}
+
+// search returns the k nearest nodes to q
+func (s *VectorSet) search(q *node, k int, ef int) []candidate {
+	if s.entry == nil || k <= 0 {
+		return nil
+	}
+	entries := []candidate{{n: s.entry, d: s.distance(q, s.entry)}}
+	for l := s.level; l > 0; l-- {
+		entries = s.searchLayer(q, entries, 1, l)
+	}
+	found := s.searchLayer(q, entries, max(ef, k), 0)
+	return found[:min(len(found), k)]
+}
@@ -0,0 +1,308 @@
+package vector
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand/v2"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/internal/encoding"
+)
+
+var (
+	ErrDimensionMismatch = errors.New("ERR Vector dimension mismatch")
+	ErrEmptyVector       = errors.New("ERR vector must not be empty")
+	ErrElementNotFound   = errors.New("ERR element not found in set")
+)
+
+const (
+	DefaultM  = 16
+	DefaultEF = 200
+)
+
+// Quantization is how the vectors are stored
+type Quantization uint8
+
+const (
+	// Q8 stores every component as an int8 with a per vector scale
+	Q8 Quantization = iota
+	// NoQuant stores the vectors as float32
+	NoQuant
+)
+
+// Metric is the distance function of the set
+type Metric uint8
+
+const (
+	Cosine Metric = iota
+	L2
+)
+
+// Options are the options of a vector set, they are fixed when the set is created
+type Options struct {
+	Quantization Quantization
+	Metric       Metric
+	// M is the max number of links of a node, 2*M on the bottom layer
+	M int
+	// EF is the exploration factor used when building the graph
+	EF int
+}
+
+// Result is an element returned by a similarity search
+type Result struct {
+	Element string
+	Score   float64
+}
+
+// VectorSet is a set of named vectors indexed by a HNSW graph
+type VectorSet struct {
+	dim   int
+	opts  Options
+	nodes map[string]*node
+	entry *node
+	level int
+	rand  *rand.Rand
+}
+
+// NewVectorSet creates a new vector set
+func NewVectorSet(opts Options) *VectorSet {
+	if opts.M <= 0 {
+		opts.M = DefaultM
+	}
+	if opts.EF <= 0 {
+		opts.EF = DefaultEF
+	}
+	return &VectorSet{
+		opts:  opts,
+		nodes: make(map[string]*node),
+		rand:  rand.New(rand.NewPCG(uint64(opts.M), uint64(opts.EF))),
+	}
+}
+
+// Type returns the type of the data structure
+func (s *VectorSet) Type() ds.ValueType {
+	return ds.VectorSet
+}
+
+// Options returns the options of the set
+func (s *VectorSet) Options() Options {
+	return s.opts
+}
+
+// VAdd adds the element with the vector, the vector of an existing element is replaced.
+// It returns true if the element is new.
+func (s *VectorSet) VAdd(element string, vector []float32) (bool, error) {
+	if len(vector) == 0 {
+		return false, ErrEmptyVector
+	}
+	if s.dim != 0 && len(vector) != s.dim {
+		return false, ErrDimensionMismatch
+	}
+	s.dim = len(vector)
+	n := s.newNode(element, vector)
+	old, ok := s.nodes[element]
+	if ok {
+		n.attr = old.attr
+		delete(s.nodes, element)
+		s.remove(old)
+	}
+	s.nodes[element] = n
+	s.insert(n)
+	return !ok, nil
+}
+
+// VRem removes the element
+func (s *VectorSet) VRem(element string) bool {
+	n, ok := s.nodes[element]
+	if !ok {
+		return false
+	}
+	delete(s.nodes, element)
+	s.remove(n)
+	return true
+}
+
+// VSim returns the count elements most similar to the vector.
+// ef is the search exploration factor, larger values are more accurate.
+func (s *VectorSet) VSim(vector []float32, count int, ef int) ([]Result, error) {
+	if len(vector) != s.dim {
+		return nil, ErrDimensionMismatch
+	}
+	return s.results(s.search(s.newNode("", vector), count, ef)), nil
+}
+
+// VSimElement returns the count elements most similar to the element
+func (s *VectorSet) VSimElement(element string, count int, ef int) ([]Result, error) {
+	n, ok := s.nodes[element]
+	if !ok {
+		return nil, ErrElementNotFound
+	}
+	return s.results(s.search(n, count, ef)), nil
+}
+
+func (s *VectorSet) results(found []candidate) []Result {
+	results := make([]Result, len(found))
+	for i, c := range found {
+		results[i] = Result{Element: c.n.element, Score: s.score(c.d)}
+	}
+	return results
+}
+
+// VCard returns the number of elements
+func (s *VectorSet) VCard() int64 {
+	return int64(len(s.nodes))
+}
+
+// VDim returns the dimension of the vectors
+func (s *VectorSet) VDim() int64 {
+	return int64(s.dim)
+}
+
+// VEmb returns the approximated vector of the element, nil if it doesn't exist
+func (s *VectorSet) VEmb(element string) []float32 {
+	n, ok := s.nodes[element]
+	if !ok {
+		return nil
+	}
+	v := n.vector()
+	if s.opts.Metric == Cosine {
+		for i := range v {
+			v[i] *= n.norm
+		}
+	}
+	return v
+}
+
+// VGetAttr returns the attributes of the element
+func (s *VectorSet) VGetAttr(element string) (string, bool) {
+	n, ok := s.nodes[element]
+	if !ok || n.attr == "" {
+		return "", false
+	}
+	return n.attr, true
+}
+
+// VSetAttr sets the attributes of the element, an empty attr removes them
+func (s *VectorSet) VSetAttr(element string, attr string) bool {
+	n, ok := s.nodes[element]
+	if !ok {
+		return false
+	}
+	n.attr = attr
+	return true
+}
+
+// GetValue encodes the elements followed by the HNSW graph: the entry node, then the layers of
+// every node with the indexes of its neighbors
+func (s *VectorSet) GetValue() []byte {
+	b := make([]byte, 0, 64+len(s.nodes)*(s.dim+16+s.opts.M*4))
+	b = append(b, byte(s.opts.Quantization), byte(s.opts.Metric))
+	b = binary.AppendUvarint(b, uint64(s.opts.M))
+	b = binary.AppendUvarint(b, uint64(s.opts.EF))
+	b = binary.AppendUvarint(b, uint64(s.dim))
+	b = binary.AppendUvarint(b, uint64(len(s.nodes)))
+	indexes := make(map[*node]uint64, len(s.nodes))
+	nodes := make([]*node, 0, len(s.nodes))
+	for _, n := range s.nodes {
+		indexes[n] = uint64(len(nodes))
+		nodes = append(nodes, n)
+		b = encoding.AppendString(b, n.element)
+		b = encoding.AppendString(b, n.attr)
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(n.norm))
+		if n.q != nil {
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(n.scale))
+			for _, x := range n.q {
+				b = append(b, byte(x))
+			}
+			continue
+		}
+		for _, x := range n.vec {
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(x))
+		}
+	}
+	if s.entry == nil {
+		return b
+	}
+	b = binary.AppendUvarint(b, indexes[s.entry])
+	for _, n := range nodes {
+		b = binary.AppendUvarint(b, uint64(len(n.links)))
+		for _, links := range n.links {
+			b = binary.AppendUvarint(b, uint64(len(links)))
+			for _, nb := range links {
+				b = binary.AppendUvarint(b, indexes[nb])
+			}
+		}
+	}
+	return b
+}
+
+// SetValue decodes the set from the bytes of GetValue, the graph is rebuilt if it's missing
+func (s *VectorSet) SetValue(b []byte) {
+	if len(b) < 2 {
+		return
+	}
+	opts := Options{Quantization: Quantization(b[0]), Metric: Metric(b[1])}
+	r := encoding.NewReader(b[2:])
+	opts.M = int(r.Uvarint())
+	opts.EF = int(r.Uvarint())
+	*s = *NewVectorSet(opts)
+	s.dim = int(r.Uvarint())
+	var nodes []*node
+	for i := r.Uvarint(); i > 0 && r.Ok(); i-- {
+		n := &node{element: r.String(), attr: r.String(), norm: r.Float32()}
+		if opts.Quantization == NoQuant {
+			n.vec = make([]float32, s.dim)
+			for j := range n.vec {
+				n.vec[j] = r.Float32()
+			}
+		} else {
+			n.scale = r.Float32()
+			n.q = make([]int8, s.dim)
+			for j, x := range r.Bytes(s.dim) {
+				n.q[j] = int8(x)
+			}
+		}
+		if !r.Ok() {
+			break
+		}
+		s.nodes[n.element] = n
+		nodes = append(nodes, n)
+	}
+	if r.Ok() && r.Len() > 0 && s.setGraph(r, nodes) {
+		return
+	}
+	for _, n := range nodes {
+		n.links, n.inbound = nil, nil
+	}
+	for _, n := range nodes {
+		s.insert(n)
+	}
+}
+
+// setGraph links the nodes by the graph read, it returns false if the graph is corrupted
+func (s *VectorSet) setGraph(r *encoding.Reader, nodes []*node) bool {
+	entry := r.Uvarint()
+	if entry >= uint64(len(nodes)) {
+		return false
+	}
+	for _, n := range nodes {
+		layers := r.Uvarint()
+		if !r.Ok() || layers == 0 || layers > maxLevel+1 {
+			return false
+		}
+		n.links = make([][]*node, layers)
+		for l := range n.links {
+			for i := r.Uvarint(); i > 0; i-- {
+				nb := r.Uvarint()
+				if !r.Ok() || nb >= uint64(len(nodes)) {
+					return false
+				}
+				addLink(n, nodes[nb], l)
+			}
+		}
+	}
+	s.entry = nodes[entry]
+	s.level = len(s.entry.links) - 1
+	return r.Ok()
+}
@@ -0,0 +1,246 @@
+package vector
+
+import (
+	"maps"
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func randomVectors(n, dim int) [][]float32 {
+	r := rand.New(rand.NewPCG(1, 2))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = r.Float32()*2 - 1
+		}
+	}
+	return vectors
+}
+
+// bruteForce returns the k nearest elements using exact distances
+func bruteForce(s *VectorSet, q []float32, k int) []string {
+	qn := s.newNode("", q)
+	type pair struct {
+		element string
+		d       float32
+	}
+	pairs := make([]pair, 0, len(s.nodes))
+	for _, n := range s.nodes {
+		pairs = append(pairs, pair{n.element, s.distance(qn, n)})
+	}
+	slices.SortFunc(pairs, func(a, b pair) int {
+		if a.d < b.d {
+			return -1
+		}
+		if a.d > b.d {
+			return 1
+		}
+		return 0
+	})
+	elements := make([]string, 0, k)
+	for _, p := range pairs[:k] {
+		elements = append(elements, p.element)
+	}
+	return elements
+}
+
+func recall(t *testing.T, s *VectorSet, queries [][]float32, k int) float64 {
+	var hits, total int
+	for _, q := range queries {
+		results, err := s.VSim(q, k, 100)
+		if err != nil {
+			t.Fatalf("VSim() error = %v", err)
+		}
+		want := bruteForce(s, q, k)
+		for _, r := range results {
+			if slices.Contains(want, r.Element) {
+				hits++
+			}
+		}
+		total += k
+	}
+	return float64(hits) / float64(total)
+}
+
+func TestVectorSet_VSimRecall(t *testing.T) {
+	vectors := randomVectors(1000, 32)
+	for _, opts := range []Options{
+		{Quantization: NoQuant, Metric: Cosine},
+		{Quantization: Q8, Metric: Cosine},
+		{Quantization: NoQuant, Metric: L2},
+		{Quantization: Q8, Metric: L2},
+	} {
+		s := NewVectorSet(opts)
+		for i, v := range vectors {
+			if _, err := s.VAdd(strconv.Itoa(i), v); err != nil {
+				t.Fatalf("VAdd() error = %v", err)
+			}
+		}
+		if r := recall(t, s, vectors[:50], 10); r < 0.9 {
+			t.Errorf("recall(%+v) = %v, want >= 0.9", opts, r)
+		}
+	}
+}
+
+func TestVectorSet_VAdd(t *testing.T) {
+	s := NewVectorSet(Options{Quantization: NoQuant})
+	if added, _ := s.VAdd("a", []float32{1, 0}); !added {
+		t.Errorf("VAdd() = %v, want %v", added, true)
+	}
+	if added, _ := s.VAdd("a", []float32{0, 1}); added {
+		t.Errorf("VAdd() = %v, want %v", added, false)
+	}
+	if _, err := s.VAdd("b", []float32{1, 0, 0}); err != ErrDimensionMismatch {
+		t.Errorf("VAdd() error = %v, want %v", err, ErrDimensionMismatch)
+	}
+	if s.VCard() != 1 || s.VDim() != 2 {
+		t.Errorf("VCard() = %v, VDim() = %v, want 1, 2", s.VCard(), s.VDim())
+	}
+	if got := s.VEmb("a"); !reflect.DeepEqual(got, []float32{0, 1}) {
+		t.Errorf("VEmb() = %v, want %v", got, []float32{0, 1})
+	}
+}
+
+func TestVectorSet_VSim(t *testing.T) {
+	s := NewVectorSet(Options{})
+	_, _ = s.VAdd("x", []float32{1, 0})
+	_, _ = s.VAdd("y", []float32{0, 1})
+	_, _ = s.VAdd("xy", []float32{1, 1})
+	_, _ = s.VAdd("-x", []float32{-1, 0})
+	results, _ := s.VSim([]float32{2, 0.1}, 2, 0)
+	if len(results) != 2 || results[0].Element != "x" || results[1].Element != "xy" {
+		t.Errorf("VSim() = %v, want x, xy", results)
+	}
+	if math.Abs(results[0].Score-1) > 0.01 {
+		t.Errorf("VSim() score = %v, want ~1", results[0].Score)
+	}
+	results, _ = s.VSimElement("x", 4, 0)
+	if results[len(results)-1].Element != "-x" || results[len(results)-1].Score > 0.01 {
+		t.Errorf("VSimElement() = %v, want -x last with score ~0", results)
+	}
+	if _, err := s.VSimElement("none", 1, 0); err != ErrElementNotFound {
+		t.Errorf("VSimElement() error = %v, want %v", err, ErrElementNotFound)
+	}
+}
+
+func TestVectorSet_VRem(t *testing.T) {
+	vectors := randomVectors(300, 8)
+	s := NewVectorSet(Options{Quantization: NoQuant})
+	for i, v := range vectors {
+		_, _ = s.VAdd(strconv.Itoa(i), v)
+	}
+	for i := 0; i < 300; i += 2 {
+		if !s.VRem(strconv.Itoa(i)) {
+			t.Fatalf("VRem(%d) = false", i)
+		}
+	}
+	if s.VRem("0") {
+		t.Errorf("VRem() = true, want false")
+	}
+	if s.VCard() != 150 {
+		t.Errorf("VCard() = %v, want %v", s.VCard(), 150)
+	}
+	for _, q := range vectors[:20] {
+		results, _ := s.VSim(q, 150, 150)
+		if len(results) != 150 {
+			t.Fatalf("VSim() returned %v elements, want %v", len(results), 150)
+		}
+		for _, r := range results {
+			if i, _ := strconv.Atoi(r.Element); i%2 == 0 {
+				t.Fatalf("VSim() returned removed element %v", r.Element)
+			}
+		}
+	}
+	if r := recall(t, s, vectors[:20], 5); r < 0.9 {
+		t.Errorf("recall after VRem = %v, want >= 0.9", r)
+	}
+}
+
+func TestVectorSet_Attr(t *testing.T) {
+	s := NewVectorSet(Options{})
+	_, _ = s.VAdd("a", []float32{1, 2})
+	if _, ok := s.VGetAttr("a"); ok {
+		t.Errorf("VGetAttr() ok = true, want false")
+	}
+	if !s.VSetAttr("a", `{"year":2024}`) || s.VSetAttr("b", "{}") {
+		t.Errorf("VSetAttr() failed")
+	}
+	_, _ = s.VAdd("a", []float32{2, 1})
+	if attr, _ := s.VGetAttr("a"); attr != `{"year":2024}` {
+		t.Errorf("VGetAttr() = %v, want %v", attr, `{"year":2024}`)
+	}
+}
+
+func TestVectorSet_GetValueSetValue(t *testing.T) {
+	for _, opts := range []Options{{Quantization: NoQuant, Metric: L2, M: 8}, {Quantization: Q8, Metric: Cosine}} {
+		s := NewVectorSet(opts)
+		for i, v := range randomVectors(200, 16) {
+			_, _ = s.VAdd(strconv.Itoa(i), v)
+		}
+		s.VSetAttr("1", "attr")
+		restored := NewVectorSet(Options{})
+		restored.SetValue(s.GetValue())
+		if restored.Options() != s.Options() || restored.VCard() != 200 || restored.VDim() != 16 {
+			t.Fatalf("SetValue() = %+v %v %v", restored.Options(), restored.VCard(), restored.VDim())
+		}
+		if !reflect.DeepEqual(restored.VEmb("5"), s.VEmb("5")) {
+			t.Errorf("VEmb() = %v, want %v", restored.VEmb("5"), s.VEmb("5"))
+		}
+		if attr, _ := restored.VGetAttr("1"); attr != "attr" {
+			t.Errorf("VGetAttr() = %v, want %v", attr, "attr")
+		}
+		// the graph is restored as it was
+		results, _ := restored.VSimElement("7", 10, 0)
+		if want, _ := s.VSimElement("7", 10, 0); !reflect.DeepEqual(results, want) {
+			t.Errorf("VSimElement() = %v, want %v", results, want)
+		}
+
+		// the graph is rebuilt if the encoded set has none
+		entry := s.entry
+		s.entry = nil
+		restored.SetValue(s.GetValue())
+		s.entry = entry
+		if restored.VCard() != 200 || restored.entry == nil {
+			t.Fatalf("SetValue() = %v elements, want the graph rebuilt", restored.VCard())
+		}
+		if r := recall(t, restored, randomVectors(20, 16), 5); r < 0.9 {
+			t.Errorf("recall of the rebuilt graph = %v, want >= 0.9", r)
+		}
+	}
+}
+
+func TestVectorSet_Inbound(t *testing.T) {
+	s := NewVectorSet(Options{M: 4})
+	vectors := randomVectors(300, 8)
+	for i, v := range vectors {
+		_, _ = s.VAdd(strconv.Itoa(i), v)
+	}
+	for i := 0; i < 300; i += 3 {
+		s.VRem(strconv.Itoa(i))
+		_, _ = s.VAdd(strconv.Itoa(i+1), vectors[i])
+	}
+	inbound := make(map[*node]map[*node]int)
+	for _, n := range s.nodes {
+		for _, links := range n.links {
+			for _, nb := range links {
+				if s.nodes[nb.element] != nb {
+					t.Fatalf("%s links to the removed node %s", n.element, nb.element)
+				}
+				if inbound[nb] == nil {
+					inbound[nb] = make(map[*node]int)
+				}
+				inbound[nb][n]++
+			}
+		}
+	}
+	for _, n := range s.nodes {
+		if !maps.Equal(n.inbound, inbound[n]) {
+			t.Fatalf("inbound of %s = %d nodes, want %d", n.element, len(n.inbound), len(inbound[n]))
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package zset
+
+import "errors"
+
+var (
+	ErrInvalidLexRange = errors.New("ERR min or max not valid string range item")
+)
+
+type lexBorder struct {
+	value string
+	// inf is -1 for "-" and 1 for "+"
+	inf       int8
+	exclusive bool
+}
+
+func parseLexBorder(s string) (lexBorder, error) {
+	if s == "-" {
+		return lexBorder{inf: -1}, nil
+	}
+	if s == "+" {
+		return lexBorder{inf: 1}, nil
+	}
+	if len(s) == 0 {
+		return lexBorder{}, ErrInvalidLexRange
+	}
+	switch s[0] {
+	case '[':
+		return lexBorder{value: s[1:]}, nil
+	case '(':
+		return lexBorder{value: s[1:], exclusive: true}, nil
+	}
+	return lexBorder{}, ErrInvalidLexRange
+}
+
+// compare compares the borders ignoring whether they are exclusive
+func (b lexBorder) compare(o lexBorder) int {
+	if b.inf != 0 || o.inf != 0 {
+		return int(b.inf) - int(o.inf)
+	}
+	switch {
+	case b.value < o.value:
+		return -1
+	case b.value > o.value:
+		return 1
+	}
+	return 0
+}
+
+// LexRange is a lexicographical range of members like [a (b, - and +.
+// It's only meaningful when all the members have the same score.
+type LexRange struct {
+	min, max lexBorder
+}
+
+// ParseLexRange parses the min and max borders of a lexicographical range
+func ParseLexRange(min, max string) (*LexRange, error) {
+	minBorder, err := parseLexBorder(min)
+	if err != nil {
+		return nil, err
+	}
+	maxBorder, err := parseLexBorder(max)
+	if err != nil {
+		return nil, err
+	}
+	return &LexRange{min: minBorder, max: maxBorder}, nil
+}
+
+// empty returns whether no member could be in the range
+func (r *LexRange) empty() bool {
+	c := r.min.compare(r.max)
+	return c > 0 || (c == 0 && (r.min.inf != 0 || r.min.exclusive || r.max.exclusive))
+}
+
+// gteMin returns whether the member is greater than the min border
+func (r *LexRange) gteMin(member string) bool {
+	switch r.min.inf {
+	case -1:
+		return true
+	case 1:
+		return false
+	}
+	if r.min.exclusive {
+		return member > r.min.value
+	}
+	return member >= r.min.value
+}
+
+// lteMax returns whether the member is less than the max border
+func (r *LexRange) lteMax(member string) bool {
+	switch r.max.inf {
+	case -1:
+		return false
+	case 1:
+		return true
+	}
+	if r.max.exclusive {
+		return member < r.max.value
+	}
+	return member <= r.max.value
+}
@@ -0,0 +1,268 @@
+package zset
+
+import (
+	"cmp"
+	"encoding/binary"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	EncodingListpack = "listpack"
+	EncodingSkiplist = "skiplist"
+)
+
+// compareItems orders the items by score then member, like the skiplist
+func compareItems(a, b Item) int {
+	if c := cmp.Compare(a.Score, b.Score); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Member, b.Member)
+}
+
+func appendPacked(b []byte, member string, score float64) []byte {
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(score))
+	b = binary.AppendVarint(b, int64(len(member)))
+	return append(b, member...)
+}
+
+// nextPacked returns the first member of the listpack and the remaining members
+func nextPacked(b []byte) (member []byte, score float64, rest []byte) {
+	score = math.Float64frombits(binary.LittleEndian.Uint64(b))
+	mLen, n := binary.Varint(b[8:])
+	end := 8 + n + int(mLen)
+	return b[8+n : end], score, b[end:]
+}
+
+// packed returns whether the set is encoded as a listpack
+func (sortedSet *SortedSet) packed() bool {
+	return sortedSet.skiplist == nil
+}
+
+// items decodes the members of the listpack
+func (sortedSet *SortedSet) items() []Item {
+	items := make([]Item, 0, sortedSet.count)
+	for b := sortedSet.listpack; len(b) > 0; {
+		var member []byte
+		var score float64
+		member, score, b = nextPacked(b)
+		items = append(items, Item{Member: string(member), Score: score})
+	}
+	return items
+}
+
+// find returns the rank and the score of the member in the listpack
+func (sortedSet *SortedSet) find(member string) (int64, float64, bool) {
+	var rank int64
+	for b := sortedSet.listpack; len(b) > 0; rank++ {
+		var m []byte
+		var score float64
+		m, score, b = nextPacked(b)
+		if string(m) == member {
+			return rank, score, true
+		}
+	}
+	return 0, 0, false
+}
+
+// pack replaces the listpack with the sorted items, the set is converted to a skiplist
+// if the items exceed the limits
+func (sortedSet *SortedSet) pack(items []Item) {
+	limits := sortedSet.encodings.Limits()
+	if int64(len(items)) > limits.ZSetMaxListpackEntries {
+		sortedSet.convert(items)
+		return
+	}
+	size := 0
+	for _, item := range items {
+		if int64(len(item.Member)) > limits.ZSetMaxListpackValue {
+			sortedSet.convert(items)
+			return
+		}
+		size += 8 + binary.MaxVarintLen64 + len(item.Member)
+	}
+	b := make([]byte, 0, size)
+	for _, item := range items {
+		b = appendPacked(b, item.Member, item.Score)
+	}
+	sortedSet.listpack = b
+	sortedSet.count = int64(len(items))
+}
+
+// convert converts the set to a skiplist holding the items
+func (sortedSet *SortedSet) convert(items []Item) {
+	sortedSet.dict = make(map[string]*Item, len(items))
+	sortedSet.skiplist = makeSkiplist()
+	sortedSet.listpack = nil
+	sortedSet.count = 0
+	for _, item := range items {
+		sortedSet.dict[item.Member] = &Item{Member: item.Member, Score: item.Score}
+		sortedSet.skiplist.insert(item.Member, item.Score)
+	}
+}
+
+// packedAdd adds or updates the member of the listpack
+func (sortedSet *SortedSet) packedAdd(member string, score float64) int64 {
+	items := sortedSet.items()
+	i := slices.IndexFunc(items, func(item Item) bool {
+		return item.Member == member
+	})
+	if i >= 0 {
+		if items[i].Score == score {
+			return 0
+		}
+		items = slices.Delete(items, i, i+1)
+	}
+	item := Item{Member: member, Score: score}
+	j, _ := slices.BinarySearchFunc(items, item, compareItems)
+	sortedSet.pack(slices.Insert(items, j, item))
+	if i >= 0 {
+		return 0
+	}
+	return 1
+}
+
+// packedRemove removes the members of the listpack matching remove
+func (sortedSet *SortedSet) packedRemove(remove func(rank int64, item *Item) bool) int64 {
+	items := sortedSet.items()
+	kept := items[:0]
+	for i := range items {
+		if !remove(int64(i), &items[i]) {
+			kept = append(kept, items[i])
+		} else {
+			sortedSet.changes.Add(items[i].Member)
+		}
+	}
+	removed := int64(len(items) - len(kept))
+	if removed > 0 {
+		sortedSet.pack(kept)
+	}
+	return removed
+}
+
+// cursor walks the members in order, it's backed by a skiplist node or by the decoded listpack
+type cursor struct {
+	node   *node
+	packed bool
+	items  []Item
+	i      int
+	desc   bool
+}
+
+func (c *cursor) valid() bool {
+	if c.packed {
+		return c.i >= 0 && c.i < len(c.items)
+	}
+	return c.node != nil
+}
+
+func (c *cursor) item() *Item {
+	if !c.valid() {
+		return nil
+	}
+	if c.packed {
+		return &c.items[c.i]
+	}
+	return &c.node.Item
+}
+
+func (c *cursor) next() {
+	switch {
+	case c.packed && c.desc:
+		c.i--
+	case c.packed:
+		c.i++
+	case c.desc:
+		c.node = c.node.backward
+	default:
+		c.node = c.node.level[0].forward
+	}
+}
+
+// byRank returns a cursor on the member at rank, the rank starts from 0 and from the highest
+// score if desc is true
+func (sortedSet *SortedSet) byRank(rank int64, desc bool) *cursor {
+	c := &cursor{desc: desc, packed: sortedSet.packed()}
+	if c.packed {
+		c.items = sortedSet.items()
+		c.i = int(rank)
+		if desc {
+			c.i = len(c.items) - 1 - int(rank)
+		}
+		return c
+	}
+	if rank < 0 || rank >= sortedSet.skiplist.length {
+		return c
+	}
+	if desc {
+		rank = sortedSet.skiplist.length - 1 - rank
+	}
+	c.node = sortedSet.skiplist.getByRank(rank + 1)
+	return c
+}
+
+// firstInRange returns a cursor on the first member within the score range, the last one if desc is true
+func (sortedSet *SortedSet) firstInRange(min, max float64, desc bool) *cursor {
+	c := &cursor{desc: desc, packed: sortedSet.packed()}
+	if !c.packed {
+		if desc {
+			c.node = sortedSet.skiplist.getLastInRange(min, max)
+		} else {
+			c.node = sortedSet.skiplist.getFirstInRange(min, max)
+		}
+		return c
+	}
+	c.items = sortedSet.items()
+	if desc {
+		c.i = sortSearch(c.items, func(item *Item) bool { return item.Score > max }) - 1
+		if c.valid() && c.items[c.i].Score < min {
+			c.i = -1
+		}
+		return c
+	}
+	c.i = sortSearch(c.items, func(item *Item) bool { return item.Score >= min })
+	if c.valid() && c.items[c.i].Score > max {
+		c.i = len(c.items)
+	}
+	return c
+}
+
+// firstInLexRange returns a cursor on the first member within the lexicographical range,
+// the last one if desc is true
+func (sortedSet *SortedSet) firstInLexRange(r *LexRange, desc bool) *cursor {
+	c := &cursor{desc: desc, packed: sortedSet.packed()}
+	if !c.packed {
+		if desc {
+			c.node = sortedSet.skiplist.getLastInLexRange(r)
+		} else {
+			c.node = sortedSet.skiplist.getFirstInLexRange(r)
+		}
+		return c
+	}
+	c.items = sortedSet.items()
+	if r.empty() {
+		c.i = -1
+		return c
+	}
+	if desc {
+		c.i = sortSearch(c.items, func(item *Item) bool { return !r.lteMax(item.Member) }) - 1
+		if c.valid() && !r.gteMin(c.items[c.i].Member) {
+			c.i = -1
+		}
+		return c
+	}
+	c.i = sortSearch(c.items, func(item *Item) bool { return r.gteMin(item.Member) })
+	if c.valid() && !r.lteMax(c.items[c.i].Member) {
+		c.i = len(c.items)
+	}
+	return c
+}
+
+// sortSearch returns the index of the first item for which f is true
+func sortSearch(items []Item, f func(item *Item) bool) int {
+	return sort.Search(len(items), func(i int) bool {
+		return f(&items[i])
+	})
+}
@@ -332,7 +332,7 @@ func (skiplist *skiplist) removeRange(min float64, max float64, limit int, mode
 	return removed
 }
 
-// removeRangeByRank removes nodes in range [start, stop]
+// removeRangeByRank removes nodes in range [start, stop], the rank starts from 1
 func (skiplist *skiplist) removeRangeByRank(start int64, stop int64) (removed []*Item) {
 	var i int64 = 0 // rank of iterator
 	update := make([]*node, maxLevel)
@@ -349,6 +349,7 @@ func (skiplist *skiplist) removeRangeByRank(start int64, stop int64) (removed []
 	}
 
 	node = node.level[0].forward // first node in range
+	i++
 
 	// remove nodes in range
 	for node != nil && i <= stop {
@@ -361,3 +362,80 @@ func (skiplist *skiplist) removeRangeByRank(start int64, stop int64) (removed []
 	}
 	return removed
 }
+
+func (skiplist *skiplist) hasInLexRange(r *LexRange) bool {
+	if r.empty() {
+		return false
+	}
+	n := skiplist.tail
+	if n == nil || !r.gteMin(n.Member) {
+		return false
+	}
+	n = skiplist.header.level[0].forward
+	if n == nil || !r.lteMax(n.Member) {
+		return false
+	}
+	return true
+}
+
+func (skiplist *skiplist) getFirstInLexRange(r *LexRange) *node {
+	if !skiplist.hasInLexRange(r) {
+		return nil
+	}
+	n := skiplist.header
+	// scan from top level
+	for level := skiplist.level - 1; level >= 0; level-- {
+		for n.level[level].forward != nil && !r.gteMin(n.level[level].forward.Member) {
+			n = n.level[level].forward
+		}
+	}
+	/* This is an inner range, so the next node cannot be NULL. */
+	n = n.level[0].forward
+	if !r.lteMax(n.Member) {
+		return nil
+	}
+	return n
+}
+
+func (skiplist *skiplist) getLastInLexRange(r *LexRange) *node {
+	if !skiplist.hasInLexRange(r) {
+		return nil
+	}
+	n := skiplist.header
+	// scan from top level
+	for level := skiplist.level - 1; level >= 0; level-- {
+		for n.level[level].forward != nil && r.lteMax(n.level[level].forward.Member) {
+			n = n.level[level].forward
+		}
+	}
+	if !r.gteMin(n.Member) {
+		return nil
+	}
+	return n
+}
+
+// removeRangeByLex removes nodes in the lexicographical range
+func (skiplist *skiplist) removeRangeByLex(r *LexRange) (removed []*Item) {
+	update := make([]*node, maxLevel)
+	removed = make([]*Item, 0)
+	node := skiplist.header
+	for i := skiplist.level - 1; i >= 0; i-- {
+		for node.level[i].forward != nil && !r.gteMin(node.level[i].forward.Member) {
+			node = node.level[i].forward
+		}
+		update[i] = node
+	}
+
+	// node is the first one within range
+	node = node.level[0].forward
+
+	// remove nodes in range
+	for node != nil && r.lteMax(node.Member) {
+		next := node.level[0].forward
+		removedElement := node.Item
+		removed = append(removed, &removedElement)
+		skiplist.removeNode(node, update)
+		node = next
+	}
+	return removed
+}
@@ -3,7 +3,10 @@ package zset
 import (
 	"encoding/binary"
 	"errors"
+	"math"
+	"math/rand"
 	"path/filepath"
+	"slices"
 
 	"github.com/diiyw/nodis/ds"
 )
@@ -13,18 +16,59 @@ const (
 	MaxOpen = 2
 )
 
-// SortedSet is a set which keys sorted by bound score
+// SortedSet is a set which keys sorted by bound score. A small set is encoded as a listpack,
+// it is converted to a skiplist once it exceeds the limits of ds.EncodingLimits.
 type SortedSet struct {
+	// dict and skiplist are nil while the set is encoded as a listpack
 	dict     map[string]*Item
 	skiplist *skiplist
+	// listpack holds the members sorted by score then member, each member is encoded as
+	// its score followed by its varint length and its bytes
+	listpack  []byte
+	count     int64
+	changes   ds.Changes
+	encodings *ds.Encodings
 }
 
-// NewSortedSet makes a new SortedSet
+// NewSortedSet makes a new SortedSet with the default encoding limits
 func NewSortedSet() *SortedSet {
-	return &SortedSet{
-		dict:     make(map[string]*Item),
-		skiplist: makeSkiplist(),
+	return &SortedSet{}
+}
+
+// NewSortedSetWithEncodings makes a new SortedSet encoded by the limits of the encodings
+func NewSortedSetWithEncodings(e *ds.Encodings) *SortedSet {
+	return &SortedSet{encodings: e}
+}
+
+// SetEncodings sets the encodings of the set, they apply to the next members added
+func (sortedSet *SortedSet) SetEncodings(e *ds.Encodings) {
+	sortedSet.encodings = e
+}
+
+// Changes returns the members added, removed or whose score changed
+func (sortedSet *SortedSet) Changes() *ds.Changes {
+	return &sortedSet.changes
+}
+
+// Encoding returns the encoding of the set, listpack or skiplist
+func (sortedSet *SortedSet) Encoding() string {
+	if sortedSet.packed() {
+		return EncodingListpack
+	}
+	return EncodingSkiplist
+}
+
+// score returns the score of the member
+func (sortedSet *SortedSet) score(member string) (float64, bool) {
+	if sortedSet.packed() {
+		_, score, ok := sortedSet.find(member)
+		return score, ok
+	}
+	element, ok := sortedSet.dict[member]
+	if !ok {
+		return 0, false
 	}
+	return element.Score, true
 }
 
 // Type returns the type of the data structure
@@ -39,7 +83,7 @@ func (sortedSet *SortedSet) ZAdd(member string, score float64) int64 {
 
 // ZAddXX Only update elements that already exist. Don't add new elements.
 func (sortedSet *SortedSet) ZAddXX(member string, score float64) int64 {
-	_, ok := sortedSet.dict[member]
+	_, ok := sortedSet.score(member)
 	if ok {
 		return sortedSet.zAdd(member, score)
 	}
@@ -48,7 +92,7 @@ func (sortedSet *SortedSet) ZAddXX(member string, score float64) int64 {
 
 // ZAddNX Only add new elements. Don't update already existing elements.
 func (sortedSet *SortedSet) ZAddNX(member string, score float64) int64 {
-	_, ok := sortedSet.dict[member]
+	_, ok := sortedSet.score(member)
 	if !ok {
 		return sortedSet.zAdd(member, score)
 	}
@@ -57,8 +101,8 @@ func (sortedSet *SortedSet) ZAddNX(member string, score float64) int64 {
 
 // ZAddLT add member if score less than the current score
 func (sortedSet *SortedSet) ZAddLT(member string, score float64) bool {
-	element, ok := sortedSet.dict[member]
-	if ok && element.Score > score {
+	current, ok := sortedSet.score(member)
+	if ok && current > score {
 		sortedSet.zAdd(member, score)
 		return true
 	}
@@ -67,8 +111,8 @@ func (sortedSet *SortedSet) ZAddLT(member string, score float64) bool {
 
 // ZAddGT add member if score greater than the current score
 func (sortedSet *SortedSet) ZAddGT(member string, score float64) bool {
-	element, ok := sortedSet.dict[member]
-	if ok && element.Score < score {
+	current, ok := sortedSet.score(member)
+	if ok && current < score {
 		sortedSet.zAdd(member, score)
 		return true
 	}
@@ -77,6 +121,10 @@ func (sortedSet *SortedSet) ZAddGT(member string, score float64) bool {
 
 // zAdd puts member into set,  and returns whether it has inserted new node
 func (sortedSet *SortedSet) zAdd(member string, score float64) int64 {
+	sortedSet.changes.Add(member)
+	if sortedSet.packed() {
+		return sortedSet.packedAdd(member, score)
+	}
 	element, ok := sortedSet.dict[member]
 	sortedSet.dict[member] = &Item{
 		Member: member,
@@ -95,17 +143,26 @@ func (sortedSet *SortedSet) zAdd(member string, score float64) int64 {
 
 // ZCard returns number of members in set
 func (sortedSet *SortedSet) ZCard() int64 {
+	if sortedSet.packed() {
+		return sortedSet.count
+	}
 	return int64(len(sortedSet.dict))
 }
 
 // ZRem removes the given member from set
 func (sortedSet *SortedSet) ZRem(members ...string) int64 {
+	if sortedSet.packed() {
+		return sortedSet.packedRemove(func(_ int64, item *Item) bool {
+			return slices.Contains(members, item.Member)
+		})
+	}
 	var count int64
 	for _, member := range members {
 		v, ok := sortedSet.dict[member]
 		if ok {
 			sortedSet.skiplist.remove(member, v.Score)
 			delete(sortedSet.dict, member)
+			sortedSet.changes.Add(member)
 			count++
 		}
 	}
@@ -114,6 +171,16 @@ func (sortedSet *SortedSet) ZRem(members ...string) int64 {
 
 // getRank returns the rank of the given member, sort by ascending order, rank starts from 0
 func (sortedSet *SortedSet) getRank(member string, desc bool) (rank int64) {
+	if sortedSet.packed() {
+		r, _, ok := sortedSet.find(member)
+		if !ok {
+			return -1
+		}
+		if desc {
+			r = sortedSet.count - 1 - r
+		}
+		return r
+	}
 	item, ok := sortedSet.dict[member]
 	if !ok {
 		return -1
@@ -129,7 +196,7 @@ func (sortedSet *SortedSet) getRank(member string, desc bool) (rank int64) {
 
 // ZRank returns the rank of the given member, sort by ascending order, rank starts from 0
 func (sortedSet *SortedSet) ZRank(member string) (int64, error) {
-	_, ok := sortedSet.dict[member]
+	_, ok := sortedSet.score(member)
 	if !ok {
 		return 0, errors.New("member not found")
 	}
@@ -138,16 +205,16 @@ func (sortedSet *SortedSet) ZRank(member string) (int64, error) {
 
 // ZRankWithScore returns the rank of the given member, sort by ascending order, rank starts from 0
 func (sortedSet *SortedSet) ZRankWithScore(member string) (int64, *Item) {
-	element, ok := sortedSet.dict[member]
+	score, ok := sortedSet.score(member)
 	if !ok {
 		return 0, nil
 	}
-	return sortedSet.getRank(member, false), element
+	return sortedSet.getRank(member, false), &Item{Member: member, Score: score}
 }
 
 // ZRevRank returns the rank of the given member, sort by descending order, rank starts from 0
 func (sortedSet *SortedSet) ZRevRank(member string) (int64, error) {
-	_, ok := sortedSet.dict[member]
+	_, ok := sortedSet.score(member)
 	if !ok {
 		return 0, errors.New("member not found")
 	}
@@ -156,20 +223,20 @@ func (sortedSet *SortedSet) ZRevRank(member string) (int64, error) {
 
 // ZRevRankWithScore returns the rank of the given member, sort by descending order, rank starts from 0
 func (sortedSet *SortedSet) ZRevRankWithScore(member string) (int64, *Item) {
-	element, ok := sortedSet.dict[member]
+	score, ok := sortedSet.score(member)
 	if !ok {
 		return 0, nil
 	}
-	return sortedSet.getRank(member, true), element
+	return sortedSet.getRank(member, true), &Item{Member: member, Score: score}
 }
 
 // ZScore returns the score of the given member
 func (sortedSet *SortedSet) ZScore(member string) (float64, error) {
-	element, ok := sortedSet.dict[member]
+	score, ok := sortedSet.score(member)
 	if !ok {
 		return 0, errors.New("member not found")
 	}
-	return element.Score, nil
+	return score, nil
 }
 
 // forEachByRank visits each member which rank within [start, stop], sort by ascending order, rank starts from 0
@@ -185,42 +252,25 @@ func (sortedSet *SortedSet) forEachByRank(start int64, stop int64, desc bool, co
 		return
 	}
 	if start < 0 {
-		start = size + start
+		start = max(size+start, 0)
 	}
 	// stop max is size
 	if stop >= size {
 		stop = size - 1
 	}
-	// find start node
-	var node *node
-	if desc {
-		node = sortedSet.skiplist.tail
-		if start > 1 {
-			node = sortedSet.skiplist.getByRank(size - start)
-		}
-	} else {
-		node = sortedSet.skiplist.header.level[0].forward
-		if start > 1 {
-			node = sortedSet.skiplist.getByRank(start)
-		}
-	}
-
+	c := sortedSet.byRank(start, desc)
 	sliceSize := int(stop - start)
-	for i := 0; i <= sliceSize; i++ {
-		if !consumer(&node.Item) {
+	for i := 0; i <= sliceSize && c.valid(); i++ {
+		if !consumer(c.item()) {
 			break
 		}
-		if desc {
-			node = node.backward
-		} else {
-			node = node.level[0].forward
-		}
+		c.next()
 	}
 }
 
 // rangeByRank returns members which rank within [start, stop], sort by ascending order, rank starts from 0
 func (sortedSet *SortedSet) rangeByRank(start int64, stop int64, desc bool) []*Item {
-	slice := make([]*Item, 0, stop-start+1) // allocate memory
+	slice := make([]*Item, 0, max(stop-start+1, 0)) // allocate memory
 	sortedSet.forEachByRank(start, stop, desc, func(item *Item) bool {
 		slice = append(slice, item)
 		return true
@@ -266,41 +316,23 @@ func (sortedSet *SortedSet) ZCount(min float64, max float64, mode int) int64 {
 
 // forEach visits members which score or member within the given border
 func (sortedSet *SortedSet) forEach(min float64, max float64, offset int64, limit int64, desc bool, consumer func(element *Item) bool) {
-	// find start node
-	var node *node
-	if desc {
-		node = sortedSet.skiplist.getLastInRange(min, max)
-	} else {
-		node = sortedSet.skiplist.getFirstInRange(min, max)
-	}
-
-	for node != nil && offset > 0 {
-		if desc {
-			node = node.backward
-		} else {
-			node = node.level[0].forward
-		}
+	// find start member
+	c := sortedSet.firstInRange(min, max, desc)
+	for c.valid() && offset > 0 {
+		c.next()
 		offset--
 	}
 
 	// A negative limit returns all elements from the offset
-	for i := 0; (i < int(limit) || limit < 0) && node != nil; i++ {
-		if !consumer(&node.Item) {
-			break
-		}
-		if desc {
-			node = node.backward
-		} else {
-			node = node.level[0].forward
+	for i := 0; (i < int(limit) || limit < 0) && c.valid(); i++ {
+		item := c.item()
+		if min > item.Score || max < item.Score {
+			break // break through score border
 		}
-		if node == nil {
+		if !consumer(item) {
 			break
 		}
-		gtMin := min <= node.Item.Score // greater than min
-		ltMax := max >= node.Item.Score
-		if !gtMin || !ltMax {
-			break // break through score border
-		}
+		c.next()
 	}
 }
 
@@ -310,14 +342,15 @@ func (sortedSet *SortedSet) rangeByScore(min float64, max float64, offset int64,
 	if limit == 0 || offset < 0 {
 		return make([]*Item, 0)
 	}
+	// narrow the open borders so the excluded members don't count towards offset and limit
+	if mode&MinOpen == MinOpen {
+		min = math.Nextafter(min, math.Inf(1))
+	}
+	if mode&MaxOpen == MaxOpen {
+		max = math.Nextafter(max, math.Inf(-1))
+	}
 	slice := make([]*Item, 0)
 	sortedSet.forEach(min, max, offset, limit, desc, func(element *Item) bool {
-		if mode&MinOpen == MinOpen && element.Score == min {
-			return true
-		}
-		if mode&MaxOpen == MaxOpen && element.Score == max {
-			return true
-		}
 		slice = append(slice, element)
 		return true
 	})
@@ -326,9 +359,23 @@ func (sortedSet *SortedSet) rangeByScore(min float64, max float64, offset int64,
 
 // removeRange removes members which score or member within the given border
 func (sortedSet *SortedSet) removeRange(min float64, max float64, mode int) int64 {
+	if sortedSet.packed() {
+		return sortedSet.packedRemove(func(_ int64, item *Item) bool {
+			matchMin := item.Score >= min
+			if mode&MinOpen == MinOpen {
+				matchMin = item.Score > min
+			}
+			matchMax := item.Score <= max
+			if mode&MaxOpen == MaxOpen {
+				matchMax = item.Score < max
+			}
+			return matchMin && matchMax
+		})
+	}
 	removed := sortedSet.skiplist.removeRange(min, max, 0, mode)
 	for _, element := range removed {
 		delete(sortedSet.dict, element.Member)
+		sortedSet.changes.Add(element.Member)
 	}
 	return int64(len(removed))
 }
@@ -341,25 +388,35 @@ func (sortedSet *SortedSet) ZRemRangeByScore(min float64, max float64, mode int)
 // ZRemRangeByRank removes member ranking within [start, stop]
 // sort by ascending order and rank starts from 0
 func (sortedSet *SortedSet) ZRemRangeByRank(start int64, stop int64) int64 {
+	size := sortedSet.ZCard()
 	if stop < 0 {
-		stop = sortedSet.ZCard() + stop
+		stop = size + stop
 	}
 	if start < 0 {
-		start = sortedSet.ZCard() + start
+		start = max(size+start, 0)
+	}
+	if stop >= size {
+		stop = size - 1
 	}
-	if start >= stop || start < 0 {
+	if start > stop {
 		return 0
 	}
-	removed := sortedSet.skiplist.removeRangeByRank(start, stop)
+	if sortedSet.packed() {
+		return sortedSet.packedRemove(func(rank int64, _ *Item) bool {
+			return rank >= start && rank <= stop
+		})
+	}
+	removed := sortedSet.skiplist.removeRangeByRank(start+1, stop+1)
 	for _, element := range removed {
 		delete(sortedSet.dict, element.Member)
+		sortedSet.changes.Add(element.Member)
 	}
 	return int64(len(removed))
 }
 
 // ZExists returns whether the given member exists in set
 func (sortedSet *SortedSet) ZExists(member string) bool {
-	_, ok := sortedSet.dict[member]
+	_, ok := sortedSet.score(member)
 	return ok
 }
 
@@ -373,11 +430,77 @@ func (sortedSet *SortedSet) ZRevRangeByScore(min float64, max float64, offset, c
 	return sortedSet.rangeByScore(min, max, offset, count, true, mode)
 }
 
+// rangeByLex returns members within the lexicographical range
+// param limit: <0 means no limit
+func (sortedSet *SortedSet) rangeByLex(r *LexRange, offset int64, limit int64, desc bool) []*Item {
+	slice := make([]*Item, 0)
+	if limit == 0 || offset < 0 {
+		return slice
+	}
+	c := sortedSet.firstInLexRange(r, desc)
+	for ; c.valid() && (limit < 0 || int64(len(slice)) < limit); offset-- {
+		item := c.item()
+		if (desc && !r.gteMin(item.Member)) || (!desc && !r.lteMax(item.Member)) {
+			break
+		}
+		if offset <= 0 {
+			slice = append(slice, item)
+		}
+		c.next()
+	}
+	return slice
+}
+
+// ZRangeByLex returns members within the lexicographical range in ascending order
+func (sortedSet *SortedSet) ZRangeByLex(r *LexRange, offset, count int64) []*Item {
+	return sortedSet.rangeByLex(r, offset, count, false)
+}
+
+// ZRevRangeByLex returns members within the lexicographical range in descending order
+func (sortedSet *SortedSet) ZRevRangeByLex(r *LexRange, offset, count int64) []*Item {
+	return sortedSet.rangeByLex(r, offset, count, true)
+}
+
+// ZLexCount returns the number of members within the lexicographical range
+func (sortedSet *SortedSet) ZLexCount(r *LexRange) int64 {
+	if sortedSet.packed() {
+		var count int64
+		for c := sortedSet.firstInLexRange(r, false); c.valid() && r.lteMax(c.item().Member); c.next() {
+			count++
+		}
+		return count
+	}
+	first := sortedSet.skiplist.getFirstInLexRange(r)
+	if first == nil {
+		return 0
+	}
+	last := sortedSet.skiplist.getLastInLexRange(r)
+	return sortedSet.skiplist.getRank(last.Member, last.Score) - sortedSet.skiplist.getRank(first.Member, first.Score) + 1
+}
+
+// ZRemRangeByLex removes members within the lexicographical range
+func (sortedSet *SortedSet) ZRemRangeByLex(r *LexRange) int64 {
+	if sortedSet.packed() {
+		if r.empty() {
+			return 0
+		}
+		return sortedSet.packedRemove(func(_ int64, item *Item) bool {
+			return r.gteMin(item.Member) && r.lteMax(item.Member)
+		})
+	}
+	removed := sortedSet.skiplist.removeRangeByLex(r)
+	for _, element := range removed {
+		delete(sortedSet.dict, element.Member)
+		sortedSet.changes.Add(element.Member)
+	}
+	return int64(len(removed))
+}
+
 // ZIncrBy increases the score of the given member
 func (sortedSet *SortedSet) ZIncrBy(member string, score float64) float64 {
-	element, ok := sortedSet.dict[member]
+	current, ok := sortedSet.score(member)
 	if ok {
-		score += element.Score
+		score += current
 	}
 	sortedSet.zAdd(member, score)
 	return score
@@ -385,12 +508,72 @@ func (sortedSet *SortedSet) ZIncrBy(member string, score float64) float64 {
 
 // ZMax returns the member with the highest score
 func (sortedSet *SortedSet) ZMax() *Item {
-	return &sortedSet.skiplist.tail.Item
+	return sortedSet.byRank(0, true).item()
 }
 
 // ZMin returns the member with the lowest score
 func (sortedSet *SortedSet) ZMin() *Item {
-	return &sortedSet.skiplist.header.level[0].forward.Item
+	return sortedSet.byRank(0, false).item()
+}
+
+// ZPopMin removes and returns up to count members with the lowest scores
+func (sortedSet *SortedSet) ZPopMin(count int64) []*Item {
+	return sortedSet.pop(count, false)
+}
+
+// ZPopMax removes and returns up to count members with the highest scores
+func (sortedSet *SortedSet) ZPopMax(count int64) []*Item {
+	return sortedSet.pop(count, true)
+}
+
+func (sortedSet *SortedSet) pop(count int64, desc bool) []*Item {
+	if count <= 0 || sortedSet.ZCard() == 0 {
+		return nil
+	}
+	items := make([]*Item, 0, min(count, sortedSet.ZCard()))
+	sortedSet.forEachByRank(0, count-1, desc, func(item *Item) bool {
+		items = append(items, &Item{Member: item.Member, Score: item.Score})
+		return true
+	})
+	for _, item := range items {
+		sortedSet.ZRem(item.Member)
+	}
+	return items
+}
+
+// ZRandMember returns count random members, the members are distinct if count is positive
+// and may be repeated if count is negative
+func (sortedSet *SortedSet) ZRandMember(count int64) []*Item {
+	size := sortedSet.ZCard()
+	if count == 0 || size == 0 {
+		return nil
+	}
+	// at returns the member at rank, the listpack is decoded once
+	var at func(rank int64) *Item
+	if sortedSet.packed() {
+		packed := sortedSet.items()
+		at = func(rank int64) *Item {
+			return &packed[rank]
+		}
+	} else {
+		at = func(rank int64) *Item {
+			return &sortedSet.skiplist.getByRank(rank + 1).Item
+		}
+	}
+	if count < 0 {
+		items := make([]*Item, 0, -count)
+		for ; count < 0; count++ {
+			item := at(rand.Int63n(size))
+			items = append(items, &Item{Member: item.Member, Score: item.Score})
+		}
+		return items
+	}
+	items := make([]*Item, 0, min(count, size))
+	for _, i := range rand.Perm(int(size))[:min(count, size)] {
+		item := at(int64(i))
+		items = append(items, &Item{Member: item.Member, Score: item.Score})
+	}
+	return items
 }
 
 // ZScan returns members which score or member within the given border
@@ -412,9 +595,9 @@ func (sortedSet *SortedSet) ZScan(cursor int64, match string, count int64) (int6
 }
 
 func (sortedSet *SortedSet) GetValue() []byte {
-	var keyScores = make([]byte, 0, len(sortedSet.dict))
-	for _, v := range sortedSet.dict {
-		data := v.encode()
+	var keyScores = make([]byte, 0, sortedSet.ZCard())
+	for c := sortedSet.byRank(0, false); c.valid(); c.next() {
+		data := c.item().encode()
 		dataLen := len(data)
 		var b = make([]byte, 8+dataLen)
 		n := binary.PutVarint(b, int64(dataLen))
@@ -425,7 +608,7 @@ func (sortedSet *SortedSet) GetValue() []byte {
 }
 
 func (sortedSet *SortedSet) SetValue(keyScores []byte) {
-	sortedSet.skiplist = makeSkiplist()
+	items := make([]Item, 0)
 	for {
 		if len(keyScores) == 0 {
 			break
@@ -435,8 +618,9 @@ func (sortedSet *SortedSet) SetValue(keyScores []byte) {
 			break
 		}
 		end := n + int(dataLen)
-		item := decodeItem(keyScores[n:end])
-		sortedSet.zAdd(item.Member, item.Score)
+		items = append(items, *decodeItem(keyScores[n:end]))
 		keyScores = keyScores[end:]
 	}
+	slices.SortFunc(items, compareItems)
+	sortedSet.pack(items)
 }
@@ -3,7 +3,11 @@ package zset
 import (
 	"fmt"
 	"math"
+	"math/rand"
+	"strconv"
 	"testing"
+
+	"github.com/diiyw/nodis/ds"
 )
 
 func TestSortedSet_ZAdd(t *testing.T) {
@@ -248,10 +252,254 @@ func TestGetSetValue(t *testing.T) {
 	v := ss.GetValue()
 	ss2 := NewSortedSet()
 	ss2.SetValue(v)
-	if len(ss2.dict) != 3 {
-		t.Errorf("Value error expected 3 got %d", len(ss2.dict))
+	if ss2.ZCard() != 3 {
+		t.Errorf("Value error expected 3 got %d", ss2.ZCard())
 	}
 	for _, item := range ss.ZRange(0, -1) {
 		fmt.Println(item.Member, item.Score)
 	}
 }
+
+func lexMembers(items []*Item) []string {
+	members := make([]string, 0, len(items))
+	for _, item := range items {
+		members = append(members, item.Member)
+	}
+	return members
+}
+
+func TestSortedSet_ZRangeByLex(t *testing.T) {
+	ss := NewSortedSet()
+	for _, m := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		ss.ZAdd(m, 0)
+	}
+	tests := []struct {
+		min, max      string
+		offset, count int64
+		want          string
+		wantRev       string
+		wantCount     int64
+	}{
+		{"-", "[c", 0, -1, "[a b c]", "[c b a]", 3},
+		{"-", "(c", 0, -1, "[a b]", "[b a]", 2},
+		{"[aaa", "(g", 0, -1, "[b c d e f]", "[f e d c b]", 5},
+		{"(b", "+", 1, 2, "[d e]", "[f e]", 5},
+		{"-", "+", 0, -1, "[a b c d e f g]", "[g f e d c b a]", 7},
+		{"+", "-", 0, -1, "[]", "[]", 0},
+		{"[c", "[c", 0, -1, "[c]", "[c]", 1},
+		{"(c", "[c", 0, -1, "[]", "[]", 0},
+		{"[x", "[z", 0, -1, "[]", "[]", 0},
+	}
+	for _, tt := range tests {
+		r, err := ParseLexRange(tt.min, tt.max)
+		if err != nil {
+			t.Fatalf("ParseLexRange(%s, %s) error = %v", tt.min, tt.max, err)
+		}
+		if got := fmt.Sprint(lexMembers(ss.ZRangeByLex(r, tt.offset, tt.count))); got != tt.want {
+			t.Errorf("ZRangeByLex(%s, %s) = %v, want %v", tt.min, tt.max, got, tt.want)
+		}
+		if got := fmt.Sprint(lexMembers(ss.ZRevRangeByLex(r, tt.offset, tt.count))); got != tt.wantRev {
+			t.Errorf("ZRevRangeByLex(%s, %s) = %v, want %v", tt.min, tt.max, got, tt.wantRev)
+		}
+		if got := ss.ZLexCount(r); got != tt.wantCount {
+			t.Errorf("ZLexCount(%s, %s) = %v, want %v", tt.min, tt.max, got, tt.wantCount)
+		}
+	}
+	for _, border := range []string{"", "a", "{a"} {
+		if _, err := ParseLexRange(border, "+"); err != ErrInvalidLexRange {
+			t.Errorf("ParseLexRange(%q) error = %v, want %v", border, err, ErrInvalidLexRange)
+		}
+	}
+}
+
+func TestSortedSet_ZRemRangeByLex(t *testing.T) {
+	ss := NewSortedSet()
+	for _, m := range []string{"a", "b", "c", "d"} {
+		ss.ZAdd(m, 0)
+	}
+	r, _ := ParseLexRange("(a", "[c")
+	if got := ss.ZRemRangeByLex(r); got != 2 {
+		t.Errorf("ZRemRangeByLex() = %v, want %v", got, 2)
+	}
+	if ss.ZCard() != 2 || ss.ZExists("b") || !ss.ZExists("d") {
+		t.Errorf("ZRemRangeByLex() removed wrong members")
+	}
+}
+
+func TestSortedSet_ZPop(t *testing.T) {
+	ss := NewSortedSet()
+	ss.ZAdd("a", 1)
+	ss.ZAdd("b", 2)
+	ss.ZAdd("c", 3)
+	items := ss.ZPopMin(2)
+	if len(items) != 2 || items[0].Member != "a" || items[1].Member != "b" {
+		t.Errorf("ZPopMin() = %v", items)
+	}
+	items = ss.ZPopMax(5)
+	if len(items) != 1 || items[0].Member != "c" || items[0].Score != 3 {
+		t.Errorf("ZPopMax() = %v", items)
+	}
+	if ss.ZCard() != 0 || ss.ZPopMin(1) != nil {
+		t.Errorf("ZPopMin() on an empty set failed")
+	}
+}
+
+func TestSortedSet_ZRandMember(t *testing.T) {
+	ss := NewSortedSet()
+	ss.ZAdd("a", 1)
+	ss.ZAdd("b", 2)
+	ss.ZAdd("c", 3)
+	items := ss.ZRandMember(5)
+	if len(items) != 3 {
+		t.Errorf("ZRandMember() = %v, want %v members", len(items), 3)
+	}
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if seen[item.Member] {
+			t.Errorf("ZRandMember() returned %v twice", item.Member)
+		}
+		seen[item.Member] = true
+		if score, _ := ss.ZScore(item.Member); score != item.Score {
+			t.Errorf("ZRandMember() score = %v, want %v", item.Score, score)
+		}
+	}
+	if items := ss.ZRandMember(-10); len(items) != 10 {
+		t.Errorf("ZRandMember() = %v, want %v members", len(items), 10)
+	}
+	if ss.ZRandMember(0) != nil || NewSortedSet().ZRandMember(-1) != nil {
+		t.Errorf("ZRandMember() should return nil")
+	}
+}
+
+func TestSortedSet_Encoding(t *testing.T) {
+	e := ds.NewEncodings(ds.DefaultEncodingLimits)
+	ss := NewSortedSetWithEncodings(e)
+	ss.ZAdd("a", 1)
+	if ss.Encoding() != EncodingListpack {
+		t.Errorf("Encoding() = %s, want %s", ss.Encoding(), EncodingListpack)
+	}
+	e.SetLimit("zset-max-listpack-entries", 2)
+	ss.ZAdd("b", 2)
+	ss.ZAdd("c", 3)
+	if ss.Encoding() != EncodingSkiplist || ss.ZCard() != 3 || ss.ZMin().Member != "a" || ss.ZMax().Member != "c" {
+		t.Errorf("ZAdd() over the entries limit = %s %v", ss.Encoding(), lexMembers(ss.ZRange(0, -1)))
+	}
+	ss = NewSortedSet()
+	ss.ZAdd(string(make([]byte, 65)), 1)
+	if ss.Encoding() != EncodingSkiplist {
+		t.Errorf("ZAdd() over the value limit = %s", ss.Encoding())
+	}
+}
+
+// TestSortedSet_EncodingsMatch runs the same commands on a listpack and a skiplist
+func TestSortedSet_EncodingsMatch(t *testing.T) {
+	// the zero limits encode every set as a skiplist
+	unpacked := ds.NewEncodings(ds.EncodingLimits{})
+	packed := NewSortedSet()
+	skiplist := NewSortedSetWithEncodings(unpacked)
+	skiplist.ZAdd("m0", 0)
+	packed.ZAdd("m0", 0)
+
+	dump := func(items []*Item) string {
+		return fmt.Sprint(len(items), " ", func() []string {
+			s := make([]string, 0, len(items))
+			for _, item := range items {
+				s = append(s, item.Member+":"+strconv.FormatFloat(item.Score, 'g', -1, 64))
+			}
+			return s
+		}())
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		member := "m" + strconv.Itoa(r.Intn(40))
+		score := float64(r.Intn(20))
+		start, stop := int64(r.Intn(50)-10), int64(r.Intn(50)-10)
+		lex, _ := ParseLexRange("[m"+strconv.Itoa(r.Intn(40)), "(m"+strconv.Itoa(r.Intn(40)))
+		var got, want string
+		switch r.Intn(12) {
+		case 0, 1, 2:
+			got, want = fmt.Sprint(packed.ZAdd(member, score)), fmt.Sprint(skiplist.ZAdd(member, score))
+		case 3:
+			got, want = fmt.Sprint(packed.ZRem(member)), fmt.Sprint(skiplist.ZRem(member))
+		case 4:
+			got, want = dump(packed.ZRange(start, stop)), dump(skiplist.ZRange(start, stop))
+		case 5:
+			got, want = dump(packed.ZRevRange(start, stop)), dump(skiplist.ZRevRange(start, stop))
+		case 6:
+			got = dump(packed.ZRangeByScore(score, score+5, start, stop, MinOpen))
+			want = dump(skiplist.ZRangeByScore(score, score+5, start, stop, MinOpen))
+		case 7:
+			got = dump(packed.ZRevRangeByScore(score, score+5, 0, -1, MaxOpen))
+			want = dump(skiplist.ZRevRangeByScore(score, score+5, 0, -1, MaxOpen))
+		case 8:
+			// the lexicographical ranges need the members to have the same score
+			pz, sz := NewSortedSet(), NewSortedSetWithEncodings(unpacked)
+			for _, item := range packed.ZRange(0, -1) {
+				pz.ZAdd(item.Member, 0)
+			}
+			for _, item := range skiplist.ZRange(0, -1) {
+				sz.ZAdd(item.Member, 0)
+			}
+			got = dump(pz.ZRangeByLex(lex, 0, -1)) + dump(pz.ZRevRangeByLex(lex, 1, 3)) + fmt.Sprint(pz.ZLexCount(lex))
+			want = dump(sz.ZRangeByLex(lex, 0, -1)) + dump(sz.ZRevRangeByLex(lex, 1, 3)) + fmt.Sprint(sz.ZLexCount(lex))
+			if sz.ZCard() > 0 && sz.Encoding() != EncodingSkiplist {
+				t.Fatalf("Encoding() = %s, want %s", sz.Encoding(), EncodingSkiplist)
+			}
+		case 9:
+			pr, _ := packed.ZRevRank(member)
+			sr, _ := skiplist.ZRevRank(member)
+			ps, _ := packed.ZScore(member)
+			ss, _ := skiplist.ZScore(member)
+			got, want = fmt.Sprint(pr, ps), fmt.Sprint(sr, ss)
+		case 10:
+			if r.Intn(4) == 0 {
+				got, want = fmt.Sprint(packed.ZRemRangeByRank(start, stop)), fmt.Sprint(skiplist.ZRemRangeByRank(start, stop))
+			}
+		case 11:
+			got, want = dump(packed.ZPopMin(2)), dump(skiplist.ZPopMin(2))
+		}
+		if got != want {
+			t.Fatalf("step %d: listpack = %s, skiplist = %s", i, got, want)
+		}
+	}
+	if packed.Encoding() != EncodingListpack || skiplist.Encoding() != EncodingSkiplist {
+		t.Fatalf("encodings = %s %s", packed.Encoding(), skiplist.Encoding())
+	}
+	if got, want := dump(packed.ZRange(0, -1)), dump(skiplist.ZRange(0, -1)); got != want {
+		t.Errorf("listpack = %s, skiplist = %s", got, want)
+	}
+}
+
+func TestSortedSet_ZRemRangeByRank2(t *testing.T) {
+	for _, ss := range []*SortedSet{NewSortedSet(), NewSortedSetWithEncodings(ds.NewEncodings(ds.EncodingLimits{}))} {
+		for i, m := range []string{"a", "b", "c", "d"} {
+			ss.ZAdd(m, float64(i))
+		}
+		if got := ss.ZRemRangeByRank(1, 2); got != 2 || fmt.Sprint(lexMembers(ss.ZRange(0, -1))) != "[a d]" {
+			t.Errorf("ZRemRangeByRank(1, 2) = %d %v", got, lexMembers(ss.ZRange(0, -1)))
+		}
+		if got := ss.ZRemRangeByRank(-1, -1); got != 1 || fmt.Sprint(lexMembers(ss.ZRange(0, -1))) != "[a]" {
+			t.Errorf("ZRemRangeByRank(-1, -1) = %d %v", got, lexMembers(ss.ZRange(0, -1)))
+		}
+		if got := ss.ZRemRangeByRank(0, 0); got != 1 || ss.ZCard() != 0 {
+			t.Errorf("ZRemRangeByRank(0, 0) = %d", got)
+		}
+	}
+}
+
+func TestSortedSet_Changes(t *testing.T) {
+	for _, size := range []int{3, 1000} {
+		ss := NewSortedSet()
+		for i := 0; i < size; i++ {
+			ss.ZAdd("m"+strconv.Itoa(i), float64(i))
+		}
+		ss.Changes().Track("key")
+		ss.ZIncrBy("m0", 1)
+		ss.ZRem("m1")
+		ss.ZPopMax(1)
+		ss.ZScore("m2")
+		if fields := ss.Changes().Fields(); len(fields) != 3 {
+			t.Errorf("Changes() = %v, want [m0 m1 m%d] for %s", fields, size-1, ss.Encoding())
+		}
+	}
+}
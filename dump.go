@@ -0,0 +1,229 @@
+package nodis
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/patch"
+	"github.com/diiyw/nodis/rdb"
+	"github.com/diiyw/nodis/redis"
+)
+
+var (
+	ErrBusyKey        = errors.New("BUSYKEY Target key name already exists.")
+	ErrDumpPayload    = errors.New("ERR DUMP payload version or checksum are wrong")
+	ErrBadDataFormat  = errors.New("ERR Bad data format")
+	ErrMigrateConnect = errors.New("IOERR error or timeout connecting to the client")
+	ErrMigrateWrite   = errors.New("IOERR error or timeout writing to target instance")
+	ErrMigrateRead    = errors.New("IOERR error or timeout reading to target instance")
+)
+
+// Dump serializes the value of the key like the Redis DUMP command, the payload can be restored
+// by Restore or by Redis. It's nil if the key doesn't exist.
+func (n *Nodis) Dump(key string) []byte {
+	var v []byte
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		v, _ = rdb.Dump(meta.value)
+		return nil
+	})
+	return v
+}
+
+// RestoreOptions are the options of Restore
+type RestoreOptions struct {
+	// Replace replaces the existing key, Restore fails with ErrBusyKey otherwise
+	Replace bool
+	// ExpireAt is the unix time in milliseconds when the key expires, 0 for no expiration.
+	// The key isn't created if ExpireAt is in the past.
+	ExpireAt int64
+	// IdleTime is the idle time of the restored key like in OBJECT IDLETIME
+	IdleTime time.Duration
+	// SetFreq sets the access frequency of the restored key to Freq like in OBJECT FREQ
+	SetFreq bool
+	Freq    uint8
+}
+
+// Restore creates the key from a payload of Dump or of the Redis DUMP command
+func (n *Nodis) Restore(key string, payload []byte, opts *RestoreOptions) error {
+	if opts == nil {
+		opts = &RestoreOptions{}
+	}
+	return n.exec(func(tx *Tx) error {
+		meta := tx.writeKey(key, nil)
+		if meta.isOk() && !opts.Replace {
+			return ErrBusyKey
+		}
+		value, err := rdb.Restore(payload, n.listOptions(), n.store.encodings)
+		if err != nil {
+			if errors.Is(err, rdb.ErrBadPayload) {
+				return ErrDumpPayload
+			}
+			return ErrBadDataFormat
+		}
+		n.putValue(tx, key, meta, value, opts, payload)
+		return nil
+	})
+}
+
+// putValue stores the value of the restored key, the key is deleted if the expiration is in the past.
+// The payload of the patch is dumped from the value if it's nil.
+func (n *Nodis) putValue(tx *Tx, key string, meta *metadata, value ds.Value, opts *RestoreOptions, payload []byte) {
+	if opts.ExpireAt > 0 && opts.ExpireAt <= time.Now().UnixMilli() {
+		// the key is already expired
+		if meta.isOk() {
+			tx.delKey(key)
+			if meta.valueType == ds.Hash {
+				n.indexHash(key, nil)
+			}
+			n.notify(func() []patch.Op {
+				return []patch.Op{{Type: patch.OpTypeDel, Data: &patch.OpDel{Key: key}}}
+			})
+		}
+		return
+	}
+	if meta.isOk() {
+		tx.resetMeta(meta, func() ds.Value { return value })
+	} else {
+		meta = tx.newStoredMetadata(meta, func() ds.Value { return value })
+	}
+	meta.key.Expiration = opts.ExpireAt
+	meta.access.Store(time.Now().Add(-opts.IdleTime).UnixMilli())
+	if opts.SetFreq {
+		meta.freq.Store(uint32(opts.Freq))
+	}
+	h, _ := value.(*hash.HashMap)
+	n.indexHash(key, h)
+	n.signalModifiedKey(key, meta)
+	if payload == nil && len(n.listeners) > 0 {
+		// the value may change once the key is unlocked, it's dumped now
+		payload, _ = rdb.Dump(value)
+	}
+	n.notify(func() []patch.Op {
+		return []patch.Op{{Type: patch.OpTypeRestore, Data: &patch.OpRestore{Key: key, Payload: payload, Expiration: opts.ExpireAt}}}
+	})
+}
+
+// MigrateOptions are the options of Migrate
+type MigrateOptions struct {
+	// DB is the database of the destination, it's selected if it isn't 0
+	DB int64
+	// Timeout is the timeout of the connection and of every exchange with the destination, 1s if not positive
+	Timeout time.Duration
+	// Copy keeps the keys, they are deleted once restored by the destination otherwise
+	Copy bool
+	// Replace replaces the existing keys of the destination
+	Replace bool
+	// Username and Password authenticate to the destination if Password is set
+	Username string
+	Password string
+}
+
+// Migrate moves the keys to the nodis or Redis server at addr with RESTORE commands. It returns false
+// if none of the keys exists. The keys restored by the destination are deleted even if another one fails.
+func (n *Nodis) Migrate(addr string, keys []string, opts *MigrateOptions) (bool, error) {
+	if opts == nil {
+		opts = &MigrateOptions{}
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	var migrated []string
+	var payloads [][]byte
+	var ttls []int64
+	now := time.Now().UnixMilli()
+	_ = n.exec(func(tx *Tx) error {
+		for _, key := range keys {
+			meta := tx.readKey(key)
+			if !meta.isOk() {
+				continue
+			}
+			payload, err := rdb.Dump(meta.value)
+			if err != nil {
+				continue
+			}
+			var ttl int64
+			if meta.key.Expiration > 0 {
+				ttl = max(meta.key.Expiration-now, 1)
+			}
+			migrated = append(migrated, key)
+			payloads = append(payloads, payload)
+			ttls = append(ttls, ttl)
+		}
+		return nil
+	})
+	if len(migrated) == 0 {
+		return false, nil
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return false, ErrMigrateConnect
+	}
+	defer conn.Close()
+	w := redis.NewWriter(conn)
+	writeCommand := func(args ...string) {
+		w.WriteArray(len(args))
+		for _, arg := range args {
+			w.WriteBulk(arg)
+		}
+	}
+	var replies int
+	if opts.Password != "" {
+		if opts.Username != "" {
+			writeCommand("AUTH", opts.Username, opts.Password)
+		} else {
+			writeCommand("AUTH", opts.Password)
+		}
+		replies++
+	}
+	if opts.DB != 0 {
+		writeCommand("SELECT", strconv.FormatInt(opts.DB, 10))
+		replies++
+	}
+	for i, key := range migrated {
+		args := []string{"RESTORE", key, strconv.FormatInt(ttls[i], 10), string(payloads[i])}
+		if opts.Replace {
+			args = append(args, "REPLACE")
+		}
+		writeCommand(args...)
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	if err = w.Push(); err != nil {
+		return false, ErrMigrateWrite
+	}
+
+	r := redis.NewReader(conn)
+	var restored []string
+	var replyErr error
+	for i := 0; i < replies+len(migrated); i++ {
+		_ = conn.SetDeadline(time.Now().Add(timeout))
+		if _, err = r.ReadReply(); err != nil {
+			var e redis.ReplyError
+			if !errors.As(err, &e) {
+				replyErr = ErrMigrateRead
+				break
+			}
+			if replyErr == nil {
+				replyErr = fmt.Errorf("ERR Target instance replied with error: %s", e)
+			}
+			continue
+		}
+		if i >= replies {
+			restored = append(restored, migrated[i-replies])
+		}
+	}
+	if !opts.Copy && len(restored) > 0 {
+		n.Del(restored...)
+	}
+	return true, replyErr
+}
@@ -0,0 +1,121 @@
+package nodis
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDump_Restore(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.RPush("list", []byte("a"), []byte("b"))
+	n.HSet("hash", "f", []byte("v"))
+	payload := n.Dump("list")
+	if payload == nil {
+		t.Fatalf("Dump() = nil")
+	}
+	if n.Dump("missing") != nil {
+		t.Errorf("Dump() = not nil on a missing key")
+	}
+	if err := n.Restore("list", payload, nil); err != ErrBusyKey {
+		t.Errorf("Restore() error = %v, want %v", err, ErrBusyKey)
+	}
+	at := time.Now().Add(time.Hour).UnixMilli()
+	if err := n.Restore("copy", payload, &RestoreOptions{ExpireAt: at, IdleTime: time.Minute, SetFreq: true, Freq: 100}); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if idle, _ := n.ObjectIdleTime("copy"); idle < time.Minute {
+		t.Errorf("ObjectIdleTime() = %v, want >= 1m", idle)
+	}
+	if freq, _ := n.ObjectFreq("copy"); freq != 99 {
+		// it decays by one for the idle minute
+		t.Errorf("ObjectFreq() = %v, want %v", freq, 99)
+	}
+	if v := n.LRange("copy", 0, -1); len(v) != 2 || string(v[1]) != "b" {
+		t.Errorf("LRange() = %v, want [a b]", v)
+	}
+	if v := n.PExpireTime("copy"); v != at {
+		t.Errorf("PExpireTime() = %v, want %v", v, at)
+	}
+	if err := n.Restore("list", n.Dump("hash"), &RestoreOptions{Replace: true}); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if v := n.HGet("list", "f"); string(v) != "v" {
+		t.Errorf("HGet() = %s, want %s", v, "v")
+	}
+	if err := n.Restore("list", payload, &RestoreOptions{Replace: true, ExpireAt: 1}); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if n.Exists("list") != 0 {
+		t.Errorf("Exists() = 1, want the key deleted by the past expiration")
+	}
+	payload[0] = 0x42
+	if err := n.Restore("bad", payload, nil); err != ErrDumpPayload {
+		t.Errorf("Restore() error = %v, want %v", err, ErrDumpPayload)
+	}
+}
+
+// serve serves n on a free local port and returns its address
+func serve(t *testing.T, n *Nodis) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	go func() {
+		_ = n.Serve(addr)
+	}()
+	for i := 0; i < 100; i++ {
+		if c, err := net.Dial("tcp", addr); err == nil {
+			_ = c.Close()
+			return addr
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("the server isn't listening on %s", addr)
+	return ""
+}
+
+func TestMigrate(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	dst := Open(&Options{})
+	addr := serve(t, dst)
+	n.SetEX("a", []byte("1"), 100)
+	n.SAdd("b", "x", "y")
+	n.Set("c", []byte("3"), false)
+	dst.Set("c", []byte("old"), false)
+	ok, err := n.Migrate(addr, []string{"a", "b", "c", "missing"}, nil)
+	if !ok || err == nil || !strings.HasPrefix(err.Error(), "ERR Target instance replied with error: BUSYKEY") {
+		t.Fatalf("Migrate() = %v, %v, want a BUSYKEY error", ok, err)
+	}
+	if v := dst.Get("a"); string(v) != "1" || dst.TTL("a") <= 0 {
+		t.Errorf("Get() = %s with TTL %v, want 1 with a TTL", v, dst.TTL("a"))
+	}
+	if dst.SCard("b") != 2 {
+		t.Errorf("SCard() = %v, want %v", dst.SCard("b"), 2)
+	}
+	if n.Exists("a", "b", "c") != 1 {
+		t.Errorf("Exists() = %v, want only the key not restored left", n.Exists("a", "b", "c"))
+	}
+	ok, err = n.Migrate(addr, []string{"c"}, &MigrateOptions{Copy: true, Replace: true})
+	if !ok || err != nil {
+		t.Fatalf("Migrate() = %v, %v", ok, err)
+	}
+	if v := dst.Get("c"); string(v) != "3" || n.Exists("c") != 1 {
+		t.Errorf("Get() = %s, want %s and the key copied", v, "3")
+	}
+	if ok, err = n.Migrate(addr, []string{"missing"}, nil); ok || err != nil {
+		t.Errorf("Migrate() = %v, %v, want false", ok, err)
+	}
+	if _, err = n.Migrate(addr, []string{"c"}, &MigrateOptions{DB: 1}); err == nil {
+		t.Errorf("Migrate() = nil error, want the SELECT error")
+	}
+	if _, err = n.Migrate("127.0.0.1:1", []string{"c"}, nil); err != ErrMigrateConnect {
+		t.Errorf("Migrate() error = %v, want %v", err, ErrMigrateConnect)
+	}
+}
@@ -0,0 +1,364 @@
+package nodis
+
+import (
+	"errors"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/ds/set"
+	"github.com/diiyw/nodis/ds/str"
+	"github.com/diiyw/nodis/ds/timeseries"
+	"github.com/diiyw/nodis/ds/vector"
+	"github.com/diiyw/nodis/ds/zset"
+	"github.com/diiyw/nodis/storage"
+)
+
+// EvictionPolicy chooses the keys whose values leave the memory when Options.MaxMemory is
+// exceeded. The values are offloaded to the storage, or deleted if the storage is in memory and
+// not persisted.
+type EvictionPolicy uint8
+
+const (
+	// NoEviction keeps the values, the commands adding data fail with ErrOOM
+	NoEviction EvictionPolicy = iota
+	// AllKeysLRU evicts the keys accessed the least recently
+	AllKeysLRU
+	// AllKeysLFU evicts the keys accessed the least frequently
+	AllKeysLFU
+	// VolatileLRU evicts the keys with an expiration accessed the least recently
+	VolatileLRU
+	// VolatileTTL evicts the keys expiring the soonest
+	VolatileTTL
+)
+
+const (
+	// evictionSamples is the number of the keys sampled to find the one to evict, like the
+	// maxmemory-samples of Redis, and the number of the elements sampled to estimate the size
+	// of a collection
+	evictionSamples = 5
+	// keyOverhead is the estimated size of the metadata of a key, elementOverhead the one of an
+	// element of a collection
+	keyOverhead     = 64
+	elementOverhead = 16
+)
+
+var (
+	ErrUnknownEvictionPolicy = errors.New("unknown eviction policy")
+	ErrOOM                   = errors.New("OOM command not allowed when used memory > 'maxmemory'.")
+)
+
+var evictionPolicyNames = []string{"noeviction", "allkeys-lru", "allkeys-lfu", "volatile-lru", "volatile-ttl"}
+
+func (p EvictionPolicy) String() string {
+	if int(p) < len(evictionPolicyNames) {
+		return evictionPolicyNames[p]
+	}
+	return "unknown"
+}
+
+// ParseEvictionPolicy returns the policy of the name: noeviction, allkeys-lru, allkeys-lfu,
+// volatile-lru or volatile-ttl
+func ParseEvictionPolicy(name string) (EvictionPolicy, error) {
+	if name == "" {
+		return NoEviction, nil
+	}
+	for i, n := range evictionPolicyNames {
+		if n == strings.ToLower(name) {
+			return EvictionPolicy(i), nil
+		}
+	}
+	return NoEviction, ErrUnknownEvictionPolicy
+}
+
+// ParseMemory parses a size of memory in bytes like 1024, 100kb, 64mb or 1gb
+func ParseMemory(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"gb", FileSizeGB}, {"mb", FileSizeMB}, {"kb", FileSizeKB}, {"b", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSuffix(s, u.suffix), u.size
+			break
+		}
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v < 0 || v > math.MaxInt64/unit {
+		return 0, strconv.ErrSyntax
+	}
+	return v * unit, nil
+}
+
+// formatMemory formats a size of memory like the _human fields of INFO
+func formatMemory(v int64) string {
+	switch {
+	case v >= FileSizeGB:
+		return strconv.FormatFloat(float64(v)/FileSizeGB, 'f', 2, 64) + "G"
+	case v >= FileSizeMB:
+		return strconv.FormatFloat(float64(v)/FileSizeMB, 'f', 2, 64) + "M"
+	case v >= FileSizeKB:
+		return strconv.FormatFloat(float64(v)/FileSizeKB, 'f', 2, 64) + "K"
+	}
+	return strconv.FormatInt(v, 10) + "B"
+}
+
+// estimateSize estimates the memory used by the key and its value, the size of the elements of
+// a collection is estimated from a sample of them
+func estimateSize(m *metadata) int64 {
+	size := int64(keyOverhead + len(m.key.Name))
+	var sampled, samples, count int64
+	switch v := m.value.(type) {
+	case nil:
+		return size
+	case *str.String:
+		return size + int64(len(v.Get()))
+	case *hash.HashMap:
+		count = v.HLen()
+		v.Iter(func(field string, value []byte) bool {
+			sampled += int64(len(field) + len(value))
+			samples++
+			return samples < evictionSamples
+		})
+	case *set.Set:
+		count = v.SCard()
+		v.Iter(func(member string) bool {
+			sampled += int64(len(member))
+			samples++
+			return samples < evictionSamples
+		})
+	case *zset.SortedSet:
+		count = v.ZCard()
+		for _, item := range v.ZRange(0, evictionSamples-1) {
+			sampled += int64(len(item.Member) + 8)
+			samples++
+		}
+	case *list.LinkedList:
+		count = v.LLen()
+		for _, element := range v.LRange(0, evictionSamples-1) {
+			sampled += int64(len(element))
+			samples++
+		}
+	case *timeseries.TimeSeries:
+		// a timestamp and a value per sample
+		return size + v.Len()*16
+	case *vector.VectorSet:
+		return size + v.VCard()*(v.VDim()*4+elementOverhead)
+	default:
+		return size + int64(len(v.GetValue()))
+	}
+	if samples == 0 {
+		return size
+	}
+	return size + count*(sampled/samples+elementOverhead)
+}
+
+// forget removes the size of the key from the memory used, its value is leaving the memory
+func (s *store) forget(m *metadata) {
+	s.used.Add(-m.size.Swap(0))
+}
+
+// account updates the memory used with the sizes of the values of the keys, the sizes are
+// estimated while the keys are locked
+func (s *store) account(metas []*metadata, sizes []int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i, m := range metas {
+		if sizes[i] < 0 || s.metadata[m.key.Name] != m {
+			continue
+		}
+		s.used.Add(sizes[i] - m.size.Swap(sizes[i]))
+	}
+}
+
+// overMemory returns if the memory used exceeds the maxmemory
+func (s *store) overMemory() bool {
+	maxMemory := s.maxMemory.Load()
+	return maxMemory > 0 && s.used.Load() > maxMemory
+}
+
+// evict removes values from the memory by the eviction policy until the memory used is below
+// the maxmemory, a single goroutine evicts at a time
+func (s *store) evict() {
+	if !s.overMemory() || !s.evictMu.TryLock() {
+		return
+	}
+	defer s.evictMu.Unlock()
+	policy := EvictionPolicy(s.policy.Load())
+	if policy == NoEviction {
+		return
+	}
+	// the keys used or failing to be saved are missed, the eviction stops after a few
+	for misses := 0; s.overMemory() && misses < evictionSamples; {
+		m := s.evictionCandidate(policy, time.Now().UnixMilli())
+		if m == nil {
+			// nothing left to evict
+			return
+		}
+		if s.evictKey(m) {
+			misses = 0
+		} else {
+			misses++
+		}
+	}
+}
+
+// evictionCandidate returns the best key to evict of a sample of the keys in memory, the
+// keys locked are skipped
+func (s *store) evictionCandidate(policy EvictionPolicy, now int64) *metadata {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var best *metadata
+	var bestScore float64
+	samples := 0
+	// the iteration of a map starts at random
+	for _, m := range s.metadata {
+		if m.size.Load() == 0 || !m.TryRLock() {
+			continue
+		}
+		var score float64
+		ok := m.value != nil && m.isOk()
+		switch policy {
+		case AllKeysLRU:
+			score = float64(m.idleTime(now))
+		case AllKeysLFU:
+			score = -float64(m.frequency(now))
+		case VolatileLRU:
+			ok = ok && m.key.Expiration != 0
+			score = float64(m.idleTime(now))
+		case VolatileTTL:
+			ok = ok && m.key.Expiration != 0
+			score = -float64(m.key.Expiration)
+		}
+		m.RUnlock()
+		if !ok {
+			continue
+		}
+		if best == nil || score > bestScore {
+			best, bestScore = m, score
+		}
+		if samples++; samples == evictionSamples {
+			break
+		}
+	}
+	return best
+}
+
+// evictKey removes the value of the key from the memory, it's saved to the storage first if
+// it's modified. The key is deleted if the storage is in memory and not persisted. It returns
+// false if the key is used or its value can't be saved.
+func (s *store) evictKey(m *metadata) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.metadata[m.key.Name] != m || !m.TryLock() {
+		return false
+	}
+	defer m.Unlock()
+	if m.value == nil {
+		return false
+	}
+	var batch storage.Batch
+	if mem, ok := s.ss.(*storage.Memory); ok && !mem.Persistent() {
+		s.remove(&batch, m)
+		if err := storage.WriteBatch(s.ss, &batch); err != nil {
+			log.Println("Evict: ", err)
+			return false
+		}
+		delete(s.metadata, m.key.Name)
+		s.forget(m)
+		s.evictedKeys.Add(1)
+		if s.deleted != nil {
+			s.deleted(m)
+		}
+		return true
+	}
+	if m.modified() {
+		s.save(&batch, m)
+		err := storage.WriteBatch(s.ss, &batch)
+		s.saved(m, err)
+		if err != nil {
+			log.Println("Evict: ", err)
+			return false
+		}
+	}
+	m.reset()
+	m.removeFromMemory()
+	s.forget(m)
+	s.offloadedKeys.Add(1)
+	return true
+}
+
+// denyOOM returns if the command adding data must fail since the memory used exceeds the
+// maxmemory and no value can be evicted
+func (n *Nodis) denyOOM(name string) bool {
+	switch name {
+	case "SET", "SETNX", "SETEX", "PSETEX", "MSET", "MSETNX", "GETSET", "APPEND", "SETRANGE",
+		"SETBIT", "BITOP", "INCR", "INCRBY", "INCRBYFLOAT", "DECR", "DECRBY",
+		"LPUSH", "RPUSH", "LPUSHX", "RPUSHX", "LINSERT", "LSET", "LMOVE", "RPOPLPUSH", "BLMOVE",
+		"BRPOPLPUSH", "SADD", "SMOVE", "SUNIONSTORE", "SINTERSTORE", "SDIFFSTORE",
+		"ZADD", "ZINCRBY", "ZUNIONSTORE", "ZINTERSTORE", "ZDIFFSTORE", "ZRANGESTORE",
+		"HSET", "HSETNX", "HMSET", "HINCRBY", "HINCRBYFLOAT", "HSETEX",
+		"GEOADD", "GEOSEARCHSTORE", "GEORADIUS", "GEORADIUSBYMEMBER",
+		"TS.CREATE", "TS.ADD", "TS.MADD", "TS.INCRBY", "TS.DECRBY",
+		"VADD", "COPY", "RESTORE", "SORT":
+		return n.store.overMemory()
+	}
+	return false
+}
+
+// SetMaxMemory sets the maxmemory in bytes, 0 for no limit, and the eviction policy. The
+// values are evicted right away if the memory used exceeds it.
+func (n *Nodis) SetMaxMemory(maxMemory int64, policy EvictionPolicy) {
+	n.store.maxMemory.Store(max(maxMemory, 0))
+	n.store.policy.Store(uint32(policy))
+	n.store.evict()
+}
+
+// MaxMemory returns the maxmemory in bytes and the eviction policy
+func (n *Nodis) MaxMemory() (int64, EvictionPolicy) {
+	return n.store.maxMemory.Load(), EvictionPolicy(n.store.policy.Load())
+}
+
+// MemoryStats are the statistics of the memory used by the values
+type MemoryStats struct {
+	// Used is the estimated size of the keys and the values in memory
+	Used int64
+	// EvictedKeys is the number of the keys deleted by the eviction, OffloadedKeys the number
+	// of the values offloaded to the storage
+	EvictedKeys   int64
+	OffloadedKeys int64
+}
+
+// MemoryStats returns the statistics of the memory used by the values
+func (n *Nodis) MemoryStats() MemoryStats {
+	return MemoryStats{
+		Used:          n.store.used.Load(),
+		EvictedKeys:   n.store.evictedKeys.Load(),
+		OffloadedKeys: n.store.offloadedKeys.Load(),
+	}
+}
+
+// estimateSizes returns the sizes of the values of the keys to account, -1 for the keys whose
+// value hasn't changed
+func (tx *Tx) estimateSizes() []int64 {
+	sizes := make([]int64, len(tx.lockedMetas))
+	for i, m := range tx.lockedMetas {
+		sizes[i] = -1
+		if m.RWMutex == nil {
+			continue
+		}
+		// the values written and the ones loaded from the storage
+		if m.writeable || (m.value != nil && m.size.Load() == 0) {
+			sizes[i] = 0
+			if m.value != nil {
+				sizes[i] = estimateSize(m)
+			}
+		}
+	}
+	return sizes
+}
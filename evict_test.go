@@ -0,0 +1,261 @@
+package nodis
+
+import (
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/patch"
+	"github.com/diiyw/nodis/redis"
+	"github.com/diiyw/nodis/storage"
+)
+
+// valueSize is the estimated size of the keys of the tests
+var valueSize = int64(keyOverhead + len("k00") + 1000)
+
+func setKeys(n *Nodis, prefix string, count int) {
+	for i := 0; i < count; i++ {
+		n.Set(prefix+strconv.Itoa(i+10), bytes.Repeat([]byte("v"), 1000), false)
+	}
+}
+
+func TestEvict_Offload(t *testing.T) {
+	ss := &readCountMemory{Memory: storage.NewMemory()}
+	maxMemory := 10 * valueSize
+	n := Open(&Options{Storage: ss, MaxMemory: maxMemory, MaxMemoryPolicy: AllKeysLRU})
+	defer n.Close()
+	setKeys(n, "k", 50)
+	stats := n.MemoryStats()
+	if stats.Used > maxMemory || stats.Used < maxMemory-valueSize {
+		t.Errorf("Used = %d, want at most %d", stats.Used, maxMemory)
+	}
+	if stats.OffloadedKeys != 40 || stats.EvictedKeys != 0 {
+		t.Errorf("MemoryStats() = %+v, want 40 offloaded keys", stats)
+	}
+	// the offloaded values are loaded back
+	for i := 0; i < 50; i++ {
+		if v := n.Get("k" + strconv.Itoa(i+10)); len(v) != 1000 {
+			t.Fatalf("Get(k%d) = %d bytes, want 1000", i+10, len(v))
+		}
+	}
+	if used := n.MemoryStats().Used; used > maxMemory {
+		t.Errorf("Used = %d, want at most %d", used, maxMemory)
+	}
+}
+
+func TestEvict_MemoryOnly(t *testing.T) {
+	maxMemory := 10 * valueSize
+	n := Open(&Options{Storage: storage.NewMemory(), MaxMemory: maxMemory, MaxMemoryPolicy: AllKeysLFU})
+	defer n.Close()
+	setKeys(n, "k", 50)
+	if stats := n.MemoryStats(); stats.EvictedKeys != 40 || stats.OffloadedKeys != 0 || stats.Used > maxMemory {
+		t.Errorf("MemoryStats() = %+v, want 40 evicted keys", stats)
+	}
+	if v := n.Keys("*"); len(v) != 10 {
+		t.Errorf("Keys() = %d keys, want 10", len(v))
+	}
+	_ = n.Snapshot()
+	if v, _ := n.StorageStats(); v.Keys != 10 {
+		t.Errorf("StorageStats() = %d keys, want the evicted keys removed", v.Keys)
+	}
+}
+
+func TestEvict_PersistedMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodis.mem")
+	maxMemory := 10 * valueSize
+	n := Open(&Options{Storage: storage.NewMemoryWithFile(path), MaxMemory: maxMemory, MaxMemoryPolicy: AllKeysLRU})
+	setKeys(n, "k", 50)
+	if stats := n.MemoryStats(); stats.EvictedKeys != 0 || stats.OffloadedKeys != 40 {
+		t.Errorf("MemoryStats() = %+v, want the values offloaded", stats)
+	}
+	_ = n.Close()
+	n = Open(&Options{Storage: storage.NewMemoryWithFile(path)})
+	defer n.Close()
+	if v := n.Keys("*"); len(v) != 50 {
+		t.Errorf("Keys() = %d keys, want 50", len(v))
+	}
+	if v := n.Get("k10"); len(v) != 1000 {
+		t.Errorf("Get(k10) = %d bytes, want 1000", len(v))
+	}
+}
+
+func TestEvict_Notify(t *testing.T) {
+	n := Open(&Options{Storage: storage.NewMemory()})
+	defer n.Close()
+	if err := n.FTCreate(newSearchDefinition()); err != nil {
+		t.Fatalf("FTCreate() error = %v", err)
+	}
+	n.HMSet("user:1", map[string][]byte{"name": []byte("John Smith"), "age": []byte("30")})
+	deleted := make(chan string, 1)
+	n.WatchKey([]string{"user:*"}, func(op patch.Op) {
+		if op.Type == patch.OpTypeDel {
+			deleted <- op.Data.GetKey()
+		}
+	})
+	n.SetMaxMemory(1, AllKeysLRU)
+	if n.Exists("user:1") != 0 {
+		t.Fatalf("Exists() = 1, want the key evicted")
+	}
+	if got := searchKeys(t, n, "john"); len(got) != 0 {
+		t.Errorf("FTSearch() = %v, want the evicted key removed from the index", got)
+	}
+	select {
+	case key := <-deleted:
+		if key != "user:1" {
+			t.Errorf("Del patch key = %s, want user:1", key)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no Del patch for the evicted key")
+	}
+}
+
+func TestEvict_Volatile(t *testing.T) {
+	maxMemory := 15 * valueSize
+	n := Open(&Options{Storage: storage.NewMemory(), MaxMemory: maxMemory, MaxMemoryPolicy: VolatileTTL})
+	defer n.Close()
+	setKeys(n, "p", 10)
+	for i := 0; i < 30; i++ {
+		n.SetEX("v"+strconv.Itoa(i+10), bytes.Repeat([]byte("v"), 1000), int64(100+i))
+	}
+	if v := n.Keys("p*"); len(v) != 10 {
+		t.Errorf("Keys(p*) = %d keys, want the keys without expiration kept", len(v))
+	}
+	if v := n.Keys("v*"); len(v) != 5 {
+		t.Errorf("Keys(v*) = %d keys, want 5", len(v))
+	}
+	if n.denyOOM("SET") {
+		t.Errorf("denyOOM(SET) = true, want false below the maxmemory")
+	}
+	// nothing is left to evict
+	setKeys(n, "q", 10)
+	if v := n.Keys("v*"); len(v) != 0 {
+		t.Errorf("Keys(v*) = %v, want all the keys with expiration evicted", v)
+	}
+	if !n.denyOOM("SET") || n.denyOOM("GET") {
+		t.Errorf("denyOOM() = false, want the writes denied")
+	}
+	n.Del("q10", "q11", "q12", "q13", "q14", "q15")
+	if n.denyOOM("SET") {
+		t.Errorf("denyOOM(SET) = true, want false after Del")
+	}
+}
+
+func TestEvict_Candidate(t *testing.T) {
+	n := Open(&Options{Storage: storage.NewMemory()})
+	defer n.Close()
+	now := time.Now().UnixMilli()
+	for i, key := range []string{"a", "b", "c"} {
+		n.SetEX(key, []byte("v"), int64(100*(3-i)))
+		m := n.store.metadata[key]
+		m.access.Store(now - int64(i)*1000)
+		m.freq.Store(uint32(10 - i))
+	}
+	n.Set("d", []byte("v"), false)
+	n.store.metadata["d"].access.Store(now - 10000)
+	tests := []struct {
+		policy EvictionPolicy
+		want   string
+	}{
+		{AllKeysLRU, "d"},
+		{AllKeysLFU, "d"},
+		{VolatileLRU, "c"},
+		{VolatileTTL, "c"},
+	}
+	n.store.metadata["d"].freq.Store(1)
+	for _, tt := range tests {
+		if m := n.store.evictionCandidate(tt.policy, now); m == nil || m.key.Name != tt.want {
+			t.Errorf("evictionCandidate(%s) = %v, want %s", tt.policy, m, tt.want)
+		}
+	}
+}
+
+func TestEvict_Config(t *testing.T) {
+	n := Open(&Options{Storage: storage.NewMemory()})
+	defer n.Close()
+	run := func(args ...string) string {
+		w := redis.NewWriter(&bytes.Buffer{})
+		GetCommand(args[0])(n, &redis.Conn{Writer: w}, redis.Command{Name: args[0], Args: args[1:]})
+		return string(w.Bytes())
+	}
+	setKeys(n, "k", 20)
+	if v := run("CONFIG", "SET", "maxmemory", "10kb"); v != "+OK\r\n" {
+		t.Fatalf("CONFIG SET maxmemory = %q, want OK", v)
+	}
+	if !n.denyOOM("SET") {
+		t.Errorf("denyOOM(SET) = false, want true with noeviction")
+	}
+	if v := run("CONFIG", "SET", "maxmemory-policy", "allkeys-lru"); v != "+OK\r\n" {
+		t.Fatalf("CONFIG SET maxmemory-policy = %q, want OK", v)
+	}
+	if n.denyOOM("SET") || len(n.Keys("*")) != 9 {
+		t.Errorf("Keys() = %d keys, want the keys evicted by CONFIG SET", len(n.Keys("*")))
+	}
+	if v := run("CONFIG", "SET", "maxmemory-policy", "lru"); !strings.HasPrefix(v, "-ERR") {
+		t.Errorf("CONFIG SET maxmemory-policy lru = %q, want an error", v)
+	}
+	if v := run("CONFIG", "GET", "maxmemory"); v != "*2\r\n$9\r\nmaxmemory\r\n$5\r\n10240\r\n" {
+		t.Errorf("CONFIG GET maxmemory = %q", v)
+	}
+	if v := run("CONFIG", "GET", "maxmemory-policy"); v != "*2\r\n$16\r\nmaxmemory-policy\r\n$11\r\nallkeys-lru\r\n" {
+		t.Errorf("CONFIG GET maxmemory-policy = %q", v)
+	}
+	info := run("INFO")
+	for _, field := range []string{"maxmemory:10240\r\n", "maxmemory_human:10.00K\r\n", "maxmemory_policy:allkeys-lru\r\n", "evicted_keys:11\r\n", "offloaded_keys:0\r\n"} {
+		if !strings.Contains(info, field) {
+			t.Errorf("INFO doesn't contain %q", field)
+		}
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	h := hash.NewHashMap()
+	for i := 0; i < 100; i++ {
+		h.HSet("field"+strconv.Itoa(i+10), []byte("value"))
+	}
+	m := newMetadata(ds.NewKey("key", 0), false)
+	m.setValue(h)
+	want := int64(keyOverhead + 3 + 100*(len("field10value")+elementOverhead))
+	if v := estimateSize(m); v != want {
+		t.Errorf("estimateSize() = %d, want %d", v, want)
+	}
+	m.removeFromMemory()
+	if v := estimateSize(m); v != keyOverhead+3 {
+		t.Errorf("estimateSize() = %d, want %d", v, keyOverhead+3)
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int64
+		err  bool
+	}{
+		{"1024", 1024, false},
+		{"100b", 100, false},
+		{"10KB", 10 * FileSizeKB, false},
+		{"64mb", 64 * FileSizeMB, false},
+		{" 2gb", 2 * FileSizeGB, false},
+		{"-1", 0, true},
+		{"1tb", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		v, err := ParseMemory(tt.s)
+		if v != tt.want || (err != nil) != tt.err {
+			t.Errorf("ParseMemory(%q) = %d, %v, want %d", tt.s, v, err, tt.want)
+		}
+	}
+	for p := NoEviction; p <= VolatileTTL; p++ {
+		if v, err := ParseEvictionPolicy(p.String()); err != nil || v != p {
+			t.Errorf("ParseEvictionPolicy(%s) = %s, %v", p, v, err)
+		}
+	}
+	if _, err := ParseEvictionPolicy("lru"); err != ErrUnknownEvictionPolicy {
+		t.Errorf("ParseEvictionPolicy(lru) = %v, want ErrUnknownEvictionPolicy", err)
+	}
+}
@@ -0,0 +1,352 @@
+package nodis
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/ds/set"
+	"github.com/diiyw/nodis/ds/str"
+	"github.com/diiyw/nodis/ds/zset"
+	"github.com/diiyw/nodis/storage"
+)
+
+// The large hashes, sets and sorted sets are stored exploded when Options.ExplodeThreshold is
+// set: the head record of the key, under explodedHeadPrefix and the name with the expiration of
+// the key, holds the type and the number of the fields, and every field is a record under
+// explodedFieldPrefix, the length of the name, the name and the field. The field records of a
+// hash are the expiration of the field and its value, the ones of a sorted set are the score.
+const (
+	explodedHeadPrefix  = "\x00xh:"
+	explodedFieldPrefix = "\x00xf:"
+)
+
+var errExplodedHead = errors.New("invalid exploded head")
+
+func explodedHeadKey(key *ds.Key) *ds.Key {
+	return ds.NewKey(explodedHeadPrefix+key.Name, key.Expiration)
+}
+
+// explodedFields returns the prefix of the field records of the key
+func explodedFields(name string) string {
+	b := binary.AppendUvarint([]byte(explodedFieldPrefix), uint64(len(name)))
+	return string(b) + name
+}
+
+// explodedFieldKey returns the key of the field record, the field records have no expiration so
+// they are found by storage.ScanPersistentPrefix
+func explodedFieldKey(name, field string) *ds.Key {
+	return ds.NewKey(explodedFields(name)+field, 0)
+}
+
+func newRecord(b []byte) ds.Value {
+	v := str.NewString()
+	v.Set(b)
+	return v
+}
+
+// explodedLen returns the number of the fields of the collections which can be exploded, -1 for
+// the other values
+func explodedLen(v ds.Value) int64 {
+	switch v := v.(type) {
+	case *hash.HashMap:
+		return v.HLen()
+	case *set.Set:
+		return v.SCard()
+	case *zset.SortedSet:
+		return v.ZCard()
+	}
+	return -1
+}
+
+// explodedRecords calls fn with the field records of the value
+func explodedRecords(v ds.Value, fn func(field string, record ds.Value)) {
+	switch v := v.(type) {
+	case *hash.HashMap:
+		for field, value := range v.HGetAll() {
+			b := binary.AppendUvarint(nil, uint64(max(v.HPExpireTime(field), 0)))
+			fn(field, newRecord(append(b, value...)))
+		}
+	case *set.Set:
+		v.Iter(func(member string) bool {
+			fn(member, newRecord(nil))
+			return true
+		})
+	case *zset.SortedSet:
+		for _, item := range v.ZRange(0, -1) {
+			fn(item.Member, newRecord(binary.BigEndian.AppendUint64(nil, math.Float64bits(item.Score))))
+		}
+	}
+}
+
+// explodedRecord returns the record of the field, false if the collection doesn't have it
+func explodedRecord(v ds.Value, field string) (ds.Value, bool) {
+	switch v := v.(type) {
+	case *hash.HashMap:
+		value := v.HGet(field)
+		if value == nil {
+			return nil, false
+		}
+		b := binary.AppendUvarint(nil, uint64(max(v.HPExpireTime(field), 0)))
+		return newRecord(append(b, value...)), true
+	case *set.Set:
+		return newRecord(nil), v.SIsMember(field)
+	case *zset.SortedSet:
+		score, err := v.ZScore(field)
+		if err != nil {
+			return nil, false
+		}
+		return newRecord(binary.BigEndian.AppendUint64(nil, math.Float64bits(score))), true
+	}
+	return nil, false
+}
+
+// addRecord adds the field of a record to the collection
+func addRecord(v ds.Value, field string, record []byte) error {
+	switch v := v.(type) {
+	case *hash.HashMap:
+		at, n := binary.Uvarint(record)
+		if n <= 0 {
+			return storage.ErrCorruptedData
+		}
+		v.HSet(field, record[n:])
+		if at > 0 {
+			v.HPExpireAt(field, int64(at), hash.ExpireAlways)
+		}
+	case *set.Set:
+		v.SAdd(field)
+	case *zset.SortedSet:
+		if len(record) != 8 {
+			return storage.ErrCorruptedData
+		}
+		v.ZAdd(field, math.Float64frombits(binary.BigEndian.Uint64(record)))
+	}
+	return nil
+}
+
+// newExploded returns an empty collection of the type
+func newExploded(typ ds.ValueType, e *ds.Encodings) (ds.Value, error) {
+	switch typ {
+	case ds.Hash:
+		return hash.NewHashMapWithEncodings(e), nil
+	case ds.Set:
+		return set.NewSetWithEncodings(e), nil
+	case ds.ZSet:
+		return zset.NewSortedSetWithEncodings(e), nil
+	}
+	return nil, errExplodedHead
+}
+
+// save adds to the batch the records of the value of the key, exploded if it has enough fields.
+// Once a value is stored exploded only the records of the fields changed since are written,
+// saved must be called once the batch is written.
+func (s *store) save(b *storage.Batch, m *metadata) {
+	if key, ok := s.removed[m.key.Name]; ok {
+		// the records of the deleted value are removed before the new ones are written
+		delete(s.removed, m.key.Name)
+		s.removeExploded(b, key, nil)
+	}
+	n := explodedLen(m.value)
+	if s.explodeThreshold <= 0 || n < int64(s.explodeThreshold) {
+		b.Set(m.key, m.value)
+		if m.exploded {
+			s.removeExploded(b, m.key, nil)
+			m.exploded = false
+		} else if s.explodeThreshold > 0 {
+			// a head record left by a value deleted before a restart would hide the new one
+			b.Delete(explodedHeadKey(m.key))
+		}
+		return
+	}
+	changes := m.value.(ds.ChangeTracker).Changes()
+	switch {
+	case m.exploded && changes.Tracking(m.key.Name):
+		for field := range changes.Fields() {
+			if record, ok := explodedRecord(m.value, field); ok {
+				b.Set(explodedFieldKey(m.key.Name, field), record)
+			} else {
+				b.Delete(explodedFieldKey(m.key.Name, field))
+			}
+		}
+	default:
+		// the value is new or replaced, the records of the fields it doesn't have are removed,
+		// like the ones left by a quarantined value
+		fields := make(map[string]struct{}, n)
+		explodedRecords(m.value, func(field string, record ds.Value) {
+			fields[field] = struct{}{}
+			b.Set(explodedFieldKey(m.key.Name, field), record)
+		})
+		s.removeExploded(b, m.key, fields)
+		if !m.exploded {
+			b.Delete(m.key)
+		}
+		changes.Track(m.key.Name)
+	}
+	head := binary.AppendUvarint([]byte{byte(m.value.Type())}, uint64(n))
+	b.Set(explodedHeadKey(m.key), newRecord(head))
+	m.exploded = true
+}
+
+// saved resets the changes of the fields of the value saved, they are written again in full if
+// the batch failed
+func (s *store) saved(m *metadata, err error) {
+	changes, ok := m.value.(ds.ChangeTracker)
+	if !ok || !m.exploded {
+		return
+	}
+	if err != nil {
+		changes.Changes().Untrack()
+		return
+	}
+	changes.Changes().Reset()
+}
+
+// remove adds to the batch the removal of the records of the key
+func (s *store) remove(b *storage.Batch, m *metadata) {
+	b.Delete(m.key)
+	if m.exploded {
+		s.removeExploded(b, m.key, nil)
+		m.exploded = false
+	}
+}
+
+// removeLater queues the removal of the records of the key deleted from the metadata if its
+// value is stored exploded, they are removed by the next flush or gc or before a value of the
+// same name is saved. s.mu must be locked.
+func (s *store) removeLater(m *metadata) {
+	if m.exploded {
+		key := *m.key
+		s.removed[key.Name] = &key
+	}
+}
+
+// removeDeleted adds to the batch the removal of the records queued by removeLater, they are
+// queued again if the batch fails
+func (s *store) removeDeleted(b *storage.Batch) (requeue func(err error)) {
+	removed := s.removed
+	s.removed = make(map[string]*ds.Key)
+	for _, key := range removed {
+		s.removeExploded(b, key, nil)
+	}
+	return func(err error) {
+		if err == nil {
+			return
+		}
+		for name, key := range removed {
+			if _, ok := s.removed[name]; !ok {
+				s.removed[name] = key
+			}
+		}
+	}
+}
+
+// removeExploded adds to the batch the removal of the field records of the key but the kept
+// ones, the head record is removed too if there are none
+func (s *store) removeExploded(b *storage.Batch, key *ds.Key, kept map[string]struct{}) {
+	prefix := explodedFields(key.Name)
+	_ = storage.ScanPersistentPrefix(s.ss, prefix, func(field *ds.Key) bool {
+		if _, ok := kept[strings.TrimPrefix(field.Name, prefix)]; !ok {
+			b.Delete(field)
+		}
+		return true
+	})
+	if kept == nil {
+		b.Delete(explodedHeadKey(key))
+	}
+}
+
+// load reads the value of the key from the storage
+func (s *store) load(m *metadata) (ds.Value, error) {
+	if !m.exploded {
+		v, err := s.ss.Get(m.key)
+		if l, ok := v.(*list.LinkedList); ok {
+			// the entries don't keep the options of the lists
+			l.SetOptions(s.listOptions)
+		}
+		if e, ok := v.(ds.Encoded); ok {
+			e.SetEncodings(s.encodings)
+		}
+		return v, err
+	}
+	head, err := s.ss.Get(explodedHeadKey(m.key))
+	if err != nil {
+		return nil, err
+	}
+	if len(head.GetValue()) < 1 {
+		return nil, storage.ErrCorruptedData
+	}
+	v, err := newExploded(ds.ValueType(head.GetValue()[0]), s.encodings)
+	if err != nil {
+		return nil, storage.ErrCorruptedData
+	}
+	prefix := explodedFields(m.key.Name)
+	var readErr error
+	err = storage.ScanPersistentPrefix(s.ss, prefix, func(key *ds.Key) bool {
+		var record ds.Value
+		if record, readErr = s.ss.Get(key); readErr == nil {
+			readErr = addRecord(v, strings.TrimPrefix(key.Name, prefix), record.GetValue())
+		}
+		return readErr == nil
+	})
+	if readErr != nil {
+		return nil, readErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the next saves only write the fields changed
+	v.(ds.ChangeTracker).Changes().Track(m.key.Name)
+	return v, nil
+}
+
+// loadFields reads the fields of a key stored exploded, the other fields are left out
+func (s *store) loadFields(m *metadata, fields ...string) (ds.Value, error) {
+	head, err := s.ss.Get(explodedHeadKey(m.key))
+	if err != nil {
+		return nil, err
+	}
+	if len(head.GetValue()) < 1 {
+		return nil, storage.ErrCorruptedData
+	}
+	v, err := newExploded(ds.ValueType(head.GetValue()[0]), s.encodings)
+	if err != nil {
+		return nil, storage.ErrCorruptedData
+	}
+	for _, field := range fields {
+		record, err := s.ss.Get(explodedFieldKey(m.key.Name, field))
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = addRecord(v, field, record.GetValue()); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// readFields reads the key like readKey, but the value of a key stored exploded that isn't in
+// memory only has the fields read from the storage. The value must not be changed.
+func (tx *Tx) readFields(key string, fields ...string) *metadata {
+	m := tx.rLockKey(key)
+	if !m.isOk() || m.value != nil || !m.exploded || m.expired(time.Now().UnixMilli()) {
+		return tx.writeExpiredFields(tx.loadMeta(m))
+	}
+	v, err := tx.store.loadFields(m, fields...)
+	if err != nil {
+		if errors.Is(err, storage.ErrCorruptedData) {
+			tx.store.quarantine(m, err)
+		}
+		return newMetadata(m.key, false)
+	}
+	partial := newMetadata(m.key, false)
+	partial.setValue(v)
+	return partial
+}
@@ -0,0 +1,304 @@
+package nodis
+
+import (
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/storage"
+)
+
+// readCountMemory counts the reads and the batch writes of the memory storage
+type readCountMemory struct {
+	*storage.Memory
+	gets   int
+	writes int
+}
+
+func (m *readCountMemory) WriteBatch(b *storage.Batch) error {
+	m.writes += b.Len()
+	return m.Memory.WriteBatch(b)
+}
+
+func (m *readCountMemory) Get(key *ds.Key) (ds.Value, error) {
+	m.gets++
+	return m.Memory.Get(key)
+}
+
+// records returns the number of the plain records, the exploded heads and the fields of the storage
+func (m *readCountMemory) records() (plain, heads, fields int) {
+	m.ScanKeys(func(key *ds.Key) bool {
+		switch {
+		case strings.HasPrefix(key.Name, explodedHeadPrefix):
+			heads++
+		case strings.HasPrefix(key.Name, explodedFieldPrefix):
+			fields++
+		default:
+			plain++
+		}
+		return true
+	})
+	return
+}
+
+// evict removes the values from memory like gc does for the keys not accessed
+func evict(n *Nodis) {
+	_ = n.Snapshot()
+	for _, m := range n.store.metadata {
+		m.removeFromMemory()
+	}
+}
+
+func TestExplode_Hash(t *testing.T) {
+	ss := &readCountMemory{Memory: storage.NewMemory()}
+	n := Open(&Options{Storage: ss, ExplodeThreshold: 10})
+	for i := 0; i < 100; i++ {
+		n.HSet("hash", "f"+strconv.Itoa(i), []byte("v"+strconv.Itoa(i)))
+	}
+	n.HPExpire("hash", time.Hour.Milliseconds(), hash.ExpireAlways, "f1")
+	at := n.HPExpireTime("hash", "f1")[0]
+	n.HSet("small", "f", []byte("v"))
+	evict(n)
+	if plain, heads, fields := ss.records(); plain != 1 || heads != 1 || fields != 100 {
+		t.Fatalf("records = %d plain, %d heads, %d fields, want the hash exploded", plain, heads, fields)
+	}
+
+	gets := ss.gets
+	if v := n.HGet("hash", "f42"); string(v) != "v42" {
+		t.Errorf("HGet() = %s, want v42", v)
+	}
+	if ss.gets-gets != 2 || n.store.metadata["hash"].value != nil {
+		t.Errorf("HGet() = %d reads, want the head and the field only", ss.gets-gets)
+	}
+	if v := n.HMGet("hash", "f1", "missing"); string(v[0]) != "v1" || v[1] != nil {
+		t.Errorf("HMGet() = %q, want [v1 nil]", v)
+	}
+	if n.HExists("hash", "missing") {
+		t.Errorf("HExists() = true, want false")
+	}
+
+	// the fields removed are removed from the storage
+	for i := 50; i < 100; i++ {
+		n.HDel("hash", "f"+strconv.Itoa(i))
+	}
+	evict(n)
+	if _, heads, fields := ss.records(); heads != 1 || fields != 50 {
+		t.Errorf("records = %d heads, %d fields, want 50 fields", heads, fields)
+	}
+	_ = n.Close()
+
+	n = Open(&Options{Storage: ss, ExplodeThreshold: 10})
+	if v := n.HGetAll("hash"); len(v) != 50 || string(v["f49"]) != "v49" {
+		t.Errorf("HGetAll() = %d fields, want 50", len(v))
+	}
+	if v := n.HPExpireTime("hash", "f1"); len(v) != 1 || v[0] != at {
+		t.Errorf("HPExpireTime() = %v, want %d", v, at)
+	}
+	if v := n.HGet("small", "f"); string(v) != "v" {
+		t.Errorf("HGet() = %s, want v", v)
+	}
+
+	// back to a single record below the threshold
+	for i := 5; i < 50; i++ {
+		n.HDel("hash", "f"+strconv.Itoa(i))
+	}
+	evict(n)
+	if plain, heads, fields := ss.records(); plain != 2 || heads != 0 || fields != 0 {
+		t.Errorf("records = %d plain, %d heads, %d fields, want no exploded record", plain, heads, fields)
+	}
+	if v := n.HLen("hash"); v != 5 {
+		t.Errorf("HLen() = %d, want 5", v)
+	}
+	_ = n.Close()
+}
+
+func TestExplode_SetZSet(t *testing.T) {
+	ss := &readCountMemory{Memory: storage.NewMemory()}
+	n := Open(&Options{Storage: ss, ExplodeThreshold: 10})
+	for i := 0; i < 20; i++ {
+		n.SAdd("set", "m"+strconv.Itoa(i))
+		n.ZAdd("zset", "m"+strconv.Itoa(i), float64(i)+0.5)
+	}
+	evict(n)
+	if _, heads, fields := ss.records(); heads != 2 || fields != 40 {
+		t.Fatalf("records = %d heads, %d fields, want the set and the sorted set exploded", heads, fields)
+	}
+	gets := ss.gets
+	if !n.SIsMember("set", "m3") || n.SIsMember("set", "missing") {
+		t.Errorf("SIsMember() = false, want m3 only")
+	}
+	if v, err := n.ZScore("zset", "m3"); err != nil || v != 3.5 {
+		t.Errorf("ZScore() = %v, %v, want 3.5", v, err)
+	}
+	if v := n.ZMScore("zset", "m4", "missing"); v[0] == nil || *v[0] != 4.5 || v[1] != nil {
+		t.Errorf("ZMScore() = %v, want [4.5 nil]", v)
+	}
+	if ss.gets-gets != 9 {
+		t.Errorf("reads = %d, want the heads and the fields only", ss.gets-gets)
+	}
+	_ = n.Close()
+
+	n = Open(&Options{Storage: ss, ExplodeThreshold: 10})
+	defer n.Close()
+	if v := n.SCard("set"); v != 20 {
+		t.Errorf("SCard() = %d, want 20", v)
+	}
+	if v := n.ZRangeWithScores("zset", 0, 0); len(v) != 1 || v[0].Member != "m0" || v[0].Score != 0.5 {
+		t.Errorf("ZRange() = %v, want m0", v)
+	}
+	if v := n.Keys("*"); len(v) != 2 {
+		t.Errorf("Keys() = %v, want the keys without the records", v)
+	}
+}
+
+func TestExplode_Changes(t *testing.T) {
+	ss := &readCountMemory{Memory: storage.NewMemory()}
+	n := Open(&Options{Storage: ss, ExplodeThreshold: 100})
+	for i := 0; i < 10000; i++ {
+		n.HSet("hash", "f"+strconv.Itoa(i), []byte("v"))
+	}
+	_ = n.Snapshot()
+	// the fields, the head and the removal of the single record
+	if ss.writes != 10002 {
+		t.Fatalf("writes = %d, want 10002", ss.writes)
+	}
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"HSet", func() { n.HSet("hash", "f1", []byte("v1")) }},
+		{"HDel", func() { n.HDel("hash", "f2") }},
+		{"HPExpire", func() { n.HPExpire("hash", time.Hour.Milliseconds(), hash.ExpireAlways, "f3") }},
+		{"HSet after eviction", func() {
+			n.store.metadata["hash"].removeFromMemory()
+			n.HSet("hash", "f4", []byte("v4"))
+		}},
+	}
+	for _, tt := range tests {
+		ss.writes = 0
+		tt.fn()
+		_ = n.Snapshot()
+		if ss.writes != 2 {
+			t.Errorf("%s: writes = %d, want the field and the head", tt.name, ss.writes)
+		}
+	}
+
+	// a value replacing the exploded one is written in full
+	for i := 0; i < 200; i++ {
+		n.HSet("renamed", "r"+strconv.Itoa(i), []byte("v"))
+	}
+	_ = n.Snapshot()
+	if err := n.Rename("renamed", "hash"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	_ = n.Snapshot()
+	_ = n.Close()
+
+	n = Open(&Options{Storage: ss, ExplodeThreshold: 100})
+	defer n.Close()
+	if v := n.HLen("hash"); v != 200 {
+		t.Errorf("HLen() = %d, want the old fields removed", v)
+	}
+}
+
+func TestExplode_Deleted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodis.db")
+	n := Open(&Options{Storage: storage.NewMemoryWithFile(path), ExplodeThreshold: 2})
+	for _, key := range []string{"k", "deleted"} {
+		n.HSet(key, "a", []byte("1"))
+		n.HSet(key, "b", []byte("2"))
+		n.HSet(key, "c", []byte("3"))
+	}
+	_ = n.Snapshot()
+	n.Del("k", "deleted")
+	n.Set("k", []byte("str"), false)
+	_ = n.Snapshot()
+	_ = n.Close()
+
+	ss := storage.NewMemoryWithFile(path)
+	n = Open(&Options{Storage: ss, ExplodeThreshold: 2})
+	defer n.Close()
+	if v := n.Get("k"); string(v) != "str" {
+		t.Errorf("Get() = %s, want str", v)
+	}
+	if n.Exists("deleted") != 0 {
+		t.Errorf("Exists() = 1, want the deleted hash removed")
+	}
+	ss.ScanKeys(func(key *ds.Key) bool {
+		if strings.HasPrefix(key.Name, explodedHeadPrefix) || strings.HasPrefix(key.Name, explodedFieldPrefix) {
+			t.Errorf("record %q of a deleted hash is left", key.Name)
+		}
+		return true
+	})
+}
+
+func TestExplode_Encrypted(t *testing.T) {
+	for _, names := range []bool{false, true} {
+		t.Run("names="+strconv.FormatBool(names), func(t *testing.T) {
+			ss := storage.Encrypted(storage.NewMemory(), storage.NewKeyRing(1, bytes.Repeat([]byte{1}, 32)))
+			ss.SetEncryptNames(names)
+			records := func() (heads, fields int) {
+				ss.ScanKeys(func(key *ds.Key) bool {
+					if strings.HasPrefix(key.Name, explodedHeadPrefix) {
+						heads++
+					} else if strings.HasPrefix(key.Name, explodedFieldPrefix) {
+						fields++
+					}
+					return true
+				})
+				return
+			}
+			n := Open(&Options{Storage: ss, ExplodeThreshold: 10})
+			for i := 0; i < 100; i++ {
+				n.HSet("hash", "f"+strconv.Itoa(i), []byte("v"+strconv.Itoa(i)))
+			}
+			for i := 0; i < 20; i++ {
+				n.SAdd("set", "m"+strconv.Itoa(i))
+				n.ZAdd("zset", "m"+strconv.Itoa(i), float64(i)+0.5)
+				n.HSet("deleted", "f"+strconv.Itoa(i), []byte("v"))
+			}
+			evict(n)
+			if heads, fields := records(); heads != 4 || fields != 160 {
+				t.Fatalf("records = %d heads, %d fields, want the collections exploded", heads, fields)
+			}
+			if v := n.HGet("hash", "f42"); string(v) != "v42" {
+				t.Errorf("HGet() = %s, want v42", v)
+			}
+			if !n.SIsMember("set", "m3") {
+				t.Errorf("SIsMember() = false, want true")
+			}
+			if v, err := n.ZScore("zset", "m3"); err != nil || v != 3.5 {
+				t.Errorf("ZScore() = %v, %v, want 3.5", v, err)
+			}
+			for i := 50; i < 100; i++ {
+				n.HDel("hash", "f"+strconv.Itoa(i))
+			}
+			n.Del("deleted")
+			evict(n)
+			_ = n.Close()
+
+			n = Open(&Options{Storage: ss, ExplodeThreshold: 10})
+			defer n.Close()
+			if v := n.HGetAll("hash"); len(v) != 50 || string(v["f49"]) != "v49" {
+				t.Errorf("HGetAll() = %d fields, want 50", len(v))
+			}
+			if v := n.SCard("set"); v != 20 {
+				t.Errorf("SCard() = %d, want 20", v)
+			}
+			if v := n.ZRangeWithScores("zset", 0, 0); len(v) != 1 || v[0].Member != "m0" || v[0].Score != 0.5 {
+				t.Errorf("ZRange() = %v, want m0", v)
+			}
+			if n.Exists("deleted") != 0 {
+				t.Errorf("Exists() = 1, want the deleted hash removed")
+			}
+			if heads, fields := records(); heads != 3 || fields != 90 {
+				t.Errorf("records = %d heads, %d fields, want the removed fields deleted", heads, fields)
+			}
+		})
+	}
+}
@@ -5,7 +5,10 @@ go 1.25
 require (
 	github.com/alecthomas/kong v1.13.0
 	github.com/cockroachdb/pebble v1.1.5
+	github.com/golang/snappy v0.0.4
 	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.18.0
+	go.etcd.io/bbolt v1.4.3
 	google.golang.org/protobuf v1.36.11
 )
 
@@ -21,8 +24,6 @@ require (
 	github.com/getsentry/sentry-go v0.27.0 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
-	github.com/golang/snappy v0.0.4 // indirect
-	github.com/klauspost/compress v1.16.0 // indirect
 	github.com/kr/pretty v0.3.1 // indirect
 	github.com/kr/text v0.2.0 // indirect
 	github.com/matttproud/golang_protobuf_extensions v1.0.4 // indirect
@@ -33,6 +34,6 @@ require (
 	github.com/prometheus/procfs v0.9.0 // indirect
 	github.com/rogpeppe/go-internal v1.9.0 // indirect
 	golang.org/x/exp v0.0.0-20230626212559-97b1e661b5df // indirect
-	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 )
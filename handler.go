@@ -1,18 +1,27 @@
 package nodis
 
 import (
+	"encoding/binary"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"os"
 	"runtime"
+	"slices"
 	"strconv"
 	"time"
 
 	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/ds/timeseries"
+	"github.com/diiyw/nodis/ds/vector"
 	"github.com/diiyw/nodis/ds/zset"
 	"github.com/diiyw/nodis/internal/geohash"
 	"github.com/diiyw/nodis/internal/strings"
 	"github.com/diiyw/nodis/redis"
+	"github.com/diiyw/nodis/search"
 )
 
 func execCommand(conn *redis.Conn, fn func()) {
@@ -65,6 +74,10 @@ func GetCommand(name string) func(n *Nodis, conn *redis.Conn, cmd redis.Command)
 		return flushDB
 	case "SAVE":
 		return save
+	case "BGSAVE":
+		return bgSave
+	case "LASTSAVE":
+		return lastSave
 	case "INFO":
 		return info
 	case "DEL":
@@ -93,27 +106,55 @@ func GetCommand(name string) func(n *Nodis, conn *redis.Conn, cmd redis.Command)
 		return renameNx
 	case "TYPE":
 		return typ
+	case "OBJECT":
+		return object
+	case "COPY":
+		return copyKey
+	case "TOUCH":
+		return touch
+	case "EXPIRETIME":
+		return expireTime
+	case "PEXPIRETIME":
+		return pExpireTime
+	case "SORT", "SORT_RO":
+		return sortKey
+	case "DUMP":
+		return dump
+	case "RESTORE":
+		return restore
+	case "MIGRATE":
+		return migrate
 	case "SCAN":
 		return scan
 	case "SET":
 		return setString
 	case "MSET":
 		return mSet
+	case "MSETNX":
+		return mSetNX
 	case "APPEND":
 		return appendString
 	case "SETEX":
 		return setex
+	case "PSETEX":
+		return pSetex
 	case "SETNX":
 		return setnx
 	case "GET":
 		return getString
 	case "GETSET":
 		return getSet
+	case "GETEX":
+		return getEX
+	case "GETDEL":
+		return getDel
+	case "LCS":
+		return lcs
 	case "MGET":
 		return mGet
 	case "SETRANGE":
 		return setRange
-	case "GETRANGE":
+	case "GETRANGE", "SUBSTR":
 		return getRange
 	case "STRLEN":
 		return strLen
@@ -195,6 +236,16 @@ func GetCommand(name string) func(n *Nodis, conn *redis.Conn, cmd redis.Command)
 		return hScan
 	case "HVALS":
 		return hVals
+	case "HEXPIRE", "HPEXPIRE", "HEXPIREAT", "HPEXPIREAT":
+		return hExpire
+	case "HTTL", "HPTTL", "HEXPIRETIME", "HPEXPIRETIME", "HPERSIST":
+		return hTTL
+	case "HGETDEL":
+		return hGetDel
+	case "HGETEX":
+		return hGetEX
+	case "HSETEX":
+		return hSetEX
 	case "LPUSH":
 		return lPush
 	case "RPUSH":
@@ -229,6 +280,16 @@ func GetCommand(name string) func(n *Nodis, conn *redis.Conn, cmd redis.Command)
 		return bLPop
 	case "BRPOP":
 		return bRPop
+	case "LMOVE", "BLMOVE":
+		return lMove
+	case "BRPOPLPUSH":
+		return bRPopLPush
+	case "LMPOP":
+		return lMPop
+	case "BLMPOP":
+		return bLMPop
+	case "LPOS":
+		return lPos
 	case "ZADD":
 		return zAdd
 	case "ZCARD":
@@ -257,12 +318,40 @@ func GetCommand(name string) func(n *Nodis, conn *redis.Conn, cmd redis.Command)
 		return zRemRangeByRank
 	case "ZREMRANGEBYSCORE":
 		return zRemRangeByScore
+	case "ZRANGEBYLEX", "ZREVRANGEBYLEX":
+		return zRangeByLex
+	case "ZLEXCOUNT":
+		return zLexCount
+	case "ZREMRANGEBYLEX":
+		return zRemRangeByLex
 	case "ZCLEAR":
 		return zClear
 	case "ZUNIONSTORE":
 		return zUnionStore
 	case "ZINTERSTORE":
 		return zInterStore
+	case "ZUNION", "ZINTER":
+		return zUnionInter
+	case "ZINTERCARD":
+		return zInterCard
+	case "ZDIFF":
+		return zDiff
+	case "ZDIFFSTORE":
+		return zDiffStore
+	case "ZRANGESTORE":
+		return zRangeStore
+	case "ZPOPMIN", "ZPOPMAX":
+		return zPop
+	case "BZPOPMIN", "BZPOPMAX":
+		return bZPop
+	case "ZMPOP":
+		return zMPop
+	case "BZMPOP":
+		return bZMPop
+	case "ZRANDMEMBER":
+		return zRandMember
+	case "ZMSCORE":
+		return zMScore
 	case "ZEXISTS":
 		return zExists
 	case "ZSCAN":
@@ -279,6 +368,60 @@ func GetCommand(name string) func(n *Nodis, conn *redis.Conn, cmd redis.Command)
 		return geoRadius
 	case "GEORADIUSBYMEMBER":
 		return geoRadiusByMember
+	case "TS.CREATE":
+		return tsCreate
+	case "TS.ADD":
+		return tsAdd
+	case "TS.MADD":
+		return tsMAdd
+	case "TS.INCRBY":
+		return tsIncrBy
+	case "TS.DECRBY":
+		return tsDecrBy
+	case "TS.GET":
+		return tsGet
+	case "TS.RANGE":
+		return tsRange
+	case "TS.REVRANGE":
+		return tsRevRange
+	case "TS.MRANGE":
+		return tsMRange
+	case "TS.MREVRANGE":
+		return tsMRevRange
+	case "TS.CREATERULE":
+		return tsCreateRule
+	case "TS.DELETERULE":
+		return tsDeleteRule
+	case "TS.INFO":
+		return tsInfo
+	case "VADD":
+		return vAdd
+	case "VREM":
+		return vRem
+	case "VSIM":
+		return vSim
+	case "VCARD":
+		return vCard
+	case "VDIM":
+		return vDim
+	case "VEMB":
+		return vEmb
+	case "VGETATTR":
+		return vGetAttr
+	case "VSETATTR":
+		return vSetAttr
+	case "FT.CREATE":
+		return ftCreate
+	case "FT.SEARCH":
+		return ftSearch
+	case "FT.AGGREGATE":
+		return ftAggregate
+	case "FT.DROPINDEX":
+		return ftDropIndex
+	case "FT.INFO":
+		return ftInfo
+	case "FT._LIST":
+		return ftList
 	}
 	return cmdNotFound
 }
@@ -331,6 +474,34 @@ func client(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 			conn.WriteBulk(conn.Name)
 		case "SETINFO":
 			conn.WriteString("OK")
+		case "ID":
+			conn.WriteInt64(int64(conn.Fd))
+		case "UNBLOCK":
+			if len(cmd.Args) < 2 || len(cmd.Args) > 3 {
+				conn.WriteError("ERR wrong number of arguments for 'CLIENT|UNBLOCK' command")
+				return
+			}
+			id, err := strconv.Atoi(cmd.Args[1])
+			if err != nil {
+				conn.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+			withError := false
+			if len(cmd.Args) == 3 {
+				switch strings.ToUpper(cmd.Args[2]) {
+				case "TIMEOUT":
+				case "ERROR":
+					withError = true
+				default:
+					conn.WriteError("ERR CLIENT UNBLOCK reason should be TIMEOUT or ERROR")
+					return
+				}
+			}
+			if n.UnblockClient(id, withError) {
+				conn.WriteInt64(1)
+			} else {
+				conn.WriteInt64(0)
+			}
 		default:
 			conn.WriteError("CLIENT subcommand must be provided")
 		}
@@ -343,17 +514,68 @@ func config(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 		return
 	}
 	execCommand(conn, func() {
-		if cmd.Args[0] == "GET" {
-			if len(cmd.Args) < 2 {
-				conn.WriteError("CONFIG GET requires at least one argument")
-				return
-			}
-			if strings.ToUpper(cmd.Args[1]) == "DATABASES" {
+		switch strings.ToUpper(cmd.Args[0]) {
+		case "GET":
+			param := strings.ToLower(cmd.Args[1])
+			if param == "databases" {
 				conn.WriteArray(2)
 				conn.WriteBulk("databases")
 				conn.WriteBulk("0")
 				return
 			}
+			switch param {
+			case "maxmemory":
+				maxMemory, _ := n.MaxMemory()
+				conn.WriteArray(2)
+				conn.WriteBulk(param)
+				conn.WriteBulk(strconv.FormatInt(maxMemory, 10))
+				return
+			case "maxmemory-policy":
+				_, policy := n.MaxMemory()
+				conn.WriteArray(2)
+				conn.WriteBulk(param)
+				conn.WriteBulk(policy.String())
+				return
+			}
+			if v, ok := n.store.encodings.Limit(param); ok {
+				conn.WriteArray(2)
+				conn.WriteBulk(param)
+				conn.WriteBulk(strconv.FormatInt(v, 10))
+				return
+			}
+		case "SET":
+			if len(cmd.Args) != 3 {
+				conn.WriteError("ERR wrong number of arguments for 'CONFIG|SET' command")
+				return
+			}
+			param := strings.ToLower(cmd.Args[1])
+			if param == "maxmemory" || param == "maxmemory-policy" {
+				maxMemory, policy := n.MaxMemory()
+				var err error
+				if param == "maxmemory" {
+					maxMemory, err = ParseMemory(cmd.Args[2])
+				} else {
+					policy, err = ParseEvictionPolicy(cmd.Args[2])
+				}
+				if err != nil {
+					conn.WriteError("ERR CONFIG SET failed (possibly related to argument '" + cmd.Args[1] + "') - argument couldn't be parsed")
+					return
+				}
+				n.SetMaxMemory(maxMemory, policy)
+				conn.WriteOK()
+				return
+			}
+			if _, ok := n.store.encodings.Limit(param); !ok {
+				conn.WriteError("ERR Unknown option or number of arguments for CONFIG SET - '" + cmd.Args[1] + "'")
+				return
+			}
+			v, err := strconv.ParseInt(cmd.Args[2], 10, 64)
+			if err != nil || !n.store.encodings.SetLimit(param, v) {
+				conn.WriteError("ERR CONFIG SET failed (possibly related to argument '" + cmd.Args[1] + "') - argument couldn't be parsed into an integer")
+				return
+			}
+			conn.WriteString("OK")
+			return
 		}
 		conn.WriteBulkNull()
 	})
@@ -376,6 +598,27 @@ func info(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 		if keys > 0 {
 			keyspace = "db0:keys=" + strconv.FormatInt(keys, 10) + ",expires=" + strconv.FormatInt(expires, 10) + ",avg_ttl=" + strconv.FormatInt(avgTTL, 10) + "\r\n"
 		}
+		bgSaveStatus := "ok"
+		if n.bgSaveFailed.Load() {
+			bgSaveStatus = "err"
+		}
+		saving := "0"
+		if n.store.snapshot.Load() != nil {
+			saving = "1"
+		}
+		total, saved := n.saveProgress()
+		var storageStats string
+		if stats, err := n.StorageStats(); err == nil {
+			storageStats = `storage_keys:` + strconv.FormatInt(stats.Keys, 10) + "\r\n" +
+				`storage_bytes:` + strconv.FormatInt(stats.Bytes, 10) + "\r\n"
+		}
+		maxMemory, policy := n.MaxMemory()
+		memory := n.MemoryStats()
+		codec, compression := n.CompressionStats()
+		compressionRatio := 1.0
+		if compression.CompressedBytes > 0 {
+			compressionRatio = float64(compression.Bytes) / float64(compression.CompressedBytes)
+		}
 		conn.WriteBulk(`# Server` + "\r\n" +
 			`redis_version:6.0.0` + "\r\n" +
 			`os:` + runtime.GOOS + "\r\n" +
@@ -383,12 +626,29 @@ func info(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 			`# Memory` + "\r\n" +
 			`used_memory:` + usedMemory + "\r\n" +
 			`used_memory_human:` + strconv.FormatUint(memStats.HeapInuse+memStats.StackInuse/1024, 10) + "KB" + "\r\n" +
-			`maxmemory:0` + "\r\n" +
-			`maxmemory_human:0B` + "\r\n" +
-			`maxmemory_policy:noeviction` + "\r\n" +
+			`used_memory_dataset:` + strconv.FormatInt(memory.Used, 10) + "\r\n" +
+			`maxmemory:` + strconv.FormatInt(maxMemory, 10) + "\r\n" +
+			`maxmemory_human:` + formatMemory(maxMemory) + "\r\n" +
+			`maxmemory_policy:` + policy.String() + "\r\n" +
 			`# Client` + "\r\n" +
 			`maxclients:10000` + "\r\n" +
 			`connected_clients:` + strconv.Itoa(len(redis.Clients)) + "\r\n" +
+			`# Stats` + "\r\n" +
+			`evicted_keys:` + strconv.FormatInt(memory.EvictedKeys, 10) + "\r\n" +
+			`offloaded_keys:` + strconv.FormatInt(memory.OffloadedKeys, 10) + "\r\n" +
+			`# Persistence` + "\r\n" +
+			`rdb_bgsave_in_progress:` + saving + "\r\n" +
+			`rdb_last_save_time:` + strconv.FormatInt(n.LastSave().Unix(), 10) + "\r\n" +
+			`rdb_last_bgsave_status:` + bgSaveStatus + "\r\n" +
+			`current_save_keys_processed:` + strconv.FormatInt(saved, 10) + "\r\n" +
+			`current_save_keys_total:` + strconv.FormatInt(total, 10) + "\r\n" +
+			storageStats +
+			`storage_quarantined_keys:` + strconv.Itoa(len(n.Quarantined())) + "\r\n" +
+			`storage_compression:` + codec.String() + "\r\n" +
+			`storage_compressed_entries:` + strconv.FormatInt(compression.Entries, 10) + "\r\n" +
+			`storage_compressed_input_bytes:` + strconv.FormatInt(compression.Bytes, 10) + "\r\n" +
+			`storage_compressed_output_bytes:` + strconv.FormatInt(compression.CompressedBytes, 10) + "\r\n" +
+			`storage_compression_ratio:` + strconv.FormatFloat(compressionRatio, 'f', 2, 64) + "\r\n" +
 			`# Keyspace` + "\r\n" + keyspace +
 			"\r\n")
 	})
@@ -716,6 +976,355 @@ func typ(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	})
 }
 
+// OBJECT ENCODING|IDLETIME|FREQ|REFCOUNT key
+func object(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 {
+		conn.WriteError("OBJECT subcommand must be provided")
+		return
+	}
+	execCommand(conn, func() {
+		switch strings.ToUpper(cmd.Args[0]) {
+		case "ENCODING":
+			if len(cmd.Args) != 2 {
+				conn.WriteError("ERR wrong number of arguments for 'OBJECT|ENCODING' command")
+				return
+			}
+			encoding := n.ObjectEncoding(cmd.Args[1])
+			if encoding == "" {
+				conn.WriteBulkNull()
+				return
+			}
+			conn.WriteBulk(encoding)
+		case "IDLETIME", "FREQ", "REFCOUNT":
+			sub := strings.ToUpper(cmd.Args[0])
+			if len(cmd.Args) != 2 {
+				conn.WriteError("ERR wrong number of arguments for 'OBJECT|" + sub + "' command")
+				return
+			}
+			var v int64
+			var ok bool
+			switch sub {
+			case "IDLETIME":
+				var idle time.Duration
+				idle, ok = n.ObjectIdleTime(cmd.Args[1])
+				v = int64(idle / time.Second)
+			case "FREQ":
+				v, ok = n.ObjectFreq(cmd.Args[1])
+			default:
+				v, ok = n.ObjectRefCount(cmd.Args[1])
+			}
+			if !ok {
+				conn.WriteBulkNull()
+				return
+			}
+			conn.WriteInt64(v)
+		case "HELP":
+			help := []string{
+				"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+				"ENCODING <key>",
+				"    Return the kind of internal representation used in order to store the value",
+				"    associated with a <key>.",
+				"FREQ <key>",
+				"    Return the access frequency index of the <key>. The returned integer is",
+				"    proportional to the logarithm of the recent access frequency of the key.",
+				"IDLETIME <key>",
+				"    Return the idle time of the <key>, that is the approximated number of",
+				"    seconds elapsed since the last access to the key.",
+				"REFCOUNT <key>",
+				"    Return the number of references of the value associated with the specified",
+				"    <key>.",
+			}
+			conn.WriteArray(len(help))
+			for _, line := range help {
+				conn.WriteString(line)
+			}
+		default:
+			conn.WriteError("ERR unknown subcommand '" + cmd.Args[0] + "'. Try OBJECT HELP.")
+		}
+	})
+}
+
+// COPY source destination [DB destination-db] [REPLACE]
+func copyKey(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'copy' command")
+		return
+	}
+	var replace bool
+	for i := 2; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(cmd.Args[i]) {
+		case "REPLACE":
+			replace = true
+		case "DB":
+			if i+1 >= len(cmd.Args) {
+				conn.WriteError("ERR syntax error")
+				return
+			}
+			// there is a single database
+			if cmd.Args[i+1] != "0" {
+				conn.WriteError("ERR DB index is out of range")
+				return
+			}
+			i++
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	if cmd.Args[0] == cmd.Args[1] {
+		conn.WriteError("ERR source and destination objects are the same")
+		return
+	}
+	execCommand(conn, func() {
+		if n.Copy(cmd.Args[0], cmd.Args[1], replace) {
+			conn.WriteInt64(1)
+			return
+		}
+		conn.WriteInt64(0)
+	})
+}
+
+// TOUCH key [key ...]
+func touch(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 {
+		conn.WriteError("ERR wrong number of arguments for 'touch' command")
+		return
+	}
+	execCommand(conn, func() {
+		conn.WriteInt64(n.Touch(cmd.Args...))
+	})
+}
+
+// EXPIRETIME key
+func expireTime(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) != 1 {
+		conn.WriteError("ERR wrong number of arguments for 'expiretime' command")
+		return
+	}
+	execCommand(conn, func() {
+		conn.WriteInt64(n.ExpireTime(cmd.Args[0]))
+	})
+}
+
+// PEXPIRETIME key
+func pExpireTime(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) != 1 {
+		conn.WriteError("ERR wrong number of arguments for 'pexpiretime' command")
+		return
+	}
+	execCommand(conn, func() {
+		conn.WriteInt64(n.PExpireTime(cmd.Args[0]))
+	})
+}
+
+// SORT key [BY pattern] [LIMIT offset count] [GET pattern [GET pattern ...]] [ASC|DESC] [ALPHA] [STORE destination]
+func sortKey(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 {
+		conn.WriteError("ERR wrong number of arguments for '" + strings.ToLower(cmd.Name) + "' command")
+		return
+	}
+	opts := &SortOptions{}
+	for i := 1; i < len(cmd.Args); i++ {
+		opt := strings.ToUpper(cmd.Args[i])
+		switch {
+		case opt == "ASC":
+			opts.Desc = false
+		case opt == "DESC":
+			opts.Desc = true
+		case opt == "ALPHA":
+			opts.Alpha = true
+		case opt == "LIMIT" && i+2 < len(cmd.Args):
+			offset, err := strconv.ParseInt(cmd.Args[i+1], 10, 64)
+			if err != nil {
+				conn.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+			count, err := strconv.ParseInt(cmd.Args[i+2], 10, 64)
+			if err != nil {
+				conn.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+			opts.Limit, opts.Offset, opts.Count = true, offset, count
+			i += 2
+		case opt == "BY" && i+1 < len(cmd.Args):
+			opts.By = cmd.Args[i+1]
+			i++
+		case opt == "GET" && i+1 < len(cmd.Args):
+			opts.Get = append(opts.Get, cmd.Args[i+1])
+			i++
+		case opt == "STORE" && i+1 < len(cmd.Args) && cmd.Name != "SORT_RO":
+			opts.Store = cmd.Args[i+1]
+			i++
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	execCommand(conn, func() {
+		result, err := n.Sort(cmd.Args[0], opts)
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		if opts.Store != "" {
+			conn.WriteInt64(int64(len(result)))
+			return
+		}
+		conn.WriteArray(len(result))
+		for _, v := range result {
+			if v == nil {
+				conn.WriteBulkNull()
+				continue
+			}
+			conn.WriteBulk(string(v))
+		}
+	})
+}
+
+// DUMP key
+func dump(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) != 1 {
+		conn.WriteError("ERR wrong number of arguments for 'dump' command")
+		return
+	}
+	execCommand(conn, func() {
+		v := n.Dump(cmd.Args[0])
+		if v == nil {
+			conn.WriteBulkNull()
+			return
+		}
+		conn.WriteBulk(string(v))
+	})
+}
+
+// RESTORE key ttl serialized-value [REPLACE] [ABSTTL] [IDLETIME seconds] [FREQ frequency]
+func restore(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'restore' command")
+		return
+	}
+	opts := &RestoreOptions{}
+	var absTTL, idle bool
+	for i := 3; i < len(cmd.Args); i++ {
+		opt := strings.ToUpper(cmd.Args[i])
+		switch {
+		case opt == "REPLACE":
+			opts.Replace = true
+		case opt == "ABSTTL":
+			absTTL = true
+		case opt == "IDLETIME" && i+1 < len(cmd.Args) && !opts.SetFreq:
+			seconds, err := strconv.ParseInt(cmd.Args[i+1], 10, 64)
+			if err != nil {
+				conn.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+			if seconds < 0 {
+				conn.WriteError("ERR Invalid IDLETIME value, must be >= 0")
+				return
+			}
+			opts.IdleTime = time.Duration(seconds) * time.Second
+			idle = true
+			i++
+		case opt == "FREQ" && i+1 < len(cmd.Args) && !idle:
+			freq, err := strconv.ParseInt(cmd.Args[i+1], 10, 64)
+			if err != nil {
+				conn.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+			if freq < 0 || freq > 255 {
+				conn.WriteError("ERR Invalid FREQ value, must be >= 0 and <= 255")
+				return
+			}
+			opts.SetFreq, opts.Freq = true, uint8(freq)
+			i++
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	ttl, err := strconv.ParseInt(cmd.Args[1], 10, 64)
+	if err != nil {
+		conn.WriteError("ERR value is not an integer or out of range")
+		return
+	}
+	if ttl < 0 {
+		conn.WriteError("ERR Invalid TTL value, must be >= 0")
+		return
+	}
+	if ttl > 0 {
+		opts.ExpireAt = ttl
+		if !absTTL {
+			opts.ExpireAt += time.Now().UnixMilli()
+		}
+	}
+	execCommand(conn, func() {
+		if err := n.Restore(cmd.Args[0], []byte(cmd.Args[2]), opts); err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteOK()
+	})
+}
+
+// MIGRATE host port key|"" destination-db timeout [COPY] [REPLACE] [AUTH password] [AUTH2 username password] [KEYS key [key ...]]
+func migrate(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 5 {
+		conn.WriteError("ERR wrong number of arguments for 'migrate' command")
+		return
+	}
+	opts := &MigrateOptions{}
+	keys := []string{cmd.Args[2]}
+	for i := 5; i < len(cmd.Args); i++ {
+		opt := strings.ToUpper(cmd.Args[i])
+		switch {
+		case opt == "COPY":
+			opts.Copy = true
+		case opt == "REPLACE":
+			opts.Replace = true
+		case opt == "AUTH" && i+1 < len(cmd.Args):
+			opts.Password = cmd.Args[i+1]
+			i++
+		case opt == "AUTH2" && i+2 < len(cmd.Args):
+			opts.Username, opts.Password = cmd.Args[i+1], cmd.Args[i+2]
+			i += 2
+		case opt == "KEYS":
+			if cmd.Args[2] != "" {
+				conn.WriteError("ERR When using MIGRATE KEYS option, the key argument must be set to the empty string")
+				return
+			}
+			keys = cmd.Args[i+1:]
+			i = len(cmd.Args)
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	db, err := strconv.ParseInt(cmd.Args[3], 10, 64)
+	if err != nil {
+		conn.WriteError("ERR value is not an integer or out of range")
+		return
+	}
+	timeout, err := strconv.ParseInt(cmd.Args[4], 10, 64)
+	if err != nil {
+		conn.WriteError("ERR value is not an integer or out of range")
+		return
+	}
+	opts.DB = db
+	opts.Timeout = time.Duration(timeout) * time.Millisecond
+	execCommand(conn, func() {
+		ok, err := n.Migrate(net.JoinHostPort(cmd.Args[0], cmd.Args[1]), keys, opts)
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		if !ok {
+			conn.WriteString("NOKEY")
+			return
+		}
+		conn.WriteOK()
+	})
+}
+
 // SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
 func scan(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	if len(cmd.Args) == 0 {
@@ -764,63 +1373,50 @@ func setString(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 		conn.WriteError("SET requires at least two arguments")
 		return
 	}
-	execCommand(conn, func() {
-		key := cmd.Args[0]
-		value := []byte(cmd.Args[1])
-		var get []byte
-		if cmd.Options.GET > 1 {
-			get = n.Get(key)
-		}
-		if cmd.Options.NX > 1 {
-			if !n.SetNX(key, value, cmd.Options.KEEPTTL > 1) {
-				conn.WriteBulkNull()
-				return
-			}
-		} else if cmd.Options.XX > 1 {
-			if !n.SetXX(key, value, cmd.Options.KEEPTTL > 1) {
-				conn.WriteBulkNull()
-				return
-			}
-		} else {
-			n.Set(key, value, cmd.Options.KEEPTTL > 1)
-		}
-		if cmd.Options.EX > 1 {
-			seconds, _ := strconv.ParseInt(cmd.Args[cmd.Options.EX], 10, 64)
-			n.Expire(key, seconds)
-			conn.WriteOK()
-			return
-		}
-		if cmd.Options.PX > 1 {
-			milliseconds, _ := strconv.ParseInt(cmd.Args[cmd.Options.PX], 10, 64)
-			if n.ExpirePX(key, milliseconds) == 0 {
-				conn.WriteBulkNull()
+	opts := &SetOptions{}
+	expire := false
+	for i := 2; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(cmd.Args[i]) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GET":
+			opts.Get = true
+		case "KEEPTTL":
+			opts.KeepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if expire || i+1 >= len(cmd.Args) {
+				conn.WriteError("ERR syntax error")
 				return
 			}
-			conn.WriteOK()
-			return
-		}
-		if cmd.Options.EXAT > 1 {
-			seconds, _ := strconv.ParseInt(cmd.Args[cmd.Options.EXAT], 10, 64)
-			if n.ExpireAt(key, time.Unix(seconds, 0)) == 0 {
-				conn.WriteBulkNull()
+			at, ok := parseExpiration(cmd.Args, i)
+			if !ok {
+				conn.WriteError("ERR invalid expire time in 'set' command")
 				return
 			}
-			conn.WriteOK()
+			opts.ExpireAt = at
+			expire = true
+			i++
+		default:
+			conn.WriteError("ERR syntax error")
 			return
 		}
-		if cmd.Options.PXAT > 1 {
-			milliseconds, _ := strconv.ParseInt(cmd.Args[cmd.Options.PXAT], 10, 64)
-			seconds := milliseconds / 1000
-			ns := (milliseconds - seconds*1000) * 1000 * 1000
-			n.ExpireAt(key, time.Unix(seconds, ns))
+	}
+	if (opts.NX && opts.XX) || (opts.KeepTTL && expire) {
+		conn.WriteError("ERR syntax error")
+		return
+	}
+	execCommand(conn, func() {
+		old, ok := n.SetWithOptions(cmd.Args[0], []byte(cmd.Args[1]), opts)
+		switch {
+		case opts.Get && old != nil:
+			conn.WriteBulk(string(old))
+		case opts.Get || !ok:
+			conn.WriteBulkNull()
+		default:
 			conn.WriteOK()
-			return
-		}
-		if get != nil {
-			conn.WriteBulk(string(get))
-			return
 		}
-		conn.WriteOK()
 	})
 }
 
@@ -837,6 +1433,21 @@ func mSet(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	})
 }
 
+// MSETNX key value [key value ...]
+func mSetNX(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 || len(cmd.Args)%2 != 0 {
+		conn.WriteError("ERR wrong number of arguments for 'msetnx' command")
+		return
+	}
+	execCommand(conn, func() {
+		if n.MSetNX(cmd.Args...) {
+			conn.WriteInt64(1)
+			return
+		}
+		conn.WriteInt64(0)
+	})
+}
+
 func appendString(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	if len(cmd.Args) < 2 {
 		conn.WriteError("APPEND requires at least two arguments")
@@ -863,14 +1474,35 @@ func setex(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	})
 }
 
-func setnx(n *Nodis, conn *redis.Conn, cmd redis.Command) {
-	if len(cmd.Args) < 2 {
-		conn.WriteError("SETNX requires at least two arguments")
+// PSETEX key milliseconds value
+func pSetex(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'psetex' command")
+		return
+	}
+	milliseconds, err := strconv.ParseInt(cmd.Args[1], 10, 64)
+	if err != nil {
+		conn.WriteError("ERR value is not an integer or out of range")
+		return
+	}
+	if milliseconds <= 0 {
+		conn.WriteError("ERR invalid expire time in 'psetex' command")
 		return
 	}
 	execCommand(conn, func() {
-		key := cmd.Args[0]
-		value := []byte(cmd.Args[1])
+		n.SetPX(cmd.Args[0], []byte(cmd.Args[2]), milliseconds)
+		conn.WriteOK()
+	})
+}
+
+func setnx(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("SETNX requires at least two arguments")
+		return
+	}
+	execCommand(conn, func() {
+		key := cmd.Args[0]
+		value := []byte(cmd.Args[1])
 		if n.SetNX(key, value, false) {
 			conn.WriteInt64(1)
 			return
@@ -989,6 +1621,136 @@ func getString(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	})
 }
 
+// GETEX key [EX seconds|PX milliseconds|EXAT timestamp|PXAT timestamp-milliseconds|PERSIST]
+func getEX(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 {
+		conn.WriteError("ERR wrong number of arguments for 'getex' command")
+		return
+	}
+	opts := &GetEXOptions{}
+	if len(cmd.Args) > 1 {
+		switch strings.ToUpper(cmd.Args[1]) {
+		case "PERSIST":
+			if len(cmd.Args) != 2 {
+				conn.WriteError("ERR syntax error")
+				return
+			}
+			opts.Persist = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if len(cmd.Args) != 3 {
+				conn.WriteError("ERR syntax error")
+				return
+			}
+			at, ok := parseExpiration(cmd.Args, 1)
+			if !ok {
+				conn.WriteError("ERR invalid expire time in 'getex' command")
+				return
+			}
+			opts.ExpireAt = at
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	execCommand(conn, func() {
+		v := n.GetEX(cmd.Args[0], opts)
+		if v == nil {
+			conn.WriteBulkNull()
+			return
+		}
+		conn.WriteBulk(string(v))
+	})
+}
+
+// GETDEL key
+func getDel(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) != 1 {
+		conn.WriteError("ERR wrong number of arguments for 'getdel' command")
+		return
+	}
+	execCommand(conn, func() {
+		v := n.GetDel(cmd.Args[0])
+		if v == nil {
+			conn.WriteBulkNull()
+			return
+		}
+		conn.WriteBulk(string(v))
+	})
+}
+
+// LCS key1 key2 [LEN] [IDX] [MINMATCHLEN len] [WITHMATCHLEN]
+func lcs(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'lcs' command")
+		return
+	}
+	var getLen, getIdx, withMatchLen bool
+	var minMatchLen int64
+	for i := 2; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(cmd.Args[i]) {
+		case "LEN":
+			getLen = true
+		case "IDX":
+			getIdx = true
+		case "WITHMATCHLEN":
+			withMatchLen = true
+		case "MINMATCHLEN":
+			if i+1 >= len(cmd.Args) {
+				conn.WriteError("ERR syntax error")
+				return
+			}
+			v, err := strconv.ParseInt(cmd.Args[i+1], 10, 64)
+			if err != nil {
+				conn.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+			minMatchLen = max(v, 0)
+			i++
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	if getLen && getIdx {
+		conn.WriteError("ERR If you want both the length and indexes, please just use IDX.")
+		return
+	}
+	execCommand(conn, func() {
+		v, matches, err := n.LCS(cmd.Args[0], cmd.Args[1], minMatchLen)
+		if err != nil {
+			conn.WriteError("ERR " + err.Error())
+			return
+		}
+		switch {
+		case getLen:
+			conn.WriteInt64(int64(len(v)))
+		case getIdx:
+			conn.WriteArray(4)
+			conn.WriteBulk("matches")
+			conn.WriteArray(len(matches))
+			for _, m := range matches {
+				if withMatchLen {
+					conn.WriteArray(3)
+				} else {
+					conn.WriteArray(2)
+				}
+				for _, r := range [][2]int64{m.A, m.B} {
+					conn.WriteArray(2)
+					conn.WriteInt64(r[0])
+					conn.WriteInt64(r[1])
+				}
+				if withMatchLen {
+					conn.WriteInt64(m.Len)
+				}
+			}
+			conn.WriteBulk("len")
+			conn.WriteInt64(int64(len(v)))
+		default:
+			conn.WriteBulk(string(v))
+		}
+	})
+}
+
 func getSet(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	if len(cmd.Args) < 2 {
 		conn.WriteError("GETSET requires at least two arguments")
@@ -1709,6 +2471,245 @@ func hVals(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	})
 }
 
+// parseHFields parses FIELDS numfields field ... at args[i], each field is followed by size-1 values
+func parseHFields(args []string, i int, size int) ([]string, string) {
+	if i >= len(args) || strings.ToUpper(args[i]) != "FIELDS" || i+1 >= len(args) {
+		return nil, "ERR Mandatory argument FIELDS is missing or not at the right position"
+	}
+	num, err := strconv.Atoi(args[i+1])
+	if err != nil || num <= 0 {
+		return nil, "ERR Parameter `numFields` should be greater than 0"
+	}
+	if len(args)-i-2 != num*size {
+		return nil, "ERR The `numfields` parameter must match the number of arguments"
+	}
+	return args[i+2:], ""
+}
+
+func writeHFieldResults(conn *redis.Conn, results []int64) {
+	conn.WriteArray(len(results))
+	for _, r := range results {
+		conn.WriteInt64(r)
+	}
+}
+
+func writeHFieldValues(conn *redis.Conn, values [][]byte) {
+	conn.WriteArray(len(values))
+	for _, v := range values {
+		if v == nil {
+			conn.WriteBulkNull()
+		} else {
+			conn.WriteBulk(string(v))
+		}
+	}
+}
+
+// HEXPIRE key seconds [NX|XX|GT|LT] FIELDS numfields field ...
+// HPEXPIRE, HEXPIREAT and HPEXPIREAT have the same arguments
+func hExpire(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError(cmd.Name + " requires at least four arguments")
+		return
+	}
+	v, err := strconv.ParseInt(cmd.Args[1], 10, 64)
+	if err != nil || v < 0 {
+		conn.WriteError("ERR value is not an integer or out of range")
+		return
+	}
+	cond := hash.ExpireAlways
+	i := 2
+	switch strings.ToUpper(cmd.Args[i]) {
+	case "NX":
+		cond = hash.ExpireNX
+	case "XX":
+		cond = hash.ExpireXX
+	case "GT":
+		cond = hash.ExpireGT
+	case "LT":
+		cond = hash.ExpireLT
+	}
+	if cond != hash.ExpireAlways {
+		i++
+	}
+	fields, errStr := parseHFields(cmd.Args, i, 1)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		key := cmd.Args[0]
+		var results []int64
+		switch cmd.Name {
+		case "HEXPIRE":
+			results = n.HExpire(key, v, cond, fields...)
+		case "HPEXPIRE":
+			results = n.HPExpire(key, v, cond, fields...)
+		case "HEXPIREAT":
+			results = n.HExpireAt(key, time.Unix(v, 0), cond, fields...)
+		default:
+			results = n.HExpireAt(key, time.UnixMilli(v), cond, fields...)
+		}
+		writeHFieldResults(conn, results)
+	})
+}
+
+// HTTL key FIELDS numfields field ...
+// HPTTL, HEXPIRETIME, HPEXPIRETIME and HPERSIST have the same arguments
+func hTTL(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError(cmd.Name + " requires at least three arguments")
+		return
+	}
+	fields, errStr := parseHFields(cmd.Args, 1, 1)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		key := cmd.Args[0]
+		var results []int64
+		switch cmd.Name {
+		case "HTTL":
+			results = n.HTTL(key, fields...)
+		case "HPTTL":
+			results = n.HPTTL(key, fields...)
+		case "HEXPIRETIME":
+			results = n.HExpireTime(key, fields...)
+		case "HPEXPIRETIME":
+			results = n.HPExpireTime(key, fields...)
+		default:
+			results = n.HPersist(key, fields...)
+		}
+		writeHFieldResults(conn, results)
+	})
+}
+
+// HGETDEL key FIELDS numfields field ...
+func hGetDel(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("HGETDEL requires at least three arguments")
+		return
+	}
+	fields, errStr := parseHFields(cmd.Args, 1, 1)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		writeHFieldValues(conn, n.HGetDel(cmd.Args[0], fields...))
+	})
+}
+
+// parseExpiration parses EX seconds, PX milliseconds, EXAT timestamp or PXAT timestamp-milliseconds
+// at args[i] and returns the unix time in milliseconds
+func parseExpiration(args []string, i int) (int64, bool) {
+	if i+1 >= len(args) {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(args[i+1], 10, 64)
+	if err != nil || v <= 0 {
+		return 0, false
+	}
+	switch strings.ToUpper(args[i]) {
+	case "EX":
+		return time.Now().UnixMilli() + v*1000, true
+	case "PX":
+		return time.Now().UnixMilli() + v, true
+	case "EXAT":
+		return v * 1000, true
+	case "PXAT":
+		return v, true
+	}
+	return 0, false
+}
+
+// HGETEX key [EX seconds|PX milliseconds|EXAT timestamp|PXAT timestamp-milliseconds|PERSIST] FIELDS numfields field ...
+func hGetEX(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("HGETEX requires at least three arguments")
+		return
+	}
+	opts := &HGetEXOptions{}
+	i := 1
+	switch strings.ToUpper(cmd.Args[i]) {
+	case "PERSIST":
+		opts.Persist = true
+		i++
+	case "EX", "PX", "EXAT", "PXAT":
+		at, ok := parseExpiration(cmd.Args, i)
+		if !ok {
+			conn.WriteError("ERR invalid expire time in 'hgetex' command")
+			return
+		}
+		opts.ExpireAt = at
+		i += 2
+	}
+	fields, errStr := parseHFields(cmd.Args, i, 1)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		writeHFieldValues(conn, n.HGetEX(cmd.Args[0], opts, fields...))
+	})
+}
+
+// HSETEX key [FNX|FXX] [EX seconds|PX milliseconds|EXAT timestamp|PXAT timestamp-milliseconds|KEEPTTL]
+// FIELDS numfields field value ...
+func hSetEX(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("HSETEX requires at least four arguments")
+		return
+	}
+	opts := &HSetEXOptions{}
+	i := 1
+	for ; i < len(cmd.Args); i++ {
+		opt := strings.ToUpper(cmd.Args[i])
+		if opt == "FIELDS" {
+			break
+		}
+		switch opt {
+		case "FNX":
+			opts.NX = true
+		case "FXX":
+			opts.XX = true
+		case "KEEPTTL":
+			opts.KeepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			at, ok := parseExpiration(cmd.Args, i)
+			if !ok {
+				conn.WriteError("ERR invalid expire time in 'hsetex' command")
+				return
+			}
+			opts.ExpireAt = at
+			i++
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	if (opts.NX && opts.XX) || (opts.KeepTTL && opts.ExpireAt != 0) {
+		conn.WriteError("ERR syntax error")
+		return
+	}
+	args, errStr := parseHFields(cmd.Args, i, 2)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	fields := make(map[string][]byte, len(args)/2)
+	for j := 0; j < len(args); j += 2 {
+		fields[args[j]] = []byte(args[j+1])
+	}
+	execCommand(conn, func() {
+		if n.HSetEX(cmd.Args[0], fields, opts) {
+			conn.WriteInt64(1)
+		} else {
+			conn.WriteInt64(0)
+		}
+	})
+}
+
 func lPush(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	if len(cmd.Args) < 2 {
 		conn.WriteError("LPUSH requires at least two arguments")
@@ -2003,20 +3004,26 @@ func bLPop(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	for i := 0; i < len(cmd.Args)-1; i++ {
 		keys = append(keys, cmd.Args[i])
 	}
-	timeout, err := strconv.ParseFloat(cmd.Args[len(cmd.Args)-1], 64)
-	if err != nil {
-		conn.WriteError("ERR timeout value is not an integer or out of range")
+	timeout, errStr := parseTimeout(cmd.Args[len(cmd.Args)-1])
+	if errStr != "" {
+		conn.WriteError(errStr)
 		return
 	}
 	execCommand(conn, func() {
-		k, v := n.BLPop(time.Duration(timeout*time.Second.Seconds())*time.Second, keys...)
+		c, stop := newBlockedClient(conn)
+		defer stop()
+		k, v, err := n.bLMPop(c, timeout, keys, list.Left, 1)
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
 		if k == "" {
 			conn.WriteArrayNull()
 			return
 		}
 		conn.WriteArray(2)
 		conn.WriteBulk(k)
-		conn.WriteBulk(string(v))
+		conn.WriteBulk(string(v[0]))
 	})
 }
 
@@ -2029,91 +3036,332 @@ func bRPop(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	for i := 0; i < len(cmd.Args)-1; i++ {
 		keys = append(keys, cmd.Args[i])
 	}
-	timeout, err := strconv.ParseFloat(cmd.Args[len(cmd.Args)-1], 64)
-	if err != nil {
-		conn.WriteError("ERR timeout value is not an integer or out of range")
+	timeout, errStr := parseTimeout(cmd.Args[len(cmd.Args)-1])
+	if errStr != "" {
+		conn.WriteError(errStr)
 		return
 	}
 	execCommand(conn, func() {
-		k, v := n.BRPop(time.Duration(timeout*time.Second.Seconds())*time.Second, keys...)
+		c, stop := newBlockedClient(conn)
+		defer stop()
+		k, v, err := n.bLMPop(c, timeout, keys, list.Right, 1)
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
 		if k == "" {
 			conn.WriteArrayNull()
 			return
 		}
 		conn.WriteArray(2)
 		conn.WriteBulk(k)
-		conn.WriteBulk(string(v))
+		conn.WriteBulk(string(v[0]))
 	})
 }
+func parseListDirection(s string) (list.Direction, bool) {
+	switch strings.ToUpper(s) {
+	case "LEFT":
+		return list.Left, true
+	case "RIGHT":
+		return list.Right, true
+	}
+	return 0, false
+}
 
-// ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member   ...]
-func zAdd(n *Nodis, conn *redis.Conn, cmd redis.Command) {
-	if len(cmd.Args) < 3 {
-		conn.WriteError("ZADD requires at least three arguments")
+// LMOVE source destination LEFT|RIGHT LEFT|RIGHT
+// BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+func lMove(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	argc := 4
+	if cmd.Name == "BLMOVE" {
+		argc = 5
+	}
+	if len(cmd.Args) != argc {
+		conn.WriteError("ERR wrong number of arguments for '" + cmd.Name + "' command")
 		return
 	}
-	itemStart := max(cmd.Options.NX, cmd.Options.XX, cmd.Options.LT, cmd.Options.GT, cmd.Options.CH, cmd.Options.INCR)
-	if itemStart+1 > len(cmd.Args) {
-		conn.WriteError("ZADD requires at least one score-member pair")
+	wherefrom, ok := parseListDirection(cmd.Args[2])
+	if !ok {
+		conn.WriteError("ERR syntax error")
 		return
 	}
-	itemStart++
-	key := cmd.Args[0]
-	var count int64 = 0
+	whereto, ok := parseListDirection(cmd.Args[3])
+	if !ok {
+		conn.WriteError("ERR syntax error")
+		return
+	}
+	var timeout time.Duration
+	if cmd.Name == "BLMOVE" {
+		var errStr string
+		timeout, errStr = parseTimeout(cmd.Args[4])
+		if errStr != "" {
+			conn.WriteError(errStr)
+			return
+		}
+	}
 	execCommand(conn, func() {
-		for i := itemStart; i < len(cmd.Args); i += 2 {
-			if i+2 > len(cmd.Args) {
-				break
-			}
-			score, err := strconv.ParseFloat(cmd.Args[i], 64)
+		var v []byte
+		if cmd.Name == "BLMOVE" {
+			c, stop := newBlockedClient(conn)
+			defer stop()
+			var err error
+			v, err = n.bLMove(c, timeout, cmd.Args[0], cmd.Args[1], wherefrom, whereto)
 			if err != nil {
-				conn.WriteError("ERR score value is not a valid float")
-				return
-			}
-			member := cmd.Args[i+1]
-			if cmd.Options.INCR > 0 {
-				score = n.ZIncrBy(key, member, score)
-				conn.WriteBulk(strconv.FormatFloat(score, 'f', -1, 64))
-				return
-			}
-			if cmd.Options.XX > 0 {
-				conn.WriteInt64(n.ZAddXX(key, member, score))
-				return
-			}
-			if cmd.Options.NX > 0 {
-				conn.WriteInt64(n.ZAddNX(key, member, score))
-				return
-			}
-			if cmd.Options.LT > 0 {
-				conn.WriteInt64(n.ZAddLT(key, member, score))
-				return
-			}
-			if cmd.Options.GT > 0 {
-				conn.WriteInt64(n.ZAddGT(key, member, score))
+				conn.WriteError(err.Error())
 				return
 			}
-			count += n.ZAdd(key, member, score)
+		} else {
+			v = n.LMove(cmd.Args[0], cmd.Args[1], wherefrom, whereto)
 		}
-		conn.WriteInt64(count)
+		if v == nil {
+			conn.WriteBulkNull()
+			return
+		}
+		conn.WriteBulk(string(v))
 	})
 }
 
-func zCard(n *Nodis, conn *redis.Conn, cmd redis.Command) {
-	if len(cmd.Args) == 0 {
-		conn.WriteError("ZCARD requires at least one argument")
+// BRPOPLPUSH source destination timeout
+func bRPopLPush(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'BRPOPLPUSH' command")
+		return
+	}
+	timeout, errStr := parseTimeout(cmd.Args[2])
+	if errStr != "" {
+		conn.WriteError(errStr)
 		return
 	}
 	execCommand(conn, func() {
-		key := cmd.Args[0]
-		conn.WriteInt64(n.ZCard(key))
+		c, stop := newBlockedClient(conn)
+		defer stop()
+		v, err := n.bLMove(c, timeout, cmd.Args[0], cmd.Args[1], list.Right, list.Left)
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		if v == nil {
+			conn.WriteBulkNull()
+			return
+		}
+		conn.WriteBulk(string(v))
 	})
 }
 
-// ZRANK key member [WITHSCORE]
-func zRank(n *Nodis, conn *redis.Conn, cmd redis.Command) {
-	if len(cmd.Args) < 2 {
-		conn.WriteError("ZRANK requires at least two argument")
-		return
+// parseListPopCount parses LEFT|RIGHT [COUNT count] at i
+func parseListPopCount(args []string, i int) (list.Direction, int64, string) {
+	if i >= len(args) {
+		return 0, 0, "ERR syntax error"
+	}
+	where, ok := parseListDirection(args[i])
+	if !ok {
+		return 0, 0, "ERR syntax error"
+	}
+	var count int64 = 1
+	if i+1 < len(args) {
+		if strings.ToUpper(args[i+1]) != "COUNT" || i+3 != len(args) {
+			return 0, 0, "ERR syntax error"
+		}
+		v, err := strconv.ParseInt(args[i+2], 10, 64)
+		if err != nil || v <= 0 {
+			return 0, 0, "ERR count should be greater than 0"
+		}
+		count = v
+	}
+	return where, count, ""
+}
+
+func writeLMPop(conn *redis.Conn, key string, values [][]byte) {
+	if key == "" {
+		conn.WriteArrayNull()
+		return
+	}
+	conn.WriteArray(2)
+	conn.WriteBulk(key)
+	conn.WriteArray(len(values))
+	for _, v := range values {
+		conn.WriteBulk(string(v))
+	}
+}
+
+// LMPOP numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func lMPop(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	keys, i, errStr := parseNumKeys(cmd.Args, 0)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	where, count, errStr := parseListPopCount(cmd.Args, i)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		key, values := n.LMPop(keys, where, count)
+		writeLMPop(conn, key, values)
+	})
+}
+
+// BLMPOP timeout numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func bLMPop(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 1 {
+		conn.WriteError("ERR wrong number of arguments for 'BLMPOP' command")
+		return
+	}
+	timeout, errStr := parseTimeout(cmd.Args[0])
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	keys, i, errStr := parseNumKeys(cmd.Args, 1)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	where, count, errStr := parseListPopCount(cmd.Args, i)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		c, stop := newBlockedClient(conn)
+		defer stop()
+		key, values, err := n.bLMPop(c, timeout, keys, where, count)
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		writeLMPop(conn, key, values)
+	})
+}
+
+// LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+func lPos(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'LPOS' command")
+		return
+	}
+	var rank, count, maxLen int64 = 1, 1, 0
+	var withCount bool
+	for i := 2; i < len(cmd.Args); i += 2 {
+		if i+1 >= len(cmd.Args) {
+			conn.WriteError("ERR syntax error")
+			return
+		}
+		v, err := strconv.ParseInt(cmd.Args[i+1], 10, 64)
+		if err != nil {
+			conn.WriteError("ERR value is not an integer or out of range")
+			return
+		}
+		switch strings.ToUpper(cmd.Args[i]) {
+		case "RANK":
+			if v == 0 || v == math.MinInt64 {
+				conn.WriteError("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+				return
+			}
+			rank = v
+		case "COUNT":
+			if v < 0 {
+				conn.WriteError("ERR COUNT can't be negative")
+				return
+			}
+			count = v
+			withCount = true
+		case "MAXLEN":
+			if v < 0 {
+				conn.WriteError("ERR MAXLEN can't be negative")
+				return
+			}
+			maxLen = v
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	execCommand(conn, func() {
+		v := n.LPos(cmd.Args[0], []byte(cmd.Args[1]), rank, count, maxLen)
+		if withCount {
+			conn.WriteArray(len(v))
+			for _, index := range v {
+				conn.WriteInt64(index)
+			}
+			return
+		}
+		if len(v) == 0 {
+			conn.WriteBulkNull()
+			return
+		}
+		conn.WriteInt64(v[0])
+	})
+}
+
+// ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member   ...]
+func zAdd(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ZADD requires at least three arguments")
+		return
+	}
+	itemStart := max(cmd.Options.NX, cmd.Options.XX, cmd.Options.LT, cmd.Options.GT, cmd.Options.CH, cmd.Options.INCR)
+	if itemStart+1 > len(cmd.Args) {
+		conn.WriteError("ZADD requires at least one score-member pair")
+		return
+	}
+	itemStart++
+	key := cmd.Args[0]
+	var count int64 = 0
+	execCommand(conn, func() {
+		for i := itemStart; i < len(cmd.Args); i += 2 {
+			if i+2 > len(cmd.Args) {
+				break
+			}
+			score, err := strconv.ParseFloat(cmd.Args[i], 64)
+			if err != nil {
+				conn.WriteError("ERR score value is not a valid float")
+				return
+			}
+			member := cmd.Args[i+1]
+			if cmd.Options.INCR > 0 {
+				score = n.ZIncrBy(key, member, score)
+				conn.WriteBulk(strconv.FormatFloat(score, 'f', -1, 64))
+				return
+			}
+			if cmd.Options.XX > 0 {
+				conn.WriteInt64(n.ZAddXX(key, member, score))
+				return
+			}
+			if cmd.Options.NX > 0 {
+				conn.WriteInt64(n.ZAddNX(key, member, score))
+				return
+			}
+			if cmd.Options.LT > 0 {
+				conn.WriteInt64(n.ZAddLT(key, member, score))
+				return
+			}
+			if cmd.Options.GT > 0 {
+				conn.WriteInt64(n.ZAddGT(key, member, score))
+				return
+			}
+			count += n.ZAdd(key, member, score)
+		}
+		conn.WriteInt64(count)
+	})
+}
+
+func zCard(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 {
+		conn.WriteError("ZCARD requires at least one argument")
+		return
+	}
+	execCommand(conn, func() {
+		key := cmd.Args[0]
+		conn.WriteInt64(n.ZCard(key))
+	})
+}
+
+// ZRANK key member [WITHSCORE]
+func zRank(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ZRANK requires at least two argument")
+		return
 	}
 	execCommand(conn, func() {
 		key := cmd.Args[0]
@@ -2207,6 +3455,43 @@ func zRange(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 		return
 	}
 	key := cmd.Args[0]
+	if cmd.Options.BYLEX > 2 {
+		if cmd.Options.WITHSCORES > 2 {
+			conn.WriteError("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+			return
+		}
+		var offset, count int64 = 0, -1
+		if cmd.Options.LIMIT > 2 {
+			var errStr string
+			offset, count, errStr = parseZLexLimit(cmd.Args[cmd.Options.LIMIT-1:min(cmd.Options.LIMIT+2, len(cmd.Args))], 0)
+			if errStr != "" {
+				conn.WriteError(errStr)
+				return
+			}
+		}
+		min, max := cmd.Args[1], cmd.Args[2]
+		if cmd.Options.REV > 2 {
+			min, max = max, min
+		}
+		execCommand(conn, func() {
+			var results []string
+			var err error
+			if cmd.Options.REV > 2 {
+				results, err = n.ZRevRangeByLex(key, min, max, offset, count)
+			} else {
+				results, err = n.ZRangeByLex(key, min, max, offset, count)
+			}
+			if err != nil {
+				conn.WriteError(err.Error())
+				return
+			}
+			conn.WriteArray(len(results))
+			for _, v := range results {
+				conn.WriteBulk(v)
+			}
+		})
+		return
+	}
 	var mode int
 	if cmd.Options.BYSCORE > 2 {
 		if cmd.Args[1][0] == '(' {
@@ -2417,6 +3702,88 @@ func zRangeByScore(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	})
 }
 
+// parseZLexLimit parses the LIMIT offset count at args[i], the count is -1 without LIMIT
+func parseZLexLimit(args []string, i int) (int64, int64, string) {
+	if i >= len(args) {
+		return 0, -1, ""
+	}
+	if strings.ToUpper(args[i]) != "LIMIT" || i+3 != len(args) {
+		return 0, 0, "ERR syntax error"
+	}
+	offset, err := strconv.ParseInt(args[i+1], 10, 64)
+	if err != nil {
+		return 0, 0, "ERR offset value is not an integer or out of range"
+	}
+	count, err := strconv.ParseInt(args[i+2], 10, 64)
+	if err != nil {
+		return 0, 0, "ERR count value is not an integer or out of range"
+	}
+	return offset, count, ""
+}
+
+// ZRANGEBYLEX key min max [LIMIT offset count]
+// ZREVRANGEBYLEX key max min [LIMIT offset count]
+func zRangeByLex(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError(cmd.Name + " requires at least three arguments")
+		return
+	}
+	offset, count, errStr := parseZLexLimit(cmd.Args, 3)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		var results []string
+		var err error
+		if cmd.Name == "ZREVRANGEBYLEX" {
+			results, err = n.ZRevRangeByLex(cmd.Args[0], cmd.Args[2], cmd.Args[1], offset, count)
+		} else {
+			results, err = n.ZRangeByLex(cmd.Args[0], cmd.Args[1], cmd.Args[2], offset, count)
+		}
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteArray(len(results))
+		for _, v := range results {
+			conn.WriteBulk(v)
+		}
+	})
+}
+
+// ZLEXCOUNT key min max
+func zLexCount(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ZLEXCOUNT requires at least three arguments")
+		return
+	}
+	execCommand(conn, func() {
+		v, err := n.ZLexCount(cmd.Args[0], cmd.Args[1], cmd.Args[2])
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteInt64(v)
+	})
+}
+
+// ZREMRANGEBYLEX key min max
+func zRemRangeByLex(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ZREMRANGEBYLEX requires at least three arguments")
+		return
+	}
+	execCommand(conn, func() {
+		v, err := n.ZRemRangeByLex(cmd.Args[0], cmd.Args[1], cmd.Args[2])
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteInt64(v)
+	})
+}
+
 func zRevRangeByScore(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	if len(cmd.Args) < 3 {
 		conn.WriteError("ZREVRANGEBYSCORE requires at least three arguments")
@@ -2630,46 +3997,467 @@ func zInterStore(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	})
 }
 
-func zClear(n *Nodis, conn *redis.Conn, cmd redis.Command) {
-	if len(cmd.Args) == 0 {
-		conn.WriteError("ZCLEAR requires at least one argument")
+// parseNumKeys parses numkeys key [key ...] at i, it returns the keys and the index of the next argument
+func parseNumKeys(args []string, i int) ([]string, int, string) {
+	if i >= len(args) {
+		return nil, 0, "ERR syntax error"
 	}
-	execCommand(conn, func() {
-		key := cmd.Args[0]
-		n.ZClear(key)
-		conn.WriteString("OK")
-	})
+	num, err := strconv.Atoi(args[i])
+	if err != nil {
+		return nil, 0, "ERR numkeys value is not a valid integer"
+	}
+	if num <= 0 {
+		return nil, 0, "ERR numkeys can't be non-positive value"
+	}
+	if i+1+num > len(args) {
+		return nil, 0, "ERR Number of keys can't be greater than number of args"
+	}
+	return args[i+1 : i+1+num], i + 1 + num, ""
 }
 
-func zExists(n *Nodis, conn *redis.Conn, cmd redis.Command) {
-	if len(cmd.Args) < 2 {
-		conn.WriteError("ZEXISTS requires at least two arguments")
+func writeZItems(conn *redis.Conn, items []*zset.Item, withScores bool) {
+	if withScores {
+		conn.WriteArray(len(items) * 2)
+	} else {
+		conn.WriteArray(len(items))
 	}
-	execCommand(conn, func() {
-		key := cmd.Args[0]
-		member := cmd.Args[1]
-		is := n.ZExists(key, member)
-		var r int64 = 0
-		if is {
-			r = 1
+	for _, item := range items {
+		conn.WriteBulk(item.Member)
+		if withScores {
+			conn.WriteBulk(strconv.FormatFloat(item.Score, 'f', -1, 64))
 		}
-		conn.WriteInt64(r)
-	})
+	}
 }
 
-func zScan(n *Nodis, conn *redis.Conn, cmd redis.Command) {
-	if len(cmd.Args) < 2 {
-		conn.WriteError("ZSCAN requires at least two arguments")
-		return
+// parseZPopCount parses MIN|MAX [COUNT count] at i
+func parseZPopCount(args []string, i int) (bool, int64, string) {
+	if i >= len(args) {
+		return false, 0, "ERR syntax error"
+	}
+	var max bool
+	switch strings.ToUpper(args[i]) {
+	case "MIN":
+	case "MAX":
+		max = true
+	default:
+		return false, 0, "ERR syntax error"
 	}
-	key := cmd.Args[0]
-	cursor, err := strconv.ParseInt(cmd.Args[1], 10, 64)
-	if err != nil {
-		conn.WriteError("ERR cursor value is not a valid integer")
+	var count int64 = 1
+	if i+1 < len(args) {
+		if strings.ToUpper(args[i+1]) != "COUNT" || i+3 != len(args) {
+			return false, 0, "ERR syntax error"
+		}
+		v, err := strconv.ParseInt(args[i+2], 10, 64)
+		if err != nil || v <= 0 {
+			return false, 0, "ERR count should be greater than 0"
+		}
+		count = v
+	}
+	return max, count, ""
+}
+
+func writeZMPop(conn *redis.Conn, key string, items []*zset.Item) {
+	if key == "" {
+		conn.WriteArrayNull()
 		return
 	}
-	var match = "*"
-	if cmd.Options.MATCH > 0 {
+	conn.WriteArray(2)
+	conn.WriteBulk(key)
+	conn.WriteArray(len(items))
+	for _, item := range items {
+		conn.WriteArray(2)
+		conn.WriteBulk(item.Member)
+		conn.WriteBulk(strconv.FormatFloat(item.Score, 'f', -1, 64))
+	}
+}
+
+func parseTimeout(s string) (time.Duration, string) {
+	timeout, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, "ERR timeout is not a float or out of range"
+	}
+	if timeout < 0 {
+		return 0, "ERR timeout is negative"
+	}
+	return time.Duration(timeout * float64(time.Second)), ""
+}
+
+// ZPOPMIN key [count]
+// ZPOPMAX key [count]
+func zPop(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
+		conn.WriteError("ERR wrong number of arguments for '" + cmd.Name + "' command")
+		return
+	}
+	var count int64 = 1
+	if len(cmd.Args) == 2 {
+		v, err := strconv.ParseInt(cmd.Args[1], 10, 64)
+		if err != nil || v < 0 {
+			conn.WriteError("ERR value is out of range, must be positive")
+			return
+		}
+		count = v
+	}
+	execCommand(conn, func() {
+		var items []*zset.Item
+		if cmd.Name == "ZPOPMAX" {
+			items = n.ZPopMax(cmd.Args[0], count)
+		} else {
+			items = n.ZPopMin(cmd.Args[0], count)
+		}
+		writeZItems(conn, items, true)
+	})
+}
+
+// BZPOPMIN key [key ...] timeout
+// BZPOPMAX key [key ...] timeout
+func bZPop(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for '" + cmd.Name + "' command")
+		return
+	}
+	timeout, errStr := parseTimeout(cmd.Args[len(cmd.Args)-1])
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	keys := cmd.Args[:len(cmd.Args)-1]
+	execCommand(conn, func() {
+		c, stop := newBlockedClient(conn)
+		defer stop()
+		key, items, err := n.bZMPop(c, timeout, keys, cmd.Name == "BZPOPMAX", 1)
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		if len(items) == 0 {
+			conn.WriteArrayNull()
+			return
+		}
+		conn.WriteArray(3)
+		conn.WriteBulk(key)
+		conn.WriteBulk(items[0].Member)
+		conn.WriteBulk(strconv.FormatFloat(items[0].Score, 'f', -1, 64))
+	})
+}
+
+// ZMPOP numkeys key [key ...] MIN|MAX [COUNT count]
+func zMPop(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	keys, i, errStr := parseNumKeys(cmd.Args, 0)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	max, count, errStr := parseZPopCount(cmd.Args, i)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		key, items := n.ZMPop(keys, max, count)
+		writeZMPop(conn, key, items)
+	})
+}
+
+// BZMPOP timeout numkeys key [key ...] MIN|MAX [COUNT count]
+func bZMPop(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 1 {
+		conn.WriteError("ERR wrong number of arguments for 'BZMPOP' command")
+		return
+	}
+	timeout, errStr := parseTimeout(cmd.Args[0])
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	keys, i, errStr := parseNumKeys(cmd.Args, 1)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	max, count, errStr := parseZPopCount(cmd.Args, i)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		c, stop := newBlockedClient(conn)
+		defer stop()
+		key, items, err := n.bZMPop(c, timeout, keys, max, count)
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		writeZMPop(conn, key, items)
+	})
+}
+
+// ZRANDMEMBER key [count [WITHSCORES]]
+func zRandMember(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 1 || len(cmd.Args) > 3 {
+		conn.WriteError("ERR wrong number of arguments for 'ZRANDMEMBER' command")
+		return
+	}
+	key := cmd.Args[0]
+	if len(cmd.Args) == 1 {
+		execCommand(conn, func() {
+			items := n.ZRandMember(key, 1)
+			if len(items) == 0 {
+				conn.WriteBulkNull()
+				return
+			}
+			conn.WriteBulk(items[0].Member)
+		})
+		return
+	}
+	count, err := strconv.ParseInt(cmd.Args[1], 10, 64)
+	if err != nil {
+		conn.WriteError("ERR value is not an integer or out of range")
+		return
+	}
+	withScores := len(cmd.Args) == 3
+	if withScores && strings.ToUpper(cmd.Args[2]) != "WITHSCORES" {
+		conn.WriteError("ERR syntax error")
+		return
+	}
+	execCommand(conn, func() {
+		writeZItems(conn, n.ZRandMember(key, count), withScores)
+	})
+}
+
+// ZMSCORE key member [member ...]
+func zMScore(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'ZMSCORE' command")
+		return
+	}
+	execCommand(conn, func() {
+		scores := n.ZMScore(cmd.Args[0], cmd.Args[1:]...)
+		conn.WriteArray(len(scores))
+		for _, score := range scores {
+			if score == nil {
+				conn.WriteBulkNull()
+				continue
+			}
+			conn.WriteBulk(strconv.FormatFloat(*score, 'f', -1, 64))
+		}
+	})
+}
+
+// ZDIFF numkeys key [key ...] [WITHSCORES]
+func zDiff(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	keys, i, errStr := parseNumKeys(cmd.Args, 0)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	withScores := i < len(cmd.Args)
+	if withScores && (strings.ToUpper(cmd.Args[i]) != "WITHSCORES" || i+1 != len(cmd.Args)) {
+		conn.WriteError("ERR syntax error")
+		return
+	}
+	execCommand(conn, func() {
+		writeZItems(conn, n.ZDiff(keys), withScores)
+	})
+}
+
+// ZDIFFSTORE destination numkeys key [key ...]
+func zDiffStore(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 1 {
+		conn.WriteError("ERR wrong number of arguments for 'ZDIFFSTORE' command")
+		return
+	}
+	keys, i, errStr := parseNumKeys(cmd.Args, 1)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	if i != len(cmd.Args) {
+		conn.WriteError("ERR syntax error")
+		return
+	}
+	execCommand(conn, func() {
+		conn.WriteInt64(n.ZDiffStore(cmd.Args[0], keys))
+	})
+}
+
+// ZINTERCARD numkeys key [key ...] [LIMIT limit]
+func zInterCard(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	keys, i, errStr := parseNumKeys(cmd.Args, 0)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	var limit int64
+	if i < len(cmd.Args) {
+		if strings.ToUpper(cmd.Args[i]) != "LIMIT" || i+2 != len(cmd.Args) {
+			conn.WriteError("ERR syntax error")
+			return
+		}
+		v, err := strconv.ParseInt(cmd.Args[i+1], 10, 64)
+		if err != nil || v < 0 {
+			conn.WriteError("ERR LIMIT can't be negative")
+			return
+		}
+		limit = v
+	}
+	execCommand(conn, func() {
+		conn.WriteInt64(n.ZInterCard(keys, limit))
+	})
+}
+
+// ZUNION numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+// ZINTER has the same arguments
+func zUnionInter(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	keys, i, errStr := parseNumKeys(cmd.Args, 0)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	var weights []float64
+	var aggregate string
+	var withScores bool
+	for i < len(cmd.Args) {
+		switch strings.ToUpper(cmd.Args[i]) {
+		case "WEIGHTS":
+			if i+len(keys) >= len(cmd.Args) {
+				conn.WriteError("ERR syntax error")
+				return
+			}
+			weights = make([]float64, len(keys))
+			for j := range weights {
+				w, err := strconv.ParseFloat(cmd.Args[i+1+j], 64)
+				if err != nil {
+					conn.WriteError("ERR weight value is not a float")
+					return
+				}
+				weights[j] = w
+			}
+			i += len(keys) + 1
+		case "AGGREGATE":
+			if i+1 >= len(cmd.Args) {
+				conn.WriteError("ERR syntax error")
+				return
+			}
+			aggregate = strings.ToUpper(cmd.Args[i+1])
+			if aggregate != "SUM" && aggregate != "MIN" && aggregate != "MAX" {
+				conn.WriteError("ERR syntax error")
+				return
+			}
+			i += 2
+		case "WITHSCORES":
+			withScores = true
+			i++
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	execCommand(conn, func() {
+		if cmd.Name == "ZINTER" {
+			writeZItems(conn, n.ZInter(keys, weights, aggregate), withScores)
+			return
+		}
+		writeZItems(conn, n.ZUnion(keys, weights, aggregate), withScores)
+	})
+}
+
+// ZRANGESTORE dst src min max [BYSCORE|BYLEX] [REV] [LIMIT offset count]
+func zRangeStore(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'ZRANGESTORE' command")
+		return
+	}
+	opts := &ZRangeStoreOptions{}
+	for i := 4; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(cmd.Args[i]) {
+		case "BYSCORE":
+			opts.ByScore = true
+		case "BYLEX":
+			opts.ByLex = true
+		case "REV":
+			opts.Rev = true
+		case "LIMIT":
+			if i+2 >= len(cmd.Args) {
+				conn.WriteError("ERR syntax error")
+				return
+			}
+			var err error
+			opts.Offset, err = strconv.ParseInt(cmd.Args[i+1], 10, 64)
+			if err != nil {
+				conn.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+			opts.Count, err = strconv.ParseInt(cmd.Args[i+2], 10, 64)
+			if err != nil {
+				conn.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+			opts.Limit = true
+			i += 2
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	if opts.ByScore && opts.ByLex {
+		conn.WriteError("ERR syntax error")
+		return
+	}
+	if opts.Limit && !opts.ByScore && !opts.ByLex {
+		conn.WriteError("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+		return
+	}
+	execCommand(conn, func() {
+		v, err := n.ZRangeStore(cmd.Args[0], cmd.Args[1], cmd.Args[2], cmd.Args[3], opts)
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteInt64(v)
+	})
+}
+
+func zClear(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 {
+		conn.WriteError("ZCLEAR requires at least one argument")
+	}
+	execCommand(conn, func() {
+		key := cmd.Args[0]
+		n.ZClear(key)
+		conn.WriteString("OK")
+	})
+}
+
+func zExists(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ZEXISTS requires at least two arguments")
+	}
+	execCommand(conn, func() {
+		key := cmd.Args[0]
+		member := cmd.Args[1]
+		is := n.ZExists(key, member)
+		var r int64 = 0
+		if is {
+			r = 1
+		}
+		conn.WriteInt64(r)
+	})
+}
+
+func zScan(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ZSCAN requires at least two arguments")
+		return
+	}
+	key := cmd.Args[0]
+	cursor, err := strconv.ParseInt(cmd.Args[1], 10, 64)
+	if err != nil {
+		conn.WriteError("ERR cursor value is not a valid integer")
+		return
+	}
+	var match = "*"
+	if cmd.Options.MATCH > 0 {
 		match = cmd.Args[cmd.Options.MATCH]
 	}
 	var count int64 = 10
@@ -2694,11 +4482,30 @@ func zScan(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 
 func save(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	execCommand(conn, func() {
-		n.store.flush()
+		if err := n.Save(); err != nil {
+			conn.WriteError("ERR " + err.Error())
+			return
+		}
 		conn.WriteString("OK")
 	})
 }
 
+func bgSave(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	execCommand(conn, func() {
+		if err := n.BGSave(); err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteString("Background saving started")
+	})
+}
+
+func lastSave(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	execCommand(conn, func() {
+		conn.WriteInt64(n.LastSave().Unix())
+	})
+}
+
 func geoAdd(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 	if len(cmd.Args) < 4 {
 		conn.WriteError("GEOADD requires at least four arguments")
@@ -2991,3 +4798,1145 @@ func geoRadiusByMember(n *Nodis, conn *redis.Conn, cmd redis.Command) {
 		}
 	})
 }
+
+// tsArgs are the options of TS.CREATE, TS.ADD, TS.INCRBY and TS.DECRBY
+type tsArgs struct {
+	opts        *TSOptions
+	onDuplicate timeseries.DuplicatePolicy
+	timestamp   int64
+}
+
+func parseTSArgs(args []string) (*tsArgs, string) {
+	a := &tsArgs{opts: &TSOptions{}, timestamp: time.Now().UnixMilli()}
+	for i := 0; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		if opt == "LABELS" {
+			labels := args[i+1:]
+			if len(labels)%2 != 0 {
+				return nil, "ERR TSDB: invalid LABELS"
+			}
+			for j := 0; j < len(labels); j += 2 {
+				a.opts.Labels = append(a.opts.Labels, timeseries.Label{Name: labels[j], Value: labels[j+1]})
+			}
+			break
+		}
+		if i+1 >= len(args) {
+			return nil, "ERR syntax error"
+		}
+		i++
+		switch opt {
+		case "RETENTION":
+			v, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil || v < 0 {
+				return nil, "ERR TSDB: invalid RETENTION value"
+			}
+			a.opts.Retention = v
+		case "DUPLICATE_POLICY":
+			p, err := timeseries.ParseDuplicatePolicy(args[i])
+			if err != nil {
+				return nil, err.Error()
+			}
+			a.opts.DuplicatePolicy = p
+		case "ON_DUPLICATE":
+			p, err := timeseries.ParseDuplicatePolicy(args[i])
+			if err != nil {
+				return nil, err.Error()
+			}
+			a.onDuplicate = p
+		case "TIMESTAMP":
+			v, ok := parseTSTimestamp(args[i])
+			if !ok {
+				return nil, "ERR TSDB: invalid timestamp"
+			}
+			a.timestamp = v
+		case "ENCODING", "CHUNK_SIZE":
+			// chunks are always compressed and sized by the server
+		default:
+			return nil, "ERR syntax error"
+		}
+	}
+	return a, ""
+}
+
+// parseTSTimestamp parses a sample timestamp, "*" means now
+func parseTSTimestamp(s string) (int64, bool) {
+	if s == "*" {
+		return time.Now().UnixMilli(), true
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// parseTSRangeTimestamp parses a range boundary, "-" and "+" are the min and max timestamps
+func parseTSRangeTimestamp(s string) (int64, bool) {
+	switch s {
+	case "-":
+		return 0, true
+	case "+":
+		return math.MaxInt64, true
+	}
+	return parseTSTimestamp(s)
+}
+
+// tsRangeArgs are the options of the TS.RANGE like commands
+type tsRangeArgs struct {
+	opts       *TSRangeOptions
+	withLabels bool
+	filters    []string
+}
+
+func parseTSRangeArgs(args []string) (*tsRangeArgs, string) {
+	a := &tsRangeArgs{opts: &TSRangeOptions{}}
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "WITHLABELS":
+			a.withLabels = true
+		case "FILTER":
+			a.filters = args[i+1:]
+			return a, ""
+		case "COUNT":
+			if i+1 >= len(args) {
+				return nil, "ERR syntax error"
+			}
+			v, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || v < 0 {
+				return nil, "ERR TSDB: invalid COUNT value"
+			}
+			a.opts.Count = v
+			i++
+		case "AGGREGATION":
+			if i+2 >= len(args) {
+				return nil, "ERR syntax error"
+			}
+			agg, err := timeseries.ParseAggregator(args[i+1])
+			if err != nil {
+				return nil, err.Error()
+			}
+			bucket, err := strconv.ParseInt(args[i+2], 10, 64)
+			if err != nil || bucket <= 0 {
+				return nil, timeseries.ErrBucketDurationNeg.Error()
+			}
+			a.opts.Aggregation = &timeseries.Aggregation{Type: agg, BucketDuration: bucket}
+			i += 2
+		default:
+			return nil, "ERR syntax error"
+		}
+	}
+	return a, ""
+}
+
+func writeTSSample(conn *redis.Conn, s timeseries.Sample) {
+	conn.WriteArray(2)
+	conn.WriteInt64(s.Timestamp)
+	conn.WriteBulk(strconv.FormatFloat(s.Value, 'f', -1, 64))
+}
+
+func writeTSLabels(conn *redis.Conn, labels []timeseries.Label) {
+	conn.WriteArray(len(labels))
+	for _, l := range labels {
+		conn.WriteArray(2)
+		conn.WriteBulk(l.Name)
+		conn.WriteBulk(l.Value)
+	}
+}
+
+// TS.CREATE key [RETENTION retentionPeriod] [DUPLICATE_POLICY policy] [LABELS label value ...]
+func tsCreate(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 {
+		conn.WriteError("TS.CREATE requires at least one argument")
+		return
+	}
+	a, errStr := parseTSArgs(cmd.Args[1:])
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		if err := n.TSCreate(cmd.Args[0], a.opts); err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteOK()
+	})
+}
+
+// TS.ADD key timestamp value [RETENTION retentionPeriod] [ON_DUPLICATE policy] [LABELS label value ...]
+func tsAdd(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("TS.ADD requires at least three arguments")
+		return
+	}
+	timestamp, ok := parseTSTimestamp(cmd.Args[1])
+	if !ok {
+		conn.WriteError("ERR TSDB: invalid timestamp")
+		return
+	}
+	value, err := strconv.ParseFloat(cmd.Args[2], 64)
+	if err != nil {
+		conn.WriteError("ERR TSDB: invalid value")
+		return
+	}
+	a, errStr := parseTSArgs(cmd.Args[3:])
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		ts, err := n.TSAdd(cmd.Args[0], timestamp, value, a.opts, a.onDuplicate)
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteInt64(ts)
+	})
+}
+
+// TS.MADD key timestamp value [key timestamp value ...]
+func tsMAdd(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 3 || len(cmd.Args)%3 != 0 {
+		conn.WriteError("TS.MADD requires key-timestamp-value triples")
+		return
+	}
+	samples := make([]TSSample, 0, len(cmd.Args)/3)
+	for i := 0; i < len(cmd.Args); i += 3 {
+		timestamp, ok := parseTSTimestamp(cmd.Args[i+1])
+		if !ok {
+			conn.WriteError("ERR TSDB: invalid timestamp")
+			return
+		}
+		value, err := strconv.ParseFloat(cmd.Args[i+2], 64)
+		if err != nil {
+			conn.WriteError("ERR TSDB: invalid value")
+			return
+		}
+		samples = append(samples, TSSample{Key: cmd.Args[i], Timestamp: timestamp, Value: value})
+	}
+	execCommand(conn, func() {
+		errs := n.TSMAdd(samples...)
+		conn.WriteArray(len(errs))
+		for i, err := range errs {
+			if err != nil {
+				conn.WriteError(err.Error())
+				continue
+			}
+			conn.WriteInt64(samples[i].Timestamp)
+		}
+	})
+}
+
+// TS.INCRBY key addend [TIMESTAMP timestamp] [RETENTION retentionPeriod] [LABELS label value ...]
+func tsIncrBy(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	tsIncrOrDecrBy(n, conn, cmd, "TS.INCRBY", 1)
+}
+
+// TS.DECRBY key subtrahend [TIMESTAMP timestamp] [RETENTION retentionPeriod] [LABELS label value ...]
+func tsDecrBy(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	tsIncrOrDecrBy(n, conn, cmd, "TS.DECRBY", -1)
+}
+
+func tsIncrOrDecrBy(n *Nodis, conn *redis.Conn, cmd redis.Command, name string, sign float64) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError(name + " requires at least two arguments")
+		return
+	}
+	delta, err := strconv.ParseFloat(cmd.Args[1], 64)
+	if err != nil {
+		conn.WriteError("ERR TSDB: invalid value")
+		return
+	}
+	a, errStr := parseTSArgs(cmd.Args[2:])
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		if _, err := n.TSIncrBy(cmd.Args[0], a.timestamp, sign*delta, a.opts); err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteInt64(a.timestamp)
+	})
+}
+
+// TS.GET key
+func tsGet(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 {
+		conn.WriteError("TS.GET requires at least one argument")
+		return
+	}
+	execCommand(conn, func() {
+		s, err := n.TSGet(cmd.Args[0])
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		if s == nil {
+			conn.WriteArray(0)
+			return
+		}
+		writeTSSample(conn, *s)
+	})
+}
+
+// TS.RANGE key fromTimestamp toTimestamp [COUNT count] [AGGREGATION aggregator bucketDuration]
+func tsRange(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	tsRangeCommand(n, conn, cmd, "TS.RANGE", false)
+}
+
+// TS.REVRANGE key fromTimestamp toTimestamp [COUNT count] [AGGREGATION aggregator bucketDuration]
+func tsRevRange(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	tsRangeCommand(n, conn, cmd, "TS.REVRANGE", true)
+}
+
+func tsRangeCommand(n *Nodis, conn *redis.Conn, cmd redis.Command, name string, rev bool) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError(name + " requires at least three arguments")
+		return
+	}
+	from, ok := parseTSRangeTimestamp(cmd.Args[1])
+	if !ok {
+		conn.WriteError("ERR TSDB: invalid fromTimestamp")
+		return
+	}
+	to, ok := parseTSRangeTimestamp(cmd.Args[2])
+	if !ok {
+		conn.WriteError("ERR TSDB: invalid toTimestamp")
+		return
+	}
+	a, errStr := parseTSRangeArgs(cmd.Args[3:])
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		var samples []timeseries.Sample
+		var err error
+		if rev {
+			samples, err = n.TSRevRange(cmd.Args[0], from, to, a.opts)
+		} else {
+			samples, err = n.TSRange(cmd.Args[0], from, to, a.opts)
+		}
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteArray(len(samples))
+		for _, s := range samples {
+			writeTSSample(conn, s)
+		}
+	})
+}
+
+// TS.MRANGE fromTimestamp toTimestamp [WITHLABELS] [COUNT count] [AGGREGATION aggregator bucketDuration] FILTER filterExpr...
+func tsMRange(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	tsMRangeCommand(n, conn, cmd, "TS.MRANGE", false)
+}
+
+// TS.MREVRANGE fromTimestamp toTimestamp [WITHLABELS] [COUNT count] [AGGREGATION aggregator bucketDuration] FILTER filterExpr...
+func tsMRevRange(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	tsMRangeCommand(n, conn, cmd, "TS.MREVRANGE", true)
+}
+
+func tsMRangeCommand(n *Nodis, conn *redis.Conn, cmd redis.Command, name string, rev bool) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError(name + " requires at least four arguments")
+		return
+	}
+	from, ok := parseTSRangeTimestamp(cmd.Args[0])
+	if !ok {
+		conn.WriteError("ERR TSDB: invalid fromTimestamp")
+		return
+	}
+	to, ok := parseTSRangeTimestamp(cmd.Args[1])
+	if !ok {
+		conn.WriteError("ERR TSDB: invalid toTimestamp")
+		return
+	}
+	a, errStr := parseTSRangeArgs(cmd.Args[2:])
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	execCommand(conn, func() {
+		var results []*TSRangeResult
+		var err error
+		if rev {
+			results, err = n.TSMRevRange(from, to, a.filters, a.opts)
+		} else {
+			results, err = n.TSMRange(from, to, a.filters, a.opts)
+		}
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteArray(len(results))
+		for _, r := range results {
+			conn.WriteArray(3)
+			conn.WriteBulk(r.Key)
+			if a.withLabels {
+				writeTSLabels(conn, r.Labels)
+			} else {
+				conn.WriteArray(0)
+			}
+			conn.WriteArray(len(r.Samples))
+			for _, s := range r.Samples {
+				writeTSSample(conn, s)
+			}
+		}
+	})
+}
+
+// TS.CREATERULE sourceKey destKey AGGREGATION aggregator bucketDuration
+func tsCreateRule(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 5 || strings.ToUpper(cmd.Args[2]) != "AGGREGATION" {
+		conn.WriteError("TS.CREATERULE requires source key, destination key and AGGREGATION")
+		return
+	}
+	agg, err := timeseries.ParseAggregator(cmd.Args[3])
+	if err != nil {
+		conn.WriteError(err.Error())
+		return
+	}
+	bucket, err := strconv.ParseInt(cmd.Args[4], 10, 64)
+	if err != nil || bucket <= 0 {
+		conn.WriteError(timeseries.ErrBucketDurationNeg.Error())
+		return
+	}
+	execCommand(conn, func() {
+		err := n.TSCreateRule(cmd.Args[0], cmd.Args[1], timeseries.Aggregation{Type: agg, BucketDuration: bucket})
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteOK()
+	})
+}
+
+// TS.DELETERULE sourceKey destKey
+func tsDeleteRule(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("TS.DELETERULE requires at least two arguments")
+		return
+	}
+	execCommand(conn, func() {
+		if err := n.TSDeleteRule(cmd.Args[0], cmd.Args[1]); err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteOK()
+	})
+}
+
+// TS.INFO key
+func tsInfo(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 {
+		conn.WriteError("TS.INFO requires at least one argument")
+		return
+	}
+	execCommand(conn, func() {
+		info, err := n.TSInfo(cmd.Args[0])
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteArray(18)
+		conn.WriteBulk("totalSamples")
+		conn.WriteInt64(info.TotalSamples)
+		conn.WriteBulk("firstTimestamp")
+		conn.WriteInt64(info.FirstTimestamp)
+		conn.WriteBulk("lastTimestamp")
+		conn.WriteInt64(info.LastTimestamp)
+		conn.WriteBulk("retentionTime")
+		conn.WriteInt64(info.Retention)
+		conn.WriteBulk("chunkCount")
+		conn.WriteInt64(info.ChunkCount)
+		conn.WriteBulk("duplicatePolicy")
+		if info.DuplicatePolicy == timeseries.DuplicateNone {
+			conn.WriteBulkNull()
+		} else {
+			conn.WriteBulk(info.DuplicatePolicy.String())
+		}
+		conn.WriteBulk("labels")
+		writeTSLabels(conn, info.Labels)
+		conn.WriteBulk("sourceKey")
+		if info.SourceKey == "" {
+			conn.WriteBulkNull()
+		} else {
+			conn.WriteBulk(info.SourceKey)
+		}
+		conn.WriteBulk("rules")
+		conn.WriteArray(len(info.Rules))
+		for _, r := range info.Rules {
+			conn.WriteArray(3)
+			conn.WriteBulk(r.Key)
+			conn.WriteInt64(r.Aggregation.BucketDuration)
+			conn.WriteBulk(strings.ToUpper(r.Aggregation.Type.String()))
+		}
+	})
+}
+
+// parseVector parses a FP32 blob or VALUES num v1 v2 ... starting at args[i]
+// and returns the vector and the index of the next argument
+func parseVector(args []string, i int) ([]float32, int, string) {
+	if i >= len(args) {
+		return nil, i, "ERR syntax error"
+	}
+	switch strings.ToUpper(args[i]) {
+	case "FP32":
+		if i+1 >= len(args) || len(args[i+1]) == 0 || len(args[i+1])%4 != 0 {
+			return nil, i, "ERR invalid FP32 vector"
+		}
+		blob := args[i+1]
+		v := make([]float32, len(blob)/4)
+		for j := range v {
+			v[j] = math.Float32frombits(binary.LittleEndian.Uint32([]byte(blob[j*4 : j*4+4])))
+		}
+		return v, i + 2, ""
+	case "VALUES":
+		if i+1 >= len(args) {
+			return nil, i, "ERR syntax error"
+		}
+		num, err := strconv.Atoi(args[i+1])
+		if err != nil || num <= 0 || i+2+num > len(args) {
+			return nil, i, "ERR invalid vector dimension"
+		}
+		v := make([]float32, num)
+		for j := range v {
+			f, err := strconv.ParseFloat(args[i+2+j], 32)
+			if err != nil {
+				return nil, i, "ERR invalid vector value"
+			}
+			v[j] = float32(f)
+		}
+		return v, i + 2 + num, ""
+	}
+	return nil, i, "ERR syntax error"
+}
+
+// VADD key (FP32 vector | VALUES num vector) element [CAS] [NOQUANT | Q8] [COSINE | L2] [EF build-exploration-factor] [SETATTR attributes] [M numlinks]
+func vAdd(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("VADD requires at least three arguments")
+		return
+	}
+	v, i, errStr := parseVector(cmd.Args, 1)
+	if errStr != "" {
+		conn.WriteError(errStr)
+		return
+	}
+	if i >= len(cmd.Args) {
+		conn.WriteError("VADD requires an element")
+		return
+	}
+	element := cmd.Args[i]
+	opts := &VAddOptions{}
+	for i++; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(cmd.Args[i]) {
+		case "CAS":
+			// the graph is always updated synchronously
+		case "NOQUANT":
+			opts.Quantization = vector.NoQuant
+		case "Q8":
+			opts.Quantization = vector.Q8
+		case "COSINE":
+			opts.Metric = vector.Cosine
+		case "L2":
+			opts.Metric = vector.L2
+		case "EF", "M":
+			if i+1 >= len(cmd.Args) {
+				conn.WriteError("ERR syntax error")
+				return
+			}
+			num, err := strconv.Atoi(cmd.Args[i+1])
+			if err != nil || num <= 0 {
+				conn.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+			if strings.ToUpper(cmd.Args[i]) == "EF" {
+				opts.EF = num
+			} else {
+				opts.M = num
+			}
+			i++
+		case "SETATTR":
+			if i+1 >= len(cmd.Args) {
+				conn.WriteError("ERR syntax error")
+				return
+			}
+			opts.Attr = cmd.Args[i+1]
+			i++
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	execCommand(conn, func() {
+		added, err := n.VAdd(cmd.Args[0], element, v, opts)
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		if added {
+			conn.WriteInt64(1)
+			return
+		}
+		conn.WriteInt64(0)
+	})
+}
+
+// VREM key element
+func vRem(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("VREM requires at least two arguments")
+		return
+	}
+	execCommand(conn, func() {
+		if n.VRem(cmd.Args[0], cmd.Args[1]) {
+			conn.WriteInt64(1)
+			return
+		}
+		conn.WriteInt64(0)
+	})
+}
+
+// VSIM key (ELE | FP32 | VALUES num) (vector | element) [WITHSCORES] [COUNT num] [EF search-exploration-factor]
+func vSim(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("VSIM requires at least three arguments")
+		return
+	}
+	var v []float32
+	var element string
+	i := 3
+	if strings.ToUpper(cmd.Args[1]) == "ELE" {
+		element = cmd.Args[2]
+	} else {
+		var errStr string
+		v, i, errStr = parseVector(cmd.Args, 1)
+		if errStr != "" {
+			conn.WriteError(errStr)
+			return
+		}
+	}
+	count, ef := 10, 0
+	var withScores bool
+	for ; i < len(cmd.Args); i++ {
+		opt := strings.ToUpper(cmd.Args[i])
+		if opt == "WITHSCORES" {
+			withScores = true
+			continue
+		}
+		if (opt != "COUNT" && opt != "EF") || i+1 >= len(cmd.Args) {
+			conn.WriteError("ERR syntax error")
+			return
+		}
+		num, err := strconv.Atoi(cmd.Args[i+1])
+		if err != nil || num <= 0 {
+			conn.WriteError("ERR value is not an integer or out of range")
+			return
+		}
+		if opt == "COUNT" {
+			count = num
+		} else {
+			ef = num
+		}
+		i++
+	}
+	execCommand(conn, func() {
+		var results []vector.Result
+		var err error
+		if v == nil {
+			results, err = n.VSimElement(cmd.Args[0], element, count, ef)
+		} else {
+			results, err = n.VSim(cmd.Args[0], v, count, ef)
+		}
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		if withScores {
+			conn.WriteArray(len(results) * 2)
+		} else {
+			conn.WriteArray(len(results))
+		}
+		for _, r := range results {
+			conn.WriteBulk(r.Element)
+			if withScores {
+				conn.WriteBulk(strconv.FormatFloat(r.Score, 'f', -1, 64))
+			}
+		}
+	})
+}
+
+// VCARD key
+func vCard(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 {
+		conn.WriteError("VCARD requires at least one argument")
+		return
+	}
+	execCommand(conn, func() {
+		conn.WriteInt64(n.VCard(cmd.Args[0]))
+	})
+}
+
+// VDIM key
+func vDim(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 {
+		conn.WriteError("VDIM requires at least one argument")
+		return
+	}
+	execCommand(conn, func() {
+		dim := n.VDim(cmd.Args[0])
+		if dim == 0 {
+			conn.WriteError("ERR key does not exist")
+			return
+		}
+		conn.WriteInt64(dim)
+	})
+}
+
+// VEMB key element
+func vEmb(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("VEMB requires at least two arguments")
+		return
+	}
+	execCommand(conn, func() {
+		v := n.VEmb(cmd.Args[0], cmd.Args[1])
+		if v == nil {
+			conn.WriteArrayNull()
+			return
+		}
+		conn.WriteArray(len(v))
+		for _, f := range v {
+			conn.WriteBulk(strconv.FormatFloat(float64(f), 'f', -1, 32))
+		}
+	})
+}
+
+// VGETATTR key element
+func vGetAttr(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("VGETATTR requires at least two arguments")
+		return
+	}
+	execCommand(conn, func() {
+		attr, ok := n.VGetAttr(cmd.Args[0], cmd.Args[1])
+		if !ok {
+			conn.WriteBulkNull()
+			return
+		}
+		conn.WriteBulk(attr)
+	})
+}
+
+// VSETATTR key element json
+func vSetAttr(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("VSETATTR requires at least three arguments")
+		return
+	}
+	execCommand(conn, func() {
+		if n.VSetAttr(cmd.Args[0], cmd.Args[1], cmd.Args[2]) {
+			conn.WriteInt64(1)
+			return
+		}
+		conn.WriteInt64(0)
+	})
+}
+
+// FT.CREATE index [ON HASH] [PREFIX count prefix ...] SCHEMA field TEXT|TAG|NUMERIC|GEO [WEIGHT weight] [SEPARATOR sep] [SORTABLE] ...
+func ftCreate(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("FT.CREATE requires at least four arguments")
+		return
+	}
+	def := &search.Definition{Name: cmd.Args[0]}
+	i := 1
+	for ; i < len(cmd.Args); i++ {
+		opt := strings.ToUpper(cmd.Args[i])
+		if opt == "SCHEMA" {
+			break
+		}
+		switch opt {
+		case "ON":
+			if i+1 >= len(cmd.Args) || strings.ToUpper(cmd.Args[i+1]) != "HASH" {
+				conn.WriteError("ERR only HASH is supported")
+				return
+			}
+			i++
+		case "PREFIX":
+			if i+1 >= len(cmd.Args) {
+				conn.WriteError("ERR syntax error")
+				return
+			}
+			num, err := strconv.Atoi(cmd.Args[i+1])
+			if err != nil || num < 0 || i+1+num >= len(cmd.Args) {
+				conn.WriteError("ERR Bad arguments for PREFIX")
+				return
+			}
+			def.Prefixes = append(def.Prefixes, cmd.Args[i+2:i+2+num]...)
+			i += 1 + num
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	for i++; i < len(cmd.Args); i++ {
+		if i+1 >= len(cmd.Args) {
+			conn.WriteError("ERR Field type is missing")
+			return
+		}
+		typ, err := search.ParseFieldType(cmd.Args[i+1])
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		f := search.Field{Name: cmd.Args[i], Type: typ}
+		for i += 2; i < len(cmd.Args); i++ {
+			opt := strings.ToUpper(cmd.Args[i])
+			if opt == "SORTABLE" {
+				f.Sortable = true
+				continue
+			}
+			if (opt != "WEIGHT" && opt != "SEPARATOR") || i+1 >= len(cmd.Args) {
+				break
+			}
+			if opt == "WEIGHT" {
+				f.Weight, err = strconv.ParseFloat(cmd.Args[i+1], 64)
+				if err != nil || f.Weight <= 0 {
+					conn.WriteError("ERR Bad arguments for WEIGHT")
+					return
+				}
+			} else {
+				if len(cmd.Args[i+1]) != 1 {
+					conn.WriteError("ERR Bad arguments for SEPARATOR")
+					return
+				}
+				f.Separator = cmd.Args[i+1][0]
+			}
+			i++
+		}
+		i--
+		def.Fields = append(def.Fields, f)
+	}
+	if len(def.Fields) == 0 {
+		conn.WriteError("ERR Fields arguments are missing")
+		return
+	}
+	execCommand(conn, func() {
+		if err := n.FTCreate(def); err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteOK()
+	})
+}
+
+// ftFieldName strips the @ of a field reference
+func ftFieldName(s string) string {
+	if len(s) > 0 && s[0] == '@' {
+		return s[1:]
+	}
+	return s
+}
+
+// parseFTLimit parses LIMIT offset num at args[i]
+func parseFTLimit(args []string, i int) (int, int, bool) {
+	if i+2 >= len(args) {
+		return 0, 0, false
+	}
+	offset, err := strconv.Atoi(args[i+1])
+	if err != nil || offset < 0 {
+		return 0, 0, false
+	}
+	num, err := strconv.Atoi(args[i+2])
+	if err != nil || num < 0 {
+		return 0, 0, false
+	}
+	return offset, num, true
+}
+
+// parseFTCount parses a "count arg ..." list at args[i]
+func parseFTCount(args []string, i int) ([]string, bool) {
+	if i+1 >= len(args) {
+		return nil, false
+	}
+	num, err := strconv.Atoi(args[i+1])
+	if err != nil || num < 0 || i+1+num >= len(args) {
+		return nil, false
+	}
+	return args[i+2 : i+2+num], true
+}
+
+// FT.SEARCH index query [NOCONTENT] [WITHSCORES] [RETURN count field ...] [SORTBY field [ASC|DESC]] [LIMIT offset num]
+func ftSearch(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("FT.SEARCH requires at least two arguments")
+		return
+	}
+	opts := &FTSearchOptions{Options: search.Options{Limit: 10}}
+	var withScores bool
+	for i := 2; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(cmd.Args[i]) {
+		case "NOCONTENT":
+			opts.NoContent = true
+		case "WITHSCORES":
+			withScores = true
+		case "RETURN":
+			fields, ok := parseFTCount(cmd.Args, i)
+			if !ok {
+				conn.WriteError("ERR Bad arguments for RETURN")
+				return
+			}
+			for _, f := range fields {
+				opts.Return = append(opts.Return, ftFieldName(f))
+			}
+			i += 1 + len(fields)
+		case "SORTBY":
+			if i+1 >= len(cmd.Args) {
+				conn.WriteError("ERR Bad arguments for SORTBY")
+				return
+			}
+			opts.SortBy = ftFieldName(cmd.Args[i+1])
+			i++
+			if i+1 < len(cmd.Args) {
+				switch strings.ToUpper(cmd.Args[i+1]) {
+				case "DESC":
+					opts.Desc = true
+					i++
+				case "ASC":
+					i++
+				}
+			}
+		case "LIMIT":
+			offset, num, ok := parseFTLimit(cmd.Args, i)
+			if !ok {
+				conn.WriteError("ERR Bad arguments for LIMIT")
+				return
+			}
+			opts.Offset, opts.Limit = offset, num
+			i += 2
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	execCommand(conn, func() {
+		result, err := n.FTSearch(cmd.Args[0], cmd.Args[1], opts)
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		size := 1
+		if withScores {
+			size++
+		}
+		if !opts.NoContent {
+			size++
+		}
+		conn.WriteArray(1 + len(result.Docs)*size)
+		conn.WriteInt64(result.Total)
+		for _, doc := range result.Docs {
+			conn.WriteBulk(doc.Key)
+			if withScores {
+				conn.WriteBulk(strconv.FormatFloat(doc.Score, 'f', -1, 64))
+			}
+			if opts.NoContent {
+				continue
+			}
+			fields := make([]string, 0, len(doc.Fields))
+			for f := range doc.Fields {
+				fields = append(fields, f)
+			}
+			slices.Sort(fields)
+			conn.WriteArray(len(fields) * 2)
+			for _, f := range fields {
+				conn.WriteBulk(f)
+				conn.WriteBulk(string(doc.Fields[f]))
+			}
+		}
+	})
+}
+
+// FT.AGGREGATE index query [LOAD count field ...] [GROUPBY count field ... [REDUCE func nargs arg ... [AS name]] ...]
+// [SORTBY nargs field [ASC|DESC] ...] [LIMIT offset num]
+func ftAggregate(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("FT.AGGREGATE requires at least two arguments")
+		return
+	}
+	req := &FTAggregateRequest{}
+	for i := 2; i < len(cmd.Args); i++ {
+		opt := strings.ToUpper(cmd.Args[i])
+		switch opt {
+		case "LOAD", "GROUPBY", "SORTBY":
+			args, ok := parseFTCount(cmd.Args, i)
+			if !ok {
+				conn.WriteError("ERR Bad arguments for " + opt)
+				return
+			}
+			i += 1 + len(args)
+			for _, arg := range args {
+				switch {
+				case opt == "LOAD":
+					req.Load = append(req.Load, ftFieldName(arg))
+				case opt == "GROUPBY":
+					req.GroupBy = append(req.GroupBy, ftFieldName(arg))
+				case strings.ToUpper(arg) == "DESC" && len(req.SortBy) > 0:
+					req.SortBy[len(req.SortBy)-1].Desc = true
+				case strings.ToUpper(arg) != "ASC":
+					req.SortBy = append(req.SortBy, search.SortKey{Field: ftFieldName(arg)})
+				}
+			}
+		case "REDUCE":
+			if i+1 >= len(cmd.Args) {
+				conn.WriteError("ERR Bad arguments for REDUCE")
+				return
+			}
+			fn, err := search.ParseReducer(cmd.Args[i+1])
+			if err != nil {
+				conn.WriteError(err.Error())
+				return
+			}
+			args, ok := parseFTCount(cmd.Args, i+1)
+			if !ok {
+				conn.WriteError("ERR Bad arguments for REDUCE")
+				return
+			}
+			r := search.Reducer{Func: fn}
+			if len(args) > 0 {
+				r.Field = ftFieldName(args[0])
+			}
+			i += 2 + len(args)
+			if i+2 < len(cmd.Args) && strings.ToUpper(cmd.Args[i+1]) == "AS" {
+				r.As = cmd.Args[i+2]
+				i += 2
+			}
+			req.Reducers = append(req.Reducers, r)
+		case "LIMIT":
+			offset, num, ok := parseFTLimit(cmd.Args, i)
+			if !ok {
+				conn.WriteError("ERR Bad arguments for LIMIT")
+				return
+			}
+			req.Offset, req.Limit = offset, num
+			i += 2
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+	execCommand(conn, func() {
+		rows, err := n.FTAggregate(cmd.Args[0], cmd.Args[1], req)
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteArray(1 + len(rows))
+		conn.WriteInt64(int64(len(rows)))
+		for _, row := range rows {
+			fields := make([]string, 0, len(row))
+			for f := range row {
+				fields = append(fields, f)
+			}
+			slices.Sort(fields)
+			conn.WriteArray(len(fields) * 2)
+			for _, f := range fields {
+				conn.WriteBulk(f)
+				conn.WriteBulk(row[f])
+			}
+		}
+	})
+}
+
+// FT.DROPINDEX index [DD]
+func ftDropIndex(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 {
+		conn.WriteError("FT.DROPINDEX requires at least one argument")
+		return
+	}
+	deleteDocs := len(cmd.Args) > 1 && strings.ToUpper(cmd.Args[1]) == "DD"
+	execCommand(conn, func() {
+		if err := n.FTDropIndex(cmd.Args[0], deleteDocs); err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		conn.WriteOK()
+	})
+}
+
+// FT.INFO index
+func ftInfo(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	if len(cmd.Args) == 0 {
+		conn.WriteError("FT.INFO requires at least one argument")
+		return
+	}
+	execCommand(conn, func() {
+		info, err := n.FTInfo(cmd.Args[0])
+		if err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+		def := info.Definition
+		conn.WriteArray(12)
+		conn.WriteBulk("index_name")
+		conn.WriteBulk(def.Name)
+		conn.WriteBulk("index_definition")
+		conn.WriteArray(4)
+		conn.WriteBulk("key_type")
+		conn.WriteBulk("HASH")
+		conn.WriteBulk("prefixes")
+		conn.WriteArray(len(def.Prefixes))
+		for _, p := range def.Prefixes {
+			conn.WriteBulk(p)
+		}
+		conn.WriteBulk("attributes")
+		conn.WriteArray(len(def.Fields))
+		for _, f := range def.Fields {
+			size := 4
+			if f.Type == search.Text || f.Type == search.Tag {
+				size += 2
+			}
+			if f.Sortable {
+				size++
+			}
+			conn.WriteArray(size)
+			conn.WriteBulk("identifier")
+			conn.WriteBulk(f.Name)
+			conn.WriteBulk("type")
+			conn.WriteBulk(f.Type.String())
+			switch f.Type {
+			case search.Text:
+				conn.WriteBulk("WEIGHT")
+				conn.WriteBulk(strconv.FormatFloat(f.Weight, 'f', -1, 64))
+			case search.Tag:
+				conn.WriteBulk("SEPARATOR")
+				conn.WriteBulk(string(f.Separator))
+			}
+			if f.Sortable {
+				conn.WriteBulk("SORTABLE")
+			}
+		}
+		conn.WriteBulk("num_docs")
+		conn.WriteInt64(info.NumDocs)
+		conn.WriteBulk("num_terms")
+		conn.WriteInt64(info.NumTerms)
+		conn.WriteBulk("num_records")
+		conn.WriteInt64(info.NumRecords)
+	})
+}
+
+// FT._LIST
+func ftList(n *Nodis, conn *redis.Conn, cmd redis.Command) {
+	execCommand(conn, func() {
+		names := n.FTList()
+		conn.WriteArray(len(names))
+		for _, name := range names {
+			conn.WriteBulk(name)
+		}
+	})
+}
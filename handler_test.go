@@ -2,8 +2,11 @@ package nodis
 
 import (
 	"bytes"
+	"net"
+	"os"
 	"testing"
 
+	"github.com/diiyw/nodis/ds"
 	"github.com/diiyw/nodis/redis"
 )
 
@@ -70,7 +73,8 @@ func TestClient_Config_InvalidArgs(t *testing.T) {
 }
 
 func TestClient_Config_InvalidOption(t *testing.T) {
-	n := &Nodis{}
+	n := Open(&Options{})
+	defer n.Close()
 	w := redis.NewWriter(&bytes.Buffer{})
 	cmd := redis.Command{
 		Name: "CONFIG",
@@ -84,6 +88,80 @@ func TestClient_Config_InvalidOption(t *testing.T) {
 		t.Errorf("Expected %q, but got %q", expected, w.Bytes())
 	}
 }
+func TestClient_Config_Set(t *testing.T) {
+	n := Open(&Options{})
+	defer n.Close()
+	limits := ds.DefaultEncodingLimits
+	limits.SetMaxIntsetEntries = 4
+	other := Open(&Options{EncodingLimits: &limits})
+	defer other.Close()
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SET", "zset-max-listpack-entries", "16"}, "+OK\r\n"},
+		{[]string{"GET", "zset-max-listpack-entries"}, "*2\r\n$25\r\nzset-max-listpack-entries\r\n$2\r\n16\r\n"},
+		{[]string{"set", "HASH-MAX-LISTPACK-VALUE", "8"}, "+OK\r\n"},
+		{[]string{"GET", "hash-max-listpack-value"}, "*2\r\n$23\r\nhash-max-listpack-value\r\n$1\r\n8\r\n"},
+		{[]string{"SET", "set-max-intset-entries", "-1"}, "-ERR CONFIG SET failed (possibly related to argument 'set-max-intset-entries') - argument couldn't be parsed into an integer\r\n"},
+		{[]string{"SET", "invalid", "1"}, "-ERR Unknown option or number of arguments for CONFIG SET - 'invalid'\r\n"},
+		{[]string{"SET", "invalid"}, "-ERR wrong number of arguments for 'CONFIG|SET' command\r\n"},
+	}
+	for _, tt := range tests {
+		w := redis.NewWriter(&bytes.Buffer{})
+		config(n, &redis.Conn{Writer: w}, redis.Command{Name: "CONFIG", Args: tt.args})
+		if got := string(w.Bytes()); got != tt.want {
+			t.Errorf("CONFIG %v = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+	// the limits are per instance
+	if got := other.EncodingLimits(); got != limits {
+		t.Errorf("EncodingLimits() of the other instance = %+v, want %+v", got, limits)
+	}
+}
+
+func TestObject_Encoding(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.Set("int", []byte("12"), false)
+	n.Set("embstr", []byte("hello"), false)
+	n.Set("raw", bytes.Repeat([]byte("a"), 45), false)
+	n.RPush("list", []byte("a"))
+	n.HSet("hash", "a", []byte("1"))
+	n.HSet("bighash", "a", bytes.Repeat([]byte("a"), 65))
+	n.SAdd("intset", "1", "2")
+	n.SAdd("set", "a")
+	n.ZAdd("zset", "a", 1)
+	n.store.encodings.SetLimit("zset-max-listpack-entries", 1)
+	n.ZAdd("bigzset", "a", 1)
+	n.ZAdd("bigzset", "b", 2)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"ENCODING", "int"}, "$3\r\nint\r\n"},
+		{[]string{"ENCODING", "embstr"}, "$6\r\nembstr\r\n"},
+		{[]string{"ENCODING", "raw"}, "$3\r\nraw\r\n"},
+		{[]string{"ENCODING", "list"}, "$9\r\nquicklist\r\n"},
+		{[]string{"ENCODING", "hash"}, "$8\r\nlistpack\r\n"},
+		{[]string{"ENCODING", "bighash"}, "$9\r\nhashtable\r\n"},
+		{[]string{"ENCODING", "intset"}, "$6\r\nintset\r\n"},
+		{[]string{"ENCODING", "set"}, "$9\r\nhashtable\r\n"},
+		{[]string{"encoding", "zset"}, "$8\r\nlistpack\r\n"},
+		{[]string{"ENCODING", "bigzset"}, "$8\r\nskiplist\r\n"},
+		{[]string{"ENCODING", "missing"}, "$-1\r\n"},
+		{[]string{"ENCODING"}, "-ERR wrong number of arguments for 'OBJECT|ENCODING' command\r\n"},
+		{[]string{"INVALID", "key"}, "-ERR unknown subcommand 'INVALID'. Try OBJECT HELP.\r\n"},
+	}
+	for _, tt := range tests {
+		w := redis.NewWriter(&bytes.Buffer{})
+		GetCommand("OBJECT")(n, &redis.Conn{Writer: w}, redis.Command{Name: "OBJECT", Args: tt.args})
+		if got := string(w.Bytes()); got != tt.want {
+			t.Errorf("OBJECT %v = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
 func TestClient_Ping(t *testing.T) {
 	n := &Nodis{}
 	w := redis.NewWriter(&bytes.Buffer{})
@@ -144,3 +222,407 @@ func TestClient_Echo_NoArgs(t *testing.T) {
 		t.Errorf("Expected %q, but got %q", expected, w.Bytes())
 	}
 }
+
+func TestTimeSeries_Commands(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"TS.CREATE", []string{"ts", "RETENTION", "0", "LABELS", "area", "us"}, "+OK\r\n"},
+		{"TS.ADD", []string{"ts", "1000", "1.5"}, ":1000\r\n"},
+		{"TS.MADD", []string{"ts", "2000", "2", "none", "1", "1"}, "*2\r\n:2000\r\n-ERR TSDB: the key does not exist\r\n"},
+		{"TS.GET", []string{"ts"}, "*2\r\n:2000\r\n$1\r\n2\r\n"},
+		{"TS.RANGE", []string{"ts", "-", "+"}, "*2\r\n*2\r\n:1000\r\n$3\r\n1.5\r\n*2\r\n:2000\r\n$1\r\n2\r\n"},
+		{"TS.REVRANGE", []string{"ts", "-", "+", "COUNT", "1"}, "*1\r\n*2\r\n:2000\r\n$1\r\n2\r\n"},
+		{"TS.RANGE", []string{"ts", "-", "+", "AGGREGATION", "max", "10000"}, "*1\r\n*2\r\n:0\r\n$1\r\n2\r\n"},
+		{"TS.MRANGE", []string{"-", "+", "WITHLABELS", "FILTER", "area=us"}, "*1\r\n*3\r\n$2\r\nts\r\n*1\r\n*2\r\n$4\r\narea\r\n$2\r\nus\r\n*2\r\n*2\r\n:1000\r\n$3\r\n1.5\r\n*2\r\n:2000\r\n$1\r\n2\r\n"},
+		{"TS.INCRBY", []string{"ts", "3", "TIMESTAMP", "3000"}, ":3000\r\n"},
+		{"TS.ADD", []string{"ts", "abc", "1"}, "-ERR TSDB: invalid timestamp\r\n"},
+		{"TS.RANGE", []string{"ts", "-", "+", "AGGREGATION", "median", "10"}, "-ERR TSDB: Unknown aggregation type\r\n"},
+	}
+	for _, tt := range tests {
+		w := redis.NewWriter(&bytes.Buffer{})
+		GetCommand(tt.name)(n, &redis.Conn{Writer: w}, redis.Command{Name: tt.name, Args: tt.args})
+		if got := string(w.Bytes()); got != tt.want {
+			t.Errorf("%s %v = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestVector_Commands(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"VADD", []string{"vs", "VALUES", "2", "1", "0", "a", "NOQUANT", "SETATTR", "{}"}, ":1\r\n"},
+		{"VADD", []string{"vs", "FP32", "\x00\x00\x00\x00\x00\x00\x80\x3f", "b"}, ":1\r\n"},
+		{"VADD", []string{"vs", "VALUES", "2", "1", "0", "a"}, ":0\r\n"},
+		{"VADD", []string{"vs", "VALUES", "3", "1", "0", "0", "c"}, "-ERR Vector dimension mismatch\r\n"},
+		{"VCARD", []string{"vs"}, ":2\r\n"},
+		{"VDIM", []string{"vs"}, ":2\r\n"},
+		{"VDIM", []string{"none"}, "-ERR key does not exist\r\n"},
+		{"VEMB", []string{"vs", "b"}, "*2\r\n$1\r\n0\r\n$1\r\n1\r\n"},
+		{"VSIM", []string{"vs", "VALUES", "2", "1", "0", "COUNT", "1"}, "*1\r\n$1\r\na\r\n"},
+		{"VSIM", []string{"vs", "ELE", "b", "WITHSCORES", "COUNT", "1"}, "*2\r\n$1\r\nb\r\n$1\r\n1\r\n"},
+		{"VGETATTR", []string{"vs", "a"}, "$2\r\n{}\r\n"},
+		{"VSETATTR", []string{"vs", "b", "{\"x\":1}"}, ":1\r\n"},
+		{"VGETATTR", []string{"vs", "b"}, "$7\r\n{\"x\":1}\r\n"},
+		{"VREM", []string{"vs", "a"}, ":1\r\n"},
+		{"VREM", []string{"vs", "a"}, ":0\r\n"},
+	}
+	for _, tt := range tests {
+		w := redis.NewWriter(&bytes.Buffer{})
+		GetCommand(tt.name)(n, &redis.Conn{Writer: w}, redis.Command{Name: tt.name, Args: tt.args})
+		if got := string(w.Bytes()); got != tt.want {
+			t.Errorf("%s %q = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestSearch_Commands(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	n.HSet("user:1", "name", []byte("John"))
+	n.HSet("user:1", "age", []byte("30"))
+	n.HSet("user:2", "name", []byte("Jane"))
+	n.HSet("user:2", "age", []byte("25"))
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"FT.CREATE", []string{"idx", "ON", "HASH", "PREFIX", "1", "user:", "SCHEMA", "name", "TEXT", "SORTABLE", "age", "NUMERIC"}, "+OK\r\n"},
+		{"FT.CREATE", []string{"idx", "SCHEMA", "name", "TEXT"}, "-Index already exists\r\n"},
+		{"FT.CREATE", []string{"bad", "SCHEMA", "name", "VECTOR"}, "-ERR Invalid field type\r\n"},
+		{"FT._LIST", nil, "*1\r\n$3\r\nidx\r\n"},
+		{"FT.SEARCH", []string{"idx", "john"}, "*3\r\n:1\r\n$6\r\nuser:1\r\n*4\r\n$3\r\nage\r\n$2\r\n30\r\n$4\r\nname\r\n$4\r\nJohn\r\n"},
+		{"FT.SEARCH", []string{"idx", "*", "NOCONTENT", "SORTBY", "age", "DESC", "LIMIT", "0", "1"}, "*2\r\n:2\r\n$6\r\nuser:1\r\n"},
+		{"FT.SEARCH", []string{"idx", "@age:[0 26]", "RETURN", "1", "name"}, "*3\r\n:1\r\n$6\r\nuser:2\r\n*2\r\n$4\r\nname\r\n$4\r\nJane\r\n"},
+		{"FT.SEARCH", []string{"idx", "@title:x"}, "-ERR Unknown field in query\r\n"},
+		{"FT.SEARCH", []string{"none", "*"}, "-Unknown Index name\r\n"},
+		{"FT.AGGREGATE", []string{"idx", "*", "GROUPBY", "0", "REDUCE", "SUM", "1", "@age", "AS", "total"}, "*2\r\n:1\r\n*2\r\n$5\r\ntotal\r\n$2\r\n55\r\n"},
+		{"FT.AGGREGATE", []string{"idx", "*", "SORTBY", "2", "@age", "DESC", "LIMIT", "0", "1"}, "*2\r\n:1\r\n*4\r\n$3\r\nage\r\n$2\r\n30\r\n$4\r\nname\r\n$4\r\nJohn\r\n"},
+		{"FT.INFO", []string{"idx"}, "*12\r\n$10\r\nindex_name\r\n$3\r\nidx\r\n$16\r\nindex_definition\r\n*4\r\n$8\r\nkey_type\r\n$4\r\nHASH\r\n$8\r\nprefixes\r\n*1\r\n$5\r\nuser:\r\n$10\r\nattributes\r\n*2\r\n*7\r\n$10\r\nidentifier\r\n$4\r\nname\r\n$4\r\ntype\r\n$4\r\nTEXT\r\n$6\r\nWEIGHT\r\n$1\r\n1\r\n$8\r\nSORTABLE\r\n*4\r\n$10\r\nidentifier\r\n$3\r\nage\r\n$4\r\ntype\r\n$7\r\nNUMERIC\r\n$8\r\nnum_docs\r\n:2\r\n$9\r\nnum_terms\r\n:2\r\n$11\r\nnum_records\r\n:4\r\n"},
+		{"FT.DROPINDEX", []string{"idx", "DD"}, "+OK\r\n"},
+		{"FT.DROPINDEX", []string{"idx"}, "-Unknown Index name\r\n"},
+		{"EXISTS", []string{"user:1", "user:2"}, ":0\r\n"},
+	}
+	for _, tt := range tests {
+		w := redis.NewWriter(&bytes.Buffer{})
+		GetCommand(tt.name)(n, &redis.Conn{Writer: w}, redis.Command{Name: tt.name, Args: tt.args})
+		if got := string(w.Bytes()); got != tt.want {
+			t.Errorf("%s %q = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestHash_FieldExpirationCommands(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	n.HSet("h", "a", []byte("1"))
+	n.HSet("h", "b", []byte("2"))
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"HEXPIRE", []string{"h", "100", "FIELDS", "2", "a", "none"}, "*2\r\n:1\r\n:-2\r\n"},
+		{"HEXPIRE", []string{"h", "200", "GT", "FIELDS", "2", "a", "b"}, "*2\r\n:1\r\n:0\r\n"},
+		{"HPEXPIRE", []string{"h", "100", "FIELDS", "2", "a"}, "-ERR The `numfields` parameter must match the number of arguments\r\n"},
+		{"HEXPIRE", []string{"h", "100", "FIELDS", "0"}, "-ERR Parameter `numFields` should be greater than 0\r\n"},
+		{"HEXPIREAT", []string{"h", "4102444800", "FIELDS", "1", "b"}, "*1\r\n:1\r\n"},
+		{"HEXPIRETIME", []string{"h", "FIELDS", "1", "b"}, "*1\r\n:4102444800\r\n"},
+		{"HPEXPIRETIME", []string{"h", "FIELDS", "1", "b"}, "*1\r\n:4102444800000\r\n"},
+		{"HTTL", []string{"h", "FIELDS", "1", "a"}, "*1\r\n:200\r\n"},
+		{"HPERSIST", []string{"h", "FIELDS", "2", "a", "a"}, "*2\r\n:1\r\n:-1\r\n"},
+		{"HPTTL", []string{"h", "FIELDS", "1", "a"}, "*1\r\n:-1\r\n"},
+		{"HGETEX", []string{"h", "PXAT", "4102444800000", "FIELDS", "1", "a"}, "*1\r\n$1\r\n1\r\n"},
+		{"HEXPIRETIME", []string{"h", "FIELDS", "1", "a"}, "*1\r\n:4102444800\r\n"},
+		{"HSETEX", []string{"h", "FNX", "EX", "100", "FIELDS", "1", "c", "3"}, ":1\r\n"},
+		{"HSETEX", []string{"h", "FNX", "FIELDS", "1", "c", "4"}, ":0\r\n"},
+		{"HSETEX", []string{"h", "FXX", "KEEPTTL", "FIELDS", "1", "c", "5"}, ":1\r\n"},
+		{"HTTL", []string{"h", "FIELDS", "1", "c"}, "*1\r\n:100\r\n"},
+		{"HGETDEL", []string{"h", "FIELDS", "3", "a", "c", "none"}, "*3\r\n$1\r\n1\r\n$1\r\n5\r\n$-1\r\n"},
+		{"HGETDEL", []string{"h", "FIELDS", "1", "b"}, "*1\r\n$1\r\n2\r\n"},
+		{"EXISTS", []string{"h"}, ":0\r\n"},
+	}
+	for _, tt := range tests {
+		w := redis.NewWriter(&bytes.Buffer{})
+		GetCommand(tt.name)(n, &redis.Conn{Writer: w}, redis.Command{Name: tt.name, Args: tt.args})
+		if got := string(w.Bytes()); got != tt.want {
+			t.Errorf("%s %q = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestZSet_LexCommands(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	for _, m := range []string{"a", "b", "c", "d"} {
+		n.ZAdd("z", m, 0)
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		options redis.Options
+		want    string
+	}{
+		{"ZRANGEBYLEX", []string{"z", "[b", "+"}, redis.Options{}, "*3\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n"},
+		{"ZRANGEBYLEX", []string{"z", "-", "+", "LIMIT", "1", "1"}, redis.Options{}, "*1\r\n$1\r\nb\r\n"},
+		{"ZRANGEBYLEX", []string{"z", "-", "+", "LIMIT", "1"}, redis.Options{}, "-ERR syntax error\r\n"},
+		{"ZRANGEBYLEX", []string{"z", "b", "+"}, redis.Options{}, "-ERR min or max not valid string range item\r\n"},
+		{"ZREVRANGEBYLEX", []string{"z", "(c", "-"}, redis.Options{}, "*2\r\n$1\r\nb\r\n$1\r\na\r\n"},
+		{"ZRANGE", []string{"z", "(a", "[c", "BYLEX"}, redis.Options{BYLEX: 3}, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"ZRANGE", []string{"z", "+", "-", "BYLEX", "REV", "LIMIT", "0", "2"}, redis.Options{BYLEX: 3, REV: 4, LIMIT: 6}, "*2\r\n$1\r\nd\r\n$1\r\nc\r\n"},
+		{"ZLEXCOUNT", []string{"z", "-", "(c"}, redis.Options{}, ":2\r\n"},
+		{"ZREMRANGEBYLEX", []string{"z", "[b", "[c"}, redis.Options{}, ":2\r\n"},
+		{"ZLEXCOUNT", []string{"z", "-", "+"}, redis.Options{}, ":2\r\n"},
+	}
+	for _, tt := range tests {
+		w := redis.NewWriter(&bytes.Buffer{})
+		GetCommand(tt.name)(n, &redis.Conn{Writer: w}, redis.Command{Name: tt.name, Args: tt.args, Options: tt.options})
+		if got := string(w.Bytes()); got != tt.want {
+			t.Errorf("%s %q = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestZSet_PopAndMultiKeyCommands(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	n.ZAdd("z1", "a", 1)
+	n.ZAdd("z1", "b", 2)
+	n.ZAdd("z1", "c", 3)
+	n.ZAdd("z2", "b", 1)
+	n.SAdd("s", "c", "d")
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"ZUNION", []string{"2", "z1", "s", "WITHSCORES"}, "*8\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nd\r\n$1\r\n1\r\n$1\r\nb\r\n$1\r\n2\r\n$1\r\nc\r\n$1\r\n4\r\n"},
+		{"ZINTER", []string{"2", "z1", "z2", "WEIGHTS", "1", "3", "AGGREGATE", "max"}, "*1\r\n$1\r\nb\r\n"},
+		{"ZINTER", []string{"2", "z1"}, "-ERR Number of keys can't be greater than number of args\r\n"},
+		{"ZINTERCARD", []string{"2", "z1", "s", "LIMIT", "5"}, ":1\r\n"},
+		{"ZDIFF", []string{"3", "z1", "z2", "s", "WITHSCORES"}, "*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"ZDIFFSTORE", []string{"d", "2", "z1", "z2"}, ":2\r\n"},
+		{"ZRANGESTORE", []string{"r", "z1", "(1", "+inf", "BYSCORE", "LIMIT", "0", "1"}, ":1\r\n"},
+		{"ZRANGESTORE", []string{"r", "z1", "0", "1", "LIMIT", "0", "1"}, "-ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX\r\n"},
+		{"ZMSCORE", []string{"z1", "a", "x"}, "*2\r\n$1\r\n1\r\n$-1\r\n"},
+		{"ZRANDMEMBER", []string{"z2"}, "$1\r\nb\r\n"},
+		{"ZRANDMEMBER", []string{"z2", "-2", "WITHSCORES"}, "*4\r\n$1\r\nb\r\n$1\r\n1\r\n$1\r\nb\r\n$1\r\n1\r\n"},
+		{"ZPOPMIN", []string{"z1"}, "*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"ZPOPMAX", []string{"z1", "0"}, "*0\r\n"},
+		{"ZMPOP", []string{"2", "none", "z1", "MAX", "COUNT", "5"}, "*2\r\n$2\r\nz1\r\n*2\r\n*2\r\n$1\r\nc\r\n$1\r\n3\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+		{"ZMPOP", []string{"1", "z1", "MIN"}, "*-1\r\n"},
+		{"ZMPOP", []string{"1", "z1", "MID"}, "-ERR syntax error\r\n"},
+		{"BZPOPMIN", []string{"z1", "z2", "0.01"}, "*3\r\n$2\r\nz2\r\n$1\r\nb\r\n$1\r\n1\r\n"},
+		{"BZPOPMAX", []string{"z1", "0.01"}, "*-1\r\n"},
+		{"BZMPOP", []string{"0.01", "1", "r", "MIN"}, "*2\r\n$1\r\nr\r\n*1\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+		{"BZMPOP", []string{"-1", "1", "r", "MIN"}, "-ERR timeout is negative\r\n"},
+	}
+	for _, tt := range tests {
+		w := redis.NewWriter(&bytes.Buffer{})
+		GetCommand(tt.name)(n, &redis.Conn{Writer: w}, redis.Command{Name: tt.name, Args: tt.args})
+		if got := string(w.Bytes()); got != tt.want {
+			t.Errorf("%s %q = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestList_MoveAndPosCommands(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	n.RPush("l", []byte("a"), []byte("b"), []byte("c"), []byte("b"))
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"LPOS", []string{"l", "b"}, ":1\r\n"},
+		{"LPOS", []string{"l", "b", "RANK", "-1"}, ":3\r\n"},
+		{"LPOS", []string{"l", "b", "COUNT", "0"}, "*2\r\n:1\r\n:3\r\n"},
+		{"LPOS", []string{"l", "b", "COUNT", "0", "MAXLEN", "2"}, "*1\r\n:1\r\n"},
+		{"LPOS", []string{"l", "x"}, "$-1\r\n"},
+		{"LPOS", []string{"l", "b", "RANK", "0"}, "-ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list\r\n"},
+		{"LMOVE", []string{"l", "m", "RIGHT", "LEFT"}, "$1\r\nb\r\n"},
+		{"LMOVE", []string{"l", "m", "UP", "LEFT"}, "-ERR syntax error\r\n"},
+		{"BLMOVE", []string{"l", "l", "LEFT", "RIGHT", "0"}, "$1\r\na\r\n"},
+		{"BRPOPLPUSH", []string{"none", "m", "0.01"}, "$-1\r\n"},
+		{"LMPOP", []string{"2", "none", "l", "LEFT", "COUNT", "2"}, "*2\r\n$1\r\nl\r\n*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"LMPOP", []string{"1", "none", "LEFT"}, "*-1\r\n"},
+		{"BLMPOP", []string{"0.01", "2", "none", "l", "RIGHT"}, "*2\r\n$1\r\nl\r\n*1\r\n$1\r\na\r\n"},
+		{"BLMPOP", []string{"0.01", "1", "none", "LEFT"}, "*-1\r\n"},
+		{"BLMPOP", []string{"0.01", "1", "none", "LEFT", "COUNT", "0"}, "-ERR count should be greater than 0\r\n"},
+	}
+	for _, tt := range tests {
+		w := redis.NewWriter(&bytes.Buffer{})
+		GetCommand(tt.name)(n, &redis.Conn{Writer: w}, redis.Command{Name: tt.name, Args: tt.args})
+		if got := string(w.Bytes()); got != tt.want {
+			t.Errorf("%s %q = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestString_Commands(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"SET", []string{"a", "1", "GET"}, "$-1\r\n"},
+		{"SET", []string{"a", "2", "GET", "EX", "100"}, "$1\r\n1\r\n"},
+		{"TTL", []string{"a"}, ":100\r\n"},
+		{"SET", []string{"a", "3", "EX", "100", "GET"}, "$1\r\n2\r\n"},
+		{"SET", []string{"a", "4", "NX"}, "$-1\r\n"},
+		{"SET", []string{"a", "4", "NX", "GET"}, "$1\r\n3\r\n"},
+		{"SET", []string{"a", "4", "XX", "KEEPTTL"}, "+OK\r\n"},
+		{"TTL", []string{"a"}, ":100\r\n"},
+		{"SET", []string{"a", "5"}, "+OK\r\n"},
+		{"TTL", []string{"a"}, ":-1\r\n"},
+		{"SET", []string{"a", "EX"}, "+OK\r\n"},
+		{"SET", []string{"a", "1", "EX"}, "-ERR syntax error\r\n"},
+		{"SET", []string{"a", "1", "EX", "0"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{"SET", []string{"a", "1", "NX", "XX"}, "-ERR syntax error\r\n"},
+		{"SET", []string{"a", "1", "KEEPTTL", "PX", "10"}, "-ERR syntax error\r\n"},
+		{"SET", []string{"a", "1", "EX", "10", "PX", "10"}, "-ERR syntax error\r\n"},
+		{"GETEX", []string{"a", "PX", "100000"}, "$2\r\nEX\r\n"},
+		{"TTL", []string{"a"}, ":100\r\n"},
+		{"GETEX", []string{"a", "PERSIST"}, "$2\r\nEX\r\n"},
+		{"TTL", []string{"a"}, ":-1\r\n"},
+		{"GETEX", []string{"a", "EX"}, "-ERR syntax error\r\n"},
+		{"GETEX", []string{"a", "INVALID"}, "-ERR syntax error\r\n"},
+		{"GETEX", []string{"missing"}, "$-1\r\n"},
+		{"GETDEL", []string{"a"}, "$2\r\nEX\r\n"},
+		{"GETDEL", []string{"a"}, "$-1\r\n"},
+		{"PSETEX", []string{"a", "100000", "v"}, "+OK\r\n"},
+		{"TTL", []string{"a"}, ":100\r\n"},
+		{"PSETEX", []string{"a", "0", "v"}, "-ERR invalid expire time in 'psetex' command\r\n"},
+		{"MSETNX", []string{"b", "1", "c", "2"}, ":1\r\n"},
+		{"MSETNX", []string{"c", "3", "d", "4"}, ":0\r\n"},
+		{"MSETNX", []string{"d"}, "-ERR wrong number of arguments for 'msetnx' command\r\n"},
+		{"SET", []string{"s", "This is a string"}, "+OK\r\n"},
+		{"SUBSTR", []string{"s", "0", "3"}, "$4\r\nThis\r\n"},
+		{"MSET", []string{"key1", "ohmytext", "key2", "mynewtext"}, "+OK\r\n"},
+		{"LCS", []string{"key1", "key2"}, "$6\r\nmytext\r\n"},
+		{"LCS", []string{"key1", "key2", "LEN"}, ":6\r\n"},
+		{"LCS", []string{"key1", "key2", "IDX"}, "*4\r\n$7\r\nmatches\r\n*2\r\n*2\r\n*2\r\n:4\r\n:7\r\n*2\r\n:5\r\n:8\r\n*2\r\n*2\r\n:2\r\n:3\r\n*2\r\n:0\r\n:1\r\n$3\r\nlen\r\n:6\r\n"},
+		{"LCS", []string{"key1", "key2", "IDX", "MINMATCHLEN", "4", "WITHMATCHLEN"}, "*4\r\n$7\r\nmatches\r\n*1\r\n*3\r\n*2\r\n:4\r\n:7\r\n*2\r\n:5\r\n:8\r\n:4\r\n$3\r\nlen\r\n:6\r\n"},
+		{"LCS", []string{"key1", "key2", "LEN", "IDX"}, "-ERR If you want both the length and indexes, please just use IDX.\r\n"},
+		{"LCS", []string{"key1", "missing", "LEN"}, ":0\r\n"},
+	}
+	for _, tt := range tests {
+		w := redis.NewWriter(&bytes.Buffer{})
+		GetCommand(tt.name)(n, &redis.Conn{Writer: w}, redis.Command{Name: tt.name, Args: tt.args})
+		if got := string(w.Bytes()); got != tt.want {
+			t.Errorf("%s %v = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestKey_Commands(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"SET", []string{"a", "1"}, "+OK\r\n"},
+		{"COPY", []string{"a", "b"}, ":1\r\n"},
+		{"COPY", []string{"a", "b"}, ":0\r\n"},
+		{"COPY", []string{"a", "b", "REPLACE"}, ":1\r\n"},
+		{"COPY", []string{"a", "b", "DB", "0", "REPLACE"}, ":1\r\n"},
+		{"COPY", []string{"a", "b", "DB", "1"}, "-ERR DB index is out of range\r\n"},
+		{"COPY", []string{"a", "a"}, "-ERR source and destination objects are the same\r\n"},
+		{"COPY", []string{"a", "b", "INVALID"}, "-ERR syntax error\r\n"},
+		{"COPY", []string{"missing", "c"}, ":0\r\n"},
+		{"TOUCH", []string{"a", "b", "missing"}, ":2\r\n"},
+		{"OBJECT", []string{"IDLETIME", "a"}, ":0\r\n"},
+		{"OBJECT", []string{"REFCOUNT", "a"}, ":1\r\n"},
+		{"OBJECT", []string{"FREQ", "missing"}, "$-1\r\n"},
+		{"OBJECT", []string{"IDLETIME"}, "-ERR wrong number of arguments for 'OBJECT|IDLETIME' command\r\n"},
+		{"EXPIRETIME", []string{"a"}, ":-1\r\n"},
+		{"PEXPIRETIME", []string{"missing"}, ":-2\r\n"},
+		{"EXPIREAT", []string{"a", "33177117420"}, ":1\r\n"},
+		{"EXPIRETIME", []string{"a"}, ":33177117420\r\n"},
+		{"PEXPIRETIME", []string{"a"}, ":33177117420000\r\n"},
+		{"RPUSH", []string{"l", "3", "1", "2"}, ":3\r\n"},
+		{"MSET", []string{"w_1", "3", "w_2", "2", "w_3", "1"}, "+OK\r\n"},
+		{"SORT", []string{"l"}, "*3\r\n$1\r\n1\r\n$1\r\n2\r\n$1\r\n3\r\n"},
+		{"SORT", []string{"l", "DESC", "LIMIT", "0", "2"}, "*2\r\n$1\r\n3\r\n$1\r\n2\r\n"},
+		{"SORT", []string{"l", "BY", "w_*", "GET", "#", "GET", "x_*"}, "*6\r\n$1\r\n3\r\n$-1\r\n$1\r\n2\r\n$-1\r\n$1\r\n1\r\n$-1\r\n"},
+		{"SORT", []string{"l", "ALPHA", "STORE", "dst"}, ":3\r\n"},
+		{"LRANGE", []string{"dst", "0", "-1"}, "*3\r\n$1\r\n1\r\n$1\r\n2\r\n$1\r\n3\r\n"},
+		{"SORT", []string{"a"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"SORT", []string{"l", "LIMIT", "0"}, "-ERR syntax error\r\n"},
+		{"SORT_RO", []string{"l", "DESC"}, "*3\r\n$1\r\n3\r\n$1\r\n2\r\n$1\r\n1\r\n"},
+		{"SORT_RO", []string{"l", "STORE", "dst"}, "-ERR syntax error\r\n"},
+	}
+	for _, tt := range tests {
+		w := redis.NewWriter(&bytes.Buffer{})
+		GetCommand(tt.name)(n, &redis.Conn{Writer: w}, redis.Command{Name: tt.name, Args: tt.args})
+		if got := string(w.Bytes()); got != tt.want {
+			t.Errorf("%s %v = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestDump_Commands(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	addr := serve(t, Open(&Options{}))
+	host, port, _ := net.SplitHostPort(addr)
+	// DUMP of the integer 10 by Redis 7.0
+	payload := "\x00\xc0\n\n\x00n\x9fWE\x0e\xaec\xbb"
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"RESTORE", []string{"a", "0", payload}, "+OK\r\n"},
+		{"GET", []string{"a"}, "$2\r\n10\r\n"},
+		// the payload of Redis 6
+		{"DUMP", []string{"a"}, "$13\r\n\x00\xc0\n\t\x00\xbem\x06\x89Z(\x00\n\r\n"},
+		{"DUMP", []string{"missing"}, "$-1\r\n"},
+		{"RESTORE", []string{"a", "0", payload}, "-BUSYKEY Target key name already exists.\r\n"},
+		{"RESTORE", []string{"a", "100000", payload, "REPLACE", "IDLETIME", "100"}, "+OK\r\n"},
+		{"TTL", []string{"a"}, ":100\r\n"},
+		{"RESTORE", []string{"b", "33177117420000", payload, "ABSTTL", "FREQ", "10"}, "+OK\r\n"},
+		{"OBJECT", []string{"FREQ", "b"}, ":10\r\n"},
+		{"EXPIRETIME", []string{"b"}, ":33177117420\r\n"},
+		{"RESTORE", []string{"b", "-1", payload}, "-ERR Invalid TTL value, must be >= 0\r\n"},
+		{"RESTORE", []string{"b", "0", payload, "FREQ", "256"}, "-ERR Invalid FREQ value, must be >= 0 and <= 255\r\n"},
+		{"RESTORE", []string{"b", "0", payload, "FREQ", "1", "IDLETIME", "1"}, "-ERR syntax error\r\n"},
+		{"RESTORE", []string{"c", "0", "bad payload"}, "-ERR DUMP payload version or checksum are wrong\r\n"},
+		{"MIGRATE", []string{host, port, "a", "0", "1000"}, "+OK\r\n"},
+		{"EXISTS", []string{"a"}, ":0\r\n"},
+		{"MIGRATE", []string{host, port, "", "0", "1000", "COPY", "KEYS", "b", "missing"}, "+OK\r\n"},
+		{"EXISTS", []string{"b"}, ":1\r\n"},
+		{"MIGRATE", []string{host, port, "missing", "0", "1000"}, "+NOKEY\r\n"},
+		{"MIGRATE", []string{host, port, "b", "0", "1000", "KEYS", "b"}, "-ERR When using MIGRATE KEYS option, the key argument must be set to the empty string\r\n"},
+		{"MIGRATE", []string{host, port, "b", "0", "1000", "COPY"}, "-ERR Target instance replied with error: BUSYKEY Target key name already exists.\r\n"},
+	}
+	for _, tt := range tests {
+		w := redis.NewWriter(&bytes.Buffer{})
+		GetCommand(tt.name)(n, &redis.Conn{Writer: w}, redis.Command{Name: tt.name, Args: tt.args})
+		if got := string(w.Bytes()); got != tt.want {
+			t.Errorf("%s %q = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
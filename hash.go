@@ -1,6 +1,8 @@
 package nodis
 
 import (
+	"time"
+
 	"github.com/diiyw/nodis/patch"
 
 	"github.com/diiyw/nodis/ds"
@@ -8,7 +10,7 @@ import (
 )
 
 func (n *Nodis) newHash() ds.Value {
-	return hash.NewHashMap()
+	return hash.NewHashMapWithEncodings(n.store.encodings)
 }
 
 func (n *Nodis) HSet(key string, field string, value []byte) int64 {
@@ -16,6 +18,7 @@ func (n *Nodis) HSet(key string, field string, value []byte) int64 {
 	_ = n.exec(func(tx *Tx) error {
 		meta := tx.writeKey(key, n.newHash)
 		v = meta.value.(*hash.HashMap).HSet(field, value)
+		n.indexHash(key, meta.value.(*hash.HashMap))
 		n.signalModifiedKey(key, meta)
 		n.notify(func() []patch.Op {
 			return []patch.Op{{Type: patch.OpTypeHSet, Data: &patch.OpHSet{Key: key, Field: field, Value: value}}}
@@ -28,7 +31,7 @@ func (n *Nodis) HSet(key string, field string, value []byte) int64 {
 func (n *Nodis) HGet(key string, field string) []byte {
 	var v []byte
 	_ = n.exec(func(tx *Tx) error {
-		meta := tx.readKey(key)
+		meta := tx.readFields(key, field)
 		if !meta.isOk() {
 			return nil
 		}
@@ -49,6 +52,7 @@ func (n *Nodis) HDel(key string, fields ...string) int64 {
 		if meta.value.(*hash.HashMap).HLen() == 0 {
 			tx.delKey(key)
 		}
+		n.indexHash(key, meta.value.(*hash.HashMap))
 		n.signalModifiedKey(key, meta)
 		n.notify(func() []patch.Op {
 			return []patch.Op{{Type: patch.OpTypeHDel, Data: &patch.OpHDel{Key: key, Fields: fields}}}
@@ -87,7 +91,7 @@ func (n *Nodis) HKeys(key string) []string {
 func (n *Nodis) HExists(key string, field string) bool {
 	var v bool
 	_ = n.exec(func(tx *Tx) error {
-		meta := tx.readKey(key)
+		meta := tx.readFields(key, field)
 		if !meta.isOk() {
 			return nil
 		}
@@ -117,6 +121,7 @@ func (n *Nodis) HIncrBy(key string, field string, value int64) (int64, error) {
 	_ = n.exec(func(tx *Tx) error {
 		meta := tx.writeKey(key, n.newHash)
 		v, err = meta.value.(*hash.HashMap).HIncrBy(field, value)
+		n.indexHash(key, meta.value.(*hash.HashMap))
 		n.signalModifiedKey(key, meta)
 		n.notify(func() []patch.Op {
 			return []patch.Op{{Type: patch.OpTypeHIncrBy, Data: &patch.OpHIncrBy{Key: key, Field: field, IncrInt: value}}}
@@ -132,6 +137,7 @@ func (n *Nodis) HIncrByFloat(key string, field string, value float64) (float64,
 	_ = n.exec(func(tx *Tx) error {
 		meta := tx.writeKey(key, n.newHash)
 		v, err = meta.value.(*hash.HashMap).HIncrByFloat(field, value)
+		n.indexHash(key, meta.value.(*hash.HashMap))
 		n.signalModifiedKey(key, meta)
 		n.notify(func() []patch.Op {
 			return []patch.Op{{Type: patch.OpTypeHIncrByFloat, Data: &patch.OpHIncrByFloat{Key: key, Field: field, IncrFloat: value}}}
@@ -152,6 +158,7 @@ func (n *Nodis) HSetNX(key string, field string, value []byte) int64 {
 			return nil
 		}
 		v = meta.value.(*hash.HashMap).HSet(field, value)
+		n.indexHash(key, meta.value.(*hash.HashMap))
 		n.signalModifiedKey(key, meta)
 		n.notify(func() []patch.Op {
 			return []patch.Op{{Type: patch.OpTypeHSet, Data: &patch.OpHSet{Key: key, Field: field, Value: value}}}
@@ -178,6 +185,7 @@ func (n *Nodis) HMSet(key string, fields map[string][]byte) int64 {
 			return ops
 		})
 		meta.value.(*hash.HashMap).HMSet(fields)
+		n.indexHash(key, meta.value.(*hash.HashMap))
 		return nil
 	})
 	return v
@@ -186,7 +194,7 @@ func (n *Nodis) HMSet(key string, fields map[string][]byte) int64 {
 func (n *Nodis) HMGet(key string, fields ...string) [][]byte {
 	var v [][]byte
 	_ = n.exec(func(tx *Tx) error {
-		meta := tx.readKey(key)
+		meta := tx.readFields(key, fields...)
 		if !meta.isOk() {
 			return nil
 		}
@@ -240,3 +248,312 @@ func (n *Nodis) HStrLen(key, field string) int64 {
 	})
 	return v
 }
+
+// HSetEXOptions are the options of HSetEX
+type HSetEXOptions struct {
+	// NX only sets the fields if none of them exists
+	NX bool
+	// XX only sets the fields if all of them exist
+	XX bool
+	// ExpireAt is the unix time in milliseconds when the fields expire, 0 removes the expiration
+	ExpireAt int64
+	// KeepTTL keeps the expiration of the fields
+	KeepTTL bool
+}
+
+// HGetEXOptions are the options of HGetEX
+type HGetEXOptions struct {
+	// ExpireAt is the unix time in milliseconds when the fields expire, 0 keeps the expiration
+	ExpireAt int64
+	// Persist removes the expiration of the fields
+	Persist bool
+}
+
+// hExpireAt sets the expiration of the fields, see hash.HashMap.HPExpireAt for the results
+func (n *Nodis) hExpireAt(key string, at int64, cond hash.ExpireCondition, fields ...string) []int64 {
+	results := make([]int64, len(fields))
+	for i := range results {
+		results[i] = -2
+	}
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.writeKey(key, nil)
+		if !meta.isOk() {
+			return nil
+		}
+		h := meta.value.(*hash.HashMap)
+		var expired, deleted []string
+		for i, field := range fields {
+			results[i] = h.HPExpireAt(field, at, cond)
+			switch results[i] {
+			case 1:
+				expired = append(expired, field)
+			case 2:
+				deleted = append(deleted, field)
+			}
+		}
+		if len(expired) == 0 && len(deleted) == 0 {
+			return nil
+		}
+		if h.HLen() == 0 {
+			tx.delKey(key)
+		}
+		if len(deleted) > 0 {
+			n.indexHash(key, h)
+		}
+		n.signalModifiedKey(key, meta)
+		n.notify(func() []patch.Op {
+			ops := make([]patch.Op, 0, 2)
+			if len(expired) > 0 {
+				ops = append(ops, patch.Op{Type: patch.OpTypeHExpireAt, Data: &patch.OpHExpireAt{Key: key, Fields: expired, Expiration: at}})
+			}
+			if len(deleted) > 0 {
+				ops = append(ops, patch.Op{Type: patch.OpTypeHDel, Data: &patch.OpHDel{Key: key, Fields: deleted}})
+			}
+			return ops
+		})
+		return nil
+	})
+	return results
+}
+
+// HExpire sets the expiration of the fields in seconds.
+// For each field it returns -2 if the field doesn't exist, 0 if the condition isn't met,
+// 1 if the expiration is set and 2 if the field is deleted because seconds is 0.
+func (n *Nodis) HExpire(key string, seconds int64, cond hash.ExpireCondition, fields ...string) []int64 {
+	return n.hExpireAt(key, time.Now().UnixMilli()+seconds*1000, cond, fields...)
+}
+
+// HPExpire sets the expiration of the fields in milliseconds, the results are the same as HExpire
+func (n *Nodis) HPExpire(key string, milliseconds int64, cond hash.ExpireCondition, fields ...string) []int64 {
+	return n.hExpireAt(key, time.Now().UnixMilli()+milliseconds, cond, fields...)
+}
+
+// HExpireAt sets the expiration of the fields to the timestamp, the results are the same as HExpire
+func (n *Nodis) HExpireAt(key string, timestamp time.Time, cond hash.ExpireCondition, fields ...string) []int64 {
+	return n.hExpireAt(key, timestamp.UnixMilli(), cond, fields...)
+}
+
+// HPExpireTime returns the expiration of the fields as unix times in milliseconds,
+// -2 if the field doesn't exist and -1 if the field has no expiration
+func (n *Nodis) HPExpireTime(key string, fields ...string) []int64 {
+	results := make([]int64, len(fields))
+	for i := range results {
+		results[i] = -2
+	}
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		h := meta.value.(*hash.HashMap)
+		for i, field := range fields {
+			results[i] = h.HPExpireTime(field)
+		}
+		return nil
+	})
+	return results
+}
+
+// HExpireTime returns the expiration of the fields as unix times in seconds
+func (n *Nodis) HExpireTime(key string, fields ...string) []int64 {
+	results := n.HPExpireTime(key, fields...)
+	for i, at := range results {
+		if at > 0 {
+			results[i] = at / 1000
+		}
+	}
+	return results
+}
+
+// HPTTL returns the remaining time to live of the fields in milliseconds
+func (n *Nodis) HPTTL(key string, fields ...string) []int64 {
+	results := n.HPExpireTime(key, fields...)
+	now := time.Now().UnixMilli()
+	for i, at := range results {
+		if at > 0 {
+			results[i] = max(at-now, 0)
+		}
+	}
+	return results
+}
+
+// HTTL returns the remaining time to live of the fields in seconds
+func (n *Nodis) HTTL(key string, fields ...string) []int64 {
+	results := n.HPTTL(key, fields...)
+	for i, ttl := range results {
+		if ttl >= 0 {
+			results[i] = (ttl + 500) / 1000
+		}
+	}
+	return results
+}
+
+// HPersist removes the expiration of the fields, it returns -2 if the field
+// doesn't exist, -1 if the field has no expiration and 1 if it's removed
+func (n *Nodis) HPersist(key string, fields ...string) []int64 {
+	results := make([]int64, len(fields))
+	for i := range results {
+		results[i] = -2
+	}
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.writeKey(key, nil)
+		if !meta.isOk() {
+			return nil
+		}
+		h := meta.value.(*hash.HashMap)
+		var persisted []string
+		for i, field := range fields {
+			results[i] = h.HPersist(field)
+			if results[i] == 1 {
+				persisted = append(persisted, field)
+			}
+		}
+		if len(persisted) == 0 {
+			return nil
+		}
+		n.signalModifiedKey(key, meta)
+		n.notify(func() []patch.Op {
+			return []patch.Op{{Type: patch.OpTypeHPersist, Data: &patch.OpHPersist{Key: key, Fields: persisted}}}
+		})
+		return nil
+	})
+	return results
+}
+
+// HGetDel returns the values of the fields and deletes them, the key is deleted when the hash is empty
+func (n *Nodis) HGetDel(key string, fields ...string) [][]byte {
+	v := make([][]byte, len(fields))
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.writeKey(key, nil)
+		if !meta.isOk() {
+			return nil
+		}
+		h := meta.value.(*hash.HashMap)
+		v = h.HMGet(fields...)
+		if h.HDel(fields...) == 0 {
+			return nil
+		}
+		if h.HLen() == 0 {
+			tx.delKey(key)
+		}
+		n.indexHash(key, h)
+		n.signalModifiedKey(key, meta)
+		n.notify(func() []patch.Op {
+			return []patch.Op{{Type: patch.OpTypeHDel, Data: &patch.OpHDel{Key: key, Fields: fields}}}
+		})
+		return nil
+	})
+	return v
+}
+
+// HGetEX returns the values of the fields and sets or removes their expiration
+func (n *Nodis) HGetEX(key string, opts *HGetEXOptions, fields ...string) [][]byte {
+	if opts == nil {
+		opts = &HGetEXOptions{}
+	}
+	v := make([][]byte, len(fields))
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.writeKey(key, nil)
+		if !meta.isOk() {
+			return nil
+		}
+		h := meta.value.(*hash.HashMap)
+		v = h.HMGet(fields...)
+		var changed, deleted []string
+		for _, field := range fields {
+			var r int64
+			if opts.Persist {
+				r = h.HPersist(field)
+			} else if opts.ExpireAt != 0 {
+				r = h.HPExpireAt(field, opts.ExpireAt, hash.ExpireAlways)
+			}
+			switch r {
+			case 1:
+				changed = append(changed, field)
+			case 2:
+				deleted = append(deleted, field)
+			}
+		}
+		if len(changed) == 0 && len(deleted) == 0 {
+			return nil
+		}
+		if h.HLen() == 0 {
+			tx.delKey(key)
+		}
+		if len(deleted) > 0 {
+			n.indexHash(key, h)
+		}
+		n.signalModifiedKey(key, meta)
+		n.notify(func() []patch.Op {
+			ops := make([]patch.Op, 0, 2)
+			if len(changed) > 0 && opts.Persist {
+				ops = append(ops, patch.Op{Type: patch.OpTypeHPersist, Data: &patch.OpHPersist{Key: key, Fields: changed}})
+			} else if len(changed) > 0 {
+				ops = append(ops, patch.Op{Type: patch.OpTypeHExpireAt, Data: &patch.OpHExpireAt{Key: key, Fields: changed, Expiration: opts.ExpireAt}})
+			}
+			if len(deleted) > 0 {
+				ops = append(ops, patch.Op{Type: patch.OpTypeHDel, Data: &patch.OpHDel{Key: key, Fields: deleted}})
+			}
+			return ops
+		})
+		return nil
+	})
+	return v
+}
+
+// HSetEX sets the fields and their expiration, it returns false if the NX or XX condition isn't met
+func (n *Nodis) HSetEX(key string, fields map[string][]byte, opts *HSetEXOptions) bool {
+	if opts == nil {
+		opts = &HSetEXOptions{}
+	}
+	var ok bool
+	_ = n.exec(func(tx *Tx) error {
+		var created bool
+		meta := tx.writeKey(key, func() ds.Value {
+			created = true
+			return n.newHash()
+		})
+		h := meta.value.(*hash.HashMap)
+		for field := range fields {
+			exists := h.HExists(field)
+			if (opts.NX && exists) || (opts.XX && !exists) {
+				if created {
+					tx.delKey(key)
+				}
+				return nil
+			}
+		}
+		ok = true
+		expires := make(map[string]int64, len(fields))
+		for field, value := range fields {
+			if opts.KeepTTL {
+				if at := h.HPExpireTime(field); at > 0 {
+					expires[field] = at
+				}
+			} else if opts.ExpireAt != 0 {
+				expires[field] = opts.ExpireAt
+			}
+			h.HSet(field, value)
+		}
+		for field, at := range expires {
+			h.HPExpireAt(field, at, hash.ExpireAlways)
+		}
+		if h.HLen() == 0 {
+			tx.delKey(key)
+		}
+		n.indexHash(key, h)
+		n.signalModifiedKey(key, meta)
+		n.notify(func() []patch.Op {
+			ops := make([]patch.Op, 0, len(fields)+len(expires))
+			for field, value := range fields {
+				ops = append(ops, patch.Op{Type: patch.OpTypeHSet, Data: &patch.OpHSet{Key: key, Field: field, Value: value}})
+			}
+			for field, at := range expires {
+				ops = append(ops, patch.Op{Type: patch.OpTypeHExpireAt, Data: &patch.OpHExpireAt{Key: key, Fields: []string{field}, Expiration: at}})
+			}
+			return ops
+		})
+		return nil
+	})
+	return ok
+}
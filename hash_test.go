@@ -2,7 +2,12 @@ package nodis
 
 import (
 	"os"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/patch"
 )
 
 func TestHash_HSet(t *testing.T) {
@@ -145,3 +150,176 @@ func TestHash_HVals(t *testing.T) {
 		t.Error("HVars failed")
 	}
 }
+
+func TestHash_HExpire(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.HSet("hash", "a", []byte("1"))
+	n.HSet("hash", "b", []byte("2"))
+	if got := n.HExpire("hash", 100, hash.ExpireAlways, "a", "none"); !reflect.DeepEqual(got, []int64{1, -2}) {
+		t.Errorf("HExpire() = %v, want %v", got, []int64{1, -2})
+	}
+	if got := n.HExpire("hash", 200, hash.ExpireNX, "a", "b"); !reflect.DeepEqual(got, []int64{0, 1}) {
+		t.Errorf("HExpire() = %v, want %v", got, []int64{0, 1})
+	}
+	if got := n.HTTL("hash", "a", "b"); !reflect.DeepEqual(got, []int64{100, 200}) {
+		t.Errorf("HTTL() = %v, want %v", got, []int64{100, 200})
+	}
+	if got := n.HPersist("hash", "a", "a"); !reflect.DeepEqual(got, []int64{1, -1}) {
+		t.Errorf("HPersist() = %v, want %v", got, []int64{1, -1})
+	}
+	if got := n.HExpire("nokey", 100, hash.ExpireAlways, "a"); !reflect.DeepEqual(got, []int64{-2}) {
+		t.Errorf("HExpire() = %v, want %v", got, []int64{-2})
+	}
+	if got := n.HPExpire("hash", 0, hash.ExpireAlways, "a", "b"); !reflect.DeepEqual(got, []int64{2, 2}) {
+		t.Errorf("HPExpire() = %v, want %v", got, []int64{2, 2})
+	}
+	if n.Exists("hash") != 0 {
+		t.Error("HPExpire() didn't delete the empty hash")
+	}
+}
+
+func TestHash_FieldExpiration(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.HSet("hash", "a", []byte("1"))
+	n.HSet("hash", "b", []byte("2"))
+	n.HPExpire("hash", 1, hash.ExpireAlways, "a")
+	time.Sleep(5 * time.Millisecond)
+	if n.HGet("hash", "a") != nil || n.HLen("hash") != 1 || len(n.HGetAll("hash")) != 1 {
+		t.Error("expired field is visible")
+	}
+	at := time.Now().Add(time.Hour).Truncate(time.Second)
+	n.HExpireAt("hash", at, hash.ExpireAlways, "b")
+	if got := n.HExpireTime("hash", "a", "b"); !reflect.DeepEqual(got, []int64{-2, at.Unix()}) {
+		t.Errorf("HExpireTime() = %v, want %v", got, []int64{-2, at.Unix()})
+	}
+	n.HSet("hash", "c", []byte("3"))
+	n.HPExpire("hash", 1, hash.ExpireAlways, "c")
+	time.Sleep(5 * time.Millisecond)
+	n.store.gc()
+	if got := n.HPTTL("hash", "b", "c"); got[0] <= 0 || got[1] != -2 {
+		t.Errorf("HPTTL() = %v", got)
+	}
+	n.HExpireAt("hash", time.Now().Add(time.Millisecond), hash.ExpireAlways, "b")
+	time.Sleep(5 * time.Millisecond)
+	n.store.gc()
+	if n.Exists("hash") != 0 {
+		t.Error("gc didn't delete the empty hash")
+	}
+}
+
+func TestHash_LastFieldExpiration(t *testing.T) {
+	n := Open(&Options{})
+	defer n.Close()
+	if err := n.FTCreate(newSearchDefinition()); err != nil {
+		t.Fatalf("FTCreate() error = %v", err)
+	}
+	deleted := make(chan string, 2)
+	n.WatchKey([]string{"user:*"}, func(op patch.Op) {
+		if op.Type == patch.OpTypeDel {
+			deleted <- op.Data.GetKey()
+		}
+	})
+	for _, key := range []string{"user:1", "user:2"} {
+		n.HMSet(key, map[string][]byte{"name": []byte("John Smith"), "age": []byte("30")})
+		n.HPExpire(key, 1, hash.ExpireAlways, "name", "age")
+	}
+	time.Sleep(5 * time.Millisecond)
+	// read access
+	if n.Exists("user:1") != 0 || n.Type("user:1") != "none" || n.HLen("user:1") != 0 {
+		t.Error("the hash whose fields expired is visible")
+	}
+	// write access
+	if n.HSet("user:2", "age", []byte("31")) != 1 || len(n.HGetAll("user:2")) != 1 {
+		t.Errorf("HGetAll() = %v, want the new field only", n.HGetAll("user:2"))
+	}
+	if got := n.Keys("user:*"); !reflect.DeepEqual(got, []string{"user:2"}) {
+		t.Errorf("Keys() = %v, want %v", got, []string{"user:2"})
+	}
+	if got := searchKeys(t, n, "john"); len(got) != 0 {
+		t.Errorf("FTSearch() = %v, want the hashes removed from the index", got)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-deleted:
+		case <-time.After(time.Second):
+			t.Fatalf("no Del patch for the hash whose fields expired")
+		}
+	}
+}
+
+func TestHash_HGetDel(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.HSet("hash", "a", []byte("1"))
+	n.HSet("hash", "b", []byte("2"))
+	if got := n.HGetDel("hash", "a", "none"); !reflect.DeepEqual(got, [][]byte{[]byte("1"), nil}) {
+		t.Errorf("HGetDel() = %q", got)
+	}
+	n.HGetDel("hash", "b")
+	if n.Exists("hash") != 0 {
+		t.Error("HGetDel() didn't delete the empty hash")
+	}
+}
+
+func TestHash_HGetEX(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.HSet("hash", "a", []byte("1"))
+	at := time.Now().Add(time.Hour).UnixMilli()
+	if got := n.HGetEX("hash", &HGetEXOptions{ExpireAt: at}, "a", "none"); !reflect.DeepEqual(got, [][]byte{[]byte("1"), nil}) {
+		t.Errorf("HGetEX() = %q", got)
+	}
+	if got := n.HPExpireTime("hash", "a"); got[0] != at {
+		t.Errorf("HPExpireTime() = %v, want %v", got, at)
+	}
+	n.HGetEX("hash", &HGetEXOptions{Persist: true}, "a")
+	if got := n.HTTL("hash", "a"); got[0] != -1 {
+		t.Errorf("HTTL() = %v, want %v", got, -1)
+	}
+}
+
+func TestHash_HSetEX(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	at := time.Now().Add(time.Hour).UnixMilli()
+	if !n.HSetEX("hash", map[string][]byte{"a": []byte("1")}, &HSetEXOptions{ExpireAt: at}) {
+		t.Error("HSetEX() = false, want true")
+	}
+	if n.HSetEX("hash", map[string][]byte{"a": []byte("2"), "b": []byte("2")}, &HSetEXOptions{NX: true}) {
+		t.Error("HSetEX(NX) = true, want false")
+	}
+	if n.HSetEX("other", map[string][]byte{"a": []byte("2")}, &HSetEXOptions{XX: true}) || n.Exists("other") != 0 {
+		t.Error("HSetEX(XX) created the key")
+	}
+	n.HSetEX("hash", map[string][]byte{"a": []byte("3")}, &HSetEXOptions{XX: true, KeepTTL: true})
+	if got := n.HPExpireTime("hash", "a"); string(n.HGet("hash", "a")) != "3" || got[0] != at {
+		t.Errorf("HPExpireTime() = %v, want %v", got, at)
+	}
+	n.HSetEX("hash", map[string][]byte{"a": []byte("4")}, nil)
+	if got := n.HTTL("hash", "a"); got[0] != -1 {
+		t.Errorf("HTTL() = %v, want %v", got, -1)
+	}
+}
+
+func TestHash_FieldExpirationPatch(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n2 := Open(&Options{})
+	n.HSet("hash", "a", []byte("1"))
+	n2.HSet("hash", "a", []byte("1"))
+	n.WatchKey([]string{"*"}, func(op patch.Op) {
+		_ = n2.ApplyPatch(op)
+	})
+	n.HExpire("hash", 100, hash.ExpireAlways, "a")
+	time.Sleep(50 * time.Millisecond)
+	if got := n2.HTTL("hash", "a"); got[0] != 100 {
+		t.Errorf("HTTL() = %v, want %v", got, 100)
+	}
+	n.HPersist("hash", "a")
+	time.Sleep(50 * time.Millisecond)
+	if got := n2.HTTL("hash", "a"); got[0] != -1 {
+		t.Errorf("HTTL() = %v, want %v", got, -1)
+	}
+}
@@ -0,0 +1,100 @@
+package encoding
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// AppendString appends the string prefixed by its uvarint length
+func AppendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// Reader decodes the values encoded by the data structures, it stops reading after the first
+// error and returns the zero values then
+type Reader struct {
+	b   []byte
+	err bool
+}
+
+// NewReader returns a reader of the bytes
+func NewReader(b []byte) *Reader {
+	return &Reader{b: b}
+}
+
+// Ok returns if all the values have been read
+func (r *Reader) Ok() bool {
+	return !r.err
+}
+
+// Len returns the number of the bytes left
+func (r *Reader) Len() int {
+	return len(r.b)
+}
+
+func (r *Reader) Uvarint() uint64 {
+	if r.err {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = true
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *Reader) Varint() int64 {
+	if r.err {
+		return 0
+	}
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = true
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *Reader) Byte() byte {
+	b := r.Bytes(1)
+	if len(b) == 0 {
+		return 0
+	}
+	return b[0]
+}
+
+// Bytes returns the next n bytes, they aren't copied
+func (r *Reader) Bytes(n int) []byte {
+	if r.err || n < 0 || len(r.b) < n {
+		r.err = true
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+// String returns the string prefixed by its uvarint length
+func (r *Reader) String() string {
+	return string(r.Bytes(int(r.Uvarint())))
+}
+
+func (r *Reader) Float32() float32 {
+	b := r.Bytes(4)
+	if len(b) == 0 {
+		return 0
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b))
+}
+
+func (r *Reader) Float64() float64 {
+	b := r.Bytes(8)
+	if len(b) == 0 {
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
@@ -14,6 +14,18 @@ func ToUpper(v string) string {
 	return unsafe.String(unsafe.SliceData(buf), len(buf))
 }
 
+func ToLower(v string) string {
+	buf := make([]byte, len(v))
+	for i, vv := range v {
+		if 'A' <= vv && vv <= 'Z' {
+			buf[i] = uint8(vv + 32)
+		} else {
+			buf[i] = uint8(vv)
+		}
+	}
+	return unsafe.String(unsafe.SliceData(buf), len(buf))
+}
+
 func Fnv32(key string) uint32 {
 	h := uint32(2166136261)
 	for i := 0; i < len(key); i++ {
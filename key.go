@@ -8,8 +8,13 @@ import (
 	"time"
 
 	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
 	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/ds/set"
+	"github.com/diiyw/nodis/ds/str"
+	"github.com/diiyw/nodis/ds/zset"
 	"github.com/diiyw/nodis/redis"
+	"github.com/diiyw/nodis/storage"
 
 	"github.com/diiyw/nodis/patch"
 )
@@ -24,6 +29,9 @@ func (n *Nodis) Del(keys ...string) int64 {
 				continue
 			}
 			tx.delKey(key)
+			if meta.valueType == ds.Hash {
+				n.indexHash(key, nil)
+			}
 			c++
 		}
 		return nil
@@ -31,6 +39,19 @@ func (n *Nodis) Del(keys ...string) int64 {
 	return c
 }
 
+// deleted notifies the deletion of a key by the store rather than by a command, the key is
+// locked
+func (n *Nodis) deleted(m *metadata) {
+	key := m.key.Name
+	if m.valueType == ds.Hash {
+		n.indexHash(key, nil)
+	}
+	n.signalModifiedKey(key, m)
+	n.notify(func() []patch.Op {
+		return []patch.Op{{Type: patch.OpTypeDel, Data: &patch.OpDel{Key: key}}}
+	})
+}
+
 func (n *Nodis) Unlink(keys ...string) int64 {
 	v := n.Del(keys...)
 	runtime.GC()
@@ -452,8 +473,9 @@ func (n *Nodis) Rename(key, dstKey string) error {
 		}
 		dstMeta.setValue(meta.value)
 		tx.storeMeta(dstMeta)
+		n.indexRenamed(key, dstKey, meta, dstMeta)
 		n.signalModifiedKey(key, meta)
-		n.signalModifiedKey(key, dstMeta)
+		n.signalModifiedKey(dstKey, dstMeta)
 		n.notify(func() []patch.Op {
 			return []patch.Op{{Type: patch.OpTypeRename, Data: &patch.OpRename{Key: key, DstKey: dstKey}}}
 		})
@@ -478,8 +500,9 @@ func (n *Nodis) RenameNX(key, dstKey string) error {
 		dstMeta.state = meta.state
 		dstMeta.setValue(meta.value)
 		tx.storeMeta(dstMeta)
+		n.indexRenamed(key, dstKey, meta, dstMeta)
 		n.signalModifiedKey(key, meta)
-		n.signalModifiedKey(key, dstMeta)
+		n.signalModifiedKey(dstKey, dstMeta)
 		n.notify(func() []patch.Op {
 			return []patch.Op{{Type: patch.OpTypeRename, Data: &patch.OpRename{Key: key, DstKey: dstKey}}}
 		})
@@ -502,6 +525,172 @@ func (n *Nodis) Type(key string) string {
 	return v
 }
 
+// peek runs fn on the key without recording an access, like the OBJECT subcommands
+func (n *Nodis) peek(key string, fn func(meta *metadata)) {
+	tx := newTx(n.store)
+	tx.noTouch = true
+	defer tx.commit()
+	fn(tx.readKey(key))
+}
+
+// ObjectEncoding returns the internal encoding of the key value, it's empty if the key doesn't exist
+func (n *Nodis) ObjectEncoding(key string) string {
+	var v string
+	n.peek(key, func(meta *metadata) {
+		if !meta.isOk() {
+			return
+		}
+		switch value := meta.value.(type) {
+		case *str.String:
+			s := value.Get()
+			switch {
+			case ds.IsIntString(string(s)):
+				v = "int"
+			case len(s) <= 44:
+				v = "embstr"
+			default:
+				v = "raw"
+			}
+		case *list.LinkedList:
+			v = "quicklist"
+		case *hash.HashMap:
+			v = value.Encoding()
+		case *set.Set:
+			v = value.Encoding()
+		case *zset.SortedSet:
+			v = value.Encoding()
+		default:
+			v = "raw"
+		}
+	})
+	return v
+}
+
+// ObjectIdleTime returns the time since the last access of the key, false if the key doesn't exist
+func (n *Nodis) ObjectIdleTime(key string) (time.Duration, bool) {
+	var v time.Duration
+	var ok bool
+	n.peek(key, func(meta *metadata) {
+		if ok = meta.isOk(); ok {
+			v = meta.idleTime(time.Now().UnixMilli())
+		}
+	})
+	return v, ok
+}
+
+// ObjectFreq returns the logarithmic access frequency of the key, from 0 to 255 like the LFU counter
+// of Redis. It decreases by one every minute the key is idle. It's false if the key doesn't exist.
+func (n *Nodis) ObjectFreq(key string) (int64, bool) {
+	var v int64
+	var ok bool
+	n.peek(key, func(meta *metadata) {
+		if ok = meta.isOk(); ok {
+			v = int64(meta.frequency(time.Now().UnixMilli()))
+		}
+	})
+	return v, ok
+}
+
+// ObjectRefCount returns the number of references of the key value, the values are never shared
+// so it's always 1. It's false if the key doesn't exist.
+func (n *Nodis) ObjectRefCount(key string) (int64, bool) {
+	var ok bool
+	n.peek(key, func(meta *metadata) {
+		ok = meta.isOk()
+	})
+	if !ok {
+		return 0, false
+	}
+	return 1, true
+}
+
+// Touch records an access to the keys and returns the number of existing keys
+func (n *Nodis) Touch(keys ...string) int64 {
+	var v int64
+	for _, key := range keys {
+		_ = n.exec(func(tx *Tx) error {
+			if tx.readKey(key).isOk() {
+				v++
+			}
+			return nil
+		})
+	}
+	return v
+}
+
+// PExpireTime returns the unix time in milliseconds when the key expires,
+// -2 if the key doesn't exist and -1 if it has no expiration
+func (n *Nodis) PExpireTime(key string) int64 {
+	var v int64
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		switch {
+		case !meta.isOk():
+			v = -2
+		case meta.key.Expiration == 0:
+			v = -1
+		default:
+			v = meta.key.Expiration
+		}
+		return nil
+	})
+	return v
+}
+
+// ExpireTime returns the unix time in seconds when the key expires,
+// -2 if the key doesn't exist and -1 if it has no expiration
+func (n *Nodis) ExpireTime(key string) int64 {
+	v := n.PExpireTime(key)
+	if v > 0 {
+		v /= 1000
+	}
+	return v
+}
+
+// Copy copies the value of key and its expiration to dstKey, an existing dstKey is only overwritten
+// if replace is true. It returns whether the value is copied.
+func (n *Nodis) Copy(key, dstKey string, replace bool) bool {
+	if key == dstKey {
+		return false
+	}
+	var ok bool
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		dstMeta := tx.writeKey(dstKey, nil)
+		if dstMeta.isOk() && !replace {
+			return nil
+		}
+		value, err := storage.NewEntry(meta.value).GetValue()
+		if err != nil {
+			return err
+		}
+		if l, ok := value.(*list.LinkedList); ok {
+			l.SetOptions(n.listOptions())
+		}
+		if e, ok := value.(ds.Encoded); ok {
+			e.SetEncodings(n.store.encodings)
+		}
+		if dstMeta.isOk() {
+			dstMeta.setValue(value)
+		} else {
+			dstMeta = tx.newStoredMetadata(dstMeta, func() ds.Value { return value })
+		}
+		dstMeta.key.Expiration = meta.key.Expiration
+		h, _ := value.(*hash.HashMap)
+		n.indexHash(dstKey, h)
+		ok = true
+		n.signalModifiedKey(dstKey, dstMeta)
+		n.notify(func() []patch.Op {
+			return []patch.Op{{Type: patch.OpTypeCopy, Data: &patch.OpCopy{Key: key, DstKey: dstKey, Replace: replace}}}
+		})
+		return nil
+	})
+	return ok
+}
+
 // Scan the keys
 func (n *Nodis) Scan(cursor int64, match string, count int64, typ ds.ValueType) (int64, []string) {
 	keyLen := int64(len(n.store.metadata))
@@ -591,4 +780,5 @@ func (n *Nodis) signalModifiedKey(key string, meta *metadata) {
 		})
 	}
 	n.store.watchMu.RUnlock()
+	n.blocking.signal(key)
 }
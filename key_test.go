@@ -293,3 +293,91 @@ func TestKey_Exists(t *testing.T) {
 		t.Errorf("Exists() = %v, want %v", n.Exists("test"), false)
 	}
 }
+
+func TestKey_Copy(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.SetEX("src", []byte("v1"), 100)
+	if !n.Copy("src", "dst", false) {
+		t.Fatalf("Copy() = false, want true")
+	}
+	if v := n.Get("dst"); string(v) != "v1" {
+		t.Errorf("Get() = %s, want %s", v, "v1")
+	}
+	if n.TTL("dst") <= 0 {
+		t.Errorf("TTL() = %v, want > 0", n.TTL("dst"))
+	}
+	n.Set("src", []byte("v2"), false)
+	if v := n.Get("dst"); string(v) != "v1" {
+		t.Errorf("Get() = %s after changing the source, want %s", v, "v1")
+	}
+	if n.Copy("src", "dst", false) {
+		t.Errorf("Copy() = true on an existing destination, want false")
+	}
+	if !n.Copy("src", "dst", true) {
+		t.Errorf("Copy() = false with replace, want true")
+	}
+	if v := n.Get("dst"); string(v) != "v2" {
+		t.Errorf("Get() = %s, want %s", v, "v2")
+	}
+	n.HSet("hash", "a", []byte("1"))
+	if !n.Copy("hash", "dst", true) {
+		t.Errorf("Copy() = false with replace, want true")
+	}
+	if v := n.HGet("dst", "a"); string(v) != "1" {
+		t.Errorf("HGet() = %s, want %s", v, "1")
+	}
+	if n.Copy("missing", "dst2", false) || n.Copy("hash", "hash", true) {
+		t.Errorf("Copy() = true, want false")
+	}
+}
+
+func TestKey_Touch(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.Set("a", []byte("1"), false)
+	n.Set("b", []byte("2"), false)
+	n.peek("a", func(meta *metadata) {
+		meta.access.Store(time.Now().Add(-10 * time.Second).UnixMilli())
+	})
+	idle, ok := n.ObjectIdleTime("a")
+	if !ok || idle < 10*time.Second {
+		t.Errorf("ObjectIdleTime() = %v, %v, want >= 10s", idle, ok)
+	}
+	if v := n.Touch("a", "b", "missing"); v != 2 {
+		t.Errorf("Touch() = %v, want %v", v, 2)
+	}
+	idle, _ = n.ObjectIdleTime("a")
+	if idle >= 10*time.Second {
+		t.Errorf("ObjectIdleTime() = %v after touch, want < 10s", idle)
+	}
+	if freq, ok := n.ObjectFreq("a"); !ok || freq < lfuInitFreq {
+		t.Errorf("ObjectFreq() = %v, %v, want >= %v", freq, ok, lfuInitFreq)
+	}
+	if _, ok := n.ObjectIdleTime("missing"); ok {
+		t.Errorf("ObjectIdleTime() = true on a missing key, want false")
+	}
+	if v, ok := n.ObjectRefCount("a"); !ok || v != 1 {
+		t.Errorf("ObjectRefCount() = %v, %v, want 1", v, ok)
+	}
+}
+
+func TestKey_ExpireTime(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.Set("a", []byte("1"), false)
+	if v := n.ExpireTime("a"); v != -1 {
+		t.Errorf("ExpireTime() = %v, want %v", v, -1)
+	}
+	if v := n.ExpireTime("missing"); v != -2 {
+		t.Errorf("ExpireTime() = %v, want %v", v, -2)
+	}
+	at := time.Now().Add(time.Hour).UnixMilli()
+	n.ExpireAt("a", time.UnixMilli(at))
+	if v := n.PExpireTime("a"); v != at {
+		t.Errorf("PExpireTime() = %v, want %v", v, at)
+	}
+	if v := n.ExpireTime("a"); v != at/1000 {
+		t.Errorf("ExpireTime() = %v, want %v", v, at/1000)
+	}
+}
@@ -9,9 +9,17 @@ import (
 	"github.com/diiyw/nodis/ds/list"
 )
 
+// listOptions returns the options of the lists
+func (n *Nodis) listOptions() list.Options {
+	return list.Options{
+		ChunkSize:     n.options.ListChunkSize,
+		CompressDepth: n.options.ListCompressDepth,
+	}
+}
+
 // newList creates a new list
 func (n *Nodis) newList() ds.Value {
-	return list.NewLinkedList()
+	return list.NewLinkedListWithOptions(n.listOptions())
 }
 
 func (n *Nodis) LPush(key string, values ...[]byte) int64 {
@@ -20,7 +28,6 @@ func (n *Nodis) LPush(key string, values ...[]byte) int64 {
 		meta := tx.writeKey(key, n.newList)
 		meta.value.(*list.LinkedList).LPush(values...)
 		v = meta.value.(*list.LinkedList).LLen()
-		n.notifyBlockingKey(key)
 		n.signalModifiedKey(key, meta)
 		n.notify(func() []patch.Op {
 			return []patch.Op{{Type: patch.OpTypeLPush, Data: &patch.OpLPush{Key: key, Values: values}}}
@@ -36,7 +43,6 @@ func (n *Nodis) RPush(key string, values ...[]byte) int64 {
 		meta := tx.writeKey(key, n.newList)
 		meta.value.(*list.LinkedList).RPush(values...)
 		v = meta.value.(*list.LinkedList).LLen()
-		n.notifyBlockingKey(key)
 		n.signalModifiedKey(key, meta)
 		n.notify(func() []patch.Op {
 			return []patch.Op{{Type: patch.OpTypeRPush, Data: &patch.OpRPush{Key: key, Values: values}}}
@@ -233,165 +239,128 @@ func (n *Nodis) LRange(key string, start, stop int64) [][]byte {
 	return v
 }
 
+// LPopRPush pops the first element of source and pushes it to the tail of destination, see LMove.
 func (n *Nodis) LPopRPush(source, destination string) []byte {
-	var v [][]byte
-	_ = n.exec(func(tx *Tx) error {
-		meta := tx.writeKey(source, nil)
-		if !meta.isOk() {
-
-			return nil
-		}
-		v = meta.value.(*list.LinkedList).LPop(1)
-		if v == nil {
-			return nil
-		}
-		if meta.value.(*list.LinkedList).LLen() == 0 {
-			tx.delKey(source)
-		}
-		n.signalModifiedKey(source, meta)
-		dst := tx.writeKey(destination, n.newList)
-		dst.value.(*list.LinkedList).RPush(v...)
-		n.notifyBlockingKey(destination)
-		n.signalModifiedKey(destination, dst)
-		n.notify(func() []patch.Op {
-			return []patch.Op{{Type: patch.OpTypeLPopRPush, Data: &patch.OpLPopRPush{Key: source, DstKey: destination}}}
-		})
-		return nil
-	})
-	if len(v) == 0 {
-		return nil
-	}
-	return v[0]
+	return n.LMove(source, destination, list.Left, list.Right)
 }
 
+// RPopLPush pops the last element of source and pushes it to the head of destination, see LMove.
 func (n *Nodis) RPopLPush(source, destination string) []byte {
-	var v [][]byte
+	return n.LMove(source, destination, list.Right, list.Left)
+}
+
+// LMove atomically pops an element from the wherefrom end of source and pushes it to the whereto end
+// of destination, source and destination may be the same list. It returns nil if source is empty.
+func (n *Nodis) LMove(source, destination string, wherefrom, whereto list.Direction) []byte {
+	var v []byte
 	_ = n.exec(func(tx *Tx) error {
 		meta := tx.writeKey(source, nil)
 		if !meta.isOk() {
 			return nil
 		}
-		v = meta.value.(*list.LinkedList).RPop(1)
-		if v == nil {
+		values := meta.value.(*list.LinkedList).Pop(wherefrom, 1)
+		if values == nil {
 			return nil
 		}
-		if meta.value.(*list.LinkedList).LLen() == 0 {
-			tx.delKey(source)
+		v = values[0]
+		dst := meta
+		if destination != source {
+			if meta.value.(*list.LinkedList).LLen() == 0 {
+				tx.delKey(source)
+			}
+			n.signalModifiedKey(source, meta)
+			dst = tx.writeKey(destination, n.newList)
 		}
-		n.signalModifiedKey(source, meta)
-		dst := tx.writeKey(destination, n.newList)
-		dst.value.(*list.LinkedList).LPush(v...)
-		n.notifyBlockingKey(destination)
+		dst.value.(*list.LinkedList).Push(whereto, v)
 		n.signalModifiedKey(destination, dst)
 		n.notify(func() []patch.Op {
-			return []patch.Op{{Type: patch.OpTypeRPopLPush, Data: &patch.OpRPopLPush{Key: source, DstKey: destination}}}
+			return []patch.Op{{Type: patch.OpTypeLMove, Data: &patch.OpLMove{Key: source, DstKey: destination, From: uint32(wherefrom), To: uint32(whereto)}}}
 		})
 		return nil
 	})
-	if len(v) == 0 {
-		return nil
-	}
-	return v[0]
+	return v
 }
 
-func (n *Nodis) addBlockKey(key string, c chan string) {
-	n.blockingKeysMutex.Lock()
-	cList, ok := n.blockingKeys[key]
-	if !ok {
-		cList = list.NewLinkedListG[chan string]()
-		cList.LPush(c)
-		n.blockingKeys[key] = cList
-	} else {
-		cList.LPush(c)
-	}
-	n.blockingKeysMutex.Unlock()
+// BLMove is the blocking variant of LMove, see BLPop.
+func (n *Nodis) BLMove(timeout time.Duration, source, destination string, wherefrom, whereto list.Direction) []byte {
+	v, _ := n.bLMove(nil, timeout, source, destination, wherefrom, whereto)
+	return v
 }
 
-func (n *Nodis) notifyBlockingKey(key string) {
-	n.blockingKeysMutex.RLock()
-	cList, ok := n.blockingKeys[key]
-	n.blockingKeysMutex.RUnlock()
-	if !ok {
-		return
-	}
-	cList.ForRange(func(c chan string) bool {
-		c <- key
-		return true
+func (n *Nodis) bLMove(c *blockedClient, timeout time.Duration, source, destination string, wherefrom, whereto list.Direction) ([]byte, error) {
+	var v []byte
+	_, err := n.block(c, timeout, []string{source}, func(key string) bool {
+		v = n.LMove(source, destination, wherefrom, whereto)
+		return v != nil
 	})
+	return v, err
 }
 
-func (n *Nodis) removeBlockingKeys(rc chan string, keys ...string) {
-	n.blockingKeysMutex.Lock()
-	for _, key := range keys {
-		cList, ok := n.blockingKeys[key]
-		if !ok {
-			n.blockingKeysMutex.Unlock()
-			return
-		}
-		cList.ForRangeNode(func(node *list.NodeG[chan string]) bool {
-			if node.Value() == rc {
-				cList.RemoveNode(node)
-				return false
-			}
-			return true
-		})
-	}
-	close(rc)
-	n.blockingKeysMutex.Unlock()
+// BRPopLPush is the blocking variant of RPopLPush, see BLMove.
+func (n *Nodis) BRPopLPush(timeout time.Duration, source, destination string) []byte {
+	return n.BLMove(timeout, source, destination, list.Right, list.Left)
 }
 
-func (n *Nodis) BLPop(timeout time.Duration, keys ...string) (string, []byte) {
-	var c = make(chan string)
-	defer n.removeBlockingKeys(c, keys...)
+// LMPop pops up to count elements from the where end of the first non-empty list.
+func (n *Nodis) LMPop(keys []string, where list.Direction, count int64) (string, [][]byte) {
 	for _, key := range keys {
-		results := n.LPop(key, 1)
-		if results != nil {
-			n.notify(func() []patch.Op {
-				return []patch.Op{{Type: patch.OpTypeLPop, Data: &patch.OpLPop{Key: key}}}
-			})
-			return key, results[0]
+		var v [][]byte
+		if where == list.Left {
+			v = n.LPop(key, count)
+		} else {
+			v = n.RPop(key, count)
 		}
-		n.addBlockKey(key, c)
-	}
-	select {
-	case key := <-c:
-		results := n.LPop(key, 1)
-		if results != nil {
-			n.notify(func() []patch.Op {
-				return []patch.Op{{Type: patch.OpTypeLPop, Data: &patch.OpLPop{Key: key}}}
-			})
-			return key, results[0]
+		if len(v) > 0 {
+			return key, v
 		}
-	case <-time.After(timeout):
-		break
 	}
 	return "", nil
 }
 
-func (n *Nodis) BRPop(timeout time.Duration, keys ...string) (string, []byte) {
-	var c = make(chan string)
-	defer n.removeBlockingKeys(c, keys...)
-	for _, key := range keys {
-		results := n.RPop(key, 1)
-		if results != nil {
-			n.notify(func() []patch.Op {
-				return []patch.Op{{Type: patch.OpTypeRPop, Data: &patch.OpRPop{Key: key}}}
-			})
-			return key, results[0]
+// BLMPop is the blocking variant of LMPop, see BLPop.
+func (n *Nodis) BLMPop(timeout time.Duration, keys []string, where list.Direction, count int64) (string, [][]byte) {
+	key, v, _ := n.bLMPop(nil, timeout, keys, where, count)
+	return key, v
+}
+
+func (n *Nodis) bLMPop(c *blockedClient, timeout time.Duration, keys []string, where list.Direction, count int64) (string, [][]byte, error) {
+	var v [][]byte
+	key, err := n.block(c, timeout, keys, func(key string) bool {
+		_, v = n.LMPop([]string{key}, where, count)
+		return len(v) > 0
+	})
+	return key, v, err
+}
+
+// LPos returns the indexes of the elements matching element in the list stored at key, see list.LinkedList.LPos.
+func (n *Nodis) LPos(key string, element []byte, rank, count, maxLen int64) []int64 {
+	var v []int64
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
 		}
-		n.addBlockKey(key, c)
+		v = meta.value.(*list.LinkedList).LPos(element, rank, count, maxLen)
+		return nil
+	})
+	return v
+}
+
+// BLPop is the blocking variant of LPop, it pops the first element of the first non-empty list and
+// blocks until an element is pushed or the timeout is reached, a zero timeout blocks indefinitely.
+func (n *Nodis) BLPop(timeout time.Duration, keys ...string) (string, []byte) {
+	key, v, _ := n.bLMPop(nil, timeout, keys, list.Left, 1)
+	if len(v) == 0 {
+		return "", nil
 	}
-	select {
-	case key := <-c:
-		results := n.RPop(key, 1)
-		if results != nil {
-			n.notify(func() []patch.Op {
-				return []patch.Op{{Type: patch.OpTypeRPop, Data: &patch.OpRPop{Key: key}}}
-			})
-			return key, results[0]
-		}
-	case <-time.After(timeout):
-		break
+	return key, v[0]
+}
+
+// BRPop is the blocking variant of RPop, see BLPop.
+func (n *Nodis) BRPop(timeout time.Duration, keys ...string) (string, []byte) {
+	key, v, _ := n.bLMPop(nil, timeout, keys, list.Right, 1)
+	if len(v) == 0 {
+		return "", nil
 	}
-	return "", nil
+	return key, v[0]
 }
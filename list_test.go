@@ -2,8 +2,13 @@ package nodis
 
 import (
 	"os"
+	"reflect"
+	"strconv"
 	"testing"
 	"time"
+
+	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/patch"
 )
 
 func TestList_LPush(t *testing.T) {
@@ -231,3 +236,112 @@ func TestList_RPopLPush2(t *testing.T) {
 		t.Error("LPopRPush failed expected list length 1")
 	}
 }
+
+func TestList_LMove(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.RPush("list", []byte("a"), []byte("b"), []byte("c"))
+	if v := n.LMove("list", "list", list.Right, list.Left); string(v) != "c" {
+		t.Errorf("LMove() = %s, want %s", v, "c")
+	}
+	if v := n.LMove("list", "dst", list.Left, list.Right); string(v) != "c" {
+		t.Errorf("LMove() = %s, want %s", v, "c")
+	}
+	if v := n.RPopLPush("list", "dst"); string(v) != "b" {
+		t.Errorf("RPopLPush() = %s, want %s", v, "b")
+	}
+	if got := n.LRange("dst", 0, -1); len(got) != 2 || string(got[0]) != "b" || string(got[1]) != "c" {
+		t.Errorf("LRange() = %s", got)
+	}
+	n.LPopRPush("list", "dst")
+	if n.Exists("list") != 0 || n.LMove("list", "dst", list.Left, list.Left) != nil {
+		t.Errorf("LMove() should empty the source")
+	}
+}
+
+func TestList_BLMove(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	if v := n.BLMove(10*time.Millisecond, "list", "dst", list.Left, list.Left); v != nil {
+		t.Errorf("BLMove() = %s, want nil", v)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		n.RPush("list", []byte("value"))
+	}()
+	if v := n.BRPopLPush(0, "list", "dst"); string(v) != "value" {
+		t.Errorf("BRPopLPush() = %s, want %s", v, "value")
+	}
+	if v := n.LIndex("dst", 0); string(v) != "value" {
+		t.Errorf("LIndex() = %s, want %s", v, "value")
+	}
+}
+
+func TestList_LMPop(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.RPush("list2", []byte("a"), []byte("b"), []byte("c"))
+	key, v := n.LMPop([]string{"list1", "list2"}, list.Right, 2)
+	if key != "list2" || len(v) != 2 || string(v[0]) != "c" || string(v[1]) != "b" {
+		t.Errorf("LMPop() = %v, %s", key, v)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		n.LPush("list1", []byte("x"))
+	}()
+	n.LPop("list2", 1)
+	key, v = n.BLMPop(time.Second, []string{"list1", "list2"}, list.Left, 5)
+	if key != "list1" || len(v) != 1 || string(v[0]) != "x" {
+		t.Errorf("BLMPop() = %v, %s", key, v)
+	}
+}
+
+func TestList_LPos(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.RPush("list", []byte("a"), []byte("b"), []byte("a"))
+	if got := n.LPos("list", []byte("a"), -1, 0, 0); !reflect.DeepEqual(got, []int64{2, 0}) {
+		t.Errorf("LPos() = %v, want %v", got, []int64{2, 0})
+	}
+	if got := n.LPos("none", []byte("a"), 1, 1, 0); got != nil {
+		t.Errorf("LPos() = %v, want nil", got)
+	}
+}
+
+func TestList_LMovePatch(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n2 := Open(&Options{})
+	n.RPush("list", []byte("a"), []byte("b"))
+	n2.RPush("list", []byte("a"), []byte("b"))
+	n.WatchKey([]string{"*"}, func(op patch.Op) {
+		_ = n2.ApplyPatch(op)
+	})
+	n.LMove("list", "dst", list.Right, list.Left)
+	time.Sleep(50 * time.Millisecond)
+	if got := n2.LRange("dst", 0, -1); len(got) != 1 || string(got[0]) != "b" {
+		t.Errorf("LRange() = %s", got)
+	}
+}
+
+func TestList_OptionsRestored(t *testing.T) {
+	n := Open(&Options{ListChunkSize: 8, ListCompressDepth: 1})
+	defer n.Close()
+	for i := 0; i < 64; i++ {
+		n.RPush("list", []byte(strconv.Itoa(i)))
+	}
+	want := list.Options{ChunkSize: 8, CompressDepth: 1}
+	_ = n.Snapshot()
+	n.store.metadata["list"].removeFromMemory()
+	n.Copy("list", "copy", false)
+	for _, key := range []string{"list", "copy"} {
+		n.peek(key, func(meta *metadata) {
+			if got := meta.value.(*list.LinkedList).Options(); got != want {
+				t.Errorf("%s options = %+v, want %+v", key, got, want)
+			}
+		})
+	}
+	if got := n.LRange("list", 30, 30); len(got) != 1 || string(got[0]) != "30" {
+		t.Errorf("LRange() = %s, want 30", got)
+	}
+}
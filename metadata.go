@@ -1,8 +1,10 @@
 package nodis
 
 import (
+	"math/rand"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/diiyw/nodis/ds"
 )
@@ -12,23 +14,76 @@ const (
 	KeyStateModified uint8 = 2
 )
 
+const (
+	// lfuInitFreq is the access frequency of the new keys, they are not evicted right away
+	lfuInitFreq = 5
+	// lfuLogFactor makes the frequency logarithmic, it takes about 1M accesses to reach 255
+	lfuLogFactor = 10
+	// lfuDecayTime is the duration after which an idle key frequency is decremented
+	lfuDecayTime = time.Minute
+)
+
 type metadata struct {
 	*sync.RWMutex
 	key       *ds.Key
 	value     ds.Value
-	count     atomic.Int64
 	valueType ds.ValueType
+	// access is the unix time in milliseconds of the last access
+	access atomic.Int64
+	// freq is the logarithmic access frequency, like the LFU counter of Redis
+	freq      atomic.Uint32
 	state     uint8
 	writeable bool
+	// exploded is set if the value is stored exploded
+	exploded bool
+	// size is the estimated memory used by the key and its value, 0 when the value isn't in memory
+	size atomic.Int64
 }
 
 func newMetadata(key *ds.Key, writeable bool) *metadata {
-	return &metadata{
+	m := &metadata{
 		RWMutex:   new(sync.RWMutex),
 		key:       key,
 		value:     nil,
 		writeable: writeable,
 	}
+	m.resetAccess()
+	return m
+}
+
+// resetAccess resets the access tracking as if the key is new
+func (m *metadata) resetAccess() {
+	m.access.Store(time.Now().UnixMilli())
+	m.freq.Store(lfuInitFreq)
+}
+
+// touch records an access to the key
+func (m *metadata) touch() {
+	now := time.Now().UnixMilli()
+	freq := m.frequency(now)
+	if freq < 255 {
+		base := max(float64(freq)-lfuInitFreq, 0)
+		if rand.Float64() < 1/(base*lfuLogFactor+1) {
+			freq++
+		}
+	}
+	m.freq.Store(freq)
+	m.access.Store(now)
+}
+
+// idleTime returns the time since the last access
+func (m *metadata) idleTime(now int64) time.Duration {
+	return time.Duration(max(now-m.access.Load(), 0)) * time.Millisecond
+}
+
+// frequency returns the access frequency decremented for every lfuDecayTime the key has been idle
+func (m *metadata) frequency(now int64) uint32 {
+	periods := uint32(m.idleTime(now) / lfuDecayTime)
+	freq := m.freq.Load()
+	if periods >= freq {
+		return 0
+	}
+	return freq - periods
 }
 
 func (m *metadata) expired(now int64) bool {
@@ -49,7 +104,6 @@ func (m *metadata) modified() bool {
 // reset the key state
 func (m *metadata) reset() {
 	m.state = KeyStateNormal
-	m.count.Add(-1)
 }
 
 func (m *metadata) setValue(value ds.Value) {
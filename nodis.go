@@ -3,41 +3,70 @@ package nodis
 import (
 	"errors"
 	"log"
+	"maps"
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/diiyw/nodis/storage"
 
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
 	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/ds/timeseries"
+	"github.com/diiyw/nodis/ds/vector"
 	"github.com/diiyw/nodis/internal/listener"
 	"github.com/diiyw/nodis/patch"
 	"github.com/diiyw/nodis/redis"
+	"github.com/diiyw/nodis/search"
 )
 
 var (
-	ErrUnknownOperation = errors.New("unknown operation")
+	ErrUnknownOperation    = errors.New("unknown operation")
+	ErrSnapshotUnsupported = errors.New("the storage doesn't keep snapshots")
+	ErrStatsUnsupported    = errors.New("the storage doesn't provide statistics")
 )
 
 type Nodis struct {
-	store             *store
-	listeners         []*listener.Listener
-	blockingKeysMutex sync.RWMutex
-	blockingKeys      map[string]*list.LinkedListG[chan string] // blocking keys
-	options           *Options
+	store     *store
+	listeners []*listener.Listener
+	blocking  *blocking // clients blocked on keys
+	indexesMu sync.RWMutex
+	indexes   map[string]*search.Index // full-text indexes
+	options   *Options
+	// bgSaving is set while a background save is running and bgSaveFailed if the last one failed
+	bgSaving     atomic.Bool
+	bgSaveFailed atomic.Bool
+	// lastSave is the unix time in seconds of the last successful save
+	lastSave atomic.Int64
 }
 
 func Open(opt *Options) *Nodis {
 	if opt.Storage == nil {
 		opt.Storage = storage.NewMemory()
 	}
+	if c, ok := opt.Storage.(storage.Compressor); ok && opt.Compression != storage.CodecNone {
+		c.SetCompression(opt.Compression, opt.CompressionThreshold)
+	}
 	n := &Nodis{
-		options:      opt,
-		blockingKeys: make(map[string]*list.LinkedListG[chan string]), // initialize blockingKeys
+		options:  opt,
+		blocking: newBlocking(),
+		indexes:  make(map[string]*search.Index),
+	}
+	n.store = newStore(opt.Storage, opt.ExplodeThreshold)
+	n.store.deleted = n.deleted
+	n.store.listOptions = n.listOptions()
+	limits := ds.DefaultEncodingLimits
+	if opt.EncodingLimits != nil {
+		limits = *opt.EncodingLimits
 	}
-	n.store = newStore(opt.Storage)
+	n.store.encodings = ds.NewEncodings(limits)
+	n.SetMaxMemory(opt.MaxMemory, opt.MaxMemoryPolicy)
+	n.lastSave.Store(time.Now().Unix())
+	n.loadIndexes()
 	go func() {
 		if opt.GCDuration != 0 {
 			for {
@@ -61,13 +90,104 @@ func Open(opt *Options) *Nodis {
 	return n
 }
 
-// Snapshot saves the data to disk
+// Snapshot flushes the changes and saves the data to disk
 func (n *Nodis) Snapshot() error {
+	n.store.flush()
 	return n.store.ss.Snapshot()
 }
 
+// StorageStats returns the statistics of the storage
+func (n *Nodis) StorageStats() (storage.Stats, error) {
+	p, ok := n.store.ss.(storage.StatsProvider)
+	if !ok {
+		return storage.Stats{}, ErrStatsUnsupported
+	}
+	stats, err := p.Stats()
+	if errors.Is(err, storage.ErrUnsupported) {
+		// a wrapped storage without statistics
+		return stats, ErrStatsUnsupported
+	}
+	return stats, err
+}
+
+// CompressionStats returns the codec compressing the entries of the storage and the statistics
+// of the compressed entries, CodecNone if the storage doesn't compress them
+func (n *Nodis) CompressionStats() (storage.Codec, storage.CompressionStats) {
+	c, ok := n.store.ss.(storage.Compressor)
+	if !ok {
+		return storage.CodecNone, storage.CompressionStats{}
+	}
+	return c.Compression(), c.CompressionStats()
+}
+
+// SetEncodingLimits sets the limits from which the hashes, sets and sorted sets leave their
+// compact encoding
+func (n *Nodis) SetEncodingLimits(limits ds.EncodingLimits) {
+	n.store.encodings.SetLimits(limits)
+}
+
+// EncodingLimits returns the limits of the compact encodings
+func (n *Nodis) EncodingLimits() ds.EncodingLimits {
+	return n.store.encodings.Limits()
+}
+
+// Quarantined returns the keys removed since their stored value is corrupted, with the error
+// of their value
+func (n *Nodis) Quarantined() map[string]error {
+	n.store.quarantineMu.Lock()
+	defer n.store.quarantineMu.Unlock()
+	return maps.Clone(n.store.quarantined)
+}
+
+// snapshotManager returns the storage if it keeps its snapshots
+func (n *Nodis) snapshotManager() (storage.SnapshotManager, error) {
+	sm, ok := n.store.ss.(storage.SnapshotManager)
+	if !ok {
+		return nil, ErrSnapshotUnsupported
+	}
+	return sm, nil
+}
+
+// snapshotError returns ErrSnapshotUnsupported if the storage wraps one not keeping snapshots
+func snapshotError(err error) error {
+	if errors.Is(err, storage.ErrUnsupported) {
+		return ErrSnapshotUnsupported
+	}
+	return err
+}
+
+// ListSnapshots returns the snapshots of the storage from the oldest to the latest
+func (n *Nodis) ListSnapshots() ([]storage.SnapshotInfo, error) {
+	sm, err := n.snapshotManager()
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := sm.ListSnapshots()
+	return snapshots, snapshotError(err)
+}
+
+// PruneSnapshots removes the snapshots beyond the retention of the storage, it returns the number of the removed ones
+func (n *Nodis) PruneSnapshots() (int, error) {
+	sm, err := n.snapshotManager()
+	if err != nil {
+		return 0, err
+	}
+	pruned, err := sm.PruneSnapshots()
+	return pruned, snapshotError(err)
+}
+
+// RestoreSnapshot replaces the data by the snapshot the next time the database is opened
+func (n *Nodis) RestoreSnapshot(name string) error {
+	sm, err := n.snapshotManager()
+	if err != nil {
+		return err
+	}
+	return snapshotError(sm.RestoreSnapshot(name))
+}
+
 // Close the store
 func (n *Nodis) Close() error {
+	n.blocking.close()
 	return n.store.close()
 }
 
@@ -77,6 +197,7 @@ func (n *Nodis) Clear() {
 	if err != nil {
 		log.Println("Clear: ", err)
 	}
+	n.clearIndexes()
 }
 
 func (n *Nodis) notify(f func() []patch.Op) {
@@ -121,7 +242,7 @@ func (n *Nodis) applyPatch(p patch.Op) error {
 	case *patch.OpDel:
 		n.Del(op.Key)
 	case *patch.OpExpire:
-		n.Expire(op.Key, op.Expiration)
+		n.ExpireAt(op.Key, time.UnixMilli(op.Expiration))
 	case *patch.OpExpireAt:
 		n.ExpireAt(op.Key, time.Unix(op.Expiration, 0))
 	case *patch.OpHClear:
@@ -136,12 +257,18 @@ func (n *Nodis) applyPatch(p patch.Op) error {
 		return err
 	case *patch.OpHSet:
 		n.HSet(op.Key, op.Field, op.Value)
+	case *patch.OpHExpireAt:
+		n.HExpireAt(op.Key, time.UnixMilli(op.Expiration), hash.ExpireAlways, op.Fields...)
+	case *patch.OpHPersist:
+		n.HPersist(op.Key, op.Fields...)
 	case *patch.OpLInsert:
 		n.LInsert(op.Key, op.Pivot, op.Value, op.Before)
 	case *patch.OpLPop:
 		n.LPop(op.Key, op.Count)
 	case *patch.OpLPopRPush:
 		n.LPopRPush(op.Key, op.DstKey)
+	case *patch.OpLMove:
+		n.LMove(op.Key, op.DstKey, list.Direction(op.From), list.Direction(op.To))
 	case *patch.OpLPush:
 		n.LPush(op.Key, op.Values...)
 	case *patch.OpLPushX:
@@ -165,7 +292,9 @@ func (n *Nodis) applyPatch(p patch.Op) error {
 	case *patch.OpSRem:
 		n.SRem(op.Key, op.Members...)
 	case *patch.OpSet:
-		n.Set(op.Key, op.Value, op.KeepTTL)
+		n.SetWithOptions(op.Key, op.Value, &SetOptions{KeepTTL: op.KeepTTL, ExpireAt: op.Expiration})
+	case *patch.OpPersist:
+		n.Persist(op.Key)
 	case *patch.OpZAdd:
 		n.ZAdd(op.Key, op.Member, op.Score)
 	case *patch.OpZClear:
@@ -173,13 +302,59 @@ func (n *Nodis) applyPatch(p patch.Op) error {
 	case *patch.OpZIncrBy:
 		n.ZIncrBy(op.Key, op.Member, op.Score)
 	case *patch.OpZRem:
-		n.ZRem(op.Key, op.Member)
+		n.ZRem(op.Key, op.Members...)
 	case *patch.OpZRemRangeByRank:
 		n.ZRemRangeByRank(op.Key, op.Start, op.Stop)
 	case *patch.OpZRemRangeByScore:
 		n.ZRemRangeByScore(op.Key, op.Min, op.Max, int(op.Mode))
+	case *patch.OpZUnionStore:
+		n.ZUnionStore(op.Key, op.Keys, op.Weights, op.Aggregate)
+	case *patch.OpZInterStore:
+		n.ZInterStore(op.Key, op.Keys, op.Weights, op.Aggregate)
+	case *patch.OpZDiffStore:
+		n.ZDiffStore(op.Key, op.Keys)
+	case *patch.OpZRangeStore:
+		opts := &ZRangeStoreOptions{ByScore: op.ByScore, ByLex: op.ByLex, Rev: op.Rev, Limit: true, Offset: op.Offset, Count: op.Count}
+		_, err := n.ZRangeStore(op.Key, op.Src, op.Start, op.Stop, opts)
+		return err
+	case *patch.OpZRemRangeByLex:
+		_, err := n.ZRemRangeByLex(op.Key, op.Min, op.Max)
+		return err
 	case *patch.OpRename:
 		return n.Rename(op.Key, op.DstKey)
+	case *patch.OpCopy:
+		n.Copy(op.Key, op.DstKey, op.Replace)
+	case *patch.OpRestore:
+		return n.Restore(op.Key, op.Payload, &RestoreOptions{Replace: true, ExpireAt: op.Expiration})
+	case *patch.OpTSCreate:
+		opts := &TSOptions{Retention: op.Retention, DuplicatePolicy: timeseries.DuplicatePolicy(op.DuplicatePolicy)}
+		for i := 0; i+1 < len(op.Labels); i += 2 {
+			opts.Labels = append(opts.Labels, timeseries.Label{Name: op.Labels[i], Value: op.Labels[i+1]})
+		}
+		return n.TSCreate(op.Key, opts)
+	case *patch.OpTSAdd:
+		_, err := n.TSAdd(op.Key, op.Timestamp, op.Value, nil, timeseries.DuplicatePolicy(op.OnDuplicate))
+		return err
+	case *patch.OpTSIncrBy:
+		_, err := n.TSIncrBy(op.Key, op.Timestamp, op.Value, nil)
+		return err
+	case *patch.OpTSCreateRule:
+		return n.TSCreateRule(op.Key, op.DstKey, timeseries.Aggregation{Type: timeseries.Aggregator(op.Aggregation), BucketDuration: op.BucketDuration})
+	case *patch.OpTSDeleteRule:
+		return n.TSDeleteRule(op.Key, op.DstKey)
+	case *patch.OpVAdd:
+		opts := &VAddOptions{Options: vector.Options{
+			Quantization: vector.Quantization(op.Quantization),
+			Metric:       vector.Metric(op.Metric),
+			M:            int(op.M),
+			EF:           int(op.EF),
+		}}
+		_, err := n.VAdd(op.Key, op.Element, op.Vector, opts)
+		return err
+	case *patch.OpVRem:
+		n.VRem(op.Key, op.Element)
+	case *patch.OpVSetAttr:
+		n.VSetAttr(op.Key, op.Element, op.Attr)
 	default:
 		return ErrUnknownOperation
 	}
@@ -221,7 +396,11 @@ func (n *Nodis) Serve(addr string) error {
 		os.Exit(0)
 	}()
 	return redis.Serve(addr, func(conn *redis.Conn, cmd redis.Command) {
-		GetCommand(cmd.Name)(n, conn, cmd)
+		if n.denyOOM(cmd.Name) {
+			conn.WriteError(ErrOOM.Error())
+		} else {
+			GetCommand(cmd.Name)(n, conn, cmd)
+		}
 		if conn.HasError() && conn.State != 0 {
 			conn.State |= redis.MultiError
 		}
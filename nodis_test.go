@@ -1,12 +1,20 @@
 package nodis
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/diiyw/nodis/ds"
 	"github.com/diiyw/nodis/patch"
+	"github.com/diiyw/nodis/redis"
+	"github.com/diiyw/nodis/storage"
 )
 
 func TestNodis_Open(t *testing.T) {
@@ -235,3 +243,191 @@ func TestNodis_OpenAndClose(t *testing.T) {
 		t.Errorf("LPop() = %s, want %v", v, "lpush")
 	}
 }
+
+func TestNodis_RestoreSnapshot(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	if _, err := Open(&Options{}).ListSnapshots(); err != ErrSnapshotUnsupported {
+		t.Errorf("ListSnapshots() error = %v, want %v", err, ErrSnapshotUnsupported)
+	}
+	opt := &Options{Storage: storage.NewPebble(filepath.Join(t.TempDir(), "data"), nil)}
+	n := Open(opt)
+	n.Set("key", []byte("snapshot"), false)
+	if err := n.Snapshot(); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	snapshots, err := n.ListSnapshots()
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("ListSnapshots() = %v, %v", snapshots, err)
+	}
+	n.Set("key", []byte("changed"), false)
+	if err = n.RestoreSnapshot(snapshots[0].Name); err != nil {
+		t.Fatalf("RestoreSnapshot() error = %v", err)
+	}
+	_ = n.Close()
+	n = Open(opt)
+	defer n.Close()
+	if v := n.Get("key"); string(v) != "snapshot" {
+		t.Errorf("Get() = %s, want snapshot", v)
+	}
+}
+
+func TestNodis_MemoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	n := Open(&Options{Storage: storage.NewMemoryWithFile(path)})
+	n.Set("key", []byte("v"), false)
+	n.HSet("hash", "f", []byte("v"))
+	_ = n.Close()
+	n = Open(&Options{Storage: storage.NewMemoryWithFile(path)})
+	if v := n.Get("key"); string(v) != "v" {
+		t.Errorf("Get() = %s, want v", v)
+	}
+	if v := n.HGet("hash", "f"); string(v) != "v" {
+		t.Errorf("HGet() = %s, want v", v)
+	}
+}
+
+// batchMemory counts the writes of the memory storage
+type batchMemory struct {
+	*storage.Memory
+	sets, batches int
+}
+
+func (m *batchMemory) Set(key *ds.Key, value ds.Value) error {
+	m.sets++
+	return m.Memory.Set(key, value)
+}
+
+func (m *batchMemory) WriteBatch(b *storage.Batch) error {
+	m.batches++
+	return m.Memory.WriteBatch(b)
+}
+
+func TestNodis_FlushBatch(t *testing.T) {
+	ss := &batchMemory{Memory: storage.NewMemory()}
+	n := Open(&Options{Storage: ss})
+	defer n.Close()
+	for i := 0; i < 10; i++ {
+		n.Set("key"+strconv.Itoa(i), []byte("v"), false)
+	}
+	if err := n.Snapshot(); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	if ss.sets != 0 || ss.batches != 1 {
+		t.Errorf("Snapshot() = %d sets and %d batches, want a single batch", ss.sets, ss.batches)
+	}
+	stats, err := n.StorageStats()
+	if err != nil || stats.Keys != 10 {
+		t.Errorf("StorageStats() = %+v, %v, want 10 keys", stats, err)
+	}
+	w := redis.NewWriter(&bytes.Buffer{})
+	GetCommand("INFO")(n, &redis.Conn{Writer: w}, redis.Command{Name: "INFO"})
+	if !strings.Contains(string(w.Bytes()), "storage_keys:10\r\n") {
+		t.Errorf("INFO = %s, want the storage keys", w.Bytes())
+	}
+
+	n.store.gc()
+	n.store.gc()
+	if ss.sets != 0 || ss.batches != 2 {
+		t.Errorf("gc() = %d sets and %d batches, want no write of the saved keys", ss.sets, ss.batches)
+	}
+	n.Set("key0", []byte("v2"), false)
+	n.store.gc()
+	if ss.sets != 0 || ss.batches != 3 {
+		t.Errorf("gc() = %d sets and %d batches, want a single batch", ss.sets, ss.batches)
+	}
+}
+
+func TestNodis_StorageStatsUnsupported(t *testing.T) {
+	n := Open(&Options{Storage: storage.NewDirectory(t.TempDir())})
+	defer n.Close()
+	if _, err := n.StorageStats(); err != ErrStatsUnsupported {
+		t.Errorf("StorageStats() error = %v, want %v", err, ErrStatsUnsupported)
+	}
+
+	// the encrypted storage forwards the statistics of the storage it wraps
+	encrypted := storage.Encrypted(storage.NewDirectory(t.TempDir()), storage.NewKeyRing(1, bytes.Repeat([]byte{1}, 32)))
+	n = Open(&Options{Storage: encrypted})
+	defer n.Close()
+	if _, err := n.StorageStats(); err != ErrStatsUnsupported {
+		t.Errorf("StorageStats() error = %v, want %v", err, ErrStatsUnsupported)
+	}
+	if _, err := n.ListSnapshots(); err != ErrSnapshotUnsupported {
+		t.Errorf("ListSnapshots() error = %v, want %v", err, ErrSnapshotUnsupported)
+	}
+}
+
+func TestNodis_Compression(t *testing.T) {
+	ss := storage.NewMemory()
+	n := Open(&Options{Storage: ss, Compression: storage.CodecZstd, CompressionThreshold: 64})
+	// the compression of another instance is its own
+	other := Open(&Options{Storage: storage.NewMemory(), Compression: storage.CodecSnappy})
+	_ = other.Close()
+	value := strings.Repeat("nodis", 100)
+	n.Set("key", []byte(value), false)
+	_ = n.Close()
+	if stats, _ := ss.Stats(); stats.Bytes >= int64(len(value)) {
+		t.Errorf("Stats() = %+v, want the value compressed", stats)
+	}
+	n = Open(&Options{Storage: ss})
+	defer n.Close()
+	if v := n.Get("key"); string(v) != value {
+		t.Errorf("Get() = %d bytes, want the value", len(v))
+	}
+	if codec, stats := other.CompressionStats(); codec != storage.CodecSnappy || stats.Entries != 0 {
+		t.Errorf("CompressionStats() = %s %+v, want no entry compressed by the other instance", codec, stats)
+	}
+	w := redis.NewWriter(&bytes.Buffer{})
+	GetCommand("INFO")(n, &redis.Conn{Writer: w}, redis.Command{Name: "INFO"})
+	if !strings.Contains(string(w.Bytes()), "storage_compression:zstd\r\n") ||
+		!strings.Contains(string(w.Bytes()), "storage_compressed_entries:1\r\n") {
+		t.Errorf("INFO = %s, want the compression", w.Bytes())
+	}
+}
+
+// corruptedMemory fails to read the values of the corrupted keys
+type corruptedMemory struct {
+	*storage.Memory
+	corrupted map[string]bool
+}
+
+func (m *corruptedMemory) Get(key *ds.Key) (ds.Value, error) {
+	if m.corrupted[key.Name] {
+		return nil, fmt.Errorf("%w: entry checksum mismatch", storage.ErrCorruptedData)
+	}
+	return m.Memory.Get(key)
+}
+
+func TestNodis_Quarantine(t *testing.T) {
+	ss := &corruptedMemory{Memory: storage.NewMemory(), corrupted: map[string]bool{"a": true, "b": true}}
+	n := Open(&Options{Storage: ss})
+	defer n.Close()
+	n.Set("a", []byte("v"), false)
+	n.Set("b", []byte("v"), false)
+	n.Set("c", []byte("v"), false)
+	_ = n.Snapshot()
+	for _, m := range n.store.metadata {
+		m.removeFromMemory()
+	}
+	if v := n.Get("a"); v != nil {
+		t.Errorf("Get() = %s, want nil", v)
+	}
+	if n.Exists("a") != 0 {
+		t.Errorf("Exists() = 1, want the key quarantined")
+	}
+	// written again
+	if v, err := n.Incr("b"); err != nil || v != 1 {
+		t.Errorf("Incr() = %d, %v, want 1", v, err)
+	}
+	if v := n.Get("c"); string(v) != "v" {
+		t.Errorf("Get() = %s, want v", v)
+	}
+	quarantined := n.Quarantined()
+	if len(quarantined) != 2 || !errors.Is(quarantined["a"], storage.ErrCorruptedData) {
+		t.Errorf("Quarantined() = %v, want a and b", quarantined)
+	}
+	w := redis.NewWriter(&bytes.Buffer{})
+	GetCommand("INFO")(n, &redis.Conn{Writer: w}, redis.Command{Name: "INFO"})
+	if !strings.Contains(string(w.Bytes()), "storage_quarantined_keys:2\r\n") {
+		t.Errorf("INFO = %s, want the quarantined keys", w.Bytes())
+	}
+}
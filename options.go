@@ -1,6 +1,7 @@
 package nodis
 
 import (
+	"github.com/diiyw/nodis/ds"
 	"github.com/diiyw/nodis/storage"
 	"time"
 )
@@ -27,6 +28,41 @@ type Options struct {
 
 	// Channel is the Pub/Sub channel.
 	Channel Channel
+
+	// ListChunkSize is the max number of elements of a list chunk. Default 0 for list.DefaultChunkSize.
+	ListChunkSize int
+
+	// ListCompressDepth is the number of chunks at each end of a list that are never compressed,
+	// the interior chunks are compressed. Default 0 for disabling compression.
+	ListCompressDepth int
+
+	// EncodingLimits are the limits from which the hashes, sets and sorted sets leave their compact
+	// encoding, they can be changed by CONFIG SET. Default nil for ds.DefaultEncodingLimits.
+	EncodingLimits *ds.EncodingLimits
+
+	// DumpFile is the path of the RDB file written by Save. Default "dump.rdb".
+	DumpFile string
+
+	// Compression is the codec compressing the values written to the storage if it's a
+	// storage.Compressor. Default storage.CodecNone.
+	Compression storage.Codec
+
+	// CompressionThreshold is the size from which the values are compressed.
+	// Default 0 for storage.DefaultCompressionThreshold.
+	CompressionThreshold int
+
+	// ExplodeThreshold is the number of fields from which the hashes, sets and sorted sets are
+	// stored as a record per field, HGET, SISMEMBER and ZSCORE read then only their fields when
+	// the value isn't in memory. Default 0 for storing every value as a single record.
+	ExplodeThreshold int
+
+	// MaxMemory is the limit in bytes of the estimated memory used by the values, the values
+	// are evicted by MaxMemoryPolicy when it's exceeded. Default 0 for no limit.
+	MaxMemory int64
+
+	// MaxMemoryPolicy chooses the values evicted when MaxMemory is exceeded, they are offloaded
+	// to the storage or deleted if the storage is in memory and not persisted. Default NoEviction.
+	MaxMemoryPolicy EvictionPolicy
 }
 
 var DefaultOptions = &Options{
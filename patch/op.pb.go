@@ -1937,7 +1937,7 @@ type OpZUnionStore struct {
 	unknownFields protoimpl.UnknownFields
 
 	Key       string    `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
-	Keys      []string  `protobuf:"bytes,2,rep,name=ScanKeys,proto3" json:"ScanKeys,omitempty"`
+	Keys      []string  `protobuf:"bytes,2,rep,name=Keys,proto3" json:"Keys,omitempty"`
 	Weights   []float64 `protobuf:"fixed64,3,rep,packed,name=Weights,proto3" json:"Weights,omitempty"`
 	Aggregate string    `protobuf:"bytes,4,opt,name=Aggregate,proto3" json:"Aggregate,omitempty"`
 }
@@ -2008,7 +2008,7 @@ type OpZInterStore struct {
 	unknownFields protoimpl.UnknownFields
 
 	Key       string    `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
-	Keys      []string  `protobuf:"bytes,2,rep,name=ScanKeys,proto3" json:"ScanKeys,omitempty"`
+	Keys      []string  `protobuf:"bytes,2,rep,name=Keys,proto3" json:"Keys,omitempty"`
 	Weights   []float64 `protobuf:"fixed64,3,rep,packed,name=Weights,proto3" json:"Weights,omitempty"`
 	Aggregate string    `protobuf:"bytes,4,opt,name=Aggregate,proto3" json:"Aggregate,omitempty"`
 }
@@ -2128,6 +2128,1094 @@ func (x *OpRenameNX) GetDstKey() string {
 	return ""
 }
 
+type OpTSCreate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key             string   `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Retention       int64    `protobuf:"varint,2,opt,name=Retention,proto3" json:"Retention,omitempty"`
+	DuplicatePolicy int64    `protobuf:"varint,3,opt,name=DuplicatePolicy,proto3" json:"DuplicatePolicy,omitempty"`
+	Labels          []string `protobuf:"bytes,4,rep,name=Labels,proto3" json:"Labels,omitempty"`
+}
+
+func (x *OpTSCreate) Reset() {
+	*x = OpTSCreate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[36]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpTSCreate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpTSCreate) ProtoMessage() {}
+
+func (x *OpTSCreate) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[36]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpTSCreate.ProtoReflect.Descriptor instead.
+func (*OpTSCreate) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *OpTSCreate) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpTSCreate) GetRetention() int64 {
+	if x != nil {
+		return x.Retention
+	}
+	return 0
+}
+
+func (x *OpTSCreate) GetDuplicatePolicy() int64 {
+	if x != nil {
+		return x.DuplicatePolicy
+	}
+	return 0
+}
+
+func (x *OpTSCreate) GetLabels() []string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type OpTSAdd struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key         string  `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Timestamp   int64   `protobuf:"varint,2,opt,name=Timestamp,proto3" json:"Timestamp,omitempty"`
+	Value       float64 `protobuf:"fixed64,3,opt,name=Value,proto3" json:"Value,omitempty"`
+	OnDuplicate int64   `protobuf:"varint,4,opt,name=OnDuplicate,proto3" json:"OnDuplicate,omitempty"`
+}
+
+func (x *OpTSAdd) Reset() {
+	*x = OpTSAdd{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[37]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpTSAdd) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpTSAdd) ProtoMessage() {}
+
+func (x *OpTSAdd) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[37]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpTSAdd.ProtoReflect.Descriptor instead.
+func (*OpTSAdd) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *OpTSAdd) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpTSAdd) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *OpTSAdd) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *OpTSAdd) GetOnDuplicate() int64 {
+	if x != nil {
+		return x.OnDuplicate
+	}
+	return 0
+}
+
+type OpTSIncrBy struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key       string  `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Timestamp int64   `protobuf:"varint,2,opt,name=Timestamp,proto3" json:"Timestamp,omitempty"`
+	Value     float64 `protobuf:"fixed64,3,opt,name=Value,proto3" json:"Value,omitempty"`
+}
+
+func (x *OpTSIncrBy) Reset() {
+	*x = OpTSIncrBy{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[38]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpTSIncrBy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpTSIncrBy) ProtoMessage() {}
+
+func (x *OpTSIncrBy) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[38]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpTSIncrBy.ProtoReflect.Descriptor instead.
+func (*OpTSIncrBy) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *OpTSIncrBy) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpTSIncrBy) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *OpTSIncrBy) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+type OpTSCreateRule struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key            string `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	DstKey         string `protobuf:"bytes,2,opt,name=DstKey,proto3" json:"DstKey,omitempty"`
+	Aggregation    int64  `protobuf:"varint,3,opt,name=Aggregation,proto3" json:"Aggregation,omitempty"`
+	BucketDuration int64  `protobuf:"varint,4,opt,name=BucketDuration,proto3" json:"BucketDuration,omitempty"`
+}
+
+func (x *OpTSCreateRule) Reset() {
+	*x = OpTSCreateRule{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[39]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpTSCreateRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpTSCreateRule) ProtoMessage() {}
+
+func (x *OpTSCreateRule) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[39]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpTSCreateRule.ProtoReflect.Descriptor instead.
+func (*OpTSCreateRule) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *OpTSCreateRule) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpTSCreateRule) GetDstKey() string {
+	if x != nil {
+		return x.DstKey
+	}
+	return ""
+}
+
+func (x *OpTSCreateRule) GetAggregation() int64 {
+	if x != nil {
+		return x.Aggregation
+	}
+	return 0
+}
+
+func (x *OpTSCreateRule) GetBucketDuration() int64 {
+	if x != nil {
+		return x.BucketDuration
+	}
+	return 0
+}
+
+type OpTSDeleteRule struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key    string `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	DstKey string `protobuf:"bytes,2,opt,name=DstKey,proto3" json:"DstKey,omitempty"`
+}
+
+func (x *OpTSDeleteRule) Reset() {
+	*x = OpTSDeleteRule{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[40]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpTSDeleteRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpTSDeleteRule) ProtoMessage() {}
+
+func (x *OpTSDeleteRule) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[40]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpTSDeleteRule.ProtoReflect.Descriptor instead.
+func (*OpTSDeleteRule) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *OpTSDeleteRule) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpTSDeleteRule) GetDstKey() string {
+	if x != nil {
+		return x.DstKey
+	}
+	return ""
+}
+
+type OpVAdd struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key          string    `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Element      string    `protobuf:"bytes,2,opt,name=Element,proto3" json:"Element,omitempty"`
+	Vector       []float32 `protobuf:"fixed32,3,rep,packed,name=Vector,proto3" json:"Vector,omitempty"`
+	Quantization int64     `protobuf:"varint,4,opt,name=Quantization,proto3" json:"Quantization,omitempty"`
+	Metric       int64     `protobuf:"varint,5,opt,name=Metric,proto3" json:"Metric,omitempty"`
+	M            int64     `protobuf:"varint,6,opt,name=M,proto3" json:"M,omitempty"`
+	EF           int64     `protobuf:"varint,7,opt,name=EF,proto3" json:"EF,omitempty"`
+}
+
+func (x *OpVAdd) Reset() {
+	*x = OpVAdd{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[41]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpVAdd) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpVAdd) ProtoMessage() {}
+
+func (x *OpVAdd) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[41]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpVAdd.ProtoReflect.Descriptor instead.
+func (*OpVAdd) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *OpVAdd) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpVAdd) GetElement() string {
+	if x != nil {
+		return x.Element
+	}
+	return ""
+}
+
+func (x *OpVAdd) GetVector() []float32 {
+	if x != nil {
+		return x.Vector
+	}
+	return nil
+}
+
+func (x *OpVAdd) GetQuantization() int64 {
+	if x != nil {
+		return x.Quantization
+	}
+	return 0
+}
+
+func (x *OpVAdd) GetMetric() int64 {
+	if x != nil {
+		return x.Metric
+	}
+	return 0
+}
+
+func (x *OpVAdd) GetM() int64 {
+	if x != nil {
+		return x.M
+	}
+	return 0
+}
+
+func (x *OpVAdd) GetEF() int64 {
+	if x != nil {
+		return x.EF
+	}
+	return 0
+}
+
+type OpVRem struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key     string `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Element string `protobuf:"bytes,2,opt,name=Element,proto3" json:"Element,omitempty"`
+}
+
+func (x *OpVRem) Reset() {
+	*x = OpVRem{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[42]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpVRem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpVRem) ProtoMessage() {}
+
+func (x *OpVRem) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[42]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpVRem.ProtoReflect.Descriptor instead.
+func (*OpVRem) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *OpVRem) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpVRem) GetElement() string {
+	if x != nil {
+		return x.Element
+	}
+	return ""
+}
+
+type OpVSetAttr struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key     string `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Element string `protobuf:"bytes,2,opt,name=Element,proto3" json:"Element,omitempty"`
+	Attr    string `protobuf:"bytes,3,opt,name=Attr,proto3" json:"Attr,omitempty"`
+}
+
+func (x *OpVSetAttr) Reset() {
+	*x = OpVSetAttr{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[43]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpVSetAttr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpVSetAttr) ProtoMessage() {}
+
+func (x *OpVSetAttr) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[43]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpVSetAttr.ProtoReflect.Descriptor instead.
+func (*OpVSetAttr) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *OpVSetAttr) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpVSetAttr) GetElement() string {
+	if x != nil {
+		return x.Element
+	}
+	return ""
+}
+
+func (x *OpVSetAttr) GetAttr() string {
+	if x != nil {
+		return x.Attr
+	}
+	return ""
+}
+
+type OpHExpireAt struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key        string   `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Fields     []string `protobuf:"bytes,2,rep,name=Fields,proto3" json:"Fields,omitempty"`
+	Expiration int64    `protobuf:"varint,3,opt,name=Expiration,proto3" json:"Expiration,omitempty"`
+}
+
+func (x *OpHExpireAt) Reset() {
+	*x = OpHExpireAt{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[44]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpHExpireAt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpHExpireAt) ProtoMessage() {}
+
+func (x *OpHExpireAt) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[44]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpHExpireAt.ProtoReflect.Descriptor instead.
+func (*OpHExpireAt) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *OpHExpireAt) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpHExpireAt) GetFields() []string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *OpHExpireAt) GetExpiration() int64 {
+	if x != nil {
+		return x.Expiration
+	}
+	return 0
+}
+
+type OpHPersist struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key    string   `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Fields []string `protobuf:"bytes,2,rep,name=Fields,proto3" json:"Fields,omitempty"`
+}
+
+func (x *OpHPersist) Reset() {
+	*x = OpHPersist{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[45]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpHPersist) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpHPersist) ProtoMessage() {}
+
+func (x *OpHPersist) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[45]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpHPersist.ProtoReflect.Descriptor instead.
+func (*OpHPersist) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *OpHPersist) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpHPersist) GetFields() []string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+type OpZRemRangeByLex struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key string `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Min string `protobuf:"bytes,2,opt,name=Min,proto3" json:"Min,omitempty"`
+	Max string `protobuf:"bytes,3,opt,name=Max,proto3" json:"Max,omitempty"`
+}
+
+func (x *OpZRemRangeByLex) Reset() {
+	*x = OpZRemRangeByLex{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[46]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpZRemRangeByLex) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpZRemRangeByLex) ProtoMessage() {}
+
+func (x *OpZRemRangeByLex) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[46]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpZRemRangeByLex.ProtoReflect.Descriptor instead.
+func (*OpZRemRangeByLex) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *OpZRemRangeByLex) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpZRemRangeByLex) GetMin() string {
+	if x != nil {
+		return x.Min
+	}
+	return ""
+}
+
+func (x *OpZRemRangeByLex) GetMax() string {
+	if x != nil {
+		return x.Max
+	}
+	return ""
+}
+
+type OpZDiffStore struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key  string   `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Keys []string `protobuf:"bytes,2,rep,name=Keys,proto3" json:"Keys,omitempty"`
+}
+
+func (x *OpZDiffStore) Reset() {
+	*x = OpZDiffStore{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[47]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpZDiffStore) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpZDiffStore) ProtoMessage() {}
+
+func (x *OpZDiffStore) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[47]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpZDiffStore.ProtoReflect.Descriptor instead.
+func (*OpZDiffStore) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *OpZDiffStore) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpZDiffStore) GetKeys() []string {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+type OpZRangeStore struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key     string `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Src     string `protobuf:"bytes,2,opt,name=Src,proto3" json:"Src,omitempty"`
+	Start   string `protobuf:"bytes,3,opt,name=Start,proto3" json:"Start,omitempty"`
+	Stop    string `protobuf:"bytes,4,opt,name=Stop,proto3" json:"Stop,omitempty"`
+	ByScore bool   `protobuf:"varint,5,opt,name=ByScore,proto3" json:"ByScore,omitempty"`
+	ByLex   bool   `protobuf:"varint,6,opt,name=ByLex,proto3" json:"ByLex,omitempty"`
+	Rev     bool   `protobuf:"varint,7,opt,name=Rev,proto3" json:"Rev,omitempty"`
+	Offset  int64  `protobuf:"varint,8,opt,name=Offset,proto3" json:"Offset,omitempty"`
+	Count   int64  `protobuf:"varint,9,opt,name=Count,proto3" json:"Count,omitempty"`
+}
+
+func (x *OpZRangeStore) Reset() {
+	*x = OpZRangeStore{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[48]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpZRangeStore) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpZRangeStore) ProtoMessage() {}
+
+func (x *OpZRangeStore) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[48]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpZRangeStore.ProtoReflect.Descriptor instead.
+func (*OpZRangeStore) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *OpZRangeStore) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpZRangeStore) GetSrc() string {
+	if x != nil {
+		return x.Src
+	}
+	return ""
+}
+
+func (x *OpZRangeStore) GetStart() string {
+	if x != nil {
+		return x.Start
+	}
+	return ""
+}
+
+func (x *OpZRangeStore) GetStop() string {
+	if x != nil {
+		return x.Stop
+	}
+	return ""
+}
+
+func (x *OpZRangeStore) GetByScore() bool {
+	if x != nil {
+		return x.ByScore
+	}
+	return false
+}
+
+func (x *OpZRangeStore) GetByLex() bool {
+	if x != nil {
+		return x.ByLex
+	}
+	return false
+}
+
+func (x *OpZRangeStore) GetRev() bool {
+	if x != nil {
+		return x.Rev
+	}
+	return false
+}
+
+func (x *OpZRangeStore) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *OpZRangeStore) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type OpLMove struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key    string `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	DstKey string `protobuf:"bytes,2,opt,name=DstKey,proto3" json:"DstKey,omitempty"`
+	From   uint32 `protobuf:"varint,3,opt,name=From,proto3" json:"From,omitempty"`
+	To     uint32 `protobuf:"varint,4,opt,name=To,proto3" json:"To,omitempty"`
+}
+
+func (x *OpLMove) Reset() {
+	*x = OpLMove{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[49]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpLMove) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpLMove) ProtoMessage() {}
+
+func (x *OpLMove) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[49]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpLMove.ProtoReflect.Descriptor instead.
+func (*OpLMove) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *OpLMove) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpLMove) GetDstKey() string {
+	if x != nil {
+		return x.DstKey
+	}
+	return ""
+}
+
+func (x *OpLMove) GetFrom() uint32 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *OpLMove) GetTo() uint32 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+type OpCopy struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key     string `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	DstKey  string `protobuf:"bytes,2,opt,name=DstKey,proto3" json:"DstKey,omitempty"`
+	Replace bool   `protobuf:"varint,3,opt,name=Replace,proto3" json:"Replace,omitempty"`
+}
+
+func (x *OpCopy) Reset() {
+	*x = OpCopy{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[50]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpCopy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpCopy) ProtoMessage() {}
+
+func (x *OpCopy) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[50]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpCopy.ProtoReflect.Descriptor instead.
+func (*OpCopy) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *OpCopy) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpCopy) GetDstKey() string {
+	if x != nil {
+		return x.DstKey
+	}
+	return ""
+}
+
+func (x *OpCopy) GetReplace() bool {
+	if x != nil {
+		return x.Replace
+	}
+	return false
+}
+
+type OpRestore struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key        string `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Payload    []byte `protobuf:"bytes,2,opt,name=Payload,proto3" json:"Payload,omitempty"`
+	Expiration int64  `protobuf:"varint,3,opt,name=Expiration,proto3" json:"Expiration,omitempty"`
+}
+
+func (x *OpRestore) Reset() {
+	*x = OpRestore{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_op_proto_msgTypes[51]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpRestore) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpRestore) ProtoMessage() {}
+
+func (x *OpRestore) ProtoReflect() protoreflect.Message {
+	mi := &file_op_proto_msgTypes[51]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpRestore.ProtoReflect.Descriptor instead.
+func (*OpRestore) Descriptor() ([]byte, []int) {
+	return file_op_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *OpRestore) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OpRestore) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *OpRestore) GetExpiration() int64 {
+	if x != nil {
+		return x.Expiration
+	}
+	return 0
+}
+
 var File_op_proto protoreflect.FileDescriptor
 
 var file_op_proto_rawDesc = []byte{
@@ -2277,9 +3365,106 @@ var file_op_proto_rawDesc = []byte{
 	0x65, 0x67, 0x61, 0x74, 0x65, 0x22, 0x36, 0x0a, 0x0a, 0x4f, 0x70, 0x52, 0x65, 0x6e, 0x61, 0x6d,
 	0x65, 0x4e, 0x58, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
 	0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x44, 0x73, 0x74, 0x4b, 0x65, 0x79, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x44, 0x73, 0x74, 0x4b, 0x65, 0x79, 0x42, 0x0a, 0x5a,
-	0x08, 0x2e, 0x2e, 0x2f, 0x70, 0x61, 0x74, 0x63, 0x68, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x33,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x44, 0x73, 0x74, 0x4b, 0x65, 0x79, 0x22, 0x7e, 0x0a,
+	0x0a, 0x4f, 0x70, 0x54, 0x53, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x4b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x1c, 0x0a,
+	0x09, 0x52, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x09, 0x52, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x28, 0x0a, 0x0f, 0x44,
+	0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x44, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x50,
+	0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x18,
+	0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x22, 0x71, 0x0a,
+	0x07, 0x4f, 0x70, 0x54, 0x53, 0x41, 0x64, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x54, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x54,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x14, 0x0a, 0x05, 0x56, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x20,
+	0x0a, 0x0b, 0x4f, 0x6e, 0x44, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x0b, 0x4f, 0x6e, 0x44, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65,
+	0x22, 0x52, 0x0a, 0x0a, 0x4f, 0x70, 0x54, 0x53, 0x49, 0x6e, 0x63, 0x72, 0x42, 0x79, 0x12, 0x10,
+	0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79,
+	0x12, 0x1c, 0x0a, 0x09, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x09, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x14,
+	0x0a, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x56,
+	0x61, 0x6c, 0x75, 0x65, 0x22, 0x84, 0x01, 0x0a, 0x0e, 0x4f, 0x70, 0x54, 0x53, 0x43, 0x72, 0x65,
+	0x61, 0x74, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x44, 0x73, 0x74,
+	0x4b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x44, 0x73, 0x74, 0x4b, 0x65,
+	0x79, 0x12, 0x20, 0x0a, 0x0b, 0x41, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x41, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x26, 0x0a, 0x0e, 0x42, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x44, 0x75, 0x72,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e, 0x42, 0x75, 0x63,
+	0x6b, 0x65, 0x74, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x3a, 0x0a, 0x0e, 0x4f,
+	0x70, 0x54, 0x53, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x12, 0x10, 0x0a,
+	0x03, 0x4b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12,
+	0x16, 0x0a, 0x06, 0x44, 0x73, 0x74, 0x4b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x44, 0x73, 0x74, 0x4b, 0x65, 0x79, 0x22, 0xa6, 0x01, 0x0a, 0x06, 0x4f, 0x70, 0x56, 0x41,
+	0x64, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x4b, 0x65, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x45, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x45, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x16,
+	0x0a, 0x06, 0x56, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x03, 0x20, 0x03, 0x28, 0x02, 0x52, 0x06,
+	0x56, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x12, 0x22, 0x0a, 0x0c, 0x51, 0x75, 0x61, 0x6e, 0x74, 0x69,
+	0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x51, 0x75,
+	0x61, 0x6e, 0x74, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x4d, 0x65,
+	0x74, 0x72, 0x69, 0x63, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x4d, 0x65, 0x74, 0x72,
+	0x69, 0x63, 0x12, 0x0c, 0x0a, 0x01, 0x4d, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x01, 0x4d,
+	0x12, 0x0e, 0x0a, 0x02, 0x45, 0x46, 0x18, 0x07, 0x20, 0x01, 0x28, 0x03, 0x52, 0x02, 0x45, 0x46,
+	0x22, 0x34, 0x0a, 0x06, 0x4f, 0x70, 0x56, 0x52, 0x65, 0x6d, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65,
+	0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x18, 0x0a, 0x07,
+	0x45, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x45,
+	0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x22, 0x4c, 0x0a, 0x0a, 0x4f, 0x70, 0x56, 0x53, 0x65, 0x74,
+	0x41, 0x74, 0x74, 0x72, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x45, 0x6c, 0x65, 0x6d, 0x65, 0x6e,
+	0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x45, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74,
+	0x12, 0x12, 0x0a, 0x04, 0x41, 0x74, 0x74, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x41, 0x74, 0x74, 0x72, 0x22, 0x57, 0x0a, 0x0b, 0x4f, 0x70, 0x48, 0x45, 0x78, 0x70, 0x69, 0x72,
+	0x65, 0x41, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x18,
+	0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x12, 0x1e, 0x0a,
+	0x0a, 0x45, 0x78, 0x70, 0x69, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x0a, 0x45, 0x78, 0x70, 0x69, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x36, 0x0a,
+	0x0a, 0x4f, 0x70, 0x48, 0x50, 0x65, 0x72, 0x73, 0x69, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x4b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x16, 0x0a,
+	0x06, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x46,
+	0x69, 0x65, 0x6c, 0x64, 0x73, 0x22, 0x48, 0x0a, 0x10, 0x4f, 0x70, 0x5a, 0x52, 0x65, 0x6d, 0x52,
+	0x61, 0x6e, 0x67, 0x65, 0x42, 0x79, 0x4c, 0x65, 0x78, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x4d,
+	0x69, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4d, 0x69, 0x6e, 0x12, 0x10, 0x0a,
+	0x03, 0x4d, 0x61, 0x78, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4d, 0x61, 0x78, 0x22,
+	0x34, 0x0a, 0x0c, 0x4f, 0x70, 0x5a, 0x44, 0x69, 0x66, 0x66, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x12,
+	0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65,
+	0x79, 0x12, 0x12, 0x0a, 0x04, 0x4b, 0x65, 0x79, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52,
+	0x04, 0x4b, 0x65, 0x79, 0x73, 0x22, 0xcd, 0x01, 0x0a, 0x0d, 0x4f, 0x70, 0x5a, 0x52, 0x61, 0x6e,
+	0x67, 0x65, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x53, 0x72, 0x63,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x53, 0x72, 0x63, 0x12, 0x14, 0x0a, 0x05, 0x53,
+	0x74, 0x61, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x53, 0x74, 0x61, 0x72,
+	0x74, 0x12, 0x12, 0x0a, 0x04, 0x53, 0x74, 0x6f, 0x70, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x53, 0x74, 0x6f, 0x70, 0x12, 0x18, 0x0a, 0x07, 0x42, 0x79, 0x53, 0x63, 0x6f, 0x72, 0x65,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x42, 0x79, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x12,
+	0x14, 0x0a, 0x05, 0x42, 0x79, 0x4c, 0x65, 0x78, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05,
+	0x42, 0x79, 0x4c, 0x65, 0x78, 0x12, 0x10, 0x0a, 0x03, 0x52, 0x65, 0x76, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x03, 0x52, 0x65, 0x76, 0x12, 0x16, 0x0a, 0x06, 0x4f, 0x66, 0x66, 0x73, 0x65,
+	0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12,
+	0x14, 0x0a, 0x05, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05,
+	0x43, 0x6f, 0x75, 0x6e, 0x74, 0x22, 0x57, 0x0a, 0x07, 0x4f, 0x70, 0x4c, 0x4d, 0x6f, 0x76, 0x65,
+	0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b,
+	0x65, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x44, 0x73, 0x74, 0x4b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x44, 0x73, 0x74, 0x4b, 0x65, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x46, 0x72,
+	0x6f, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x46, 0x72, 0x6f, 0x6d, 0x12, 0x0e,
+	0x0a, 0x02, 0x54, 0x6f, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x02, 0x54, 0x6f, 0x22, 0x4c,
+	0x0a, 0x06, 0x4f, 0x70, 0x43, 0x6f, 0x70, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x44, 0x73,
+	0x74, 0x4b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x44, 0x73, 0x74, 0x4b,
+	0x65, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x07, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x22, 0x57, 0x0a, 0x09,
+	0x4f, 0x70, 0x52, 0x65, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x50,
+	0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x50, 0x61,
+	0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x1e, 0x0a, 0x0a, 0x45, 0x78, 0x70, 0x69, 0x72, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x45, 0x78, 0x70, 0x69, 0x72,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x42, 0x0a, 0x5a, 0x08, 0x2e, 0x2e, 0x2f, 0x70, 0x61, 0x74, 0x63,
+	0x68, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -2294,7 +3479,7 @@ func file_op_proto_rawDescGZIP() []byte {
 	return file_op_proto_rawDescData
 }
 
-var file_op_proto_msgTypes = make([]protoimpl.MessageInfo, 36)
+var file_op_proto_msgTypes = make([]protoimpl.MessageInfo, 52)
 var file_op_proto_goTypes = []any{
 	(*OpClear)(nil),            // 0: patch.OpClear
 	(*OpDel)(nil),              // 1: patch.OpDel
@@ -2332,6 +3517,22 @@ var file_op_proto_goTypes = []any{
 	(*OpZUnionStore)(nil),      // 33: patch.OpZUnionStore
 	(*OpZInterStore)(nil),      // 34: patch.OpZInterStore
 	(*OpRenameNX)(nil),         // 35: patch.OpRenameNX
+	(*OpTSCreate)(nil),         // 36: patch.OpTSCreate
+	(*OpTSAdd)(nil),            // 37: patch.OpTSAdd
+	(*OpTSIncrBy)(nil),         // 38: patch.OpTSIncrBy
+	(*OpTSCreateRule)(nil),     // 39: patch.OpTSCreateRule
+	(*OpTSDeleteRule)(nil),     // 40: patch.OpTSDeleteRule
+	(*OpVAdd)(nil),             // 41: patch.OpVAdd
+	(*OpVRem)(nil),             // 42: patch.OpVRem
+	(*OpVSetAttr)(nil),         // 43: patch.OpVSetAttr
+	(*OpHExpireAt)(nil),        // 44: patch.OpHExpireAt
+	(*OpHPersist)(nil),         // 45: patch.OpHPersist
+	(*OpZRemRangeByLex)(nil),   // 46: patch.OpZRemRangeByLex
+	(*OpZDiffStore)(nil),       // 47: patch.OpZDiffStore
+	(*OpZRangeStore)(nil),      // 48: patch.OpZRangeStore
+	(*OpLMove)(nil),            // 49: patch.OpLMove
+	(*OpCopy)(nil),             // 50: patch.OpCopy
+	(*OpRestore)(nil),          // 51: patch.OpRestore
 }
 var file_op_proto_depIdxs = []int32{
 	0, // [0:0] is the sub-list for method output_type
@@ -2779,6 +3980,198 @@ func file_op_proto_init() {
 				return nil
 			}
 		}
+		file_op_proto_msgTypes[36].Exporter = func(v any, i int) any {
+			switch v := v.(*OpTSCreate); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[37].Exporter = func(v any, i int) any {
+			switch v := v.(*OpTSAdd); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[38].Exporter = func(v any, i int) any {
+			switch v := v.(*OpTSIncrBy); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[39].Exporter = func(v any, i int) any {
+			switch v := v.(*OpTSCreateRule); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[40].Exporter = func(v any, i int) any {
+			switch v := v.(*OpTSDeleteRule); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[41].Exporter = func(v any, i int) any {
+			switch v := v.(*OpVAdd); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[42].Exporter = func(v any, i int) any {
+			switch v := v.(*OpVRem); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[43].Exporter = func(v any, i int) any {
+			switch v := v.(*OpVSetAttr); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[44].Exporter = func(v any, i int) any {
+			switch v := v.(*OpHExpireAt); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[45].Exporter = func(v any, i int) any {
+			switch v := v.(*OpHPersist); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[46].Exporter = func(v any, i int) any {
+			switch v := v.(*OpZRemRangeByLex); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[47].Exporter = func(v any, i int) any {
+			switch v := v.(*OpZDiffStore); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[48].Exporter = func(v any, i int) any {
+			switch v := v.(*OpZRangeStore); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[49].Exporter = func(v any, i int) any {
+			switch v := v.(*OpLMove); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[50].Exporter = func(v any, i int) any {
+			switch v := v.(*OpCopy); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_op_proto_msgTypes[51].Exporter = func(v any, i int) any {
+			switch v := v.(*OpRestore); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -2786,7 +4179,7 @@ func file_op_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_op_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   36,
+			NumMessages:   52,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -43,6 +43,22 @@ const (
 	OpTypeZUnionStore
 	OpTypeZInterStore
 	OpTypeRenameNX
+	OpTypeTSCreate
+	OpTypeTSAdd
+	OpTypeTSIncrBy
+	OpTypeTSCreateRule
+	OpTypeTSDeleteRule
+	OpTypeVAdd
+	OpTypeVRem
+	OpTypeVSetAttr
+	OpTypeHExpireAt
+	OpTypeHPersist
+	OpTypeZRemRangeByLex
+	OpTypeZDiffStore
+	OpTypeZRangeStore
+	OpTypeLMove
+	OpTypeCopy
+	OpTypeRestore
 )
 
 type OpData interface {
@@ -135,6 +151,38 @@ func DecodeOp(data []byte) (op Op, err error) {
 		op.Data = &OpZInterStore{}
 	case OpTypeRenameNX:
 		op.Data = &OpRenameNX{}
+	case OpTypeTSCreate:
+		op.Data = &OpTSCreate{}
+	case OpTypeTSAdd:
+		op.Data = &OpTSAdd{}
+	case OpTypeTSIncrBy:
+		op.Data = &OpTSIncrBy{}
+	case OpTypeTSCreateRule:
+		op.Data = &OpTSCreateRule{}
+	case OpTypeTSDeleteRule:
+		op.Data = &OpTSDeleteRule{}
+	case OpTypeVAdd:
+		op.Data = &OpVAdd{}
+	case OpTypeVRem:
+		op.Data = &OpVRem{}
+	case OpTypeVSetAttr:
+		op.Data = &OpVSetAttr{}
+	case OpTypeHExpireAt:
+		op.Data = &OpHExpireAt{}
+	case OpTypeHPersist:
+		op.Data = &OpHPersist{}
+	case OpTypeZRemRangeByLex:
+		op.Data = &OpZRemRangeByLex{}
+	case OpTypeZDiffStore:
+		op.Data = &OpZDiffStore{}
+	case OpTypeZRangeStore:
+		op.Data = &OpZRangeStore{}
+	case OpTypeLMove:
+		op.Data = &OpLMove{}
+	case OpTypeCopy:
+		op.Data = &OpCopy{}
+	case OpTypeRestore:
+		op.Data = &OpRestore{}
 	default:
 		err = errors.New("unknown operation type")
 	}
@@ -0,0 +1,128 @@
+package nodis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/diiyw/nodis/rdb"
+)
+
+var (
+	ErrBackgroundSave = errors.New("ERR Background save already in progress")
+)
+
+// ImportRDB loads the keys of the database 0 of a Redis RDB file, the existing keys are replaced.
+// The keys of the other databases, the expired keys, the streams and the module values are skipped.
+func (n *Nodis) ImportRDB(r io.Reader) error {
+	f, err := rdb.NewFileReader(r)
+	if err != nil {
+		return err
+	}
+	f.ListOptions = n.listOptions()
+	f.Encodings = n.store.encodings
+	var skipped int
+	now := time.Now().UnixMilli()
+	for {
+		e, err := f.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if e.DB != 0 || (e.ExpireAt > 0 && e.ExpireAt <= now) {
+			skipped++
+			continue
+		}
+		opts := &RestoreOptions{ExpireAt: e.ExpireAt, SetFreq: e.HasFreq, Freq: e.Freq}
+		if e.HasIdle {
+			opts.IdleTime = time.Duration(e.Idle) * time.Second
+		}
+		_ = n.exec(func(tx *Tx) error {
+			n.putValue(tx, e.Key, tx.writeKey(e.Key, nil), e.Value, opts, nil)
+			return nil
+		})
+	}
+	if skipped += f.Skipped; skipped > 0 {
+		log.Println("ImportRDB: skipped", skipped, "keys")
+	}
+	return nil
+}
+
+// ExportRDB writes a point-in-time copy of the keys to w as a Redis RDB file like Backup does.
+// The expirations of the hash fields aren't written, Redis only loads them from later RDB versions.
+func (n *Nodis) ExportRDB(w io.Writer) error {
+	f, err := rdb.NewFileWriter(w)
+	if err != nil {
+		return err
+	}
+	err = n.saveSnapshot(context.Background(), func(e *snapshotEntry) error {
+		return f.WriteDump(e.key, e.expireAt, e.payload)
+	})
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// dumpFile returns the path of the RDB file of Save
+func (n *Nodis) dumpFile() string {
+	if n.options.DumpFile == "" {
+		return "dump.rdb"
+	}
+	return n.options.DumpFile
+}
+
+// Save flushes the changes to the storage and writes the keys to the RDB file of Options.DumpFile.
+// The file is replaced once completely written.
+func (n *Nodis) Save() error {
+	path := n.dumpFile()
+	tmp, err := os.CreateTemp(filepath.Dir(path), "temp-*.rdb")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = n.ExportRDB(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	n.lastSave.Store(time.Now().Unix())
+	return nil
+}
+
+// LastSave returns the time of the last successful save, it's the opening time if none
+func (n *Nodis) LastSave() time.Time {
+	return time.Unix(n.lastSave.Load(), 0)
+}
+
+// BGSave runs Save in the background, it fails with ErrBackgroundSave if a save is already running.
+// The status of the save is reported by the persistence section of INFO.
+func (n *Nodis) BGSave() error {
+	if !n.bgSaving.CompareAndSwap(false, true) {
+		return ErrBackgroundSave
+	}
+	go func() {
+		defer n.bgSaving.Store(false)
+		err := n.Save()
+		n.bgSaveFailed.Store(err != nil)
+		if err != nil {
+			log.Println("BGSave: ", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,29 @@
+package rdb
+
+// crc64Table is the table of the reflected Jones polynomial used by Redis
+var crc64Table = makeCRC64Table(0x95ac9329ac4bc9b5)
+
+func makeCRC64Table(poly uint64) *[256]uint64 {
+	t := new([256]uint64)
+	for i := range t {
+		crc := uint64(i)
+		for j := 0; j < 8; j++ {
+			if crc&1 == 1 {
+				crc = crc>>1 ^ poly
+			} else {
+				crc >>= 1
+			}
+		}
+		t[i] = crc
+	}
+	return t
+}
+
+// CRC64 updates the Redis CRC64 checksum crc with the bytes of b, the checksum of the payloads
+// and of the RDB files starts from 0
+func CRC64(crc uint64, b []byte) uint64 {
+	for _, v := range b {
+		crc = crc64Table[byte(crc)^v] ^ crc>>8
+	}
+	return crc
+}
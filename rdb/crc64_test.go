@@ -0,0 +1,13 @@
+package rdb
+
+import "testing"
+
+func TestCRC64(t *testing.T) {
+	// the check value of the Redis implementation
+	if v := CRC64(0, []byte("123456789")); v != 0xe9c6d914c4b8d9ca {
+		t.Errorf("CRC64() = %x, want %x", v, uint64(0xe9c6d914c4b8d9ca))
+	}
+	if v := CRC64(CRC64(0, []byte("1234")), []byte("56789")); v != 0xe9c6d914c4b8d9ca {
+		t.Errorf("CRC64() updated = %x, want %x", v, uint64(0xe9c6d914c4b8d9ca))
+	}
+}
@@ -0,0 +1,475 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/ds/set"
+	"github.com/diiyw/nodis/ds/str"
+	"github.com/diiyw/nodis/ds/timeseries"
+	"github.com/diiyw/nodis/ds/vector"
+	"github.com/diiyw/nodis/ds/zset"
+)
+
+// maxPrealloc bounds the memory allocated ahead from the lengths read, a corrupted length
+// fails when the data runs out instead of allocating it all
+const maxPrealloc = 1 << 16
+
+// Decoder reads the RDB encoding of the values
+type Decoder struct {
+	r   io.Reader
+	buf [9]byte
+	// ListOptions are the options of the decoded lists
+	ListOptions list.Options
+	// Encodings are the encodings of the decoded hashes, sets and sorted sets
+	Encodings *ds.Encodings
+}
+
+// NewDecoder returns a decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+func (d *Decoder) read(n int) ([]byte, error) {
+	if _, err := io.ReadFull(d.r, d.buf[:n]); err != nil {
+		return nil, unexpected(err)
+	}
+	return d.buf[:n], nil
+}
+
+// unexpected reports the end of the data in the middle of a value as an unexpected EOF
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReadByte reads a byte like a type or an opcode
+func (d *Decoder) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(d.r, d.buf[:1]); err != nil {
+		return 0, err
+	}
+	return d.buf[0], nil
+}
+
+// readLength reads a length, encoded is true if it's the encoding of a special string
+func (d *Decoder) readLength() (uint64, bool, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, false, err
+	}
+	first := b[0]
+	switch first >> 6 {
+	case 0:
+		return uint64(first & 0x3f), false, nil
+	case 1:
+		b, err = d.read(1)
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(first&0x3f)<<8 | uint64(b[0]), false, nil
+	case 2:
+		switch first {
+		case 0x80:
+			if b, err = d.read(4); err != nil {
+				return 0, false, err
+			}
+			return uint64(binary.BigEndian.Uint32(b)), false, nil
+		case 0x81:
+			if b, err = d.read(8); err != nil {
+				return 0, false, err
+			}
+			return binary.BigEndian.Uint64(b), false, nil
+		}
+		return 0, false, ErrBadFormat
+	}
+	return uint64(first & 0x3f), true, nil
+}
+
+// ReadLength reads a length
+func (d *Decoder) ReadLength() (uint64, error) {
+	n, encoded, err := d.readLength()
+	if err == nil && encoded {
+		err = ErrBadFormat
+	}
+	return n, err
+}
+
+// readBytes reads n bytes without allocating them all ahead
+func (d *Decoder) readBytes(n uint64) ([]byte, error) {
+	if n <= maxPrealloc {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(d.r, b); err != nil {
+			return nil, unexpected(err)
+		}
+		return b, nil
+	}
+	var buf bytes.Buffer
+	m, err := buf.ReadFrom(io.LimitReader(d.r, int64(n)))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(m) != n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadString reads a string, the integers are returned as decimal strings
+func (d *Decoder) ReadString() ([]byte, error) {
+	n, encoded, err := d.readLength()
+	if err != nil {
+		return nil, err
+	}
+	if !encoded {
+		return d.readBytes(n)
+	}
+	switch n {
+	case 0, 1, 2:
+		b, err := d.read(1 << n)
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendInt(nil, readInt(b), 10), nil
+	case 3:
+		clen, err := d.ReadLength()
+		if err != nil {
+			return nil, err
+		}
+		length, err := d.ReadLength()
+		if err != nil {
+			return nil, err
+		}
+		if length > math.MaxInt32 {
+			return nil, ErrBadFormat
+		}
+		data, err := d.readBytes(clen)
+		if err != nil {
+			return nil, err
+		}
+		return lzfDecompress(data, int(length))
+	}
+	return nil, ErrBadFormat
+}
+
+// ReadMillisecondTime reads a unix time in milliseconds
+func (d *Decoder) ReadMillisecondTime() (int64, error) {
+	b, err := d.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(b)), nil
+}
+
+// readDouble reads a double of the first sorted set type, it's a string
+func (d *Decoder) readDouble() (float64, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, err
+	}
+	switch n := b[0]; n {
+	case 253:
+		return math.NaN(), nil
+	case 254:
+		return math.Inf(1), nil
+	case 255:
+		return math.Inf(-1), nil
+	default:
+		v, err := d.readBytes(uint64(n))
+		if err != nil {
+			return 0, err
+		}
+		return parseFloat(v)
+	}
+}
+
+func (d *Decoder) readBinaryDouble() (float64, error) {
+	b, err := d.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+func parseFloat(b []byte) (float64, error) {
+	v, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return 0, ErrBadFormat
+	}
+	return v, nil
+}
+
+// readStrings reads a length followed by as many strings
+func (d *Decoder) readStrings() ([][]byte, error) {
+	n, err := d.ReadLength()
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, 0, min(n, maxPrealloc))
+	for i := uint64(0); i < n; i++ {
+		v, err := d.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// readPacked reads a string holding a packed encoding and returns its entries
+func (d *Decoder) readPacked(entries func(b []byte) ([][]byte, error)) ([][]byte, error) {
+	b, err := d.ReadString()
+	if err != nil {
+		return nil, err
+	}
+	return entries(b)
+}
+
+// ReadObject reads a value of the RDB type
+func (d *Decoder) ReadObject(typ byte) (ds.Value, error) {
+	switch typ {
+	case TypeString:
+		v, err := d.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		s := str.NewString()
+		s.Set(v)
+		return s, nil
+	case TypeList, TypeListZiplist, TypeListQuicklist, TypeListQuicklist2:
+		return d.readList(typ)
+	case TypeSet, TypeSetIntset, TypeSetListpack:
+		var members [][]byte
+		var err error
+		switch typ {
+		case TypeSet:
+			members, err = d.readStrings()
+		case TypeSetIntset:
+			members, err = d.readPacked(intsetEntries)
+		default:
+			members, err = d.readPacked(listpackEntries)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s := set.NewSetWithEncodings(d.Encodings)
+		for _, member := range members {
+			s.SAdd(string(member))
+		}
+		return s, nil
+	case TypeZSet, TypeZSet2, TypeZSetZiplist, TypeZSetListpack:
+		return d.readZSet(typ)
+	case TypeHash, TypeHashZipmap, TypeHashZiplist, TypeHashListpack, TypeHashMetadata, TypeHashListpackEx:
+		return d.readHash(typ)
+	case TypeTimeSeries, TypeVectorSet:
+		v, err := d.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		if typ == TypeTimeSeries {
+			t := timeseries.NewTimeSeries()
+			t.SetValue(v)
+			return t, nil
+		}
+		s := vector.NewVectorSet(vector.Options{})
+		s.SetValue(v)
+		return s, nil
+	}
+	return nil, ErrUnsupportedType
+}
+
+func (d *Decoder) readList(typ byte) (ds.Value, error) {
+	var values [][]byte
+	var err error
+	switch typ {
+	case TypeList:
+		values, err = d.readStrings()
+	case TypeListZiplist:
+		values, err = d.readPacked(ziplistEntries)
+	case TypeListQuicklist:
+		var nodes [][]byte
+		if nodes, err = d.readStrings(); err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			entries, err := ziplistEntries(node)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, entries...)
+		}
+	case TypeListQuicklist2:
+		var n uint64
+		if n, err = d.ReadLength(); err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < n; i++ {
+			container, err := d.ReadLength()
+			if err != nil {
+				return nil, err
+			}
+			node, err := d.ReadString()
+			if err != nil {
+				return nil, err
+			}
+			switch container {
+			case quicklistNodePlain:
+				values = append(values, node)
+			case quicklistNodePacked:
+				entries, err := listpackEntries(node)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, entries...)
+			default:
+				return nil, ErrBadFormat
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	l := list.NewLinkedListWithOptions(d.ListOptions)
+	l.RPush(values...)
+	return l, nil
+}
+
+func (d *Decoder) readZSet(typ byte) (ds.Value, error) {
+	z := zset.NewSortedSetWithEncodings(d.Encodings)
+	if typ == TypeZSetZiplist || typ == TypeZSetListpack {
+		entries := ziplistEntries
+		if typ == TypeZSetListpack {
+			entries = listpackEntries
+		}
+		values, err := d.readPacked(entries)
+		if err != nil {
+			return nil, err
+		}
+		if len(values)%2 != 0 {
+			return nil, ErrBadFormat
+		}
+		for i := 0; i < len(values); i += 2 {
+			score, err := parseFloat(values[i+1])
+			if err != nil {
+				return nil, err
+			}
+			z.ZAdd(string(values[i]), score)
+		}
+		return z, nil
+	}
+	n, err := d.ReadLength()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < n; i++ {
+		member, err := d.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		var score float64
+		if typ == TypeZSet {
+			score, err = d.readDouble()
+		} else {
+			score, err = d.readBinaryDouble()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(score) {
+			return nil, ErrBadFormat
+		}
+		z.ZAdd(string(member), score)
+	}
+	return z, nil
+}
+
+// readHash reads a hash, the fields already expired are dropped
+func (d *Decoder) readHash(typ byte) (ds.Value, error) {
+	h := hash.NewHashMapWithEncodings(d.Encodings)
+	now := time.Now().UnixMilli()
+	var minExpire int64
+	var err error
+	if typ == TypeHashMetadata || typ == TypeHashListpackEx {
+		if minExpire, err = d.ReadMillisecondTime(); err != nil {
+			return nil, err
+		}
+	}
+	setField := func(field, value []byte, at int64) {
+		if at > 0 && at <= now {
+			return
+		}
+		h.HSet(string(field), value)
+		if at > 0 {
+			h.HPExpireAt(string(field), at, hash.ExpireAlways)
+		}
+	}
+	switch typ {
+	case TypeHash, TypeHashMetadata:
+		n, err := d.ReadLength()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < n; i++ {
+			var at int64
+			if typ == TypeHashMetadata {
+				ttl, err := d.ReadLength()
+				if err != nil {
+					return nil, err
+				}
+				if ttl != 0 {
+					at = int64(ttl) + minExpire - 1
+				}
+			}
+			field, err := d.ReadString()
+			if err != nil {
+				return nil, err
+			}
+			value, err := d.ReadString()
+			if err != nil {
+				return nil, err
+			}
+			setField(field, value, at)
+		}
+		return h, nil
+	}
+	var entries func(b []byte) ([][]byte, error)
+	switch typ {
+	case TypeHashZipmap:
+		entries = zipmapEntries
+	case TypeHashZiplist:
+		entries = ziplistEntries
+	default:
+		entries = listpackEntries
+	}
+	values, err := d.readPacked(entries)
+	if err != nil {
+		return nil, err
+	}
+	// the fields of a listpack with expirations are followed by their value and their expiration
+	step := 2
+	if typ == TypeHashListpackEx {
+		step = 3
+	}
+	if len(values)%step != 0 {
+		return nil, ErrBadFormat
+	}
+	for i := 0; i < len(values); i += step {
+		var at int64
+		if step == 3 {
+			if at, err = strconv.ParseInt(string(values[i+2]), 10, 64); err != nil {
+				return nil, ErrBadFormat
+			}
+		}
+		setField(values[i], values[i+1], at)
+	}
+	return h, nil
+}
@@ -0,0 +1,139 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/ds/set"
+	"github.com/diiyw/nodis/ds/zset"
+)
+
+// listpack returns a listpack of short strings and of 64-bit integers
+func listpack(values ...any) []byte {
+	b := []byte{0, 0, 0, 0, byte(len(values)), 0}
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			b = append(b, 0x80|byte(len(v)))
+			b = append(b, v...)
+			b = append(b, byte(1+len(v)))
+		case int64:
+			b = binary.LittleEndian.AppendUint64(append(b, 0xf4), uint64(v))
+			b = append(b, 9)
+		}
+	}
+	b = append(b, 0xff)
+	binary.LittleEndian.PutUint32(b, uint32(len(b)))
+	return b
+}
+
+func TestDecoder_ReadObject(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	// a quicklist of a packed node and a plain node
+	_ = e.WriteLength(2)
+	_ = e.WriteLength(quicklistNodePacked)
+	_ = e.WriteString(testListpack)
+	_ = e.WriteLength(quicklistNodePlain)
+	_ = e.WriteString([]byte("plain"))
+	d := NewDecoder(&buf)
+	v, err := d.ReadObject(TypeListQuicklist2)
+	if err != nil {
+		t.Fatalf("ReadObject() error = %v", err)
+	}
+	if got := entriesStrings(v.(*list.LinkedList).LRange(0, -1)); !reflect.DeepEqual(got, []string{"a", "1", "-100", "100000", "plain"}) {
+		t.Errorf("ReadObject() = %v", got)
+	}
+
+	_ = e.WriteString(testZiplist)
+	if v, err = d.ReadObject(TypeListZiplist); err != nil {
+		t.Fatalf("ReadObject() error = %v", err)
+	}
+	if got := entriesStrings(v.(*list.LinkedList).LRange(0, -1)); !reflect.DeepEqual(got, []string{"a", "5", "300"}) {
+		t.Errorf("ReadObject() = %v", got)
+	}
+
+	_ = e.WriteString([]byte{2, 0, 0, 0, 2, 0, 0, 0, 1, 0, 2, 0})
+	if v, err = d.ReadObject(TypeSetIntset); err != nil {
+		t.Fatalf("ReadObject() error = %v", err)
+	}
+	if s := v.(*set.Set); s.SCard() != 2 || !s.SIsMember("2") || s.Encoding() != set.EncodingIntset {
+		t.Errorf("ReadObject() = %v", s.SMembers())
+	}
+
+	_ = e.WriteString(listpack("a", "b"))
+	if v, err = d.ReadObject(TypeSetListpack); err != nil {
+		t.Fatalf("ReadObject() error = %v", err)
+	}
+	if s := v.(*set.Set); s.SCard() != 2 || !s.SIsMember("b") {
+		t.Errorf("ReadObject() = %v", s.SMembers())
+	}
+
+	_ = e.WriteString(listpack("a", "1.5", "b", "2"))
+	if v, err = d.ReadObject(TypeZSetListpack); err != nil {
+		t.Fatalf("ReadObject() error = %v", err)
+	}
+	if score, _ := v.(*zset.SortedSet).ZScore("a"); score != 1.5 {
+		t.Errorf("ZScore() = %v, want %v", score, 1.5)
+	}
+
+	// a sorted set of the first type holds the scores as strings
+	_ = e.WriteLength(2)
+	_ = e.WriteString([]byte("a"))
+	buf.Write([]byte{3, '2', '.', '5'})
+	_ = e.WriteString([]byte("b"))
+	buf.WriteByte(254)
+	if v, err = d.ReadObject(TypeZSet); err != nil {
+		t.Fatalf("ReadObject() error = %v", err)
+	}
+	if max := v.(*zset.SortedSet).ZMax(); max.Member != "b" {
+		t.Errorf("ZMax() = %v, want %v", max.Member, "b")
+	}
+
+	_ = e.WriteString([]byte{1, 1, 'f', 1, 0, 'v', 0xff})
+	if v, err = d.ReadObject(TypeHashZipmap); err != nil {
+		t.Fatalf("ReadObject() error = %v", err)
+	}
+	if got := v.(*hash.HashMap).HGet("f"); string(got) != "v" {
+		t.Errorf("HGet() = %s, want %s", got, "v")
+	}
+
+	_ = e.WriteString(listpack("f", "v", "g", "w"))
+	if v, err = d.ReadObject(TypeHashListpack); err != nil {
+		t.Fatalf("ReadObject() error = %v", err)
+	}
+	if got := v.(*hash.HashMap).HGetAll(); len(got) != 2 || string(got["g"]) != "w" {
+		t.Errorf("HGetAll() = %v", got)
+	}
+	if _, err = d.ReadByte(); err == nil {
+		t.Errorf("ReadByte() = nil error, want EOF")
+	}
+}
+
+func TestDecoder_ReadHashListpackEx(t *testing.T) {
+	at := time.Now().Add(time.Hour).UnixMilli()
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	_ = e.WriteMillisecondTime(1000)
+	// the expired field and its value are dropped
+	_ = e.WriteString(listpack("a", "1", int64(0), "b", "2", at, "c", "3", int64(1)))
+	v, err := NewDecoder(&buf).ReadObject(TypeHashListpackEx)
+	if err != nil {
+		t.Fatalf("ReadObject() error = %v", err)
+	}
+	h := v.(*hash.HashMap)
+	if h.HLen() != 2 || h.HExists("c") {
+		t.Errorf("HGetAll() = %v, want a and b", h.HGetAll())
+	}
+	if got := h.HPExpireTime("b"); got != at {
+		t.Errorf("HPExpireTime() = %v, want %v", got, at)
+	}
+	if got := h.HPExpireTime("a"); got != -1 {
+		t.Errorf("HPExpireTime() = %v, want %v", got, -1)
+	}
+}
@@ -0,0 +1,70 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/list"
+)
+
+// Dump serializes the value like the Redis DUMP command: the RDB type and encoding of the value
+// followed by the RDB version and the CRC64 of the payload
+func Dump(v ds.Value) ([]byte, error) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.Compress = true
+	if err := e.WriteObject(v); err != nil {
+		return nil, err
+	}
+	version := uint16(Version)
+	if buf.Bytes()[0] == TypeHashMetadata {
+		version = VersionHashMetadata
+	}
+	payload := binary.LittleEndian.AppendUint16(buf.Bytes(), version)
+	return binary.LittleEndian.AppendUint64(payload, CRC64(0, payload)), nil
+}
+
+// VerifyPayload checks the version and the checksum of a payload. A checksum of 0 is not
+// checked like Redis does.
+func VerifyPayload(payload []byte) error {
+	if len(payload) < 10 {
+		return ErrBadPayload
+	}
+	footer := payload[len(payload)-10:]
+	if binary.LittleEndian.Uint16(footer) > MaxVersion {
+		return ErrBadPayload
+	}
+	crc := binary.LittleEndian.Uint64(footer[2:])
+	if crc != 0 && crc != CRC64(0, payload[:len(payload)-8]) {
+		return ErrBadPayload
+	}
+	return nil
+}
+
+// Restore deserializes a payload of Dump or of the Redis DUMP command, the lists are created
+// with the options and the other collections with the encodings
+func Restore(payload []byte, opts list.Options, e *ds.Encodings) (ds.Value, error) {
+	if err := VerifyPayload(payload); err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(payload[:len(payload)-10])
+	d := NewDecoder(r)
+	d.ListOptions = opts
+	d.Encodings = e
+	typ, err := d.ReadByte()
+	if err != nil {
+		return nil, ErrBadFormat
+	}
+	v, err := d.ReadObject(typ)
+	if err != nil {
+		if err == ErrUnsupportedType {
+			return nil, err
+		}
+		return nil, ErrBadFormat
+	}
+	if r.Len() != 0 {
+		return nil, ErrBadFormat
+	}
+	return v, nil
+}
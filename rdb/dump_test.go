@@ -0,0 +1,174 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/ds/set"
+	"github.com/diiyw/nodis/ds/str"
+	"github.com/diiyw/nodis/ds/timeseries"
+	"github.com/diiyw/nodis/ds/vector"
+	"github.com/diiyw/nodis/ds/zset"
+)
+
+func TestRestore_Redis(t *testing.T) {
+	// DUMP of the integer 10 by Redis 7.0
+	payload := []byte("\x00\xc0\n\n\x00n\x9fWE\x0e\xaec\xbb")
+	v, err := Restore(payload, list.Options{}, nil)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if s, ok := v.(*str.String); !ok || string(s.Get()) != "10" {
+		t.Errorf("Restore() = %v, want the string 10", v)
+	}
+	payload[1] = 0xc1
+	if _, err = Restore(payload, list.Options{}, nil); err != ErrBadPayload {
+		t.Errorf("Restore() error = %v, want %v", err, ErrBadPayload)
+	}
+	// a version newer than MaxVersion
+	payload = []byte("\x00\xc0\n\x0d\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+	if _, err = Restore(payload, list.Options{}, nil); err != ErrBadPayload {
+		t.Errorf("Restore() error = %v, want %v", err, ErrBadPayload)
+	}
+	// the checksum isn't checked when it's 0
+	payload = []byte("\x00\xc0\n\x0a\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+	if _, err = Restore(payload, list.Options{}, nil); err != nil {
+		t.Errorf("Restore() error = %v", err)
+	}
+	payload = []byte("\x00\xc0\x0a\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+	if _, err = Restore(payload, list.Options{}, nil); err != ErrBadFormat {
+		t.Errorf("Restore() error = %v, want %v", err, ErrBadFormat)
+	}
+}
+
+func TestDump_String(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []byte
+	}{
+		{"10", []byte{TypeString, 0xc0, 10}},
+		{"-300", []byte{TypeString, 0xc1, 0xd4, 0xfe}},
+		{"100000", []byte{TypeString, 0xc2, 0xa0, 0x86, 0x01, 0x00}},
+		{"012", []byte{TypeString, 3, '0', '1', '2'}},
+		{"12345678901", []byte{TypeString, 11, '1', '2', '3', '4', '5', '6', '7', '8', '9', '0', '1'}},
+	}
+	for _, tt := range tests {
+		s := str.NewString()
+		s.Set([]byte(tt.value))
+		payload, err := Dump(s)
+		if err != nil {
+			t.Fatalf("Dump() error = %v", err)
+		}
+		if got := payload[:len(payload)-10]; !bytes.Equal(got, tt.want) {
+			t.Errorf("Dump(%s) = %v, want %v", tt.value, got, tt.want)
+		}
+		if v := binary.LittleEndian.Uint16(payload[len(payload)-10:]); v != Version {
+			t.Errorf("Dump(%s) version = %v, want %v", tt.value, v, Version)
+		}
+	}
+	// the long strings are compressed
+	s := str.NewString()
+	s.Set([]byte(strings.Repeat("nodis", 100)))
+	payload, _ := Dump(s)
+	if payload[1] != 0xc3 {
+		t.Errorf("Dump() encoding = %x, want LZF", payload[1])
+	}
+	v, err := Restore(payload, list.Options{}, nil)
+	if err != nil || string(v.(*str.String).Get()) != strings.Repeat("nodis", 100) {
+		t.Errorf("Restore() = %v, %v, want the long string", v, err)
+	}
+}
+
+func TestDump_RoundTrip(t *testing.T) {
+	l := list.NewLinkedList()
+	l.RPush([]byte("a"), []byte("1"), []byte(strings.Repeat("b", 100)))
+	s := set.NewSet()
+	s.SAdd("a", "b", "1")
+	z := zset.NewSortedSet()
+	z.ZAdd("a", 1.5)
+	z.ZAdd("b", -2)
+	h := hash.NewHashMap()
+	h.HSet("a", []byte("1"))
+	h.HSet("b", []byte("2"))
+	ts := timeseries.NewTimeSeries()
+	_, _ = ts.Add(1000, 1.5, timeseries.DuplicateBlock)
+	vs := vector.NewVectorSet(vector.Options{})
+	_, _ = vs.VAdd("a", []float32{1, 2})
+	tests := []struct {
+		value ds.Value
+		typ   byte
+	}{
+		{l, TypeList},
+		{s, TypeSet},
+		{z, TypeZSet2},
+		{h, TypeHash},
+		{ts, TypeTimeSeries},
+		{vs, TypeVectorSet},
+	}
+	for _, tt := range tests {
+		payload, err := Dump(tt.value)
+		if err != nil {
+			t.Fatalf("Dump() error = %v", err)
+		}
+		if payload[0] != tt.typ {
+			t.Errorf("Dump() type = %v, want %v", payload[0], tt.typ)
+		}
+		v, err := Restore(payload, list.Options{}, nil)
+		if err != nil {
+			t.Fatalf("Restore() error = %v", err)
+		}
+		if !bytes.Equal(v.GetValue(), tt.value.GetValue()) && v.Type() != ds.Set && v.Type() != ds.Hash {
+			t.Errorf("Restore() = %v, want %v", v, tt.value)
+		}
+	}
+	v, _ := Restore(mustDump(t, s), list.Options{}, nil)
+	if got := v.(*set.Set).SMembers(); len(got) != 3 || !v.(*set.Set).SIsMember("1") {
+		t.Errorf("Restore() = %v, want the set members", got)
+	}
+	v, _ = Restore(mustDump(t, h), list.Options{}, nil)
+	if got := v.(*hash.HashMap).HGetAll(); !reflect.DeepEqual(got, h.HGetAll()) {
+		t.Errorf("Restore() = %v, want %v", got, h.HGetAll())
+	}
+}
+
+func mustDump(t *testing.T, v ds.Value) []byte {
+	payload, err := Dump(v)
+	if err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	return payload
+}
+
+func TestDump_HashFieldTTL(t *testing.T) {
+	h := hash.NewHashMap()
+	h.HSet("a", []byte("1"))
+	h.HSet("b", []byte("2"))
+	h.HSet("c", []byte("3"))
+	at := time.Now().Add(time.Hour).UnixMilli()
+	h.HPExpireAt("a", at, hash.ExpireAlways)
+	h.HPExpireAt("b", at+1000, hash.ExpireAlways)
+	payload := mustDump(t, h)
+	if payload[0] != TypeHashMetadata {
+		t.Errorf("Dump() type = %v, want %v", payload[0], TypeHashMetadata)
+	}
+	if v := binary.LittleEndian.Uint16(payload[len(payload)-10:]); v != VersionHashMetadata {
+		t.Errorf("Dump() version = %v, want %v", v, VersionHashMetadata)
+	}
+	v, err := Restore(payload, list.Options{}, nil)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	r := v.(*hash.HashMap)
+	for field, want := range map[string]int64{"a": at, "b": at + 1000, "c": -1} {
+		if got := r.HPExpireTime(field); got != want {
+			t.Errorf("HPExpireTime(%s) = %v, want %v", field, got, want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/ds/set"
+	"github.com/diiyw/nodis/ds/str"
+	"github.com/diiyw/nodis/ds/timeseries"
+	"github.com/diiyw/nodis/ds/vector"
+	"github.com/diiyw/nodis/ds/zset"
+)
+
+// Encoder writes the RDB encoding of the values. The values are written with the plain types
+// of the RDB format, Redis converts them to its compact encodings when loading them.
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+	// Compress compresses the strings with LZF like Redis does when rdbcompression is enabled
+	Compress bool
+	// Version is the RDB version the values are written for, 0 for the latest. The expirations
+	// of the hash fields are dropped below VersionHashMetadata.
+	Version int
+}
+
+// NewEncoder returns an encoder writing to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, buf: make([]byte, 0, 16)}
+}
+
+func (e *Encoder) write(b []byte) error {
+	_, err := e.w.Write(b)
+	return err
+}
+
+// WriteByte writes a byte like a type or an opcode
+func (e *Encoder) WriteByte(b byte) error {
+	e.buf = append(e.buf[:0], b)
+	return e.write(e.buf)
+}
+
+// WriteLength writes a length
+func (e *Encoder) WriteLength(n uint64) error {
+	e.buf = appendLength(e.buf[:0], n)
+	return e.write(e.buf)
+}
+
+func appendLength(b []byte, n uint64) []byte {
+	switch {
+	case n < 1<<6:
+		return append(b, byte(n))
+	case n < 1<<14:
+		return append(b, byte(n>>8)|0x40, byte(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0x80), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0x81), n)
+}
+
+// WriteString writes a string, the integers are written as such and the long strings are
+// compressed if Compress is set
+func (e *Encoder) WriteString(s []byte) error {
+	if len(s) <= 11 && ds.IsIntString(string(s)) {
+		v, _ := strconv.ParseInt(string(s), 10, 64)
+		switch {
+		case v >= math.MinInt8 && v <= math.MaxInt8:
+			e.buf = append(e.buf[:0], 0xc0, byte(v))
+			return e.write(e.buf)
+		case v >= math.MinInt16 && v <= math.MaxInt16:
+			e.buf = binary.LittleEndian.AppendUint16(append(e.buf[:0], 0xc1), uint16(v))
+			return e.write(e.buf)
+		case v >= math.MinInt32 && v <= math.MaxInt32:
+			e.buf = binary.LittleEndian.AppendUint32(append(e.buf[:0], 0xc2), uint32(v))
+			return e.write(e.buf)
+		}
+	}
+	if e.Compress && len(s) > 20 {
+		if c := lzfCompress(s, len(s)-4); c != nil {
+			e.buf = append(e.buf[:0], 0xc3)
+			e.buf = appendLength(e.buf, uint64(len(c)))
+			e.buf = appendLength(e.buf, uint64(len(s)))
+			if err := e.write(e.buf); err != nil {
+				return err
+			}
+			return e.write(c)
+		}
+	}
+	if err := e.WriteLength(uint64(len(s))); err != nil {
+		return err
+	}
+	return e.write(s)
+}
+
+// WriteMillisecondTime writes a unix time in milliseconds
+func (e *Encoder) WriteMillisecondTime(ms int64) error {
+	e.buf = binary.LittleEndian.AppendUint64(e.buf[:0], uint64(ms))
+	return e.write(e.buf)
+}
+
+func (e *Encoder) writeDouble(v float64) error {
+	e.buf = binary.LittleEndian.AppendUint64(e.buf[:0], math.Float64bits(v))
+	return e.write(e.buf)
+}
+
+// ObjectType returns the RDB type the value is written with
+func ObjectType(v ds.Value) (byte, error) {
+	switch value := v.(type) {
+	case *str.String:
+		return TypeString, nil
+	case *list.LinkedList:
+		return TypeList, nil
+	case *set.Set:
+		return TypeSet, nil
+	case *zset.SortedSet:
+		return TypeZSet2, nil
+	case *hash.HashMap:
+		for _, field := range value.HKeys() {
+			if value.HPExpireTime(field) > 0 {
+				return TypeHashMetadata, nil
+			}
+		}
+		return TypeHash, nil
+	case *timeseries.TimeSeries:
+		return TypeTimeSeries, nil
+	case *vector.VectorSet:
+		return TypeVectorSet, nil
+	}
+	return 0, ErrUnsupportedType
+}
+
+// objectType returns the RDB type the value is written with for the version of the encoder
+func (e *Encoder) objectType(v ds.Value) (byte, error) {
+	typ, err := ObjectType(v)
+	if err == nil && typ == TypeHashMetadata && e.Version != 0 && e.Version < VersionHashMetadata {
+		typ = TypeHash
+	}
+	return typ, err
+}
+
+// WriteObject writes the type of the value followed by the value
+func (e *Encoder) WriteObject(v ds.Value) error {
+	typ, err := e.objectType(v)
+	if err != nil {
+		return err
+	}
+	if err = e.WriteByte(typ); err != nil {
+		return err
+	}
+	return e.writeValue(typ, v)
+}
+
+// writeValue writes the value with the encoding of the RDB type
+func (e *Encoder) writeValue(typ byte, v ds.Value) error {
+	var err error
+	switch value := v.(type) {
+	case *str.String:
+		return e.WriteString(value.Get())
+	case *list.LinkedList:
+		return e.writeStrings(value.LRange(0, -1))
+	case *set.Set:
+		if err = e.WriteLength(uint64(value.SCard())); err != nil {
+			return err
+		}
+		value.Iter(func(member string) bool {
+			err = e.WriteString([]byte(member))
+			return err == nil
+		})
+		return err
+	case *zset.SortedSet:
+		items := value.ZRange(0, -1)
+		if err = e.WriteLength(uint64(len(items))); err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err = e.WriteString([]byte(item.Member)); err != nil {
+				return err
+			}
+			if err = e.writeDouble(item.Score); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *hash.HashMap:
+		return e.writeHash(value, typ == TypeHashMetadata)
+	}
+	return e.WriteString(v.GetValue())
+}
+
+func (e *Encoder) writeStrings(values [][]byte) error {
+	if err := e.WriteLength(uint64(len(values))); err != nil {
+		return err
+	}
+	for _, v := range values {
+		if err := e.WriteString(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeHash writes the fields of the hash, the fields with an expiration are preceded by it
+// relatively to the earliest one
+func (e *Encoder) writeHash(h *hash.HashMap, withTTL bool) error {
+	fields := h.HGetAll()
+	var minExpire int64
+	if withTTL {
+		for field := range fields {
+			if at := h.HPExpireTime(field); at > 0 && (minExpire == 0 || at < minExpire) {
+				minExpire = at
+			}
+		}
+		if err := e.WriteMillisecondTime(minExpire); err != nil {
+			return err
+		}
+	}
+	if err := e.WriteLength(uint64(len(fields))); err != nil {
+		return err
+	}
+	for field, value := range fields {
+		if withTTL {
+			var ttl uint64
+			if at := h.HPExpireTime(field); at > 0 {
+				ttl = uint64(at-minExpire) + 1
+			}
+			if err := e.WriteLength(ttl); err != nil {
+				return err
+			}
+		}
+		if err := e.WriteString([]byte(field)); err != nil {
+			return err
+		}
+		if err := e.WriteString(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,456 @@
+package rdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/list"
+)
+
+// The opcodes of the RDB files
+const (
+	opSlotInfo     byte = 0xf4
+	opFunction2    byte = 0xf5
+	opModuleAux    byte = 0xf7
+	opIdle         byte = 0xf8
+	opFreq         byte = 0xf9
+	opAux          byte = 0xfa
+	opResizeDB     byte = 0xfb
+	opExpireTimeMs byte = 0xfc
+	opExpireTime   byte = 0xfd
+	opSelectDB     byte = 0xfe
+	opEOF          byte = 0xff
+)
+
+// The opcodes of the values serialized by the modules
+const (
+	moduleOpEOF    = 0
+	moduleOpSInt   = 1
+	moduleOpUInt   = 2
+	moduleOpFloat  = 3
+	moduleOpDouble = 4
+	moduleOpString = 5
+)
+
+// FileVersion is the RDB version of the written files, it's the version of Redis 7.2.
+// The expirations of the hash fields need a later version, they aren't written.
+const FileVersion = 11
+
+// Entry is a key of an RDB file
+type Entry struct {
+	// DB is the database of the key
+	DB    uint64
+	Key   string
+	Value ds.Value
+	// ExpireAt is the unix time in milliseconds when the key expires, 0 for no expiration
+	ExpireAt int64
+	// Idle is the idle time of the key in seconds if HasIdle is set
+	Idle    uint64
+	HasIdle bool
+	// Freq is the access frequency of the key if HasFreq is set
+	Freq    uint8
+	HasFreq bool
+}
+
+// crcReader computes the checksum of the bytes read
+type crcReader struct {
+	r   *bufio.Reader
+	crc uint64
+}
+
+func (c *crcReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.crc = CRC64(c.crc, p[:n])
+	return n, err
+}
+
+// FileReader reads the keys of an RDB file
+type FileReader struct {
+	r *crcReader
+	d *Decoder
+	// Version is the RDB version of the file
+	Version int
+	// Aux are the auxiliary fields of the file like redis-ver
+	Aux map[string]string
+	// Skipped is the number of the keys skipped so far because their type is a module or a stream
+	Skipped int
+	// ListOptions are the options of the read lists
+	ListOptions list.Options
+	// Encodings are the encodings of the read hashes, sets and sorted sets
+	Encodings *ds.Encodings
+	db        uint64
+	done      bool
+}
+
+// NewFileReader reads the header of the RDB file and returns a reader of its keys
+func NewFileReader(r io.Reader) (*FileReader, error) {
+	cr := &crcReader{r: bufio.NewReader(r)}
+	f := &FileReader{r: cr, d: NewDecoder(cr), Aux: make(map[string]string)}
+	header := make([]byte, 9)
+	if _, err := io.ReadFull(cr, header); err != nil || string(header[:5]) != "REDIS" {
+		return nil, fmt.Errorf("rdb: not an RDB file")
+	}
+	version, err := strconv.Atoi(string(header[5:]))
+	if err != nil || version < 1 || version > MaxVersion {
+		return nil, fmt.Errorf("rdb: unsupported version %s", header[5:])
+	}
+	f.Version = version
+	return f, nil
+}
+
+// Next returns the next key of the file, it returns io.EOF at the end of the file once the
+// checksum is verified. The expired keys are returned too.
+func (f *FileReader) Next() (*Entry, error) {
+	if f.done {
+		return nil, io.EOF
+	}
+	f.d.ListOptions = f.ListOptions
+	f.d.Encodings = f.Encodings
+	e := &Entry{}
+	for {
+		typ, err := f.d.ReadByte()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		switch typ {
+		case opEOF:
+			f.done = true
+			return nil, f.verifyChecksum()
+		case opSelectDB:
+			if f.db, err = f.d.ReadLength(); err != nil {
+				return nil, err
+			}
+		case opResizeDB:
+			if _, err = f.d.ReadLength(); err == nil {
+				_, err = f.d.ReadLength()
+			}
+		case opSlotInfo:
+			for i := 0; i < 3 && err == nil; i++ {
+				_, err = f.d.ReadLength()
+			}
+		case opAux:
+			var k, v []byte
+			if k, err = f.d.ReadString(); err == nil {
+				if v, err = f.d.ReadString(); err == nil {
+					f.Aux[string(k)] = string(v)
+				}
+			}
+		case opFunction2:
+			_, err = f.d.ReadString()
+		case opModuleAux:
+			// the module id, the when opcode and when precede the module data
+			for i := 0; i < 3 && err == nil; i++ {
+				_, err = f.d.ReadLength()
+			}
+			if err == nil {
+				err = f.skipModuleValue()
+			}
+		case opExpireTime:
+			var b []byte
+			if b, err = f.d.read(4); err == nil {
+				e.ExpireAt = int64(binary.LittleEndian.Uint32(b)) * 1000
+			}
+		case opExpireTimeMs:
+			e.ExpireAt, err = f.d.ReadMillisecondTime()
+		case opIdle:
+			e.Idle, err = f.d.ReadLength()
+			e.HasIdle = true
+		case opFreq:
+			e.Freq, err = f.d.ReadByte()
+			e.HasFreq = true
+		default:
+			key, err := f.d.ReadString()
+			if err != nil {
+				return nil, err
+			}
+			e.DB, e.Key = f.db, string(key)
+			skipped, err := f.skipValue(typ)
+			if err != nil {
+				return nil, err
+			}
+			if skipped {
+				f.Skipped++
+				e = &Entry{}
+				continue
+			}
+			if e.Value, err = f.d.ReadObject(typ); err != nil {
+				return nil, err
+			}
+			return e, nil
+		}
+		if err != nil {
+			return nil, unexpected(err)
+		}
+	}
+}
+
+// verifyChecksum checks the checksum following the end of the file, a checksum of 0 is not checked
+func (f *FileReader) verifyChecksum() error {
+	if f.Version < 5 {
+		return io.EOF
+	}
+	crc := f.r.crc
+	b, err := f.d.read(8)
+	if err != nil {
+		return err
+	}
+	if v := binary.LittleEndian.Uint64(b); v != 0 && v != crc {
+		return ErrBadPayload
+	}
+	return io.EOF
+}
+
+// skipValue skips the values of the modules and of the streams, it returns whether the value is skipped
+func (f *FileReader) skipValue(typ byte) (bool, error) {
+	switch typ {
+	case TypeModule2:
+		if _, err := f.d.ReadLength(); err != nil {
+			return false, err
+		}
+		return true, f.skipModuleValue()
+	case TypeStreamListpacks, TypeStreamListpack2, TypeStreamListpack3:
+		return true, f.skipStream(typ)
+	}
+	return false, nil
+}
+
+// skipModuleValue skips the data serialized by a module
+func (f *FileReader) skipModuleValue() error {
+	for {
+		op, err := f.d.ReadLength()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case moduleOpEOF:
+			return nil
+		case moduleOpSInt, moduleOpUInt:
+			_, err = f.d.ReadLength()
+		case moduleOpFloat:
+			_, err = f.d.read(4)
+		case moduleOpDouble:
+			_, err = f.d.read(8)
+		case moduleOpString:
+			_, err = f.d.ReadString()
+		default:
+			return ErrBadFormat
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// skipLengths skips n lengths
+func (f *FileReader) skipLengths(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := f.d.ReadLength(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipStream skips a stream
+func (f *FileReader) skipStream(typ byte) error {
+	nodes, err := f.d.ReadLength()
+	if err != nil {
+		return err
+	}
+	// the nodes are a master id followed by a listpack
+	for i := uint64(0); i < 2*nodes; i++ {
+		if _, err = f.d.ReadString(); err != nil {
+			return err
+		}
+	}
+	// the length and the last id, then the first id, the max deleted id and the entries added
+	lengths := 3
+	if typ != TypeStreamListpacks {
+		lengths += 5
+	}
+	if err = f.skipLengths(lengths); err != nil {
+		return err
+	}
+	groups, err := f.d.ReadLength()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < groups; i++ {
+		// the name, the last id and the entries read
+		if _, err = f.d.ReadString(); err != nil {
+			return err
+		}
+		lengths = 2
+		if typ != TypeStreamListpacks {
+			lengths++
+		}
+		if err = f.skipLengths(lengths); err != nil {
+			return err
+		}
+		// the pending entries are an id, a delivery time and a delivery count
+		pending, err := f.d.ReadLength()
+		if err != nil {
+			return err
+		}
+		for j := uint64(0); j < pending; j++ {
+			if _, err = f.d.readBytes(16 + 8); err != nil {
+				return err
+			}
+			if _, err = f.d.ReadLength(); err != nil {
+				return err
+			}
+		}
+		consumers, err := f.d.ReadLength()
+		if err != nil {
+			return err
+		}
+		for j := uint64(0); j < consumers; j++ {
+			// the name, the seen time, the active time and the ids of the pending entries
+			if _, err = f.d.ReadString(); err != nil {
+				return err
+			}
+			size := 8
+			if typ == TypeStreamListpack3 {
+				size += 8
+			}
+			if _, err = f.d.readBytes(uint64(size)); err != nil {
+				return err
+			}
+			pending, err := f.d.ReadLength()
+			if err != nil {
+				return err
+			}
+			for k := uint64(0); k < pending; k++ {
+				if _, err = f.d.readBytes(16); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// crcWriter computes the checksum of the bytes written
+type crcWriter struct {
+	w   *bufio.Writer
+	crc uint64
+}
+
+func (c *crcWriter) Write(p []byte) (int, error) {
+	c.crc = CRC64(c.crc, p)
+	return c.w.Write(p)
+}
+
+// FileWriter writes the keys of a database to an RDB file of FileVersion
+type FileWriter struct {
+	w *crcWriter
+	e *Encoder
+}
+
+// NewFileWriter writes the header and the auxiliary fields of an RDB file, then selects the database 0
+func NewFileWriter(w io.Writer) (*FileWriter, error) {
+	cw := &crcWriter{w: bufio.NewWriter(w)}
+	f := &FileWriter{w: cw, e: NewEncoder(cw)}
+	f.e.Compress = true
+	f.e.Version = FileVersion
+	if _, err := fmt.Fprintf(cw, "REDIS%04d", FileVersion); err != nil {
+		return nil, err
+	}
+	aux := [][2]string{
+		{"redis-ver", "7.2.0"},
+		{"redis-bits", strconv.Itoa(strconv.IntSize)},
+		{"ctime", strconv.FormatInt(time.Now().Unix(), 10)},
+		{"aof-base", "0"},
+	}
+	for _, kv := range aux {
+		if err := f.writeAux(kv[0], kv[1]); err != nil {
+			return nil, err
+		}
+	}
+	if err := f.e.WriteByte(opSelectDB); err != nil {
+		return nil, err
+	}
+	if err := f.e.WriteLength(0); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileWriter) writeAux(key, value string) error {
+	if err := f.e.WriteByte(opAux); err != nil {
+		return err
+	}
+	if err := f.e.WriteString([]byte(key)); err != nil {
+		return err
+	}
+	return f.e.WriteString([]byte(value))
+}
+
+// Write writes a key and its expiration, the database of the entry is ignored
+func (f *FileWriter) Write(e *Entry) error {
+	if e.ExpireAt > 0 {
+		if err := f.e.WriteByte(opExpireTimeMs); err != nil {
+			return err
+		}
+		if err := f.e.WriteMillisecondTime(e.ExpireAt); err != nil {
+			return err
+		}
+	}
+	typ, err := f.e.objectType(e.Value)
+	if err != nil {
+		return err
+	}
+	if err = f.e.WriteByte(typ); err != nil {
+		return err
+	}
+	if err = f.e.WriteString([]byte(e.Key)); err != nil {
+		return err
+	}
+	return f.e.writeValue(typ, e.Value)
+}
+
+// Close writes the end of the file and its checksum, it doesn't close the underlying writer
+func (f *FileWriter) Close() error {
+	if err := f.e.WriteByte(opEOF); err != nil {
+		return err
+	}
+	if _, err := f.w.w.Write(binary.LittleEndian.AppendUint64(nil, f.w.crc)); err != nil {
+		return err
+	}
+	return f.w.w.Flush()
+}
+
+// WriteDump writes a key from a payload of Dump. The payloads of the hashes with field expirations
+// are decoded and written without them.
+func (f *FileWriter) WriteDump(key string, expireAt int64, payload []byte) error {
+	if len(payload) < 11 {
+		return ErrBadPayload
+	}
+	if payload[0] == TypeHashMetadata {
+		v, err := Restore(payload, list.Options{}, nil)
+		if err != nil {
+			return err
+		}
+		return f.Write(&Entry{Key: key, Value: v, ExpireAt: expireAt})
+	}
+	if expireAt > 0 {
+		if err := f.e.WriteByte(opExpireTimeMs); err != nil {
+			return err
+		}
+		if err := f.e.WriteMillisecondTime(expireAt); err != nil {
+			return err
+		}
+	}
+	if err := f.e.WriteByte(payload[0]); err != nil {
+		return err
+	}
+	if err := f.e.WriteString([]byte(key)); err != nil {
+		return err
+	}
+	return f.e.write(payload[1 : len(payload)-10])
+}
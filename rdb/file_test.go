@@ -0,0 +1,212 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/ds/set"
+	"github.com/diiyw/nodis/ds/str"
+	"github.com/diiyw/nodis/ds/zset"
+)
+
+// readEntries reads all the keys of an RDB file
+func readEntries(t *testing.T, b []byte) (*FileReader, []*Entry) {
+	t.Helper()
+	f, err := NewFileReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("NewFileReader() error = %v", err)
+	}
+	var entries []*Entry
+	for {
+		e, err := f.Next()
+		if err == io.EOF {
+			return f, entries
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+func TestFileWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewFileWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewFileWriter() error = %v", err)
+	}
+	s := str.NewString()
+	s.Set([]byte(strings.Repeat("nodis", 10)))
+	l := list.NewLinkedList()
+	l.RPush([]byte("a"), []byte("b"))
+	z := zset.NewSortedSet()
+	z.ZAdd("m", 1.5)
+	h := hash.NewHashMap()
+	h.HSet("f", []byte("v"))
+	h.HPExpireAt("f", time.Now().Add(time.Hour).UnixMilli(), hash.ExpireAlways)
+	at := time.Now().Add(time.Hour).UnixMilli()
+	for _, e := range []*Entry{
+		{Key: "s", Value: s, ExpireAt: at},
+		{Key: "l", Value: l},
+		{Key: "z", Value: z},
+		{Key: "h", Value: h},
+	} {
+		if err = w.Write(e); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("REDIS0011")) {
+		t.Errorf("header = %q, want REDIS0011", buf.Bytes()[:9])
+	}
+	f, entries := readEntries(t, buf.Bytes())
+	if f.Version != FileVersion || f.Aux["redis-ver"] != "7.2.0" {
+		t.Errorf("Version = %v, Aux = %v", f.Version, f.Aux)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("Next() returned %d keys, want 4", len(entries))
+	}
+	if e := entries[0]; e.Key != "s" || e.ExpireAt != at || string(e.Value.(*str.String).Get()) != strings.Repeat("nodis", 10) {
+		t.Errorf("Next() = %+v", e)
+	}
+	if got := entriesStrings(entries[1].Value.(*list.LinkedList).LRange(0, -1)); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("LRange() = %v", got)
+	}
+	if score, _ := entries[2].Value.(*zset.SortedSet).ZScore("m"); score != 1.5 {
+		t.Errorf("ZScore() = %v", score)
+	}
+	// the expirations of the hash fields need a later version
+	rh := entries[3].Value.(*hash.HashMap)
+	if string(rh.HGet("f")) != "v" || rh.HPExpireTime("f") > 0 {
+		t.Errorf("HGet() = %s, HPExpireTime() = %v", rh.HGet("f"), rh.HPExpireTime("f"))
+	}
+}
+
+func TestFileReader(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("REDIS0010")
+	e := NewEncoder(&buf)
+	e.Compress = true
+	_ = e.WriteByte(opAux)
+	_ = e.WriteString([]byte("redis-ver"))
+	_ = e.WriteString([]byte("7.0.0"))
+	// the auxiliary data of a module
+	_ = e.WriteByte(opModuleAux)
+	_ = e.WriteLength(12345)
+	_ = e.WriteLength(moduleOpUInt)
+	_ = e.WriteLength(2)
+	_ = e.WriteLength(moduleOpString)
+	_ = e.WriteString([]byte("aux"))
+	_ = e.WriteLength(moduleOpEOF)
+	_ = e.WriteByte(opFunction2)
+	_ = e.WriteString([]byte("#!lua name=lib"))
+	_ = e.WriteByte(opSelectDB)
+	_ = e.WriteLength(0)
+	_ = e.WriteByte(opResizeDB)
+	_ = e.WriteLength(3)
+	_ = e.WriteLength(1)
+	// a compressed string expiring in seconds
+	at := time.Now().Add(time.Hour).Unix()
+	_ = e.WriteByte(opExpireTime)
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(at)))
+	_ = e.WriteByte(TypeString)
+	_ = e.WriteString([]byte("s"))
+	_ = e.WriteString([]byte(strings.Repeat("a", 100)))
+	// a value of a module
+	_ = e.WriteByte(TypeModule2)
+	_ = e.WriteString([]byte("module"))
+	_ = e.WriteLength(12345)
+	_ = e.WriteLength(moduleOpDouble)
+	_ = e.writeDouble(1)
+	_ = e.WriteLength(moduleOpFloat)
+	buf.Write(make([]byte, 4))
+	_ = e.WriteLength(moduleOpSInt)
+	_ = e.WriteLength(7)
+	_ = e.WriteLength(moduleOpEOF)
+	// a stream of a group with a pending entry and a consumer
+	_ = e.WriteByte(TypeStreamListpack3)
+	_ = e.WriteString([]byte("stream"))
+	_ = e.WriteLength(1)
+	_ = e.WriteString(make([]byte, 16))
+	_ = e.WriteString(listpack("f", "v"))
+	for i := 0; i < 8; i++ {
+		_ = e.WriteLength(0)
+	}
+	_ = e.WriteLength(1)
+	_ = e.WriteString([]byte("group"))
+	for i := 0; i < 3; i++ {
+		_ = e.WriteLength(0)
+	}
+	_ = e.WriteLength(1)
+	buf.Write(make([]byte, 16+8))
+	_ = e.WriteLength(1)
+	_ = e.WriteLength(1)
+	_ = e.WriteString([]byte("consumer"))
+	buf.Write(make([]byte, 16))
+	_ = e.WriteLength(1)
+	buf.Write(make([]byte, 16))
+	// a set with an access frequency
+	_ = e.WriteByte(opFreq)
+	_ = e.WriteByte(5)
+	_ = e.WriteByte(TypeSetListpack)
+	_ = e.WriteString([]byte("set"))
+	_ = e.WriteString(listpack("a", "b"))
+	_ = e.WriteByte(opSelectDB)
+	_ = e.WriteLength(1)
+	_ = e.WriteByte(TypeString)
+	_ = e.WriteString([]byte("db1"))
+	_ = e.WriteString([]byte("v"))
+	_ = e.WriteByte(opEOF)
+	buf.Write(binary.LittleEndian.AppendUint64(nil, CRC64(0, buf.Bytes())))
+
+	f, entries := readEntries(t, buf.Bytes())
+	if f.Skipped != 2 || f.Aux["redis-ver"] != "7.0.0" {
+		t.Errorf("Skipped = %v, Aux = %v", f.Skipped, f.Aux)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Next() returned %d keys, want 3", len(entries))
+	}
+	if e := entries[0]; e.Key != "s" || e.ExpireAt != at*1000 || string(e.Value.(*str.String).Get()) != strings.Repeat("a", 100) {
+		t.Errorf("Next() = %+v", e)
+	}
+	if e := entries[1]; e.Key != "set" || !e.HasFreq || e.Freq != 5 || e.Value.(*set.Set).SCard() != 2 {
+		t.Errorf("Next() = %+v", e)
+	}
+	if e := entries[2]; e.Key != "db1" || e.DB != 1 {
+		t.Errorf("Next() = %+v", e)
+	}
+
+	b := buf.Bytes()
+	b[len(b)-1]++
+	f, _ = NewFileReader(bytes.NewReader(b))
+	var err error
+	for err == nil {
+		_, err = f.Next()
+	}
+	if err != ErrBadPayload {
+		t.Errorf("Next() error = %v, want %v", err, ErrBadPayload)
+	}
+	if _, err = f.Next(); err != io.EOF {
+		t.Errorf("Next() error = %v, want %v", err, io.EOF)
+	}
+	if _, err = NewFileReader(strings.NewReader("REDIS0099")); err == nil {
+		t.Errorf("NewFileReader() error = nil on an unsupported version")
+	}
+	// a truncated file
+	f, _ = NewFileReader(bytes.NewReader(b[:len(b)-20]))
+	for err = nil; err == nil; {
+		_, err = f.Next()
+	}
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Next() error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
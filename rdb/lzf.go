@@ -0,0 +1,100 @@
+package rdb
+
+// lzfDecompress decompresses the LZF compressed data into a buffer of the uncompressed length
+func lzfDecompress(data []byte, length int) ([]byte, error) {
+	out := make([]byte, 0, length)
+	for i := 0; i < len(data); {
+		ctrl := int(data[i])
+		i++
+		if ctrl < 1<<5 {
+			// literal run of ctrl+1 bytes
+			end := i + ctrl + 1
+			if end > len(data) || len(out)+ctrl+1 > length {
+				return nil, ErrBadFormat
+			}
+			out = append(out, data[i:end]...)
+			i = end
+			continue
+		}
+		// back reference of at least 3 bytes
+		n := ctrl >> 5
+		if n == 7 {
+			if i >= len(data) {
+				return nil, ErrBadFormat
+			}
+			n += int(data[i])
+			i++
+		}
+		if i >= len(data) {
+			return nil, ErrBadFormat
+		}
+		ref := len(out) - (ctrl&0x1f)<<8 - int(data[i]) - 1
+		i++
+		if ref < 0 || len(out)+n+2 > length {
+			return nil, ErrBadFormat
+		}
+		// the reference may overlap the output, copy byte by byte
+		for j := 0; j < n+2; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+	if len(out) != length {
+		return nil, ErrBadFormat
+	}
+	return out, nil
+}
+
+// lzfCompress compresses the data with LZF, it returns nil if the compressed data is not smaller
+// than limit bytes
+func lzfCompress(data []byte, limit int) []byte {
+	const (
+		hashBits  = 14
+		maxOffset = 1 << 13
+		maxRef    = 7 + 255 + 2
+		maxLit    = 1 << 5
+	)
+	var table [1 << hashBits]int
+	out := make([]byte, 0, limit)
+	literals := 0
+	flush := func(end int) {
+		for start := end - literals; start < end; start += maxLit {
+			n := min(end-start, maxLit)
+			out = append(out, byte(n-1))
+			out = append(out, data[start:start+n]...)
+		}
+		literals = 0
+	}
+	i := 0
+	for i+2 < len(data) {
+		h := (uint32(data[i])<<16 | uint32(data[i+1])<<8 | uint32(data[i+2])) * 2654435761 >> (32 - hashBits)
+		ref := table[h] - 1
+		table[h] = i + 1
+		offset := i - ref - 1
+		if ref < 0 || offset >= maxOffset || data[ref] != data[i] || data[ref+1] != data[i+1] || data[ref+2] != data[i+2] {
+			literals++
+			i++
+			continue
+		}
+		flush(i)
+		n := 3
+		for n < maxRef && i+n < len(data) && data[ref+n] == data[i+n] {
+			n++
+		}
+		if n-2 < 7 {
+			out = append(out, byte((n-2)<<5|offset>>8))
+		} else {
+			out = append(out, byte(7<<5|offset>>8), byte(n-2-7))
+		}
+		out = append(out, byte(offset))
+		i += n
+		if len(out) >= limit {
+			return nil
+		}
+	}
+	literals += len(data) - i
+	flush(len(data))
+	if len(out) >= limit {
+		return nil
+	}
+	return out
+}
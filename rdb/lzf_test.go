@@ -0,0 +1,41 @@
+package rdb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLZFDecompress(t *testing.T) {
+	// a literal 'a' followed by a reference to it of 9 bytes
+	v, err := lzfDecompress([]byte{0x00, 'a', 0xe0, 0x00, 0x00}, 10)
+	if err != nil || string(v) != "aaaaaaaaaa" {
+		t.Errorf("lzfDecompress() = %q, %v, want %q", v, err, "aaaaaaaaaa")
+	}
+	if _, err = lzfDecompress([]byte{0x00, 'a', 0xe0, 0x00, 0x00}, 9); err != ErrBadFormat {
+		t.Errorf("lzfDecompress() error = %v, want %v", err, ErrBadFormat)
+	}
+	if _, err = lzfDecompress([]byte{0x20, 0x05}, 3); err != ErrBadFormat {
+		t.Errorf("lzfDecompress() error = %v, want %v", err, ErrBadFormat)
+	}
+}
+
+func TestLZFCompress(t *testing.T) {
+	tests := [][]byte{
+		bytes.Repeat([]byte("a"), 1000),
+		bytes.Repeat([]byte("hello world "), 100),
+		append(bytes.Repeat([]byte("abcdefghijklmnopqrstuvwxyz0123456789"), 3), bytes.Repeat([]byte{0}, 300)...),
+	}
+	for _, data := range tests {
+		c := lzfCompress(data, len(data)-4)
+		if c == nil {
+			t.Fatalf("lzfCompress() = nil, want compressed data")
+		}
+		v, err := lzfDecompress(c, len(data))
+		if err != nil || !bytes.Equal(v, data) {
+			t.Errorf("lzfDecompress(lzfCompress()) = %q, %v, want %q", v, err, data)
+		}
+	}
+	if c := lzfCompress([]byte("abcdefghijklmnopqrstuvwxyz"), 22); c != nil {
+		t.Errorf("lzfCompress() = %v, want nil for incompressible data", c)
+	}
+}
@@ -0,0 +1,60 @@
+// Package rdb implements the Redis RDB serialization of the values, it's compatible with the
+// payloads of the Redis DUMP and RESTORE commands.
+package rdb
+
+import (
+	"errors"
+)
+
+// The RDB types of the values
+const (
+	TypeString          byte = 0
+	TypeList            byte = 1
+	TypeSet             byte = 2
+	TypeZSet            byte = 3
+	TypeHash            byte = 4
+	TypeZSet2           byte = 5
+	TypeModule          byte = 6
+	TypeModule2         byte = 7
+	TypeHashZipmap      byte = 9
+	TypeListZiplist     byte = 10
+	TypeSetIntset       byte = 11
+	TypeZSetZiplist     byte = 12
+	TypeHashZiplist     byte = 13
+	TypeListQuicklist   byte = 14
+	TypeStreamListpacks byte = 15
+	TypeHashListpack    byte = 16
+	TypeZSetListpack    byte = 17
+	TypeListQuicklist2  byte = 18
+	TypeStreamListpack2 byte = 19
+	TypeSetListpack     byte = 20
+	TypeStreamListpack3 byte = 21
+	TypeHashMetadata    byte = 24
+	TypeHashListpackEx  byte = 25
+
+	// TypeTimeSeries and TypeVectorSet are the types of the nodis values Redis doesn't have,
+	// they hold the nodis encoding of the value and only nodis can load them
+	TypeTimeSeries byte = 0xE6
+	TypeVectorSet  byte = 0xE7
+)
+
+const (
+	// Version is the RDB version of the payloads without hash field expirations, the payloads are
+	// loadable by Redis 5 and later
+	Version = 9
+	// VersionHashMetadata is the RDB version of the payloads with hash field expirations
+	VersionHashMetadata = 12
+	// MaxVersion is the latest RDB version that can be loaded
+	MaxVersion = 12
+)
+
+const (
+	quicklistNodePlain  = 1
+	quicklistNodePacked = 2
+)
+
+var (
+	ErrUnsupportedType = errors.New("rdb: unsupported value type")
+	ErrBadFormat       = errors.New("rdb: bad data format")
+	ErrBadPayload      = errors.New("rdb: payload version or checksum are wrong")
+)
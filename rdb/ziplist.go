@@ -0,0 +1,260 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+// The packed encodings are read from the strings of the RDB values. All the integers are
+// returned as decimal strings like Redis does when it converts them.
+
+// ziplistEntries returns the entries of a ziplist
+func ziplistEntries(b []byte) ([][]byte, error) {
+	if len(b) < 11 {
+		return nil, ErrBadFormat
+	}
+	count := int(binary.LittleEndian.Uint16(b[8:]))
+	entries := make([][]byte, 0, count)
+	p := 10
+	for {
+		if p >= len(b) {
+			return nil, ErrBadFormat
+		}
+		if b[p] == 0xff {
+			break
+		}
+		// skip the length of the previous entry
+		if b[p] == 0xfe {
+			p += 5
+		} else {
+			p++
+		}
+		if p >= len(b) {
+			return nil, ErrBadFormat
+		}
+		enc := b[p]
+		p++
+		var entry []byte
+		var n int
+		switch {
+		case enc>>6 == 0:
+			n = int(enc & 0x3f)
+		case enc>>6 == 1:
+			if p >= len(b) {
+				return nil, ErrBadFormat
+			}
+			n = int(enc&0x3f)<<8 | int(b[p])
+			p++
+		case enc == 0x80:
+			if p+4 > len(b) {
+				return nil, ErrBadFormat
+			}
+			n = int(binary.BigEndian.Uint32(b[p:]))
+			p += 4
+		case enc == 0xc0, enc == 0xd0, enc == 0xe0, enc == 0xf0, enc == 0xfe:
+			size := ziplistIntSize(enc)
+			if p+size > len(b) {
+				return nil, ErrBadFormat
+			}
+			entry = strconv.AppendInt(nil, readInt(b[p:p+size]), 10)
+			p += size
+		case enc>>4 == 0xf && enc&0xf >= 1 && enc&0xf <= 13:
+			entry = strconv.AppendInt(nil, int64(enc&0xf)-1, 10)
+		default:
+			return nil, ErrBadFormat
+		}
+		if entry == nil {
+			if p+n > len(b) {
+				return nil, ErrBadFormat
+			}
+			entry = b[p : p+n]
+			p += n
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ziplistIntSize returns the size of the integer of the ziplist encoding
+func ziplistIntSize(enc byte) int {
+	switch enc {
+	case 0xc0:
+		return 2
+	case 0xd0:
+		return 4
+	case 0xe0:
+		return 8
+	case 0xf0:
+		return 3
+	}
+	return 1
+}
+
+// listpackEntries returns the entries of a listpack
+func listpackEntries(b []byte) ([][]byte, error) {
+	if len(b) < 7 {
+		return nil, ErrBadFormat
+	}
+	count := int(binary.LittleEndian.Uint16(b[4:]))
+	entries := make([][]byte, 0, count)
+	p := 6
+	for {
+		if p >= len(b) {
+			return nil, ErrBadFormat
+		}
+		enc := b[p]
+		if enc == 0xff {
+			break
+		}
+		start := p
+		p++
+		var entry []byte
+		var n int
+		switch {
+		case enc>>7 == 0:
+			entry = strconv.AppendInt(nil, int64(enc), 10)
+		case enc>>6 == 2:
+			n = int(enc & 0x3f)
+		case enc>>5 == 6:
+			if p >= len(b) {
+				return nil, ErrBadFormat
+			}
+			v := int64(enc&0x1f)<<8 | int64(b[p])
+			if v >= 1<<12 {
+				v -= 1 << 13
+			}
+			entry = strconv.AppendInt(nil, v, 10)
+			p++
+		case enc>>4 == 0xe:
+			if p >= len(b) {
+				return nil, ErrBadFormat
+			}
+			n = int(enc&0xf)<<8 | int(b[p])
+			p++
+		case enc == 0xf0:
+			if p+4 > len(b) {
+				return nil, ErrBadFormat
+			}
+			n = int(binary.LittleEndian.Uint32(b[p:]))
+			p += 4
+		case enc >= 0xf1 && enc <= 0xf4:
+			size := listpackIntSize(enc)
+			if p+size > len(b) {
+				return nil, ErrBadFormat
+			}
+			entry = strconv.AppendInt(nil, readInt(b[p:p+size]), 10)
+			p += size
+		default:
+			return nil, ErrBadFormat
+		}
+		if entry == nil {
+			if n < 0 || p+n > len(b) {
+				return nil, ErrBadFormat
+			}
+			entry = b[p : p+n]
+			p += n
+		}
+		// skip the backlen
+		p += backlenSize(p - start)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// listpackIntSize returns the size of the integer of the listpack encoding
+func listpackIntSize(enc byte) int {
+	switch enc {
+	case 0xf1:
+		return 2
+	case 0xf2:
+		return 3
+	case 0xf3:
+		return 4
+	}
+	return 8
+}
+
+// backlenSize returns the size of the backlen of an entry of size n
+func backlenSize(n int) int {
+	switch {
+	case n <= 127:
+		return 1
+	case n < 16383:
+		return 2
+	case n < 2097151:
+		return 3
+	case n < 268435455:
+		return 4
+	}
+	return 5
+}
+
+// intsetEntries returns the integers of an intset
+func intsetEntries(b []byte) ([][]byte, error) {
+	if len(b) < 8 {
+		return nil, ErrBadFormat
+	}
+	size := int(binary.LittleEndian.Uint32(b))
+	count := int(binary.LittleEndian.Uint32(b[4:]))
+	if (size != 2 && size != 4 && size != 8) || len(b) != 8+size*count {
+		return nil, ErrBadFormat
+	}
+	entries := make([][]byte, 0, count)
+	for p := 8; p < len(b); p += size {
+		entries = append(entries, strconv.AppendInt(nil, readInt(b[p:p+size]), 10))
+	}
+	return entries, nil
+}
+
+// zipmapEntries returns the fields followed by their values of a zipmap
+func zipmapEntries(b []byte) ([][]byte, error) {
+	var entries [][]byte
+	p := 1
+	for {
+		if p >= len(b) {
+			return nil, ErrBadFormat
+		}
+		if b[p] == 0xff {
+			break
+		}
+		for i := 0; i < 2; i++ {
+			if p >= len(b) {
+				return nil, ErrBadFormat
+			}
+			n := int(b[p])
+			p++
+			if n == 254 {
+				if p+4 > len(b) {
+					return nil, ErrBadFormat
+				}
+				n = int(binary.LittleEndian.Uint32(b[p:]))
+				p += 4
+			}
+			// the value is followed by unused bytes
+			var free int
+			if i == 1 {
+				if p >= len(b) {
+					return nil, ErrBadFormat
+				}
+				free = int(b[p])
+				p++
+			}
+			if p+n+free > len(b) {
+				return nil, ErrBadFormat
+			}
+			entries = append(entries, b[p:p+n])
+			p += n + free
+		}
+	}
+	return entries, nil
+}
+
+// readInt reads a little endian signed integer of 1 to 8 bytes
+func readInt(b []byte) int64 {
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	shift := 64 - 8*len(b)
+	return int64(v<<shift) >> shift
+}
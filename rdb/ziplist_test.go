@@ -0,0 +1,82 @@
+package rdb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func entriesStrings(entries [][]byte) []string {
+	s := make([]string, 0, len(entries))
+	for _, e := range entries {
+		s = append(s, string(e))
+	}
+	return s
+}
+
+// testZiplist holds "a", 5 and 300
+var testZiplist = []byte{
+	20, 0, 0, 0, 15, 0, 0, 0, 3, 0,
+	0x00, 0x01, 'a',
+	0x03, 0xf6,
+	0x02, 0xc0, 0x2c, 0x01,
+	0xff,
+}
+
+// testListpack holds "a", 1, -100 and 100000
+var testListpack = []byte{
+	20, 0, 0, 0, 4, 0,
+	0x81, 'a', 0x02,
+	0x01, 0x01,
+	0xdf, 0x9c, 0x02,
+	0xf2, 0xa0, 0x86, 0x01, 0x04,
+	0xff,
+}
+
+func TestZiplistEntries(t *testing.T) {
+	entries, err := ziplistEntries(testZiplist)
+	if err != nil {
+		t.Fatalf("ziplistEntries() error = %v", err)
+	}
+	if got := entriesStrings(entries); !reflect.DeepEqual(got, []string{"a", "5", "300"}) {
+		t.Errorf("ziplistEntries() = %v, want %v", got, []string{"a", "5", "300"})
+	}
+	if _, err = ziplistEntries(testZiplist[:len(testZiplist)-2]); err != ErrBadFormat {
+		t.Errorf("ziplistEntries() error = %v, want %v", err, ErrBadFormat)
+	}
+}
+
+func TestListpackEntries(t *testing.T) {
+	entries, err := listpackEntries(testListpack)
+	if err != nil {
+		t.Fatalf("listpackEntries() error = %v", err)
+	}
+	if got := entriesStrings(entries); !reflect.DeepEqual(got, []string{"a", "1", "-100", "100000"}) {
+		t.Errorf("listpackEntries() = %v, want %v", got, []string{"a", "1", "-100", "100000"})
+	}
+	if _, err = listpackEntries(testListpack[:len(testListpack)-3]); err != ErrBadFormat {
+		t.Errorf("listpackEntries() error = %v, want %v", err, ErrBadFormat)
+	}
+}
+
+func TestIntsetEntries(t *testing.T) {
+	entries, err := intsetEntries([]byte{2, 0, 0, 0, 3, 0, 0, 0, 0xfd, 0xff, 1, 0, 2, 0})
+	if err != nil {
+		t.Fatalf("intsetEntries() error = %v", err)
+	}
+	if got := entriesStrings(entries); !reflect.DeepEqual(got, []string{"-3", "1", "2"}) {
+		t.Errorf("intsetEntries() = %v, want %v", got, []string{"-3", "1", "2"})
+	}
+	if _, err = intsetEntries([]byte{3, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0}); err != ErrBadFormat {
+		t.Errorf("intsetEntries() error = %v, want %v", err, ErrBadFormat)
+	}
+}
+
+func TestZipmapEntries(t *testing.T) {
+	entries, err := zipmapEntries([]byte{2, 1, 'f', 1, 0, 'v', 2, 'f', '2', 2, 1, 'v', '2', 0, 0xff})
+	if err != nil {
+		t.Fatalf("zipmapEntries() error = %v", err)
+	}
+	if got := entriesStrings(entries); !reflect.DeepEqual(got, []string{"f", "v", "f2", "v2"}) {
+		t.Errorf("zipmapEntries() = %v, want %v", got, []string{"f", "v", "f2", "v2"})
+	}
+}
@@ -0,0 +1,110 @@
+package nodis
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diiyw/nodis/redis"
+)
+
+func TestRDB_ImportExport(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.Set("s", []byte("v"), false)
+	n.Expire("s", 3600)
+	n.RPush("list", []byte("a"), []byte("b"))
+	n.SAdd("set", "a", "b", "c")
+	n.ZAdd("zset", "m", 2)
+	n.HSet("hash", "f", []byte("v"))
+	var buf bytes.Buffer
+	if err := n.ExportRDB(&buf); err != nil {
+		t.Fatalf("ExportRDB() error = %v", err)
+	}
+
+	m := Open(&Options{})
+	m.Set("s", []byte("old"), false)
+	if err := m.ImportRDB(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("ImportRDB() error = %v", err)
+	}
+	if v := m.Get("s"); string(v) != "v" {
+		t.Errorf("Get() = %s, want v", v)
+	}
+	if v := m.PExpireTime("s"); v != n.PExpireTime("s") {
+		t.Errorf("PExpireTime() = %v, want %v", v, n.PExpireTime("s"))
+	}
+	if v := m.LRange("list", 0, -1); len(v) != 2 || string(v[0]) != "a" {
+		t.Errorf("LRange() = %v, want [a b]", v)
+	}
+	if v := m.SCard("set"); v != 3 {
+		t.Errorf("SCard() = %v, want 3", v)
+	}
+	if v, _ := m.ZScore("zset", "m"); v != 2 {
+		t.Errorf("ZScore() = %v, want 2", v)
+	}
+	if v := m.HGet("hash", "f"); string(v) != "v" {
+		t.Errorf("HGet() = %s, want v", v)
+	}
+	if err := m.ImportRDB(bytes.NewReader([]byte("nodis"))); err == nil {
+		t.Errorf("ImportRDB() error = nil on a bad file")
+	}
+}
+
+func TestRDB_Save(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	n := Open(&Options{DumpFile: path})
+	n.Set("key", []byte("value"), false)
+	w := redis.NewWriter(&bytes.Buffer{})
+	GetCommand("SAVE")(n, &redis.Conn{Writer: w}, redis.Command{Name: "SAVE"})
+	if v := string(w.Bytes()); v != "+OK\r\n" {
+		t.Errorf("SAVE = %q", v)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	m := Open(&Options{})
+	err = m.ImportRDB(f)
+	_ = f.Close()
+	if err != nil {
+		t.Fatalf("ImportRDB() error = %v", err)
+	}
+	if v := m.Get("key"); string(v) != "value" {
+		t.Errorf("Get() = %s, want value", v)
+	}
+
+	_ = os.Remove(path)
+	w.Reset()
+	GetCommand("BGSAVE")(n, &redis.Conn{Writer: w}, redis.Command{Name: "BGSAVE"})
+	if v := string(w.Bytes()); v != "+Background saving started\r\n" {
+		t.Errorf("BGSAVE = %q", v)
+	}
+	for i := 0; n.bgSaving.Load() && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err = os.Stat(path); err != nil {
+		t.Errorf("Stat() error = %v", err)
+	}
+	if n.bgSaveFailed.Load() {
+		t.Errorf("bgSaveFailed = true")
+	}
+	w.Reset()
+	GetCommand("LASTSAVE")(n, &redis.Conn{Writer: w}, redis.Command{Name: "LASTSAVE"})
+	if v := string(w.Bytes()); v != ":"+strconv.FormatInt(n.LastSave().Unix(), 10)+"\r\n" {
+		t.Errorf("LASTSAVE = %q", v)
+	}
+	w.Reset()
+	GetCommand("INFO")(n, &redis.Conn{Writer: w}, redis.Command{Name: "INFO"})
+	if v := string(w.Bytes()); !strings.Contains(v, "rdb_bgsave_in_progress:0\r\n") || !strings.Contains(v, "rdb_last_bgsave_status:ok\r\n") {
+		t.Errorf("INFO = %q", v)
+	}
+	n.bgSaving.Store(true)
+	if err = n.BGSave(); err != ErrBackgroundSave {
+		t.Errorf("BGSave() error = %v, want %v", err, ErrBackgroundSave)
+	}
+}
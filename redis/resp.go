@@ -130,6 +130,7 @@ var (
 	ErrInvalidRequestExceptedArray       = errors.New("invalid request, expected array")
 	ErrInvalidRequestExceptedArrayLength = errors.New("invalid request, expected array length")
 	ErrInvalidRequestExceptedBulk        = errors.New("invalid request, expected bulk")
+	ErrInvalidReply                      = errors.New("invalid reply, expected a simple string, an error, an integer or a bulk")
 )
 
 func (r *Reader) ReadCommand() error {
@@ -266,6 +267,58 @@ func (r *Reader) readBulk() (string, error) {
 	return v, nil
 }
 
+// ReplyError is an error reply
+type ReplyError string
+
+func (e ReplyError) Error() string {
+	return string(e)
+}
+
+// ReadReply reads a simple string, error, integer or bulk string reply. An error reply is
+// returned as a ReplyError and a null bulk string as an empty string.
+func (r *Reader) ReadReply() (string, error) {
+	r.reset()
+	err := r.readByte()
+	if err != nil {
+		return "", err
+	}
+	typ := r.peekByte(0)
+	r.discard()
+	switch typ {
+	case StringType, IntegerType, ErrType:
+		err = r.readLine()
+		if err != nil {
+			return "", err
+		}
+		v := r.String()
+		if typ == ErrType {
+			return "", ReplyError(v)
+		}
+		return v, nil
+	case BulkType:
+		l, err := r.readInteger()
+		if err != nil {
+			return "", err
+		}
+		if l < 0 {
+			return "", nil
+		}
+		err = r.readByteN(l)
+		if err != nil {
+			return "", err
+		}
+		v := r.String()
+		// Read the trailing CRLF
+		err = r.readLine()
+		if err != nil {
+			return "", err
+		}
+		r.discard()
+		return v, nil
+	}
+	return "", ErrInvalidReply
+}
+
 func (r *Reader) readUtil(end byte) (bool, error) {
 	var lineEnd bool
 	for {
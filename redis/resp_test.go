@@ -2,8 +2,10 @@ package redis
 
 import (
 	"io"
+	"net"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestReadInlineSimple(t *testing.T) {
@@ -395,3 +397,51 @@ func BenchmarkReadCommand(b *testing.B) {
 		_ = r.ReadCommand()
 	}
 }
+
+func TestConnWaitClosed(t *testing.T) {
+	server, client := net.Pipe()
+	r := &connReader{Conn: server}
+	c := &Conn{Reader: NewReader(r), conn: r}
+	closed, stop := c.WaitClosed()
+	_, _ = client.Write([]byte("ping\r\n"))
+	stop()
+	select {
+	case <-closed:
+		t.Fatalf("connection closed")
+	default:
+	}
+	// the data sent while waiting is kept for the next command
+	if err := c.ReadCommand(); err != nil || c.cmd.Name != "PING" {
+		t.Errorf("ReadCommand() = %s, %v", c.cmd.Name, err)
+	}
+	closed, stop = c.WaitClosed()
+	defer stop()
+	_ = client.Close()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Errorf("connection not closed")
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	r := NewReader(strings.NewReader("+OK\r\n-ERR bad\r\n:42\r\n$5\r\nhe\r\nl\r\n$-1\r\n$0\r\n\r\n*1\r\n"))
+	tests := []struct {
+		want string
+		err  error
+	}{
+		{"OK", nil},
+		{"", ReplyError("ERR bad")},
+		{"42", nil},
+		{"he\r\nl", nil},
+		{"", nil},
+		{"", nil},
+		{"", ErrInvalidReply},
+	}
+	for i, tt := range tests {
+		got, err := r.ReadReply()
+		if got != tt.want || err != tt.err {
+			t.Errorf("ReadReply() #%d = %q, %v, want %q, %v", i, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package redis
 
 import (
+	"errors"
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -16,6 +19,7 @@ const (
 var (
 	ClientLocker sync.RWMutex
 	Clients      = make(map[int]*Conn)
+	lastFd       atomic.Int64
 )
 
 type HandlerFunc func(c *Conn, cmd Command)
@@ -29,6 +33,53 @@ type Conn struct {
 	Commands  []func()
 	State     uint8
 	WatchKeys map[string]bool
+	conn      *connReader
+}
+
+// connReader serves the bytes read while waiting for the connection to be closed before reading the connection
+type connReader struct {
+	net.Conn
+	pending []byte
+}
+
+func (r *connReader) Read(p []byte) (int, error) {
+	if len(r.pending) > 0 {
+		n := copy(p, r.pending)
+		r.pending = r.pending[n:]
+		return n, nil
+	}
+	return r.Conn.Read(p)
+}
+
+// WaitClosed watches the connection while a command blocks, the returned channel is closed when
+// the client closes the connection. The data sent by the client in the meantime is kept for the
+// next commands. stop must be called before reading the next command.
+func (c *Conn) WaitClosed() (<-chan struct{}, func()) {
+	if c.conn == nil {
+		return nil, func() {}
+	}
+	closed := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		buf := make([]byte, 512)
+		for {
+			n, err := c.conn.Conn.Read(buf)
+			c.conn.pending = append(c.conn.pending, buf[:n]...)
+			if err != nil {
+				var ne net.Error
+				if !errors.As(err, &ne) || !ne.Timeout() {
+					close(closed)
+				}
+				return
+			}
+		}
+	}()
+	return closed, func() {
+		_ = c.conn.SetReadDeadline(time.Now())
+		<-exited
+		_ = c.conn.SetReadDeadline(time.Time{})
+	}
 }
 
 func Serve(addr string, handler HandlerFunc) error {
@@ -48,14 +99,16 @@ func Serve(addr string, handler HandlerFunc) error {
 }
 
 func handleConn(conn net.Conn, handler HandlerFunc) {
+	r := &connReader{Conn: conn}
 	ClientLocker.Lock()
 	c := &Conn{
-		Fd:        len(Clients) + 1,
-		Reader:    NewReader(conn),
+		Fd:        int(lastFd.Add(1)),
+		Reader:    NewReader(r),
 		Writer:    NewWriter(conn),
 		Client:    conn,
 		Commands:  make([]func(), 0),
 		WatchKeys: make(map[string]bool),
+		conn:      r,
 	}
 	Clients[c.Fd] = c
 	ClientLocker.Unlock()
@@ -0,0 +1,350 @@
+package nodis
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/ds/str"
+	"github.com/diiyw/nodis/search"
+)
+
+var (
+	ErrFTIndexExists  = errors.New("Index already exists")
+	ErrFTUnknownIndex = errors.New("Unknown Index name")
+)
+
+// ftIndexKeyPrefix is the prefix of the storage keys holding the index definitions,
+// they are hidden from the keyspace.
+const ftIndexKeyPrefix = "\x00ft:"
+
+// FTSearchOptions are the options of FTSearch
+type FTSearchOptions struct {
+	search.Options
+	// NoContent only returns the keys of the documents
+	NoContent bool
+	// Return only returns the given hash fields
+	Return []string
+}
+
+// FTDocument is a document returned by FTSearch
+type FTDocument struct {
+	Key    string
+	Score  float64
+	Fields map[string][]byte
+}
+
+// FTSearchResult is the result of FTSearch
+type FTSearchResult struct {
+	Total int64
+	Docs  []*FTDocument
+}
+
+// FTAggregateRequest is the request of FTAggregate
+type FTAggregateRequest struct {
+	search.AggregateRequest
+	// Load loads hash fields that aren't in the schema
+	Load []string
+}
+
+// FTInfo is the information of an index
+type FTInfo struct {
+	Definition *search.Definition
+	NumDocs    int64
+	NumTerms   int64
+	NumRecords int64
+}
+
+// FTCreate creates an index over the hashes matching the definition and indexes the existing hashes
+func (n *Nodis) FTCreate(def *search.Definition) error {
+	n.indexesMu.Lock()
+	if _, ok := n.indexes[def.Name]; ok {
+		n.indexesMu.Unlock()
+		return ErrFTIndexExists
+	}
+	idx := search.NewIndex(def)
+	n.indexes[def.Name] = idx
+	n.saveIndex(idx)
+	n.indexesMu.Unlock()
+	n.buildIndexes(idx)
+	return nil
+}
+
+// FTDropIndex drops the index, the indexed hashes are deleted if deleteDocs is true
+func (n *Nodis) FTDropIndex(name string, deleteDocs bool) error {
+	n.indexesMu.Lock()
+	idx, ok := n.indexes[name]
+	if !ok {
+		n.indexesMu.Unlock()
+		return ErrFTUnknownIndex
+	}
+	delete(n.indexes, name)
+	err := n.store.ss.Delete(ds.NewKey(ftIndexKeyPrefix+name, 0))
+	if err != nil {
+		log.Println("Drop index: ", err)
+	}
+	keys := idx.Keys()
+	n.indexesMu.Unlock()
+	if deleteDocs {
+		n.Del(keys...)
+	}
+	return nil
+}
+
+// FTList returns the names of all the indexes
+func (n *Nodis) FTList() []string {
+	n.indexesMu.RLock()
+	defer n.indexesMu.RUnlock()
+	names := make([]string, 0, len(n.indexes))
+	for name := range n.indexes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// FTInfo returns the information of the index
+func (n *Nodis) FTInfo(name string) (*FTInfo, error) {
+	n.indexesMu.RLock()
+	defer n.indexesMu.RUnlock()
+	idx, ok := n.indexes[name]
+	if !ok {
+		return nil, ErrFTUnknownIndex
+	}
+	return &FTInfo{
+		Definition: idx.Definition(),
+		NumDocs:    idx.NumDocs(),
+		NumTerms:   idx.NumTerms(),
+		NumRecords: idx.NumRecords(),
+	}, nil
+}
+
+// FTSearch searches the index, the first 10 documents are returned if opts is nil
+func (n *Nodis) FTSearch(name, query string, opts *FTSearchOptions) (*FTSearchResult, error) {
+	if opts == nil {
+		opts = &FTSearchOptions{Options: search.Options{Limit: 10}}
+	}
+	n.indexesMu.RLock()
+	idx, ok := n.indexes[name]
+	if !ok {
+		n.indexesMu.RUnlock()
+		return nil, ErrFTUnknownIndex
+	}
+	r, err := idx.Search(query, opts.Options)
+	n.indexesMu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	result := &FTSearchResult{Total: r.Total, Docs: make([]*FTDocument, 0, len(r.Docs))}
+	for _, doc := range r.Docs {
+		fields, ok := n.loadIndexedHash(doc.Key, opts.Return)
+		if !ok {
+			result.Total--
+			continue
+		}
+		d := &FTDocument{Key: doc.Key, Score: doc.Score}
+		if !opts.NoContent {
+			d.Fields = fields
+		}
+		result.Docs = append(result.Docs, d)
+	}
+	return result, nil
+}
+
+// FTAggregate runs the query and aggregates the indexed values of the matched documents
+func (n *Nodis) FTAggregate(name, query string, req *FTAggregateRequest) ([]map[string]string, error) {
+	if req == nil {
+		req = &FTAggregateRequest{}
+	}
+	n.indexesMu.RLock()
+	idx, ok := n.indexes[name]
+	if !ok {
+		n.indexesMu.RUnlock()
+		return nil, ErrFTUnknownIndex
+	}
+	scores, err := idx.Match(query)
+	if err != nil {
+		n.indexesMu.RUnlock()
+		return nil, err
+	}
+	keys := make([]string, 0, len(scores))
+	for key := range scores {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	rows := make([]map[string]string, 0, len(keys))
+	for _, key := range keys {
+		values, _ := idx.Values(key)
+		row := make(map[string]string, len(values)+len(req.Load))
+		for field, value := range values {
+			row[field] = value
+		}
+		rows = append(rows, row)
+	}
+	n.indexesMu.RUnlock()
+	if len(req.Load) > 0 {
+		loaded := rows[:0]
+		for i, key := range keys {
+			fields, ok := n.loadIndexedHash(key, req.Load)
+			if !ok {
+				continue
+			}
+			for field, value := range fields {
+				rows[i][field] = string(value)
+			}
+			loaded = append(loaded, rows[i])
+		}
+		rows = loaded
+	}
+	return search.Aggregate(rows, &req.AggregateRequest), nil
+}
+
+// loadIndexedHash loads the fields of an indexed hash, all the fields are returned if fields is empty.
+// A hash that has expired in the meantime is removed from the indexes.
+func (n *Nodis) loadIndexedHash(key string, fields []string) (map[string][]byte, bool) {
+	var v map[string][]byte
+	var ok bool
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() || meta.valueType != ds.Hash {
+			n.indexHash(key, nil)
+			return nil
+		}
+		ok = true
+		h := meta.value.(*hash.HashMap)
+		if len(fields) == 0 {
+			v = h.HGetAll()
+			return nil
+		}
+		v = make(map[string][]byte, len(fields))
+		for _, field := range fields {
+			if value := h.HGet(field); value != nil {
+				v[field] = value
+			}
+		}
+		return nil
+	})
+	return v, ok
+}
+
+// indexHash updates the indexes after the hash stored at key is modified, a nil or empty
+// hash removes the key from the indexes. The caller must hold the lock of the key.
+func (n *Nodis) indexHash(key string, h *hash.HashMap) {
+	n.indexesMu.Lock()
+	defer n.indexesMu.Unlock()
+	var fields map[string][]byte
+	for _, idx := range n.indexes {
+		if h == nil || h.HLen() == 0 || !idx.MatchKey(key) {
+			idx.Remove(key)
+			continue
+		}
+		if fields == nil {
+			fields = h.HGetAll()
+		}
+		idx.Add(key, fields)
+	}
+}
+
+// saveIndex persists the definition of the index, the caller must hold indexesMu
+func (n *Nodis) saveIndex(idx *search.Index) {
+	def := idx.Definition()
+	v := str.NewString()
+	v.Set(def.Encode())
+	err := n.store.ss.Set(ds.NewKey(ftIndexKeyPrefix+def.Name, 0), v)
+	if err != nil {
+		log.Println("Save index: ", err)
+	}
+}
+
+// loadIndexes loads the persisted index definitions and rebuilds the indexes
+func (n *Nodis) loadIndexes() {
+	n.store.mu.Lock()
+	keys := make([]*ds.Key, 0)
+	for name, m := range n.store.metadata {
+		if strings.HasPrefix(name, ftIndexKeyPrefix) {
+			keys = append(keys, m.key)
+			delete(n.store.metadata, name)
+		}
+	}
+	n.store.mu.Unlock()
+	indexes := make([]*search.Index, 0, len(keys))
+	for _, key := range keys {
+		v, err := n.store.ss.Get(key)
+		if err != nil {
+			log.Println("Load index: ", err)
+			continue
+		}
+		s, ok := v.(*str.String)
+		if !ok {
+			continue
+		}
+		def, err := search.DecodeDefinition(s.Get())
+		if err != nil {
+			log.Println("Load index: ", err)
+			continue
+		}
+		idx := search.NewIndex(def)
+		n.indexes[def.Name] = idx
+		indexes = append(indexes, idx)
+	}
+	n.buildIndexes(indexes...)
+}
+
+// clearIndexes removes all the documents and persists the definitions again after the store is cleared
+func (n *Nodis) clearIndexes() {
+	n.indexesMu.Lock()
+	defer n.indexesMu.Unlock()
+	for _, idx := range n.indexes {
+		idx.Clear()
+		n.saveIndex(idx)
+	}
+}
+
+// buildIndexes indexes the existing hashes
+func (n *Nodis) buildIndexes(indexes ...*search.Index) {
+	if len(indexes) == 0 {
+		return
+	}
+	n.store.mu.RLock()
+	keys := make([]string, 0)
+	for key := range n.store.metadata {
+		for _, idx := range indexes {
+			if idx.MatchKey(key) {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	n.store.mu.RUnlock()
+	for _, key := range keys {
+		_ = n.exec(func(tx *Tx) error {
+			meta := tx.readKey(key)
+			if !meta.isOk() || meta.valueType != ds.Hash {
+				return nil
+			}
+			fields := meta.value.(*hash.HashMap).HGetAll()
+			n.indexesMu.Lock()
+			defer n.indexesMu.Unlock()
+			for _, idx := range indexes {
+				// skip the dropped indexes
+				if n.indexes[idx.Definition().Name] == idx && idx.MatchKey(key) {
+					idx.Add(key, fields)
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// indexRenamed moves the document of a renamed hash, the destination key is always overwritten
+func (n *Nodis) indexRenamed(key, dstKey string, meta, dstMeta *metadata) {
+	if meta.valueType != ds.Hash {
+		n.indexHash(dstKey, nil)
+		return
+	}
+	n.indexHash(key, nil)
+	n.indexHash(dstKey, dstMeta.value.(*hash.HashMap))
+}
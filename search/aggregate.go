@@ -0,0 +1,170 @@
+package search
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownReducer = errors.New("ERR Unknown reducer")
+)
+
+// Reducer reduces the rows of a group into a single value
+type Reducer struct {
+	// Func is one of COUNT, COUNT_DISTINCT, SUM, AVG, MIN and MAX
+	Func  string
+	Field string
+	// As is the name of the result, the function name by default
+	As string
+}
+
+// SortKey is a field to sort the rows by
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// AggregateRequest describes how rows are grouped, sorted and paged
+type AggregateRequest struct {
+	GroupBy  []string
+	Reducers []Reducer
+	SortBy   []SortKey
+	Offset   int
+	// Limit is the max number of returned rows, 0 returns all the rows
+	Limit int
+}
+
+// ParseReducer validates a reducer function name
+func ParseReducer(name string) (string, error) {
+	name = strings.ToUpper(name)
+	switch name {
+	case "COUNT", "COUNT_DISTINCT", "SUM", "AVG", "MIN", "MAX":
+		return name, nil
+	}
+	return "", ErrUnknownReducer
+}
+
+// Aggregate groups, reduces, sorts and pages the rows
+func Aggregate(rows []map[string]string, req *AggregateRequest) []map[string]string {
+	if len(req.GroupBy) > 0 || len(req.Reducers) > 0 {
+		rows = group(rows, req.GroupBy, req.Reducers)
+	}
+	if len(req.SortBy) > 0 {
+		slices.SortStableFunc(rows, func(a, b map[string]string) int {
+			for _, k := range req.SortBy {
+				c := compareValues(a[k.Field], b[k.Field])
+				if k.Desc {
+					c = -c
+				}
+				if c != 0 {
+					return c
+				}
+			}
+			return 0
+		})
+	}
+	start := min(max(req.Offset, 0), len(rows))
+	end := len(rows)
+	if req.Limit > 0 {
+		end = min(start+req.Limit, len(rows))
+	}
+	return rows[start:end]
+}
+
+func group(rows []map[string]string, fields []string, reducers []Reducer) []map[string]string {
+	groups := make(map[string][]map[string]string)
+	order := make([]string, 0)
+	for _, row := range rows {
+		values := make([]string, len(fields))
+		for i, f := range fields {
+			values[i] = row[f]
+		}
+		id := strings.Join(values, "\x00")
+		if _, ok := groups[id]; !ok {
+			order = append(order, id)
+		}
+		groups[id] = append(groups[id], row)
+	}
+	result := make([]map[string]string, 0, len(groups))
+	for _, id := range order {
+		members := groups[id]
+		row := make(map[string]string, len(fields)+len(reducers))
+		for _, f := range fields {
+			row[f] = members[0][f]
+		}
+		for _, r := range reducers {
+			name := r.As
+			if name == "" {
+				name = strings.ToLower(r.Func)
+			}
+			row[name] = reduce(members, r)
+		}
+		result = append(result, row)
+	}
+	return result
+}
+
+func reduce(rows []map[string]string, r Reducer) string {
+	switch r.Func {
+	case "COUNT":
+		return strconv.Itoa(len(rows))
+	case "COUNT_DISTINCT":
+		distinct := make(map[string]struct{})
+		for _, row := range rows {
+			if v, ok := row[r.Field]; ok {
+				distinct[v] = struct{}{}
+			}
+		}
+		return strconv.Itoa(len(distinct))
+	}
+	var sum float64
+	var count int
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	for _, row := range rows {
+		v, err := strconv.ParseFloat(row[r.Field], 64)
+		if err != nil {
+			continue
+		}
+		sum += v
+		count++
+		minValue = math.Min(minValue, v)
+		maxValue = math.Max(maxValue, v)
+	}
+	var v float64
+	switch r.Func {
+	case "SUM":
+		v = sum
+	case "AVG":
+		if count > 0 {
+			v = sum / float64(count)
+		}
+	case "MIN":
+		if count > 0 {
+			v = minValue
+		}
+	case "MAX":
+		if count > 0 {
+			v = maxValue
+		}
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// compareValues compares numerically when both values are numbers
+func compareValues(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,462 @@
+package search
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrUnknownFieldType = errors.New("ERR Invalid field type")
+	ErrCorruptedIndex   = errors.New("ERR corrupted index definition")
+)
+
+// FieldType is the type of an indexed hash field
+type FieldType uint8
+
+const (
+	Text FieldType = iota
+	Tag
+	Numeric
+	Geo
+)
+
+// ParseFieldType parses a field type name like "TEXT"
+func ParseFieldType(s string) (FieldType, error) {
+	switch strings.ToUpper(s) {
+	case "TEXT":
+		return Text, nil
+	case "TAG":
+		return Tag, nil
+	case "NUMERIC":
+		return Numeric, nil
+	case "GEO":
+		return Geo, nil
+	}
+	return Text, ErrUnknownFieldType
+}
+
+func (t FieldType) String() string {
+	switch t {
+	case Tag:
+		return "TAG"
+	case Numeric:
+		return "NUMERIC"
+	case Geo:
+		return "GEO"
+	}
+	return "TEXT"
+}
+
+// Field is a hash field of the schema
+type Field struct {
+	Name string
+	Type FieldType
+	// Weight multiplies the score of the terms of a text field, 1 by default
+	Weight float64
+	// Separator splits the values of a tag field, ',' by default
+	Separator byte
+	Sortable  bool
+}
+
+// Definition describes which hashes are indexed and how
+type Definition struct {
+	Name string
+	// Prefixes of the indexed keys, every key is indexed when empty
+	Prefixes []string
+	Fields   []Field
+}
+
+// Encode encodes the definition
+func (d *Definition) Encode() []byte {
+	b := appendString(nil, d.Name)
+	b = binary.AppendUvarint(b, uint64(len(d.Prefixes)))
+	for _, p := range d.Prefixes {
+		b = appendString(b, p)
+	}
+	b = binary.AppendUvarint(b, uint64(len(d.Fields)))
+	for _, f := range d.Fields {
+		b = appendString(b, f.Name)
+		b = append(b, byte(f.Type), f.Separator)
+		if f.Sortable {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f.Weight))
+	}
+	return b
+}
+
+// DecodeDefinition decodes a definition encoded by Encode
+func DecodeDefinition(b []byte) (*Definition, error) {
+	d := &Definition{}
+	var ok bool
+	if d.Name, b, ok = readString(b); !ok {
+		return nil, ErrCorruptedIndex
+	}
+	n, c := binary.Uvarint(b)
+	if c <= 0 {
+		return nil, ErrCorruptedIndex
+	}
+	b = b[c:]
+	for ; n > 0; n-- {
+		var p string
+		if p, b, ok = readString(b); !ok {
+			return nil, ErrCorruptedIndex
+		}
+		d.Prefixes = append(d.Prefixes, p)
+	}
+	n, c = binary.Uvarint(b)
+	if c <= 0 {
+		return nil, ErrCorruptedIndex
+	}
+	b = b[c:]
+	for ; n > 0; n-- {
+		var f Field
+		if f.Name, b, ok = readString(b); !ok || len(b) < 11 {
+			return nil, ErrCorruptedIndex
+		}
+		f.Type = FieldType(b[0])
+		f.Separator = b[1]
+		f.Sortable = b[2] == 1
+		f.Weight = math.Float64frombits(binary.LittleEndian.Uint64(b[3:]))
+		b = b[11:]
+		d.Fields = append(d.Fields, f)
+	}
+	return d, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func readString(b []byte) (string, []byte, bool) {
+	n, c := binary.Uvarint(b)
+	if c <= 0 || uint64(len(b)-c) < n {
+		return "", nil, false
+	}
+	return string(b[c : c+int(n)]), b[c+int(n):], true
+}
+
+// numericEntry is a value of a numeric field
+type numericEntry struct {
+	value float64
+	key   string
+}
+
+func compareNumericEntry(a, b numericEntry) int {
+	if a.value < b.value {
+		return -1
+	}
+	if a.value > b.value {
+		return 1
+	}
+	return strings.Compare(a.key, b.key)
+}
+
+// numericIndex keeps the values of a numeric field sorted
+type numericIndex struct {
+	entries []numericEntry
+}
+
+func (n *numericIndex) add(e numericEntry) {
+	i, found := slices.BinarySearchFunc(n.entries, e, compareNumericEntry)
+	if !found {
+		n.entries = slices.Insert(n.entries, i, e)
+	}
+}
+
+func (n *numericIndex) remove(e numericEntry) {
+	i, found := slices.BinarySearchFunc(n.entries, e, compareNumericEntry)
+	if found {
+		n.entries = slices.Delete(n.entries, i, i+1)
+	}
+}
+
+// rangeKeys returns the keys whose value is between min and max
+func (n *numericIndex) rangeKeys(min, max float64, minExclusive, maxExclusive bool) []string {
+	i, _ := slices.BinarySearchFunc(n.entries, min, func(e numericEntry, v float64) int {
+		if e.value < v || (minExclusive && e.value == v) {
+			return -1
+		}
+		return 1
+	})
+	keys := make([]string, 0)
+	for ; i < len(n.entries); i++ {
+		v := n.entries[i].value
+		if v > max || (maxExclusive && v == max) {
+			break
+		}
+		keys = append(keys, n.entries[i].key)
+	}
+	return keys
+}
+
+type point struct {
+	lon, lat float64
+}
+
+// Index is an inverted index over hashes, it isn't safe for concurrent use
+type Index struct {
+	def     *Definition
+	fields  map[string]*Field
+	docs    map[string]map[string]string              // key -> indexed field values
+	text    map[string]map[string]map[string]int      // field -> term -> key -> frequency
+	tags    map[string]map[string]map[string]struct{} // field -> tag -> keys
+	numeric map[string]*numericIndex
+	geo     map[string]map[string]point
+}
+
+// NewIndex creates an empty index
+func NewIndex(def *Definition) *Index {
+	idx := &Index{
+		def:     def,
+		fields:  make(map[string]*Field),
+		docs:    make(map[string]map[string]string),
+		text:    make(map[string]map[string]map[string]int),
+		tags:    make(map[string]map[string]map[string]struct{}),
+		numeric: make(map[string]*numericIndex),
+		geo:     make(map[string]map[string]point),
+	}
+	for i := range def.Fields {
+		f := &def.Fields[i]
+		if f.Weight == 0 {
+			f.Weight = 1
+		}
+		if f.Separator == 0 {
+			f.Separator = ','
+		}
+		idx.fields[f.Name] = f
+		switch f.Type {
+		case Text:
+			idx.text[f.Name] = make(map[string]map[string]int)
+		case Tag:
+			idx.tags[f.Name] = make(map[string]map[string]struct{})
+		case Numeric:
+			idx.numeric[f.Name] = &numericIndex{}
+		case Geo:
+			idx.geo[f.Name] = make(map[string]point)
+		}
+	}
+	return idx
+}
+
+// Definition returns the definition of the index
+func (idx *Index) Definition() *Definition {
+	return idx.def
+}
+
+// Field returns the schema field by name
+func (idx *Index) Field(name string) (*Field, bool) {
+	f, ok := idx.fields[name]
+	return f, ok
+}
+
+// MatchKey returns whether the key is covered by the index
+func (idx *Index) MatchKey(key string) bool {
+	if len(idx.def.Prefixes) == 0 {
+		return true
+	}
+	for _, p := range idx.def.Prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add indexes the hash stored at key, replacing the previous document
+func (idx *Index) Add(key string, hash map[string][]byte) {
+	idx.Remove(key)
+	values := make(map[string]string)
+	for name, f := range idx.fields {
+		raw, ok := hash[name]
+		if !ok {
+			continue
+		}
+		value := string(raw)
+		switch f.Type {
+		case Text:
+			for _, term := range Tokenize(value) {
+				postings, ok := idx.text[name][term]
+				if !ok {
+					postings = make(map[string]int)
+					idx.text[name][term] = postings
+				}
+				postings[key]++
+			}
+		case Tag:
+			for _, tag := range splitTags(value, f.Separator) {
+				keys, ok := idx.tags[name][tag]
+				if !ok {
+					keys = make(map[string]struct{})
+					idx.tags[name][tag] = keys
+				}
+				keys[key] = struct{}{}
+			}
+		case Numeric:
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			idx.numeric[name].add(numericEntry{value: v, key: key})
+		case Geo:
+			p, ok := parsePoint(value)
+			if !ok {
+				continue
+			}
+			idx.geo[name][key] = p
+		}
+		values[name] = value
+	}
+	idx.docs[key] = values
+}
+
+// Remove removes the document of the key
+func (idx *Index) Remove(key string) bool {
+	values, ok := idx.docs[key]
+	if !ok {
+		return false
+	}
+	delete(idx.docs, key)
+	for name, value := range values {
+		f := idx.fields[name]
+		switch f.Type {
+		case Text:
+			for _, term := range Tokenize(value) {
+				postings := idx.text[name][term]
+				delete(postings, key)
+				if len(postings) == 0 {
+					delete(idx.text[name], term)
+				}
+			}
+		case Tag:
+			for _, tag := range splitTags(value, f.Separator) {
+				keys := idx.tags[name][tag]
+				delete(keys, key)
+				if len(keys) == 0 {
+					delete(idx.tags[name], tag)
+				}
+			}
+		case Numeric:
+			v, err := strconv.ParseFloat(value, 64)
+			if err == nil {
+				idx.numeric[name].remove(numericEntry{value: v, key: key})
+			}
+		case Geo:
+			delete(idx.geo[name], key)
+		}
+	}
+	return true
+}
+
+// Clear removes all the documents
+func (idx *Index) Clear() {
+	*idx = *NewIndex(idx.def)
+}
+
+// Values returns the indexed field values of the document
+func (idx *Index) Values(key string) (map[string]string, bool) {
+	values, ok := idx.docs[key]
+	return values, ok
+}
+
+// Keys returns the keys of all the documents
+func (idx *Index) Keys() []string {
+	keys := make([]string, 0, len(idx.docs))
+	for key := range idx.docs {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// NumDocs returns the number of indexed documents
+func (idx *Index) NumDocs() int64 {
+	return int64(len(idx.docs))
+}
+
+// NumTerms returns the number of distinct terms of the text fields
+func (idx *Index) NumTerms() int64 {
+	var n int64
+	for _, terms := range idx.text {
+		n += int64(len(terms))
+	}
+	return n
+}
+
+// NumRecords returns the number of records of the inverted indexes
+func (idx *Index) NumRecords() int64 {
+	var n int64
+	for _, terms := range idx.text {
+		for _, postings := range terms {
+			n += int64(len(postings))
+		}
+	}
+	for _, tags := range idx.tags {
+		for _, keys := range tags {
+			n += int64(len(keys))
+		}
+	}
+	for _, num := range idx.numeric {
+		n += int64(len(num.entries))
+	}
+	for _, points := range idx.geo {
+		n += int64(len(points))
+	}
+	return n
+}
+
+var stopWords = map[string]struct{}{
+	"a": {}, "is": {}, "the": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {}, "but": {},
+	"by": {}, "for": {}, "if": {}, "in": {}, "into": {}, "it": {}, "no": {}, "not": {}, "of": {}, "on": {},
+	"or": {}, "such": {}, "that": {}, "their": {}, "then": {}, "there": {}, "these": {}, "they": {},
+	"this": {}, "to": {}, "was": {}, "will": {}, "with": {},
+}
+
+// Tokenize splits a text into lower case terms without stop words
+func Tokenize(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	terms := words[:0]
+	for _, w := range words {
+		if _, ok := stopWords[w]; !ok {
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+func splitTags(s string, sep byte) []string {
+	tags := make([]string, 0)
+	for _, t := range strings.Split(s, string(sep)) {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// parsePoint parses a "longitude,latitude" value
+func parsePoint(s string) (point, bool) {
+	lon, lat, ok := strings.Cut(s, ",")
+	if !ok {
+		return point{}, false
+	}
+	x, err := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	if err != nil {
+		return point{}, false
+	}
+	y, err := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	if err != nil {
+		return point{}, false
+	}
+	return point{lon: x, lat: y}, true
+}
@@ -0,0 +1,539 @@
+package search
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/diiyw/nodis/internal/geohash"
+)
+
+var (
+	ErrSyntax       = errors.New("ERR Syntax error in query")
+	ErrUnknownField = errors.New("ERR Unknown field in query")
+)
+
+// Options are the paging and sorting options of a search
+type Options struct {
+	Offset int
+	// Limit is the max number of returned documents, 0 only counts the matches
+	Limit int
+	// SortBy sorts by the value of a schema field instead of the score
+	SortBy string
+	Desc   bool
+}
+
+// Document is a matched document
+type Document struct {
+	Key   string
+	Score float64
+}
+
+// Result is the result of a search
+type Result struct {
+	Total int64
+	Docs  []Document
+}
+
+// Search runs the query and returns a page of the matched documents.
+//
+// The query language supports terms, prefixes (hel*), quoted phrases ("hello world"),
+// intersections (a b), unions (a | b), negations (-a), grouping with parentheses and
+// field modifiers: @title:hello, @tags:{a | b}, @age:[10 (20] and @loc:[lon lat radius m|km|mi|ft].
+func (idx *Index) Search(query string, opts Options) (*Result, error) {
+	scores, err := idx.Match(query)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]Document, 0, len(scores))
+	for key, score := range scores {
+		docs = append(docs, Document{Key: key, Score: score})
+	}
+	idx.sort(docs, opts.SortBy, opts.Desc)
+	result := &Result{Total: int64(len(docs))}
+	start := min(max(opts.Offset, 0), len(docs))
+	end := min(start+max(opts.Limit, 0), len(docs))
+	result.Docs = docs[start:end]
+	return result, nil
+}
+
+// Match returns the scores of all the documents matching the query
+func (idx *Index) Match(query string) (map[string]float64, error) {
+	p := &parser{s: query, idx: idx}
+	p.skipSpace()
+	if p.pos == len(p.s) {
+		return nil, ErrSyntax
+	}
+	n, err := p.parseUnion("")
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, ErrSyntax
+	}
+	if n == nil {
+		return map[string]float64{}, nil
+	}
+	return n.eval(idx), nil
+}
+
+func (idx *Index) sort(docs []Document, field string, desc bool) {
+	f, ok := idx.fields[field]
+	if !ok {
+		slices.SortFunc(docs, func(a, b Document) int {
+			if a.Score != b.Score {
+				if a.Score > b.Score {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(a.Key, b.Key)
+		})
+		return
+	}
+	slices.SortFunc(docs, func(a, b Document) int {
+		va, oka := idx.docs[a.Key][field]
+		vb, okb := idx.docs[b.Key][field]
+		// documents without the field are always last
+		if oka != okb {
+			if oka {
+				return -1
+			}
+			return 1
+		}
+		c := 0
+		if f.Type == Numeric {
+			x, _ := strconv.ParseFloat(va, 64)
+			y, _ := strconv.ParseFloat(vb, 64)
+			if x < y {
+				c = -1
+			} else if x > y {
+				c = 1
+			}
+		} else {
+			c = strings.Compare(strings.ToLower(va), strings.ToLower(vb))
+		}
+		if desc {
+			c = -c
+		}
+		if c == 0 {
+			return strings.Compare(a.Key, b.Key)
+		}
+		return c
+	})
+}
+
+// node is a node of the parsed query
+type node interface {
+	eval(idx *Index) map[string]float64
+}
+
+type termNode struct {
+	field  string
+	term   string
+	prefix bool
+}
+
+func (n *termNode) eval(idx *Index) map[string]float64 {
+	scores := make(map[string]float64)
+	total := float64(len(idx.docs))
+	for name, terms := range idx.text {
+		if n.field != "" && n.field != name {
+			continue
+		}
+		weight := idx.fields[name].Weight
+		add := func(postings map[string]int) {
+			idf := math.Log(1 + total/float64(len(postings)))
+			for key, tf := range postings {
+				scores[key] += weight * float64(tf) * idf
+			}
+		}
+		if !n.prefix {
+			if postings, ok := terms[n.term]; ok {
+				add(postings)
+			}
+			continue
+		}
+		for term, postings := range terms {
+			if strings.HasPrefix(term, n.term) {
+				add(postings)
+			}
+		}
+	}
+	return scores
+}
+
+type tagNode struct {
+	field string
+	tags  []string
+}
+
+func (n *tagNode) eval(idx *Index) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, tag := range n.tags {
+		for key := range idx.tags[n.field][tag] {
+			scores[key] = 1
+		}
+	}
+	return scores
+}
+
+type numericNode struct {
+	field                      string
+	min, max                   float64
+	minExclusive, maxExclusive bool
+}
+
+func (n *numericNode) eval(idx *Index) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, key := range idx.numeric[n.field].rangeKeys(n.min, n.max, n.minExclusive, n.maxExclusive) {
+		scores[key] = 1
+	}
+	return scores
+}
+
+type geoNode struct {
+	field  string
+	center point
+	radius float64 // meters
+}
+
+func (n *geoNode) eval(idx *Index) map[string]float64 {
+	scores := make(map[string]float64)
+	for key, p := range idx.geo[n.field] {
+		if geohash.GetDistance(n.center.lon, n.center.lat, p.lon, p.lat) <= n.radius {
+			scores[key] = 1
+		}
+	}
+	return scores
+}
+
+type allNode struct{}
+
+func (n *allNode) eval(idx *Index) map[string]float64 {
+	scores := make(map[string]float64, len(idx.docs))
+	for key := range idx.docs {
+		scores[key] = 1
+	}
+	return scores
+}
+
+type notNode struct {
+	child node
+}
+
+func (n *notNode) eval(idx *Index) map[string]float64 {
+	excluded := n.child.eval(idx)
+	scores := make(map[string]float64)
+	for key := range idx.docs {
+		if _, ok := excluded[key]; !ok {
+			scores[key] = 1
+		}
+	}
+	return scores
+}
+
+type intersectNode struct {
+	children []node
+}
+
+func (n *intersectNode) eval(idx *Index) map[string]float64 {
+	scores := n.children[0].eval(idx)
+	for _, child := range n.children[1:] {
+		other := child.eval(idx)
+		for key, score := range scores {
+			s, ok := other[key]
+			if !ok {
+				delete(scores, key)
+				continue
+			}
+			scores[key] = score + s
+		}
+	}
+	return scores
+}
+
+type unionNode struct {
+	children []node
+}
+
+func (n *unionNode) eval(idx *Index) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, child := range n.children {
+		for key, score := range child.eval(idx) {
+			scores[key] += score
+		}
+	}
+	return scores
+}
+
+// parser is a recursive descent parser of the query language
+type parser struct {
+	s   string
+	pos int
+	idx *Index
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) peek() byte {
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *parser) expect(c byte) error {
+	p.skipSpace()
+	if p.peek() != c {
+		return ErrSyntax
+	}
+	p.pos++
+	return nil
+}
+
+// parseUnion parses intersections separated by '|', field scopes the terms
+func (p *parser) parseUnion(field string) (node, error) {
+	children := make([]node, 0, 1)
+	for {
+		n, err := p.parseIntersect(field)
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			children = append(children, n)
+		}
+		p.skipSpace()
+		if p.peek() != '|' {
+			break
+		}
+		p.pos++
+	}
+	switch len(children) {
+	case 0:
+		return nil, nil
+	case 1:
+		return children[0], nil
+	}
+	return &unionNode{children: children}, nil
+}
+
+func (p *parser) parseIntersect(field string) (node, error) {
+	children := make([]node, 0, 1)
+	var parsed bool
+	for {
+		p.skipSpace()
+		c := p.peek()
+		if c == 0 || c == ')' || c == '|' {
+			break
+		}
+		n, err := p.parseUnary(field)
+		if err != nil {
+			return nil, err
+		}
+		parsed = true
+		if n != nil {
+			children = append(children, n)
+		}
+	}
+	if !parsed {
+		return nil, ErrSyntax
+	}
+	switch len(children) {
+	case 0:
+		// only stop words
+		return nil, nil
+	case 1:
+		return children[0], nil
+	}
+	return &intersectNode{children: children}, nil
+}
+
+func (p *parser) parseUnary(field string) (node, error) {
+	if p.peek() != '-' {
+		return p.parseAtom(field)
+	}
+	p.pos++
+	n, err := p.parseAtom(field)
+	if err != nil || n == nil {
+		return nil, err
+	}
+	return &notNode{child: n}, nil
+}
+
+func (p *parser) parseAtom(field string) (node, error) {
+	switch p.peek() {
+	case '(':
+		p.pos++
+		n, err := p.parseUnion(field)
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(')')
+	case '@':
+		p.pos++
+		return p.parseField()
+	case '"':
+		p.pos++
+		end := strings.IndexByte(p.s[p.pos:], '"')
+		if end < 0 {
+			return nil, ErrSyntax
+		}
+		phrase := p.s[p.pos : p.pos+end]
+		p.pos += end + 1
+		return termsNode(field, Tokenize(phrase)), nil
+	case '*':
+		p.pos++
+		return &allNode{}, nil
+	}
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(" \t|()@\"{}[]", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	word := p.s[start:p.pos]
+	if word == "" {
+		return nil, ErrSyntax
+	}
+	if strings.HasSuffix(word, "*") {
+		prefix := strings.ToLower(strings.TrimRight(word, "*"))
+		if prefix == "" {
+			return nil, ErrSyntax
+		}
+		return &termNode{field: field, term: prefix, prefix: true}, nil
+	}
+	return termsNode(field, Tokenize(word)), nil
+}
+
+// termsNode builds the intersection of the terms, nil if there is no term
+func termsNode(field string, terms []string) node {
+	switch len(terms) {
+	case 0:
+		return nil
+	case 1:
+		return &termNode{field: field, term: terms[0]}
+	}
+	children := make([]node, len(terms))
+	for i, term := range terms {
+		children[i] = &termNode{field: field, term: term}
+	}
+	return &intersectNode{children: children}
+}
+
+func (p *parser) parseField() (node, error) {
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] != ':' && p.s[p.pos] != ' ' {
+		p.pos++
+	}
+	name := p.s[start:p.pos]
+	if p.peek() != ':' {
+		return nil, ErrSyntax
+	}
+	p.pos++
+	f, ok := p.idx.fields[name]
+	if !ok {
+		return nil, ErrUnknownField
+	}
+	p.skipSpace()
+	switch f.Type {
+	case Tag:
+		if p.peek() != '{' {
+			return nil, ErrSyntax
+		}
+		p.pos++
+		end := strings.IndexByte(p.s[p.pos:], '}')
+		if end < 0 {
+			return nil, ErrSyntax
+		}
+		tags := make([]string, 0)
+		for _, t := range strings.Split(p.s[p.pos:p.pos+end], "|") {
+			t = strings.ToLower(strings.TrimSpace(t))
+			if t != "" {
+				tags = append(tags, t)
+			}
+		}
+		p.pos += end + 1
+		return &tagNode{field: name, tags: tags}, nil
+	case Numeric, Geo:
+		if p.peek() != '[' {
+			return nil, ErrSyntax
+		}
+		p.pos++
+		end := strings.IndexByte(p.s[p.pos:], ']')
+		if end < 0 {
+			return nil, ErrSyntax
+		}
+		args := strings.Fields(p.s[p.pos : p.pos+end])
+		p.pos += end + 1
+		if f.Type == Numeric {
+			return parseNumericRange(name, args)
+		}
+		return parseGeoFilter(name, args)
+	}
+	return p.parseAtom(name)
+}
+
+func parseNumericRange(field string, args []string) (node, error) {
+	if len(args) != 2 {
+		return nil, ErrSyntax
+	}
+	n := &numericNode{field: field}
+	var err error
+	if n.min, n.minExclusive, err = parseBound(args[0]); err != nil {
+		return nil, err
+	}
+	if n.max, n.maxExclusive, err = parseBound(args[1]); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// parseBound parses a numeric bound like "10", "(10" or "-inf"
+func parseBound(s string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(s, "(")
+	s = strings.TrimPrefix(s, "(")
+	switch strings.ToLower(s) {
+	case "-inf":
+		return math.Inf(-1), exclusive, nil
+	case "+inf", "inf":
+		return math.Inf(1), exclusive, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false, ErrSyntax
+	}
+	return v, exclusive, nil
+}
+
+func parseGeoFilter(field string, args []string) (node, error) {
+	if len(args) != 4 {
+		return nil, ErrSyntax
+	}
+	var values [3]float64
+	for i := range values {
+		v, err := strconv.ParseFloat(args[i], 64)
+		if err != nil {
+			return nil, ErrSyntax
+		}
+		values[i] = v
+	}
+	radius := values[2]
+	switch strings.ToLower(args[3]) {
+	case "m":
+	case "km":
+		radius *= 1000
+	case "mi":
+		radius *= 1609.34
+	case "ft":
+		radius *= 0.3048
+	default:
+		return nil, ErrSyntax
+	}
+	return &geoNode{field: field, center: point{lon: values[0], lat: values[1]}, radius: radius}, nil
+}
@@ -0,0 +1,165 @@
+package search
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func newTestIndex() *Index {
+	idx := NewIndex(&Definition{
+		Name:     "users",
+		Prefixes: []string{"user:"},
+		Fields: []Field{
+			{Name: "name", Type: Text, Weight: 2},
+			{Name: "bio", Type: Text},
+			{Name: "tags", Type: Tag},
+			{Name: "age", Type: Numeric, Sortable: true},
+			{Name: "loc", Type: Geo},
+		},
+	})
+	idx.Add("user:1", map[string][]byte{"name": []byte("John Smith"), "bio": []byte("Go developer and runner"), "tags": []byte("go,Running"), "age": []byte("30"), "loc": []byte("13.361389,38.115556")})
+	idx.Add("user:2", map[string][]byte{"name": []byte("Jane Doe"), "bio": []byte("Rust developer"), "tags": []byte("rust"), "age": []byte("25"), "loc": []byte("15.087269,37.502669")})
+	idx.Add("user:3", map[string][]byte{"name": []byte("Johnny Walker"), "bio": []byte("Whisky"), "tags": []byte("drinks, go"), "age": []byte("40")})
+	return idx
+}
+
+func matchKeys(t *testing.T, idx *Index, query string) []string {
+	scores, err := idx.Match(query)
+	if err != nil {
+		t.Fatalf("Match(%q) error = %v", query, err)
+	}
+	keys := make([]string, 0, len(scores))
+	for key := range scores {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestIndex_Match(t *testing.T) {
+	idx := newTestIndex()
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"developer", []string{"user:1", "user:2"}},
+		{"developer -rust", []string{"user:1"}},
+		{"john*", []string{"user:1", "user:3"}},
+		{"john | jane", []string{"user:1", "user:2"}},
+		{"@name:john", []string{"user:1"}},
+		{"@bio:(whisky | rust)", []string{"user:2", "user:3"}},
+		{`"go developer"`, []string{"user:1"}},
+		{"@tags:{go}", []string{"user:1", "user:3"}},
+		{"@tags:{RUST | drinks}", []string{"user:2", "user:3"}},
+		{"@age:[25 30]", []string{"user:1", "user:2"}},
+		{"@age:[(25 +inf]", []string{"user:1", "user:3"}},
+		{"@loc:[15 37 200 km]", []string{"user:1", "user:2"}},
+		{"@loc:[15 37 100 km]", []string{"user:2"}},
+		{"(developer | whisky) @age:[35 inf]", []string{"user:3"}},
+		{"*", []string{"user:1", "user:2", "user:3"}},
+		{"the", []string{}},
+	}
+	for _, tt := range tests {
+		if got := matchKeys(t, idx, tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Match(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+	for _, query := range []string{"", "@none:x", "(a", "@age:[1]", "@tags:go"} {
+		if _, err := idx.Match(query); err == nil {
+			t.Errorf("Match(%q) error = nil, want error", query)
+		}
+	}
+}
+
+func TestIndex_Search(t *testing.T) {
+	idx := newTestIndex()
+	result, _ := idx.Search("*", Options{Limit: 2, SortBy: "age", Desc: true})
+	if result.Total != 3 || len(result.Docs) != 2 || result.Docs[0].Key != "user:3" || result.Docs[1].Key != "user:1" {
+		t.Errorf("Search() = %+v", result)
+	}
+	result, _ = idx.Search("*", Options{Offset: 2, Limit: 10, SortBy: "age"})
+	if len(result.Docs) != 1 || result.Docs[0].Key != "user:3" {
+		t.Errorf("Search() = %+v", result)
+	}
+	// name has a higher weight than bio
+	idx.Add("user:4", map[string][]byte{"bio": []byte("john")})
+	result, _ = idx.Search("john", Options{Limit: 10})
+	if len(result.Docs) != 2 || result.Docs[0].Key != "user:1" || result.Docs[0].Score <= result.Docs[1].Score {
+		t.Errorf("Search() = %+v", result)
+	}
+}
+
+func TestIndex_Remove(t *testing.T) {
+	idx := newTestIndex()
+	if !idx.Remove("user:1") || idx.Remove("user:1") {
+		t.Errorf("Remove() failed")
+	}
+	if got := matchKeys(t, idx, "developer"); !reflect.DeepEqual(got, []string{"user:2"}) {
+		t.Errorf("Match() = %v, want %v", got, []string{"user:2"})
+	}
+	if got := matchKeys(t, idx, "@age:[-inf +inf]"); !reflect.DeepEqual(got, []string{"user:2", "user:3"}) {
+		t.Errorf("Match() = %v, want %v", got, []string{"user:2", "user:3"})
+	}
+	idx.Add("user:2", map[string][]byte{"name": []byte("Jane")})
+	if got := matchKeys(t, idx, "developer"); len(got) != 0 {
+		t.Errorf("Match() = %v, want none", got)
+	}
+	if idx.NumDocs() != 2 {
+		t.Errorf("NumDocs() = %v, want %v", idx.NumDocs(), 2)
+	}
+	idx.Clear()
+	if idx.NumDocs() != 0 || idx.NumRecords() != 0 {
+		t.Errorf("Clear() failed")
+	}
+}
+
+func TestIndex_MatchKey(t *testing.T) {
+	idx := newTestIndex()
+	if !idx.MatchKey("user:9") || idx.MatchKey("item:1") {
+		t.Errorf("MatchKey() failed")
+	}
+	if !NewIndex(&Definition{}).MatchKey("anything") {
+		t.Errorf("MatchKey() without prefixes = false, want true")
+	}
+}
+
+func TestDefinition_EncodeDecode(t *testing.T) {
+	def := newTestIndex().Definition()
+	got, err := DecodeDefinition(def.Encode())
+	if err != nil {
+		t.Fatalf("DecodeDefinition() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, def) {
+		t.Errorf("DecodeDefinition() = %+v, want %+v", got, def)
+	}
+	if _, err := DecodeDefinition([]byte{10}); err != ErrCorruptedIndex {
+		t.Errorf("DecodeDefinition() error = %v, want %v", err, ErrCorruptedIndex)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	rows := []map[string]string{
+		{"city": "a", "age": "10"},
+		{"city": "b", "age": "20"},
+		{"city": "a", "age": "30"},
+		{"city": "c", "age": "5"},
+	}
+	got := Aggregate(rows, &AggregateRequest{
+		GroupBy: []string{"city"},
+		Reducers: []Reducer{
+			{Func: "COUNT", As: "n"},
+			{Func: "AVG", Field: "age"},
+			{Func: "MAX", Field: "age", As: "oldest"},
+		},
+		SortBy: []SortKey{{Field: "n", Desc: true}, {Field: "city"}},
+		Limit:  2,
+	})
+	want := []map[string]string{
+		{"city": "a", "n": "2", "avg": "20", "oldest": "30"},
+		{"city": "b", "n": "1", "avg": "20", "oldest": "20"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Aggregate() = %v, want %v", got, want)
+	}
+}
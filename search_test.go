@@ -0,0 +1,174 @@
+package nodis
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/diiyw/nodis/search"
+	"github.com/diiyw/nodis/storage"
+)
+
+func newSearchDefinition() *search.Definition {
+	return &search.Definition{
+		Name:     "users",
+		Prefixes: []string{"user:"},
+		Fields: []search.Field{
+			{Name: "name", Type: search.Text},
+			{Name: "city", Type: search.Tag},
+			{Name: "age", Type: search.Numeric, Sortable: true},
+		},
+	}
+}
+
+func searchKeys(t *testing.T, n *Nodis, query string) []string {
+	result, err := n.FTSearch("users", query, &FTSearchOptions{Options: search.Options{Limit: 10, SortBy: "age"}, NoContent: true})
+	if err != nil {
+		t.Fatalf("FTSearch(%q) error = %v", query, err)
+	}
+	keys := make([]string, 0, len(result.Docs))
+	for _, doc := range result.Docs {
+		keys = append(keys, doc.Key)
+	}
+	return keys
+}
+
+func TestNodis_FTCreate(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	n.HMSet("user:1", map[string][]byte{"name": []byte("John Smith"), "city": []byte("London"), "age": []byte("30")})
+	n.HSet("item:1", "name", []byte("John"))
+	if err := n.FTCreate(newSearchDefinition()); err != nil {
+		t.Fatalf("FTCreate() error = %v", err)
+	}
+	if err := n.FTCreate(newSearchDefinition()); err != ErrFTIndexExists {
+		t.Errorf("FTCreate() error = %v, want %v", err, ErrFTIndexExists)
+	}
+	if got := searchKeys(t, n, "john"); len(got) != 1 || got[0] != "user:1" {
+		t.Errorf("FTSearch() = %v, want %v", got, []string{"user:1"})
+	}
+	if got := n.FTList(); len(got) != 1 || got[0] != "users" {
+		t.Errorf("FTList() = %v, want %v", got, []string{"users"})
+	}
+}
+
+func TestNodis_FTSearchWritePaths(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	_ = n.FTCreate(newSearchDefinition())
+	n.HSet("user:1", "name", []byte("John"))
+	n.HSet("user:1", "age", []byte("30"))
+	n.HSetNX("user:2", "name", []byte("Jane"))
+	_, _ = n.HIncrBy("user:2", "age", 25)
+	n.HMSet("user:3", map[string][]byte{"name": []byte("Bob"), "city": []byte("Paris")})
+	if got := searchKeys(t, n, "@age:[20 40]"); len(got) != 2 || got[0] != "user:2" || got[1] != "user:1" {
+		t.Errorf("FTSearch() = %v, want %v", got, []string{"user:2", "user:1"})
+	}
+	_, _ = n.HIncrByFloat("user:2", "age", 10.5)
+	if got := searchKeys(t, n, "@age:[20 30]"); len(got) != 1 || got[0] != "user:1" {
+		t.Errorf("FTSearch() = %v, want %v", got, []string{"user:1"})
+	}
+	n.HDel("user:1", "name")
+	if got := searchKeys(t, n, "john"); len(got) != 0 {
+		t.Errorf("FTSearch() = %v, want none", got)
+	}
+	n.HDel("user:1", "age")
+	n.Del("user:2")
+	if got := searchKeys(t, n, "*"); len(got) != 1 || got[0] != "user:3" {
+		t.Errorf("FTSearch() = %v, want %v", got, []string{"user:3"})
+	}
+	_ = n.Rename("user:3", "user:4")
+	if got := searchKeys(t, n, "@city:{paris}"); len(got) != 1 || got[0] != "user:4" {
+		t.Errorf("FTSearch() = %v, want %v", got, []string{"user:4"})
+	}
+	_ = n.Rename("user:4", "other:4")
+	if got := searchKeys(t, n, "*"); len(got) != 0 {
+		t.Errorf("FTSearch() = %v, want none", got)
+	}
+	n.HSet("user:5", "name", []byte("Expired"))
+	n.ExpirePX("user:5", 1)
+	time.Sleep(5 * time.Millisecond)
+	result, _ := n.FTSearch("users", "expired", nil)
+	if result.Total != 0 || len(result.Docs) != 0 {
+		t.Errorf("FTSearch() = %+v, want none", result)
+	}
+	if info, _ := n.FTInfo("users"); info.NumDocs != 0 {
+		t.Errorf("FTInfo().NumDocs = %v, want %v", info.NumDocs, 0)
+	}
+}
+
+func TestNodis_FTAggregate(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	_ = n.FTCreate(newSearchDefinition())
+	n.HMSet("user:1", map[string][]byte{"city": []byte("London"), "age": []byte("30"), "email": []byte("a@b.c")})
+	n.HMSet("user:2", map[string][]byte{"city": []byte("London"), "age": []byte("20")})
+	n.HMSet("user:3", map[string][]byte{"city": []byte("Paris"), "age": []byte("40")})
+	rows, err := n.FTAggregate("users", "*", &FTAggregateRequest{AggregateRequest: search.AggregateRequest{
+		GroupBy:  []string{"city"},
+		Reducers: []search.Reducer{{Func: "COUNT", As: "count"}, {Func: "AVG", Field: "age", As: "age"}},
+		SortBy:   []search.SortKey{{Field: "count", Desc: true}},
+	}})
+	if err != nil {
+		t.Fatalf("FTAggregate() error = %v", err)
+	}
+	if len(rows) != 2 || rows[0]["city"] != "London" || rows[0]["count"] != "2" || rows[0]["age"] != "25" || rows[1]["city"] != "Paris" {
+		t.Errorf("FTAggregate() = %v", rows)
+	}
+	rows, _ = n.FTAggregate("users", "@age:[25 35]", &FTAggregateRequest{Load: []string{"email"}})
+	if len(rows) != 1 || rows[0]["email"] != "a@b.c" {
+		t.Errorf("FTAggregate() = %v", rows)
+	}
+	if _, err := n.FTAggregate("none", "*", nil); err != ErrFTUnknownIndex {
+		t.Errorf("FTAggregate() error = %v, want %v", err, ErrFTUnknownIndex)
+	}
+}
+
+func TestNodis_FTDropIndex(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	_ = n.FTCreate(newSearchDefinition())
+	n.HSet("user:1", "name", []byte("John"))
+	if err := n.FTDropIndex("users", false); err != nil {
+		t.Fatalf("FTDropIndex() error = %v", err)
+	}
+	if n.Exists("user:1") != 1 {
+		t.Errorf("FTDropIndex() deleted the documents")
+	}
+	_ = n.FTCreate(newSearchDefinition())
+	_ = n.FTDropIndex("users", true)
+	if n.Exists("user:1") != 0 {
+		t.Errorf("FTDropIndex() kept the documents")
+	}
+	if _, err := n.FTInfo("users"); err != ErrFTUnknownIndex {
+		t.Errorf("FTInfo() error = %v, want %v", err, ErrFTUnknownIndex)
+	}
+}
+
+func TestNodis_FTRebuild(t *testing.T) {
+	ss := storage.NewMemory()
+	n := Open(&Options{Storage: ss})
+	_ = n.FTCreate(newSearchDefinition())
+	n.HSet("user:1", "name", []byte("John"))
+	_ = n.Close()
+
+	n = Open(&Options{Storage: ss})
+	defer n.Close()
+	if got := n.Keys("*"); len(got) != 1 || got[0] != "user:1" {
+		t.Errorf("Keys() = %v, want %v", got, []string{"user:1"})
+	}
+	if got := searchKeys(t, n, "john"); len(got) != 1 || got[0] != "user:1" {
+		t.Errorf("FTSearch() = %v, want %v", got, []string{"user:1"})
+	}
+	n.Clear()
+	if got := n.FTList(); len(got) != 1 {
+		t.Errorf("FTList() = %v, want %v", got, []string{"users"})
+	}
+	if info, _ := n.FTInfo("users"); info.NumDocs != 0 {
+		t.Errorf("FTInfo().NumDocs = %v, want %v", info.NumDocs, 0)
+	}
+}
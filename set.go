@@ -7,7 +7,7 @@ import (
 )
 
 func (n *Nodis) newSet() ds.Value {
-	return set.NewSet()
+	return set.NewSetWithEncodings(n.store.encodings)
 }
 
 // SAdd adds the specified members to the set stored at key.
@@ -151,7 +151,7 @@ func (n *Nodis) SUnionStore(destination string, keys ...string) int64 {
 func (n *Nodis) SIsMember(key, member string) bool {
 	var v bool
 	_ = n.exec(func(tx *Tx) error {
-		meta := tx.readKey(key)
+		meta := tx.readFields(key, member)
 		if !meta.isOk() {
 			return nil
 		}
@@ -0,0 +1,151 @@
+package nodis
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/diiyw/nodis/rdb"
+)
+
+// snapshot is a point-in-time copy of the keys. Every key is copied by the snapshot or by the
+// first write to it since the snapshot started, whichever comes first, so the writers are not
+// stopped while the keys are saved. A transaction running when the snapshot starts may be
+// copied partly before and partly after its writes.
+type snapshot struct {
+	mu sync.Mutex
+	// at is the unix time in milliseconds of the snapshot, the keys expired at this time are skipped
+	at int64
+	// keys are the keys at the start of the snapshot and pending the ones not copied yet
+	keys    []string
+	pending map[string]*metadata
+	copied  []*snapshotEntry
+	// copying are the copies in progress
+	copying sync.WaitGroup
+	saved   atomic.Int64
+}
+
+// snapshotEntry is the copy of a key
+type snapshotEntry struct {
+	key      string
+	expireAt int64
+	// payload is the value serialized by rdb.Dump
+	payload []byte
+}
+
+// startSnapshot starts a snapshot of the keys, it fails with ErrBackgroundSave if a snapshot is running
+func (s *store) startSnapshot() (*snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sn := &snapshot{
+		at:      time.Now().UnixMilli(),
+		pending: make(map[string]*metadata, len(s.metadata)),
+	}
+	for key, m := range s.metadata {
+		if m.isOk() && !m.expired(sn.at) {
+			sn.keys = append(sn.keys, key)
+			sn.pending[key] = m
+		}
+	}
+	if !s.snapshot.CompareAndSwap(nil, sn) {
+		return nil, ErrBackgroundSave
+	}
+	return sn, nil
+}
+
+// copy copies the key if it's pending, the key must be locked
+func (sn *snapshot) copy(s *store, m *metadata) {
+	sn.mu.Lock()
+	if sn.pending[m.key.Name] != m {
+		sn.mu.Unlock()
+		return
+	}
+	delete(sn.pending, m.key.Name)
+	sn.copying.Add(1)
+	sn.mu.Unlock()
+	defer sn.copying.Done()
+	defer sn.saved.Add(1)
+	if m.expired(sn.at) {
+		return
+	}
+	value := m.value
+	if value == nil {
+		v, err := s.load(m)
+		if err != nil {
+			return
+		}
+		value = v
+	}
+	payload, err := rdb.Dump(value)
+	if err != nil {
+		return
+	}
+	e := &snapshotEntry{key: m.key.Name, expireAt: m.key.Expiration, payload: payload}
+	sn.mu.Lock()
+	sn.copied = append(sn.copied, e)
+	sn.mu.Unlock()
+}
+
+// copyKey copies the key if it's pending
+func (sn *snapshot) copyKey(s *store, key string) {
+	sn.mu.Lock()
+	m := sn.pending[key]
+	sn.mu.Unlock()
+	if m == nil {
+		return
+	}
+	m.RLock()
+	sn.copy(s, m)
+	m.RUnlock()
+}
+
+// copyAll copies all the pending keys, like before they are cleared
+func (sn *snapshot) copyAll(s *store) {
+	for _, key := range sn.keys {
+		sn.copyKey(s, key)
+	}
+}
+
+// take returns the keys copied since the last call
+func (sn *snapshot) take() []*snapshotEntry {
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+	copied := sn.copied
+	sn.copied = nil
+	return copied
+}
+
+// saveSnapshot flushes the changes to the storage and calls fn with the copy of every key of a snapshot
+func (n *Nodis) saveSnapshot(ctx context.Context, fn func(e *snapshotEntry) error) error {
+	n.store.flush()
+	sn, err := n.store.startSnapshot()
+	if err != nil {
+		return err
+	}
+	defer n.store.snapshot.Store(nil)
+	for i, key := range sn.keys {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		sn.copyKey(n.store, key)
+		if i == len(sn.keys)-1 {
+			// the last keys may be copied by writers
+			sn.copying.Wait()
+		}
+		for _, e := range sn.take() {
+			if err = fn(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// saveProgress returns the number of the keys of the running snapshot and of the ones saved
+func (n *Nodis) saveProgress() (total, saved int64) {
+	if sn := n.store.snapshot.Load(); sn != nil {
+		return int64(len(sn.keys)), sn.saved.Load()
+	}
+	return 0, 0
+}
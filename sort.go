@@ -0,0 +1,223 @@
+package nodis
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/ds/set"
+	"github.com/diiyw/nodis/ds/str"
+	"github.com/diiyw/nodis/ds/zset"
+	"github.com/diiyw/nodis/patch"
+)
+
+var (
+	ErrSortInvalidScore = errors.New("ERR One or more scores can't be converted into double")
+	ErrSortWrongType    = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+)
+
+// SortOptions are the options of Sort
+type SortOptions struct {
+	// By is the pattern of the keys holding the weights, the first * is replaced by the element and
+	// a trailing ->field reads a hash field. The elements are not sorted if the pattern has no *.
+	By string
+	// Limit returns Count elements from Offset, a negative Count returns all the elements from Offset
+	Limit  bool
+	Offset int64
+	Count  int64
+	// Get are the patterns of the keys returned instead of the elements, # returns the element itself
+	Get []string
+	// Desc sorts from the largest to the smallest element
+	Desc bool
+	// Alpha sorts lexicographically instead of numerically
+	Alpha bool
+	// Store is the key of the list storing the result, the result is still returned
+	Store string
+}
+
+// sortElement is an element to sort with its weight
+type sortElement struct {
+	value  string
+	score  float64
+	weight []byte
+}
+
+// Sort returns the elements of the list, set or sorted set at key sorted numerically or lexicographically.
+// The missing values of the Get patterns are nil.
+func (n *Nodis) Sort(key string, opts *SortOptions) ([][]byte, error) {
+	if opts == nil {
+		opts = &SortOptions{}
+	}
+	var elements []*sortElement
+	var valueType ds.ValueType
+	var err error
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		valueType = meta.valueType
+		var values []string
+		switch v := meta.value.(type) {
+		case *list.LinkedList:
+			for _, value := range v.LRange(0, -1) {
+				values = append(values, string(value))
+			}
+		case *set.Set:
+			values = v.SMembers()
+		case *zset.SortedSet:
+			for _, item := range v.ZRange(0, -1) {
+				values = append(values, item.Member)
+			}
+		default:
+			err = ErrSortWrongType
+			return nil
+		}
+		elements = make([]*sortElement, 0, len(values))
+		for _, value := range values {
+			elements = append(elements, &sortElement{value: value})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sorting := opts.By == "" || strings.Contains(opts.By, "*")
+	if sorting {
+		for _, e := range elements {
+			if opts.By != "" {
+				e.weight = n.sortLookup(opts.By, e.value)
+			} else {
+				e.weight = []byte(e.value)
+			}
+			if opts.Alpha || (opts.By != "" && e.weight == nil) {
+				continue
+			}
+			e.score, err = strconv.ParseFloat(string(e.weight), 64)
+			if err != nil {
+				return nil, ErrSortInvalidScore
+			}
+		}
+		slices.SortFunc(elements, func(a, b *sortElement) int {
+			c := cmp.Compare(a.score, b.score)
+			if opts.Alpha {
+				c = bytes.Compare(a.weight, b.weight)
+			}
+			if c == 0 {
+				// the elements with the same weight are sorted lexicographically
+				c = strings.Compare(a.value, b.value)
+			}
+			if opts.Desc {
+				return -c
+			}
+			return c
+		})
+	} else if valueType == ds.ZSet && opts.Desc {
+		slices.Reverse(elements)
+	} else if valueType == ds.Set {
+		// the members of a set have no order, sort them to get a stable result
+		slices.SortFunc(elements, func(a, b *sortElement) int {
+			return strings.Compare(a.value, b.value)
+		})
+	}
+
+	if opts.Limit {
+		offset := min(max(opts.Offset, 0), int64(len(elements)))
+		elements = elements[offset:]
+		if opts.Count >= 0 && opts.Count < int64(len(elements)) {
+			elements = elements[:opts.Count]
+		}
+	}
+	result := make([][]byte, 0, len(elements)*max(len(opts.Get), 1))
+	for _, e := range elements {
+		if len(opts.Get) == 0 {
+			result = append(result, []byte(e.value))
+			continue
+		}
+		for _, pattern := range opts.Get {
+			result = append(result, n.sortLookup(pattern, e.value))
+		}
+	}
+	if opts.Store != "" {
+		n.sortStore(opts.Store, result)
+	}
+	return result, nil
+}
+
+// sortLookup returns the value of the key built from the pattern and the element, the string or
+// the hash field, nil if the key doesn't exist or has another type
+func (n *Nodis) sortLookup(pattern, element string) []byte {
+	if pattern == "#" {
+		return []byte(element)
+	}
+	i := strings.IndexByte(pattern, '*')
+	if i < 0 {
+		return nil
+	}
+	key := pattern[:i] + element + pattern[i+1:]
+	var field string
+	if j := strings.Index(pattern[i+1:], "->"); j >= 0 && i+1+j+2 < len(pattern) {
+		key = pattern[:i] + element + pattern[i+1:i+1+j]
+		field = pattern[i+1+j+2:]
+	}
+	var v []byte
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		switch value := meta.value.(type) {
+		case *str.String:
+			if field == "" {
+				v = bytes.Clone(value.Get())
+			}
+		case *hash.HashMap:
+			if field != "" {
+				v = bytes.Clone(value.HGet(field))
+			}
+		}
+		return nil
+	})
+	return v
+}
+
+// sortStore replaces the destination with a list of the values, the nil values are stored as empty strings
+func (n *Nodis) sortStore(destination string, values [][]byte) {
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.writeKey(destination, nil)
+		if meta.isOk() && meta.valueType == ds.Hash {
+			n.indexHash(destination, nil)
+		}
+		ops := []patch.Op{{Type: patch.OpTypeDel, Data: &patch.OpDel{Key: destination}}}
+		switch {
+		case len(values) == 0:
+			if meta.isOk() {
+				tx.delKey(destination)
+			}
+		case meta.isOk():
+			tx.resetMeta(meta, n.newList)
+			meta.key.Expiration = 0
+		default:
+			meta = tx.newStoredMetadata(meta, n.newList)
+		}
+		if len(values) > 0 {
+			l := meta.value.(*list.LinkedList)
+			for _, v := range values {
+				l.RPush(bytes.Clone(v))
+			}
+			ops = append(ops, patch.Op{Type: patch.OpTypeRPush, Data: &patch.OpRPush{Key: destination, Values: values}})
+		}
+		n.signalModifiedKey(destination, meta)
+		n.notify(func() []patch.Op {
+			return ops
+		})
+		return nil
+	})
+}
@@ -0,0 +1,89 @@
+package nodis
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func sortStrings(values [][]byte) []string {
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		if v == nil {
+			s = append(s, "<nil>")
+			continue
+		}
+		s = append(s, string(v))
+	}
+	return s
+}
+
+func TestSort(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.RPush("list", []byte("3"), []byte("10"), []byte("1"), []byte("2"))
+	n.RPush("words", []byte("b"), []byte("c"), []byte("a"))
+	n.SAdd("set", "3", "1", "2")
+	n.ZAdd("zset", "a", 2)
+	n.ZAdd("zset", "b", 1)
+	n.Set("weight_1", []byte("30"), false)
+	n.Set("weight_2", []byte("20"), false)
+	n.Set("weight_3", []byte("10"), false)
+	n.HSet("obj_1", "name", []byte("one"))
+	n.HSet("obj_3", "name", []byte("three"))
+	tests := []struct {
+		name string
+		key  string
+		opts *SortOptions
+		want []string
+	}{
+		{"numeric", "list", nil, []string{"1", "2", "3", "10"}},
+		{"desc", "list", &SortOptions{Desc: true}, []string{"10", "3", "2", "1"}},
+		{"alpha", "list", &SortOptions{Alpha: true}, []string{"1", "10", "2", "3"}},
+		{"limit", "list", &SortOptions{Limit: true, Offset: 1, Count: 2}, []string{"2", "3"}},
+		{"limit all", "list", &SortOptions{Limit: true, Offset: 2, Count: -1}, []string{"3", "10"}},
+		{"words", "words", &SortOptions{Alpha: true}, []string{"a", "b", "c"}},
+		{"set", "set", nil, []string{"1", "2", "3"}},
+		{"zset", "zset", &SortOptions{Alpha: true, Desc: true}, []string{"b", "a"}},
+		{"by", "set", &SortOptions{By: "weight_*"}, []string{"3", "2", "1"}},
+		{"nosort", "zset", &SortOptions{By: "nosort", Desc: true}, []string{"a", "b"}},
+		{"get", "set", &SortOptions{Get: []string{"#", "obj_*->name"}}, []string{"1", "one", "2", "<nil>", "3", "three"}},
+		{"missing", "missing", nil, []string{}},
+	}
+	for _, tt := range tests {
+		got, err := n.Sort(tt.key, tt.opts)
+		if err != nil {
+			t.Errorf("%s: Sort() error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(sortStrings(got), tt.want) {
+			t.Errorf("%s: Sort() = %v, want %v", tt.name, sortStrings(got), tt.want)
+		}
+	}
+	if _, err := n.Sort("words", nil); err != ErrSortInvalidScore {
+		t.Errorf("Sort() error = %v, want %v", err, ErrSortInvalidScore)
+	}
+	if _, err := n.Sort("weight_1", nil); err != ErrSortWrongType {
+		t.Errorf("Sort() error = %v, want %v", err, ErrSortWrongType)
+	}
+}
+
+func TestSort_Store(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.RPush("list", []byte("3"), []byte("1"), []byte("2"))
+	n.Set("dst", []byte("old"), false)
+	got, err := n.Sort("list", &SortOptions{Store: "dst"})
+	if err != nil || len(got) != 3 {
+		t.Fatalf("Sort() = %v, %v, want 3 elements", got, err)
+	}
+	if v := sortStrings(n.LRange("dst", 0, -1)); !reflect.DeepEqual(v, []string{"1", "2", "3"}) {
+		t.Errorf("LRange() = %v, want %v", v, []string{"1", "2", "3"})
+	}
+	if _, err = n.Sort("missing", &SortOptions{Store: "dst"}); err != nil {
+		t.Fatalf("Sort() error = %v", err)
+	}
+	if n.Exists("dst") != 0 {
+		t.Errorf("Exists() = %v, want the destination removed", n.Exists("dst"))
+	}
+}
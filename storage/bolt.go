@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/diiyw/nodis/ds"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltBucket is the bucket of the keys
+var boltBucket = []byte("nodis")
+
+// Bolt is a storage of a single bbolt file, every change is an ACID transaction
+type Bolt struct {
+	compression
+	path    string
+	options *bolt.Options
+	db      *bolt.DB
+}
+
+// NewBolt creates a new bbolt storage of the file
+func NewBolt(path string, options *bolt.Options) *Bolt {
+	return &Bolt{
+		path:    path,
+		options: options,
+	}
+}
+
+// Init opens the file and creates the bucket of the keys
+func (b *Bolt) Init() error {
+	db, err := bolt.Open(b.path, 0600, b.options)
+	if err != nil {
+		return err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return err
+	}
+	b.db = db
+	return nil
+}
+
+// Get the value from the storage
+func (b *Bolt) Get(key *ds.Key) (ds.Value, error) {
+	var entry *Entry
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltBucket).Get(key.Encode())
+		if v == nil {
+			return ErrKeyNotFound
+		}
+		// the value is only valid in the transaction
+		var err error
+		entry, err = parseEntry(append([]byte(nil), v...))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry.GetValue()
+}
+
+// Set the value to the storage
+func (b *Bolt) Set(key *ds.Key, value ds.Value) error {
+	data := NewEntry(value).encode(&b.compression)
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put(key.Encode(), data)
+	})
+}
+
+// Delete the value from the storage
+func (b *Bolt) Delete(key *ds.Key) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete(key.Encode())
+	})
+}
+
+// Clear the storage
+func (b *Bolt) Clear() error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(boltBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(boltBucket)
+		return err
+	})
+}
+
+// Close the storage
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+// Snapshot copies the storage to the file suffixed by ".snapshot", the previous snapshot is
+// replaced once the copy is complete
+func (b *Bolt) Snapshot() error {
+	tmp := b.path + ".snapshot.tmp"
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(tmp, 0600)
+	})
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filepath.Clean(b.path)+".snapshot")
+}
+
+// ScanKeys returns the keys in the storage
+func (b *Bolt) ScanKeys(fn func(*ds.Key) bool) {
+	_ = b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			key, err := ds.DecodeKey(k)
+			if err != nil {
+				continue
+			}
+			if !fn(key) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// WriteBatch writes the changes of the batch in a single transaction
+func (b *Bolt) WriteBatch(batch *Batch) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, op := range batch.Ops() {
+			var err error
+			if op.Value == nil {
+				err = bucket.Delete(op.Key.Encode())
+			} else {
+				err = bucket.Put(op.Key.Encode(), NewEntry(op.Value).encode(&b.compression))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Stats returns the number of the keys and the size of the file
+func (b *Bolt) Stats() (Stats, error) {
+	var stats Stats
+	err := b.db.View(func(tx *bolt.Tx) error {
+		stats.Keys = int64(tx.Bucket(boltBucket).Stats().KeyN)
+		stats.Bytes = tx.Size()
+		return nil
+	})
+	return stats, err
+}
+
+type boltCursor struct {
+	cursor *bolt.Cursor
+}
+
+func (c boltCursor) seek(key []byte) []byte {
+	k, _ := c.cursor.Seek(key)
+	return k
+}
+
+func (c boltCursor) next() []byte {
+	k, _ := c.cursor.Next()
+	return k
+}
+
+// ScanPrefix calls fn with the keys of the prefix in the order of the names from start, the keys
+// are sought in each group of the same expiration
+func (b *Bolt) ScanPrefix(prefix, start string, fn func(*ds.Key) bool) error {
+	return b.scanCursor(fn, func(c keyCursor) []*ds.Key {
+		return scanEncodedPrefix(c, prefix, start)
+	})
+}
+
+// ScanPersistentPrefix calls fn with the keys without expiration of the prefix in the order of
+// the names
+func (b *Bolt) ScanPersistentPrefix(prefix string, fn func(*ds.Key) bool) error {
+	return b.scanCursor(fn, func(c keyCursor) []*ds.Key {
+		return scanEncodedPersistent(c, prefix)
+	})
+}
+
+// scanCursor calls fn with the keys found by scan once the transaction is closed
+func (b *Bolt) scanCursor(fn func(*ds.Key) bool, scan func(c keyCursor) []*ds.Key) error {
+	var keys []*ds.Key
+	err := b.db.View(func(tx *bolt.Tx) error {
+		keys = scan(boltCursor{cursor: tx.Bucket(boltBucket).Cursor()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// fn may read the storage
+	scanSorted(keys, fn)
+	return nil
+}
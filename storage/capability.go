@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/diiyw/nodis/ds"
+)
+
+// BatchOp is a change of a Batch, a nil Value deletes the key
+type BatchOp struct {
+	Key   *ds.Key
+	Value ds.Value
+}
+
+// Batch is a list of changes written at once
+type Batch struct {
+	ops []BatchOp
+}
+
+// Set adds the setting of the value of the key
+func (b *Batch) Set(key *ds.Key, value ds.Value) {
+	b.ops = append(b.ops, BatchOp{Key: key, Value: value})
+}
+
+// Delete adds the removal of the key
+func (b *Batch) Delete(key *ds.Key) {
+	b.ops = append(b.ops, BatchOp{Key: key})
+}
+
+// Ops returns the changes in the order they were added
+func (b *Batch) Ops() []BatchOp {
+	return b.ops
+}
+
+// Len returns the number of the changes
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// BatchWriter is a storage writing the changes of a batch atomically
+type BatchWriter interface {
+	WriteBatch(b *Batch) error
+}
+
+// PrefixScanner is a storage iterating its keys by name
+type PrefixScanner interface {
+	// ScanPrefix calls fn with the keys whose name starts with prefix, in the order of the names
+	// from the first one not lower than start, until fn returns false
+	ScanPrefix(prefix, start string, fn func(*ds.Key) bool) error
+}
+
+// PersistentPrefixScanner is a storage iterating its keys without expiration by name
+type PersistentPrefixScanner interface {
+	// ScanPersistentPrefix calls fn with the keys without expiration whose name starts with
+	// prefix, in the order of the names, until fn returns false
+	ScanPersistentPrefix(prefix string, fn func(*ds.Key) bool) error
+}
+
+// Compressor is a storage compressing its entries
+type Compressor interface {
+	// SetCompression sets the codec compressing the entries of at least threshold bytes, 0 for
+	// the default threshold
+	SetCompression(codec Codec, threshold int)
+	// Compression returns the codec of the entries
+	Compression() Codec
+	// CompressionStats returns the statistics of the entries compressed since the start
+	CompressionStats() CompressionStats
+}
+
+// Stats are the statistics of a storage
+type Stats struct {
+	// Keys is the number of the keys, -1 when it isn't known without reading all of them
+	Keys int64
+	// Bytes is the size of the data
+	Bytes int64
+}
+
+// StatsProvider is a storage returning its statistics
+type StatsProvider interface {
+	Stats() (Stats, error)
+}
+
+// WriteBatch writes the batch by the storage if it's a BatchWriter, else by its changes one
+// at a time
+func WriteBatch(s Storage, b *Batch) error {
+	if b.Len() == 0 {
+		return nil
+	}
+	if w, ok := s.(BatchWriter); ok {
+		return w.WriteBatch(b)
+	}
+	for _, op := range b.ops {
+		var err error
+		if op.Value == nil {
+			err = s.Delete(op.Key)
+		} else {
+			err = s.Set(op.Key, op.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanPrefix calls fn with the keys of the prefix from start by the storage if it's a
+// PrefixScanner, else by sorting the matching keys of ScanKeys
+func ScanPrefix(s Storage, prefix, start string, fn func(*ds.Key) bool) error {
+	if p, ok := s.(PrefixScanner); ok {
+		return p.ScanPrefix(prefix, start, fn)
+	}
+	return scanKeysPrefix(s, prefix, start, fn)
+}
+
+// scanKeysPrefix calls fn with the keys of the prefix from start by sorting the matching keys
+// of ScanKeys
+func scanKeysPrefix(s Storage, prefix, start string, fn func(*ds.Key) bool) error {
+	var keys []*ds.Key
+	s.ScanKeys(func(key *ds.Key) bool {
+		if strings.HasPrefix(key.Name, prefix) && key.Name >= start {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	sortKeys(keys)
+	scanSorted(keys, fn)
+	return nil
+}
+
+// ScanPersistentPrefix calls fn with the keys without expiration of the prefix by the storage
+// if it's a PersistentPrefixScanner, else by ScanPrefix
+func ScanPersistentPrefix(s Storage, prefix string, fn func(*ds.Key) bool) error {
+	if p, ok := s.(PersistentPrefixScanner); ok {
+		return p.ScanPersistentPrefix(prefix, fn)
+	}
+	return ScanPrefix(s, prefix, "", func(key *ds.Key) bool {
+		return key.Expiration != 0 || fn(key)
+	})
+}
+
+func sortKeys(keys []*ds.Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+}
+
+// keyCursor is a cursor of the encoded keys of a storage sorted by bytes
+type keyCursor interface {
+	// seek moves to the first key not lower than key, it returns nil at the end
+	seek(key []byte) []byte
+	next() []byte
+}
+
+// scanEncodedPrefix returns the keys of the prefix from start sorted by name by a cursor of the
+// encoded keys. The encoded keys start by the expiration, the names are sought in each group of
+// the same expiration.
+func scanEncodedPrefix(c keyCursor, prefix, start string) []*ds.Key {
+	from := max(prefix, start)
+	var keys []*ds.Key
+	k := c.seek(nil)
+	for k != nil {
+		if len(k) < 8 {
+			k = c.next()
+			continue
+		}
+		group := bytes.Clone(k[:8])
+		keys = scanEncodedGroup(c, group, prefix, from, keys)
+		// the next expiration
+		i := len(group) - 1
+		for ; i >= 0 && group[i] == 0xff; i-- {
+			group[i] = 0
+		}
+		if i < 0 {
+			break
+		}
+		group[i]++
+		k = c.seek(group)
+	}
+	sortKeys(keys)
+	return keys
+}
+
+// scanEncodedGroup appends to keys the ones of the prefix from start in the group of the same
+// expiration, they are sorted by name
+func scanEncodedGroup(c keyCursor, group []byte, prefix, from string, keys []*ds.Key) []*ds.Key {
+	for k := c.seek(append(bytes.Clone(group), from...)); k != nil && bytes.HasPrefix(k, group); k = c.next() {
+		key, err := ds.DecodeKey(k)
+		if err != nil || !strings.HasPrefix(key.Name, prefix) {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// scanEncodedPersistent returns the keys without expiration of the prefix sorted by name, they
+// are in a single group
+func scanEncodedPersistent(c keyCursor, prefix string) []*ds.Key {
+	return scanEncodedGroup(c, make([]byte, 8), prefix, prefix, nil)
+}
+
+// scanSorted calls fn with the keys until it returns false
+func scanSorted(keys []*ds.Key, fn func(*ds.Key) bool) {
+	for _, key := range keys {
+		if !fn(key) {
+			break
+		}
+	}
+}
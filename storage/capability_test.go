@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"bytes"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/diiyw/nodis/ds"
+)
+
+// sliceCursor is a cursor of sorted encoded keys counting its seeks
+type sliceCursor struct {
+	keys  [][]byte
+	i     int
+	seeks int
+}
+
+func (c *sliceCursor) seek(key []byte) []byte {
+	c.seeks++
+	c.i, _ = slices.BinarySearchFunc(c.keys, key, bytes.Compare)
+	return c.current()
+}
+
+func (c *sliceCursor) next() []byte {
+	c.i++
+	return c.current()
+}
+
+func (c *sliceCursor) current() []byte {
+	if c.i >= len(c.keys) {
+		return nil
+	}
+	return c.keys[c.i]
+}
+
+func TestScanEncodedPersistent(t *testing.T) {
+	c := &sliceCursor{}
+	for i := 0; i < 100; i++ {
+		c.keys = append(c.keys, ds.NewKey("user:"+strconv.Itoa(i), int64(i+1)).Encode())
+	}
+	for _, name := range []string{"user:b", "other", "user:a"} {
+		c.keys = append(c.keys, ds.NewKey(name, 0).Encode())
+	}
+	slices.SortFunc(c.keys, bytes.Compare)
+	keys := scanEncodedPersistent(c, "user:")
+	if len(keys) != 2 || keys[0].Name != "user:a" || keys[1].Name != "user:b" {
+		t.Errorf("scanEncodedPersistent() = %v, want [user:a user:b]", keys)
+	}
+	if c.seeks != 1 {
+		t.Errorf("scanEncodedPersistent() = %d seeks, want 1", c.seeks)
+	}
+	c.seeks = 0
+	if keys = scanEncodedPrefix(c, "user:", ""); len(keys) != 102 || c.seeks < 100 {
+		t.Errorf("scanEncodedPrefix() = %d keys and %d seeks, want a seek per expiration", len(keys), c.seeks)
+	}
+}
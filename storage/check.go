@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"github.com/diiyw/nodis/ds"
+)
+
+// Check reads every value of the storage, it calls fn with the keys whose value can't be read
+// and returns the number of the keys and of the unreadable ones
+func Check(s Storage, fn func(key *ds.Key, err error)) (keys int, failed int) {
+	var all []*ds.Key
+	s.ScanKeys(func(key *ds.Key) bool {
+		all = append(all, key)
+		return true
+	})
+	for _, key := range all {
+		if _, err := s.Get(key); err != nil {
+			failed++
+			fn(key, err)
+		}
+	}
+	return len(all), failed
+}
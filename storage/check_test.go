@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/diiyw/nodis/ds"
+)
+
+func TestCheck(t *testing.T) {
+	d := NewDirectory(filepath.Join(t.TempDir(), "data"))
+	if err := d.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	_ = d.Set(ds.NewKey("a", 0), newString("a value"))
+	_ = d.Set(ds.NewKey("b", 0), newString("b value"))
+	// a bit flipped in the value of b
+	path := d.file(ds.NewKey("b", 0))
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 1
+	_ = os.WriteFile(path, data, 0644)
+
+	var corrupted []string
+	keys, failed := Check(d, func(key *ds.Key, err error) {
+		if !errors.Is(err, ErrCorruptedData) {
+			t.Errorf("Check() %s error = %v, want %v", key.Name, err, ErrCorruptedData)
+		}
+		corrupted = append(corrupted, key.Name)
+	})
+	if keys != 2 || failed != 1 || len(corrupted) != 1 || corrupted[0] != "b" {
+		t.Errorf("Check() = %d, %d, %v, want b corrupted of 2 keys", keys, failed, corrupted)
+	}
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is the compression of the entries, it's kept in the high bits of the type of the entry
+// so the entries written before the compression decode as uncompressed ones
+type Codec uint8
+
+const (
+	CodecNone Codec = iota
+	CodecSnappy
+	CodecZstd
+)
+
+const (
+	codecShift = 6
+	typeMask   = 1<<codecShift - 1
+	// DefaultCompressionThreshold is the size from which the values are compressed
+	DefaultCompressionThreshold = 256
+)
+
+var (
+	ErrUnknownCodec = errors.New("unknown compression codec")
+)
+
+var codecNames = []string{"none", "snappy", "zstd"}
+
+func (c Codec) String() string {
+	if int(c) < len(codecNames) {
+		return codecNames[c]
+	}
+	return "unknown"
+}
+
+// ParseCodec returns the codec of the name: none, snappy or zstd
+func ParseCodec(name string) (Codec, error) {
+	if name == "" {
+		return CodecNone, nil
+	}
+	for i, n := range codecNames {
+		if n == name {
+			return Codec(i), nil
+		}
+	}
+	return CodecNone, ErrUnknownCodec
+}
+
+// CompressionStats are the statistics of the compressed entries since the start
+type CompressionStats struct {
+	// Entries is the number of the compressed entries
+	Entries int64
+	// Bytes is the size of their values, CompressedBytes once compressed
+	Bytes           int64
+	CompressedBytes int64
+}
+
+// compression compresses the entries of a storage, the storages embed it to be a Compressor.
+// The entries aren't compressed until SetCompression is called.
+type compression struct {
+	codec atomic.Uint32
+	// threshold is DefaultCompressionThreshold when it's 0
+	threshold atomic.Int64
+	entries   atomic.Int64
+	bytes     atomic.Int64
+	// compressed is the size of the compressed values
+	compressed atomic.Int64
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+// SetCompression sets the codec compressing the entries of at least threshold bytes, 0 for the
+// default threshold. The entries already stored are unchanged.
+func (c *compression) SetCompression(codec Codec, threshold int) {
+	if threshold <= 0 {
+		threshold = DefaultCompressionThreshold
+	}
+	c.codec.Store(uint32(codec))
+	c.threshold.Store(int64(threshold))
+}
+
+// Compression returns the codec of the entries
+func (c *compression) Compression() Codec {
+	return Codec(c.codec.Load())
+}
+
+// CompressionStats returns the statistics of the compressed entries
+func (c *compression) CompressionStats() CompressionStats {
+	return CompressionStats{
+		Entries:         c.entries.Load(),
+		Bytes:           c.bytes.Load(),
+		CompressedBytes: c.compressed.Load(),
+	}
+}
+
+func initZstd() {
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+}
+
+// compress compresses the value by the codec of SetCompression, it returns the value unchanged
+// if it's below the threshold or the compression doesn't make it smaller
+func (c *compression) compress(value []byte) (Codec, []byte) {
+	codec := c.Compression()
+	threshold := c.threshold.Load()
+	if threshold == 0 {
+		threshold = DefaultCompressionThreshold
+	}
+	if codec == CodecNone || int64(len(value)) < threshold {
+		return CodecNone, value
+	}
+	var compressed []byte
+	switch codec {
+	case CodecSnappy:
+		compressed = snappy.Encode(nil, value)
+	case CodecZstd:
+		zstdOnce.Do(initZstd)
+		compressed = zstdEncoder.EncodeAll(value, nil)
+	default:
+		return CodecNone, value
+	}
+	if len(compressed) >= len(value) {
+		return CodecNone, value
+	}
+	c.entries.Add(1)
+	c.bytes.Add(int64(len(value)))
+	c.compressed.Add(int64(len(compressed)))
+	return codec, compressed
+}
+
+func decompress(codec Codec, data []byte) ([]byte, error) {
+	switch codec {
+	case CodecNone:
+		return data, nil
+	case CodecSnappy:
+		value, err := snappy.Decode(nil, data)
+		if err != nil {
+			return nil, ErrCorruptedData
+		}
+		return value, nil
+	case CodecZstd:
+		zstdOnce.Do(initZstd)
+		value, err := zstdDecoder.DecodeAll(data, nil)
+		if err != nil {
+			return nil, ErrCorruptedData
+		}
+		return value, nil
+	}
+	return nil, ErrUnknownCodec
+}
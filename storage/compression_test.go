@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/str"
+)
+
+func TestEntry_Compression(t *testing.T) {
+	var c compression
+	large := bytes.Repeat([]byte(`{"name":"nodis","tags":["a","b"]}`), 100)
+	for _, codec := range []Codec{CodecSnappy, CodecZstd} {
+		t.Run(codec.String(), func(t *testing.T) {
+			c.SetCompression(codec, 0)
+			before := c.CompressionStats()
+			value := str.NewString()
+			value.Set(large)
+			encoded := NewEntry(value).encode(&c)
+			if Codec(encoded[1]>>codecShift) != codec || len(encoded) >= len(large) {
+				t.Fatalf("encode() = %d bytes of codec %d, want compressed by %s", len(encoded), encoded[1]>>codecShift, codec)
+			}
+			entry, err := parseEntry(encoded)
+			if err != nil {
+				t.Fatalf("parseEntry() error = %v", err)
+			}
+			if ds.ValueType(entry.Type) != ds.String || !bytes.Equal(entry.Value, large) {
+				t.Errorf("parseEntry() = %d %d bytes, want the string", entry.Type, len(entry.Value))
+			}
+			stats := c.CompressionStats()
+			if stats.Entries != before.Entries+1 || stats.Bytes != before.Bytes+int64(len(large)) ||
+				stats.CompressedBytes != before.CompressedBytes+int64(len(encoded)-entryHeaderSize) {
+				t.Errorf("CompressionStats() = %+v, want the entry counted from %+v", stats, before)
+			}
+
+			// below the threshold
+			value.Set([]byte("small"))
+			if encoded = NewEntry(value).encode(&c); encoded[1] != uint8(ds.String) {
+				t.Errorf("encode() type = %d, want an uncompressed string", encoded[1])
+			}
+			// not smaller once compressed
+			random := make([]byte, 1024)
+			_, _ = rand.Read(random)
+			value.Set(random)
+			if encoded = NewEntry(value).encode(&c); encoded[1] != uint8(ds.String) {
+				t.Errorf("encode() type = %d, want an uncompressed string", encoded[1])
+			}
+
+			// an entry of the previous format
+			encoded = []byte{uint8(ds.String) | uint8(codec)<<codecShift}
+			encoded = append(encoded, "not compressed"...)
+			if _, err = parseEntry(encoded); err != ErrCorruptedData {
+				t.Errorf("parseEntry() error = %v, want %v", err, ErrCorruptedData)
+			}
+		})
+	}
+
+	// the entries written without compression decode whatever the codec
+	c.SetCompression(CodecNone, 0)
+	value := str.NewString()
+	value.Set(large)
+	encoded := NewEntry(value).encode(&c)
+	c.SetCompression(CodecZstd, 0)
+	if entry, err := parseEntry(encoded); err != nil || !bytes.Equal(entry.Value, large) {
+		t.Errorf("parseEntry() = %v, want the uncompressed entry", err)
+	}
+	encoded = NewEntry(str.NewString()).encode(&c)
+	encoded[1] |= 3 << codecShift
+	binary.BigEndian.PutUint32(encoded[6:], crc32.Checksum(encoded[:6], crc32c))
+	if _, err := parseEntry(encoded); err != ErrUnknownCodec {
+		t.Errorf("parseEntry() error = %v, want %v", err, ErrUnknownCodec)
+	}
+}
+
+func TestParseCodec(t *testing.T) {
+	for name, want := range map[string]Codec{"": CodecNone, "none": CodecNone, "snappy": CodecSnappy, "zstd": CodecZstd} {
+		if got, err := ParseCodec(name); err != nil || got != want {
+			t.Errorf("ParseCodec(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseCodec("lz4"); err != ErrUnknownCodec {
+		t.Errorf("ParseCodec() error = %v, want %v", err, ErrUnknownCodec)
+	}
+}
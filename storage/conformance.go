@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/ds/list"
+	"github.com/diiyw/nodis/ds/set"
+	"github.com/diiyw/nodis/ds/str"
+	"github.com/diiyw/nodis/ds/zset"
+)
+
+// RunConformance checks that the storages created by newStorage follow the contract of Storage,
+// third party storages can run it from their tests. newStorage returns a new empty storage that
+// isn't initialized yet, like on a temporary directory of t.
+func RunConformance(t *testing.T, newStorage func(t *testing.T) Storage) {
+	open := func(t *testing.T) Storage {
+		t.Helper()
+		s := newStorage(t)
+		if err := s.Init(); err != nil {
+			t.Fatalf("Init() error = %v", err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+		return s
+	}
+	t.Run("GetMissing", func(t *testing.T) {
+		s := open(t)
+		if _, err := s.Get(ds.NewKey("missing", 0)); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Get() error = %v, want %v", err, ErrKeyNotFound)
+		}
+	})
+	t.Run("SetGet", func(t *testing.T) {
+		s := open(t)
+		for i, v := range conformanceValues() {
+			key := ds.NewKey("key"+strconv.Itoa(i), 0)
+			if err := s.Set(key, v); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			got, err := s.Get(key)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.Type() != v.Type() || !bytes.Equal(got.GetValue(), v.GetValue()) {
+				t.Errorf("Get() = %v, want %v", got, v)
+			}
+		}
+	})
+	t.Run("Overwrite", func(t *testing.T) {
+		s := open(t)
+		key := ds.NewKey("key", 0)
+		_ = s.Set(key, conformanceString("a"))
+		_ = s.Set(key, conformanceString("b"))
+		if v, err := s.Get(key); err != nil || string(v.GetValue()) != "b" {
+			t.Errorf("Get() = %v, %v, want b", v, err)
+		}
+	})
+	t.Run("Copies", func(t *testing.T) {
+		s := open(t)
+		key := ds.NewKey("key", 0)
+		v := str.NewString()
+		v.Set([]byte("a"))
+		_ = s.Set(key, v)
+		v.Set([]byte("b"))
+		got, err := s.Get(key)
+		if err != nil || string(got.GetValue()) != "a" {
+			t.Fatalf("Get() = %v, %v, want the value when it was set", got, err)
+		}
+		got.(*str.String).Set([]byte("c"))
+		if got, _ = s.Get(key); string(got.GetValue()) != "a" {
+			t.Errorf("Get() = %s, want the value when it was set", got.GetValue())
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		s := open(t)
+		key := ds.NewKey("key", 0)
+		_ = s.Set(key, conformanceString("a"))
+		if err := s.Delete(key); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if _, err := s.Get(key); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Get() error = %v, want %v", err, ErrKeyNotFound)
+		}
+		if err := s.Delete(key); err != nil {
+			t.Errorf("Delete() error = %v on a missing key", err)
+		}
+	})
+	t.Run("ScanKeys", func(t *testing.T) {
+		s := open(t)
+		for i := 0; i < 10; i++ {
+			_ = s.Set(ds.NewKey("key"+strconv.Itoa(i), int64(i)), conformanceString("v"))
+		}
+		keys := make(map[string]int64)
+		s.ScanKeys(func(key *ds.Key) bool {
+			keys[key.Name] = key.Expiration
+			return true
+		})
+		if len(keys) != 10 {
+			t.Fatalf("ScanKeys() = %v, want 10 keys", keys)
+		}
+		for i := 0; i < 10; i++ {
+			if exp, ok := keys["key"+strconv.Itoa(i)]; !ok || exp != int64(i) {
+				t.Errorf("ScanKeys() key%d = %v, %v, want the expiration %d", i, exp, ok, i)
+			}
+		}
+		var n int
+		s.ScanKeys(func(key *ds.Key) bool {
+			n++
+			return false
+		})
+		if n != 1 {
+			t.Errorf("ScanKeys() called fn %d times after it returned false, want 1", n)
+		}
+	})
+	t.Run("Clear", func(t *testing.T) {
+		s := open(t)
+		key := ds.NewKey("key", 0)
+		_ = s.Set(key, conformanceString("a"))
+		if err := s.Clear(); err != nil {
+			t.Fatalf("Clear() error = %v", err)
+		}
+		if _, err := s.Get(key); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Get() error = %v, want %v", err, ErrKeyNotFound)
+		}
+		s.ScanKeys(func(key *ds.Key) bool {
+			t.Errorf("ScanKeys() = %v after Clear()", key)
+			return true
+		})
+		if err := s.Set(key, conformanceString("b")); err != nil {
+			t.Errorf("Set() error = %v after Clear()", err)
+		}
+	})
+	t.Run("Reopen", func(t *testing.T) {
+		s := newStorage(t)
+		if err := s.Init(); err != nil {
+			t.Fatalf("Init() error = %v", err)
+		}
+		key := ds.NewKey("key", 0)
+		_ = s.Set(key, conformanceString("a"))
+		if err := s.Snapshot(); err != nil {
+			t.Errorf("Snapshot() error = %v", err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		if err := s.Init(); err != nil {
+			t.Fatalf("Init() error = %v", err)
+		}
+		defer s.Close()
+		if v, err := s.Get(key); err != nil || string(v.GetValue()) != "a" {
+			t.Errorf("Get() = %v, %v after Init(), want a", v, err)
+		}
+	})
+	t.Run("WriteBatch", func(t *testing.T) {
+		s := open(t)
+		_ = s.Set(ds.NewKey("deleted", 0), conformanceString("a"))
+		var b Batch
+		b.Set(ds.NewKey("a", 0), conformanceString("a"))
+		b.Set(ds.NewKey("b", 0), conformanceString("b"))
+		b.Delete(ds.NewKey("deleted", 0))
+		b.Set(ds.NewKey("a", 0), conformanceString("c"))
+		if err := WriteBatch(s, &b); err != nil {
+			t.Fatalf("WriteBatch() error = %v", err)
+		}
+		if v, err := s.Get(ds.NewKey("a", 0)); err != nil || string(v.GetValue()) != "c" {
+			t.Errorf("Get() = %v, %v, want the last change c", v, err)
+		}
+		if v, err := s.Get(ds.NewKey("b", 0)); err != nil || string(v.GetValue()) != "b" {
+			t.Errorf("Get() = %v, %v, want b", v, err)
+		}
+		if _, err := s.Get(ds.NewKey("deleted", 0)); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Get() error = %v, want %v", err, ErrKeyNotFound)
+		}
+	})
+	t.Run("ScanPrefix", func(t *testing.T) {
+		s := open(t)
+		for _, name := range []string{"user:3", "user:1", "other", "user:2", "user"} {
+			_ = s.Set(ds.NewKey(name, 0), conformanceString("v"))
+		}
+		_ = s.Set(ds.NewKey("user:4", 100), conformanceString("v"))
+		_ = s.Set(ds.NewKey("user:0", 1<<40), conformanceString("v"))
+		var names []string
+		err := ScanPrefix(s, "user:", "user:2", func(key *ds.Key) bool {
+			names = append(names, key.Name)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("ScanPrefix() error = %v", err)
+		}
+		if strings.Join(names, ",") != "user:2,user:3,user:4" {
+			t.Errorf("ScanPrefix() = %v, want [user:2 user:3 user:4]", names)
+		}
+		names = names[:0]
+		_ = ScanPrefix(s, "user", "", func(key *ds.Key) bool {
+			names = append(names, key.Name)
+			return len(names) < 2
+		})
+		if strings.Join(names, ",") != "user,user:0" {
+			t.Errorf("ScanPrefix() = %v, want [user user:0] until fn returns false", names)
+		}
+		names = names[:0]
+		err = ScanPersistentPrefix(s, "user:", func(key *ds.Key) bool {
+			names = append(names, key.Name)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("ScanPersistentPrefix() error = %v", err)
+		}
+		if strings.Join(names, ",") != "user:1,user:2,user:3" {
+			t.Errorf("ScanPersistentPrefix() = %v, want [user:1 user:2 user:3]", names)
+		}
+	})
+	t.Run("Stats", func(t *testing.T) {
+		s := open(t)
+		p, ok := s.(StatsProvider)
+		if !ok {
+			t.Skip("not a StatsProvider")
+		}
+		for i := 0; i < 3; i++ {
+			_ = s.Set(ds.NewKey("key"+strconv.Itoa(i), 0), conformanceString("v"))
+		}
+		stats, err := p.Stats()
+		if err != nil {
+			t.Fatalf("Stats() error = %v", err)
+		}
+		if stats.Keys != 3 && stats.Keys != -1 || stats.Bytes <= 0 {
+			t.Errorf("Stats() = %+v, want 3 or -1 keys and some bytes", stats)
+		}
+	})
+	t.Run("Concurrent", func(t *testing.T) {
+		s := open(t)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				key := ds.NewKey("key"+strconv.Itoa(i), 0)
+				for j := 0; j < 20; j++ {
+					if err := s.Set(key, conformanceString(strconv.Itoa(j))); err != nil {
+						t.Errorf("Set() error = %v", err)
+						return
+					}
+					if _, err := s.Get(key); err != nil {
+						t.Errorf("Get() error = %v", err)
+						return
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
+
+func conformanceString(v string) ds.Value {
+	s := str.NewString()
+	s.Set([]byte(v))
+	return s
+}
+
+// conformanceValues returns a value of every type, the sets have a single member since their
+// encoding follows the order of a map
+func conformanceValues() []ds.Value {
+	l := list.NewLinkedList()
+	l.RPush([]byte("a"), []byte("b"))
+	s := set.NewSet()
+	s.SAdd("a")
+	z := zset.NewSortedSet()
+	z.ZAdd("a", 1)
+	h := hash.NewHashMap()
+	h.HSet("f", []byte("v"))
+	return []ds.Value{conformanceString("v"), conformanceString(""), l, s, z, h}
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMemory_Conformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) Storage {
+		return NewMemory()
+	})
+}
+
+func TestMemoryWithFile_Conformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) Storage {
+		return NewMemoryWithFile(filepath.Join(t.TempDir(), "memory.db"))
+	})
+}
+
+func TestPebble_Conformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) Storage {
+		return NewPebble(filepath.Join(t.TempDir(), "data"), nil)
+	})
+}
+
+func TestBolt_Conformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) Storage {
+		return NewBolt(filepath.Join(t.TempDir(), "data.db"), nil)
+	})
+}
+
+func TestDirectory_Conformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) Storage {
+		return NewDirectory(filepath.Join(t.TempDir(), "data"))
+	})
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/diiyw/nodis/ds"
+)
+
+// directoryTempPrefix prefixes the files being written, they are removed by Init
+const directoryTempPrefix = "tmp-"
+
+// Directory is a storage keeping one file per key. The files are named by the hash of the key
+// and hold the length of the encoded key, the encoded key and the encoded Entry of the value.
+// They are replaced atomically by writing a temporary file renamed over them.
+type Directory struct {
+	compression
+	path string
+}
+
+// NewDirectory creates a new directory storage
+func NewDirectory(path string) *Directory {
+	return &Directory{path: path}
+}
+
+// Init creates the directory and removes the files of the interrupted writes
+func (d *Directory) Init() error {
+	if err := os.MkdirAll(d.path, 0755); err != nil {
+		return err
+	}
+	temps, err := filepath.Glob(filepath.Join(d.path, directoryTempPrefix+"*"))
+	if err != nil {
+		return err
+	}
+	for _, tmp := range temps {
+		_ = os.Remove(tmp)
+	}
+	return nil
+}
+
+// file returns the path of the file of the key, the files are spread in subdirectories by the
+// first byte of the hash
+func (d *Directory) file(key *ds.Key) string {
+	sum := sha256.Sum256(key.Encode())
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(d.path, name[:2], name)
+}
+
+// readDirectoryFile reads the key and the entry of a file
+func readDirectoryFile(path string) (*ds.Key, []byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrKeyNotFound
+		}
+		return nil, nil, err
+	}
+	n, size := binary.Uvarint(b)
+	if size <= 0 || uint64(len(b)-size) < n {
+		return nil, nil, ErrCorruptedData
+	}
+	key, err := ds.DecodeKey(b[size : size+int(n)])
+	if err != nil {
+		return nil, nil, ErrCorruptedData
+	}
+	return key, b[size+int(n):], nil
+}
+
+// Get the value from the storage
+func (d *Directory) Get(key *ds.Key) (ds.Value, error) {
+	stored, data, err := readDirectoryFile(d.file(key))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(stored.Encode(), key.Encode()) {
+		// another key of the same hash
+		return nil, ErrKeyNotFound
+	}
+	entry, err := parseEntry(data)
+	if err != nil {
+		return nil, err
+	}
+	return entry.GetValue()
+}
+
+// Set the value to the storage
+func (d *Directory) Set(key *ds.Key, value ds.Value) error {
+	encoded := key.Encode()
+	data := binary.AppendUvarint(nil, uint64(len(encoded)))
+	data = append(data, encoded...)
+	data = append(data, NewEntry(value).encode(&d.compression)...)
+	path := d.file(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(d.path, directoryTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Delete the value from the storage
+func (d *Directory) Delete(key *ds.Key) error {
+	err := os.Remove(d.file(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Clear the storage
+func (d *Directory) Clear() error {
+	if err := os.RemoveAll(d.path); err != nil {
+		return err
+	}
+	return os.MkdirAll(d.path, 0755)
+}
+
+// Close the storage
+func (d *Directory) Close() error {
+	return nil
+}
+
+// Snapshot does nothing, every change is written to its file right away
+func (d *Directory) Snapshot() error {
+	return nil
+}
+
+// ScanKeys returns the keys in the storage
+func (d *Directory) ScanKeys(fn func(*ds.Key) bool) {
+	_ = filepath.WalkDir(d.path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || strings.HasPrefix(entry.Name(), directoryTempPrefix) {
+			return nil
+		}
+		key, _, err := readDirectoryFile(path)
+		if err != nil {
+			return nil
+		}
+		if !fn(key) {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+}
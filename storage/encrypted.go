@@ -0,0 +1,531 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/str"
+)
+
+const (
+	// encryptedVersion starts the encrypted records, the ID of the key, the nonce and the
+	// sealed entry follow
+	encryptedVersion    = 1
+	encryptedHeaderSize = 1 + 4 + 12
+	// encryptedNameSize is the size of the ID of the key and the synthetic IV of the names
+	encryptedNameSize = 4 + aes.BlockSize
+	encryptedLocks    = 64
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown encryption key")
+	ErrDecrypt    = errors.New("decryption failed")
+)
+
+// KeyProvider returns the keys of an Encrypted storage, the keys are 16, 24 or 32 bytes long
+type KeyProvider interface {
+	// CurrentKeyID returns the ID of the key encrypting the new records
+	CurrentKeyID() uint32
+	// Key returns the key of the ID, ErrUnknownKey if there's none
+	Key(id uint32) ([]byte, error)
+	// KeyIDs returns the IDs of all the keys
+	KeyIDs() []uint32
+}
+
+// KeyRing is a KeyProvider of the keys in memory, the last added key is the current one
+type KeyRing struct {
+	mu      sync.RWMutex
+	current uint32
+	keys    map[uint32][]byte
+}
+
+// NewKeyRing creates a key ring with the current key
+func NewKeyRing(id uint32, key []byte) *KeyRing {
+	return &KeyRing{current: id, keys: map[uint32][]byte{id: key}}
+}
+
+// Add adds a key and makes it the current one, the records are encrypted by it from now
+func (r *KeyRing) Add(id uint32, key []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[id] = key
+	r.current = id
+}
+
+// Remove removes a key, the records it encrypted can't be read anymore
+func (r *KeyRing) Remove(id uint32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id != r.current {
+		delete(r.keys, id)
+	}
+}
+
+func (r *KeyRing) CurrentKeyID() uint32 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+func (r *KeyRing) Key(id uint32) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func (r *KeyRing) KeyIDs() []uint32 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]uint32, 0, len(r.keys))
+	for id := range r.keys {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// encryptedKey are the ciphers derived from a key of the provider
+type encryptedKey struct {
+	value cipher.AEAD
+	name  cipher.Block
+	mac   []byte
+}
+
+// EncryptedStorage is a storage encrypting the values by AES-GCM before they reach the inner
+// storage, and the names of the keys if enabled. The values are stored as strings of the version,
+// the ID of the key, the nonce and the sealed entry, the name of the key authenticates them.
+// The names are encrypted deterministically so they can be looked up: they're the ID of the key,
+// a synthetic IV of the HMAC of the name and the name encrypted by AES-CTR.
+//
+// The statistics and the snapshots are the ones of the inner storage, the prefix scans too unless
+// the names are encrypted. The entries are compressed before they're encrypted, by the codec set
+// on the EncryptedStorage.
+type EncryptedStorage struct {
+	compression
+	inner        Storage
+	keys         KeyProvider
+	encryptNames bool
+	ciphersMu    sync.Mutex
+	ciphers      map[string]*encryptedKey
+	// locks serialize the writes of a key with its re-encryption
+	locks [encryptedLocks]sync.Mutex
+}
+
+// Encrypted wraps the storage to encrypt its values by the keys of the provider
+func Encrypted(inner Storage, keys KeyProvider) *EncryptedStorage {
+	return &EncryptedStorage{
+		inner:   inner,
+		keys:    keys,
+		ciphers: make(map[string]*encryptedKey),
+	}
+}
+
+// SetEncryptNames enables the encryption of the names of the keys, it must be set before the
+// first write of the storage
+func (e *EncryptedStorage) SetEncryptNames(enabled bool) {
+	e.encryptNames = enabled
+}
+
+// cipher returns the ciphers of the key of the ID
+func (e *EncryptedStorage) cipher(id uint32) (*encryptedKey, error) {
+	key, err := e.keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	e.ciphersMu.Lock()
+	defer e.ciphersMu.Unlock()
+	// the key of an ID may be replaced, the ciphers are cached by the key itself
+	if c, ok := e.ciphers[string(key)]; ok {
+		return c, nil
+	}
+	derive := func(label string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(label))
+		return h.Sum(nil)
+	}
+	if _, err = aes.NewCipher(key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derive("nodis value"))
+	if err != nil {
+		return nil, err
+	}
+	value, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	name, err := aes.NewCipher(derive("nodis name"))
+	if err != nil {
+		return nil, err
+	}
+	c := &encryptedKey{value: value, name: name, mac: derive("nodis name mac")}
+	e.ciphers[string(key)] = c
+	return c, nil
+}
+
+// lock locks the writes of the keys, it returns the function unlocking them
+func (e *EncryptedStorage) lock(keys ...*ds.Key) func() {
+	var indexes []int
+	for _, key := range keys {
+		h := fnv.New32a()
+		h.Write([]byte(key.Name))
+		indexes = append(indexes, int(h.Sum32()%encryptedLocks))
+	}
+	// locked in order, once each
+	sort.Ints(indexes)
+	indexes = slices.Compact(indexes)
+	for _, i := range indexes {
+		e.locks[i].Lock()
+	}
+	return func() {
+		for _, i := range indexes {
+			e.locks[i].Unlock()
+		}
+	}
+}
+
+// encryptName returns the stored key of the key by the key of the ID
+func (e *EncryptedStorage) encryptName(key *ds.Key, id uint32) (*ds.Key, error) {
+	if !e.encryptNames {
+		return key, nil
+	}
+	c, err := e.cipher(id)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, encryptedNameSize+len(key.Name))
+	binary.BigEndian.PutUint32(b, id)
+	h := hmac.New(sha256.New, c.mac)
+	h.Write([]byte(key.Name))
+	iv := b[4:encryptedNameSize]
+	copy(iv, h.Sum(nil))
+	cipher.NewCTR(c.name, iv).XORKeyStream(b[encryptedNameSize:], []byte(key.Name))
+	return ds.NewKey(string(b), key.Expiration), nil
+}
+
+// decryptName returns the key of a stored key and the ID of the key encrypting it
+func (e *EncryptedStorage) decryptName(stored *ds.Key) (*ds.Key, uint32, error) {
+	if !e.encryptNames {
+		return stored, 0, nil
+	}
+	if len(stored.Name) < encryptedNameSize {
+		return nil, 0, ErrDecrypt
+	}
+	id := binary.BigEndian.Uint32([]byte(stored.Name[:4]))
+	c, err := e.cipher(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	iv := []byte(stored.Name[4:encryptedNameSize])
+	name := make([]byte, len(stored.Name)-encryptedNameSize)
+	cipher.NewCTR(c.name, iv).XORKeyStream(name, []byte(stored.Name[encryptedNameSize:]))
+	h := hmac.New(sha256.New, c.mac)
+	h.Write(name)
+	if !hmac.Equal(h.Sum(nil)[:aes.BlockSize], iv) {
+		return nil, 0, ErrDecrypt
+	}
+	return ds.NewKey(string(name), stored.Expiration), id, nil
+}
+
+// seal encrypts the value of the key by the current key
+func (e *EncryptedStorage) seal(key *ds.Key, value ds.Value) (ds.Value, error) {
+	id := e.keys.CurrentKeyID()
+	c, err := e.cipher(id)
+	if err != nil {
+		return nil, err
+	}
+	entry := NewEntry(value).encode(&e.compression)
+	b := make([]byte, encryptedHeaderSize, encryptedHeaderSize+len(entry)+c.value.Overhead())
+	b[0] = encryptedVersion
+	binary.BigEndian.PutUint32(b[1:], id)
+	nonce := b[5:encryptedHeaderSize]
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	v := str.NewString()
+	v.Set(c.value.Seal(b, nonce, entry, []byte(key.Name)))
+	return v, nil
+}
+
+// open decrypts a record of the key, it returns the ID of the key encrypting it
+func (e *EncryptedStorage) open(key *ds.Key, record ds.Value) (ds.Value, uint32, error) {
+	b := record.GetValue()
+	if record.Type() != ds.String || len(b) < encryptedHeaderSize || b[0] != encryptedVersion {
+		return nil, 0, ErrCorruptedData
+	}
+	id := binary.BigEndian.Uint32(b[1:])
+	c, err := e.cipher(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := c.value.Open(nil, b[5:encryptedHeaderSize], b[encryptedHeaderSize:], []byte(key.Name))
+	if err != nil {
+		return nil, 0, ErrDecrypt
+	}
+	entry, err := parseEntry(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	v, err := entry.GetValue()
+	return v, id, err
+}
+
+func (e *EncryptedStorage) Init() error {
+	return e.inner.Init()
+}
+
+// get returns the record of the key and its stored key, the names encrypted by the current
+// key are looked up first
+func (e *EncryptedStorage) get(key *ds.Key) (ds.Value, *ds.Key, error) {
+	current := e.keys.CurrentKeyID()
+	ids := []uint32{current}
+	if e.encryptNames {
+		for _, id := range e.keys.KeyIDs() {
+			if id != current {
+				ids = append(ids, id)
+			}
+		}
+	}
+	for _, id := range ids {
+		stored, err := e.encryptName(key, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		record, err := e.inner.Get(stored)
+		if errors.Is(err, ErrKeyNotFound) {
+			continue
+		}
+		return record, stored, err
+	}
+	return nil, nil, ErrKeyNotFound
+}
+
+// Get the value from the storage
+func (e *EncryptedStorage) Get(key *ds.Key) (ds.Value, error) {
+	record, _, err := e.get(key)
+	if err != nil {
+		return nil, err
+	}
+	v, _, err := e.open(key, record)
+	return v, err
+}
+
+// batch adds the changes of setting the value of the key, nil to delete it. The names encrypted
+// by the previous keys are deleted.
+func (e *EncryptedStorage) batch(b *Batch, key *ds.Key, value ds.Value) error {
+	current := e.keys.CurrentKeyID()
+	if value != nil {
+		stored, err := e.encryptName(key, current)
+		if err != nil {
+			return err
+		}
+		record, err := e.seal(key, value)
+		if err != nil {
+			return err
+		}
+		b.Set(stored, record)
+	}
+	if !e.encryptNames {
+		if value == nil {
+			b.Delete(key)
+		}
+		return nil
+	}
+	for _, id := range e.keys.KeyIDs() {
+		if id == current && value != nil {
+			continue
+		}
+		stored, err := e.encryptName(key, id)
+		if err != nil {
+			return err
+		}
+		b.Delete(stored)
+	}
+	return nil
+}
+
+// Set the value to the storage
+func (e *EncryptedStorage) Set(key *ds.Key, value ds.Value) error {
+	return e.write(key, value)
+}
+
+// Delete the value from the storage
+func (e *EncryptedStorage) Delete(key *ds.Key) error {
+	return e.write(key, nil)
+}
+
+func (e *EncryptedStorage) write(key *ds.Key, value ds.Value) error {
+	var b Batch
+	if err := e.batch(&b, key, value); err != nil {
+		return err
+	}
+	defer e.lock(key)()
+	return WriteBatch(e.inner, &b)
+}
+
+// WriteBatch encrypts the changes of the batch, it's atomic if the inner storage is a BatchWriter
+func (e *EncryptedStorage) WriteBatch(b *Batch) error {
+	var encrypted Batch
+	keys := make([]*ds.Key, 0, b.Len())
+	for _, op := range b.Ops() {
+		if err := e.batch(&encrypted, op.Key, op.Value); err != nil {
+			return err
+		}
+		keys = append(keys, op.Key)
+	}
+	defer e.lock(keys...)()
+	return WriteBatch(e.inner, &encrypted)
+}
+
+// Clear the storage
+func (e *EncryptedStorage) Clear() error {
+	return e.inner.Clear()
+}
+
+// Close the storage
+func (e *EncryptedStorage) Close() error {
+	return e.inner.Close()
+}
+
+// Snapshot the storage
+func (e *EncryptedStorage) Snapshot() error {
+	return e.inner.Snapshot()
+}
+
+// ScanKeys returns the keys in the storage, the names that can't be decrypted are skipped
+func (e *EncryptedStorage) ScanKeys(fn func(*ds.Key) bool) {
+	e.inner.ScanKeys(func(stored *ds.Key) bool {
+		key, _, err := e.decryptName(stored)
+		if err != nil {
+			return true
+		}
+		return fn(key)
+	})
+}
+
+// ScanPrefix calls fn with the keys of the prefix from start in the order of the names. The
+// encrypted names don't keep that order, all the keys are scanned and sorted then.
+func (e *EncryptedStorage) ScanPrefix(prefix, start string, fn func(*ds.Key) bool) error {
+	if !e.encryptNames {
+		return ScanPrefix(e.inner, prefix, start, fn)
+	}
+	return scanKeysPrefix(e, prefix, start, fn)
+}
+
+// ScanPersistentPrefix calls fn with the keys without expiration of the prefix in the order of
+// the names, all the keys are scanned and sorted if the names are encrypted
+func (e *EncryptedStorage) ScanPersistentPrefix(prefix string, fn func(*ds.Key) bool) error {
+	if !e.encryptNames {
+		return ScanPersistentPrefix(e.inner, prefix, fn)
+	}
+	return scanKeysPrefix(e, prefix, "", func(key *ds.Key) bool {
+		return key.Expiration != 0 || fn(key)
+	})
+}
+
+// Stats returns the statistics of the inner storage, ErrUnsupported if it doesn't provide them
+func (e *EncryptedStorage) Stats() (Stats, error) {
+	p, ok := e.inner.(StatsProvider)
+	if !ok {
+		return Stats{}, ErrUnsupported
+	}
+	return p.Stats()
+}
+
+// ListSnapshots returns the snapshots of the inner storage, ErrUnsupported if it doesn't keep them
+func (e *EncryptedStorage) ListSnapshots() ([]SnapshotInfo, error) {
+	sm, ok := e.inner.(SnapshotManager)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return sm.ListSnapshots()
+}
+
+// PruneSnapshots removes the snapshots of the inner storage beyond its retention
+func (e *EncryptedStorage) PruneSnapshots() (int, error) {
+	sm, ok := e.inner.(SnapshotManager)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	return sm.PruneSnapshots()
+}
+
+// RestoreSnapshot restores the snapshot of the inner storage, its records stay encrypted by the
+// keys of the time of the snapshot
+func (e *EncryptedStorage) RestoreSnapshot(name string) error {
+	sm, ok := e.inner.(SnapshotManager)
+	if !ok {
+		return ErrUnsupported
+	}
+	return sm.RestoreSnapshot(name)
+}
+
+// Reencrypt encrypts by the current key the records encrypted by the previous ones, it returns
+// the number of the re-encrypted records. It runs along the reads and writes of the storage, like
+// in a goroutine after the rotation of the keys, until ctx is done.
+func (e *EncryptedStorage) Reencrypt(ctx context.Context) (int, error) {
+	var keys []*ds.Key
+	e.inner.ScanKeys(func(stored *ds.Key) bool {
+		keys = append(keys, stored)
+		return true
+	})
+	var n int
+	for _, stored := range keys {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		done, err := e.reencrypt(stored)
+		if err != nil {
+			return n, err
+		}
+		if done {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// reencrypt re-encrypts the record of a stored key if it isn't encrypted by the current key
+func (e *EncryptedStorage) reencrypt(stored *ds.Key) (bool, error) {
+	key, nameID, err := e.decryptName(stored)
+	if err != nil {
+		return false, err
+	}
+	defer e.lock(key)()
+	record, err := e.inner.Get(stored)
+	if errors.Is(err, ErrKeyNotFound) {
+		// changed since the scan
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	value, valueID, err := e.open(key, record)
+	if err != nil {
+		return false, err
+	}
+	current := e.keys.CurrentKeyID()
+	if valueID == current && (!e.encryptNames || nameID == current) {
+		return false, nil
+	}
+	var b Batch
+	if err = e.batch(&b, key, value); err != nil {
+		return false, err
+	}
+	return true, WriteBatch(e.inner, &b)
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/nodis/ds"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestEncrypted_Conformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) Storage {
+		return Encrypted(NewMemory(), NewKeyRing(1, testKey(1)))
+	})
+}
+
+func TestEncryptedNames_Conformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) Storage {
+		e := Encrypted(NewMemory(), NewKeyRing(1, testKey(1)))
+		e.SetEncryptNames(true)
+		return e
+	})
+}
+
+func TestEncrypted_AtRest(t *testing.T) {
+	inner := NewMemory()
+	e := Encrypted(inner, NewKeyRing(1, testKey(1)))
+	e.SetEncryptNames(true)
+	_ = e.Init()
+	_ = e.Set(ds.NewKey("secret-name", 0), newString("secret-value"))
+	inner.RLock()
+	defer inner.RUnlock()
+	for encoded, kv := range inner.data {
+		if strings.Contains(encoded, "secret") || bytes.Contains(kv.entry, []byte("secret")) {
+			t.Errorf("inner storage = %q: %q, want encrypted", encoded, kv.entry)
+		}
+	}
+}
+
+func TestEncrypted_Tampered(t *testing.T) {
+	inner := NewMemory()
+	e := Encrypted(inner, NewKeyRing(1, testKey(1)))
+	_ = e.Init()
+	_ = e.Set(ds.NewKey("a", 0), newString("a"))
+	// a record moved to another key doesn't decrypt
+	record, _ := inner.Get(ds.NewKey("a", 0))
+	_ = inner.Set(ds.NewKey("b", 0), record)
+	if _, err := e.Get(ds.NewKey("b", 0)); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Get() error = %v, want %v", err, ErrDecrypt)
+	}
+	// the records of a removed key can't be read
+	other := Encrypted(inner, NewKeyRing(2, testKey(2)))
+	if _, err := other.Get(ds.NewKey("a", 0)); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Get() error = %v, want %v", err, ErrUnknownKey)
+	}
+	invalid := Encrypted(NewMemory(), NewKeyRing(1, []byte("short")))
+	if err := invalid.Set(ds.NewKey("a", 0), newString("a")); err == nil {
+		t.Errorf("Set() error = nil, want an error of the invalid key")
+	}
+}
+
+func TestEncrypted_Rotation(t *testing.T) {
+	for _, names := range []bool{false, true} {
+		t.Run("names="+strconv.FormatBool(names), func(t *testing.T) {
+			inner := NewMemory()
+			keys := NewKeyRing(1, testKey(1))
+			e := Encrypted(inner, keys)
+			e.SetEncryptNames(names)
+			_ = e.Init()
+			for i := 0; i < 10; i++ {
+				_ = e.Set(ds.NewKey("key"+strconv.Itoa(i), 0), newString("v1"))
+			}
+			keys.Add(2, testKey(2))
+			// the records of both keys are read
+			_ = e.Set(ds.NewKey("key0", 0), newString("v2"))
+			_ = e.Set(ds.NewKey("new", 0), newString("v2"))
+			for i := 0; i < 10; i++ {
+				v, err := e.Get(ds.NewKey("key"+strconv.Itoa(i), 0))
+				if err != nil || len(v.GetValue()) != 2 {
+					t.Fatalf("Get() = %v, %v, want the value", v, err)
+				}
+			}
+			if keys := inner.data; len(keys) != 11 {
+				t.Errorf("inner storage = %d keys, want 11 without the stale names", len(keys))
+			}
+
+			n, err := e.Reencrypt(context.Background())
+			if err != nil || n != 9 {
+				t.Fatalf("Reencrypt() = %d, %v, want 9 records", n, err)
+			}
+			if n, _ = e.Reencrypt(context.Background()); n != 0 {
+				t.Errorf("Reencrypt() = %d again, want 0", n)
+			}
+			keys.Remove(1)
+			var scanned int
+			e.ScanKeys(func(key *ds.Key) bool {
+				scanned++
+				if _, err := e.Get(key); err != nil {
+					t.Errorf("Get(%s) error = %v after the removal of the previous key", key.Name, err)
+				}
+				return true
+			})
+			if scanned != 11 {
+				t.Errorf("ScanKeys() = %d keys, want 11", scanned)
+			}
+			if _, err = e.Get(ds.NewKey("key1", 0)); err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			keys.Add(3, testKey(3))
+			if _, err = e.Reencrypt(ctx); !errors.Is(err, context.Canceled) {
+				t.Errorf("Reencrypt() error = %v, want %v", err, context.Canceled)
+			}
+		})
+	}
+}
+
+func TestEncrypted_Capabilities(t *testing.T) {
+	for _, names := range []bool{false, true} {
+		t.Run("names="+strconv.FormatBool(names), func(t *testing.T) {
+			inner := NewMemory()
+			e := Encrypted(inner, NewKeyRing(1, testKey(1)))
+			e.SetEncryptNames(names)
+			_ = e.Init()
+			for _, name := range []string{"user:c", "user:a", "other", "user:b"} {
+				_ = e.Set(ds.NewKey(name, 0), newString("v"))
+			}
+			_ = e.Set(ds.NewKey("user:d", 1<<40), newString("v"))
+			var scanned []string
+			err := e.ScanPrefix("user:", "user:b", func(key *ds.Key) bool {
+				scanned = append(scanned, key.Name)
+				return true
+			})
+			if err != nil || strings.Join(scanned, " ") != "user:b user:c user:d" {
+				t.Errorf("ScanPrefix() = %v, %v, want [user:b user:c user:d]", scanned, err)
+			}
+			scanned = nil
+			err = e.ScanPersistentPrefix("user:", func(key *ds.Key) bool {
+				scanned = append(scanned, key.Name)
+				return true
+			})
+			if err != nil || strings.Join(scanned, " ") != "user:a user:b user:c" {
+				t.Errorf("ScanPersistentPrefix() = %v, %v, want [user:a user:b user:c]", scanned, err)
+			}
+			want, _ := inner.Stats()
+			if stats, err := e.Stats(); err != nil || stats != want || stats.Keys != 5 {
+				t.Errorf("Stats() = %+v, %v, want %+v", stats, err, want)
+			}
+			if _, err = e.ListSnapshots(); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("ListSnapshots() error = %v, want %v", err, ErrUnsupported)
+			}
+		})
+	}
+}
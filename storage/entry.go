@@ -1,13 +1,18 @@
 package storage
 
 import (
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash/crc32"
 
 	"github.com/diiyw/nodis/ds"
 	"github.com/diiyw/nodis/ds/hash"
 	"github.com/diiyw/nodis/ds/list"
 	"github.com/diiyw/nodis/ds/set"
 	"github.com/diiyw/nodis/ds/str"
+	"github.com/diiyw/nodis/ds/timeseries"
+	"github.com/diiyw/nodis/ds/vector"
 	"github.com/diiyw/nodis/ds/zset"
 )
 
@@ -21,10 +26,28 @@ type Entry struct {
 	Value []byte
 }
 
-func (e *Entry) encode() []byte {
-	var b = make([]byte, 1+len(e.Value))
-	b[0] = e.Type
-	copy(b[1:], e.Value)
+const (
+	// entryVersion starts the entries, the type, the length of the payload and the CRC32C of the
+	// header and the payload follow. The entries of the previous format start by their type
+	// which is lower than entryLegacyMax.
+	entryVersion    = 0xc1
+	entryLegacyMax  = 0xc0
+	entryHeaderSize = 1 + 1 + 4 + 4
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// encode encodes the entry, the payload is the value compressed by the codec of the storage
+// which is kept in the high bits of the type
+func (e *Entry) encode(c *compression) []byte {
+	codec, value := c.compress(e.Value)
+	var b = make([]byte, entryHeaderSize+len(value))
+	b[0] = entryVersion
+	b[1] = e.Type | uint8(codec)<<codecShift
+	binary.BigEndian.PutUint32(b[2:], uint32(len(value)))
+	copy(b[entryHeaderSize:], value)
+	crc := crc32.Update(crc32.Checksum(b[:6], crc32c), crc32c, value)
+	binary.BigEndian.PutUint32(b[6:], crc)
 	return b
 }
 
@@ -32,8 +55,35 @@ func (e *Entry) from(b []byte) error {
 	if len(b) < 1 {
 		return ErrCorruptedData
 	}
-	e.Type = b[0]
-	e.Value = b[1:]
+	if b[0] < entryLegacyMax {
+		// type and value without checksum
+		return e.fromPayload(b[0], b[1:])
+	}
+	if b[0] != entryVersion {
+		return fmt.Errorf("%w: unknown entry version %#x", ErrCorruptedData, b[0])
+	}
+	if len(b) < entryHeaderSize {
+		return fmt.Errorf("%w: truncated entry header", ErrCorruptedData)
+	}
+	length := binary.BigEndian.Uint32(b[2:])
+	if uint64(len(b)-entryHeaderSize) != uint64(length) {
+		return fmt.Errorf("%w: entry payload of %d bytes, want %d", ErrCorruptedData, len(b)-entryHeaderSize, length)
+	}
+	payload := b[entryHeaderSize:]
+	crc := crc32.Update(crc32.Checksum(b[:6], crc32c), crc32c, payload)
+	if crc != binary.BigEndian.Uint32(b[6:]) {
+		return fmt.Errorf("%w: entry checksum mismatch", ErrCorruptedData)
+	}
+	return e.fromPayload(b[1], payload)
+}
+
+func (e *Entry) fromPayload(typ byte, payload []byte) error {
+	e.Type = typ & typeMask
+	value, err := decompress(Codec(typ>>codecShift), payload)
+	if err != nil {
+		return err
+	}
+	e.Value = value
 	return nil
 }
 
@@ -46,8 +96,14 @@ func NewEntry(v ds.Value) *Entry {
 	return e
 }
 
-func (e *Entry) GetValue() (ds.Value, error) {
-	var value ds.Value
+// GetValue decodes the value, ErrCorruptedData is returned if its type is unknown or its
+// data can't be decoded
+func (e *Entry) GetValue() (value ds.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			value, err = nil, fmt.Errorf("%w: %v", ErrCorruptedData, r)
+		}
+	}()
 	switch ds.ValueType(e.Type) {
 	case ds.String:
 		v := str.NewString()
@@ -69,8 +125,16 @@ func (e *Entry) GetValue() (ds.Value, error) {
 		v := set.NewSet()
 		v.SetValue(e.Value)
 		value = v
+	case ds.TimeSeries:
+		t := timeseries.NewTimeSeries()
+		t.SetValue(e.Value)
+		value = t
+	case ds.VectorSet:
+		v := vector.NewVectorSet(vector.Options{})
+		v.SetValue(e.Value)
+		value = v
 	default:
-		panic("unhandled default case")
+		return nil, fmt.Errorf("%w: unknown value type %d", ErrCorruptedData, e.Type)
 	}
 	return value, nil
 }
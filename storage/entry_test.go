@@ -1,11 +1,16 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
 	"reflect"
 	"testing"
 
 	"github.com/diiyw/nodis/ds"
 	"github.com/diiyw/nodis/ds/str"
+	"github.com/diiyw/nodis/ds/vector"
 )
 
 func TestValueEntry_EncodeDecode(t *testing.T) {
@@ -13,7 +18,7 @@ func TestValueEntry_EncodeDecode(t *testing.T) {
 	value.Set([]byte("test value"))
 	entry := NewEntry(value)
 
-	encoded := entry.encode()
+	encoded := entry.encode(&compression{})
 
 	decoded := &Entry{}
 	err := decoded.from(encoded)
@@ -34,7 +39,7 @@ func TestParseValue(t *testing.T) {
 	value := str.NewString()
 	value.Set([]byte("test value"))
 	entry := NewEntry(value)
-	encoded := entry.encode()
+	encoded := entry.encode(&compression{})
 
 	parsedEntry, err := parseEntry(encoded)
 	if err != nil {
@@ -56,3 +61,61 @@ func TestParseValue(t *testing.T) {
 		t.Errorf("parsedValue.GetValue() = %v, want %v", parsedValue.GetValue(), entry.Value)
 	}
 }
+
+func TestEntry_VectorSet(t *testing.T) {
+	value := vector.NewVectorSet(vector.Options{Quantization: vector.NoQuant, Metric: vector.L2})
+	_, _ = value.VAdd("a", []float32{1, 2, 3})
+	_, _ = value.VAdd("b", []float32{3, 2, 1})
+	entry, err := parseEntry(NewEntry(value).encode(&compression{}))
+	if err != nil {
+		t.Fatalf("parseEntry failed: %v", err)
+	}
+	parsedValue, err := entry.GetValue()
+	if err != nil {
+		t.Fatalf("GetValue failed: %v", err)
+	}
+	vs := parsedValue.(*vector.VectorSet)
+	if vs.Options() != value.Options() || vs.VCard() != 2 {
+		t.Errorf("parsedValue = %+v %v, want %+v %v", vs.Options(), vs.VCard(), value.Options(), 2)
+	}
+	if !reflect.DeepEqual(vs.VEmb("b"), []float32{3, 2, 1}) {
+		t.Errorf("VEmb() = %v, want %v", vs.VEmb("b"), []float32{3, 2, 1})
+	}
+}
+
+func TestEntry_Corrupted(t *testing.T) {
+	value := str.NewString()
+	value.Set([]byte("test value"))
+	encoded := NewEntry(value).encode(&compression{})
+
+	flipped := bytes.Clone(encoded)
+	flipped[len(flipped)-1] ^= 1
+	unknownType := NewEntry(value).encode(&compression{})
+	unknownType[1] = 42
+	binary.BigEndian.PutUint32(unknownType[6:], crc32.Update(crc32.Checksum(unknownType[:6], crc32c), crc32c, unknownType[entryHeaderSize:]))
+	for name, data := range map[string][]byte{
+		"empty":     {},
+		"header":    encoded[:5],
+		"truncated": encoded[:len(encoded)-1],
+		"trailing":  append(bytes.Clone(encoded), 0),
+		"flipped":   flipped,
+		"version":   append([]byte{0xc2}, encoded[1:]...),
+	} {
+		if _, err := parseEntry(data); !errors.Is(err, ErrCorruptedData) {
+			t.Errorf("parseEntry() %s error = %v, want %v", name, err, ErrCorruptedData)
+		}
+	}
+	entry, err := parseEntry(unknownType)
+	if err != nil {
+		t.Fatalf("parseEntry() error = %v", err)
+	}
+	if _, err = entry.GetValue(); !errors.Is(err, ErrCorruptedData) {
+		t.Errorf("GetValue() error = %v, want %v", err, ErrCorruptedData)
+	}
+
+	// the entries of the previous format are read without checksum
+	entry, err = parseEntry(append([]byte{byte(ds.String)}, "legacy"...))
+	if err != nil || entry.Type != byte(ds.String) || string(entry.Value) != "legacy" {
+		t.Errorf("parseEntry() = %+v, %v, want the legacy string", entry, err)
+	}
+}
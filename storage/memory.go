@@ -1,19 +1,34 @@
 package storage
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/diiyw/nodis/ds"
 )
 
+// memoryFileMagic starts the files of the memory storage, the keys follow as the length of the
+// encoded key, the encoded key, the length of the encoded entry and the encoded entry
+const memoryFileMagic = "NODISMEM"
+
 type KeyValue struct {
-	key   *ds.Key
-	value ds.Value
+	key ds.Key
+	// entry is the encoded Entry of the value, the value is copied so that changing it doesn't change the storage
+	entry []byte
 }
 
 type Memory struct {
 	sync.RWMutex
+	compression
 	data map[string]KeyValue
+	// path is the file the storage is persisted to, empty for none
+	path string
 }
 
 func NewMemory() *Memory {
@@ -22,29 +37,106 @@ func NewMemory() *Memory {
 	}
 }
 
-// Init initializes the storage.
+// NewMemoryWithFile creates a memory storage persisted to the file, the file is loaded by Init and
+// written by Snapshot and Close
+func NewMemoryWithFile(path string) *Memory {
+	m := NewMemory()
+	m.path = path
+	return m
+}
+
+// Persistent returns if the storage is persisted to a file
+func (m *Memory) Persistent() bool {
+	return m.path != ""
+}
+
+// Init initializes the storage, the file of the storage is loaded if any.
 func (m *Memory) Init() error {
+	if m.path == "" {
+		return nil
+	}
+	f, err := os.Open(m.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	data, err := readMemoryFile(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	m.data = data
+	m.Unlock()
 	return nil
 }
 
-// Get returns a value from the storage.
+// readMemoryFile reads the keys of a file of the storage
+func readMemoryFile(r *bufio.Reader) (map[string]KeyValue, error) {
+	magic := make([]byte, len(memoryFileMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != memoryFileMagic {
+		return nil, ErrCorruptedData
+	}
+	data := make(map[string]KeyValue)
+	readBytes := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil || n > 1<<32 {
+			return nil, ErrCorruptedData
+		}
+		b, err := io.ReadAll(io.LimitReader(r, int64(n)))
+		if err != nil || uint64(len(b)) != n {
+			return nil, ErrCorruptedData
+		}
+		return b, nil
+	}
+	for {
+		if _, err := r.Peek(1); err == io.EOF {
+			return data, nil
+		}
+		encoded, err := readBytes()
+		if err != nil {
+			return nil, err
+		}
+		key, err := ds.DecodeKey(encoded)
+		if err != nil {
+			return nil, ErrCorruptedData
+		}
+		entry, err := readBytes()
+		if err != nil {
+			return nil, err
+		}
+		if len(entry) < 1 {
+			return nil, ErrCorruptedData
+		}
+		data[string(encoded)] = KeyValue{key: *key, entry: entry}
+	}
+}
+
+// Get returns a copy of a value from the storage.
 func (m *Memory) Get(key *ds.Key) (ds.Value, error) {
 	m.RLock()
-	defer m.RUnlock()
 	v, ok := m.data[string(key.Encode())]
+	m.RUnlock()
 	if !ok {
 		return nil, ErrKeyNotFound
 	}
-	return v.value, nil
+	entry, err := parseEntry(bytes.Clone(v.entry))
+	if err != nil {
+		return nil, err
+	}
+	return entry.GetValue()
 }
 
-// Set sets a value in the storage.
+// Set sets a copy of a value in the storage.
 func (m *Memory) Set(key *ds.Key, value ds.Value) error {
+	entry := NewEntry(value).encode(&m.compression)
 	m.Lock()
 	defer m.Unlock()
 	m.data[string(key.Encode())] = KeyValue{
-		key:   key,
-		value: value,
+		key:   *key,
+		entry: entry,
 	}
 	return nil
 }
@@ -57,14 +149,52 @@ func (m *Memory) Delete(key *ds.Key) error {
 	return nil
 }
 
-// Close closes the storage.
+// Close closes the storage, it's written to its file if any.
 func (m *Memory) Close() error {
-	return nil
+	return m.Snapshot()
 }
 
-// Snapshot creates a snapshot of the storage.
+// Snapshot writes the storage to its file if any. The file is replaced once completely written.
 func (m *Memory) Snapshot() error {
-	return nil
+	if m.path == "" {
+		return nil
+	}
+	// the entries are never changed, they are written without holding the lock
+	m.RLock()
+	kvs := make([]KeyValue, 0, len(m.data))
+	for _, kv := range m.data {
+		kvs = append(kvs, kv)
+	}
+	m.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), filepath.Base(m.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	_, _ = w.WriteString(memoryFileMagic)
+	var buf []byte
+	for _, kv := range kvs {
+		encoded := kv.key.Encode()
+		buf = binary.AppendUvarint(buf[:0], uint64(len(encoded)))
+		buf = append(buf, encoded...)
+		buf = binary.AppendUvarint(buf, uint64(len(kv.entry)))
+		_, _ = w.Write(buf)
+		_, _ = w.Write(kv.entry)
+	}
+	if err = w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.path)
 }
 
 // ScanKeys returns the keys in the storage.
@@ -72,7 +202,8 @@ func (m *Memory) ScanKeys(f func(*ds.Key) bool) {
 	m.RLock()
 	defer m.RUnlock()
 	for _, kv := range m.data {
-		if !f(kv.key) {
+		key := kv.key
+		if !f(&key) {
 			break
 		}
 	}
@@ -85,3 +216,50 @@ func (m *Memory) Clear() error {
 	m.data = make(map[string]KeyValue)
 	return nil
 }
+
+// WriteBatch applies the changes of the batch at once.
+func (m *Memory) WriteBatch(b *Batch) error {
+	entries := make([][]byte, b.Len())
+	for i, op := range b.Ops() {
+		if op.Value != nil {
+			entries[i] = NewEntry(op.Value).encode(&m.compression)
+		}
+	}
+	m.Lock()
+	defer m.Unlock()
+	for i, op := range b.Ops() {
+		if entries[i] == nil {
+			delete(m.data, string(op.Key.Encode()))
+			continue
+		}
+		m.data[string(op.Key.Encode())] = KeyValue{key: *op.Key, entry: entries[i]}
+	}
+	return nil
+}
+
+// ScanPrefix calls fn with the keys of the prefix in the order of the names from start.
+func (m *Memory) ScanPrefix(prefix, start string, fn func(*ds.Key) bool) error {
+	var keys []*ds.Key
+	m.RLock()
+	for _, kv := range m.data {
+		if strings.HasPrefix(kv.key.Name, prefix) && kv.key.Name >= start {
+			key := kv.key
+			keys = append(keys, &key)
+		}
+	}
+	m.RUnlock()
+	sortKeys(keys)
+	scanSorted(keys, fn)
+	return nil
+}
+
+// Stats returns the number of the keys and the size of the encoded keys and values.
+func (m *Memory) Stats() (Stats, error) {
+	m.RLock()
+	defer m.RUnlock()
+	stats := Stats{Keys: int64(len(m.data))}
+	for encoded, kv := range m.data {
+		stats.Bytes += int64(len(encoded) + len(kv.entry))
+	}
+	return stats, nil
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/str"
+)
+
+func TestMemory_Copies(t *testing.T) {
+	m := NewMemory()
+	key := ds.NewKey("key", 0)
+	value := str.NewString()
+	value.Set([]byte("a"))
+	_ = m.Set(key, value)
+	// changing the value doesn't change the storage
+	value.Set([]byte("b"))
+	v, err := m.Get(key)
+	if err != nil || string(v.GetValue()) != "a" {
+		t.Fatalf("Get() = %v, %v, want a", v, err)
+	}
+	v.(*str.String).Set([]byte("c"))
+	if v, _ = m.Get(key); string(v.GetValue()) != "a" {
+		t.Errorf("Get() = %s, want a", v.GetValue())
+	}
+}
+
+func TestMemory_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	m := NewMemoryWithFile(path)
+	if err := m.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	_ = m.Set(ds.NewKey("a", 0), newString("1"))
+	_ = m.Set(ds.NewKey("b", 100), newString("2"))
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("ReadDir() = %v, want only the file of the storage", entries)
+	}
+
+	m = NewMemoryWithFile(path)
+	if err := m.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if v, err := m.Get(ds.NewKey("b", 100)); err != nil || string(v.GetValue()) != "2" {
+		t.Errorf("Get() = %v, %v, want 2", v, err)
+	}
+	var keys int
+	m.ScanKeys(func(key *ds.Key) bool {
+		keys++
+		if key.Name == "b" && key.Expiration != 100 {
+			t.Errorf("ScanKeys() key = %v, want the expiration 100", key)
+		}
+		return true
+	})
+	if keys != 2 {
+		t.Errorf("ScanKeys() = %d keys, want 2", keys)
+	}
+
+	_ = os.WriteFile(path, []byte("NODISMEM\x10"), 0644)
+	if err := NewMemoryWithFile(path).Init(); err != ErrCorruptedData {
+		t.Errorf("Init() error = %v, want %v", err, ErrCorruptedData)
+	}
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"time"
@@ -10,9 +11,11 @@ import (
 )
 
 type Pebble struct {
-	path    string
-	options *pebble.Options
-	db      *pebble.DB
+	compression
+	path      string
+	options   *pebble.Options
+	db        *pebble.DB
+	snapshots SnapshotOptions
 }
 
 // NewPebble creates a new Pebble storage
@@ -23,7 +26,25 @@ func NewPebble(path string, options *pebble.Options) *Pebble {
 	}
 }
 
+// SetSnapshotOptions sets the directory and the retention of the snapshots
+func (p *Pebble) SetSnapshotOptions(opts SnapshotOptions) {
+	p.snapshots = opts
+}
+
+// snapshotOptions returns the snapshot options with the default directory
+func (p *Pebble) snapshotOptions() SnapshotOptions {
+	opts := p.snapshots
+	if opts.Dir == "" {
+		opts.Dir = filepath.Clean(p.path) + "-snapshots"
+	}
+	return opts
+}
+
+// Init opens the storage, the data is replaced first by the snapshot of RestoreSnapshot if any
 func (p *Pebble) Init() error {
+	if err := applyRestore(p.snapshotOptions().Dir, p.path); err != nil {
+		return err
+	}
 	db, err := pebble.Open(p.path, p.options)
 	if err != nil {
 		return err
@@ -36,6 +57,9 @@ func (p *Pebble) Init() error {
 func (p *Pebble) Get(key *ds.Key) (ds.Value, error) {
 	v, closer, err := p.db.Get(key.Encode())
 	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return nil, ErrKeyNotFound
+		}
 		return nil, err
 	}
 	defer closer.Close()
@@ -49,7 +73,7 @@ func (p *Pebble) Get(key *ds.Key) (ds.Value, error) {
 // Set the value to the storage
 func (p *Pebble) Set(key *ds.Key, value ds.Value) error {
 	entry := NewEntry(value)
-	data := entry.encode()
+	data := entry.encode(&p.compression)
 	return p.db.Set(key.Encode(), data, pebble.Sync)
 }
 
@@ -81,10 +105,33 @@ func (p *Pebble) Close() error {
 	return p.db.Close()
 }
 
-// Snapshot the storage
+// Snapshot checkpoints the storage to the snapshot directory, then removes the snapshots beyond the retention
 func (p *Pebble) Snapshot() error {
-	dstDir := time.Now().Format("20060102150405")
-	return p.db.Checkpoint(filepath.Join(p.path, dstDir))
+	opts := p.snapshotOptions()
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return err
+	}
+	name := time.Now().Format(snapshotTimeFormat)
+	if err := p.db.Checkpoint(filepath.Join(opts.Dir, name), pebble.WithFlushedWAL()); err != nil {
+		return err
+	}
+	_, err := pruneSnapshots(opts)
+	return err
+}
+
+// ListSnapshots returns the snapshots from the oldest to the latest
+func (p *Pebble) ListSnapshots() ([]SnapshotInfo, error) {
+	return listSnapshots(p.snapshotOptions().Dir)
+}
+
+// PruneSnapshots removes the snapshots beyond the retention, it returns the number of the removed ones
+func (p *Pebble) PruneSnapshots() (int, error) {
+	return pruneSnapshots(p.snapshotOptions())
+}
+
+// RestoreSnapshot replaces the data by the snapshot the next time the storage is initialized
+func (p *Pebble) RestoreSnapshot(name string) error {
+	return markRestore(p.snapshotOptions().Dir, name)
 }
 
 // ScanKeys returns the keys in the storage
@@ -104,3 +151,74 @@ func (p *Pebble) ScanKeys(fn func(*ds.Key) bool) {
 		}
 	}
 }
+
+// WriteBatch writes the changes of the batch in a single synced pebble batch
+func (p *Pebble) WriteBatch(b *Batch) error {
+	batch := p.db.NewBatch()
+	defer batch.Close()
+	for _, op := range b.Ops() {
+		var err error
+		if op.Value == nil {
+			err = batch.Delete(op.Key.Encode(), nil)
+		} else {
+			err = batch.Set(op.Key.Encode(), NewEntry(op.Value).encode(&p.compression), nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return batch.Commit(pebble.Sync)
+}
+
+// Stats returns the disk space used by the storage, the keys aren't counted
+func (p *Pebble) Stats() (Stats, error) {
+	return Stats{Keys: -1, Bytes: int64(p.db.Metrics().DiskSpaceUsage())}, nil
+}
+
+type pebbleCursor struct {
+	iter *pebble.Iterator
+}
+
+func (c pebbleCursor) seek(key []byte) []byte {
+	if !c.iter.SeekGE(key) {
+		return nil
+	}
+	return c.iter.Key()
+}
+
+func (c pebbleCursor) next() []byte {
+	if !c.iter.Next() {
+		return nil
+	}
+	return c.iter.Key()
+}
+
+// ScanPrefix calls fn with the keys of the prefix in the order of the names from start, the keys
+// are sought in each group of the same expiration
+func (p *Pebble) ScanPrefix(prefix, start string, fn func(*ds.Key) bool) error {
+	return p.scanCursor(fn, func(c keyCursor) []*ds.Key {
+		return scanEncodedPrefix(c, prefix, start)
+	})
+}
+
+// ScanPersistentPrefix calls fn with the keys without expiration of the prefix in the order of
+// the names
+func (p *Pebble) ScanPersistentPrefix(prefix string, fn func(*ds.Key) bool) error {
+	return p.scanCursor(fn, func(c keyCursor) []*ds.Key {
+		return scanEncodedPersistent(c, prefix)
+	})
+}
+
+// scanCursor calls fn with the keys found by scan once the iterator is closed
+func (p *Pebble) scanCursor(fn func(*ds.Key) bool, scan func(c keyCursor) []*ds.Key) error {
+	iter, err := p.db.NewIter(nil)
+	if err != nil {
+		return err
+	}
+	keys := scan(pebbleCursor{iter: iter})
+	if err = iter.Close(); err != nil {
+		return err
+	}
+	scanSorted(keys, fn)
+	return nil
+}
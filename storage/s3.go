@@ -0,0 +1,454 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diiyw/nodis/ds"
+)
+
+const (
+	s3KeysDir      = "keys/"
+	s3ManifestName = "manifest.json"
+	s3TimeFormat   = "20060102T150405Z"
+	s3EmptyHash    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Options are the options of the client of a S3 compatible object storage
+type S3Options struct {
+	// Endpoint is the URL of the service, default https://s3.<region>.amazonaws.com
+	Endpoint string
+	// Region of the bucket, default us-east-1
+	Region string
+	// AccessKeyID and SecretAccessKey sign the requests, they are sent unsigned when empty
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// PathStyle puts the bucket in the path rather than in the host, most of the
+	// self-hosted services need it
+	PathStyle bool
+	// Client sends the requests, default http.DefaultClient
+	Client *http.Client
+	// CacheDir keeps a copy of the values in a local directory when set, the bucket must
+	// not be changed by another writer then
+	CacheDir string
+}
+
+// S3Error is an error response of the service
+type S3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *S3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+// S3Manifest is the object written by Snapshot, it lists the objects of the keys at the time
+// of the snapshot
+type S3Manifest struct {
+	Time    time.Time          `json:"time"`
+	Objects []S3ManifestObject `json:"objects"`
+}
+
+type S3ManifestObject struct {
+	Key  string `json:"key"`
+	ETag string `json:"etag"`
+	Size int64  `json:"size"`
+}
+
+// S3 is a storage keeping one object per key in a bucket of a S3 compatible object storage.
+// The objects are named by the hex of the encoded key under the prefix, so the keys are listed
+// without reading the objects.
+type S3 struct {
+	compression
+	bucket  string
+	prefix  string
+	options S3Options
+	client  *http.Client
+	cache   *Directory
+}
+
+// NewS3 creates a new storage of the objects under the prefix of the bucket
+func NewS3(bucket, prefix string, options S3Options) *S3 {
+	if options.Region == "" {
+		options.Region = "us-east-1"
+	}
+	if options.Endpoint == "" {
+		options.Endpoint = "https://s3." + options.Region + ".amazonaws.com"
+	}
+	s := &S3{
+		bucket:  bucket,
+		prefix:  prefix,
+		options: options,
+		client:  options.Client,
+	}
+	if s.client == nil {
+		s.client = http.DefaultClient
+	}
+	if options.CacheDir != "" {
+		s.cache = NewDirectory(options.CacheDir)
+	}
+	return s
+}
+
+// Init checks the bucket is reachable and creates the cache directory
+func (s *S3) Init() error {
+	resp, err := s.do(http.MethodHead, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if s.cache != nil {
+		return s.cache.Init()
+	}
+	return nil
+}
+
+// object returns the name of the object of the key
+func (s *S3) object(key *ds.Key) string {
+	return s.prefix + s3KeysDir + hex.EncodeToString(key.Encode())
+}
+
+// Get the value from the storage
+func (s *S3) Get(key *ds.Key) (ds.Value, error) {
+	if s.cache != nil {
+		if v, err := s.cache.Get(key); err == nil {
+			return v, nil
+		}
+	}
+	resp, err := s.do(http.MethodGet, s.object(key), nil, nil)
+	if err != nil {
+		if e, ok := err.(*S3Error); ok && e.StatusCode == http.StatusNotFound {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := parseEntry(data)
+	if err != nil {
+		return nil, err
+	}
+	v, err := entry.GetValue()
+	if err != nil {
+		return nil, err
+	}
+	if s.cache != nil {
+		_ = s.cache.Set(key, v)
+	}
+	return v, nil
+}
+
+// Set the value to the storage
+func (s *S3) Set(key *ds.Key, value ds.Value) error {
+	resp, err := s.do(http.MethodPut, s.object(key), nil, NewEntry(value).encode(&s.compression))
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if s.cache != nil {
+		return s.cache.Set(key, value)
+	}
+	return nil
+}
+
+// Delete the value from the storage
+func (s *S3) Delete(key *ds.Key) error {
+	if s.cache != nil {
+		if err := s.cache.Delete(key); err != nil {
+			return err
+		}
+	}
+	return s.deleteObject(s.object(key))
+}
+
+func (s *S3) deleteObject(name string) error {
+	resp, err := s.do(http.MethodDelete, name, nil, nil)
+	if err != nil {
+		if e, ok := err.(*S3Error); ok && e.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// Clear the storage, the manifest of the last snapshot is kept
+func (s *S3) Clear() error {
+	if s.cache != nil {
+		if err := s.cache.Clear(); err != nil {
+			return err
+		}
+	}
+	var names []string
+	err := s.list(s.prefix+s3KeysDir, func(o s3Object) bool {
+		names = append(names, o.Key)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err = s.deleteObject(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close the storage
+func (s *S3) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// Snapshot writes the manifest object listing the objects of the keys, every change is
+// written to its object right away
+func (s *S3) Snapshot() error {
+	manifest := S3Manifest{Time: time.Now().UTC(), Objects: []S3ManifestObject{}}
+	err := s.list(s.prefix+s3KeysDir, func(o s3Object) bool {
+		manifest.Objects = append(manifest.Objects, S3ManifestObject{Key: o.Key, ETag: o.ETag, Size: o.Size})
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodPut, s.prefix+s3ManifestName, nil, data)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Manifest reads the manifest of the last snapshot
+func (s *S3) Manifest() (*S3Manifest, error) {
+	resp, err := s.do(http.MethodGet, s.prefix+s3ManifestName, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	manifest := &S3Manifest{}
+	if err = json.NewDecoder(resp.Body).Decode(manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ScanKeys returns the keys in the storage
+func (s *S3) ScanKeys(fn func(*ds.Key) bool) {
+	dir := s.prefix + s3KeysDir
+	_ = s.list(dir, func(o s3Object) bool {
+		b, err := hex.DecodeString(strings.TrimPrefix(o.Key, dir))
+		if err != nil {
+			return true
+		}
+		key, err := ds.DecodeKey(b)
+		if err != nil {
+			return true
+		}
+		return fn(key)
+	})
+}
+
+type s3Object struct {
+	Key  string `xml:"Key"`
+	ETag string `xml:"ETag"`
+	Size int64  `xml:"Size"`
+}
+
+type s3ListResult struct {
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+}
+
+// list calls fn with the objects of the prefix until it returns false
+func (s *S3) list(prefix string, fn func(s3Object) bool) error {
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		resp, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		for _, o := range result.Contents {
+			if !fn(o) {
+				return nil
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// do sends the request of the object, the bucket when name is empty, and returns a S3Error
+// when the status isn't a success
+func (s *S3) do(method, name string, query url.Values, body []byte) (*http.Response, error) {
+	u, err := url.Parse(s.options.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if s.options.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket
+		if name != "" {
+			u.Path += "/" + name
+		}
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + name
+	}
+	u.RawPath = s3EncodePath(u.Path)
+	u.RawQuery = s3EncodeQuery(query)
+	req, err := http.NewRequestWithContext(context.Background(), method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if s.options.AccessKeyID != "" {
+		s3Sign(req, body, s.options, time.Now())
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	e := &S3Error{}
+	if method != http.MethodHead {
+		data, _ := io.ReadAll(resp.Body)
+		_ = xml.Unmarshal(data, e)
+	}
+	e.StatusCode = resp.StatusCode
+	return nil, e
+}
+
+// s3Sign signs the request by the AWS signature version 4, the host and every header of the
+// request are signed
+func s3Sign(req *http.Request, body []byte, options S3Options, now time.Time) {
+	date := now.UTC().Format(s3TimeFormat)
+	payloadHash := s3EmptyHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Date", date)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if options.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", options.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date[:8] + "/" + options.Region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := []byte("AWS4" + options.SecretAccessKey)
+	for _, part := range []string{date[:8], options.Region, "s3", "aws4_request"} {
+		key = s3HMAC(key, part)
+	}
+	signature := hex.EncodeToString(s3HMAC(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+options.AccessKeyID+"/"+scope+
+		",SignedHeaders="+signedHeaders+",Signature="+signature)
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape escapes all the characters but the unreserved ones of RFC 3986, as the signature
+// requires
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3EncodePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3EncodeQuery encodes the query sorted by name
+func s3EncodeQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, s3Escape(name)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// snapshotTimeFormat is the format of the names of the snapshots
+const snapshotTimeFormat = "20060102150405.000"
+
+// restoreMarker is the file of the snapshot directory naming the snapshot to restore on Init
+const restoreMarker = "RESTORE"
+
+var (
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+)
+
+// SnapshotOptions are the options of the snapshots of a storage
+type SnapshotOptions struct {
+	// Dir is the directory of the snapshots. Default the data directory suffixed by "-snapshots".
+	Dir string
+	// Retain is the number of the latest snapshots kept. Default 0 for keeping them all.
+	Retain int
+	// MaxAge is the age after which the snapshots are removed. Default 0 for keeping them all.
+	MaxAge time.Duration
+}
+
+// SnapshotInfo describes a snapshot
+type SnapshotInfo struct {
+	Name string
+	Time time.Time
+	// Size is the size of the files of the snapshot in bytes
+	Size int64
+}
+
+// SnapshotManager is implemented by the storages keeping their snapshots
+type SnapshotManager interface {
+	// ListSnapshots returns the snapshots from the oldest to the latest
+	ListSnapshots() ([]SnapshotInfo, error)
+	// PruneSnapshots removes the snapshots beyond the retention, it returns the number of the removed ones
+	PruneSnapshots() (int, error)
+	// RestoreSnapshot replaces the data by the snapshot the next time the storage is initialized
+	RestoreSnapshot(name string) error
+}
+
+// listSnapshots returns the snapshots of the directory from the oldest to the latest
+func listSnapshots(dir string) ([]SnapshotInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var snapshots []SnapshotInfo
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		t, err := time.ParseInLocation(snapshotTimeFormat, entry.Name(), time.Local)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, SnapshotInfo{
+			Name: entry.Name(),
+			Time: t,
+			Size: dirSize(filepath.Join(dir, entry.Name())),
+		})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+// dirSize returns the size of the files of the directory
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// pruneSnapshots removes the snapshots of the directory beyond the retention
+func pruneSnapshots(opts SnapshotOptions) (int, error) {
+	snapshots, err := listSnapshots(opts.Dir)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	var removed int
+	for i, s := range snapshots {
+		old := opts.MaxAge > 0 && now.Sub(s.Time) > opts.MaxAge
+		extra := opts.Retain > 0 && i < len(snapshots)-opts.Retain
+		if !old && !extra {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(opts.Dir, s.Name)); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// markRestore records the snapshot to restore the next time the storage is initialized
+func markRestore(dir, name string) error {
+	if _, err := time.ParseInLocation(snapshotTimeFormat, name, time.Local); err != nil {
+		return ErrSnapshotNotFound
+	}
+	if info, err := os.Stat(filepath.Join(dir, name)); err != nil || !info.IsDir() {
+		return ErrSnapshotNotFound
+	}
+	return os.WriteFile(filepath.Join(dir, restoreMarker), []byte(name), 0644)
+}
+
+// applyRestore replaces the data directory by a copy of the snapshot to restore if any
+func applyRestore(dir, path string) error {
+	name, err := os.ReadFile(filepath.Join(dir, restoreMarker))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	src := filepath.Join(dir, string(name))
+	// the snapshot is copied aside first so that the data is kept if the copy fails
+	tmp := path + ".restoring"
+	if err = os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err = copyDir(src, tmp); err != nil {
+		_ = os.RemoveAll(tmp)
+		return err
+	}
+	if err = os.RemoveAll(path); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(dir, restoreMarker))
+}
+
+// copyDir copies the directory. The immutable table files are hard linked when possible like
+// Pebble checkpoints do, the other files are appended to.
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if filepath.Ext(path) == ".sst" && os.Link(path, target) == nil {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
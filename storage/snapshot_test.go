@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/str"
+)
+
+func newString(v string) ds.Value {
+	s := str.NewString()
+	s.Set([]byte(v))
+	return s
+}
+
+func TestPebble_Snapshots(t *testing.T) {
+	dir := t.TempDir()
+	p := NewPebble(filepath.Join(dir, "data"), nil)
+	p.SetSnapshotOptions(SnapshotOptions{Retain: 2})
+	if err := p.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	key := ds.NewKey("key", 0)
+	for _, v := range []string{"a", "b", "c"} {
+		if err := p.Set(key, newString(v)); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		if err := p.Snapshot(); err != nil {
+			t.Fatalf("Snapshot() error = %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	snapshots, err := p.ListSnapshots()
+	if err != nil {
+		t.Fatalf("ListSnapshots() error = %v", err)
+	}
+	if len(snapshots) != 2 || !snapshots[0].Time.Before(snapshots[1].Time) || snapshots[0].Size == 0 {
+		t.Fatalf("ListSnapshots() = %v, want the 2 latest snapshots", snapshots)
+	}
+	// the snapshots are out of the data directory
+	if _, err = os.Stat(filepath.Join(dir, "data-snapshots", snapshots[0].Name)); err != nil {
+		t.Errorf("Stat() error = %v", err)
+	}
+
+	if err = p.RestoreSnapshot("missing"); err != ErrSnapshotNotFound {
+		t.Errorf("RestoreSnapshot() error = %v, want %v", err, ErrSnapshotNotFound)
+	}
+	if err = p.RestoreSnapshot(snapshots[0].Name); err != nil {
+		t.Fatalf("RestoreSnapshot() error = %v", err)
+	}
+	_ = p.Set(key, newString("d"))
+	_ = p.Close()
+	if err = p.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if v, err := p.Get(key); err != nil || string(v.GetValue()) != "b" {
+		t.Errorf("Get() = %v, %v, want the value of the snapshot", v, err)
+	}
+	// the restored data doesn't change the snapshot
+	_ = p.Set(key, newString("e"))
+	_ = p.Close()
+	_ = p.RestoreSnapshot(snapshots[0].Name)
+	_ = p.Init()
+	if v, err := p.Get(key); err != nil || string(v.GetValue()) != "b" {
+		t.Errorf("Get() = %v, %v, want the value of the snapshot", v, err)
+	}
+	_ = p.Close()
+
+	p.SetSnapshotOptions(SnapshotOptions{MaxAge: time.Nanosecond})
+	if removed, err := p.PruneSnapshots(); err != nil || removed != 2 {
+		t.Errorf("PruneSnapshots() = %v, %v, want 2", removed, err)
+	}
+}
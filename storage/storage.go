@@ -8,6 +8,9 @@ import (
 
 var (
 	ErrKeyNotFound = errors.New("key not found")
+	// ErrUnsupported is returned by a storage wrapping another one when the wrapped storage lacks
+	// the capability
+	ErrUnsupported = errors.New("the storage doesn't support the operation")
 )
 
 type Storage interface {
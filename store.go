@@ -2,10 +2,13 @@ package nodis
 
 import (
 	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
 	"github.com/diiyw/nodis/storage"
 
 	"github.com/diiyw/nodis/ds/list"
@@ -19,21 +22,58 @@ type store struct {
 	closed      bool
 	watchMu     sync.RWMutex
 	watchedKeys map[string]*list.LinkedListG[*redis.Conn]
+	// lastGC is the unix time in milliseconds of the last gc
+	lastGC int64
+	// snapshot is the running snapshot of the keys
+	snapshot atomic.Pointer[snapshot]
+	// quarantined are the keys whose stored value is corrupted
+	quarantineMu sync.Mutex
+	quarantined  map[string]error
+	// explodeThreshold is the number of fields from which the collections are stored exploded
+	explodeThreshold int
+	// removed are the keys deleted whose exploded records are still in the storage
+	removed map[string]*ds.Key
+	// listOptions are the options of the lists read from the storage
+	listOptions list.Options
+	// encodings are the encodings of the hashes, sets and sorted sets
+	encodings *ds.Encodings
+	// used is the estimated memory used by the values, maxMemory its limit and policy the
+	// EvictionPolicy of the values when it's exceeded
+	used          atomic.Int64
+	maxMemory     atomic.Int64
+	policy        atomic.Uint32
+	evictMu       sync.Mutex
+	evictedKeys   atomic.Int64
+	offloadedKeys atomic.Int64
+	// deleted is called with the keys deleted by the store rather than by a command: the keys
+	// evicted and the hashes whose last field expired
+	deleted func(m *metadata)
 }
 
-func newStore(ss storage.Storage) *store {
+func newStore(ss storage.Storage, explodeThreshold int) *store {
 	s := &store{
-		ss:          ss,
-		metadata:    make(map[string]*metadata),
-		watchedKeys: make(map[string]*list.LinkedListG[*redis.Conn]),
+		ss:               ss,
+		explodeThreshold: explodeThreshold,
+		metadata:         make(map[string]*metadata),
+		watchedKeys:      make(map[string]*list.LinkedListG[*redis.Conn]),
+		quarantined:      make(map[string]error),
+		removed:          make(map[string]*ds.Key),
 	}
 	err := s.ss.Init()
 	if err != nil {
 		log.Fatal(err)
 	}
 	s.ss.ScanKeys(func(key *ds.Key) bool {
+		if strings.HasPrefix(key.Name, explodedFieldPrefix) {
+			return true
+		}
+		exploded := strings.HasPrefix(key.Name, explodedHeadPrefix)
+		if exploded {
+			key = ds.NewKey(strings.TrimPrefix(key.Name, explodedHeadPrefix), key.Expiration)
+		}
 		var m = newMetadata(key, false)
 		m.state |= KeyStateNormal
+		m.exploded = exploded
 		s.metadata[key.Name] = m
 		return true
 	})
@@ -45,6 +85,9 @@ func (s *store) flush() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	now := time.Now().UnixMilli()
+	var batch storage.Batch
+	requeue := s.removeDeleted(&batch)
+	var saved []*metadata
 	for _, m := range s.metadata {
 		m.Lock()
 		defer m.Unlock()
@@ -54,11 +97,17 @@ func (s *store) flush() {
 		if m.value == nil {
 			continue
 		}
-		// save to storage
-		err := s.ss.Set(m.key, m.value)
-		if err != nil {
-			log.Println("Flush changes: ", err)
-		}
+		s.save(&batch, m)
+		saved = append(saved, m)
+	}
+	// save to storage while the keys are locked
+	err := storage.WriteBatch(s.ss, &batch)
+	if err != nil {
+		log.Println("Flush changes: ", err)
+	}
+	requeue(err)
+	for _, m := range saved {
+		s.saved(m, err)
 	}
 }
 
@@ -70,24 +119,73 @@ func (s *store) gc() {
 		return
 	}
 	now := time.Now().UnixMilli()
+	var batch storage.Batch
+	requeue := s.removeDeleted(&batch)
+	var saved []*metadata
 	for key, m := range s.metadata {
 		m.Lock()
 		defer m.Unlock()
 		if m.expired(now) || !m.isOk() {
 			delete(s.metadata, key)
+			s.forget(m)
+			if m.exploded {
+				// the field records don't expire with the head record
+				s.remove(&batch, m)
+			}
 			continue
 		}
-		if m.modified() {
-			err := s.ss.Set(m.key, m.value)
-			if err != nil {
-				log.Println("GC: ", err)
+		// remove the expired hash fields
+		if h, ok := m.value.(*hash.HashMap); ok && h.RemoveExpired(now) > 0 {
+			if h.HLen() == 0 {
+				delete(s.metadata, key)
+				s.forget(m)
+				s.remove(&batch, m)
+				if s.deleted != nil {
+					s.deleted(m)
+				}
+				continue
 			}
+			m.state |= KeyStateModified
 		}
+		if m.modified() {
+			s.save(&batch, m)
+			saved = append(saved, m)
+		}
+	}
+	err := storage.WriteBatch(s.ss, &batch)
+	requeue(err)
+	for _, m := range saved {
+		s.saved(m, err)
+	}
+	if err != nil {
+		// the values are kept in memory until they are saved
+		log.Println("GC: ", err)
+		return
+	}
+	for _, m := range s.metadata {
 		m.reset()
-		if m.count.Load() < 0 {
+		// the keys not accessed since the last gc are only kept in the storage
+		if m.access.Load() < s.lastGC {
 			m.removeFromMemory()
+			s.forget(m)
 		}
 	}
+	s.lastGC = now
+}
+
+// quarantine removes a key whose stored value is corrupted, the record is left in the storage
+// until the key is written again
+func (s *store) quarantine(m *metadata, err error) {
+	log.Printf("Quarantine key %q: %v", m.key.Name, err)
+	s.quarantineMu.Lock()
+	s.quarantined[m.key.Name] = err
+	s.quarantineMu.Unlock()
+	s.mu.Lock()
+	if s.metadata[m.key.Name] == m {
+		delete(s.metadata, m.key.Name)
+		s.forget(m)
+	}
+	s.mu.Unlock()
 }
 
 // close the store
@@ -99,8 +197,13 @@ func (s *store) close() error {
 
 // clear the store
 func (s *store) clear() error {
+	if sn := s.snapshot.Load(); sn != nil {
+		sn.copyAll(s)
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	clear(s.metadata)
+	clear(s.removed)
+	s.used.Store(0)
 	return s.ss.Clear()
 }
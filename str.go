@@ -1,6 +1,8 @@
 package nodis
 
 import (
+	"maps"
+	"slices"
 	"strconv"
 	"time"
 	"unsafe"
@@ -14,119 +16,159 @@ func (n *Nodis) newStr() ds.Value {
 	return str.NewString()
 }
 
-// Set a key with a value and a TTL
-func (n *Nodis) Set(key string, value []byte, keepTTL bool) {
+// SetOptions are the options of SetWithOptions
+type SetOptions struct {
+	// NX only sets the key if it doesn't exist
+	NX bool
+	// XX only sets the key if it exists
+	XX bool
+	// ExpireAt is the unix time in milliseconds when the key expires, 0 removes the expiration
+	ExpireAt int64
+	// KeepTTL keeps the expiration of the key
+	KeepTTL bool
+	// Get returns the old value of the key, it must be a string
+	Get bool
+}
+
+// SetWithOptions sets the key and its expiration at once, it returns the old value
+// if opts.Get is true and whether the key is set
+func (n *Nodis) SetWithOptions(key string, value []byte, opts *SetOptions) ([]byte, bool) {
+	var old []byte
+	var ok bool
 	_ = n.exec(func(tx *Tx) error {
-		meta := tx.writeKey(key, n.newStr)
+		meta := tx.writeKey(key, nil)
+		exists := meta.isOk()
+		if opts.Get && exists {
+			old = meta.value.(*str.String).Get()
+		}
+		if (opts.NX && exists) || (opts.XX && !exists) {
+			return nil
+		}
+		if !exists {
+			meta = tx.newStoredMetadata(meta, n.newStr)
+		} else if _, isStr := meta.value.(*str.String); !isStr {
+			// SET overwrites the keys of any type
+			if meta.valueType == ds.Hash {
+				n.indexHash(key, nil)
+			}
+			tx.resetMeta(meta, n.newStr)
+		}
 		meta.value.(*str.String).Set(value)
-		if !keepTTL {
-			meta.key.Expiration = 0
+		if !opts.KeepTTL {
+			meta.key.Expiration = opts.ExpireAt
 		}
+		ok = true
 		n.signalModifiedKey(key, meta)
 		n.notify(func() []patch.Op {
-			return []patch.Op{{Type: patch.OpTypeSet, Data: &patch.OpSet{Key: key, Value: value, KeepTTL: keepTTL}}}
+			return []patch.Op{{Type: patch.OpTypeSet, Data: &patch.OpSet{Key: key, Value: value, KeepTTL: opts.KeepTTL, Expiration: opts.ExpireAt}}}
 		})
 		return nil
 	})
+	return old, ok
+}
+
+// Set a key with a value and a TTL
+func (n *Nodis) Set(key string, value []byte, keepTTL bool) {
+	n.SetWithOptions(key, value, &SetOptions{KeepTTL: keepTTL})
 }
 
 // GetSet set a key with a value and return the old value
 func (n *Nodis) GetSet(key string, value []byte) []byte {
-	var v []byte
-	_ = n.exec(func(tx *Tx) error {
-		meta := tx.writeKey(key, n.newStr)
-		v = meta.value.(*str.String).GetSet(value)
-		n.signalModifiedKey(key, meta)
-		n.notify(func() []patch.Op {
-			return []patch.Op{{Type: patch.OpTypeSet, Data: &patch.OpSet{Key: key, Value: value}}}
-		})
-		return nil
-	})
+	v, _ := n.SetWithOptions(key, value, &SetOptions{Get: true})
 	return v
 }
 
 // SetEX set a key with specified expire time, in seconds (a positive integer).
 func (n *Nodis) SetEX(key string, value []byte, seconds int64) {
-	_ = n.exec(func(tx *Tx) error {
-		meta := tx.writeKey(key, n.newStr)
-		meta.key.Expiration = time.Now().UnixMilli() + seconds*1000
-		meta.value.(*str.String).Set(value)
-		n.signalModifiedKey(key, meta)
-		n.notify(func() []patch.Op {
-			return []patch.Op{{Type: patch.OpTypeSet, Data: &patch.OpSet{Key: key, Value: value, Expiration: meta.key.Expiration}}}
-		})
-		return nil
-	})
+	n.SetWithOptions(key, value, &SetOptions{ExpireAt: time.Now().UnixMilli() + seconds*1000})
 }
 
 // SetPX set a key with specified expire time, in milliseconds (a positive integer).
 func (n *Nodis) SetPX(key string, value []byte, milliseconds int64) {
-	_ = n.exec(func(tx *Tx) error {
-		meta := tx.writeKey(key, n.newStr)
-		meta.key.Expiration = time.Now().UnixMilli() + milliseconds
-		n.signalModifiedKey(key, meta)
-		n.notify(func() []patch.Op {
-			return []patch.Op{{Type: patch.OpTypeSet, Data: &patch.OpSet{Key: key, Value: value, Expiration: meta.key.Expiration}}}
-		})
-		meta.value.(*str.String).Set(value)
-		return nil
-	})
+	n.SetWithOptions(key, value, &SetOptions{ExpireAt: time.Now().UnixMilli() + milliseconds})
 }
 
 // SetNX set a key with a value if it does not exist
 func (n *Nodis) SetNX(key string, value []byte, keepTTL bool) bool {
-	var ok bool
+	_, ok := n.SetWithOptions(key, value, &SetOptions{NX: true, KeepTTL: keepTTL})
+	return ok
+}
+
+// SetXX set a key with a value if it exists
+func (n *Nodis) SetXX(key string, value []byte, keepTTL bool) bool {
+	_, ok := n.SetWithOptions(key, value, &SetOptions{XX: true, KeepTTL: keepTTL})
+	return ok
+}
+
+// Get a key
+func (n *Nodis) Get(key string) []byte {
+	var v []byte
 	_ = n.exec(func(tx *Tx) error {
-		meta := tx.writeKey(key, nil)
-		if meta.isOk() {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
 			return nil
 		}
-		meta = tx.newStoredMetadata(meta, n.newStr)
-		if !keepTTL {
-			meta.key.Expiration = 0
-		}
-		meta.value.(*str.String).Set(value)
-		n.signalModifiedKey(key, meta)
-		n.notify(func() []patch.Op {
-			return []patch.Op{{Type: patch.OpTypeSet, Data: &patch.OpSet{Key: key, Value: value, KeepTTL: keepTTL}}}
-		})
-		ok = true
+		v = meta.value.(*str.String).Get()
 		return nil
 	})
-	return ok
+	return v
 }
 
-// SetXX set a key with a value if it exists
-func (n *Nodis) SetXX(key string, value []byte, keepTTL bool) bool {
-	var ok bool
+// GetEXOptions are the options of GetEX
+type GetEXOptions struct {
+	// ExpireAt is the unix time in milliseconds when the key expires, 0 keeps the expiration
+	ExpireAt int64
+	// Persist removes the expiration of the key
+	Persist bool
+}
+
+// GetEX returns the value of the key and sets or removes its expiration,
+// the key is deleted if the expiration is in the past
+func (n *Nodis) GetEX(key string, opts *GetEXOptions) []byte {
+	var v []byte
 	_ = n.exec(func(tx *Tx) error {
 		meta := tx.writeKey(key, nil)
 		if !meta.isOk() {
 			return nil
 		}
-		meta.value.(*str.String).Set(value)
-		if !keepTTL {
+		v = meta.value.(*str.String).Get()
+		var op patch.Op
+		switch {
+		case opts.Persist && meta.key.Expiration != 0:
 			meta.key.Expiration = 0
+			op = patch.Op{Type: patch.OpTypePersist, Data: &patch.OpPersist{Key: key}}
+		case opts.ExpireAt != 0 && opts.ExpireAt <= time.Now().UnixMilli():
+			tx.delKey(key)
+			op = patch.Op{Type: patch.OpTypeDel, Data: &patch.OpDel{Key: key}}
+		case opts.ExpireAt != 0:
+			meta.key.Expiration = opts.ExpireAt
+			op = patch.Op{Type: patch.OpTypeExpire, Data: &patch.OpExpire{Key: key, Expiration: opts.ExpireAt}}
+		default:
+			return nil
 		}
 		n.signalModifiedKey(key, meta)
 		n.notify(func() []patch.Op {
-			return []patch.Op{{Type: patch.OpTypeSet, Data: &patch.OpSet{Key: key, Value: value, KeepTTL: keepTTL}}}
+			return []patch.Op{op}
 		})
-		ok = true
 		return nil
 	})
-	return ok
+	return v
 }
 
-// Get a key
-func (n *Nodis) Get(key string) []byte {
+// GetDel returns the value of the key and deletes it
+func (n *Nodis) GetDel(key string) []byte {
 	var v []byte
 	_ = n.exec(func(tx *Tx) error {
-		meta := tx.readKey(key)
+		meta := tx.writeKey(key, nil)
 		if !meta.isOk() {
 			return nil
 		}
 		v = meta.value.(*str.String).Get()
+		tx.delKey(key)
+		n.signalModifiedKey(key, meta)
+		n.notify(func() []patch.Op {
+			return []patch.Op{{Type: patch.OpTypeDel, Data: &patch.OpDel{Key: key}}}
+		})
 		return nil
 	})
 	return v
@@ -355,3 +397,63 @@ func (n *Nodis) MSet(pairs ...string) {
 		n.Set(pairs[i], unsafe.Slice(unsafe.StringData(pairs[i+1]), len(pairs[i+1])), false)
 	}
 }
+
+// MSetNX sets the given keys to their respective values only if none of them exists,
+// the keys are set at once
+func (n *Nodis) MSetNX(pairs ...string) bool {
+	if len(pairs)%2 != 0 {
+		return false
+	}
+	values := make(map[string][]byte, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		values[pairs[i]] = []byte(pairs[i+1])
+	}
+	// lock the keys in the same order to avoid deadlocks with the other multi-key writes
+	keys := slices.Sorted(maps.Keys(values))
+	var ok bool
+	_ = n.exec(func(tx *Tx) error {
+		metas := make([]*metadata, len(keys))
+		for i, key := range keys {
+			metas[i] = tx.writeKey(key, nil)
+			if metas[i].isOk() {
+				return nil
+			}
+		}
+		ops := make([]patch.Op, 0, len(keys))
+		for i, key := range keys {
+			meta := tx.newStoredMetadata(metas[i], n.newStr)
+			meta.value.(*str.String).Set(values[key])
+			n.signalModifiedKey(key, meta)
+			ops = append(ops, patch.Op{Type: patch.OpTypeSet, Data: &patch.OpSet{Key: key, Value: values[key]}})
+		}
+		n.notify(func() []patch.Op {
+			return ops
+		})
+		ok = true
+		return nil
+	})
+	return ok
+}
+
+// LCS returns the longest common subsequence of the strings stored at key1 and key2, a missing key
+// is an empty string. The matches are returned from the last one to the first one and the ones
+// shorter than minMatchLen are skipped.
+func (n *Nodis) LCS(key1, key2 string, minMatchLen int64) ([]byte, []str.LCSMatch, error) {
+	var a, b []byte
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key1)
+		if meta.isOk() {
+			a = meta.value.(*str.String).Get()
+		}
+		if key2 == key1 {
+			b = a
+			return nil
+		}
+		meta = tx.readKey(key2)
+		if meta.isOk() {
+			b = meta.value.(*str.String).Get()
+		}
+		return nil
+	})
+	return str.LCS(a, b, minMatchLen)
+}
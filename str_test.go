@@ -1,8 +1,12 @@
 package nodis
 
 import (
+	"os"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/diiyw/nodis/patch"
 )
 
 func TestStr_Set(t *testing.T) {
@@ -57,3 +61,110 @@ func TestStr_BitCount(t *testing.T) {
 		t.Errorf("BitCount failed expected 8 got %d", n.BitCount("a", 0, 0, true))
 	}
 }
+
+func TestStr_SetWithOptions(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n2 := Open(&Options{})
+	var mu sync.Mutex
+	var ops []patch.Op
+	n.WatchKey([]string{"*"}, func(op patch.Op) {
+		mu.Lock()
+		ops = append(ops, op)
+		mu.Unlock()
+	})
+	at := time.Now().Add(time.Hour).UnixMilli()
+	if _, ok := n.SetWithOptions("a", []byte("1"), &SetOptions{XX: true}); ok {
+		t.Errorf("SetWithOptions() XX set a missing key")
+	}
+	if old, ok := n.SetWithOptions("a", []byte("1"), &SetOptions{NX: true, Get: true, ExpireAt: at}); !ok || old != nil {
+		t.Errorf("SetWithOptions() NX = %s, %v", old, ok)
+	}
+	if old, ok := n.SetWithOptions("a", []byte("2"), &SetOptions{NX: true, Get: true}); ok || string(old) != "1" {
+		t.Errorf("SetWithOptions() NX on existing key = %s, %v", old, ok)
+	}
+	if old, ok := n.SetWithOptions("a", []byte("3"), &SetOptions{XX: true, Get: true, KeepTTL: true}); !ok || string(old) != "1" {
+		t.Errorf("SetWithOptions() XX = %s, %v", old, ok)
+	}
+	if got := n.PTTL("a"); got <= 0 {
+		t.Errorf("KeepTTL lost the expiration, PTTL() = %d", got)
+	}
+	n.HSet("h", "f", []byte("v"))
+	n.Set("h", []byte("s"), false)
+	if got := string(n.Get("h")); got != "s" || n.Type("h") != "string" {
+		t.Errorf("Set() didn't overwrite the hash, Get() = %s", got)
+	}
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	// HSet, then one op per successful SET
+	if len(ops) != 4 {
+		t.Fatalf("got %d ops, want %d", len(ops), 4)
+	}
+	for _, op := range ops {
+		if op, ok := op.Data.(*patch.OpSet); ok && string(op.Value) == "1" && op.Expiration != at {
+			t.Errorf("OpSet.Expiration = %d, want %d", op.Expiration, at)
+		}
+	}
+	_ = n2.ApplyPatch(patch.Op{Type: patch.OpTypeSet, Data: &patch.OpSet{Key: "a", Value: []byte("1"), Expiration: at}})
+	if got := n2.PTTL("a"); string(n2.Get("a")) != "1" || got <= 0 {
+		t.Errorf("patched key = %s with PTTL %d", n2.Get("a"), got)
+	}
+}
+
+func TestStr_SetNXExpired(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.SetPX("a", []byte("1"), 1)
+	time.Sleep(5 * time.Millisecond)
+	if !n.SetNX("a", []byte("2"), true) {
+		t.Errorf("SetNX() on an expired key = false, want true")
+	}
+	if got := n.PTTL("a"); string(n.Get("a")) != "2" || got != -1 {
+		t.Errorf("Get() = %s with PTTL %d", n.Get("a"), got)
+	}
+}
+
+func TestStr_GetEX(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	if v := n.GetEX("a", &GetEXOptions{Persist: true}); v != nil {
+		t.Errorf("GetEX() on a missing key = %s", v)
+	}
+	n.Set("a", []byte("1"), false)
+	if v := n.GetEX("a", &GetEXOptions{ExpireAt: time.Now().Add(time.Hour).UnixMilli()}); string(v) != "1" || n.PTTL("a") <= 0 {
+		t.Errorf("GetEX() = %s with PTTL %d", v, n.PTTL("a"))
+	}
+	if v := n.GetEX("a", &GetEXOptions{Persist: true}); string(v) != "1" || n.PTTL("a") != -1 {
+		t.Errorf("GetEX() PERSIST = %s with PTTL %d", v, n.PTTL("a"))
+	}
+	if v := n.GetEX("a", &GetEXOptions{ExpireAt: 1}); string(v) != "1" || n.Exists("a") != 0 {
+		t.Errorf("GetEX() in the past = %s, key exists %d", v, n.Exists("a"))
+	}
+}
+
+func TestStr_GetDel(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n.Set("a", []byte("1"), false)
+	if v := n.GetDel("a"); string(v) != "1" || n.Exists("a") != 0 {
+		t.Errorf("GetDel() = %s, key exists %d", v, n.Exists("a"))
+	}
+	if v := n.GetDel("a"); v != nil {
+		t.Errorf("GetDel() on a missing key = %s", v)
+	}
+}
+
+func TestStr_MSetNX(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	if !n.MSetNX("a", "1", "b", "2") {
+		t.Errorf("MSetNX() = false, want true")
+	}
+	if n.MSetNX("c", "3", "b", "4") {
+		t.Errorf("MSetNX() with an existing key = true, want false")
+	}
+	if n.Exists("c") != 0 || string(n.Get("b")) != "2" {
+		t.Errorf("MSetNX() partially applied")
+	}
+}
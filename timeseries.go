@@ -0,0 +1,405 @@
+package nodis
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/timeseries"
+	"github.com/diiyw/nodis/patch"
+)
+
+var (
+	ErrTSKeyExists    = errors.New("ERR TSDB: key already exists")
+	ErrTSKeyNotExists = errors.New("ERR TSDB: the key does not exist")
+	ErrTSSameKey      = errors.New("ERR TSDB: the source key and destination key should be different")
+	ErrTSRuleNotFound = errors.New("ERR TSDB: compaction rule does not exist")
+	ErrTSNoFilter     = errors.New("ERR TSDB: please provide at least one matcher")
+)
+
+// TSOptions are the options of a time series when it's created
+type TSOptions struct {
+	Retention       int64
+	DuplicatePolicy timeseries.DuplicatePolicy
+	Labels          []timeseries.Label
+}
+
+// TSSample is a sample of the given key
+type TSSample struct {
+	Key       string
+	Timestamp int64
+	Value     float64
+}
+
+// TSRangeOptions are the options of TS.RANGE like commands
+type TSRangeOptions struct {
+	Count       int64
+	Aggregation *timeseries.Aggregation
+}
+
+// TSRangeResult is the result of a time series matched by TS.MRANGE
+type TSRangeResult struct {
+	Key     string
+	Labels  []timeseries.Label
+	Samples []timeseries.Sample
+}
+
+// TSInfo is the information of a time series
+type TSInfo struct {
+	TotalSamples    int64
+	FirstTimestamp  int64
+	LastTimestamp   int64
+	Retention       int64
+	ChunkCount      int64
+	DuplicatePolicy timeseries.DuplicatePolicy
+	Labels          []timeseries.Label
+	SourceKey       string
+	Rules           []*timeseries.Rule
+}
+
+func (n *Nodis) newTimeSeries(opts *TSOptions) func() ds.Value {
+	return func() ds.Value {
+		t := timeseries.NewTimeSeries()
+		if opts != nil {
+			t.SetRetention(opts.Retention)
+			t.SetDuplicatePolicy(opts.DuplicatePolicy)
+			t.SetLabels(opts.Labels)
+		}
+		return t
+	}
+}
+
+func (n *Nodis) tsCreateOp(key string, opts *TSOptions) patch.Op {
+	op := &patch.OpTSCreate{Key: key}
+	if opts != nil {
+		op.Retention = opts.Retention
+		op.DuplicatePolicy = int64(opts.DuplicatePolicy)
+		for _, l := range opts.Labels {
+			op.Labels = append(op.Labels, l.Name, l.Value)
+		}
+	}
+	return patch.Op{Type: patch.OpTypeTSCreate, Data: op}
+}
+
+// tsCompact adds the downsampled samples to the destination keys of the rules
+func (n *Nodis) tsCompact(tx *Tx, compactions []timeseries.Compaction) {
+	for _, c := range compactions {
+		meta := tx.writeKey(c.Key, nil)
+		if !meta.isOk() {
+			continue
+		}
+		t, ok := meta.value.(*timeseries.TimeSeries)
+		if !ok {
+			continue
+		}
+		_, _ = t.Add(c.Sample.Timestamp, c.Sample.Value, timeseries.DuplicateLast)
+		n.signalModifiedKey(c.Key, meta)
+	}
+}
+
+// TSCreate creates a new time series
+func (n *Nodis) TSCreate(key string, opts *TSOptions) error {
+	return n.exec(func(tx *Tx) error {
+		var created bool
+		newFn := n.newTimeSeries(opts)
+		meta := tx.writeKey(key, func() ds.Value {
+			created = true
+			return newFn()
+		})
+		if !created {
+			return ErrTSKeyExists
+		}
+		n.signalModifiedKey(key, meta)
+		n.notify(func() []patch.Op {
+			return []patch.Op{n.tsCreateOp(key, opts)}
+		})
+		return nil
+	})
+}
+
+// TSAdd appends a sample to the time series, the series is created with opts if it doesn't exist.
+// onDuplicate overrides the duplicate policy of the series when it isn't DuplicateNone.
+func (n *Nodis) TSAdd(key string, timestamp int64, value float64, opts *TSOptions, onDuplicate timeseries.DuplicatePolicy) (int64, error) {
+	err := n.exec(func(tx *Tx) error {
+		var created bool
+		newFn := n.newTimeSeries(opts)
+		meta := tx.writeKey(key, func() ds.Value {
+			created = true
+			return newFn()
+		})
+		compactions, err := meta.value.(*timeseries.TimeSeries).Add(timestamp, value, onDuplicate)
+		if err != nil {
+			return err
+		}
+		n.tsCompact(tx, compactions)
+		n.signalModifiedKey(key, meta)
+		n.notify(func() []patch.Op {
+			op := patch.Op{Type: patch.OpTypeTSAdd, Data: &patch.OpTSAdd{Key: key, Timestamp: timestamp, Value: value, OnDuplicate: int64(onDuplicate)}}
+			if created {
+				return []patch.Op{n.tsCreateOp(key, opts), op}
+			}
+			return []patch.Op{op}
+		})
+		return nil
+	})
+	return timestamp, err
+}
+
+// TSMAdd appends samples to existing time series, an error is returned for each sample
+func (n *Nodis) TSMAdd(samples ...TSSample) []error {
+	errs := make([]error, len(samples))
+	for i, s := range samples {
+		errs[i] = n.exec(func(tx *Tx) error {
+			meta := tx.writeKey(s.Key, nil)
+			if !meta.isOk() {
+				return ErrTSKeyNotExists
+			}
+			compactions, err := meta.value.(*timeseries.TimeSeries).Add(s.Timestamp, s.Value, timeseries.DuplicateNone)
+			if err != nil {
+				return err
+			}
+			n.tsCompact(tx, compactions)
+			n.signalModifiedKey(s.Key, meta)
+			n.notify(func() []patch.Op {
+				return []patch.Op{{Type: patch.OpTypeTSAdd, Data: &patch.OpTSAdd{Key: s.Key, Timestamp: s.Timestamp, Value: s.Value}}}
+			})
+			return nil
+		})
+	}
+	return errs
+}
+
+// TSIncrBy increases the value of the latest sample, the series is created with opts if it doesn't exist
+func (n *Nodis) TSIncrBy(key string, timestamp int64, delta float64, opts *TSOptions) (float64, error) {
+	var v float64
+	err := n.exec(func(tx *Tx) error {
+		var created bool
+		newFn := n.newTimeSeries(opts)
+		meta := tx.writeKey(key, func() ds.Value {
+			created = true
+			return newFn()
+		})
+		var compactions []timeseries.Compaction
+		var err error
+		v, compactions, err = meta.value.(*timeseries.TimeSeries).IncrBy(timestamp, delta)
+		if err != nil {
+			return err
+		}
+		n.tsCompact(tx, compactions)
+		n.signalModifiedKey(key, meta)
+		n.notify(func() []patch.Op {
+			op := patch.Op{Type: patch.OpTypeTSIncrBy, Data: &patch.OpTSIncrBy{Key: key, Timestamp: timestamp, Value: delta}}
+			if created {
+				return []patch.Op{n.tsCreateOp(key, opts), op}
+			}
+			return []patch.Op{op}
+		})
+		return nil
+	})
+	return v, err
+}
+
+// TSDecrBy decreases the value of the latest sample, the series is created with opts if it doesn't exist
+func (n *Nodis) TSDecrBy(key string, timestamp int64, delta float64, opts *TSOptions) (float64, error) {
+	return n.TSIncrBy(key, timestamp, -delta, opts)
+}
+
+// TSGet returns the latest sample, nil if the series is empty
+func (n *Nodis) TSGet(key string) (*timeseries.Sample, error) {
+	var v *timeseries.Sample
+	err := n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return ErrTSKeyNotExists
+		}
+		v = meta.value.(*timeseries.TimeSeries).Get()
+		return nil
+	})
+	return v, err
+}
+
+// TSRange returns the samples between from and to
+func (n *Nodis) TSRange(key string, from, to int64, opts *TSRangeOptions) ([]timeseries.Sample, error) {
+	return n.tsRange(key, from, to, opts, false)
+}
+
+// TSRevRange returns the samples between from and to in reverse order
+func (n *Nodis) TSRevRange(key string, from, to int64, opts *TSRangeOptions) ([]timeseries.Sample, error) {
+	return n.tsRange(key, from, to, opts, true)
+}
+
+func (n *Nodis) tsRange(key string, from, to int64, opts *TSRangeOptions, rev bool) ([]timeseries.Sample, error) {
+	if opts == nil {
+		opts = &TSRangeOptions{}
+	}
+	var v []timeseries.Sample
+	err := n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return ErrTSKeyNotExists
+		}
+		v = meta.value.(*timeseries.TimeSeries).Range(from, to, opts.Count, opts.Aggregation, rev)
+		return nil
+	})
+	return v, err
+}
+
+// TSMRange returns the samples between from and to of all the series matching the filters
+func (n *Nodis) TSMRange(from, to int64, filters []string, opts *TSRangeOptions) ([]*TSRangeResult, error) {
+	return n.tsMRange(from, to, filters, opts, false)
+}
+
+// TSMRevRange is like TSMRange but returns the samples in reverse order
+func (n *Nodis) TSMRevRange(from, to int64, filters []string, opts *TSRangeOptions) ([]*TSRangeResult, error) {
+	return n.tsMRange(from, to, filters, opts, true)
+}
+
+func (n *Nodis) tsMRange(from, to int64, filters []string, opts *TSRangeOptions, rev bool) ([]*TSRangeResult, error) {
+	if opts == nil {
+		opts = &TSRangeOptions{}
+	}
+	matchers := make([]timeseries.Filter, 0, len(filters))
+	var positive bool
+	for _, f := range filters {
+		m, err := timeseries.ParseFilter(f)
+		if err != nil {
+			return nil, err
+		}
+		positive = positive || m.Positive()
+		matchers = append(matchers, m)
+	}
+	if !positive {
+		return nil, ErrTSNoFilter
+	}
+	now := time.Now().UnixMilli()
+	n.store.mu.RLock()
+	keys := make([]string, 0)
+	for key, m := range n.store.metadata {
+		if !m.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	n.store.mu.RUnlock()
+	sort.Strings(keys)
+	results := make([]*TSRangeResult, 0)
+	for _, key := range keys {
+		_ = n.exec(func(tx *Tx) error {
+			meta := tx.readKey(key)
+			if !meta.isOk() {
+				return nil
+			}
+			t, ok := meta.value.(*timeseries.TimeSeries)
+			if !ok || !t.Match(matchers...) {
+				return nil
+			}
+			results = append(results, &TSRangeResult{
+				Key:     key,
+				Labels:  t.Labels(),
+				Samples: t.Range(from, to, opts.Count, opts.Aggregation, rev),
+			})
+			return nil
+		})
+	}
+	return results, nil
+}
+
+// TSCreateRule creates a compaction rule from key to dstKey, both series must exist
+func (n *Nodis) TSCreateRule(key, dstKey string, aggregation timeseries.Aggregation) error {
+	if key == dstKey {
+		return ErrTSSameKey
+	}
+	if aggregation.BucketDuration <= 0 {
+		return timeseries.ErrBucketDurationNeg
+	}
+	return n.exec(func(tx *Tx) error {
+		meta := tx.writeKey(key, nil)
+		if !meta.isOk() {
+			return ErrTSKeyNotExists
+		}
+		dstMeta := tx.writeKey(dstKey, nil)
+		if !dstMeta.isOk() {
+			return ErrTSKeyNotExists
+		}
+		src := meta.value.(*timeseries.TimeSeries)
+		dst := dstMeta.value.(*timeseries.TimeSeries)
+		if dst.Source() != "" {
+			return timeseries.ErrRuleExists
+		}
+		// chained compactions are not supported
+		if src.Source() != "" || len(dst.Rules()) > 0 {
+			return timeseries.ErrRuleChain
+		}
+		if err := src.AddRule(dstKey, aggregation); err != nil {
+			return err
+		}
+		dst.SetSource(key)
+		n.signalModifiedKey(key, meta)
+		n.signalModifiedKey(dstKey, dstMeta)
+		n.notify(func() []patch.Op {
+			return []patch.Op{{Type: patch.OpTypeTSCreateRule, Data: &patch.OpTSCreateRule{
+				Key:            key,
+				DstKey:         dstKey,
+				Aggregation:    int64(aggregation.Type),
+				BucketDuration: aggregation.BucketDuration,
+			}}}
+		})
+		return nil
+	})
+}
+
+// TSDeleteRule deletes the compaction rule from key to dstKey
+func (n *Nodis) TSDeleteRule(key, dstKey string) error {
+	return n.exec(func(tx *Tx) error {
+		meta := tx.writeKey(key, nil)
+		if !meta.isOk() {
+			return ErrTSKeyNotExists
+		}
+		if !meta.value.(*timeseries.TimeSeries).DeleteRule(dstKey) {
+			return ErrTSRuleNotFound
+		}
+		n.signalModifiedKey(key, meta)
+		if key != dstKey {
+			dstMeta := tx.writeKey(dstKey, nil)
+			if dstMeta.isOk() {
+				if dst, ok := dstMeta.value.(*timeseries.TimeSeries); ok && dst.Source() == key {
+					dst.SetSource("")
+					n.signalModifiedKey(dstKey, dstMeta)
+				}
+			}
+		}
+		n.notify(func() []patch.Op {
+			return []patch.Op{{Type: patch.OpTypeTSDeleteRule, Data: &patch.OpTSDeleteRule{Key: key, DstKey: dstKey}}}
+		})
+		return nil
+	})
+}
+
+// TSInfo returns the information of the time series
+func (n *Nodis) TSInfo(key string) (*TSInfo, error) {
+	var v *TSInfo
+	err := n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return ErrTSKeyNotExists
+		}
+		t := meta.value.(*timeseries.TimeSeries)
+		v = &TSInfo{
+			TotalSamples:    t.Len(),
+			Retention:       t.Retention(),
+			ChunkCount:      t.ChunkCount(),
+			DuplicatePolicy: t.DuplicatePolicy(),
+			Labels:          t.Labels(),
+			SourceKey:       t.Source(),
+			Rules:           t.Rules(),
+		}
+		if first := t.First(); first != nil {
+			v.FirstTimestamp = first.Timestamp
+		}
+		if last := t.Get(); last != nil {
+			v.LastTimestamp = last.Timestamp
+		}
+		return nil
+	})
+	return v, err
+}
@@ -0,0 +1,183 @@
+package nodis
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/diiyw/nodis/ds/timeseries"
+	"github.com/diiyw/nodis/patch"
+)
+
+func TestTimeSeries_TSCreate(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	opts := &TSOptions{Retention: 100, Labels: []timeseries.Label{{Name: "area", Value: "us"}}}
+	if err := n.TSCreate("ts", opts); err != nil {
+		t.Fatalf("TSCreate() error = %v", err)
+	}
+	if err := n.TSCreate("ts", opts); err != ErrTSKeyExists {
+		t.Errorf("TSCreate() error = %v, want %v", err, ErrTSKeyExists)
+	}
+	info, err := n.TSInfo("ts")
+	if err != nil {
+		t.Fatalf("TSInfo() error = %v", err)
+	}
+	if info.Retention != 100 || !reflect.DeepEqual(info.Labels, opts.Labels) {
+		t.Errorf("TSInfo() = %v, want retention %v labels %v", info, 100, opts.Labels)
+	}
+}
+
+func TestTimeSeries_TSAddRange(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	for i := int64(1); i <= 5; i++ {
+		if _, err := n.TSAdd("ts", i*1000, float64(i), nil, timeseries.DuplicateNone); err != nil {
+			t.Fatalf("TSAdd() error = %v", err)
+		}
+	}
+	if _, err := n.TSAdd("ts", 1000, 10, nil, timeseries.DuplicateNone); err != timeseries.ErrDuplicateBlocked {
+		t.Errorf("TSAdd() error = %v, want %v", err, timeseries.ErrDuplicateBlocked)
+	}
+	if _, err := n.TSAdd("ts", 1000, 10, nil, timeseries.DuplicateLast); err != nil {
+		t.Errorf("TSAdd() error = %v", err)
+	}
+	got, _ := n.TSRange("ts", 0, 3000, nil)
+	want := []timeseries.Sample{{Timestamp: 1000, Value: 10}, {Timestamp: 2000, Value: 2}, {Timestamp: 3000, Value: 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TSRange() = %v, want %v", got, want)
+	}
+	got, _ = n.TSRevRange("ts", 0, 5000, &TSRangeOptions{Count: 2})
+	want = []timeseries.Sample{{Timestamp: 5000, Value: 5}, {Timestamp: 4000, Value: 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TSRevRange() = %v, want %v", got, want)
+	}
+	got, _ = n.TSRange("ts", 0, 5000, &TSRangeOptions{Aggregation: &timeseries.Aggregation{Type: timeseries.AggSum, BucketDuration: 2000}})
+	want = []timeseries.Sample{{Timestamp: 0, Value: 10}, {Timestamp: 2000, Value: 5}, {Timestamp: 4000, Value: 9}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TSRange() = %v, want %v", got, want)
+	}
+	s, _ := n.TSGet("ts")
+	if s == nil || *s != (timeseries.Sample{Timestamp: 5000, Value: 5}) {
+		t.Errorf("TSGet() = %v, want %v", s, timeseries.Sample{Timestamp: 5000, Value: 5})
+	}
+	if _, err := n.TSGet("none"); err != ErrTSKeyNotExists {
+		t.Errorf("TSGet() error = %v, want %v", err, ErrTSKeyNotExists)
+	}
+}
+
+func TestTimeSeries_TSMAdd(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	_ = n.TSCreate("a", nil)
+	errs := n.TSMAdd(TSSample{Key: "a", Timestamp: 1, Value: 1}, TSSample{Key: "b", Timestamp: 1, Value: 1}, TSSample{Key: "a", Timestamp: 2, Value: 2})
+	if errs[0] != nil || errs[1] != ErrTSKeyNotExists || errs[2] != nil {
+		t.Errorf("TSMAdd() = %v", errs)
+	}
+}
+
+func TestTimeSeries_TSIncrBy(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	v, _ := n.TSIncrBy("ts", 10, 3, nil)
+	if v != 3 {
+		t.Errorf("TSIncrBy() = %v, want %v", v, 3)
+	}
+	v, _ = n.TSDecrBy("ts", 20, 1, nil)
+	if v != 2 {
+		t.Errorf("TSDecrBy() = %v, want %v", v, 2)
+	}
+	if _, err := n.TSIncrBy("ts", 5, 1, nil); err != timeseries.ErrTimestampNotLast {
+		t.Errorf("TSIncrBy() error = %v, want %v", err, timeseries.ErrTimestampNotLast)
+	}
+}
+
+func TestTimeSeries_TSCreateRule(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	_ = n.TSCreate("src", nil)
+	_ = n.TSCreate("dst", nil)
+	_ = n.TSCreate("other", nil)
+	agg := timeseries.Aggregation{Type: timeseries.AggAvg, BucketDuration: 10}
+	if err := n.TSCreateRule("src", "none", agg); err != ErrTSKeyNotExists {
+		t.Errorf("TSCreateRule() error = %v, want %v", err, ErrTSKeyNotExists)
+	}
+	if err := n.TSCreateRule("src", "dst", agg); err != nil {
+		t.Fatalf("TSCreateRule() error = %v", err)
+	}
+	if err := n.TSCreateRule("other", "dst", agg); err != timeseries.ErrRuleExists {
+		t.Errorf("TSCreateRule() error = %v, want %v", err, timeseries.ErrRuleExists)
+	}
+	if err := n.TSCreateRule("dst", "other", agg); err != timeseries.ErrRuleChain {
+		t.Errorf("TSCreateRule() error = %v, want %v", err, timeseries.ErrRuleChain)
+	}
+	for i := int64(0); i < 25; i++ {
+		_, _ = n.TSAdd("src", i, float64(i), nil, timeseries.DuplicateNone)
+	}
+	got, _ := n.TSRange("dst", 0, 100, nil)
+	want := []timeseries.Sample{{Timestamp: 0, Value: 4.5}, {Timestamp: 10, Value: 14.5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TSRange() = %v, want %v", got, want)
+	}
+	if err := n.TSDeleteRule("src", "dst"); err != nil {
+		t.Errorf("TSDeleteRule() error = %v", err)
+	}
+	if info, _ := n.TSInfo("dst"); info.SourceKey != "" {
+		t.Errorf("TSInfo() sourceKey = %v, want empty", info.SourceKey)
+	}
+	if err := n.TSDeleteRule("src", "dst"); err != ErrTSRuleNotFound {
+		t.Errorf("TSDeleteRule() error = %v, want %v", err, ErrTSRuleNotFound)
+	}
+}
+
+func TestTimeSeries_TSMRange(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	_ = n.TSCreate("t1", &TSOptions{Labels: []timeseries.Label{{Name: "area", Value: "us"}}})
+	_ = n.TSCreate("t2", &TSOptions{Labels: []timeseries.Label{{Name: "area", Value: "eu"}}})
+	n.Set("str", []byte("v"), false)
+	_, _ = n.TSAdd("t1", 1, 1, nil, timeseries.DuplicateNone)
+	_, _ = n.TSAdd("t2", 1, 2, nil, timeseries.DuplicateNone)
+	results, err := n.TSMRange(0, 10, []string{"area=(us,eu)"}, nil)
+	if err != nil {
+		t.Fatalf("TSMRange() error = %v", err)
+	}
+	if len(results) != 2 || results[0].Key != "t1" || results[1].Key != "t2" {
+		t.Errorf("TSMRange() = %v, want t1 and t2", results)
+	}
+	results, _ = n.TSMRange(0, 10, []string{"area=eu"}, nil)
+	if len(results) != 1 || results[0].Samples[0].Value != 2 {
+		t.Errorf("TSMRange() = %v, want t2", results)
+	}
+	if _, err := n.TSMRange(0, 10, []string{"area!=eu"}, nil); err != ErrTSNoFilter {
+		t.Errorf("TSMRange() error = %v, want %v", err, ErrTSNoFilter)
+	}
+}
+
+func TestTimeSeries_ApplyPatch(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	err := n.ApplyPatch(
+		patch.Op{Type: patch.OpTypeTSCreate, Data: &patch.OpTSCreate{Key: "ts", Retention: 50, Labels: []string{"area", "us"}}},
+		patch.Op{Type: patch.OpTypeTSAdd, Data: &patch.OpTSAdd{Key: "ts", Timestamp: 10, Value: 1}},
+		patch.Op{Type: patch.OpTypeTSIncrBy, Data: &patch.OpTSIncrBy{Key: "ts", Timestamp: 20, Value: 2}},
+	)
+	if err != nil {
+		t.Fatalf("ApplyPatch() error = %v", err)
+	}
+	got, _ := n.TSRange("ts", 0, 100, nil)
+	want := []timeseries.Sample{{Timestamp: 10, Value: 1}, {Timestamp: 20, Value: 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TSRange() = %v, want %v", got, want)
+	}
+	if info, _ := n.TSInfo("ts"); info.Retention != 50 || len(info.Labels) != 1 {
+		t.Errorf("TSInfo() = %v", info)
+	}
+}
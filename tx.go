@@ -1,14 +1,19 @@
 package nodis
 
 import (
+	"errors"
 	"time"
 
 	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/hash"
+	"github.com/diiyw/nodis/storage"
 )
 
 type Tx struct {
 	store       *store
 	lockedMetas []*metadata
+	// noTouch reads the keys without recording an access, like OBJECT IDLETIME does
+	noTouch bool
 }
 
 func newTx(store *store) *Tx {
@@ -42,15 +47,23 @@ func (tx *Tx) rLockKey(key string) *metadata {
 
 func (tx *Tx) lockMeta(m *metadata) {
 	m.Lock()
+	if sn := tx.store.snapshot.Load(); sn != nil {
+		// the key is copied before it's written
+		sn.copy(tx.store, m)
+	}
 	m.writeable = true
 	tx.lockedMetas = append(tx.lockedMetas, m)
-	m.count.Add(1)
+	if !tx.noTouch {
+		m.touch()
+	}
 }
 
 func (tx *Tx) rLockMeta(m *metadata) {
 	m.RLock()
 	tx.lockedMetas = append(tx.lockedMetas, m)
-	m.count.Add(1)
+	if !tx.noTouch {
+		m.touch()
+	}
 }
 
 func (tx *Tx) storeMeta(m *metadata) {
@@ -60,7 +73,7 @@ func (tx *Tx) storeMeta(m *metadata) {
 }
 
 func (tx *Tx) resetMeta(m *metadata, newFn func() ds.Value) {
-	m.count.Store(0)
+	m.resetAccess()
 	m.value = nil
 	if newFn != nil {
 		m.setValue(newFn())
@@ -81,7 +94,11 @@ func (tx *Tx) newStoredMetadata(m *metadata, newFn func() ds.Value) *metadata {
 
 func (tx *Tx) delKey(key string) {
 	tx.store.mu.Lock()
-	delete(tx.store.metadata, key)
+	if m, ok := tx.store.metadata[key]; ok {
+		tx.store.forget(m)
+		tx.store.removeLater(m)
+		delete(tx.store.metadata, key)
+	}
 	tx.store.mu.Unlock()
 }
 
@@ -89,20 +106,40 @@ func (tx *Tx) writeKey(key string, newFn func() ds.Value) *metadata {
 	m := tx.lockKey(key)
 	if m.isOk() {
 		if m.expired(time.Now().UnixMilli()) {
+			if newFn == nil {
+				// the key doesn't exist anymore
+				tx.delKey(key)
+				return newMetadata(ds.NewKey(key, 0), true)
+			}
 			tx.resetMeta(m, newFn)
 			return m
 		}
 		// not expired
-		if m.value != nil {
-			return m
+		if m.value == nil {
+			// if not found in memory, read from storage
+			v, err := tx.store.load(m)
+			if errors.Is(err, storage.ErrCorruptedData) {
+				tx.store.quarantine(m, err)
+				m = newMetadata(ds.NewKey(key, 0), true)
+				if newFn == nil {
+					return m
+				}
+				return tx.newStoredMetadata(m, newFn)
+			}
+			if err != nil {
+				tx.resetMeta(m, newFn)
+				return m
+			}
+			m.setValue(v)
 		}
-		// if not found in memory, read from storage
-		v, err := tx.store.ss.Get(m.key)
-		if err != nil {
+		if tx.removeExpiredFields(m) {
+			// the last field of the hash expired, the key doesn't exist anymore
+			if newFn == nil {
+				tx.delKey(key)
+				return newMetadata(ds.NewKey(key, 0), true)
+			}
 			tx.resetMeta(m, newFn)
-			return m
 		}
-		m.setValue(v)
 		return m
 	}
 	if newFn == nil {
@@ -112,7 +149,42 @@ func (tx *Tx) writeKey(key string, newFn func() ds.Value) *metadata {
 }
 
 func (tx *Tx) readKey(key string) *metadata {
-	m := tx.rLockKey(key)
+	return tx.writeExpiredFields(tx.loadMeta(tx.rLockKey(key)))
+}
+
+// writeExpiredFields locks the read key for writing if it's a hash with expired fields, they are
+// removed by writeKey
+func (tx *Tx) writeExpiredFields(m *metadata) *metadata {
+	h, ok := m.value.(*hash.HashMap)
+	if !ok || !h.HasExpired(time.Now().UnixMilli()) {
+		return m
+	}
+	// the read lock is the last one taken
+	tx.lockedMetas = tx.lockedMetas[:len(tx.lockedMetas)-1]
+	m.RUnlock()
+	return tx.writeKey(m.key.Name, nil)
+}
+
+// removeExpiredFields removes the expired fields of the hash of the write locked key, the store
+// deletes the key like an evicted one if the last field is removed. It returns if the key is
+// deleted.
+func (tx *Tx) removeExpiredFields(m *metadata) bool {
+	h, ok := m.value.(*hash.HashMap)
+	if !ok || h.RemoveExpired(time.Now().UnixMilli()) == 0 {
+		return false
+	}
+	m.state |= KeyStateModified
+	if h.HLen() > 0 {
+		return false
+	}
+	if tx.store.deleted != nil {
+		tx.store.deleted(m)
+	}
+	return true
+}
+
+// loadMeta loads the value of the read locked metadata if it isn't in memory
+func (tx *Tx) loadMeta(m *metadata) *metadata {
 	if m.isOk() {
 		if m.expired(time.Now().UnixMilli()) {
 			return newMetadata(m.key, false)
@@ -122,8 +194,11 @@ func (tx *Tx) readKey(key string) *metadata {
 			return m
 		}
 		// if not found in memory, read from storage
-		v, err := tx.store.ss.Get(m.key)
+		v, err := tx.store.load(m)
 		if err != nil {
+			if errors.Is(err, storage.ErrCorruptedData) {
+				tx.store.quarantine(m, err)
+			}
 			return newMetadata(m.key, false)
 		}
 		m.setValue(v)
@@ -133,8 +208,11 @@ func (tx *Tx) readKey(key string) *metadata {
 }
 
 func (tx *Tx) commit() {
+	sizes := tx.estimateSizes()
 	for _, meta := range tx.lockedMetas {
 		meta.commit()
 	}
+	tx.store.account(tx.lockedMetas, sizes)
 	tx.lockedMetas = tx.lockedMetas[:0]
+	tx.store.evict()
 }
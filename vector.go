@@ -0,0 +1,202 @@
+package nodis
+
+import (
+	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/vector"
+	"github.com/diiyw/nodis/patch"
+)
+
+// VAddOptions are the options of VAdd. The embedded vector options
+// are only used when the vector set is created.
+type VAddOptions struct {
+	vector.Options
+	// Attr sets the attributes of the element when it isn't empty
+	Attr string
+}
+
+func (n *Nodis) newVectorSet(opts vector.Options) func() ds.Value {
+	return func() ds.Value {
+		return vector.NewVectorSet(opts)
+	}
+}
+
+// VAdd adds the element with the vector to the vector set, it returns true if the element is new
+func (n *Nodis) VAdd(key string, element string, v []float32, opts *VAddOptions) (bool, error) {
+	if opts == nil {
+		opts = &VAddOptions{}
+	}
+	var added bool
+	err := n.exec(func(tx *Tx) error {
+		var created bool
+		newFn := n.newVectorSet(opts.Options)
+		meta := tx.writeKey(key, func() ds.Value {
+			created = true
+			return newFn()
+		})
+		vs := meta.value.(*vector.VectorSet)
+		var err error
+		added, err = vs.VAdd(element, v)
+		if err != nil {
+			if created {
+				tx.delKey(key)
+			}
+			return err
+		}
+		if opts.Attr != "" {
+			vs.VSetAttr(element, opts.Attr)
+		}
+		n.signalModifiedKey(key, meta)
+		n.notify(func() []patch.Op {
+			o := vs.Options()
+			ops := []patch.Op{{Type: patch.OpTypeVAdd, Data: &patch.OpVAdd{
+				Key:          key,
+				Element:      element,
+				Vector:       v,
+				Quantization: int64(o.Quantization),
+				Metric:       int64(o.Metric),
+				M:            int64(o.M),
+				EF:           int64(o.EF),
+			}}}
+			if opts.Attr != "" {
+				ops = append(ops, patch.Op{Type: patch.OpTypeVSetAttr, Data: &patch.OpVSetAttr{Key: key, Element: element, Attr: opts.Attr}})
+			}
+			return ops
+		})
+		return nil
+	})
+	return added, err
+}
+
+// VRem removes the element from the vector set
+func (n *Nodis) VRem(key string, element string) bool {
+	var v bool
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.writeKey(key, nil)
+		if !meta.isOk() {
+			return nil
+		}
+		vs := meta.value.(*vector.VectorSet)
+		v = vs.VRem(element)
+		if !v {
+			return nil
+		}
+		if vs.VCard() == 0 {
+			tx.delKey(key)
+		}
+		n.signalModifiedKey(key, meta)
+		n.notify(func() []patch.Op {
+			return []patch.Op{{Type: patch.OpTypeVRem, Data: &patch.OpVRem{Key: key, Element: element}}}
+		})
+		return nil
+	})
+	return v
+}
+
+// VSim returns the count elements most similar to the vector with their scores.
+// ef is the search exploration factor, larger values are slower but more accurate.
+func (n *Nodis) VSim(key string, v []float32, count int, ef int) ([]vector.Result, error) {
+	var results []vector.Result
+	err := n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		var err error
+		results, err = meta.value.(*vector.VectorSet).VSim(v, count, ef)
+		return err
+	})
+	return results, err
+}
+
+// VSimElement returns the count elements most similar to the element with their scores
+func (n *Nodis) VSimElement(key string, element string, count int, ef int) ([]vector.Result, error) {
+	var results []vector.Result
+	err := n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		var err error
+		results, err = meta.value.(*vector.VectorSet).VSimElement(element, count, ef)
+		return err
+	})
+	return results, err
+}
+
+// VCard returns the number of elements of the vector set
+func (n *Nodis) VCard(key string) int64 {
+	var v int64
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		v = meta.value.(*vector.VectorSet).VCard()
+		return nil
+	})
+	return v
+}
+
+// VDim returns the dimension of the vectors of the vector set
+func (n *Nodis) VDim(key string) int64 {
+	var v int64
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		v = meta.value.(*vector.VectorSet).VDim()
+		return nil
+	})
+	return v
+}
+
+// VEmb returns the approximated vector of the element
+func (n *Nodis) VEmb(key string, element string) []float32 {
+	var v []float32
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		v = meta.value.(*vector.VectorSet).VEmb(element)
+		return nil
+	})
+	return v
+}
+
+// VGetAttr returns the attributes of the element
+func (n *Nodis) VGetAttr(key string, element string) (string, bool) {
+	var v string
+	var ok bool
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		v, ok = meta.value.(*vector.VectorSet).VGetAttr(element)
+		return nil
+	})
+	return v, ok
+}
+
+// VSetAttr sets the attributes of the element, an empty attr removes them
+func (n *Nodis) VSetAttr(key string, element string, attr string) bool {
+	var v bool
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.writeKey(key, nil)
+		if !meta.isOk() {
+			return nil
+		}
+		v = meta.value.(*vector.VectorSet).VSetAttr(element, attr)
+		if !v {
+			return nil
+		}
+		n.signalModifiedKey(key, meta)
+		n.notify(func() []patch.Op {
+			return []patch.Op{{Type: patch.OpTypeVSetAttr, Data: &patch.OpVSetAttr{Key: key, Element: element, Attr: attr}}}
+		})
+		return nil
+	})
+	return v
+}
@@ -0,0 +1,101 @@
+package nodis
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/diiyw/nodis/ds/vector"
+	"github.com/diiyw/nodis/patch"
+)
+
+func TestVector_VAdd(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	added, err := n.VAdd("vs", "a", []float32{1, 0, 0}, &VAddOptions{Options: vector.Options{Quantization: vector.NoQuant}, Attr: `{"n":1}`})
+	if err != nil || !added {
+		t.Fatalf("VAdd() = %v, %v, want true, nil", added, err)
+	}
+	if _, err := n.VAdd("vs", "b", []float32{1, 0}, nil); err != vector.ErrDimensionMismatch {
+		t.Errorf("VAdd() error = %v, want %v", err, vector.ErrDimensionMismatch)
+	}
+	if _, err := n.VAdd("empty", "b", nil, nil); err != vector.ErrEmptyVector {
+		t.Errorf("VAdd() error = %v, want %v", err, vector.ErrEmptyVector)
+	}
+	if n.Exists("empty") != 0 {
+		t.Errorf("VAdd() created a key for an invalid vector")
+	}
+	if n.VCard("vs") != 1 || n.VDim("vs") != 3 {
+		t.Errorf("VCard() = %v, VDim() = %v, want 1, 3", n.VCard("vs"), n.VDim("vs"))
+	}
+	if got := n.VEmb("vs", "a"); !reflect.DeepEqual(got, []float32{1, 0, 0}) {
+		t.Errorf("VEmb() = %v, want %v", got, []float32{1, 0, 0})
+	}
+	if attr, _ := n.VGetAttr("vs", "a"); attr != `{"n":1}` {
+		t.Errorf("VGetAttr() = %v, want %v", attr, `{"n":1}`)
+	}
+}
+
+func TestVector_VSim(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	_, _ = n.VAdd("vs", "x", []float32{1, 0}, nil)
+	_, _ = n.VAdd("vs", "y", []float32{0, 1}, nil)
+	_, _ = n.VAdd("vs", "xy", []float32{1, 1}, nil)
+	results, err := n.VSim("vs", []float32{1, 0.2}, 2, 0)
+	if err != nil {
+		t.Fatalf("VSim() error = %v", err)
+	}
+	if len(results) != 2 || results[0].Element != "x" || results[1].Element != "xy" {
+		t.Errorf("VSim() = %v, want x, xy", results)
+	}
+	results, _ = n.VSimElement("vs", "y", 1, 0)
+	if len(results) != 1 || results[0].Element != "y" {
+		t.Errorf("VSimElement() = %v, want y", results)
+	}
+	if results, err := n.VSim("none", []float32{1, 0}, 2, 0); results != nil || err != nil {
+		t.Errorf("VSim() = %v, %v, want nil, nil", results, err)
+	}
+}
+
+func TestVector_VRem(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	_, _ = n.VAdd("vs", "a", []float32{1, 0}, nil)
+	if n.VRem("vs", "b") {
+		t.Errorf("VRem() = true, want false")
+	}
+	if !n.VRem("vs", "a") {
+		t.Errorf("VRem() = false, want true")
+	}
+	if n.Exists("vs") != 0 {
+		t.Errorf("VRem() should delete the empty vector set")
+	}
+}
+
+func TestVector_ApplyPatch(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	defer n.Close()
+	err := n.ApplyPatch(
+		patch.Op{Type: patch.OpTypeVAdd, Data: &patch.OpVAdd{Key: "vs", Element: "a", Vector: []float32{3, 4}, Quantization: int64(vector.NoQuant)}},
+		patch.Op{Type: patch.OpTypeVAdd, Data: &patch.OpVAdd{Key: "vs", Element: "b", Vector: []float32{4, 3}}},
+		patch.Op{Type: patch.OpTypeVSetAttr, Data: &patch.OpVSetAttr{Key: "vs", Element: "b", Attr: "attr"}},
+		patch.Op{Type: patch.OpTypeVRem, Data: &patch.OpVRem{Key: "vs", Element: "a"}},
+	)
+	if err != nil {
+		t.Fatalf("ApplyPatch() error = %v", err)
+	}
+	if n.VCard("vs") != 1 {
+		t.Errorf("VCard() = %v, want %v", n.VCard("vs"), 1)
+	}
+	if got := n.VEmb("vs", "b"); !reflect.DeepEqual(got, []float32{4, 3}) {
+		t.Errorf("VEmb() = %v, want %v", got, []float32{4, 3})
+	}
+	if attr, _ := n.VGetAttr("vs", "b"); attr != "attr" {
+		t.Errorf("VGetAttr() = %v, want %v", attr, "attr")
+	}
+}
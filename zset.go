@@ -1,13 +1,27 @@
 package nodis
 
 import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/diiyw/nodis/ds"
+	"github.com/diiyw/nodis/ds/set"
 	"github.com/diiyw/nodis/ds/zset"
 	"github.com/diiyw/nodis/patch"
 )
 
+var (
+	ErrZInvalidRank       = errors.New("ERR value is not an integer or out of range")
+	ErrZInvalidScoreRange = errors.New("ERR min or max is not a float")
+)
+
 func (n *Nodis) newZSet() ds.Value {
-	return zset.NewSortedSet()
+	return zset.NewSortedSetWithEncodings(n.store.encodings)
 }
 
 func (n *Nodis) ZAdd(key string, member string, score float64) int64 {
@@ -154,7 +168,7 @@ func (n *Nodis) ZRevRankWithScore(key string, member string) (int64, *zset.Item)
 
 func (n *Nodis) ZScore(key string, member string) (v float64, err error) {
 	_ = n.exec(func(tx *Tx) error {
-		meta := tx.readKey(key)
+		meta := tx.readFields(key, member)
 		if !meta.isOk() {
 			return nil
 		}
@@ -365,6 +379,85 @@ func (n *Nodis) ZRemRangeByScore(key string, min float64, max float64, mode int)
 	return v
 }
 
+func (n *Nodis) zRangeByLex(key string, min, max string, offset, count int64, desc bool) ([]string, error) {
+	r, err := zset.ParseLexRange(min, max)
+	if err != nil {
+		return nil, err
+	}
+	var v []string
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		var els []*zset.Item
+		if desc {
+			els = meta.value.(*zset.SortedSet).ZRevRangeByLex(r, offset, count)
+		} else {
+			els = meta.value.(*zset.SortedSet).ZRangeByLex(r, offset, count)
+		}
+		v = make([]string, len(els))
+		for i, el := range els {
+			v[i] = el.Member
+		}
+		return nil
+	})
+	return v, nil
+}
+
+// ZRangeByLex returns the members between min and max in lexicographical order,
+// the borders are "-", "+", "[member" or "(member" for an exclusive border
+func (n *Nodis) ZRangeByLex(key string, min, max string, offset, count int64) ([]string, error) {
+	return n.zRangeByLex(key, min, max, offset, count, false)
+}
+
+// ZRevRangeByLex returns the members between min and max in reverse lexicographical order
+func (n *Nodis) ZRevRangeByLex(key string, min, max string, offset, count int64) ([]string, error) {
+	return n.zRangeByLex(key, min, max, offset, count, true)
+}
+
+// ZLexCount returns the number of members between min and max
+func (n *Nodis) ZLexCount(key string, min, max string) (int64, error) {
+	r, err := zset.ParseLexRange(min, max)
+	if err != nil {
+		return 0, err
+	}
+	var v int64
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		v = meta.value.(*zset.SortedSet).ZLexCount(r)
+		return nil
+	})
+	return v, nil
+}
+
+// ZRemRangeByLex removes the members between min and max
+func (n *Nodis) ZRemRangeByLex(key string, min, max string) (int64, error) {
+	r, err := zset.ParseLexRange(min, max)
+	if err != nil {
+		return 0, err
+	}
+	var v int64
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.writeKey(key, nil)
+		if !meta.isOk() {
+			return nil
+		}
+		v = meta.value.(*zset.SortedSet).ZRemRangeByLex(r)
+		if v > 0 {
+			n.signalModifiedKey(key, meta)
+			n.notify(func() []patch.Op {
+				return []patch.Op{{Type: patch.OpTypeZRemRangeByLex, Data: &patch.OpZRemRangeByLex{Key: key, Min: min, Max: max}}}
+			})
+		}
+		return nil
+	})
+	return v, nil
+}
+
 func (n *Nodis) ZExists(key string, member string) bool {
 	var v bool
 	_ = n.exec(func(tx *Tx) error {
@@ -424,148 +517,432 @@ func (n *Nodis) ZMin(key string) *zset.Item {
 	return v
 }
 
+// ZUnion computes the union of the sorted sets given by the specified keys, plain sets are
+// accepted too and their members have a score of 1. The scores are multiplied by the weights
+// and aggregated with SUM (default), MIN or MAX.
 func (n *Nodis) ZUnion(keys []string, weights []float64, aggregate string) []*zset.Item {
+	return n.zCombine(keys, weights, aggregate, false)
+}
+
+// ZUnionStore computes the union of numkeys sorted sets given by the specified keys, and stores the result in destination.
+func (n *Nodis) ZUnionStore(destination string, keys []string, weights []float64, aggregate string) int64 {
+	items := n.ZUnion(keys, weights, aggregate)
+	return n.zStore(destination, items, patch.Op{Type: patch.OpTypeZUnionStore, Data: &patch.OpZUnionStore{Key: destination, Keys: keys, Weights: weights, Aggregate: aggregate}})
+}
+
+// ZInter computes the intersection of the sorted sets given by the specified keys, see ZUnion.
+func (n *Nodis) ZInter(keys []string, weights []float64, aggregate string) []*zset.Item {
+	return n.zCombine(keys, weights, aggregate, true)
+}
+
+// ZInterStore computes the intersection of numkeys sorted sets given by the specified keys, and stores the result in destination.
+func (n *Nodis) ZInterStore(destination string, keys []string, weights []float64, aggregate string) int64 {
+	items := n.ZInter(keys, weights, aggregate)
+	return n.zStore(destination, items, patch.Op{Type: patch.OpTypeZInterStore, Data: &patch.OpZInterStore{Key: destination, Keys: keys, Weights: weights, Aggregate: aggregate}})
+}
+
+// ZInterCard returns the cardinality of the intersection, the counting stops at limit if limit is positive.
+func (n *Nodis) ZInterCard(keys []string, limit int64) int64 {
+	v := int64(len(n.ZInter(keys, nil, "")))
+	if limit > 0 {
+		v = min(v, limit)
+	}
+	return v
+}
+
+// ZDiff returns the members of the first sorted set that are not in the other ones, plain sets are accepted too.
+func (n *Nodis) ZDiff(keys []string) []*zset.Item {
 	var v []*zset.Item
-	_ = n.exec(func(tx *Tx) error {
-		var items = make(map[string]float64)
-		for i, key := range keys {
-			m := tx.readKey(key)
-			if !m.isOk() {
-				continue
-			}
-			var weight float64 = 1
-			if i < len(weights) {
-				weight = weights[i]
-			}
-			zs := m.value.(*zset.SortedSet).ZRange(0, -1)
-			for _, z := range zs {
-				if aggregate == "SUM" || aggregate == "" {
-					if _, ok := items[z.Member]; !ok {
-						items[z.Member] = z.Score * weight
-					} else {
-						items[z.Member] = items[z.Member]*weight + weight*z.Score
-					}
-				}
-				if aggregate == "MIN" {
-					if _, ok := items[z.Member]; !ok || z.Score < items[z.Member] {
-						items[z.Member] = z.Score * weight
-					}
-				}
-				if aggregate == "MAX" {
-					if _, ok := items[z.Member]; !ok || z.Score > items[z.Member] {
-						items[z.Member] = z.Score * weight
-					}
+	if len(keys) == 0 {
+		return v
+	}
+	_ = n.exec(func(tx *Tx) error {
+		metas := readZKeys(tx, keys)
+		v = zItems(metas[keys[0]])
+		for key, meta := range metas {
+			if key == keys[0] {
+				// the first key removes all of its members when repeated
+				if slices.Contains(keys[1:], key) {
+					v = nil
 				}
+				continue
 			}
-		}
-		for member, score := range items {
-			v = append(v, &zset.Item{Member: member, Score: score})
+			v = slices.DeleteFunc(v, func(item *zset.Item) bool {
+				return zHasMember(meta, item.Member)
+			})
 		}
 		return nil
 	})
+	sortZItems(v)
 	return v
 }
 
-// ZUnionStore computes the union of numkeys sorted sets given by the specified keys, and stores the result in destination.
-func (n *Nodis) ZUnionStore(destination string, keys []string, weights []float64, aggregate string) int64 {
-	var v int64
+// ZDiffStore stores the result of ZDiff in destination and returns the number of stored members.
+func (n *Nodis) ZDiffStore(destination string, keys []string) int64 {
+	items := n.ZDiff(keys)
+	return n.zStore(destination, items, patch.Op{Type: patch.OpTypeZDiffStore, Data: &patch.OpZDiffStore{Key: destination, Keys: keys}})
+}
+
+// ZRangeStoreOptions are the options of ZRangeStore
+type ZRangeStoreOptions struct {
+	// ByScore and ByLex select a range of scores or a lexicographical range instead of a range of ranks
+	ByScore bool
+	ByLex   bool
+	// Rev reverses the order, start and stop are then given from the highest to the lowest like in ZRANGE ... REV
+	Rev bool
+	// Limit pages the score and lexicographical ranges, a negative Count returns all the members from Offset
+	Limit  bool
+	Offset int64
+	Count  int64
+}
+
+// ZRangeStore stores the range of the sorted set at key in destination and returns the number of stored members,
+// start and stop are ranks, scores or lexicographical borders as in ZRANGE.
+func (n *Nodis) ZRangeStore(destination, key, start, stop string, opts *ZRangeStoreOptions) (int64, error) {
+	if opts == nil {
+		opts = &ZRangeStoreOptions{}
+	}
+	var offset, count int64 = 0, -1
+	if opts.Limit {
+		offset, count = opts.Offset, opts.Count
+	}
+	min, max := start, stop
+	if opts.Rev {
+		min, max = stop, start
+	}
+	var rangeFn func(ss *zset.SortedSet) []*zset.Item
+	switch {
+	case opts.ByLex:
+		r, err := zset.ParseLexRange(min, max)
+		if err != nil {
+			return 0, err
+		}
+		rangeFn = func(ss *zset.SortedSet) []*zset.Item {
+			if opts.Rev {
+				return ss.ZRevRangeByLex(r, offset, count)
+			}
+			return ss.ZRangeByLex(r, offset, count)
+		}
+	case opts.ByScore:
+		minScore, maxScore, mode, err := parseZScoreRange(min, max)
+		if err != nil {
+			return 0, err
+		}
+		rangeFn = func(ss *zset.SortedSet) []*zset.Item {
+			if opts.Rev {
+				return ss.ZRevRangeByScore(minScore, maxScore, offset, count, mode)
+			}
+			return ss.ZRangeByScore(minScore, maxScore, offset, count, mode)
+		}
+	default:
+		startRank, err := strconv.ParseInt(start, 10, 64)
+		if err != nil {
+			return 0, ErrZInvalidRank
+		}
+		stopRank, err := strconv.ParseInt(stop, 10, 64)
+		if err != nil {
+			return 0, ErrZInvalidRank
+		}
+		rangeFn = func(ss *zset.SortedSet) []*zset.Item {
+			if opts.Rev {
+				return ss.ZRevRange(startRank, stopRank)
+			}
+			return ss.ZRange(startRank, stopRank)
+		}
+	}
+	var items []*zset.Item
 	_ = n.exec(func(tx *Tx) error {
-		meta := tx.writeKey(destination, n.newZSet)
+		meta := tx.readKey(key)
 		if !meta.isOk() {
 			return nil
 		}
-		items := n.ZUnion(keys, weights, aggregate)
-		if len(items) == 0 {
+		ss, ok := meta.value.(*zset.SortedSet)
+		if !ok {
 			return nil
 		}
-		for _, item := range items {
-			meta.value.(*zset.SortedSet).ZAdd(item.Member, item.Score)
+		for _, item := range rangeFn(ss) {
+			items = append(items, &zset.Item{Member: item.Member, Score: item.Score})
 		}
-		n.signalModifiedKey(destination, meta)
-		n.notify(func() []patch.Op {
-			return []patch.Op{{Type: patch.OpTypeZUnionStore, Data: &patch.OpZUnionStore{Key: destination, Keys: keys, Weights: weights, Aggregate: aggregate}}}
-		})
-		v = int64(len(items))
 		return nil
 	})
-	return v
+	op := &patch.OpZRangeStore{Key: destination, Src: key, Start: start, Stop: stop, ByScore: opts.ByScore, ByLex: opts.ByLex, Rev: opts.Rev, Offset: offset, Count: count}
+	return n.zStore(destination, items, patch.Op{Type: patch.OpTypeZRangeStore, Data: op}), nil
 }
 
-func (n *Nodis) ZInter(keys []string, weights []float64, aggregate string) []*zset.Item {
-	var v []*zset.Item
+// parseZScoreRange parses a range of scores, a border starting with ( is exclusive
+func parseZScoreRange(min, max string) (float64, float64, int, error) {
+	var mode int
+	if strings.HasPrefix(min, "(") {
+		mode |= zset.MinOpen
+	}
+	if strings.HasPrefix(max, "(") {
+		mode |= zset.MaxOpen
+	}
+	minScore, err := strconv.ParseFloat(strings.TrimPrefix(min, "("), 64)
+	if err != nil {
+		return 0, 0, 0, ErrZInvalidScoreRange
+	}
+	maxScore, err := strconv.ParseFloat(strings.TrimPrefix(max, "("), 64)
+	if err != nil {
+		return 0, 0, 0, ErrZInvalidScoreRange
+	}
+	return minScore, maxScore, mode, nil
+}
+
+// zCombine computes the union or the intersection of the sorted sets and sets stored at keys
+func (n *Nodis) zCombine(keys []string, weights []float64, aggregate string, inter bool) []*zset.Item {
+	var scores = make(map[string]float64)
 	_ = n.exec(func(tx *Tx) error {
-		var items = make(map[string]float64)
+		metas := readZKeys(tx, keys)
 		for i, key := range keys {
-			m := tx.readKey(key)
-			if !m.isOk() {
-				return nil
-			}
 			var weight float64 = 1
 			if i < len(weights) {
 				weight = weights[i]
 			}
-			zs := m.value.(*zset.SortedSet).ZRange(0, -1)
-			for _, z := range zs {
-				var found = true
-				for j, otherKey := range keys {
-					otherZ := tx.readKey(otherKey)
-					if i == j {
-						continue
-					}
-					if !otherZ.value.(*zset.SortedSet).ZExists(z.Member) {
-						found = false
-						break
-					}
+			next := scores
+			if inter {
+				next = make(map[string]float64, len(scores))
+			}
+			for _, item := range zItems(metas[key]) {
+				score := item.Score * weight
+				if math.IsNaN(score) {
+					// inf * 0
+					score = 0
 				}
-				if found {
-					if aggregate == "SUM" || aggregate == "" {
-						if _, ok := items[z.Member]; !ok {
-							items[z.Member] = z.Score * weight
-						} else {
-							items[z.Member] = items[z.Member]*weight + weight*z.Score
-						}
-					}
-					if aggregate == "MIN" {
-						if _, ok := items[z.Member]; !ok || z.Score < items[z.Member] {
-							items[z.Member] = z.Score * weight
-						}
-					}
-					if aggregate == "MAX" {
-						if _, ok := items[z.Member]; !ok || z.Score > items[z.Member] {
-							items[z.Member] = z.Score * weight
-						}
-					}
+				old, ok := scores[item.Member]
+				if ok {
+					score = zAggregate(old, score, aggregate)
+				} else if inter && i > 0 {
+					continue
 				}
+				next[item.Member] = score
 			}
-		}
-		for member, score := range items {
-			v = append(v, &zset.Item{Member: member, Score: score})
+			scores = next
 		}
 		return nil
 	})
+	v := make([]*zset.Item, 0, len(scores))
+	for member, score := range scores {
+		v = append(v, &zset.Item{Member: member, Score: score})
+	}
+	sortZItems(v)
 	return v
 }
 
-// ZInterStore computes the intersection of numkeys sorted sets given by the specified keys, and stores the result in destination.
-func (n *Nodis) ZInterStore(destination string, keys []string, weights []float64, aggregate string) int64 {
-	var v int64
+func zAggregate(a, b float64, aggregate string) float64 {
+	switch aggregate {
+	case "MIN":
+		return math.Min(a, b)
+	case "MAX":
+		return math.Max(a, b)
+	}
+	if v := a + b; !math.IsNaN(v) {
+		return v
+	}
+	// inf + -inf
+	return 0
+}
+
+// readZKeys read locks the keys, a repeated key is locked once
+func readZKeys(tx *Tx, keys []string) map[string]*metadata {
+	metas := make(map[string]*metadata, len(keys))
+	for _, key := range keys {
+		if _, ok := metas[key]; !ok {
+			metas[key] = tx.readKey(key)
+		}
+	}
+	return metas
+}
+
+// zItems returns the members of a sorted set or a set, the members of a set have a score of 1
+func zItems(meta *metadata) []*zset.Item {
+	if !meta.isOk() {
+		return nil
+	}
+	switch v := meta.value.(type) {
+	case *zset.SortedSet:
+		items := v.ZRange(0, -1)
+		for i, item := range items {
+			items[i] = &zset.Item{Member: item.Member, Score: item.Score}
+		}
+		return items
+	case *set.Set:
+		items := make([]*zset.Item, 0, v.SCard())
+		v.Iter(func(member string) bool {
+			items = append(items, &zset.Item{Member: member, Score: 1})
+			return true
+		})
+		return items
+	}
+	return nil
+}
+
+func zHasMember(meta *metadata, member string) bool {
+	if !meta.isOk() {
+		return false
+	}
+	switch v := meta.value.(type) {
+	case *zset.SortedSet:
+		return v.ZExists(member)
+	case *set.Set:
+		return v.SIsMember(member)
+	}
+	return false
+}
+
+// sortZItems sorts the items by score, then by member
+func sortZItems(items []*zset.Item) {
+	slices.SortFunc(items, func(a, b *zset.Item) int {
+		if c := cmp.Compare(a.Score, b.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Member, b.Member)
+	})
+}
+
+// zStore replaces the value stored at destination with a sorted set holding the items,
+// destination is deleted if there are no items.
+func (n *Nodis) zStore(destination string, items []*zset.Item, op patch.Op) int64 {
 	_ = n.exec(func(tx *Tx) error {
-		items := n.ZInter(keys, weights, aggregate)
 		meta := tx.writeKey(destination, n.newZSet)
-		if !meta.isOk() {
-			return nil
+		if meta.valueType == ds.Hash {
+			n.indexHash(destination, nil)
 		}
+		n.notify(func() []patch.Op {
+			return []patch.Op{op}
+		})
 		if len(items) == 0 {
+			tx.delKey(destination)
 			return nil
 		}
+		ss := zset.NewSortedSetWithEncodings(n.store.encodings)
 		for _, item := range items {
-			meta.value.(*zset.SortedSet).ZAdd(item.Member, item.Score)
+			ss.ZAdd(item.Member, item.Score)
 		}
+		meta.setValue(ss)
+		meta.key.Expiration = 0
 		n.signalModifiedKey(destination, meta)
+		return nil
+	})
+	return int64(len(items))
+}
+
+// ZPopMin removes and returns up to count members with the lowest scores in the sorted set at key.
+func (n *Nodis) ZPopMin(key string, count int64) []*zset.Item {
+	return n.zPop(key, count, false)
+}
+
+// ZPopMax removes and returns up to count members with the highest scores in the sorted set at key.
+func (n *Nodis) ZPopMax(key string, count int64) []*zset.Item {
+	return n.zPop(key, count, true)
+}
+
+func (n *Nodis) zPop(key string, count int64, max bool) []*zset.Item {
+	var v []*zset.Item
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.writeKey(key, nil)
+		if !meta.isOk() {
+			return nil
+		}
+		ss, ok := meta.value.(*zset.SortedSet)
+		if !ok {
+			return nil
+		}
+		if max {
+			v = ss.ZPopMax(count)
+		} else {
+			v = ss.ZPopMin(count)
+		}
+		if len(v) == 0 {
+			return nil
+		}
+		members := make([]string, len(v))
+		for i, item := range v {
+			members[i] = item.Member
+		}
+		n.signalModifiedKey(key, meta)
 		n.notify(func() []patch.Op {
-			return []patch.Op{{Type: patch.OpTypeZInterStore, Data: &patch.OpZInterStore{Key: destination, Keys: keys, Weights: weights, Aggregate: aggregate}}}
+			return []patch.Op{{Type: patch.OpTypeZRem, Data: &patch.OpZRem{Key: key, Members: members}}}
 		})
-		v = int64(len(items))
+		return nil
+	})
+	return v
+}
+
+// ZMPop pops up to count members from the first non-empty sorted set, the members with the
+// highest scores are popped if max is true.
+func (n *Nodis) ZMPop(keys []string, max bool, count int64) (string, []*zset.Item) {
+	for _, key := range keys {
+		if items := n.zPop(key, count, max); len(items) > 0 {
+			return key, items
+		}
+	}
+	return "", nil
+}
+
+// BZPopMin is the blocking variant of ZPopMin, it pops a member from the first non-empty sorted set
+// and blocks until a member is added or the timeout is reached, a zero timeout blocks indefinitely.
+func (n *Nodis) BZPopMin(timeout time.Duration, keys ...string) (string, *zset.Item) {
+	key, items := n.BZMPop(timeout, keys, false, 1)
+	if len(items) == 0 {
+		return "", nil
+	}
+	return key, items[0]
+}
+
+// BZPopMax is the blocking variant of ZPopMax, see BZPopMin.
+func (n *Nodis) BZPopMax(timeout time.Duration, keys ...string) (string, *zset.Item) {
+	key, items := n.BZMPop(timeout, keys, true, 1)
+	if len(items) == 0 {
+		return "", nil
+	}
+	return key, items[0]
+}
+
+// BZMPop is the blocking variant of ZMPop, see BZPopMin.
+func (n *Nodis) BZMPop(timeout time.Duration, keys []string, max bool, count int64) (string, []*zset.Item) {
+	key, v, _ := n.bZMPop(nil, timeout, keys, max, count)
+	return key, v
+}
+
+func (n *Nodis) bZMPop(c *blockedClient, timeout time.Duration, keys []string, max bool, count int64) (string, []*zset.Item, error) {
+	var v []*zset.Item
+	key, err := n.block(c, timeout, keys, func(key string) bool {
+		v = n.zPop(key, count, max)
+		return len(v) > 0
+	})
+	return key, v, err
+}
+
+// ZRandMember returns count random members of the sorted set at key, the members are distinct
+// if count is positive and may be repeated if count is negative.
+func (n *Nodis) ZRandMember(key string, count int64) []*zset.Item {
+	var v []*zset.Item
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readKey(key)
+		if !meta.isOk() {
+			return nil
+		}
+		v = meta.value.(*zset.SortedSet).ZRandMember(count)
+		return nil
+	})
+	return v
+}
+
+// ZMScore returns the scores of the members, the score of a missing member is nil.
+func (n *Nodis) ZMScore(key string, members ...string) []*float64 {
+	v := make([]*float64, len(members))
+	_ = n.exec(func(tx *Tx) error {
+		meta := tx.readFields(key, members...)
+		if !meta.isOk() {
+			return nil
+		}
+		ss := meta.value.(*zset.SortedSet)
+		for i, member := range members {
+			if score, err := ss.ZScore(member); err == nil {
+				v[i] = &score
+			}
+		}
 		return nil
 	})
 	return v
@@ -2,7 +2,14 @@ package nodis
 
 import (
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/diiyw/nodis/ds/zset"
+	"github.com/diiyw/nodis/patch"
 )
 
 func TestZSet_ZAdd(t *testing.T) {
@@ -44,11 +51,11 @@ func TestZSet_ZRange(t *testing.T) {
 	if len(range3) != 2 {
 		t.Errorf("ZRevRange() = %v, want %v", len(range3), 2)
 	}
-	if range3[0] != "count" {
-		t.Errorf("ZRevRange() = %v, want %v", range3[0], "count")
+	if range3[0] != "b" {
+		t.Errorf("ZRevRange() = %v, want %v", range3[0], "b")
 	}
-	if range3[1] != "b" {
-		t.Errorf("ZRevRange() = %v, want %v", range3[1], "b")
+	if range3[1] != "a" {
+		t.Errorf("ZRevRange() = %v, want %v", range3[1], "a")
 	}
 }
 
@@ -91,6 +98,40 @@ func TestZSet_ZRank(t *testing.T) {
 	}
 }
 
+func TestZSet_ZRangeFromRankOne(t *testing.T) {
+	for _, encoding := range []string{"listpack", "skiplist"} {
+		n := Open(&Options{})
+		// the long members don't fit in a listpack
+		var prefix string
+		if encoding == "skiplist" {
+			prefix = strings.Repeat("m", 100)
+		}
+		var members []string
+		for i := 0; i < 5; i++ {
+			members = append(members, prefix+strconv.Itoa(i))
+			n.ZAdd("zset", members[i], float64(i))
+		}
+		n.peek("zset", func(meta *metadata) {
+			if got := meta.value.(*zset.SortedSet).Encoding(); got != encoding {
+				t.Fatalf("Encoding() = %s, want %s", got, encoding)
+			}
+		})
+		for _, start := range []int64{1, -4} {
+			if got := n.ZRange("zset", start, 2); !reflect.DeepEqual(got, members[1:3]) {
+				t.Errorf("%s ZRange(%d, 2) = %v, want %v", encoding, start, got, members[1:3])
+			}
+			want := []string{members[3], members[2]}
+			if got := n.ZRevRange("zset", start, 2); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s ZRevRange(%d, 2) = %v, want %v", encoding, start, got, want)
+			}
+		}
+		if got := n.ZRange("zset", -10, 0); !reflect.DeepEqual(got, members[:1]) {
+			t.Errorf("%s ZRange(-10, 0) = %v, want %v", encoding, got, members[:1])
+		}
+		_ = n.Close()
+	}
+}
+
 func TestZSet_ZRevRange(t *testing.T) {
 	opt := &Options{}
 	os.RemoveAll("testdata")
@@ -263,11 +304,11 @@ func TestZSet_ZRangeWithScores(t *testing.T) {
 	if len(range1) != 2 {
 		t.Errorf("ZRangeWithScores() = %v, want %v", len(range1), 2)
 	}
-	if range1[0].Member != "a" {
-		t.Errorf("ZRangeWithScores() = %v, want %v", range1[0].Member, "a")
+	if range1[0].Member != "b" {
+		t.Errorf("ZRangeWithScores() = %v, want %v", range1[0].Member, "b")
 	}
-	if range1[1].Member != "b" {
-		t.Errorf("ZRangeWithScores() = %v, want %v", range1[1].Member, "b")
+	if range1[1].Member != "count" {
+		t.Errorf("ZRangeWithScores() = %v, want %v", range1[1].Member, "count")
 	}
 }
 
@@ -283,11 +324,11 @@ func TestZSet_ZRevRangeWithScores(t *testing.T) {
 	if len(range1) != 2 {
 		t.Errorf("ZRevRangeWithScores() = %v, want %v", len(range1), 2)
 	}
-	if range1[0].Member != "count" {
-		t.Errorf("ZRevRangeWithScores() = %v, want %v", range1[0].Member, "count")
+	if range1[0].Member != "b" {
+		t.Errorf("ZRevRangeWithScores() = %v, want %v", range1[0].Member, "b")
 	}
-	if range1[1].Member != "b" {
-		t.Errorf("ZRevRangeWithScores() = %v, want %v", range1[1].Member, "b")
+	if range1[1].Member != "a" {
+		t.Errorf("ZRevRangeWithScores() = %v, want %v", range1[1].Member, "a")
 	}
 }
 
@@ -330,3 +371,227 @@ func TestZSet_ZRevRangeByScoreWithScores(t *testing.T) {
 		t.Errorf("ZRevRangeByScoreWithScores() = %v, want %v", range1[1].Member, "a")
 	}
 }
+
+func TestZSet_ZRangeByLex(t *testing.T) {
+	opt := &Options{}
+	os.RemoveAll("testdata")
+	n := Open(opt)
+	defer n.Close()
+	for _, m := range []string{"apple", "apricot", "banana", "blueberry", "cherry"} {
+		n.ZAdd("zset", m, 0)
+	}
+	range1, err := n.ZRangeByLex("zset", "[ap", "[ap\xff", 0, -1)
+	if err != nil || !reflect.DeepEqual(range1, []string{"apple", "apricot"}) {
+		t.Errorf("ZRangeByLex() = %v, %v, want %v", range1, err, []string{"apple", "apricot"})
+	}
+	range2, _ := n.ZRevRangeByLex("zset", "(apricot", "+", 1, 2)
+	if !reflect.DeepEqual(range2, []string{"blueberry", "banana"}) {
+		t.Errorf("ZRevRangeByLex() = %v, want %v", range2, []string{"blueberry", "banana"})
+	}
+	if _, err := n.ZRangeByLex("zset", "a", "+", 0, -1); err != zset.ErrInvalidLexRange {
+		t.Errorf("ZRangeByLex() error = %v, want %v", err, zset.ErrInvalidLexRange)
+	}
+	if count, _ := n.ZLexCount("zset", "[b", "(c"); count != 2 {
+		t.Errorf("ZLexCount() = %v, want %v", count, 2)
+	}
+	if removed, _ := n.ZRemRangeByLex("zset", "-", "(b"); removed != 2 {
+		t.Errorf("ZRemRangeByLex() = %v, want %v", removed, 2)
+	}
+	if n.ZCard("zset") != 3 {
+		t.Errorf("ZCard() = %v, want %v", n.ZCard("zset"), 3)
+	}
+}
+
+func zMembers(items []*zset.Item) []string {
+	members := make([]string, len(items))
+	for i, item := range items {
+		members[i] = item.Member
+	}
+	return members
+}
+
+func TestZSet_ZPop(t *testing.T) {
+	opt := &Options{}
+	os.RemoveAll("testdata")
+	n := Open(opt)
+	defer n.Close()
+	n.ZAdd("zset", "a", 1)
+	n.ZAdd("zset", "b", 2)
+	n.ZAdd("zset", "c", 3)
+	if got := zMembers(n.ZPopMin("zset", 2)); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("ZPopMin() = %v, want %v", got, []string{"a", "b"})
+	}
+	if got := n.ZPopMax("zset", 1); len(got) != 1 || got[0].Member != "c" || got[0].Score != 3 {
+		t.Errorf("ZPopMax() = %v", got)
+	}
+	if got := n.ZPopMin("none", 1); got != nil {
+		t.Errorf("ZPopMin() = %v, want nil", got)
+	}
+	n.ZAdd("zset2", "x", 1)
+	n.ZAdd("zset2", "y", 2)
+	key, items := n.ZMPop([]string{"zset", "zset2"}, true, 5)
+	if key != "zset2" || !reflect.DeepEqual(zMembers(items), []string{"y", "x"}) {
+		t.Errorf("ZMPop() = %v, %v", key, zMembers(items))
+	}
+	if key, _ := n.ZMPop([]string{"zset", "zset2"}, false, 1); key != "" {
+		t.Errorf("ZMPop() = %v, want empty", key)
+	}
+}
+
+func TestZSet_BZPop(t *testing.T) {
+	opt := &Options{}
+	os.RemoveAll("testdata")
+	n := Open(opt)
+	defer n.Close()
+	n.ZAdd("zset", "a", 1)
+	if key, item := n.BZPopMin(time.Second, "none", "zset"); key != "zset" || item.Member != "a" {
+		t.Errorf("BZPopMin() = %v, %v", key, item)
+	}
+	if key, item := n.BZPopMax(10*time.Millisecond, "zset"); key != "" || item != nil {
+		t.Errorf("BZPopMax() = %v, %v, want timeout", key, item)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		n.ZAdd("zset2", "b", 2)
+	}()
+	key, items := n.BZMPop(0, []string{"zset", "zset2"}, true, 1)
+	if key != "zset2" || !reflect.DeepEqual(zMembers(items), []string{"b"}) {
+		t.Errorf("BZMPop() = %v, %v", key, items)
+	}
+}
+
+func TestZSet_ZRandMember(t *testing.T) {
+	opt := &Options{}
+	os.RemoveAll("testdata")
+	n := Open(opt)
+	defer n.Close()
+	n.ZAdd("zset", "a", 1)
+	n.ZAdd("zset", "b", 2)
+	if got := n.ZRandMember("zset", 3); len(got) != 2 {
+		t.Errorf("ZRandMember() = %v, want %v members", len(got), 2)
+	}
+	if got := n.ZRandMember("zset", -3); len(got) != 3 {
+		t.Errorf("ZRandMember() = %v, want %v members", len(got), 3)
+	}
+	scores := n.ZMScore("zset", "a", "none", "b")
+	if len(scores) != 3 || *scores[0] != 1 || scores[1] != nil || *scores[2] != 2 {
+		t.Errorf("ZMScore() = %v", scores)
+	}
+}
+
+func TestZSet_ZUnionInter(t *testing.T) {
+	opt := &Options{}
+	os.RemoveAll("testdata")
+	n := Open(opt)
+	defer n.Close()
+	n.ZAdd("zset1", "a", 1)
+	n.ZAdd("zset1", "b", 2)
+	n.ZAdd("zset2", "b", 3)
+	n.ZAdd("zset2", "c", 4)
+	n.SAdd("set", "a", "c")
+	union := n.ZUnion([]string{"zset1", "zset2", "set"}, []float64{1, 2, 10}, "")
+	if got := zMembers(union); !reflect.DeepEqual(got, []string{"b", "a", "c"}) {
+		t.Errorf("ZUnion() = %v", got)
+	}
+	if union[0].Score != 8 || union[1].Score != 11 || union[2].Score != 18 {
+		t.Errorf("ZUnion() scores = %v, %v, %v", union[0].Score, union[1].Score, union[2].Score)
+	}
+	inter := n.ZInter([]string{"zset1", "zset2"}, nil, "MAX")
+	if len(inter) != 1 || inter[0].Member != "b" || inter[0].Score != 3 {
+		t.Errorf("ZInter() = %v", inter)
+	}
+	if got := n.ZInter([]string{"zset1", "none"}, nil, ""); len(got) != 0 {
+		t.Errorf("ZInter() = %v, want empty", got)
+	}
+	if got := n.ZInterCard([]string{"zset1", "set"}, 0); got != 1 {
+		t.Errorf("ZInterCard() = %v, want %v", got, 1)
+	}
+	if got := n.ZUnionStore("zset1", []string{"zset1", "set"}, nil, "MIN"); got != 3 {
+		t.Errorf("ZUnionStore() = %v, want %v", got, 3)
+	}
+	if score, _ := n.ZScore("zset1", "c"); score != 1 {
+		t.Errorf("ZScore() = %v, want %v", score, 1)
+	}
+	if got := n.ZInterStore("dst", []string{"zset1", "none"}, nil, ""); got != 0 || n.Exists("dst") != 0 {
+		t.Errorf("ZInterStore() = %v, want %v", got, 0)
+	}
+}
+
+func TestZSet_ZDiff(t *testing.T) {
+	opt := &Options{}
+	os.RemoveAll("testdata")
+	n := Open(opt)
+	defer n.Close()
+	n.ZAdd("zset1", "a", 1)
+	n.ZAdd("zset1", "b", 2)
+	n.ZAdd("zset1", "c", 3)
+	n.ZAdd("zset2", "a", 1)
+	n.SAdd("set", "c")
+	if got := zMembers(n.ZDiff([]string{"zset1", "zset2", "set"})); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("ZDiff() = %v", got)
+	}
+	if got := n.ZDiffStore("dst", []string{"zset1", "zset2"}); got != 2 {
+		t.Errorf("ZDiffStore() = %v, want %v", got, 2)
+	}
+	if got := n.ZRange("dst", 0, -1); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("ZRange() = %v", got)
+	}
+}
+
+func TestZSet_ZRangeStore(t *testing.T) {
+	opt := &Options{}
+	os.RemoveAll("testdata")
+	n := Open(opt)
+	defer n.Close()
+	for i, m := range []string{"a", "b", "c", "d"} {
+		n.ZAdd("zset", m, float64(i+1))
+	}
+	tests := []struct {
+		start, stop string
+		opts        *ZRangeStoreOptions
+		want        []string
+	}{
+		{"0", "-1", nil, []string{"a", "b", "c", "d"}},
+		{"0", "0", &ZRangeStoreOptions{Rev: true}, []string{"d"}},
+		{"(1", "3", &ZRangeStoreOptions{ByScore: true}, []string{"b", "c"}},
+		{"+inf", "-inf", &ZRangeStoreOptions{ByScore: true, Rev: true, Limit: true, Offset: 1, Count: 2}, []string{"b", "c"}},
+		{"[b", "(d", &ZRangeStoreOptions{ByLex: true}, []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		v, err := n.ZRangeStore("dst", "zset", tt.start, tt.stop, tt.opts)
+		if err != nil || v != int64(len(tt.want)) {
+			t.Errorf("ZRangeStore(%v, %v) = %v, %v", tt.start, tt.stop, v, err)
+		}
+		if got := n.ZRange("dst", 0, -1); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ZRangeStore(%v, %v) stored %v, want %v", tt.start, tt.stop, got, tt.want)
+		}
+	}
+	if _, err := n.ZRangeStore("dst", "zset", "a", "b", &ZRangeStoreOptions{ByScore: true}); err != ErrZInvalidScoreRange {
+		t.Errorf("ZRangeStore() error = %v, want %v", err, ErrZInvalidScoreRange)
+	}
+}
+
+func TestZSet_Patch(t *testing.T) {
+	_ = os.RemoveAll("testdata")
+	n := Open(&Options{})
+	n2 := Open(&Options{})
+	n.WatchKey([]string{"*"}, func(op patch.Op) {
+		_ = n2.ApplyPatch(op)
+	})
+	n.ZAdd("zset", "a", 1)
+	n.ZAdd("zset", "b", 2)
+	n.ZAdd("zset", "c", 3)
+	// the patches are delivered asynchronously
+	time.Sleep(20 * time.Millisecond)
+	n.ZPopMin("zset", 1)
+	time.Sleep(20 * time.Millisecond)
+	n.ZRangeStore("range", "zset", "0", "0", nil)
+	time.Sleep(20 * time.Millisecond)
+	n.ZDiffStore("diff", []string{"zset", "range"})
+	time.Sleep(50 * time.Millisecond)
+	for _, key := range []string{"zset", "range", "diff"} {
+		if got, want := n2.ZRange(key, 0, -1), n.ZRange(key, 0, -1); !reflect.DeepEqual(got, want) {
+			t.Errorf("ZRange(%v) = %v, want %v", key, got, want)
+		}
+	}
+}